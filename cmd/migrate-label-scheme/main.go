@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"github.com/ti-community-infra/tichi/internal/pkg/labelmigrator"
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+	"k8s.io/test-infra/prow/config/secret"
+	prowflagutil "k8s.io/test-infra/prow/flagutil"
+	"sigs.k8s.io/yaml"
+)
+
+// options specifies command line parameters.
+type options struct {
+	dryRun                   bool
+	externalPluginConfigPath string
+	fromLgtmLabel            string
+	fromCanMergeLabel        string
+	repos                    prowflagutil.Strings
+
+	github prowflagutil.GitHubOptions
+}
+
+func (o *options) DefaultAndValidate() error {
+	if o.externalPluginConfigPath == "" {
+		return errors.New("required flag --external-plugin-config-path was unset")
+	}
+	if len(o.repos.Strings()) == 0 {
+		return errors.New("required flag --repo was unset")
+	}
+	if o.fromLgtmLabel == "" || o.fromCanMergeLabel == "" {
+		return errors.New("flags --from-lgtm-label and --from-can-merge-label cannot be empty")
+	}
+	return o.github.Validate(o.dryRun)
+}
+
+func (o *options) gatherOptions(flag *flag.FlagSet, args []string) error {
+	flag.BoolVar(&o.dryRun, "dry-run", true, "Dry run for testing. Uses API tokens but does not mutate.")
+	flag.StringVar(&o.externalPluginConfigPath, "external-plugin-config-path", "",
+		"Path to external_plugin_config.yaml.")
+	flag.StringVar(&o.fromLgtmLabel, "from-lgtm-label", externalplugins.DefaultLgtmLabel,
+		"The LGTM label format used before the migration.")
+	flag.StringVar(&o.fromCanMergeLabel, "from-can-merge-label", externalplugins.CanMergeLabel,
+		"The can merge label used before the migration.")
+	flag.Var(&o.repos, "repo", "The org or org/repo whose open pull requests will be relabeled.")
+	o.github.AddFlags(flag)
+
+	if err := flag.Parse(args); err != nil {
+		return fmt.Errorf("parse flags: %v", err)
+	}
+	if err := o.DefaultAndValidate(); err != nil {
+		return fmt.Errorf("invalid options: %v", err)
+	}
+
+	return nil
+}
+
+func main() {
+	o := options{}
+	if err := o.gatherOptions(flag.CommandLine, os.Args[1:]); err != nil {
+		logrus.Fatalf("Error parsing options - %v", err)
+	}
+
+	log := logrus.StandardLogger().WithField("component", "migrate-label-scheme")
+
+	bytes, err := ioutil.ReadFile(o.externalPluginConfigPath)
+	if err != nil {
+		log.WithError(err).Fatal("Error reading external plugin config.")
+	}
+	config := &externalplugins.Configuration{}
+	if err := yaml.Unmarshal(bytes, config); err != nil {
+		log.WithError(err).Fatal("Error unmarshal external plugin config.")
+	}
+	if err := config.Validate(); err != nil {
+		log.WithError(err).Fatal("Invalid external plugin config.")
+	}
+
+	secretAgent := &secret.Agent{}
+	if err := secretAgent.Start([]string{o.github.TokenPath}); err != nil {
+		log.WithError(err).Fatal("Error starting secrets agent.")
+	}
+
+	githubClient, err := o.github.GitHubClient(secretAgent, o.dryRun)
+	if err != nil {
+		log.WithError(err).Fatal("Error getting GitHub client.")
+	}
+
+	// Skip https verify.
+	//nolint:gosec
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	client := &http.Client{Transport: tr}
+	ol := &ownersclient.OwnersClient{Client: client}
+
+	var orgs, repos []string
+	for _, repo := range o.repos.Strings() {
+		if strings.Contains(repo, "/") {
+			repos = append(repos, repo)
+		} else {
+			orgs = append(orgs, repo)
+		}
+	}
+
+	migrator := &labelmigrator.Migrator{
+		From: &externalplugins.LabelScheme{
+			LgtmLabel:     o.fromLgtmLabel,
+			CanMergeLabel: o.fromCanMergeLabel,
+		},
+		Config:       config,
+		GitHubClient: githubClient,
+		OwnersLoader: ol,
+		Log:          log,
+	}
+	if err := migrator.MigrateAll(orgs, repos); err != nil {
+		log.WithError(err).Fatal("Error migrating labels.")
+	}
+	log.Info("Labels have been migrated.")
+}
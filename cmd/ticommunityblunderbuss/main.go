@@ -30,8 +30,11 @@ type options struct {
 	github prowflagutil.GitHubOptions
 
 	externalPluginsConfig string
+	reviewerPreferences   string
 
 	webhookSecretFile string
+
+	updatePeriod time.Duration
 }
 
 // validate validates github options.
@@ -51,9 +54,13 @@ func gatherOptions() options {
 	fs.IntVar(&o.port, "port", 80, "Port to listen on.")
 	fs.StringVar(&o.externalPluginsConfig, "external-plugins-config",
 		"/etc/external_plugins_config/external_plugins_config.yaml", "Path to external plugin config file.")
+	fs.StringVar(&o.reviewerPreferences, "reviewer-preferences", "",
+		"Path to the reviewer preferences file, no one has any preferences if it is empty.")
 	fs.BoolVar(&o.dryRun, "dry-run", true, "Dry run for testing. Uses API tokens but does not mutate.")
 	fs.StringVar(&o.webhookSecretFile, "hmac-secret-file",
 		"/etc/webhook/hmac", "Path to the file containing the GitHub HMAC secret.")
+	fs.DurationVar(&o.updatePeriod, "update-period", time.Hour,
+		"Period duration for periodic reminders of the review requests.")
 
 	for _, group := range []flagutil.OptionGroup{&o.github} {
 		group.AddFlags(fs)
@@ -82,6 +89,11 @@ func main() {
 		log.WithError(err).Fatalf("Error loading external plugin config from %q.", o.externalPluginsConfig)
 	}
 
+	rpa := &tiexternalplugins.ReviewerPreferencesAgent{}
+	if err := rpa.Start(o.reviewerPreferences); err != nil {
+		log.WithError(err).Fatalf("Error loading reviewer preferences from %q.", o.reviewerPreferences)
+	}
+
 	githubClient, err := o.github.GitHubClient(secretAgent, o.dryRun)
 	if err != nil {
 		logrus.WithError(err).Fatal("Error getting GitHub client.")
@@ -97,13 +109,23 @@ func main() {
 	ol := &ownersclient.OwnersClient{Client: client}
 
 	server := &server{
-		tokenGenerator: secretAgent.GetTokenGenerator(o.webhookSecretFile),
-		gc:             githubClient,
-		ol:             ol,
-		configAgent:    epa,
-		log:            log,
+		tokenGenerator:   secretAgent.GetTokenGenerator(o.webhookSecretFile),
+		gc:               githubClient,
+		ol:               ol,
+		configAgent:      epa,
+		preferencesAgent: rpa,
+		log:              log,
 	}
 
+	defer interrupts.WaitForGracefulShutdown()
+	interrupts.TickLiteral(func() {
+		start := time.Now()
+		if err := blunderbuss.HandleAll(log, githubClient, epa.Config(), rpa.Preferences(), ol); err != nil {
+			log.WithError(err).Error("Error during periodic reminders of the review requests.")
+		}
+		log.WithField("duration", fmt.Sprintf("%v", time.Since(start))).Info("Periodic reminders complete.")
+	}, o.updatePeriod)
+
 	health := pjutil.NewHealth()
 	health.ServeReady()
 
@@ -114,7 +136,6 @@ func main() {
 	externalplugins.ServeExternalPluginHelp(mux, log, helpProvider)
 	httpServer := &http.Server{Addr: ":" + strconv.Itoa(o.port), Handler: mux}
 
-	defer interrupts.WaitForGracefulShutdown()
 	interrupts.ListenAndServe(httpServer, 5*time.Second)
 }
 
@@ -124,9 +145,10 @@ type server struct {
 	tokenGenerator func() []byte
 	gc             github.Client
 
-	ol          ownersclient.OwnersLoader
-	configAgent *tiexternalplugins.ConfigAgent
-	log         *logrus.Entry
+	ol               ownersclient.OwnersLoader
+	configAgent      *tiexternalplugins.ConfigAgent
+	preferencesAgent *tiexternalplugins.ReviewerPreferencesAgent
+	log              *logrus.Entry
 }
 
 func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -150,6 +172,7 @@ func (s *server) handleEvent(eventType, eventGUID string, payload []byte) error
 	)
 	// Get external plugins config.
 	config := s.configAgent.Config()
+	preferences := s.preferencesAgent.Preferences()
 	switch eventType {
 	case "issue_comment":
 		var ice github.IssueCommentEvent
@@ -157,7 +180,12 @@ func (s *server) handleEvent(eventType, eventGUID string, payload []byte) error
 			return err
 		}
 		go func() {
-			if err := blunderbuss.HandleIssueCommentEvent(s.gc, &ice, config, s.ol, l); err != nil {
+			if err := blunderbuss.HandleIssueCommentEvent(s.gc, &ice, config, preferences, s.ol, l); err != nil {
+				l.WithField("event-type", eventType).WithError(err).Info("Error handling event.")
+			}
+		}()
+		go func() {
+			if err := blunderbuss.HandleReviewerPreferencesCommand(s.gc, &ice, config, preferences, l); err != nil {
 				l.WithField("event-type", eventType).WithError(err).Info("Error handling event.")
 			}
 		}()
@@ -166,8 +194,28 @@ func (s *server) handleEvent(eventType, eventGUID string, payload []byte) error
 		if err := json.Unmarshal(payload, &pe); err != nil {
 			return err
 		}
+		// The author association is not in the github.PullRequest.
+		var association struct {
+			PullRequest struct {
+				AuthorAssociation string `json:"author_association"`
+			} `json:"pull_request"`
+		}
+		if err := json.Unmarshal(payload, &association); err != nil {
+			return err
+		}
+		go func() {
+			if err := blunderbuss.HandlePullRequestEvent(s.gc, &pe, association.PullRequest.AuthorAssociation,
+				config, preferences, s.ol, l); err != nil {
+				l.WithField("event-type", eventType).WithError(err).Info("Error handling event.")
+			}
+		}()
+	case "pull_request_review":
+		var re github.ReviewEvent
+		if err := json.Unmarshal(payload, &re); err != nil {
+			return err
+		}
 		go func() {
-			if err := blunderbuss.HandlePullRequestEvent(s.gc, &pe, config, s.ol, l); err != nil {
+			if err := blunderbuss.HandlePullRequestReviewEvent(s.gc, &re, config, l); err != nil {
 				l.WithField("event-type", eventType).WithError(err).Info("Error handling event.")
 			}
 		}()
@@ -17,26 +17,32 @@ import (
 	"k8s.io/test-infra/pkg/flagutil"
 	"k8s.io/test-infra/prow/config/secret"
 	prowflagutil "k8s.io/test-infra/prow/flagutil"
+	git "k8s.io/test-infra/prow/git/v2"
 	"k8s.io/test-infra/prow/github"
 	"k8s.io/test-infra/prow/interrupts"
 	"k8s.io/test-infra/prow/pjutil"
 	"k8s.io/test-infra/prow/pluginhelp/externalplugins"
+	"k8s.io/test-infra/prow/plugins"
 )
 
 type options struct {
 	port int
 
-	dryRun bool
-	github prowflagutil.GitHubOptions
+	pluginConfig string
+	dryRun       bool
+	github       prowflagutil.GitHubOptions
+	git          prowflagutil.GitOptions
 
 	externalPluginsConfig string
 
+	reconcilePeriod time.Duration
+
 	webhookSecretFile string
 }
 
 // validate validates github options.
 func (o *options) validate() error {
-	for idx, group := range []flagutil.OptionGroup{&o.github} {
+	for idx, group := range []flagutil.OptionGroup{&o.github, &o.git} {
 		if err := group.Validate(o.dryRun); err != nil {
 			return fmt.Errorf("%d: %w", idx, err)
 		}
@@ -49,13 +55,16 @@ func gatherOptions() options {
 	o := options{}
 	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 	fs.IntVar(&o.port, "port", 80, "Port to listen on.")
+	fs.StringVar(&o.pluginConfig, "plugin-config", "/etc/plugins/plugins.yaml", "Path to plugin config file.")
 	fs.StringVar(&o.externalPluginsConfig, "external-plugins-config",
 		"/etc/external_plugins_config/external_plugins_config.yaml", "Path to external plugin config file.")
 	fs.BoolVar(&o.dryRun, "dry-run", true, "Dry run for testing. Uses API tokens but does not mutate.")
+	fs.DurationVar(&o.reconcilePeriod, "reconcile-period", time.Hour,
+		"Period duration for periodic reconciliations of all PRs.")
 	fs.StringVar(&o.webhookSecretFile, "hmac-secret-file",
 		"/etc/webhook/hmac", "Path to the file containing the GitHub HMAC secret.")
 
-	for _, group := range []flagutil.OptionGroup{&o.github} {
+	for _, group := range []flagutil.OptionGroup{&o.github, &o.git} {
 		group.AddFlags(fs)
 	}
 	_ = fs.Parse(os.Args[1:])
@@ -78,6 +87,11 @@ func main() {
 		logrus.WithError(err).Fatal("Error starting secrets agent.")
 	}
 
+	pa := &plugins.ConfigAgent{}
+	if err := pa.Start(o.pluginConfig, false); err != nil {
+		log.WithError(err).Fatalf("Error loading plugin config from %q.", o.pluginConfig)
+	}
+
 	epa := &tiexternalplugins.ConfigAgent{}
 	if err := epa.Start(o.externalPluginsConfig, false); err != nil {
 		log.WithError(err).Fatalf("Error loading external plugin config from %q.", o.externalPluginsConfig)
@@ -89,6 +103,11 @@ func main() {
 	}
 	githubClient.Throttle(360, 360)
 
+	gitClient, err := o.git.GitClient(githubClient, secretAgent.GetTokenGenerator(o.github.TokenPath), nil, o.dryRun)
+	if err != nil {
+		logrus.WithError(err).Fatal("Error getting Git client.")
+	}
+
 	// Skip https verify.
 	//nolint:gosec
 	tr := &http.Transport{
@@ -100,11 +119,21 @@ func main() {
 	server := &server{
 		tokenGenerator: secretAgent.GetTokenGenerator(o.webhookSecretFile),
 		gc:             githubClient,
+		gitClient:      gitClient,
 		ol:             ol,
 		configAgent:    epa,
 		log:            log,
 	}
 
+	defer interrupts.WaitForGracefulShutdown()
+	interrupts.TickLiteral(func() {
+		start := time.Now()
+		if err := lgtm.HandleAll(log, githubClient, pa.Config(), epa.Config(), ol); err != nil {
+			log.WithError(err).Error("Error during periodic reconciliation of all PRs.")
+		}
+		log.WithField("duration", fmt.Sprintf("%v", time.Since(start))).Info("Periodic reconciliation complete.")
+	}, o.reconcilePeriod)
+
 	health := pjutil.NewHealth()
 	health.ServeReady()
 
@@ -115,7 +144,6 @@ func main() {
 	externalplugins.ServeExternalPluginHelp(mux, log, helpProvider)
 	httpServer := &http.Server{Addr: ":" + strconv.Itoa(o.port), Handler: mux}
 
-	defer interrupts.WaitForGracefulShutdown()
 	interrupts.ListenAndServe(httpServer, 5*time.Second)
 }
 
@@ -124,6 +152,7 @@ func main() {
 type server struct {
 	tokenGenerator func() []byte
 	gc             github.Client
+	gitClient      git.ClientFactory
 
 	ol          ownersclient.OwnersLoader
 	configAgent *tiexternalplugins.ConfigAgent
@@ -188,7 +217,7 @@ func (s *server) handleEvent(eventType, eventGUID string, payload []byte) error
 			return err
 		}
 		go func() {
-			if err := lgtm.HandlePullRequestEvent(s.gc, &pe, config, l); err != nil {
+			if err := lgtm.HandlePullRequestEvent(s.gc, s.gitClient, &pe, config, s.ol, l); err != nil {
 				l.WithField("event-type", eventType).WithError(err).Info("Error handling event.")
 			}
 		}()
@@ -13,31 +13,40 @@ import (
 	"github.com/sirupsen/logrus"
 	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
 	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins/merge"
+	"github.com/ti-community-infra/tichi/internal/pkg/mergequeue"
 	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
 	"k8s.io/test-infra/pkg/flagutil"
 	"k8s.io/test-infra/prow/commentpruner"
 	"k8s.io/test-infra/prow/config/secret"
 	prowflagutil "k8s.io/test-infra/prow/flagutil"
+	git "k8s.io/test-infra/prow/git/v2"
 	"k8s.io/test-infra/prow/github"
 	"k8s.io/test-infra/prow/interrupts"
 	"k8s.io/test-infra/prow/pjutil"
 	"k8s.io/test-infra/prow/pluginhelp/externalplugins"
+	"k8s.io/test-infra/prow/plugins"
 )
 
 type options struct {
 	port int
 
-	dryRun bool
-	github prowflagutil.GitHubOptions
+	pluginConfig string
+	dryRun       bool
+	github       prowflagutil.GitHubOptions
+	git          prowflagutil.GitOptions
 
 	externalPluginsConfig string
 
+	mergeQueuePeriod time.Duration
+	reconcilePeriod  time.Duration
+	freezePeriod     time.Duration
+
 	webhookSecretFile string
 }
 
 // validate validates github options.
 func (o *options) validate() error {
-	for idx, group := range []flagutil.OptionGroup{&o.github} {
+	for idx, group := range []flagutil.OptionGroup{&o.github, &o.git} {
 		if err := group.Validate(o.dryRun); err != nil {
 			return fmt.Errorf("%d: %w", idx, err)
 		}
@@ -50,13 +59,20 @@ func gatherOptions() options {
 	o := options{}
 	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 	fs.IntVar(&o.port, "port", 80, "Port to listen on.")
+	fs.StringVar(&o.pluginConfig, "plugin-config", "/etc/plugins/plugins.yaml", "Path to plugin config file.")
 	fs.StringVar(&o.externalPluginsConfig, "external-plugins-config",
 		"/etc/external_plugins_config/external_plugins_config.yaml", "Path to external plugin config file.")
 	fs.BoolVar(&o.dryRun, "dry-run", true, "Dry run for testing. Uses API tokens but does not mutate.")
+	fs.DurationVar(&o.mergeQueuePeriod, "merge-queue-period", time.Minute,
+		"Period duration for syncing the merge queues.")
+	fs.DurationVar(&o.reconcilePeriod, "reconcile-period", time.Hour,
+		"Period duration for periodic reconciliations of all PRs.")
+	fs.DurationVar(&o.freezePeriod, "freeze-period", time.Minute*5,
+		"Period duration for checking whether the code freezes start or end.")
 	fs.StringVar(&o.webhookSecretFile, "hmac-secret-file",
 		"/etc/webhook/hmac", "Path to the file containing the GitHub HMAC secret.")
 
-	for _, group := range []flagutil.OptionGroup{&o.github} {
+	for _, group := range []flagutil.OptionGroup{&o.github, &o.git} {
 		group.AddFlags(fs)
 	}
 	_ = fs.Parse(os.Args[1:])
@@ -79,6 +95,11 @@ func main() {
 		logrus.WithError(err).Fatal("Error starting secrets agent.")
 	}
 
+	pa := &plugins.ConfigAgent{}
+	if err := pa.Start(o.pluginConfig, false); err != nil {
+		log.WithError(err).Fatalf("Error loading plugin config from %q.", o.pluginConfig)
+	}
+
 	epa := &tiexternalplugins.ConfigAgent{}
 	if err := epa.Start(o.externalPluginsConfig, false); err != nil {
 		log.WithError(err).Fatalf("Error loading external plugin config from %q.", o.externalPluginsConfig)
@@ -90,6 +111,11 @@ func main() {
 	}
 	githubClient.Throttle(360, 360)
 
+	gitClient, err := o.git.GitClient(githubClient, secretAgent.GetTokenGenerator(o.github.TokenPath), nil, o.dryRun)
+	if err != nil {
+		logrus.WithError(err).Fatal("Error getting Git client.")
+	}
+
 	// Skip https verify.
 	//nolint:gosec
 	tr := &http.Transport{
@@ -101,6 +127,7 @@ func main() {
 	server := &server{
 		tokenGenerator: secretAgent.GetTokenGenerator(o.webhookSecretFile),
 		gc:             githubClient,
+		gitClient:      gitClient,
 		ol:             ol,
 		configAgent:    epa,
 		log:            log,
@@ -117,6 +144,29 @@ func main() {
 	httpServer := &http.Server{Addr: ":" + strconv.Itoa(o.port), Handler: mux}
 
 	defer interrupts.WaitForGracefulShutdown()
+	interrupts.TickLiteral(func() {
+		start := time.Now()
+		if err := merge.HandleAll(log, githubClient, pa.Config(), epa.Config(), ol); err != nil {
+			log.WithError(err).Error("Error during periodic reconciliation of all PRs.")
+		}
+		log.WithField("duration", fmt.Sprintf("%v", time.Since(start))).Info("Periodic reconciliation complete.")
+	}, o.reconcilePeriod)
+	interrupts.TickLiteral(func() {
+		start := time.Now()
+		merge.HandleFreezes(log, githubClient, epa.Config())
+		log.WithField("duration", fmt.Sprintf("%v", time.Since(start))).Debug("Freeze sync complete.")
+	}, o.freezePeriod)
+	// The merge queue pushes the staging branches, so it does not run in dry run mode.
+	if !o.dryRun {
+		queue := mergequeue.NewQueue(githubClient, gitClient,
+			logrus.StandardLogger().WithField("component", mergequeue.ComponentName))
+		interrupts.TickLiteral(func() {
+			start := time.Now()
+			queue.Sync(epa.Config())
+			log.WithField("duration", fmt.Sprintf("%v", time.Since(start))).Debug("Merge queue sync complete.")
+		}, o.mergeQueuePeriod)
+	}
+
 	interrupts.ListenAndServe(httpServer, 5*time.Second)
 }
 
@@ -125,6 +175,7 @@ func main() {
 type server struct {
 	tokenGenerator func() []byte
 	gc             github.Client
+	gitClient      git.ClientFactory
 
 	ol          ownersclient.OwnersLoader
 	configAgent *tiexternalplugins.ConfigAgent
@@ -164,7 +215,7 @@ func (s *server) handleEvent(eventType, eventGUID string, payload []byte) error
 			ice.Repo.Owner.Login, ice.Repo.Name, ice.Issue.Number,
 		)
 		go func() {
-			if err := merge.HandleIssueCommentEvent(s.gc, &ice, config, s.ol, cp, l); err != nil {
+			if err := merge.HandleIssueCommentEvent(s.gc, s.gitClient, &ice, config, s.ol, cp, l); err != nil {
 				l.WithField("event-type", eventType).WithError(err).Info("Error handling event.")
 			}
 		}()
@@ -179,7 +230,8 @@ func (s *server) handleEvent(eventType, eventGUID string, payload []byte) error
 			pullReviewCommentEvent.Repo.Owner.Login, pullReviewCommentEvent.Repo.Name, pullReviewCommentEvent.PullRequest.Number,
 		)
 		go func() {
-			if err := merge.HandlePullReviewCommentEvent(s.gc, &pullReviewCommentEvent, config, s.ol, cp, l); err != nil {
+			err := merge.HandlePullReviewCommentEvent(s.gc, s.gitClient, &pullReviewCommentEvent, config, s.ol, cp, l)
+			if err != nil {
 				l.WithField("event-type", eventType).WithError(err).Info("Error handling event.")
 			}
 		}()
@@ -189,7 +241,27 @@ func (s *server) handleEvent(eventType, eventGUID string, payload []byte) error
 			return err
 		}
 		go func() {
-			if err := merge.HandlePullRequestEvent(s.gc, &pe, config, l); err != nil {
+			if err := merge.HandlePullRequestEvent(s.gc, s.gitClient, &pe, config, s.ol, l); err != nil {
+				l.WithField("event-type", eventType).WithError(err).Info("Error handling event.")
+			}
+		}()
+	case "status":
+		var se github.StatusEvent
+		if err := json.Unmarshal(payload, &se); err != nil {
+			return err
+		}
+		go func() {
+			if err := merge.HandleStatusEvent(s.gc, s.gitClient, &se, config, s.ol, l); err != nil {
+				l.WithField("event-type", eventType).WithError(err).Info("Error handling event.")
+			}
+		}()
+	case "check_run":
+		var ce merge.CheckRunEvent
+		if err := json.Unmarshal(payload, &ce); err != nil {
+			return err
+		}
+		go func() {
+			if err := merge.HandleCheckRunEvent(s.gc, s.gitClient, &ce, config, s.ol, l); err != nil {
 				l.WithField("event-type", eventType).WithError(err).Info("Error handling event.")
 			}
 		}()
@@ -28,6 +28,7 @@ type options struct {
 	github prowflagutil.GitHubOptions
 
 	externalPluginsConfig string
+	reviewerPreferences   string
 
 	webhookSecretFile string
 }
@@ -50,6 +51,8 @@ func gatherOptions() options {
 	fs.BoolVar(&o.dryRun, "dry-run", true, "Dry run for testing. Uses API tokens but does not mutate.")
 	fs.StringVar(&o.externalPluginsConfig, "external-plugins-config",
 		"/etc/external_plugins_config/external_plugins_config.yaml", "Path to external plugin config file.")
+	fs.StringVar(&o.reviewerPreferences, "reviewer-preferences", "",
+		"Path to the reviewer preferences file, no one has any preferences if it is empty.")
 	fs.StringVar(&o.webhookSecretFile, "hmac-secret-file",
 		"/etc/webhook/hmac", "Path to the file containing the GitHub HMAC secret.")
 
@@ -81,6 +84,11 @@ func main() {
 		log.WithError(err).Fatalf("Error loading external plugin config from %q.", o.externalPluginsConfig)
 	}
 
+	rpa := &tiexternalplugins.ReviewerPreferencesAgent{}
+	if err := rpa.Start(o.reviewerPreferences); err != nil {
+		log.WithError(err).Fatalf("Error loading reviewer preferences from %q.", o.reviewerPreferences)
+	}
+
 	githubClient, err := o.github.GitHubClient(secretAgent, o.dryRun)
 	if err != nil {
 		logrus.WithError(err).Fatal("Error getting GitHub client.")
@@ -95,11 +103,12 @@ func main() {
 	client := &http.Client{Transport: tr}
 
 	server := &owners.Server{
-		Client:         client,
-		TokenGenerator: secretAgent.GetTokenGenerator(o.webhookSecretFile),
-		Gc:             githubClient,
-		ConfigAgent:    epa,
-		Log:            log,
+		Client:           client,
+		TokenGenerator:   secretAgent.GetTokenGenerator(o.webhookSecretFile),
+		Gc:               githubClient,
+		ConfigAgent:      epa,
+		PreferencesAgent: rpa,
+		Log:              log,
 	}
 
 	health := pjutil.NewHealth()
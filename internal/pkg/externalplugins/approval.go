@@ -0,0 +1,133 @@
+package externalplugins
+
+import (
+	"sort"
+
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/test-infra/prow/github"
+)
+
+// defaultRoleWeight specifies the weight of the roles that are not configured.
+const defaultRoleWeight = 1
+
+// AffiliationGroup contains the approvers from the same affiliation.
+type AffiliationGroup struct {
+	// Affiliation is empty if the affiliation of the approvers is unknown.
+	Affiliation string
+	Approvers   []string
+}
+
+// LgtmWeight returns how many approvals the LGTM of the user counts for.
+func (l *TiCommunityLgtm) LgtmWeight(owners *ownersclient.Owners, login string) int {
+	if weight, ok := l.RoleWeights[owners.RoleOf(login)]; ok {
+		return weight
+	}
+	return defaultRoleWeight
+}
+
+// CountLgtm returns the weighted number of approvals from the approvers.
+func (l *TiCommunityLgtm) CountLgtm(owners *ownersclient.Owners, approvers []string) int {
+	count := 0
+	for _, approver := range approvers {
+		count += l.LgtmWeight(owners, approver)
+	}
+	return count
+}
+
+// CountCommitterLgtm returns the number of approvers who are committers.
+func CountCommitterLgtm(owners *ownersclient.Owners, approvers []string) int {
+	committers := sets.NewString(owners.Committers...)
+	count := 0
+	for _, approver := range approvers {
+		if committers.Has(approver) {
+			count++
+		}
+	}
+	return count
+}
+
+// CountAffiliations returns the number of distinct affiliations of the approvers,
+// the approvers whose affiliation is unknown are not counted.
+func CountAffiliations(owners *ownersclient.Owners, approvers []string) int {
+	affiliations := sets.NewString()
+	for _, approver := range approvers {
+		if affiliation := owners.AffiliationOf(approver); affiliation != "" {
+			affiliations.Insert(affiliation)
+		}
+	}
+	return affiliations.Len()
+}
+
+// GroupApproversByAffiliation groups the approvers by their affiliations, the groups are sorted
+// by the affiliation and the group of the approvers whose affiliation is unknown is the last one.
+func GroupApproversByAffiliation(owners *ownersclient.Owners, approvers []string) []AffiliationGroup {
+	approversOfAffiliation := map[string][]string{}
+	for _, approver := range approvers {
+		affiliation := owners.AffiliationOf(approver)
+		approversOfAffiliation[affiliation] = append(approversOfAffiliation[affiliation], approver)
+	}
+
+	var groups []AffiliationGroup
+	for affiliation, members := range approversOfAffiliation {
+		sort.Strings(members)
+		groups = append(groups, AffiliationGroup{Affiliation: affiliation, Approvers: members})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Affiliation == "" || groups[j].Affiliation == "" {
+			return groups[j].Affiliation == ""
+		}
+		return groups[i].Affiliation < groups[j].Affiliation
+	})
+	return groups
+}
+
+// MissingAffiliations returns how many more affiliations are required among the approvers.
+func (l *TiCommunityLgtm) MissingAffiliations(owners *ownersclient.Owners, approvers []string) int {
+	missing := l.RequiredAffiliations - CountAffiliations(owners, approvers)
+	if missing < 0 {
+		return 0
+	}
+	return missing
+}
+
+// MissingSigs returns the sigs of the PR that none of the approvers belongs to.
+func MissingSigs(owners *ownersclient.Owners, approvers []string) []string {
+	approverSet := sets.NewString(approvers...)
+	var missing []string
+	for sig, reviewers := range owners.Sigs {
+		if !approverSet.HasAny(reviewers...) {
+			missing = append(missing, sig)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// LgtmCountFromLabels returns the number of approvals recorded by the LGTM label. If the LGTM label
+// does not record the number, the approvals are counted from the approvers.
+func (l *TiCommunityLgtm) LgtmCountFromLabels(scheme *LabelScheme, owners *ownersclient.Owners,
+	labels []github.Label, approvers []string) int {
+	count := 0
+	for _, label := range scheme.GetLgtmLabels(labels) {
+		if scheme.IsLgtmLabelNumbered() {
+			count, _ = scheme.ParseLgtmLabel(label, owners.NeedsLgtm)
+		} else {
+			count = l.CountLgtm(owners, approvers)
+		}
+	}
+	return count
+}
+
+// NeedsApprovers returns true if the approval rules cannot be checked by the number of approvals only.
+func (l *TiCommunityLgtm) NeedsApprovers() bool {
+	return l.RequiredCommitterLgtm > 0 || l.RequiredAffiliations > 0
+}
+
+// IsLgtmSatisfied returns true if the weighted number of approvals reaches the number of
+// required LGTMs, enough approvals come from committers and the approvers come from enough affiliations.
+func (l *TiCommunityLgtm) IsLgtmSatisfied(owners *ownersclient.Owners, lgtmCount int, approvers []string) bool {
+	return lgtmCount > 0 && lgtmCount >= owners.NeedsLgtm &&
+		CountCommitterLgtm(owners, approvers) >= l.RequiredCommitterLgtm &&
+		l.MissingAffiliations(owners, approvers) == 0
+}
@@ -0,0 +1,133 @@
+package externalplugins
+
+import (
+	"fmt"
+	"strings"
+
+	git "k8s.io/test-infra/prow/git/v2"
+	"k8s.io/test-infra/prow/github"
+)
+
+const (
+	// diffCheckUserName and diffCheckUserEmail are the committer of the local merge commits
+	// which are only used to compare the diffs and never pushed.
+	diffCheckUserName  = "ti-community-bot"
+	diffCheckUserEmail = "ti-community-bot@localhost"
+)
+
+// ApprovedDiff is the diff of the PR when it is approved. The diff is from the merge base of the head
+// and the base branch to the tree of the head.
+type ApprovedDiff struct {
+	HeadSHA  string
+	TreeHash string
+	BaseSHA  string
+}
+
+// GetApprovedDiff returns the head, the tree of the head and the merge base of the head and the base branch.
+func GetApprovedDiff(gitClient git.ClientFactory, org, repo string, pr *github.PullRequest) (*ApprovedDiff, error) {
+	r, err := clonePullRequest(gitClient, org, repo, pr.Number)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Clean()
+
+	return getDiff(r, pr.Base.Ref, pr.Head.SHA)
+}
+
+// IsDiffApproved returns true if the diff from the merge base to the head of the PR is identical to the
+// approved diff, so that the PR rebased or merged with the base branch keeps the approvals. The current
+// merge base is merged into the approved head, the diffs are identical if the result is the current tree.
+// So the merge commits which resolve conflicts or contain other changes are not identical.
+func IsDiffApproved(gitClient git.ClientFactory, org, repo string, pr *github.PullRequest,
+	approved *ApprovedDiff) (bool, error) {
+	r, err := clonePullRequest(gitClient, org, repo, pr.Number)
+	if err != nil {
+		return false, err
+	}
+	defer r.Clean()
+
+	current, err := getDiff(r, pr.Base.Ref, pr.Head.SHA)
+	if err != nil {
+		return false, err
+	}
+	if current.TreeHash == approved.TreeHash && current.BaseSHA == approved.BaseSHA {
+		return true, nil
+	}
+	if approved.HeadSHA == "" {
+		return false, nil
+	}
+
+	// The approved head may be no longer referenced by the PR after a force push.
+	if err := r.FetchRef(approved.HeadSHA); err != nil {
+		return false, fmt.Errorf("failed to fetch the approved head %s: %v", approved.HeadSHA, err)
+	}
+	if err := r.Config("user.name", diffCheckUserName); err != nil {
+		return false, err
+	}
+	if err := r.Config("user.email", diffCheckUserEmail); err != nil {
+		return false, err
+	}
+	if err := r.Checkout(approved.HeadSHA); err != nil {
+		return false, err
+	}
+	merged, err := r.Merge(current.BaseSHA)
+	if err != nil {
+		return false, err
+	}
+	if !merged {
+		// The approved changes conflict with the current base.
+		return false, nil
+	}
+	tree, err := r.RevParse("HEAD^{tree}")
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(tree) == current.TreeHash, nil
+}
+
+// clonePullRequest clones the repo and fetches the head of the PR.
+func clonePullRequest(gitClient git.ClientFactory, org, repo string, number int) (git.RepoClient, error) {
+	r, err := gitClient.ClientFor(org, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to clone %s/%s: %v", org, repo, err)
+	}
+	if err := r.FetchRef(fmt.Sprintf("pull/%d/head", number)); err != nil {
+		if cleanErr := r.Clean(); cleanErr != nil {
+			err = fmt.Errorf("%v, and failed to clean the repo: %v", err, cleanErr)
+		}
+		return nil, fmt.Errorf("failed to fetch the head of pull request %d: %v", number, err)
+	}
+	return r, nil
+}
+
+// getDiff returns the head, the tree of the head and the merge base of the head and the base branch.
+func getDiff(r git.RepoClient, baseRef, headSHA string) (*ApprovedDiff, error) {
+	base, err := mergeBase(r, "origin/"+baseRef, headSHA)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := r.RevParse(headSHA + "^{tree}")
+	if err != nil {
+		return nil, err
+	}
+	return &ApprovedDiff{
+		HeadSHA:  headSHA,
+		TreeHash: strings.TrimSpace(tree),
+		BaseSHA:  base,
+	}, nil
+}
+
+// mergeBase returns the merge base of the commits. The symmetric difference `a...b` is parsed
+// as the commits and the negated merge bases, such as `^base`.
+func mergeBase(r git.RepoClient, a, b string) (string, error) {
+	out, err := r.RevParse(a + "..." + b)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "^") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "^")), nil
+		}
+	}
+	return "", fmt.Errorf("no merge base of %s and %s", a, b)
+}
@@ -0,0 +1,117 @@
+package externalplugins
+
+import (
+	"testing"
+
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins/testutil"
+)
+
+func TestIsDiffApproved(t *testing.T) {
+	testcases := []struct {
+		name   string
+		update func(r *testutil.FakeRepo)
+
+		expectApproved bool
+	}{
+		{
+			name:           "Head is not changed",
+			update:         func(r *testutil.FakeRepo) {},
+			expectApproved: true,
+		},
+		{
+			name: "Squashed with the same content",
+			update: func(r *testutil.FakeRepo) {
+				r.Checkout(testutil.PRBranch)
+				r.Git("reset", "--soft", "HEAD~1")
+				r.Git("commit", "-m", "squashed")
+				r.UpdatePR()
+			},
+			expectApproved: true,
+		},
+		{
+			name: "Rebased onto the new base with the same changes",
+			update: func(r *testutil.FakeRepo) {
+				r.Commit(map[string]string{"base": "0\n1\n2\n3\n"})
+				r.Checkout(testutil.PRBranch)
+				r.Git("rebase", "master")
+				r.UpdatePR()
+			},
+			expectApproved: true,
+		},
+		{
+			name: "Base branch is merged into the PR",
+			update: func(r *testutil.FakeRepo) {
+				r.Commit(map[string]string{"base": "0\n1\n2\n3\n"})
+				r.Checkout(testutil.PRBranch)
+				r.Git("merge", "--no-edit", "master")
+				r.UpdatePR()
+			},
+			expectApproved: true,
+		},
+		{
+			name: "New changes are pushed",
+			update: func(r *testutil.FakeRepo) {
+				r.Checkout(testutil.PRBranch)
+				r.Commit(map[string]string{"pr": "b\n"})
+				r.UpdatePR()
+			},
+		},
+		{
+			name: "Rebased with different changes",
+			update: func(r *testutil.FakeRepo) {
+				r.Commit(map[string]string{"base": "0\n1\n2\n3\n"})
+				r.Checkout(testutil.PRBranch)
+				r.Git("rebase", "master")
+				r.Commit(map[string]string{"base": "0\n1\n2\n3\n4\n"})
+				r.Git("reset", "--soft", "master")
+				r.Git("commit", "-m", "rebased")
+				r.UpdatePR()
+			},
+		},
+		{
+			name: "Merge commit resolves conflicts",
+			update: func(r *testutil.FakeRepo) {
+				r.Commit(map[string]string{"pr": "x\n"})
+				r.Checkout(testutil.PRBranch)
+				r.Git("merge", "--no-commit", "-s", "ours", "master")
+				r.Commit(map[string]string{"pr": "a\nx\n"})
+				r.UpdatePR()
+			},
+		},
+		{
+			name: "Merge commit contains other changes",
+			update: func(r *testutil.FakeRepo) {
+				r.Commit(map[string]string{"base": "0\n1\n2\n3\n"})
+				r.Checkout(testutil.PRBranch)
+				r.Git("merge", "--no-commit", "master")
+				r.Commit(map[string]string{"pr": "b\n"})
+				r.UpdatePR()
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			r, gitClient := testutil.NewFakeRepo(t)
+			approved, err := GetApprovedDiff(gitClient, testutil.Org, testutil.Repo, r.PullRequest())
+			if err != nil {
+				t.Fatalf("failed to get the approved diff: %v", err)
+			}
+			if approved.TreeHash != r.Git("rev-parse", testutil.PRBranch+"^{tree}") ||
+				approved.BaseSHA != r.Git("rev-parse", "master") {
+				t.Fatalf("unexpected approved diff: %+v", approved)
+			}
+
+			tc.update(r)
+
+			same, err := IsDiffApproved(gitClient, testutil.Org, testutil.Repo, r.PullRequest(), approved)
+			if err != nil {
+				t.Fatalf("failed to compare the diff: %v", err)
+			}
+			if same != tc.expectApproved {
+				t.Errorf("approved mismatch: got %v, want %v", same, tc.expectApproved)
+			}
+		})
+	}
+}
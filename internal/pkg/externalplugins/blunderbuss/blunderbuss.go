@@ -1,6 +1,7 @@
 package blunderbuss
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strings"
@@ -31,8 +32,18 @@ var sleep = time.Sleep
 
 type githubClient interface {
 	RequestReview(org, repo string, number int, logins []string) error
+	AssignIssue(org, repo string, number int, logins []string) error
 	GetPullRequest(org, repo string, number int) (*github.PullRequest, error)
 	GetIssueLabels(org, repo string, number int) ([]github.Label, error)
+	Query(context.Context, interface{}, map[string]interface{}) error
+	GetPullRequestChanges(org, repo string, number int) ([]github.PullRequestChange, error)
+	CreateComment(owner, repo string, number int, comment string) error
+	EditComment(org, repo string, id int, comment string) error
+	DeleteComment(org, repo string, id int) error
+	ListIssueComments(org, repo string, number int) ([]github.IssueComment, error)
+	BotUserChecker() (func(candidate string) bool, error)
+	AddLabel(org, repo string, number int, label string) error
+	RemoveLabel(org, repo string, number int, label string) error
 }
 
 // HelpProvider constructs the PluginHelp for this plugin that takes into account enabled repositories.
@@ -52,6 +63,48 @@ func HelpProvider(epa *externalplugins.ConfigAgent) func(
 				configInfoStrings = append(configInfoStrings, "<li>"+configString(opts.MaxReviewerCount)+"</li>")
 				isConfigured = true
 			}
+			if opts.ReviewerSelection == externalplugins.ReviewerSelectionLoad {
+				configInfoStrings = append(configInfoStrings,
+					"<li>The reviewers with the fewest pending review requests are preferred.</li>")
+				isConfigured = true
+			}
+			if opts.ReviewerSelection == externalplugins.ReviewerSelectionExpertise {
+				configInfoStrings = append(configInfoStrings, "<li>The reviewers who recently authored or "+
+					"reviewed the commits touching the changed files are preferred.</li>")
+				isConfigured = true
+			}
+			if opts.MaxPendingReviews > 0 {
+				configInfoStrings = append(configInfoStrings, fmt.Sprintf("<li>The reviewers with %d or more "+
+					"pending review requests are not requested.</li>", opts.MaxPendingReviews))
+				isConfigured = true
+			}
+			if opts.SigReviewerCount > 0 {
+				configInfoStrings = append(configInfoStrings, fmt.Sprintf("<li>Reviews are requested from %d "+
+					"reviewer(s) of each SIG of the PR.</li>", opts.SigReviewerCount))
+				isConfigured = true
+			}
+			if opts.AssignCommitterOnLgtm {
+				configInfoStrings = append(configInfoStrings, "<li>A committer is assigned to help merge the PR "+
+					"once the PR has acquired the required number of LGTMs.</li>")
+				isConfigured = true
+			}
+			if opts.FirstTimeContributor != nil {
+				configInfoStrings = append(configInfoStrings, "<li>A mentor is requested to review the PRs "+
+					"of the first-time contributors.</li>")
+				isConfigured = true
+			}
+			if reminder := opts.ReviewReminder; reminder != nil {
+				if reminder.RemindAfter > 0 {
+					configInfoStrings = append(configInfoStrings, fmt.Sprintf("<li>The requested reviewers "+
+						"who have not reviewed in %d hours are pinged.</li>", reminder.RemindAfter))
+					isConfigured = true
+				}
+				if reminder.EscalateAfter > 0 {
+					configInfoStrings = append(configInfoStrings, fmt.Sprintf("<li>The review pending for %d hours "+
+						"is escalated with the %s label.</li>", reminder.EscalateAfter, reminder.AttentionLabel))
+					isConfigured = true
+				}
+			}
 			configInfoStrings = append(configInfoStrings, "</ul>")
 			if isConfigured {
 				configInfo[repo.String()] = strings.Join(configInfoStrings, "\n")
@@ -83,6 +136,14 @@ func HelpProvider(epa *externalplugins.ConfigAgent) func(
 			Examples:    []string{"/auto-cc"},
 			WhoCanUse:   "Everyone",
 		})
+		pluginHelp.AddCommand(pluginhelp.Command{
+			Usage:    "/reviewer-prefs",
+			Featured: false,
+			Description: "Show your preferences of the review requests and the review reminders, " +
+				"the preferences are changed in the reviewer preferences file of the config repo.",
+			Examples:  []string{"/reviewer-prefs"},
+			WhoCanUse: "Everyone",
+		})
 		return pluginHelp, nil
 	}
 }
@@ -96,28 +157,44 @@ func configString(maxReviewerCount int) string {
 		maxReviewerCount, pluralSuffix)
 }
 
-// HandleIssueCommentEvent handles a GitHub pull request event and requests review.
-func HandlePullRequestEvent(gc githubClient, pe *github.PullRequestEvent,
-	cfg *externalplugins.Configuration, ol ownersclient.OwnersLoader, log *logrus.Entry) error {
+// HandlePullRequestEvent handles a GitHub pull request event and requests review.
+// The author association is not in the PR of the event, so it is passed separately.
+func HandlePullRequestEvent(gc githubClient, pe *github.PullRequestEvent, authorAssociation string,
+	cfg *externalplugins.Configuration, preferences map[string]ownersclient.ReviewerPreferences,
+	ol ownersclient.OwnersLoader, log *logrus.Entry) error {
 	pr := &pe.PullRequest
+	repo := &pe.Repo
+	opts := cfg.BlunderbussFor(repo.Owner.Login, repo.Name)
+	isPrLabeledEvent := pe.Action == github.PullRequestActionLabeled
+
+	// Assign a committer to help merge the PR once the LGTM label is added.
+	if opts.AssignCommitterOnLgtm && isLgtmLabeledEvent(pe, cfg) {
+		return handleLgtmLabeled(gc, pe, cfg, preferences, ol, log)
+	}
+
+	if pe.Action == github.PullRequestActionOpened {
+		return handlePullRequestOpened(gc, pe, authorAssociation, cfg, preferences, ol, log)
+	}
+
+	// When the reviewers are requested per SIG, the SIG added later also needs its reviewers.
+	requestPerSig := isPrLabeledEvent && opts.SigReviewerCount > 0
+
 	// If a PR already has reviewers, we do not automatically assign them.
-	if len(pr.RequestedReviewers) > 0 {
+	if countRequestedReviewers(pr, opts) > 0 && !requestPerSig {
 		return nil
 	}
 
-	repo := &pe.Repo
-	opts := cfg.BlunderbussFor(repo.Owner.Login, repo.Name)
 	// If there is already /cc, the author has specified reviewers.
 	prBodyWithoutCcCommand := !assign.CCRegexp.MatchString(pr.Body)
 
-	isPrLabeledEvent := pe.Action == github.PullRequestActionLabeled
 	openPrWithSigLabel := pe.PullRequest.State == "open" && strings.Contains(pe.Label.Name, externalplugins.SigPrefix)
 
 	// Only handle the event of add SIG label to the open PR.
-	if isPrLabeledEvent && openPrWithSigLabel && prBodyWithoutCcCommand {
+	if isPrLabeledEvent && openPrWithSigLabel && (prBodyWithoutCcCommand || requestPerSig) {
 		return handle(
 			gc,
-			opts,
+			cfg,
+			preferences,
 			repo,
 			pr,
 			log,
@@ -125,43 +202,70 @@ func HandlePullRequestEvent(gc githubClient, pe *github.PullRequestEvent,
 		)
 	}
 
-	isPrOpenedEvent := pe.Action == github.PullRequestActionOpened
-	repoNonRequireSigLabel := !opts.RequireSigLabel
+	return nil
+}
+
+// handlePullRequestOpened pairs the first-time contributor with a mentor and requests reviews for the opened PR.
+// The mentor and the reviewers are selected in one step after the grace period, so that the mentor is neither
+// counted nor requested again as a reviewer.
+func handlePullRequestOpened(gc githubClient, pe *github.PullRequestEvent, authorAssociation string,
+	cfg *externalplugins.Configuration, preferences map[string]ownersclient.ReviewerPreferences,
+	ol ownersclient.OwnersLoader, log *logrus.Entry) error {
+	pr := &pe.PullRequest
+	repo := &pe.Repo
+	opts := cfg.BlunderbussFor(repo.Owner.Login, repo.Name)
 
-	// Only handle the event of opening non-CC PR, when the require_sig_label option is not turned on.
-	if isPrOpenedEvent && repoNonRequireSigLabel && prBodyWithoutCcCommand {
-		// Wait a few seconds to allow other automation plugin to apply labels (Mainly SIG label).
-		gracePeriod := time.Duration(opts.GracePeriodDuration) * time.Second
-		sleep(gracePeriod)
+	welcome := opts.FirstTimeContributor != nil && IsFirstTimeContributor(authorAssociation)
+	// Only request reviews for the non-CC PR without reviewers, when the require_sig_label option is not turned on.
+	requestReview := !opts.RequireSigLabel && !assign.CCRegexp.MatchString(pr.Body)
+	if !welcome && (!requestReview || countRequestedReviewers(pr, opts) > 0) {
+		return nil
+	}
 
-		// Reacquire new added labels of PR.
-		labels, err := gc.GetIssueLabels(repo.Owner.Login, repo.Name, pr.Number)
-		if err != nil {
-			return fmt.Errorf("error loading PullRequest labels: %v", err)
+	if welcome {
+		log.Infof("Welcoming the first-time contributor %s.", pr.User.Login)
+		if err := gc.AddLabel(repo.Owner.Login, repo.Name, pr.Number, opts.FirstTimeContributor.Label); err != nil {
+			return err
 		}
+	}
+
+	// Wait a few seconds to allow other automation plugin to apply labels (Mainly SIG label).
+	gracePeriod := time.Duration(opts.GracePeriodDuration) * time.Second
+	sleep(gracePeriod)
 
-		// The task requesting review has been processed in the labeled event, and the open event
-		// does not need to be processed repeatedly.
-		if containSigLabel(labels) {
-			return nil
+	// Reacquire new added labels of PR.
+	labels, err := gc.GetIssueLabels(repo.Owner.Login, repo.Name, pr.Number)
+	if err != nil {
+		return fmt.Errorf("error loading PullRequest labels: %v", err)
+	}
+	pr.Labels = labels
+
+	if welcome {
+		if err := welcomeFirstTimeContributor(gc, cfg, preferences, repo, pr, log); err != nil {
+			return err
 		}
+	}
 
-		return handle(
-			gc,
-			opts,
-			repo,
-			pr,
-			log,
-			ol,
-		)
+	// The task requesting review has been processed in the labeled event, and the open event
+	// does not need to be processed repeatedly.
+	if !requestReview || countRequestedReviewers(pr, opts) > 0 || containSigLabel(labels) {
+		return nil
 	}
 
-	return nil
+	return handle(
+		gc,
+		cfg,
+		preferences,
+		repo,
+		pr,
+		log,
+		ol,
+	)
 }
 
 // HandleIssueCommentEvent handles a GitHub issue comment event and requests review.
 func HandleIssueCommentEvent(gc githubClient, ce *github.IssueCommentEvent, cfg *externalplugins.Configuration,
-	ol ownersclient.OwnersLoader, log *logrus.Entry) error {
+	preferences map[string]ownersclient.ReviewerPreferences, ol ownersclient.OwnersLoader, log *logrus.Entry) error {
 	// Only consider open PRs and new comments.
 	if ce.Action != github.IssueCommentActionCreated || !ce.Issue.IsPullRequest() || ce.Issue.State == "closed" {
 		return nil
@@ -188,7 +292,8 @@ func HandleIssueCommentEvent(gc githubClient, ce *github.IssueCommentEvent, cfg
 
 	return handle(
 		gc,
-		opts,
+		cfg,
+		preferences,
 		repo,
 		pr,
 		log,
@@ -196,39 +301,89 @@ func HandleIssueCommentEvent(gc githubClient, ce *github.IssueCommentEvent, cfg
 	)
 }
 
-func handle(ghc githubClient, opts *externalplugins.TiCommunityBlunderbuss, repo *github.Repo, pr *github.PullRequest,
+func handle(ghc githubClient, cfg *externalplugins.Configuration,
+	preferences map[string]ownersclient.ReviewerPreferences, repo *github.Repo, pr *github.PullRequest,
 	log *logrus.Entry, ol ownersclient.OwnersLoader) error {
+	opts := cfg.BlunderbussFor(repo.Owner.Login, repo.Name)
 	owners, err := ol.LoadOwners(opts.PullOwnersEndpoint, repo.Owner.Login, repo.Name, pr.Number)
 	if err != nil {
 		return fmt.Errorf("error loading RepoOwners: %v", err)
 	}
 
-	reviewers := getReviewers(pr.User.Login, owners.Reviewers, opts.ExcludeReviewers, log)
+	// The reviewers who do not want to be requested now according to their preferences are excluded.
+	sigs := getPullRequestSigs(pr.Labels, owners)
+	candidates := getCandidates(pr.User.Login, owners.Reviewers, opts.ExcludeReviewers)
+	unavailable := getUnavailableReviewers(ghc, opts, preferences, candidates, sigs, log)
+	// The mentor of the first-time contributor has been requested in addition to the reviewers.
+	excludeReviewers := append(append([]string{}, opts.ExcludeReviewers...), unavailable...)
+	excludeReviewers = append(excludeReviewers, getRequestedMentors(pr, opts).List()...)
+
+	var reviewers []string
+	var expertises map[string]*reviewerExpertise
+	switch opts.ReviewerSelection {
+	case externalplugins.ReviewerSelectionLoad:
+		candidates := getCandidates(pr.User.Login, owners.Reviewers, excludeReviewers)
+		reviewers = selectByLoad(ghc, opts, candidates, log)
+	case externalplugins.ReviewerSelectionExpertise:
+		candidates := getCandidates(pr.User.Login, owners.Reviewers, excludeReviewers)
+		expertises = make(map[string]*reviewerExpertise)
+		for _, expertise := range selectByExpertise(ghc, repo.Owner.Login, repo.Name, pr, candidates, log) {
+			reviewers = append(reviewers, expertise.Login)
+			expertises[expertise.Login] = expertise
+		}
+	default:
+		reviewers = getReviewers(pr.User.Login, owners.Reviewers, excludeReviewers, log)
+	}
 	maxReviewerCount := opts.MaxReviewerCount
 
-	// If the maximum count of reviewers greater than 0, it needs to be split.
-	if maxReviewerCount > 0 && len(reviewers) > maxReviewerCount {
+	if opts.SigReviewerCount > 0 && len(owners.Sigs) > 0 {
+		// The reviewers requested before are counted for their SIGs.
+		latestPR, err := ghc.GetPullRequest(repo.Owner.Login, repo.Name, pr.Number)
+		if err != nil {
+			return fmt.Errorf("error loading PullRequest: %v", err)
+		}
+		var requested []string
+		for _, reviewer := range latestPR.RequestedReviewers {
+			requested = append(requested, reviewer.Login)
+		}
+		reviewers = selectBySig(reviewers, owners.Sigs, requested, opts.SigReviewerCount, maxReviewerCount, log)
+	} else if maxReviewerCount > 0 && len(reviewers) > maxReviewerCount {
+		// If the maximum count of reviewers greater than 0, it needs to be split.
 		log.Infof("Limiting request of %d reviewers to %d maxReviewers.", len(reviewers), maxReviewerCount)
 		reviewers = reviewers[:maxReviewerCount]
 	}
 
-	if len(reviewers) > 0 {
-		log.Infof("Requesting reviews from users %s.", reviewers)
-		return ghc.RequestReview(repo.Owner.Login, repo.Name, pr.Number, reviewers)
+	if len(reviewers) == 0 {
+		return nil
 	}
-	return nil
+	log.Infof("Requesting reviews from users %s.", reviewers)
+	if err := ghc.RequestReview(repo.Owner.Login, repo.Name, pr.Number, reviewers); err != nil {
+		return err
+	}
+	if expertises == nil {
+		return nil
+	}
+	// Explain why each reviewer was chosen by the expertise.
+	var chosen []*reviewerExpertise
+	for _, reviewer := range reviewers {
+		chosen = append(chosen, expertises[reviewer])
+	}
+	return explainReviewers(ghc, cfg, repo.Owner.Login, repo.Name, pr.Number, chosen)
 }
 
-func getReviewers(author string, reviewers []string, excludeReviewers []string, log *logrus.Entry) []string {
+// getCandidates returns the sorted reviewers excluding the author and the excluded reviewers.
+func getCandidates(author string, reviewers []string, excludeReviewers []string) []string {
 	authorSet := sets.NewString(github.NormLogin(author))
 	excludeReviewersSet := sets.NewString(excludeReviewers...)
 	reviewersSet := sets.NewString()
 	reviewersSet.Insert(reviewers...)
+	return reviewersSet.Difference(authorSet).Difference(excludeReviewersSet).List()
+}
 
+func getReviewers(author string, reviewers []string, excludeReviewers []string, log *logrus.Entry) []string {
 	var result []string
 	// Exclude the author.
-	availableReviewers := layeredsets.NewString(
-		reviewersSet.Difference(authorSet).Difference(excludeReviewersSet).List()...)
+	availableReviewers := layeredsets.NewString(getCandidates(author, reviewers, excludeReviewers)...)
 
 	for availableReviewers.Len() > 0 {
 		reviewer := availableReviewers.PopRandom()
@@ -239,6 +394,15 @@ func getReviewers(author string, reviewers []string, excludeReviewers []string,
 	return result
 }
 
+// getPullRequestSigs returns the sigs of the PR from both the sig labels and the owners.
+func getPullRequestSigs(labels []github.Label, owners *ownersclient.Owners) []string {
+	sigs := sets.NewString(getSigs(labels)...)
+	for sig := range owners.Sigs {
+		sigs.Insert(sig)
+	}
+	return sigs.List()
+}
+
 func containSigLabel(labels []github.Label) bool {
 	for _, label := range labels {
 		if strings.HasPrefix(label.Name, externalplugins.SigPrefix) {
@@ -1,13 +1,17 @@
 package blunderbuss
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"reflect"
 	"sort"
 	"strings"
 	"testing"
 	"time"
 
+	githubql "github.com/shurcooL/githubv4"
 	"github.com/sirupsen/logrus"
 	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
 	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
@@ -18,6 +22,24 @@ import (
 type fakeGitHubClient struct {
 	pr        *github.PullRequest
 	requested []string
+	// pending specifies the number of the pending review requests of the reviewers.
+	pending map[string]int
+	// changes specifies the files changed by the PR.
+	changes []github.PullRequestChange
+	// history specifies the recent commits touching each file.
+	history map[string][]historyCommit
+	// openPRs specifies the labels of the open PRs found by searching, the key is the number of the PR.
+	openPRs map[int][]string
+	// reviewRequests specifies when the pending reviews of the PR were requested.
+	reviewRequests map[string]string
+	draft          bool
+	issueComments  []github.IssueComment
+	comments       []string
+	removedLabels  []string
+	// recent specifies the number of the reviews requested from the reviewers in the past week.
+	recent         map[string]int
+	editedComments []string
+	assigned       []string
 }
 
 func newFakeGitHubClient(pr *github.PullRequest) *fakeGitHubClient {
@@ -38,6 +60,14 @@ func (c *fakeGitHubClient) RequestReview(org, repo string, number int, logins []
 	return nil
 }
 
+func (c *fakeGitHubClient) AssignIssue(org, repo string, number int, logins []string) error {
+	if org != "org" || repo != "repo" || number != 5 {
+		return errors.New("unexpected issue")
+	}
+	c.assigned = append(c.assigned, logins...)
+	return nil
+}
+
 func (c *fakeGitHubClient) GetPullRequest(_, _ string, _ int) (*github.PullRequest, error) {
 	return c.pr, nil
 }
@@ -46,6 +76,154 @@ func (c *fakeGitHubClient) GetIssueLabels(_, _ string, _ int) ([]github.Label, e
 	return c.pr.Labels, nil
 }
 
+// Query returns the history of the file if the path is specified,
+// otherwise the number of the pending review requests of the reviewer in the search query.
+func (c *fakeGitHubClient) Query(_ context.Context, q interface{}, vars map[string]interface{}) error {
+	if path, ok := vars["path"]; ok {
+		commits, ok := c.history[string(path.(githubql.String))]
+		if !ok {
+			return fmt.Errorf("unknown file %s", path)
+		}
+		q.(*historyQuery).Repository.Object.Commit.History.Nodes = commits
+		return nil
+	}
+	if _, ok := vars["searchCursor"]; ok {
+		return c.searchOpenPRs(q)
+	}
+	if _, ok := vars["number"]; ok {
+		return c.queryReviewRequests(q)
+	}
+	query := string(vars["query"].(githubql.String))
+	if strings.Contains(query, " created:>=") {
+		return c.countRecentReviewRequests(query, q)
+	}
+	login := query[strings.LastIndex(query, "review-requested:")+len("review-requested:"):]
+	pending, ok := c.pending[login]
+	if !ok {
+		return fmt.Errorf("unknown reviewer %s", login)
+	}
+	result, err := json.Marshal(map[string]interface{}{
+		"Search": map[string]interface{}{"IssueCount": pending},
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(result, q)
+}
+
+// countRecentReviewRequests returns the recent review requests of the reviewer as the pending ones,
+// and none of the reviewed ones.
+func (c *fakeGitHubClient) countRecentReviewRequests(query string, q interface{}) error {
+	var count int
+	for _, field := range strings.Fields(query) {
+		if strings.HasPrefix(field, "review-requested:") {
+			count = c.recent[strings.TrimPrefix(field, "review-requested:")]
+		}
+	}
+	result, err := json.Marshal(map[string]interface{}{
+		"Search": map[string]interface{}{"IssueCount": count},
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(result, q)
+}
+
+func (c *fakeGitHubClient) searchOpenPRs(q interface{}) error {
+	var nodes []map[string]interface{}
+	for number, labels := range c.openPRs {
+		var labelNodes []map[string]interface{}
+		for _, label := range labels {
+			labelNodes = append(labelNodes, map[string]interface{}{"Name": label})
+		}
+		nodes = append(nodes, map[string]interface{}{
+			"PullRequest": map[string]interface{}{
+				"Number": number,
+				"Repository": map[string]interface{}{
+					"Name":  "repo",
+					"Owner": map[string]interface{}{"Login": "org"},
+				},
+				"Author": map[string]interface{}{"Login": "author"},
+				"Labels": map[string]interface{}{"Nodes": labelNodes},
+			},
+		})
+	}
+	result, err := json.Marshal(map[string]interface{}{
+		"Search": map[string]interface{}{"Nodes": nodes},
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(result, q)
+}
+
+func (c *fakeGitHubClient) queryReviewRequests(q interface{}) error {
+	var logins []string
+	for login := range c.reviewRequests {
+		logins = append(logins, login)
+	}
+	sort.Strings(logins)
+
+	var requests, events []map[string]interface{}
+	for _, login := range logins {
+		requestedAt := c.reviewRequests[login]
+		reviewer := map[string]interface{}{"User": map[string]interface{}{"Login": login}}
+		requests = append(requests, map[string]interface{}{"RequestedReviewer": reviewer})
+		events = append(events, map[string]interface{}{
+			"ReviewRequestedEvent": map[string]interface{}{
+				"CreatedAt":         requestedAt,
+				"RequestedReviewer": reviewer,
+			},
+		})
+	}
+	result, err := json.Marshal(map[string]interface{}{
+		"Repository": map[string]interface{}{
+			"PullRequest": map[string]interface{}{
+				"IsDraft":        c.draft,
+				"ReviewRequests": map[string]interface{}{"Nodes": requests},
+				"TimelineItems":  map[string]interface{}{"Nodes": events},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(result, q)
+}
+
+func (c *fakeGitHubClient) ListIssueComments(_, _ string, _ int) ([]github.IssueComment, error) {
+	return c.issueComments, nil
+}
+
+func (c *fakeGitHubClient) BotUserChecker() (func(candidate string) bool, error) {
+	return func(candidate string) bool {
+		return candidate == "ti-chi-bot"
+	}, nil
+}
+
+func (c *fakeGitHubClient) RemoveLabel(_, _ string, _ int, label string) error {
+	c.removedLabels = append(c.removedLabels, label)
+	return nil
+}
+
+func (c *fakeGitHubClient) GetPullRequestChanges(_, _ string, _ int) ([]github.PullRequestChange, error) {
+	return c.changes, nil
+}
+
+func (c *fakeGitHubClient) CreateComment(_, _ string, _ int, comment string) error {
+	c.comments = append(c.comments, comment)
+	return nil
+}
+
+func (c *fakeGitHubClient) EditComment(_, _ string, _ int, comment string) error {
+	c.editedComments = append(c.editedComments, comment)
+	return nil
+}
+
+func (c *fakeGitHubClient) DeleteComment(_, _ string, _ int) error {
+	return nil
+}
+
 func (c *fakeGitHubClient) AddLabel(_, _ string, _ int, labelName string) error {
 	var label github.Label
 	label.Name = labelName
@@ -54,15 +232,19 @@ func (c *fakeGitHubClient) AddLabel(_, _ string, _ int, labelName string) error
 }
 
 type fakeOwnersClient struct {
-	reviewers []string
-	needsLgtm int
+	committers []string
+	reviewers  []string
+	needsLgtm  int
+	sigs       map[string][]string
 }
 
 func (f *fakeOwnersClient) LoadOwners(_ string,
 	_, _ string, _ int) (*ownersclient.Owners, error) {
 	return &ownersclient.Owners{
-		Reviewers: f.reviewers,
-		NeedsLgtm: f.needsLgtm,
+		Committers: f.committers,
+		Reviewers:  f.reviewers,
+		NeedsLgtm:  f.needsLgtm,
+		Sigs:       f.sigs,
 	}, nil
 }
 
@@ -239,7 +421,7 @@ func TestHandleIssueCommentEvent(t *testing.T) {
 			needsLgtm: 2,
 		}
 
-		if err := HandleIssueCommentEvent(fc, e, cfg, foc, logrus.WithField("plugin", PluginName)); err != nil {
+		if err := HandleIssueCommentEvent(fc, e, cfg, nil, foc, logrus.WithField("plugin", PluginName)); err != nil {
 			t.Errorf("didn't expect error from autoccComment: %v", err)
 			continue
 		}
@@ -451,7 +633,7 @@ func TestHandlePullRequest(t *testing.T) {
 			needsLgtm: 2,
 		}
 
-		if err := HandlePullRequestEvent(fc, e, cfg, foc, logrus.WithField("plugin", PluginName)); err != nil {
+		if err := HandlePullRequestEvent(fc, e, "", cfg, nil, foc, logrus.WithField("plugin", PluginName)); err != nil {
 			t.Errorf("didn't expect error from autoccComment: %v", err)
 			continue
 		}
@@ -0,0 +1,97 @@
+package blunderbuss
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/test-infra/prow/github"
+)
+
+// isLgtmLabeledEvent returns true if the event adds the LGTM label to the open PR.
+func isLgtmLabeledEvent(pe *github.PullRequestEvent, cfg *externalplugins.Configuration) bool {
+	if pe.Action != github.PullRequestActionLabeled || pe.PullRequest.State != "open" {
+		return false
+	}
+	return cfg.LabelSchemeFor(pe.Repo.Owner.Login, pe.Repo.Name).IsLgtmLabel(pe.Label.Name)
+}
+
+// handleLgtmLabeled assigns a committer to help merge the PR once the PR has acquired the required number
+// of LGTMs, and shows the assigned committer in the review notification. The committer is selected like
+// the load-aware reviewers, and nothing is assigned if a committer has been assigned already.
+func handleLgtmLabeled(gc githubClient, pe *github.PullRequestEvent, cfg *externalplugins.Configuration,
+	preferences map[string]ownersclient.ReviewerPreferences, ol ownersclient.OwnersLoader, log *logrus.Entry) error {
+	org := pe.Repo.Owner.Login
+	repo := pe.Repo.Name
+	pr := &pe.PullRequest
+	opts := cfg.BlunderbussFor(org, repo)
+
+	owners, err := ol.LoadOwners(opts.PullOwnersEndpoint, org, repo, pr.Number)
+	if err != nil {
+		return fmt.Errorf("error loading RepoOwners: %v", err)
+	}
+	committers := sets.NewString(owners.Committers...)
+	for _, assignee := range pr.Assignees {
+		if committers.Has(github.NormLogin(assignee.Login)) {
+			log.Infof("Skip assigning a committer because %s has been assigned.", assignee.Login)
+			return nil
+		}
+	}
+
+	labels, err := gc.GetIssueLabels(org, repo, pr.Number)
+	if err != nil {
+		return fmt.Errorf("error loading PullRequest labels: %v", err)
+	}
+	satisfied, err := isLgtmSatisfied(gc, cfg, owners, org, repo, pr.Number, labels)
+	if err != nil {
+		return err
+	}
+	if !satisfied {
+		return nil
+	}
+
+	// The committers who do not want to be requested now according to their preferences are excluded.
+	sigs := getPullRequestSigs(labels, owners)
+	candidates := getCandidates(pr.User.Login, owners.Committers, opts.ExcludeReviewers)
+	unavailable := getUnavailableReviewers(gc, opts, preferences, candidates, sigs, log)
+	excludeCommitters := append(append([]string{}, opts.ExcludeReviewers...), unavailable...)
+	selected := selectByLoad(gc, opts, getCandidates(pr.User.Login, owners.Committers, excludeCommitters), log)
+	if len(selected) == 0 {
+		log.Info("No committer is available to be assigned.")
+		return nil
+	}
+	committer := selected[0]
+
+	log.Infof("Assigning the committer %s.", committer)
+	if err := gc.AssignIssue(org, repo, pr.Number, []string{committer}); err != nil {
+		return err
+	}
+	return externalplugins.UpdateAssignedCommitters(gc, cfg, owners, org, repo, pr.Number, []string{committer}, log)
+}
+
+// isLgtmSatisfied returns true if the LGTM labels and the approvers in the review notification
+// satisfy the approval rules.
+func isLgtmSatisfied(gc githubClient, cfg *externalplugins.Configuration, owners *ownersclient.Owners,
+	org, repo string, number int, labels []github.Label) (bool, error) {
+	labelScheme := cfg.LabelSchemeFor(org, repo)
+	lgtmOpts := cfg.LgtmFor(org, repo)
+	// Find out the approvers only when the approval rules depend on who approves
+	// or the LGTM label does not record the number of LGTMs.
+	var approvers []string
+	if lgtmOpts.NeedsApprovers() || !labelScheme.IsLgtmLabelNumbered() {
+		botUserChecker, err := gc.BotUserChecker()
+		if err != nil {
+			return false, err
+		}
+		comments, err := gc.ListIssueComments(org, repo, number)
+		if err != nil {
+			return false, err
+		}
+		approverSet, _ := externalplugins.GetApproversFromComments(comments, botUserChecker)
+		approvers = approverSet.List()
+	}
+	lgtmCount := lgtmOpts.LgtmCountFromLabels(labelScheme, owners, labels, approvers)
+	return lgtmOpts.IsLgtmSatisfied(owners, lgtmCount, approvers), nil
+}
@@ -0,0 +1,158 @@
+package blunderbuss
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+	"k8s.io/test-infra/prow/github"
+)
+
+func TestHandleLgtmLabeled(t *testing.T) {
+	// Keep the order of the candidates with the same load.
+	oldShuffle := shuffle
+	defer func() { shuffle = oldShuffle }()
+	shuffle = func(int, func(int, int)) {}
+
+	notification := "[REVIEW NOTIFICATION]\n\nThis pull request has been approved by:\n\n- collab1\n- collab2\n\n" +
+		"<!--Review Notification Identifier-->"
+
+	testcases := []struct {
+		name                  string
+		assignCommitterOnLgtm bool
+		label                 string
+		labels                []string
+		assignees             []string
+		requiredCommitterLgtm int
+		preferences           map[string]ownersclient.ReviewerPreferences
+
+		expectAssigned []string
+		expectEdited   string
+	}{
+		{
+			name:                  "Assign the least loaded committer",
+			assignCommitterOnLgtm: true,
+			label:                 "status/LGT2",
+			labels:                []string{"status/LGT2"},
+			expectAssigned:        []string{"committer2"},
+			expectEdited:          "This pull request has been assigned to @committer2 to help you merge",
+		},
+		{
+			name:                  "Exclude the committers by the preferences",
+			assignCommitterOnLgtm: true,
+			label:                 "status/LGT2",
+			labels:                []string{"status/LGT2"},
+			preferences: map[string]ownersclient.ReviewerPreferences{
+				"committer2": {OptOut: true},
+			},
+			expectAssigned: []string{"committer3"},
+			expectEdited:   "This pull request has been assigned to @committer3 to help you merge",
+		},
+		{
+			name:                  "The LGTM is not satisfied",
+			assignCommitterOnLgtm: true,
+			label:                 "status/LGT1",
+			labels:                []string{"status/LGT1"},
+		},
+		{
+			name:                  "The approvals from committers are not enough",
+			assignCommitterOnLgtm: true,
+			label:                 "status/LGT2",
+			labels:                []string{"status/LGT2"},
+			requiredCommitterLgtm: 1,
+		},
+		{
+			name:                  "A committer has been assigned",
+			assignCommitterOnLgtm: true,
+			label:                 "status/LGT2",
+			labels:                []string{"status/LGT2"},
+			assignees:             []string{"Committer1"},
+		},
+		{
+			name:   "The option is disabled",
+			label:  "status/LGT2",
+			labels: []string{"status/LGT2"},
+		},
+		{
+			name:                  "Other label",
+			assignCommitterOnLgtm: true,
+			label:                 "type/bug",
+			labels:                []string{"status/LGT2", "type/bug"},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			pr := &github.PullRequest{
+				Number:             5,
+				State:              "open",
+				User:               github.User{Login: "author"},
+				Labels:             mapLabelNameToLabel(tc.labels),
+				Assignees:          mapGithubLoginToGithubUser(tc.assignees),
+				RequestedReviewers: mapGithubLoginToGithubUser([]string{"collab1"}),
+			}
+			fc := newFakeGitHubClient(pr)
+			fc.issueComments = []github.IssueComment{
+				{ID: 1, Body: notification, User: github.User{Login: "ti-chi-bot"}},
+			}
+			fc.pending = map[string]int{"committer1": 3, "committer2": 1, "committer3": 2}
+			e := &github.PullRequestEvent{
+				Action:      github.PullRequestActionLabeled,
+				Label:       github.Label{Name: tc.label},
+				Number:      5,
+				PullRequest: *pr,
+				Repo:        github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+			}
+			cfg := &externalplugins.Configuration{
+				TiCommunityBlunderbuss: []externalplugins.TiCommunityBlunderbuss{
+					{
+						Repos:                 []string{"org/repo"},
+						PullOwnersEndpoint:    "https://fake/ti-community-bot",
+						ExcludeReviewers:      []string{"committer4"},
+						AssignCommitterOnLgtm: tc.assignCommitterOnLgtm,
+					},
+				},
+				TiCommunityLgtm: []externalplugins.TiCommunityLgtm{
+					{
+						Repos:                 []string{"org/repo"},
+						RequiredCommitterLgtm: tc.requiredCommitterLgtm,
+					},
+				},
+			}
+			foc := &fakeOwnersClient{
+				committers: []string{"author", "committer1", "committer2", "committer3", "committer4"},
+				reviewers:  []string{"collab1", "collab2"},
+				needsLgtm:  2,
+			}
+
+			err := HandlePullRequestEvent(fc, e, "", cfg, tc.preferences, foc, logrus.WithField("plugin", PluginName))
+			if err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+			if !reflect.DeepEqual(fc.assigned, tc.expectAssigned) {
+				t.Errorf("assigned committers mismatch: got %v, want %v", fc.assigned, tc.expectAssigned)
+			}
+			if tc.expectEdited == "" {
+				if len(fc.editedComments) != 0 {
+					t.Errorf("didn't expect the notification to be edited: %q", fc.editedComments)
+				}
+				return
+			}
+			if len(fc.editedComments) != 1 {
+				t.Fatalf("expected the notification to be edited once, got %q", fc.editedComments)
+			}
+			edited := fc.editedComments[0]
+			if !strings.Contains(edited, tc.expectEdited) {
+				t.Errorf("edited notification mismatch: got %q, want to contain %q", edited, tc.expectEdited)
+			}
+			// The approvers in the notification are kept.
+			if !strings.Contains(edited, "- collab1\n- collab2\n") {
+				t.Errorf("expected the approvers to be kept in the notification: %q", edited)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package blunderbuss
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"k8s.io/test-infra/prow/github"
+)
+
+const (
+	// maxExpertiseFiles is the maximum number of the changed files whose history is looked up.
+	maxExpertiseFiles = 20
+	// historyDepth is the number of the recent commits looked up for each changed file.
+	historyDepth = 30
+	// reviewDepth is the number of the reviews looked up for the PR associated with each commit.
+	reviewDepth = 20
+	// expertiseHalfLife is the duration after which the weight of a commit is halved.
+	expertiseHalfLife = 90 * 24 * time.Hour
+	// lastTouchedLayout is the layout of the date when a reviewer last touched the changed files.
+	lastTouchedLayout = "2006-01-02"
+)
+
+// now is used to decay the weight of the commits, it is replaced in the tests.
+var now = time.Now
+
+type historyCommit struct {
+	OID           githubql.String `graphql:"oid"`
+	CommittedDate githubql.DateTime
+	Author        struct {
+		User struct {
+			Login githubql.String
+		}
+	}
+	AssociatedPullRequests struct {
+		Nodes []struct {
+			Reviews struct {
+				Nodes []struct {
+					Author struct {
+						Login githubql.String
+					}
+				}
+			} `graphql:"reviews(first: $reviewDepth)"`
+		}
+	} `graphql:"associatedPullRequests(first: 1)"`
+}
+
+type historyQuery struct {
+	Repository struct {
+		Object struct {
+			Commit struct {
+				History struct {
+					Nodes []historyCommit
+				} `graphql:"history(first: $historyDepth, path: $path)"`
+			} `graphql:"... on Commit"`
+		} `graphql:"object(expression: $ref)"`
+	} `graphql:"repository(owner: $owner, name: $name)"`
+}
+
+// reviewerExpertise describes how a candidate reviewer is related to the changed files.
+type reviewerExpertise struct {
+	Login string
+	// Authored is the number of the recent commits touching the changed files authored by the reviewer.
+	Authored int
+	// Reviewed is the number of the recent commits touching the changed files reviewed by the reviewer.
+	Reviewed int
+	// LastTouched is the date when the reviewer last authored or reviewed a commit touching the changed files.
+	LastTouched string
+
+	score       float64
+	lastTouched time.Time
+}
+
+// touch records that the reviewer authored or reviewed a commit at the given time.
+func (e *reviewerExpertise) touch(at time.Time) {
+	age := now().Sub(at)
+	if age < 0 {
+		age = 0
+	}
+	e.score += math.Pow(0.5, float64(age)/float64(expertiseHalfLife))
+	if at.After(e.lastTouched) {
+		e.lastTouched = at
+		e.LastTouched = at.Format(lastTouchedLayout)
+	}
+}
+
+// selectByExpertise orders the candidates by how recently and how often they authored or reviewed the commits
+// touching the files changed by the PR, the candidates without any of these commits are selected randomly after
+// the others.
+func selectByExpertise(gc githubClient, org, repo string, pr *github.PullRequest, candidates []string,
+	log *logrus.Entry) []*reviewerExpertise {
+	var expertises []*reviewerExpertise
+	candidateExpertise := make(map[string]*reviewerExpertise)
+	for _, candidate := range candidates {
+		expertise := &reviewerExpertise{Login: candidate}
+		expertises = append(expertises, expertise)
+		candidateExpertise[github.NormLogin(candidate)] = expertise
+	}
+
+	changes, err := gc.GetPullRequestChanges(org, repo, pr.Number)
+	if err != nil {
+		log.WithError(err).Warn("Failed to get the changed files, the reviewers are selected randomly.")
+	}
+	if len(changes) > maxExpertiseFiles {
+		changes = changes[:maxExpertiseFiles]
+	}
+
+	// The same commit may touch several changed files, it is only counted once.
+	seenCommits := make(map[string]bool)
+	for _, change := range changes {
+		commits, err := getFileHistory(gc, org, repo, pr.Base.Ref, change.Filename)
+		if err != nil {
+			log.WithError(err).Warnf("Failed to get the history of %s.", change.Filename)
+			continue
+		}
+		for _, commit := range commits {
+			if seenCommits[string(commit.OID)] {
+				continue
+			}
+			seenCommits[string(commit.OID)] = true
+
+			committedAt := commit.CommittedDate.Time
+			author := github.NormLogin(string(commit.Author.User.Login))
+			if expertise, ok := candidateExpertise[author]; ok {
+				expertise.Authored++
+				expertise.touch(committedAt)
+			}
+
+			reviewers := make(map[string]bool)
+			for _, associatedPR := range commit.AssociatedPullRequests.Nodes {
+				for _, review := range associatedPR.Reviews.Nodes {
+					reviewer := github.NormLogin(string(review.Author.Login))
+					if reviewer == author || reviewers[reviewer] {
+						continue
+					}
+					reviewers[reviewer] = true
+					if expertise, ok := candidateExpertise[reviewer]; ok {
+						expertise.Reviewed++
+						expertise.touch(committedAt)
+					}
+				}
+			}
+		}
+	}
+
+	shuffle(len(expertises), func(i, j int) {
+		expertises[i], expertises[j] = expertises[j], expertises[i]
+	})
+	sort.SliceStable(expertises, func(i, j int) bool {
+		return expertises[i].score > expertises[j].score
+	})
+	for i, expertise := range expertises {
+		log.Infof("Added %s as reviewers with expertise score %.2f. %d reviewers found.",
+			expertise.Login, expertise.score, i+1)
+	}
+	return expertises
+}
+
+// getFileHistory returns the recent commits touching the file on the branch.
+func getFileHistory(gc githubClient, org, repo, ref, path string) ([]historyCommit, error) {
+	var query historyQuery
+	vars := map[string]interface{}{
+		"owner":        githubql.String(org),
+		"name":         githubql.String(repo),
+		"ref":          githubql.String(ref),
+		"path":         githubql.String(path),
+		"historyDepth": githubql.Int(historyDepth),
+		"reviewDepth":  githubql.Int(reviewDepth),
+	}
+	if err := gc.Query(context.Background(), &query, vars); err != nil {
+		return nil, err
+	}
+	return query.Repository.Object.Commit.History.Nodes, nil
+}
+
+// explainReviewers comments on the PR to explain why each reviewer was chosen.
+func explainReviewers(gc githubClient, cfg *externalplugins.Configuration, org, repo string, number int,
+	reviewers []*reviewerExpertise) error {
+	resp, err := cfg.RenderMessage(org, repo, externalplugins.MessageBlunderbussReviewersChosen,
+		map[string]interface{}{
+			"chosenReviewers": reviewers,
+		})
+	if err != nil {
+		return err
+	}
+	return gc.CreateComment(org, repo, number, resp)
+}
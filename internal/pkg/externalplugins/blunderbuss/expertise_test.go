@@ -0,0 +1,234 @@
+package blunderbuss
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"k8s.io/test-infra/prow/github"
+)
+
+func newHistoryCommit(t *testing.T, oid string, committedDate string, author string,
+	reviewers ...string) historyCommit {
+	var reviews []map[string]interface{}
+	for _, reviewer := range reviewers {
+		reviews = append(reviews, map[string]interface{}{
+			"Author": map[string]interface{}{"Login": reviewer},
+		})
+	}
+	result, err := json.Marshal(map[string]interface{}{
+		"OID":           oid,
+		"CommittedDate": committedDate,
+		"Author":        map[string]interface{}{"User": map[string]interface{}{"Login": author}},
+		"AssociatedPullRequests": map[string]interface{}{
+			"Nodes": []map[string]interface{}{
+				{"Reviews": map[string]interface{}{"Nodes": reviews}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal the commit: %v", err)
+	}
+	var commit historyCommit
+	if err := json.Unmarshal(result, &commit); err != nil {
+		t.Fatalf("failed to unmarshal the commit: %v", err)
+	}
+	return commit
+}
+
+func newExpertiseFakeGitHubClient(t *testing.T, pr *github.PullRequest) *fakeGitHubClient {
+	fc := newFakeGitHubClient(pr)
+	fc.changes = []github.PullRequestChange{{Filename: "a.go"}, {Filename: "b.go"}, {Filename: "new.go"}}
+	fc.history = map[string][]historyCommit{
+		"a.go": {
+			newHistoryCommit(t, "c1", "2021-05-20T00:00:00Z", "collab1", "collab2"),
+			newHistoryCommit(t, "c2", "2020-01-01T00:00:00Z", "Collab3", "collab1", "collab1", "collab3"),
+		},
+		"b.go": {
+			newHistoryCommit(t, "c1", "2021-05-20T00:00:00Z", "collab1", "collab2"),
+			newHistoryCommit(t, "c3", "2021-05-30T00:00:00Z", "outsider", "collab2", "outsider"),
+		},
+	}
+	return fc
+}
+
+func TestSelectByExpertise(t *testing.T) {
+	oldShuffle := shuffle
+	oldNow := now
+	defer func() {
+		shuffle = oldShuffle
+		now = oldNow
+	}()
+	shuffle = func(int, func(int, int)) {}
+	now = func() time.Time {
+		return time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	type expertise struct {
+		login       string
+		authored    int
+		reviewed    int
+		lastTouched string
+	}
+	testcases := []struct {
+		name       string
+		candidates []string
+		noChanges  bool
+
+		expectExpertises []expertise
+	}{
+		{
+			name:       "Recent and frequent experts first",
+			candidates: []string{"collab1", "collab2", "collab3", "collab4"},
+			expectExpertises: []expertise{
+				{login: "collab2", reviewed: 2, lastTouched: "2021-05-30"},
+				{login: "collab1", authored: 1, reviewed: 1, lastTouched: "2021-05-20"},
+				{login: "collab3", authored: 1, lastTouched: "2020-01-01"},
+				{login: "collab4"},
+			},
+		},
+		{
+			name:       "Only the candidates are selected",
+			candidates: []string{"collab3", "collab4"},
+			expectExpertises: []expertise{
+				{login: "collab3", authored: 1, lastTouched: "2020-01-01"},
+				{login: "collab4"},
+			},
+		},
+		{
+			name:       "No changed files keeps the order",
+			candidates: []string{"collab3", "collab1"},
+			noChanges:  true,
+			expectExpertises: []expertise{
+				{login: "collab3"},
+				{login: "collab1"},
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			pr := &github.PullRequest{Number: 5, Base: github.PullRequestBranch{Ref: "master"}}
+			fc := newExpertiseFakeGitHubClient(t, pr)
+			if tc.noChanges {
+				fc.changes = nil
+			}
+
+			expertises := selectByExpertise(fc, "org", "repo", pr, tc.candidates,
+				logrus.WithField("plugin", PluginName))
+			var got []expertise
+			for _, e := range expertises {
+				got = append(got, expertise{
+					login:       e.Login,
+					authored:    e.Authored,
+					reviewed:    e.Reviewed,
+					lastTouched: e.LastTouched,
+				})
+			}
+			if !reflect.DeepEqual(got, tc.expectExpertises) {
+				t.Errorf("expertises mismatch: got %v, want %v", got, tc.expectExpertises)
+			}
+		})
+	}
+}
+
+func TestHandleWithExpertiseSelection(t *testing.T) {
+	oldShuffle := shuffle
+	oldNow := now
+	defer func() {
+		shuffle = oldShuffle
+		now = oldNow
+	}()
+	shuffle = func(int, func(int, int)) {}
+	now = func() time.Time {
+		return time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	testcases := []struct {
+		name             string
+		maxReviewerCount int
+
+		expectReviewers []string
+		expectComment   string
+	}{
+		{
+			name:             "Explain the experts",
+			maxReviewerCount: 2,
+			expectReviewers:  []string{"collab2", "collab1"},
+			expectComment: "Requested reviews from these reviewers:\n\n" +
+				"- collab2: authored 0 and reviewed 2 of the recent commits touching the changed files, " +
+				"most recently on 2021-05-30\n" +
+				"- collab1: authored 1 and reviewed 1 of the recent commits touching the changed files, " +
+				"most recently on 2021-05-20",
+		},
+		{
+			name:             "Explain the reviewers without history",
+			maxReviewerCount: 0,
+			expectReviewers:  []string{"collab2", "collab1", "collab4"},
+			expectComment: "Requested reviews from these reviewers:\n\n" +
+				"- collab2: authored 0 and reviewed 2 of the recent commits touching the changed files, " +
+				"most recently on 2021-05-30\n" +
+				"- collab1: authored 1 and reviewed 1 of the recent commits touching the changed files, " +
+				"most recently on 2021-05-20\n" +
+				"- collab4: chosen from the reviewers in OWNERS",
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			pr := &github.PullRequest{
+				Number: 5,
+				User:   github.User{Login: "author"},
+				Base:   github.PullRequestBranch{Ref: "master"},
+			}
+			fc := newExpertiseFakeGitHubClient(t, pr)
+			e := &github.IssueCommentEvent{
+				Action: github.IssueCommentActionCreated,
+				Issue: github.Issue{
+					User:        github.User{Login: "author"},
+					Number:      5,
+					State:       "open",
+					PullRequest: &struct{}{},
+				},
+				Comment: github.IssueComment{
+					Body: "/auto-cc",
+					User: github.User{Login: "commenter"},
+				},
+				Repo: github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+			}
+			cfg := &externalplugins.Configuration{
+				TiCommunityBlunderbuss: []externalplugins.TiCommunityBlunderbuss{
+					{
+						Repos:              []string{"org/repo"},
+						MaxReviewerCount:   tc.maxReviewerCount,
+						ExcludeReviewers:   []string{"collab3"},
+						PullOwnersEndpoint: "https://fake/ti-community-bot",
+						ReviewerSelection:  externalplugins.ReviewerSelectionExpertise,
+					},
+				},
+			}
+			foc := &fakeOwnersClient{
+				reviewers: []string{"author", "collab1", "collab2", "collab3", "collab4"},
+				needsLgtm: 2,
+			}
+
+			if err := HandleIssueCommentEvent(fc, e, cfg, nil, foc, logrus.WithField("plugin", PluginName)); err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+			if !reflect.DeepEqual(fc.requested, tc.expectReviewers) {
+				t.Errorf("requested reviewers mismatch: got %v, want %v", fc.requested, tc.expectReviewers)
+			}
+			if len(fc.comments) != 1 {
+				t.Fatalf("expected one comment, got %v", fc.comments)
+			}
+			if fc.comments[0] != tc.expectComment {
+				t.Errorf("comment mismatch: got %q, want %q", fc.comments[0], tc.expectComment)
+			}
+		})
+	}
+}
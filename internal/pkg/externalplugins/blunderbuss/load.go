@@ -0,0 +1,71 @@
+package blunderbuss
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+)
+
+// shuffle randomizes the order of the candidates before they are sorted by the load,
+// so that the reviewers with the same load are selected randomly.
+var shuffle = rand.Shuffle
+
+// reviewerLoad is the number of the pending review requests of a reviewer.
+type reviewerLoad struct {
+	login   string
+	pending int
+}
+
+// selectByLoad orders the candidates by the number of their pending review requests across the configured
+// repos, the least loaded first. The candidates who reach the maximum pending reviews are dropped.
+func selectByLoad(gc githubClient, opts *externalplugins.TiCommunityBlunderbuss, candidates []string,
+	log *logrus.Entry) []string {
+	orgs, repos := splitRepos(opts.Repos)
+	var loads []reviewerLoad
+	for _, candidate := range candidates {
+		pending, err := externalplugins.CountPendingReviewRequests(context.Background(), gc, candidate, orgs, repos)
+		if err != nil {
+			// The candidates whose load is unknown are selected after the others.
+			log.WithError(err).Warnf("Failed to count the pending review requests of %s.", candidate)
+			loads = append(loads, reviewerLoad{login: candidate, pending: math.MaxInt32})
+			continue
+		}
+		if opts.MaxPendingReviews > 0 && pending >= opts.MaxPendingReviews {
+			log.Infof("Skip %s because of %d pending review requests.", candidate, pending)
+			continue
+		}
+		loads = append(loads, reviewerLoad{login: candidate, pending: pending})
+	}
+
+	shuffle(len(loads), func(i, j int) {
+		loads[i], loads[j] = loads[j], loads[i]
+	})
+	sort.SliceStable(loads, func(i, j int) bool {
+		return loads[i].pending < loads[j].pending
+	})
+
+	var result []string
+	for _, load := range loads {
+		result = append(result, load.login)
+		log.Infof("Added %s as reviewers. %d reviewers found.", load.login, len(result))
+	}
+	return result
+}
+
+// splitRepos splits the configured repos into the orgs and the repos in the form of org/repo.
+func splitRepos(configuredRepos []string) ([]string, []string) {
+	var orgs, repos []string
+	for _, repo := range configuredRepos {
+		if strings.Contains(repo, "/") {
+			repos = append(repos, repo)
+		} else {
+			orgs = append(orgs, repo)
+		}
+	}
+	return orgs, repos
+}
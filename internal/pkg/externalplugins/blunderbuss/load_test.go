@@ -0,0 +1,146 @@
+package blunderbuss
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"k8s.io/test-infra/prow/github"
+)
+
+func TestSelectByLoad(t *testing.T) {
+	// Keep the order of the candidates with the same load.
+	oldShuffle := shuffle
+	defer func() { shuffle = oldShuffle }()
+	shuffle = func(int, func(int, int)) {}
+
+	testcases := []struct {
+		name              string
+		candidates        []string
+		pending           map[string]int
+		maxPendingReviews int
+
+		expectReviewers []string
+	}{
+		{
+			name:       "Least loaded first",
+			candidates: []string{"collab1", "collab2", "collab3"},
+			pending: map[string]int{
+				"collab1": 10,
+				"collab2": 0,
+				"collab3": 3,
+			},
+			expectReviewers: []string{"collab2", "collab3", "collab1"},
+		},
+		{
+			name:       "Same load keeps the order",
+			candidates: []string{"collab1", "collab2", "collab3"},
+			pending: map[string]int{
+				"collab1": 1,
+				"collab2": 0,
+				"collab3": 1,
+			},
+			expectReviewers: []string{"collab2", "collab1", "collab3"},
+		},
+		{
+			name:       "Reviewers reaching the cap are skipped",
+			candidates: []string{"collab1", "collab2", "collab3"},
+			pending: map[string]int{
+				"collab1": 5,
+				"collab2": 4,
+				"collab3": 6,
+			},
+			maxPendingReviews: 5,
+			expectReviewers:   []string{"collab2"},
+		},
+		{
+			name:       "Reviewers with unknown load are selected last",
+			candidates: []string{"collab1", "collab2"},
+			pending: map[string]int{
+				"collab2": 10,
+			},
+			maxPendingReviews: 5,
+			expectReviewers:   []string{"collab1"},
+		},
+		{
+			name:       "Unknown load is after the known load",
+			candidates: []string{"collab1", "collab2"},
+			pending: map[string]int{
+				"collab2": 10,
+			},
+			expectReviewers: []string{"collab2", "collab1"},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			fc := &fakeGitHubClient{pending: tc.pending}
+			opts := &externalplugins.TiCommunityBlunderbuss{
+				Repos:             []string{"org/repo", "org2"},
+				MaxPendingReviews: tc.maxPendingReviews,
+			}
+
+			reviewers := selectByLoad(fc, opts, tc.candidates, logrus.WithField("plugin", PluginName))
+			if !reflect.DeepEqual(reviewers, tc.expectReviewers) {
+				t.Errorf("reviewers mismatch: got %v, want %v", reviewers, tc.expectReviewers)
+			}
+		})
+	}
+}
+
+func TestHandleWithLoadSelection(t *testing.T) {
+	oldShuffle := shuffle
+	defer func() { shuffle = oldShuffle }()
+	shuffle = func(int, func(int, int)) {}
+
+	pr := github.PullRequest{
+		Number: 5,
+		User:   github.User{Login: "author"},
+	}
+	fc := newFakeGitHubClient(&pr)
+	fc.pending = map[string]int{
+		"collab1": 8,
+		"collab2": 2,
+		"collab3": 1,
+		"collab4": 2,
+	}
+	e := &github.IssueCommentEvent{
+		Action: github.IssueCommentActionCreated,
+		Issue: github.Issue{
+			User:        github.User{Login: "author"},
+			Number:      5,
+			State:       "open",
+			PullRequest: &struct{}{},
+		},
+		Comment: github.IssueComment{
+			Body: "/auto-cc",
+			User: github.User{Login: "commenter"},
+		},
+		Repo: github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+	}
+	cfg := &externalplugins.Configuration{
+		TiCommunityBlunderbuss: []externalplugins.TiCommunityBlunderbuss{
+			{
+				Repos:              []string{"org/repo"},
+				MaxReviewerCount:   2,
+				ExcludeReviewers:   []string{"collab4"},
+				PullOwnersEndpoint: "https://fake/ti-community-bot",
+				ReviewerSelection:  externalplugins.ReviewerSelectionLoad,
+			},
+		},
+	}
+	foc := &fakeOwnersClient{
+		reviewers: []string{"author", "collab1", "collab2", "collab3", "collab4"},
+		needsLgtm: 2,
+	}
+
+	if err := HandleIssueCommentEvent(fc, e, cfg, nil, foc, logrus.WithField("plugin", PluginName)); err != nil {
+		t.Fatalf("didn't expect error: %v", err)
+	}
+	expectReviewers := []string{"collab3", "collab2"}
+	if !reflect.DeepEqual(fc.requested, expectReviewers) {
+		t.Errorf("requested reviewers mismatch: got %v, want %v", fc.requested, expectReviewers)
+	}
+}
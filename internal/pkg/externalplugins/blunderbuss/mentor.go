@@ -0,0 +1,112 @@
+package blunderbuss
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/test-infra/prow/github"
+)
+
+// The author associations of the first-time contributors, see
+// https://docs.github.com/en/graphql/reference/enums#commentauthorassociation.
+const (
+	authorAssociationFirstTimeContributor = "FIRST_TIME_CONTRIBUTOR"
+	authorAssociationFirstTimer           = "FIRST_TIMER"
+)
+
+// IsFirstTimeContributor returns true if the author association means that the author has not contributed
+// to the repository before.
+func IsFirstTimeContributor(authorAssociation string) bool {
+	return authorAssociation == authorAssociationFirstTimeContributor ||
+		authorAssociation == authorAssociationFirstTimer
+}
+
+// welcomeFirstTimeContributor requests a review from a mentor of the PR opened by a first-time contributor
+// and welcomes the contributor, the requested mentor is added to the requested reviewers of the PR.
+func welcomeFirstTimeContributor(gc githubClient, cfg *externalplugins.Configuration,
+	preferences map[string]ownersclient.ReviewerPreferences, repo *github.Repo, pr *github.PullRequest,
+	log *logrus.Entry) error {
+	org := repo.Owner.Login
+	opts := cfg.BlunderbussFor(org, repo.Name)
+	firstTimeContributor := opts.FirstTimeContributor
+
+	sigs := getSigs(pr.Labels)
+	mentors := firstTimeContributor.MentorsFor(sigs)
+	unavailable := getUnavailableReviewers(gc, opts, preferences, mentors, sigs, log)
+
+	mentor := pickMentor(pr, mentors, append(append([]string{}, opts.ExcludeReviewers...), unavailable...), log)
+	if mentor != "" && !isRequested(pr, mentor) {
+		log.Infof("Requesting review from the mentor %s.", mentor)
+		if err := gc.RequestReview(org, repo.Name, pr.Number, []string{mentor}); err != nil {
+			return err
+		}
+		pr.RequestedReviewers = append(pr.RequestedReviewers, github.User{Login: mentor})
+	}
+
+	resp, err := cfg.RenderMessage(org, repo.Name, externalplugins.MessageBlunderbussWelcome, map[string]interface{}{
+		"author": pr.User.Login,
+		"mentor": mentor,
+		"links":  firstTimeContributor.Links,
+	})
+	if err != nil {
+		return err
+	}
+	return gc.CreateComment(org, repo.Name, pr.Number, resp)
+}
+
+// pickMentor returns one of the mentors randomly, the mentors already requested are preferred.
+func pickMentor(pr *github.PullRequest, mentors []string, excludeReviewers []string, log *logrus.Entry) string {
+	for _, mentor := range mentors {
+		if isRequested(pr, mentor) {
+			return mentor
+		}
+	}
+	candidates := getReviewers(pr.User.Login, mentors, excludeReviewers, log)
+	if len(candidates) == 0 {
+		return ""
+	}
+	return candidates[0]
+}
+
+// isRequested returns true if the review has been requested from the user.
+func isRequested(pr *github.PullRequest, login string) bool {
+	for _, reviewer := range pr.RequestedReviewers {
+		if github.NormLogin(reviewer.Login) == github.NormLogin(login) {
+			return true
+		}
+	}
+	return false
+}
+
+// getRequestedMentors returns the mentors requested for the PR of the first-time contributor.
+func getRequestedMentors(pr *github.PullRequest, opts *externalplugins.TiCommunityBlunderbuss) sets.String {
+	requested := sets.NewString()
+	firstTimeContributor := opts.FirstTimeContributor
+	if firstTimeContributor == nil || !github.HasLabel(firstTimeContributor.Label, pr.Labels) {
+		return requested
+	}
+	mentors := sets.NewString()
+	for _, mentor := range firstTimeContributor.AllMentors() {
+		mentors.Insert(github.NormLogin(mentor))
+	}
+	for _, reviewer := range pr.RequestedReviewers {
+		if mentors.Has(github.NormLogin(reviewer.Login)) {
+			requested.Insert(github.NormLogin(reviewer.Login))
+		}
+	}
+	return requested
+}
+
+// countRequestedReviewers returns the number of the requested reviewers of the PR, the mentors of the
+// first-time contributor are not counted, so that the reviewers are still requested for the PR.
+func countRequestedReviewers(pr *github.PullRequest, opts *externalplugins.TiCommunityBlunderbuss) int {
+	mentors := getRequestedMentors(pr, opts)
+	count := 0
+	for _, reviewer := range pr.RequestedReviewers {
+		if !mentors.Has(github.NormLogin(reviewer.Login)) {
+			count++
+		}
+	}
+	return count
+}
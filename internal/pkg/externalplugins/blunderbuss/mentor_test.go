@@ -0,0 +1,282 @@
+package blunderbuss
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+	"k8s.io/test-infra/prow/github"
+)
+
+func TestHandlePullRequestOpenedByFirstTimeContributor(t *testing.T) {
+	oldSleep := sleep
+	defer func() { sleep = oldSleep }()
+
+	firstTimeContributor := &externalplugins.FirstTimeContributor{
+		Mentors: []string{"mentor1"},
+		SigMentors: []externalplugins.SigMentors{
+			{Sig: "planner", Mentors: []string{"planner-mentor"}},
+		},
+		Label: "first-time-contributor",
+		Links: []externalplugins.ContributionLink{
+			{Name: "Contributing Guide", URL: "https://example.com/contributing"},
+		},
+	}
+	testcases := []struct {
+		name                 string
+		action               github.PullRequestEventAction
+		authorAssociation    string
+		mockAddSigLabel      bool
+		requestedReviewers   []string
+		firstTimeContributor *externalplugins.FirstTimeContributor
+		preferences          map[string]ownersclient.ReviewerPreferences
+		reviewers            []string
+
+		expectRequested  []string
+		expectLabels     []string
+		expectComments   []string
+		expectSleepCount int
+	}{
+		{
+			name:                 "first-time contributor",
+			action:               github.PullRequestActionOpened,
+			authorAssociation:    "FIRST_TIME_CONTRIBUTOR",
+			expectSleepCount:     1,
+			firstTimeContributor: firstTimeContributor,
+			expectRequested:      []string{"mentor1"},
+			expectLabels:         []string{"first-time-contributor"},
+			expectComments: []string{
+				"Welcome @author! It looks like this is your first pull request here, thanks for your contribution. " +
+					"@mentor1 will mentor you through the review of this pull request.\n\n" +
+					"These links may help you:\n\n- [Contributing Guide](https://example.com/contributing)",
+			},
+		},
+		{
+			name:                 "the mentor is not requested again as a reviewer",
+			action:               github.PullRequestActionOpened,
+			authorAssociation:    "FIRST_TIME_CONTRIBUTOR",
+			expectSleepCount:     1,
+			firstTimeContributor: firstTimeContributor,
+			reviewers:            []string{"mentor1", "collab1"},
+			expectRequested:      []string{"mentor1", "collab1"},
+			expectLabels:         []string{"first-time-contributor"},
+			expectComments: []string{
+				"Welcome @author! It looks like this is your first pull request here, thanks for your contribution. " +
+					"@mentor1 will mentor you through the review of this pull request.\n\n" +
+					"These links may help you:\n\n- [Contributing Guide](https://example.com/contributing)",
+			},
+		},
+		{
+			name:                 "first-timer with the sig label",
+			action:               github.PullRequestActionOpened,
+			authorAssociation:    "FIRST_TIMER",
+			expectSleepCount:     1,
+			mockAddSigLabel:      true,
+			firstTimeContributor: firstTimeContributor,
+			expectRequested:      []string{"planner-mentor"},
+			expectLabels:         []string{"first-time-contributor", "sig/planner"},
+			expectComments: []string{
+				"Welcome @author! It looks like this is your first pull request here, thanks for your contribution. " +
+					"@planner-mentor will mentor you through the review of this pull request.\n\n" +
+					"These links may help you:\n\n- [Contributing Guide](https://example.com/contributing)",
+			},
+		},
+		{
+			name:                 "the mentor has been requested",
+			action:               github.PullRequestActionOpened,
+			authorAssociation:    "FIRST_TIME_CONTRIBUTOR",
+			expectSleepCount:     1,
+			requestedReviewers:   []string{"Mentor1"},
+			firstTimeContributor: firstTimeContributor,
+			expectLabels:         []string{"first-time-contributor"},
+			expectComments: []string{
+				"Welcome @author! It looks like this is your first pull request here, thanks for your contribution. " +
+					"@mentor1 will mentor you through the review of this pull request.\n\n" +
+					"These links may help you:\n\n- [Contributing Guide](https://example.com/contributing)",
+			},
+		},
+		{
+			name:              "the author is the only mentor",
+			action:            github.PullRequestActionOpened,
+			authorAssociation: "FIRST_TIME_CONTRIBUTOR",
+			expectSleepCount:  1,
+			firstTimeContributor: &externalplugins.FirstTimeContributor{
+				Mentors: []string{"author"},
+				Label:   "first-time-contributor",
+			},
+			expectLabels: []string{"first-time-contributor"},
+			expectComments: []string{
+				"Welcome @author! It looks like this is your first pull request here, thanks for your contribution.",
+			},
+		},
+		{
+			name:                 "the mentor opted out",
+			action:               github.PullRequestActionOpened,
+			authorAssociation:    "FIRST_TIME_CONTRIBUTOR",
+			expectSleepCount:     1,
+			firstTimeContributor: firstTimeContributor,
+			preferences: map[string]ownersclient.ReviewerPreferences{
+				"mentor1": {OptOut: true},
+			},
+			expectLabels: []string{"first-time-contributor"},
+			expectComments: []string{
+				"Welcome @author! It looks like this is your first pull request here, thanks for your contribution." +
+					"\n\nThese links may help you:\n\n- [Contributing Guide](https://example.com/contributing)",
+			},
+		},
+		{
+			name:                 "contributor",
+			action:               github.PullRequestActionOpened,
+			authorAssociation:    "CONTRIBUTOR",
+			expectSleepCount:     1,
+			firstTimeContributor: firstTimeContributor,
+		},
+		{
+			name:                 "labeled event",
+			action:               github.PullRequestActionLabeled,
+			authorAssociation:    "FIRST_TIME_CONTRIBUTOR",
+			firstTimeContributor: firstTimeContributor,
+		},
+		{
+			name:              "mentors are not configured",
+			action:            github.PullRequestActionOpened,
+			authorAssociation: "FIRST_TIME_CONTRIBUTOR",
+			expectSleepCount:  1,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			pr := github.PullRequest{
+				Number:             5,
+				State:              "open",
+				User:               github.User{Login: "author"},
+				RequestedReviewers: mapGithubLoginToGithubUser(tc.requestedReviewers),
+			}
+			fc := newFakeGitHubClient(&pr)
+			sleepCount := 0
+			sleep = func(time.Duration) {
+				sleepCount++
+				if tc.mockAddSigLabel {
+					_ = fc.AddLabel("org", "repo", pr.Number, "sig/planner")
+				}
+			}
+			e := &github.PullRequestEvent{
+				Action:      tc.action,
+				Number:      5,
+				PullRequest: pr,
+				Repo:        github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+			}
+			cfg := &externalplugins.Configuration{
+				TiCommunityBlunderbuss: []externalplugins.TiCommunityBlunderbuss{
+					{
+						Repos:                []string{"org/repo"},
+						MaxReviewerCount:     2,
+						PullOwnersEndpoint:   "https://fake/ti-community-bot",
+						FirstTimeContributor: tc.firstTimeContributor,
+					},
+				},
+			}
+
+			foc := &fakeOwnersClient{reviewers: tc.reviewers}
+
+			err := HandlePullRequestEvent(fc, e, tc.authorAssociation, cfg, tc.preferences, foc,
+				logrus.WithField("plugin", PluginName))
+			if err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+			// The mentor and the reviewers are selected after the same grace period.
+			if sleepCount != tc.expectSleepCount {
+				t.Errorf("sleep count mismatch: got %d, want %d", sleepCount, tc.expectSleepCount)
+			}
+			if !reflect.DeepEqual(fc.requested, tc.expectRequested) {
+				t.Errorf("requested reviewers mismatch: got %v, want %v", fc.requested, tc.expectRequested)
+			}
+			var labels []string
+			for _, label := range fc.pr.Labels {
+				labels = append(labels, label.Name)
+			}
+			if !reflect.DeepEqual(labels, tc.expectLabels) {
+				t.Errorf("labels mismatch: got %v, want %v", labels, tc.expectLabels)
+			}
+			if !reflect.DeepEqual(fc.comments, tc.expectComments) {
+				t.Errorf("comments mismatch: got %q, want %q", fc.comments, tc.expectComments)
+			}
+		})
+	}
+}
+
+func TestHandlePullRequestEventWithMentor(t *testing.T) {
+	testcases := []struct {
+		name               string
+		labels             []string
+		requestedReviewers []string
+
+		expectRequestCount int
+	}{
+		{
+			name:               "only the mentor is requested",
+			labels:             []string{"first-time-contributor"},
+			requestedReviewers: []string{"mentor1"},
+			expectRequestCount: 2,
+		},
+		{
+			name:               "the mentor and a reviewer are requested",
+			labels:             []string{"first-time-contributor"},
+			requestedReviewers: []string{"mentor1", "collab1"},
+		},
+		{
+			name:               "the PR is not from a first-time contributor",
+			requestedReviewers: []string{"mentor1"},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			pr := github.PullRequest{
+				Number:             5,
+				State:              "open",
+				User:               github.User{Login: "author"},
+				Labels:             mapLabelNameToLabel(append(tc.labels, "sig/planner")),
+				RequestedReviewers: mapGithubLoginToGithubUser(tc.requestedReviewers),
+			}
+			fc := newFakeGitHubClient(&pr)
+			e := &github.PullRequestEvent{
+				Action:      github.PullRequestActionLabeled,
+				Number:      5,
+				PullRequest: pr,
+				Label:       github.Label{Name: "sig/planner"},
+				Repo:        github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+			}
+			cfg := &externalplugins.Configuration{
+				TiCommunityBlunderbuss: []externalplugins.TiCommunityBlunderbuss{
+					{
+						Repos:              []string{"org/repo"},
+						MaxReviewerCount:   2,
+						PullOwnersEndpoint: "https://fake/ti-community-bot",
+						FirstTimeContributor: &externalplugins.FirstTimeContributor{
+							Mentors: []string{"mentor1"},
+							Label:   "first-time-contributor",
+						},
+					},
+				},
+			}
+			foc := &fakeOwnersClient{
+				reviewers: []string{"author", "collab1", "collab2", "collab3"},
+			}
+
+			if err := HandlePullRequestEvent(fc, e, "", cfg, nil, foc, logrus.WithField("plugin", PluginName)); err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+			if len(fc.requested) != tc.expectRequestCount {
+				t.Errorf("requested reviewers mismatch: got %v, want %d reviewers", fc.requested,
+					tc.expectRequestCount)
+			}
+		})
+	}
+}
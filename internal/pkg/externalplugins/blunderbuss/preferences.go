@@ -0,0 +1,86 @@
+package blunderbuss
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+	"k8s.io/test-infra/prow/github"
+)
+
+// reviewerPrefsRe matches the `/reviewer-prefs` command.
+var reviewerPrefsRe = regexp.MustCompile(`(?mi)^/reviewer-prefs[ \t]*$`)
+
+// getUnavailableReviewers returns the candidates who do not want the reviews of the PR of the sigs
+// to be requested from them automatically now.
+func getUnavailableReviewers(gc githubClient, opts *externalplugins.TiCommunityBlunderbuss,
+	preferences map[string]ownersclient.ReviewerPreferences, candidates []string, sigs []string,
+	log *logrus.Entry) []string {
+	current := now()
+	orgs, repos := splitRepos(opts.Repos)
+	var unavailable []string
+	for _, candidate := range candidates {
+		preference, ok := preferences[github.NormLogin(candidate)]
+		if !ok {
+			continue
+		}
+		if reason := externalplugins.ReviewerUnavailableReason(preference, sigs, current); reason != "" {
+			log.Infof("Skip %s because the reviewer %s.", candidate, reason)
+			unavailable = append(unavailable, candidate)
+			continue
+		}
+		if preference.MaxWeeklyRequests == 0 {
+			continue
+		}
+		count, err := externalplugins.CountRecentReviewRequests(context.Background(), gc, candidate, orgs, repos,
+			current.AddDate(0, 0, -7))
+		if err != nil {
+			log.WithError(err).Warnf("Failed to count the recent review requests of %s.", candidate)
+			continue
+		}
+		if count >= preference.MaxWeeklyRequests {
+			log.Infof("Skip %s because of %d review requests in the past week.", candidate, count)
+			unavailable = append(unavailable, candidate)
+		}
+	}
+	return unavailable
+}
+
+// getSigs returns the names of the sigs of the labels.
+func getSigs(labels []github.Label) []string {
+	var sigs []string
+	for _, label := range labels {
+		if strings.HasPrefix(label.Name, externalplugins.SigPrefix) {
+			sigs = append(sigs, strings.TrimPrefix(label.Name, externalplugins.SigPrefix))
+		}
+	}
+	return sigs
+}
+
+// HandleReviewerPreferencesCommand shows the reviewer preferences of the commenter by `/reviewer-prefs`.
+// The preferences are kept in the file of the config repo, so they are changed by updating the file.
+func HandleReviewerPreferencesCommand(gc githubClient, ce *github.IssueCommentEvent,
+	cfg *externalplugins.Configuration, preferences map[string]ownersclient.ReviewerPreferences,
+	log *logrus.Entry) error {
+	if ce.Action != github.IssueCommentActionCreated || !reviewerPrefsRe.MatchString(ce.Comment.Body) {
+		return nil
+	}
+	org := ce.Repo.Owner.Login
+	repo := ce.Repo.Name
+	login := ce.Comment.User.Login
+
+	log.Infof("Showing the reviewer preferences of %s.", login)
+	resp, err := cfg.RenderMessage(org, repo, externalplugins.MessageBlunderbussReviewerPreferences,
+		map[string]interface{}{
+			"login":       login,
+			"preferences": preferences[github.NormLogin(login)],
+		})
+	if err != nil {
+		return err
+	}
+	return gc.CreateComment(org, repo, ce.Issue.Number,
+		cfg.FormatResponseRaw(org, repo, ce.Comment.Body, ce.Comment.HTMLURL, login, resp))
+}
@@ -0,0 +1,160 @@
+package blunderbuss
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+	"k8s.io/test-infra/prow/github"
+)
+
+func TestHandleWithReviewerPreferences(t *testing.T) {
+	oldNow := now
+	defer func() { now = oldNow }()
+	saturday := time.Date(2021, 6, 5, 12, 0, 0, 0, time.UTC)
+	monday := time.Date(2021, 6, 7, 12, 0, 0, 0, time.UTC)
+
+	preferences := map[string]ownersclient.ReviewerPreferences{
+		"collab1": {OptOut: true},
+		"collab2": {SkipWeekends: true},
+		"collab3": {Sigs: []string{"planner"}},
+		"collab4": {MaxWeeklyRequests: 2},
+		"collab5": {MaxWeeklyRequests: 3},
+	}
+	testcases := []struct {
+		name   string
+		now    time.Time
+		labels []string
+
+		expectReviewers []string
+	}{
+		{
+			name:            "Respect the preferences",
+			now:             saturday,
+			expectReviewers: []string{"collab5"},
+		},
+		{
+			name:            "Request on weekdays for the sig",
+			now:             monday,
+			labels:          []string{"sig/planner"},
+			expectReviewers: []string{"collab2", "collab3", "collab5"},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			now = func() time.Time {
+				return tc.now
+			}
+			pr := &github.PullRequest{
+				Number: 5,
+				User:   github.User{Login: "author"},
+				Labels: mapLabelNameToLabel(tc.labels),
+			}
+			fc := newFakeGitHubClient(pr)
+			fc.recent = map[string]int{"collab4": 2, "collab5": 2}
+			e := &github.IssueCommentEvent{
+				Action: github.IssueCommentActionCreated,
+				Issue: github.Issue{
+					User:        github.User{Login: "author"},
+					Number:      5,
+					State:       "open",
+					PullRequest: &struct{}{},
+				},
+				Comment: github.IssueComment{
+					Body: "/auto-cc",
+					User: github.User{Login: "commenter"},
+				},
+				Repo: github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+			}
+			cfg := &externalplugins.Configuration{
+				TiCommunityBlunderbuss: []externalplugins.TiCommunityBlunderbuss{
+					{
+						Repos:              []string{"org/repo"},
+						PullOwnersEndpoint: "https://fake/ti-community-bot",
+					},
+				},
+			}
+			foc := &fakeOwnersClient{
+				reviewers: []string{"author", "collab1", "collab2", "collab3", "collab4", "collab5"},
+				needsLgtm: 2,
+			}
+
+			err := HandleIssueCommentEvent(fc, e, cfg, preferences, foc, logrus.WithField("plugin", PluginName))
+			if err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+			sort.Strings(fc.requested)
+			if !reflect.DeepEqual(fc.requested, tc.expectReviewers) {
+				t.Errorf("requested reviewers mismatch: got %v, want %v", fc.requested, tc.expectReviewers)
+			}
+		})
+	}
+}
+
+func TestHandleReviewerPreferencesCommand(t *testing.T) {
+	testcases := []struct {
+		name string
+		body string
+
+		expectReply string
+	}{
+		{
+			name: "Show the preferences",
+			body: "/reviewer-prefs",
+			expectReply: "The reviewer preferences of Commenter:\n\n" +
+				"- Automatic review requests: enabled\n- Skip weekends: yes\n- Time zone: UTC\n" +
+				"- Maximum requests per week: unlimited\n- SIGs: all\n- Review reminders: on\n\n" +
+				"The preferences can be changed in the reviewer preferences file of the config repo.",
+		},
+		{
+			name: "The command does not accept arguments",
+			body: "/reviewer-prefs opt-out",
+		},
+		{
+			name: "Other comment",
+			body: "/auto-cc",
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			fc := newFakeGitHubClient(&github.PullRequest{Number: 5})
+			e := &github.IssueCommentEvent{
+				Action: github.IssueCommentActionCreated,
+				Issue:  github.Issue{Number: 5, State: "open"},
+				Comment: github.IssueComment{
+					Body: tc.body,
+					User: github.User{Login: "Commenter"},
+				},
+				Repo: github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+			}
+			preferences := map[string]ownersclient.ReviewerPreferences{
+				"commenter": {SkipWeekends: true},
+			}
+
+			err := HandleReviewerPreferencesCommand(fc, e, &externalplugins.Configuration{}, preferences,
+				logrus.WithField("plugin", PluginName))
+			if err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+
+			if tc.expectReply == "" {
+				if len(fc.comments) != 0 {
+					t.Errorf("didn't expect the reply: %q", fc.comments)
+				}
+				return
+			}
+			if len(fc.comments) != 1 || !strings.Contains(fc.comments[0], tc.expectReply) {
+				t.Errorf("reply mismatch: got %q, want to contain %q", fc.comments, tc.expectReply)
+			}
+		})
+	}
+}
@@ -0,0 +1,336 @@
+package blunderbuss
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/test-infra/prow/github"
+)
+
+// reviewReminderIdentifier identifies the comments which ping the requested reviewers.
+const reviewReminderIdentifier = "Blunderbuss Review Reminder"
+
+// reviewReminderRe matches the reviewers pinged by the reminder comment.
+var reviewReminderRe = regexp.MustCompile("<!--" + reviewReminderIdentifier + ": ([^>]*)-->")
+
+type reviewRequestsQuery struct {
+	Repository struct {
+		PullRequest struct {
+			IsDraft        githubql.Boolean
+			ReviewRequests struct {
+				Nodes []struct {
+					RequestedReviewer struct {
+						User struct {
+							Login githubql.String
+						} `graphql:"... on User"`
+					}
+				}
+			} `graphql:"reviewRequests(first: 100)"`
+			TimelineItems struct {
+				Nodes []struct {
+					ReviewRequestedEvent struct {
+						CreatedAt         githubql.DateTime
+						RequestedReviewer struct {
+							User struct {
+								Login githubql.String
+							} `graphql:"... on User"`
+						}
+					} `graphql:"... on ReviewRequestedEvent"`
+				}
+			} `graphql:"timelineItems(last: 100, itemTypes: [REVIEW_REQUESTED_EVENT])"`
+		} `graphql:"pullRequest(number: $number)"`
+	} `graphql:"repository(owner: $owner, name: $name)"`
+}
+
+// reviewRequest is a pending review request of the PR.
+type reviewRequest struct {
+	login       string
+	requestedAt time.Time
+}
+
+// HandleAll reminds the requested reviewers of all open PRs who have not reviewed in time,
+// and escalates the reviews which are pending for too long.
+func HandleAll(log *logrus.Entry, gc githubClient, cfg *externalplugins.Configuration,
+	preferences map[string]ownersclient.ReviewerPreferences, ol ownersclient.OwnersLoader) error {
+	log.Info("Checking the review requests of all PRs.")
+	handled := sets.NewString()
+	for _, blunderbuss := range cfg.TiCommunityBlunderbuss {
+		if blunderbuss.ReviewReminder == nil {
+			continue
+		}
+		orgs, repos := splitRepos(blunderbuss.Repos)
+		prs, err := externalplugins.SearchOpenPullRequests(context.Background(), log, gc, orgs, repos)
+		if err != nil {
+			log.WithError(err).Errorf("Failed to search the PRs of %v.", blunderbuss.Repos)
+			continue
+		}
+		for i := range prs {
+			pr := prs[i]
+			org := string(pr.Repository.Owner.Login)
+			repo := string(pr.Repository.Name)
+			number := int(pr.Number)
+			// The PR may be found by the configs of both the org and the repo.
+			key := fmt.Sprintf("%s/%s#%d", org, repo, number)
+			if handled.Has(key) {
+				continue
+			}
+			handled.Insert(key)
+
+			l := log.WithFields(logrus.Fields{
+				"org":  org,
+				"repo": repo,
+				"pr":   number,
+			})
+			if err := handleReviewReminder(gc, cfg, ol, &pr, preferences, l); err != nil {
+				l.WithError(err).Error("Error reminding the reviewers.")
+			}
+		}
+	}
+	return nil
+}
+
+// handleReviewReminder pings the requested reviewers of the PR who have not reviewed in time,
+// and escalates the review if it is pending for too long.
+func handleReviewReminder(gc githubClient, cfg *externalplugins.Configuration, ol ownersclient.OwnersLoader,
+	pr *externalplugins.PullRequest, preferences map[string]ownersclient.ReviewerPreferences,
+	log *logrus.Entry) error {
+	org := string(pr.Repository.Owner.Login)
+	repo := string(pr.Repository.Name)
+	number := int(pr.Number)
+	opts := cfg.BlunderbussFor(org, repo)
+	reminder := opts.ReviewReminder
+	if reminder == nil {
+		return nil
+	}
+	current := now()
+	if reminder.QuietHours != nil && reminder.QuietHours.Contains(current) {
+		log.Info("Skip the review reminder during the quiet hours.")
+		return nil
+	}
+
+	var labels, sigs []string
+	for _, label := range pr.Labels.Nodes {
+		labels = append(labels, string(label.Name))
+		if strings.HasPrefix(string(label.Name), externalplugins.SigPrefix) {
+			sigs = append(sigs, strings.TrimPrefix(string(label.Name), externalplugins.SigPrefix))
+		}
+	}
+	remindAfter, escalateAfter := reminder.ThresholdsFor(sigs)
+	if remindAfter == 0 && escalateAfter == 0 {
+		return nil
+	}
+
+	requests, err := getPendingReviewRequests(gc, org, repo, number)
+	if err != nil || len(requests) == 0 {
+		return err
+	}
+
+	if remindAfter > 0 {
+		reminded, err := getRemindedTimes(gc, org, repo, number)
+		if err != nil {
+			return err
+		}
+		var reviewers []string
+		for _, request := range requests {
+			if current.Sub(request.requestedAt) < time.Duration(remindAfter)*time.Hour {
+				continue
+			}
+			// Each review request is only reminded once.
+			if remindedAt, ok := reminded[github.NormLogin(request.login)]; ok && remindedAt.After(request.requestedAt) {
+				continue
+			}
+			if preferences[github.NormLogin(request.login)].NoReminders {
+				log.Infof("Skip reminding %s because the reviewer turned off the reminders.", request.login)
+				continue
+			}
+			reviewers = append(reviewers, request.login)
+		}
+		if len(reviewers) != 0 {
+			log.Infof("Reminding the reviewers %v.", reviewers)
+			if err := remindReviewers(gc, cfg, org, repo, number, reviewers, remindAfter); err != nil {
+				return err
+			}
+		}
+	}
+
+	if escalateAfter > 0 && !sets.NewString(labels...).Has(reminder.AttentionLabel) {
+		var stalled []string
+		for _, request := range requests {
+			if current.Sub(request.requestedAt) >= time.Duration(escalateAfter)*time.Hour {
+				stalled = append(stalled, request.login)
+			}
+		}
+		if len(stalled) != 0 {
+			log.Infof("Escalating the review because of the stalled reviewers %v.", stalled)
+			return escalateReview(gc, cfg, ol, pr, requests, stalled, escalateAfter, sigs, preferences, log)
+		}
+	}
+	return nil
+}
+
+// getPendingReviewRequests returns the pending review requests of the users, the draft PRs have none.
+func getPendingReviewRequests(gc githubClient, org, repo string, number int) ([]reviewRequest, error) {
+	var query reviewRequestsQuery
+	vars := map[string]interface{}{
+		"owner":  githubql.String(org),
+		"name":   githubql.String(repo),
+		"number": githubql.Int(number),
+	}
+	if err := gc.Query(context.Background(), &query, vars); err != nil {
+		return nil, err
+	}
+	pullRequest := query.Repository.PullRequest
+	if pullRequest.IsDraft {
+		return nil, nil
+	}
+
+	// The review may be requested several times, the latest request is used.
+	requestedAt := make(map[string]time.Time)
+	for _, node := range pullRequest.TimelineItems.Nodes {
+		event := node.ReviewRequestedEvent
+		login := github.NormLogin(string(event.RequestedReviewer.User.Login))
+		if event.CreatedAt.After(requestedAt[login]) {
+			requestedAt[login] = event.CreatedAt.Time
+		}
+	}
+
+	var requests []reviewRequest
+	for _, node := range pullRequest.ReviewRequests.Nodes {
+		login := string(node.RequestedReviewer.User.Login)
+		// The teams and the requests without the request events are ignored.
+		at, ok := requestedAt[github.NormLogin(login)]
+		if login == "" || !ok {
+			continue
+		}
+		requests = append(requests, reviewRequest{login: login, requestedAt: at})
+	}
+	return requests, nil
+}
+
+// getRemindedTimes returns when each reviewer was last pinged by the reminder comments.
+func getRemindedTimes(gc githubClient, org, repo string, number int) (map[string]time.Time, error) {
+	botUserChecker, err := gc.BotUserChecker()
+	if err != nil {
+		return nil, err
+	}
+	comments, err := gc.ListIssueComments(org, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	reminded := make(map[string]time.Time)
+	for _, comment := range comments {
+		if !botUserChecker(comment.User.Login) {
+			continue
+		}
+		m := reviewReminderRe.FindStringSubmatch(comment.Body)
+		if m == nil {
+			continue
+		}
+		for _, login := range strings.Split(m[1], ",") {
+			login = github.NormLogin(strings.TrimSpace(login))
+			if comment.CreatedAt.After(reminded[login]) {
+				reminded[login] = comment.CreatedAt
+			}
+		}
+	}
+	return reminded, nil
+}
+
+// remindReviewers pings the reviewers and records them in the comment.
+func remindReviewers(gc githubClient, cfg *externalplugins.Configuration, org, repo string, number int,
+	reviewers []string, remindAfter int) error {
+	resp, err := cfg.RenderMessage(org, repo, externalplugins.MessageBlunderbussReviewReminder,
+		map[string]interface{}{
+			"reviewers": reviewers,
+			"hours":     remindAfter,
+		})
+	if err != nil {
+		return err
+	}
+	body := fmt.Sprintf("%s\n\n<!--%s: %s-->", resp, reviewReminderIdentifier, strings.Join(reviewers, ","))
+	return gc.CreateComment(org, repo, number, body)
+}
+
+// escalateReview requests reviews from the other reviewers or the committers of the SIGs,
+// and adds the attention label to the PR.
+func escalateReview(gc githubClient, cfg *externalplugins.Configuration, ol ownersclient.OwnersLoader,
+	pr *externalplugins.PullRequest, requests []reviewRequest, stalled []string, escalateAfter int, sigs []string,
+	preferences map[string]ownersclient.ReviewerPreferences, log *logrus.Entry) error {
+	org := string(pr.Repository.Owner.Login)
+	repo := string(pr.Repository.Name)
+	number := int(pr.Number)
+	opts := cfg.BlunderbussFor(org, repo)
+	reminder := opts.ReviewReminder
+
+	owners, err := ol.LoadOwners(opts.PullOwnersEndpoint, org, repo, number)
+	if err != nil {
+		return fmt.Errorf("error loading RepoOwners: %v", err)
+	}
+	pool := owners.Reviewers
+	if reminder.EscalateToCommitters {
+		pool = owners.Committers
+	}
+	// The reviewers who are already requested are not requested again.
+	exclude := append([]string{}, opts.ExcludeReviewers...)
+	for _, request := range requests {
+		exclude = append(exclude, github.NormLogin(request.login))
+	}
+	candidates := getCandidates(string(pr.Author.Login), pool, exclude)
+	exclude = append(exclude, getUnavailableReviewers(gc, opts, preferences, candidates, sigs, log)...)
+	added := getReviewers(string(pr.Author.Login), pool, exclude, log)
+	if len(added) > reminder.EscalationReviewerCount {
+		added = added[:reminder.EscalationReviewerCount]
+	}
+	if len(added) != 0 {
+		log.Infof("Requesting reviews from users %s.", added)
+		if err := gc.RequestReview(org, repo, number, added); err != nil {
+			return err
+		}
+	}
+
+	if err := gc.AddLabel(org, repo, number, reminder.AttentionLabel); err != nil {
+		return err
+	}
+
+	resp, err := cfg.RenderMessage(org, repo, externalplugins.MessageBlunderbussReviewEscalated,
+		map[string]interface{}{
+			"reviewers":  stalled,
+			"hours":      escalateAfter,
+			"added":      added,
+			"committers": reminder.EscalateToCommitters,
+			"label":      reminder.AttentionLabel,
+		})
+	if err != nil {
+		return err
+	}
+	return gc.CreateComment(org, repo, number, resp)
+}
+
+// HandlePullRequestReviewEvent removes the attention label once the PR is reviewed.
+func HandlePullRequestReviewEvent(gc githubClient, e *github.ReviewEvent, cfg *externalplugins.Configuration,
+	log *logrus.Entry) error {
+	if e.Action != github.ReviewActionSubmitted || e.PullRequest.State != "open" {
+		return nil
+	}
+	org := e.Repo.Owner.Login
+	repo := e.Repo.Name
+	reminder := cfg.BlunderbussFor(org, repo).ReviewReminder
+	if reminder == nil || !github.HasLabel(reminder.AttentionLabel, e.PullRequest.Labels) {
+		return nil
+	}
+	// The reviews of the author do not resolve the escalation.
+	if github.NormLogin(e.Review.User.Login) == github.NormLogin(e.PullRequest.User.Login) {
+		return nil
+	}
+	log.Infof("Removing the '%s' label because the PR is reviewed by %s.", reminder.AttentionLabel,
+		e.Review.User.Login)
+	return gc.RemoveLabel(org, repo, e.PullRequest.Number, reminder.AttentionLabel)
+}
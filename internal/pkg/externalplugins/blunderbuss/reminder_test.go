@@ -0,0 +1,365 @@
+package blunderbuss
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+	"k8s.io/test-infra/prow/github"
+)
+
+func TestHandleAll(t *testing.T) {
+	oldShuffle := shuffle
+	oldNow := now
+	defer func() {
+		shuffle = oldShuffle
+		now = oldNow
+	}()
+	shuffle = func(int, func(int, int)) {}
+	now = func() time.Time {
+		return time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	}
+
+	testcases := []struct {
+		name                 string
+		labels               []string
+		draft                bool
+		reviewRequests       map[string]string
+		issueComments        []github.IssueComment
+		escalateToCommitters bool
+		quietHours           *externalplugins.QuietHours
+		preferences          map[string]ownersclient.ReviewerPreferences
+
+		expectComments    []string
+		expectRequested   []string
+		expectAddedLabels []string
+	}{
+		{
+			name: "review requests in time",
+			reviewRequests: map[string]string{
+				"collab1": "2021-06-01T00:00:00Z",
+			},
+		},
+		{
+			name: "remind the stalled reviewer",
+			reviewRequests: map[string]string{
+				"collab1": "2021-05-31T00:00:00Z",
+				"collab2": "2021-06-01T06:00:00Z",
+			},
+			expectComments: []string{
+				"@collab1 this pull request has been waiting for your review for more than 24 hours, " +
+					"please take a look.\n\n<!--Blunderbuss Review Reminder: collab1-->",
+			},
+		},
+		{
+			name: "the reviewer turned off the reminders",
+			reviewRequests: map[string]string{
+				"collab1": "2021-05-31T00:00:00Z",
+			},
+			preferences: map[string]ownersclient.ReviewerPreferences{
+				"collab1": {NoReminders: true},
+			},
+		},
+		{
+			name: "the stalled reviewer has been reminded",
+			reviewRequests: map[string]string{
+				"collab1": "2021-05-31T00:00:00Z",
+			},
+			issueComments: []github.IssueComment{
+				{
+					Body:      "<!--Blunderbuss Review Reminder: collab1-->",
+					User:      github.User{Login: "ti-chi-bot"},
+					CreatedAt: time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC),
+				},
+			},
+		},
+		{
+			name: "the reminder comment is not created by the bot",
+			reviewRequests: map[string]string{
+				"collab1": "2021-05-31T00:00:00Z",
+			},
+			issueComments: []github.IssueComment{
+				{
+					Body:      "<!--Blunderbuss Review Reminder: collab1-->",
+					User:      github.User{Login: "collab2"},
+					CreatedAt: time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC),
+				},
+			},
+			expectComments: []string{
+				"@collab1 this pull request has been waiting for your review for more than 24 hours, " +
+					"please take a look.\n\n<!--Blunderbuss Review Reminder: collab1-->",
+			},
+		},
+		{
+			name: "the review is requested again after the reminder",
+			reviewRequests: map[string]string{
+				"collab1": "2021-05-31T00:00:00Z",
+			},
+			issueComments: []github.IssueComment{
+				{
+					Body:      "<!--Blunderbuss Review Reminder: collab1,collab2-->",
+					User:      github.User{Login: "ti-chi-bot"},
+					CreatedAt: time.Date(2021, 5, 30, 0, 0, 0, 0, time.UTC),
+				},
+			},
+			expectComments: []string{
+				"@collab1 this pull request has been waiting for your review for more than 24 hours, " +
+					"please take a look.\n\n<!--Blunderbuss Review Reminder: collab1-->",
+			},
+		},
+		{
+			name:   "the threshold of the sig",
+			labels: []string{"sig/planner"},
+			reviewRequests: map[string]string{
+				"collab1": "2021-05-31T22:00:00Z",
+			},
+			expectComments: []string{
+				"@collab1 this pull request has been waiting for your review for more than 12 hours, " +
+					"please take a look.\n\n<!--Blunderbuss Review Reminder: collab1-->",
+			},
+		},
+		{
+			name: "escalate to the reviewers",
+			reviewRequests: map[string]string{
+				"collab1": "2021-05-28T00:00:00Z",
+			},
+			issueComments: []github.IssueComment{
+				{
+					Body:      "<!--Blunderbuss Review Reminder: collab1-->",
+					User:      github.User{Login: "ti-chi-bot"},
+					CreatedAt: time.Date(2021, 5, 29, 0, 0, 0, 0, time.UTC),
+				},
+			},
+			expectComments: []string{
+				"The review requested from @collab1 has been pending for more than 72 hours, " +
+					"requested reviews from the reviewers @collab2 and added the `needs-review-attention` label.",
+			},
+			expectRequested:   []string{"collab2"},
+			expectAddedLabels: []string{"needs-review-attention"},
+		},
+		{
+			name: "escalate to the committers",
+			reviewRequests: map[string]string{
+				"collab1": "2021-05-28T00:00:00Z",
+			},
+			issueComments: []github.IssueComment{
+				{
+					Body:      "<!--Blunderbuss Review Reminder: collab1-->",
+					User:      github.User{Login: "ti-chi-bot"},
+					CreatedAt: time.Date(2021, 5, 29, 0, 0, 0, 0, time.UTC),
+				},
+			},
+			escalateToCommitters: true,
+			expectComments: []string{
+				"The review requested from @collab1 has been pending for more than 72 hours, " +
+					"requested reviews from the committers @committer1 and added the `needs-review-attention` label.",
+			},
+			expectRequested:   []string{"committer1"},
+			expectAddedLabels: []string{"needs-review-attention"},
+		},
+		{
+			name: "escalate without the unavailable reviewers",
+			reviewRequests: map[string]string{
+				"collab1": "2021-05-28T00:00:00Z",
+			},
+			issueComments: []github.IssueComment{
+				{
+					Body:      "<!--Blunderbuss Review Reminder: collab1-->",
+					User:      github.User{Login: "ti-chi-bot"},
+					CreatedAt: time.Date(2021, 5, 29, 0, 0, 0, 0, time.UTC),
+				},
+			},
+			preferences: map[string]ownersclient.ReviewerPreferences{
+				"collab2": {OptOut: true},
+			},
+			expectComments: []string{
+				"The review requested from @collab1 has been pending for more than 72 hours, " +
+					"added the `needs-review-attention` label.",
+			},
+			expectAddedLabels: []string{"needs-review-attention"},
+		},
+		{
+			name: "escalate without other reviewers",
+			reviewRequests: map[string]string{
+				"collab1": "2021-05-28T00:00:00Z",
+				"collab2": "2021-05-28T00:00:00Z",
+			},
+			issueComments: []github.IssueComment{
+				{
+					Body:      "<!--Blunderbuss Review Reminder: collab1,collab2-->",
+					User:      github.User{Login: "ti-chi-bot"},
+					CreatedAt: time.Date(2021, 5, 29, 0, 0, 0, 0, time.UTC),
+				},
+			},
+			expectComments: []string{
+				"The review requested from @collab1 @collab2 has been pending for more than 72 hours, " +
+					"added the `needs-review-attention` label.",
+			},
+			expectAddedLabels: []string{"needs-review-attention"},
+		},
+		{
+			name:   "the review has been escalated",
+			labels: []string{"needs-review-attention"},
+			reviewRequests: map[string]string{
+				"collab1": "2021-05-28T00:00:00Z",
+			},
+			issueComments: []github.IssueComment{
+				{
+					Body:      "<!--Blunderbuss Review Reminder: collab1-->",
+					User:      github.User{Login: "ti-chi-bot"},
+					CreatedAt: time.Date(2021, 5, 29, 0, 0, 0, 0, time.UTC),
+				},
+			},
+		},
+		{
+			name: "quiet hours",
+			reviewRequests: map[string]string{
+				"collab1": "2021-05-28T00:00:00Z",
+			},
+			quietHours: &externalplugins.QuietHours{Start: 20, End: 4, TimeZone: "Asia/Shanghai"},
+		},
+		{
+			name:  "draft PR",
+			draft: true,
+			reviewRequests: map[string]string{
+				"collab1": "2021-05-28T00:00:00Z",
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			fc := newFakeGitHubClient(&github.PullRequest{Number: 5})
+			fc.openPRs = map[int][]string{5: tc.labels}
+			fc.draft = tc.draft
+			fc.reviewRequests = tc.reviewRequests
+			fc.issueComments = tc.issueComments
+			cfg := &externalplugins.Configuration{
+				TiCommunityBlunderbuss: []externalplugins.TiCommunityBlunderbuss{
+					{
+						Repos:              []string{"org/repo"},
+						MaxReviewerCount:   2,
+						ExcludeReviewers:   []string{"collab3"},
+						PullOwnersEndpoint: "https://fake/ti-community-bot",
+						ReviewReminder: &externalplugins.ReviewReminder{
+							RemindAfter:             24,
+							EscalateAfter:           72,
+							EscalateToCommitters:    tc.escalateToCommitters,
+							EscalationReviewerCount: 1,
+							AttentionLabel:          "needs-review-attention",
+							Sigs: []externalplugins.SigReviewReminder{
+								{Sig: "planner", RemindAfter: 12},
+							},
+							QuietHours: tc.quietHours,
+						},
+					},
+				},
+			}
+			foc := &fakeOwnersClient{
+				committers: []string{"author", "committer1"},
+				reviewers:  []string{"author", "collab1", "collab2", "collab3"},
+			}
+
+			if err := HandleAll(logrus.WithField("plugin", PluginName), fc, cfg, tc.preferences, foc); err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+			if !reflect.DeepEqual(fc.comments, tc.expectComments) {
+				t.Errorf("comments mismatch: got %q, want %q", fc.comments, tc.expectComments)
+			}
+			if !reflect.DeepEqual(fc.requested, tc.expectRequested) {
+				t.Errorf("requested reviewers mismatch: got %v, want %v", fc.requested, tc.expectRequested)
+			}
+			var addedLabels []string
+			for _, label := range fc.pr.Labels {
+				addedLabels = append(addedLabels, label.Name)
+			}
+			if !reflect.DeepEqual(addedLabels, tc.expectAddedLabels) {
+				t.Errorf("added labels mismatch: got %v, want %v", addedLabels, tc.expectAddedLabels)
+			}
+		})
+	}
+}
+
+func TestHandlePullRequestReviewEvent(t *testing.T) {
+	testcases := []struct {
+		name           string
+		action         github.ReviewEventAction
+		reviewer       string
+		labels         []string
+		reviewReminder *externalplugins.ReviewReminder
+
+		expectRemovedLabels []string
+	}{
+		{
+			name:                "review by the reviewer",
+			action:              github.ReviewActionSubmitted,
+			reviewer:            "collab1",
+			labels:              []string{"needs-review-attention"},
+			reviewReminder:      &externalplugins.ReviewReminder{AttentionLabel: "needs-review-attention"},
+			expectRemovedLabels: []string{"needs-review-attention"},
+		},
+		{
+			name:           "review by the author",
+			action:         github.ReviewActionSubmitted,
+			reviewer:       "author",
+			labels:         []string{"needs-review-attention"},
+			reviewReminder: &externalplugins.ReviewReminder{AttentionLabel: "needs-review-attention"},
+		},
+		{
+			name:           "dismissed review",
+			action:         github.ReviewActionDismissed,
+			reviewer:       "collab1",
+			labels:         []string{"needs-review-attention"},
+			reviewReminder: &externalplugins.ReviewReminder{AttentionLabel: "needs-review-attention"},
+		},
+		{
+			name:           "PR without the attention label",
+			action:         github.ReviewActionSubmitted,
+			reviewer:       "collab1",
+			reviewReminder: &externalplugins.ReviewReminder{AttentionLabel: "needs-review-attention"},
+		},
+		{
+			name:     "reminder is disabled",
+			action:   github.ReviewActionSubmitted,
+			reviewer: "collab1",
+			labels:   []string{"needs-review-attention"},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			fc := newFakeGitHubClient(&github.PullRequest{Number: 5})
+			e := &github.ReviewEvent{
+				Action: tc.action,
+				PullRequest: github.PullRequest{
+					Number: 5,
+					State:  "open",
+					User:   github.User{Login: "author"},
+					Labels: mapLabelNameToLabel(tc.labels),
+				},
+				Review: github.Review{User: github.User{Login: tc.reviewer}},
+				Repo:   github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+			}
+			cfg := &externalplugins.Configuration{
+				TiCommunityBlunderbuss: []externalplugins.TiCommunityBlunderbuss{
+					{
+						Repos:          []string{"org/repo"},
+						ReviewReminder: tc.reviewReminder,
+					},
+				},
+			}
+
+			if err := HandlePullRequestReviewEvent(fc, e, cfg, logrus.WithField("plugin", PluginName)); err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+			if !reflect.DeepEqual(fc.removedLabels, tc.expectRemovedLabels) {
+				t.Errorf("removed labels mismatch: got %v, want %v", fc.removedLabels, tc.expectRemovedLabels)
+			}
+		})
+	}
+}
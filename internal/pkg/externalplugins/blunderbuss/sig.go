@@ -0,0 +1,59 @@
+package blunderbuss
+
+import (
+	"sort"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/test-infra/prow/github"
+)
+
+// selectBySig picks the reviewers from the ordered candidates for each SIG of the PR in turn, so that every SIG
+// gets a reviewer before any SIG gets another one. The reviewers already requested are counted for their SIGs,
+// and they are also counted in the maximum number of the reviewers if it is greater than 0.
+func selectBySig(candidates []string, sigs map[string][]string, requested []string, sigReviewerCount int,
+	maxReviewerCount int, log *logrus.Entry) []string {
+	var sigNames []string
+	sigMembers := make(map[string]sets.String)
+	for sig, members := range sigs {
+		sigNames = append(sigNames, sig)
+		memberSet := sets.NewString()
+		for _, member := range members {
+			memberSet.Insert(github.NormLogin(member))
+		}
+		sigMembers[sig] = memberSet
+	}
+	sort.Strings(sigNames)
+
+	assigned := sets.NewString()
+	for _, reviewer := range requested {
+		assigned.Insert(github.NormLogin(reviewer))
+	}
+	remaining := maxReviewerCount - len(requested)
+
+	var result []string
+	for round := 1; round <= sigReviewerCount; round++ {
+		for _, sig := range sigNames {
+			if maxReviewerCount > 0 && remaining <= 0 {
+				log.Infof("Limiting request of reviewers to %d maxReviewers.", maxReviewerCount)
+				return result
+			}
+			members := sigMembers[sig]
+			if members.Intersection(assigned).Len() >= round {
+				continue
+			}
+			for _, candidate := range candidates {
+				login := github.NormLogin(candidate)
+				if assigned.Has(login) || !members.Has(login) {
+					continue
+				}
+				assigned.Insert(login)
+				result = append(result, candidate)
+				remaining--
+				log.Infof("Added %s as reviewers of sig %s. %d reviewers found.", candidate, sig, len(result))
+				break
+			}
+		}
+	}
+	return result
+}
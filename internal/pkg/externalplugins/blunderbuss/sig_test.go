@@ -0,0 +1,172 @@
+package blunderbuss
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/test-infra/prow/github"
+)
+
+func TestSelectBySig(t *testing.T) {
+	sigs := map[string][]string{
+		"planner":   {"p1", "p2", "shared"},
+		"execution": {"e1", "e2", "Shared"},
+		"docs":      {"d1"},
+	}
+	testcases := []struct {
+		name             string
+		candidates       []string
+		requested        []string
+		sigReviewerCount int
+		maxReviewerCount int
+
+		expectReviewers []string
+	}{
+		{
+			name:             "one reviewer for each sig",
+			candidates:       []string{"shared", "p1", "e1", "p2", "e2", "d1"},
+			sigReviewerCount: 1,
+			expectReviewers:  []string{"d1", "shared"},
+		},
+		{
+			name:             "two reviewers for each sig",
+			candidates:       []string{"shared", "p1", "e1", "p2", "e2", "d1"},
+			sigReviewerCount: 2,
+			expectReviewers:  []string{"d1", "shared", "e1", "p1"},
+		},
+		{
+			name:             "every sig gets a reviewer before the maximum count is reached",
+			candidates:       []string{"p1", "e1", "p2", "e2", "d1"},
+			sigReviewerCount: 2,
+			maxReviewerCount: 3,
+			expectReviewers:  []string{"d1", "e1", "p1"},
+		},
+		{
+			name:             "requested reviewers are counted for their sigs",
+			candidates:       []string{"shared", "p1", "e1", "p2", "e2", "d1"},
+			requested:        []string{"P1"},
+			sigReviewerCount: 1,
+			maxReviewerCount: 3,
+			expectReviewers:  []string{"d1", "shared"},
+		},
+		{
+			name:             "requested reviewers are counted in the maximum count",
+			candidates:       []string{"shared", "p1", "e1", "p2", "e2", "d1"},
+			requested:        []string{"p1", "e1"},
+			sigReviewerCount: 1,
+			maxReviewerCount: 2,
+		},
+		{
+			name:             "sig without candidates",
+			candidates:       []string{"p1", "e1"},
+			sigReviewerCount: 1,
+			expectReviewers:  []string{"e1", "p1"},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			reviewers := selectBySig(tc.candidates, sigs, tc.requested, tc.sigReviewerCount, tc.maxReviewerCount,
+				logrus.WithField("plugin", PluginName))
+			if !reflect.DeepEqual(reviewers, tc.expectReviewers) {
+				t.Errorf("reviewers mismatch: got %v, want %v", reviewers, tc.expectReviewers)
+			}
+		})
+	}
+}
+
+func TestHandlePullRequestEventWithSigReviewers(t *testing.T) {
+	testcases := []struct {
+		name               string
+		action             github.PullRequestEventAction
+		label              string
+		requestedReviewers []string
+		sigReviewerCount   int
+
+		expectRequestedFrom []string
+		expectRequestCount  int
+	}{
+		{
+			name:                "new sig label of the PR with requested reviewers",
+			action:              github.PullRequestActionLabeled,
+			label:               "sig/execution",
+			requestedReviewers:  []string{"p1"},
+			sigReviewerCount:    1,
+			expectRequestedFrom: []string{"e1", "e2"},
+			expectRequestCount:  1,
+		},
+		{
+			name:               "the sig of the new label has requested reviewers",
+			action:             github.PullRequestActionLabeled,
+			label:              "sig/execution",
+			requestedReviewers: []string{"p1", "e1"},
+			sigReviewerCount:   1,
+		},
+		{
+			name:               "reviewers are not requested per sig",
+			action:             github.PullRequestActionLabeled,
+			label:              "sig/execution",
+			requestedReviewers: []string{"p1"},
+		},
+		{
+			name:               "non sig label",
+			action:             github.PullRequestActionLabeled,
+			label:              "type/bug",
+			requestedReviewers: []string{"p1"},
+			sigReviewerCount:   1,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			pr := github.PullRequest{
+				Number:             5,
+				State:              "open",
+				User:               github.User{Login: "author"},
+				Body:               "/cc @p1",
+				RequestedReviewers: mapGithubLoginToGithubUser(tc.requestedReviewers),
+			}
+			fc := newFakeGitHubClient(&pr)
+			e := &github.PullRequestEvent{
+				Action:      tc.action,
+				Number:      5,
+				PullRequest: pr,
+				Label:       github.Label{Name: tc.label},
+				Repo:        github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+			}
+			cfg := &externalplugins.Configuration{
+				TiCommunityBlunderbuss: []externalplugins.TiCommunityBlunderbuss{
+					{
+						Repos:              []string{"org/repo"},
+						MaxReviewerCount:   3,
+						PullOwnersEndpoint: "https://fake/ti-community-bot",
+						SigReviewerCount:   tc.sigReviewerCount,
+					},
+				},
+			}
+			foc := &fakeOwnersClient{
+				reviewers: []string{"p1", "p2", "e1", "e2"},
+				sigs: map[string][]string{
+					"planner":   {"p1", "p2"},
+					"execution": {"e1", "e2"},
+				},
+			}
+
+			if err := HandlePullRequestEvent(fc, e, "", cfg, nil, foc, logrus.WithField("plugin", PluginName)); err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+			if len(fc.requested) != tc.expectRequestCount {
+				t.Fatalf("requested reviewers mismatch: got %v, want %d reviewers", fc.requested,
+					tc.expectRequestCount)
+			}
+			if !sets.NewString(tc.expectRequestedFrom...).HasAll(fc.requested...) {
+				t.Errorf("requested reviewers %v are not in %v", fc.requested, tc.expectRequestedFrom)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package externalplugins
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/test-infra/prow/github"
+)
+
+// Ref: https://docs.github.com/en/rest/reference/checks#runs.
+const (
+	// CheckRunStatusCompleted means the check run has finished.
+	CheckRunStatusCompleted = "completed"
+	// CheckRunConclusionSuccess means the check run succeeded.
+	CheckRunConclusionSuccess = "success"
+	// CheckRunConclusionNeutral means the check run finished without a success or failure result.
+	CheckRunConclusionNeutral = "neutral"
+)
+
+// ChecksClient is the GitHub client used to get the results of the checks.
+type ChecksClient interface {
+	GetCombinedStatus(org, repo, ref string) (*github.CombinedStatus, error)
+	ListCheckRuns(org, repo, ref string) (*github.CheckRunList, error)
+}
+
+// ChecksResult contains the required checks that have not passed.
+type ChecksResult struct {
+	Failed  []string
+	Pending []string
+}
+
+// Passed returns true if all the required checks have passed.
+func (r *ChecksResult) Passed() bool {
+	return len(r.Failed) == 0 && len(r.Pending) == 0
+}
+
+// IsCheckRunPassed returns true if the check run has completed with a success or neutral conclusion.
+func IsCheckRunPassed(run github.CheckRun) bool {
+	return run.Status == CheckRunStatusCompleted &&
+		(run.Conclusion == CheckRunConclusionSuccess || run.Conclusion == CheckRunConclusionNeutral)
+}
+
+// GetRequiredChecksResult checks the commit statuses and the check runs of the ref against the
+// required contexts, the contexts which have not been reported are pending.
+func GetRequiredChecksResult(gc ChecksClient, org, repo, ref string, contexts []string) (*ChecksResult, error) {
+	passed := sets.NewString()
+	failed := sets.NewString()
+
+	combinedStatus, err := gc.GetCombinedStatus(org, repo, ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the combined status of %s: %v", ref, err)
+	}
+	if combinedStatus != nil {
+		for _, status := range combinedStatus.Statuses {
+			switch status.State {
+			case github.StatusSuccess:
+				passed.Insert(status.Context)
+			case github.StatusFailure, github.StatusError:
+				failed.Insert(status.Context)
+			}
+		}
+	}
+
+	checkRuns, err := gc.ListCheckRuns(org, repo, ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the check runs of %s: %v", ref, err)
+	}
+	// Only the latest run of each check counts when the check is requested again.
+	latestRuns := map[string]github.CheckRun{}
+	for _, run := range checkRuns.CheckRuns {
+		if latest, ok := latestRuns[run.Name]; !ok || run.ID > latest.ID {
+			latestRuns[run.Name] = run
+		}
+	}
+	for name, run := range latestRuns {
+		if run.Status != CheckRunStatusCompleted {
+			continue
+		}
+		if IsCheckRunPassed(run) {
+			passed.Insert(name)
+		} else {
+			failed.Insert(name)
+		}
+	}
+
+	result := &ChecksResult{}
+	for _, context := range contexts {
+		if passed.Has(context) {
+			continue
+		}
+		if failed.Has(context) {
+			result.Failed = append(result.Failed, context)
+		} else {
+			result.Pending = append(result.Pending, context)
+		}
+	}
+	return result, nil
+}
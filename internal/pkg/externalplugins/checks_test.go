@@ -0,0 +1,111 @@
+package externalplugins
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/test-infra/prow/github"
+)
+
+const headSHA = "abc123"
+
+type fakeChecksClient struct {
+	CombinedStatuses map[string]*github.CombinedStatus
+	CheckRuns        map[string]*github.CheckRunList
+}
+
+func (f *fakeChecksClient) GetCombinedStatus(_, _, ref string) (*github.CombinedStatus, error) {
+	return f.CombinedStatuses[ref], nil
+}
+
+func (f *fakeChecksClient) ListCheckRuns(_, _, ref string) (*github.CheckRunList, error) {
+	if runs, ok := f.CheckRuns[ref]; ok {
+		return runs, nil
+	}
+	return &github.CheckRunList{}, nil
+}
+
+func TestGetRequiredChecksResult(t *testing.T) {
+	testcases := []struct {
+		name      string
+		statuses  []github.Status
+		checkRuns []github.CheckRun
+		contexts  []string
+
+		expectFailed  []string
+		expectPending []string
+	}{
+		{
+			name: "All required checks passed",
+			statuses: []github.Status{
+				{Context: "ci/build", State: github.StatusSuccess},
+			},
+			checkRuns: []github.CheckRun{
+				{ID: 1, Name: "unit-test", Status: CheckRunStatusCompleted, Conclusion: CheckRunConclusionSuccess},
+				{ID: 2, Name: "lint", Status: CheckRunStatusCompleted, Conclusion: CheckRunConclusionNeutral},
+			},
+			contexts: []string{"ci/build", "unit-test", "lint"},
+		},
+		{
+			name: "Failed and pending checks",
+			statuses: []github.Status{
+				{Context: "ci/build", State: github.StatusFailure},
+				{Context: "ci/integration", State: github.StatusPending},
+			},
+			checkRuns: []github.CheckRun{
+				{ID: 1, Name: "unit-test", Status: "in_progress"},
+				{ID: 2, Name: "lint", Status: CheckRunStatusCompleted, Conclusion: "failure"},
+			},
+			contexts:      []string{"ci/build", "ci/integration", "unit-test", "lint", "missing"},
+			expectFailed:  []string{"ci/build", "lint"},
+			expectPending: []string{"ci/integration", "unit-test", "missing"},
+		},
+		{
+			name: "Only the latest check run counts",
+			checkRuns: []github.CheckRun{
+				{ID: 2, Name: "unit-test", Status: CheckRunStatusCompleted, Conclusion: CheckRunConclusionSuccess},
+				{ID: 1, Name: "unit-test", Status: CheckRunStatusCompleted, Conclusion: "failure"},
+				{ID: 3, Name: "lint", Status: CheckRunStatusCompleted, Conclusion: CheckRunConclusionSuccess},
+				{ID: 4, Name: "lint", Status: "queued"},
+			},
+			contexts:      []string{"unit-test", "lint"},
+			expectPending: []string{"lint"},
+		},
+		{
+			name: "Checks which are not required are ignored",
+			statuses: []github.Status{
+				{Context: "ci/build", State: github.StatusSuccess},
+				{Context: "ci/optional", State: github.StatusFailure},
+			},
+			contexts: []string{"ci/build"},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			fc := &fakeChecksClient{
+				CombinedStatuses: map[string]*github.CombinedStatus{
+					headSHA: {SHA: headSHA, Statuses: tc.statuses},
+				},
+				CheckRuns: map[string]*github.CheckRunList{
+					headSHA: {Total: len(tc.checkRuns), CheckRuns: tc.checkRuns},
+				},
+			}
+
+			result, err := GetRequiredChecksResult(fc, "org", "repo", headSHA, tc.contexts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result.Failed, tc.expectFailed) {
+				t.Errorf("failed checks mismatch: got %v, want %v", result.Failed, tc.expectFailed)
+			}
+			if !reflect.DeepEqual(result.Pending, tc.expectPending) {
+				t.Errorf("pending checks mismatch: got %v, want %v", result.Pending, tc.expectPending)
+			}
+			if result.Passed() != (len(tc.expectFailed) == 0 && len(tc.expectPending) == 0) {
+				t.Errorf("unexpected passed result: %v", result.Passed())
+			}
+		})
+	}
+}
@@ -0,0 +1,471 @@
+package cherrypicker
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/test-infra/prow/config"
+	git "k8s.io/test-infra/prow/git/v2"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/pluginhelp"
+)
+
+const (
+	// PluginName is the name of this plugin.
+	PluginName = "ti-community-cherrypicker"
+	// cherryPickBranchFormat is the format of the branches which the cherry-picks are pushed to.
+	cherryPickBranchFormat = "cherry-pick-%d-to-%s"
+)
+
+var (
+	// cherryPickRe matches the `/cherry-pick <branch>` commands, a comment may contain several commands.
+	cherryPickRe = regexp.MustCompile(`(?mi)^/cherry-?pick\s+(\S+)\s*$`)
+)
+
+type githubClient interface {
+	AddLabel(org, repo string, number int, label string) error
+	AddLabels(org, repo string, number int, labels ...string) error
+	AssignIssue(org, repo string, number int, logins []string) error
+	BotUser() (*github.UserData, error)
+	BotUserChecker() (func(candidate string) bool, error)
+	CreateComment(org, repo string, number int, comment string) error
+	CreatePullRequest(org, repo, title, body, head, base string, canModify bool) (int, error)
+	CreateDraftPullRequest(org, repo, title, body, head, base string) (int, error)
+	GetPullRequest(org, repo string, number int) (*github.PullRequest, error)
+	GetPullRequests(org, repo string) ([]github.PullRequest, error)
+	IsMember(org, user string) (bool, error)
+	ListReviews(org, repo string, number int) ([]github.Review, error)
+	RequestReview(org, repo string, number int, logins []string) error
+}
+
+// HelpProvider constructs the PluginHelp for this plugin that takes into account enabled repositories.
+// HelpProvider defines the type for function that construct the PluginHelp for plugins.
+func HelpProvider(epa *externalplugins.ConfigAgent) func(
+	enabledRepos []config.OrgRepo) (*pluginhelp.PluginHelp, error) {
+	return func(enabledRepos []config.OrgRepo) (*pluginhelp.PluginHelp, error) {
+		configInfo := map[string]string{}
+		cfg := epa.Config()
+		for _, repo := range enabledRepos {
+			opts := cfg.CherrypickerFor(repo.Org, repo.Repo)
+			var configInfoStrings []string
+
+			configInfoStrings = append(configInfoStrings, "The plugin has these configurations:<ul>")
+			configInfoStrings = append(configInfoStrings,
+				"<li>The merged PRs with the labels prefixed with "+opts.LabelPrefix+
+					" are cherry-picked to the branches named by the rest of the labels.</li>")
+			if opts.AllowAll {
+				configInfoStrings = append(configInfoStrings, "<li>Everyone can use `/cherry-pick`.</li>")
+			}
+			if len(opts.ExcludeLabels) != 0 {
+				configInfoStrings = append(configInfoStrings,
+					"<li>These labels are not copied to the cherry-pick PRs: "+
+						strings.Join(opts.ExcludeLabels, ", ")+".</li>")
+			}
+			configInfoStrings = append(configInfoStrings, "</ul>")
+
+			configInfo[repo.String()] = strings.Join(configInfoStrings, "\n")
+		}
+		pluginHelp := &pluginhelp.PluginHelp{
+			Description: "The ti-community-cherrypicker plugin cherry-picks the merged PRs to the release branches " +
+				"and opens the cherry-pick PRs.",
+			Config: configInfo,
+		}
+
+		pluginHelp.AddCommand(pluginhelp.Command{
+			Usage: "/cherry-pick <branch>",
+			Description: "Cherry-pick the PR to the branch after it is merged, " +
+				"the PR is cherry-picked at once if it has been merged.",
+			Featured:  true,
+			WhoCanUse: "Members of the organization, or everyone if the repository allows it.",
+			Examples:  []string{"/cherry-pick release-5.0", "/cherrypick release-5.0"},
+		})
+
+		return pluginHelp, nil
+	}
+}
+
+// HandlePullRequestEvent cherry-picks the PR to the branches of its cherry-pick labels when it is merged,
+// or to the branch of the cherry-pick label added after it is merged.
+func HandlePullRequestEvent(log *logrus.Entry, gc githubClient, gitClient git.ClientFactory,
+	pe *github.PullRequestEvent, cfg *externalplugins.Configuration) error {
+	pr := &pe.PullRequest
+	if !pr.Merged {
+		return nil
+	}
+	org := pr.Base.Repo.Owner.Login
+	repo := pr.Base.Repo.Name
+	opts := cfg.CherrypickerFor(org, repo)
+
+	var branches []string
+	switch pe.Action {
+	case github.PullRequestActionClosed:
+		for _, label := range pr.Labels {
+			if strings.HasPrefix(label.Name, opts.LabelPrefix) {
+				branches = append(branches, strings.TrimPrefix(label.Name, opts.LabelPrefix))
+			}
+		}
+	case github.PullRequestActionLabeled:
+		if strings.HasPrefix(pe.Label.Name, opts.LabelPrefix) {
+			branches = append(branches, strings.TrimPrefix(pe.Label.Name, opts.LabelPrefix))
+		}
+	}
+
+	for _, branch := range branches {
+		if err := cherryPickAndReport(gc, gitClient, cfg, opts, pr, branch, log); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HandleIssueCommentEvent handles the `/cherry-pick <branch>` commands, the PR is cherry-picked at once if it has
+// been merged, otherwise the cherry-pick label is added so that it is cherry-picked after it is merged.
+func HandleIssueCommentEvent(log *logrus.Entry, gc githubClient, gitClient git.ClientFactory,
+	ice *github.IssueCommentEvent, cfg *externalplugins.Configuration) error {
+	if ice.Action != github.IssueCommentActionCreated || !ice.Issue.IsPullRequest() {
+		return nil
+	}
+	matches := cherryPickRe.FindAllStringSubmatch(ice.Comment.Body, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	org := ice.Repo.Owner.Login
+	repo := ice.Repo.Name
+	number := ice.Issue.Number
+	commentAuthor := ice.Comment.User.Login
+	opts := cfg.CherrypickerFor(org, repo)
+
+	if !opts.AllowAll {
+		member, err := gc.IsMember(org, commentAuthor)
+		if err != nil {
+			return err
+		}
+		if !member {
+			resp, err := cfg.RenderMessage(org, repo, externalplugins.MessageCherrypickerOnlyMembers,
+				map[string]interface{}{
+					"org": org,
+				})
+			if err != nil {
+				return err
+			}
+			log.Infof("Reply /cherry-pick request with comment: \"%s\"", resp)
+			return gc.CreateComment(org, repo, number,
+				cfg.FormatResponseRaw(org, repo, ice.Comment.Body, ice.Comment.HTMLURL, commentAuthor, resp))
+		}
+	}
+
+	pr, err := gc.GetPullRequest(org, repo, number)
+	if err != nil {
+		return err
+	}
+
+	branches := sets.NewString()
+	for _, match := range matches {
+		branch := match[1]
+		if branches.Has(branch) {
+			continue
+		}
+		branches.Insert(branch)
+
+		if pr.Merged {
+			if err := cherryPickAndReport(gc, gitClient, cfg, opts, pr, branch, log); err != nil {
+				return err
+			}
+			continue
+		}
+
+		label := opts.LabelPrefix + branch
+		if !github.HasLabel(label, pr.Labels) {
+			log.Infof("Adding '%s' label.", label)
+			if err := gc.AddLabel(org, repo, number, label); err != nil {
+				return err
+			}
+		}
+		resp, err := cfg.RenderMessage(org, repo, externalplugins.MessageCherrypickerScheduled,
+			map[string]interface{}{
+				"branch": branch,
+			})
+		if err != nil {
+			return err
+		}
+		log.Infof("Reply /cherry-pick request with comment: \"%s\"", resp)
+		err = gc.CreateComment(org, repo, number,
+			cfg.FormatResponseRaw(org, repo, ice.Comment.Body, ice.Comment.HTMLURL, commentAuthor, resp))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cherryPickAndReport cherry-picks the PR to the branch and reports the result on the PR.
+func cherryPickAndReport(gc githubClient, gitClient git.ClientFactory, cfg *externalplugins.Configuration,
+	opts *externalplugins.TiCommunityCherrypicker, pr *github.PullRequest, branch string, log *logrus.Entry) error {
+	org := pr.Base.Repo.Owner.Login
+	repo := pr.Base.Repo.Name
+	l := log.WithFields(logrus.Fields{"pr": pr.Number, "branch": branch})
+
+	var resp string
+	number, conflictFiles, err := cherryPick(gc, gitClient, cfg, opts, pr, branch, l)
+	if err != nil {
+		l.WithError(err).Error("Failed to cherry-pick.")
+		resp, err = cfg.RenderMessage(org, repo, externalplugins.MessageCherrypickerFailed,
+			map[string]interface{}{
+				"branch": branch,
+				"error":  err.Error(),
+			})
+	} else {
+		l.Infof("Opened the cherry-pick pull request %d.", number)
+		resp, err = cfg.RenderMessage(org, repo, externalplugins.MessageCherrypickerCreated,
+			map[string]interface{}{
+				"branch":        branch,
+				"number":        number,
+				"conflictFiles": conflictFiles,
+			})
+	}
+	if err != nil {
+		return err
+	}
+	return gc.CreateComment(org, repo, pr.Number, resp)
+}
+
+// cherryPick cherry-picks the merge commit of the PR onto a new branch based on the target branch and opens
+// the cherry-pick PR with the labels and reviewers of the PR. If the cherry-pick conflicts, the conflict markers
+// are committed and the cherry-pick PR is opened as a draft, the conflicting files are returned.
+func cherryPick(gc githubClient, gitClient git.ClientFactory, cfg *externalplugins.Configuration,
+	opts *externalplugins.TiCommunityCherrypicker, pr *github.PullRequest, branch string,
+	log *logrus.Entry) (int, []string, error) {
+	org := pr.Base.Repo.Owner.Login
+	repo := pr.Base.Repo.Name
+	if pr.MergeSHA == nil || *pr.MergeSHA == "" {
+		return 0, nil, fmt.Errorf("the merge commit of the pull request is unknown")
+	}
+	mergeSHA := *pr.MergeSHA
+
+	r, err := gitClient.ClientFor(org, repo)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to clone %s/%s: %v", org, repo, err)
+	}
+	defer func() {
+		if err := r.Clean(); err != nil {
+			log.WithError(err).Error("Failed to clean the git client.")
+		}
+	}()
+	if err := configGitUser(gc, r); err != nil {
+		return 0, nil, err
+	}
+
+	newBranch := fmt.Sprintf(cherryPickBranchFormat, pr.Number, branch)
+	if !r.BranchExists(branch) {
+		return 0, nil, fmt.Errorf("the branch %s does not exist", branch)
+	}
+	// The branch left by a cherry-pick which failed to open the pull request is overwritten.
+	branchExists := r.BranchExists(newBranch)
+	if branchExists {
+		existing, err := getOpenPullRequest(gc, org, repo, newBranch)
+		if err != nil {
+			return 0, nil, err
+		}
+		if existing != nil {
+			return 0, nil, fmt.Errorf("the branch %s already exists and is used by #%d", newBranch, existing.Number)
+		}
+		log.Infof("Overwriting the branch %s which is not used by any open pull request.", newBranch)
+	}
+	if err := r.Checkout("origin/" + branch); err != nil {
+		return 0, nil, err
+	}
+	if err := r.CheckoutNewBranch(newBranch); err != nil {
+		return 0, nil, err
+	}
+
+	dir := r.Directory()
+	conflictFiles, err := cherryPickCommit(dir, mergeSHA)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := r.PushToCentral(newBranch, branchExists); err != nil {
+		return 0, nil, err
+	}
+
+	title := fmt.Sprintf("%s (#%d)", pr.Title, pr.Number)
+	body, err := cfg.RenderMessage(org, repo, externalplugins.MessageCherrypickerPullRequestBody,
+		map[string]interface{}{
+			"number":        pr.Number,
+			"branch":        branch,
+			"conflictFiles": conflictFiles,
+			"body":          pr.Body,
+		})
+	if err != nil {
+		return 0, nil, err
+	}
+	var number int
+	if len(conflictFiles) != 0 {
+		number, err = gc.CreateDraftPullRequest(org, repo, title, body, newBranch, branch)
+	} else {
+		number, err = gc.CreatePullRequest(org, repo, title, body, newBranch, branch, true)
+	}
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to open the pull request: %v", err)
+	}
+
+	// The cherry-pick PR is opened, failing to copy the labels and reviewers does not fail the cherry-pick.
+	if labels := getCopiedLabels(cfg, opts, org, repo, pr.Labels); len(labels) != 0 {
+		if err := gc.AddLabels(org, repo, number, labels...); err != nil {
+			log.WithError(err).Error("Failed to add the labels to the cherry-pick pull request.")
+		}
+	}
+	if err := gc.AssignIssue(org, repo, number, []string{pr.User.Login}); err != nil {
+		log.WithError(err).Error("Failed to assign the cherry-pick pull request.")
+	}
+	reviewers, err := getReviewers(gc, pr)
+	if err != nil {
+		log.WithError(err).Error("Failed to get the reviewers.")
+	} else if len(reviewers) != 0 {
+		if err := gc.RequestReview(org, repo, number, reviewers); err != nil {
+			log.WithError(err).Error("Failed to request the reviewers of the cherry-pick pull request.")
+		}
+	}
+	return number, conflictFiles, nil
+}
+
+// getOpenPullRequest returns the open pull request whose head is the branch of the repo,
+// it returns nil if there is no such pull request.
+func getOpenPullRequest(gc githubClient, org, repo, branch string) (*github.PullRequest, error) {
+	prs, err := gc.GetPullRequests(org, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the pull requests: %v", err)
+	}
+	for i := range prs {
+		pr := prs[i]
+		if pr.State == "open" && pr.Head.Ref == branch &&
+			pr.Head.Repo.Owner.Login == org && pr.Head.Repo.Name == repo {
+			return &pr, nil
+		}
+	}
+	return nil, nil
+}
+
+// cherryPickCommit cherry-picks the commit onto the current branch, the first parent is used as the mainline
+// if the commit is a merge commit. If the cherry-pick conflicts, the conflict markers are committed and the
+// conflicting files are returned.
+func cherryPickCommit(dir, sha string) ([]string, error) {
+	out, err := runGit(dir, "rev-list", "--parents", "-n", "1", sha)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"cherry-pick", "-x"}
+	if len(strings.Fields(string(out))) > 2 {
+		args = append(args, "-m", "1")
+	}
+	args = append(args, sha)
+	if _, pickErr := runGit(dir, args...); pickErr == nil {
+		return nil, nil
+	}
+
+	out, err = runGit(dir, "diff", "--name-only", "--diff-filter=U")
+	if err != nil {
+		return nil, err
+	}
+	conflictFiles := strings.Fields(string(out))
+	if len(conflictFiles) == 0 {
+		// The cherry-pick fails without conflicts, for example, the changes have been applied to the branch.
+		return nil, fmt.Errorf("failed to cherry-pick %s, the changes may already exist on the branch", sha)
+	}
+	if _, err := runGit(dir, "add", "--all"); err != nil {
+		return nil, err
+	}
+	if _, err := runGit(dir, "commit", "--no-edit"); err != nil {
+		return nil, err
+	}
+	return conflictFiles, nil
+}
+
+// configGitUser configures the bot as the committer of the cherry-pick commits.
+func configGitUser(gc githubClient, r git.RepoClient) error {
+	botUser, err := gc.BotUser()
+	if err != nil {
+		return fmt.Errorf("failed to get the bot user: %v", err)
+	}
+	name := botUser.Name
+	if name == "" {
+		name = botUser.Login
+	}
+	email := botUser.Email
+	if email == "" {
+		email = fmt.Sprintf("%s@users.noreply.github.com", botUser.Login)
+	}
+	if err := r.Config("user.name", name); err != nil {
+		return err
+	}
+	return r.Config("user.email", email)
+}
+
+// getCopiedLabels returns the labels of the PR which are copied to the cherry-pick PR, the labels about the
+// approvals and the cherry-picks of the PR are not copied.
+func getCopiedLabels(cfg *externalplugins.Configuration, opts *externalplugins.TiCommunityCherrypicker,
+	org, repo string, labels []github.Label) []string {
+	labelScheme := cfg.LabelSchemeFor(org, repo)
+	excluded := sets.NewString(opts.ExcludeLabels...)
+	excluded.Insert(labelScheme.CanMergeLabel)
+	excluded.Insert(labelScheme.GetLgtmLabels(labels)...)
+
+	var copied []string
+	for _, label := range labels {
+		if excluded.Has(label.Name) || strings.HasPrefix(label.Name, opts.LabelPrefix) {
+			continue
+		}
+		copied = append(copied, label.Name)
+	}
+	return copied
+}
+
+// getReviewers returns the requested reviewers and the users who have reviewed the PR, except the PR author
+// and the bot.
+func getReviewers(gc githubClient, pr *github.PullRequest) ([]string, error) {
+	org := pr.Base.Repo.Owner.Login
+	repo := pr.Base.Repo.Name
+	botUserChecker, err := gc.BotUserChecker()
+	if err != nil {
+		return nil, err
+	}
+	reviews, err := gc.ListReviews(org, repo, pr.Number)
+	if err != nil {
+		return nil, err
+	}
+
+	reviewers := sets.NewString()
+	for _, reviewer := range pr.RequestedReviewers {
+		reviewers.Insert(reviewer.Login)
+	}
+	for _, review := range reviews {
+		reviewers.Insert(review.User.Login)
+	}
+	var result []string
+	for _, reviewer := range reviewers.List() {
+		if reviewer == pr.User.Login || botUserChecker(reviewer) {
+			continue
+		}
+		result = append(result, reviewer)
+	}
+	return result, nil
+}
+
+// runGit runs the git command in the dir, the interactor of the git client does not support cherry-picks.
+func runGit(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s failed: %v, %s", strings.Join(args, " "), err, stderr.String())
+	}
+	return out, nil
+}
@@ -0,0 +1,552 @@
+package cherrypicker
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"k8s.io/test-infra/prow/git/localgit"
+	git "k8s.io/test-infra/prow/git/v2"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+)
+
+const (
+	testOrg       = "org"
+	testRepo      = "repo"
+	testNumber    = 101
+	releaseBranch = "release-5.0"
+)
+
+// createdPullRequest records the PR opened by the plugin.
+type createdPullRequest struct {
+	title string
+	body  string
+	head  string
+	base  string
+	draft bool
+}
+
+type fakeGitHubClient struct {
+	*fakegithub.FakeClient
+	created   []createdPullRequest
+	requested map[int][]string
+	// createErr is returned when opening a pull request if it is not nil.
+	createErr error
+}
+
+func (f *fakeGitHubClient) CreatePullRequest(org, repo, title, body, head, base string,
+	canModify bool) (int, error) {
+	if f.createErr != nil {
+		return 0, f.createErr
+	}
+	f.created = append(f.created, createdPullRequest{title: title, body: body, head: head, base: base})
+	return f.createPullRequest(org, repo, title, body, head, base)
+}
+
+func (f *fakeGitHubClient) CreateDraftPullRequest(org, repo, title, body, head, base string) (int, error) {
+	if f.createErr != nil {
+		return 0, f.createErr
+	}
+	f.created = append(f.created, createdPullRequest{title: title, body: body, head: head, base: base, draft: true})
+	return f.createPullRequest(org, repo, title, body, head, base)
+}
+
+// createPullRequest opens the pull request in the fake client and records its head.
+func (f *fakeGitHubClient) createPullRequest(org, repo, title, body, head, base string) (int, error) {
+	number, err := f.FakeClient.CreatePullRequest(org, repo, title, body, head, base, true)
+	if err != nil {
+		return 0, err
+	}
+	pr := f.PullRequests[number]
+	pr.State = "open"
+	pr.Head = github.PullRequestBranch{
+		Ref:  head,
+		Repo: github.Repo{Owner: github.User{Login: org}, Name: repo},
+	}
+	return number, nil
+}
+
+// GetPullRequests returns the open pull requests.
+func (f *fakeGitHubClient) GetPullRequests(_, _ string) ([]github.PullRequest, error) {
+	var prs []github.PullRequest
+	for _, pr := range f.PullRequests {
+		if pr.State == "open" {
+			prs = append(prs, *pr)
+		}
+	}
+	return prs, nil
+}
+
+func (f *fakeGitHubClient) RequestReview(org, repo string, number int, logins []string) error {
+	if f.requested == nil {
+		f.requested = map[int][]string{}
+	}
+	f.requested[number] = append(f.requested[number], logins...)
+	return nil
+}
+
+// fakeRepo is a local bare repo with the branches master and release-5.0, the PR is merged into master.
+type fakeRepo struct {
+	t   *testing.T
+	lg  *localgit.LocalGit
+	dir string
+}
+
+// newFakeRepo creates the repo where the PR changing the file based on the release branch is merged.
+// If conflict is true, the release branch changes the same line of the file.
+func newFakeRepo(t *testing.T, squash, conflict bool) (*fakeRepo, string, git.ClientFactory) {
+	lg, gitClient, err := localgit.NewV2()
+	if err != nil {
+		t.Fatalf("failed to create local git: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := lg.Clean(); err != nil {
+			t.Errorf("failed to clean local git: %v", err)
+		}
+		if err := gitClient.Clean(); err != nil {
+			t.Errorf("failed to clean git client: %v", err)
+		}
+	})
+	if err := lg.MakeFakeRepo(testOrg, testRepo); err != nil {
+		t.Fatalf("failed to make fake repo: %v", err)
+	}
+	r := &fakeRepo{t: t, lg: lg, dir: filepath.Join(lg.Dir, testOrg, testRepo)}
+
+	r.commit("file", "1\n2\n3\n")
+	r.git("branch", releaseBranch)
+	if conflict {
+		r.git("checkout", releaseBranch)
+		r.commit("file", "1\nrelease\n3\n")
+		r.git("checkout", "master")
+	}
+	r.git("checkout", "-b", "pr")
+	r.commit("file", "1\nfix\n3\n")
+	r.git("checkout", "master")
+	if squash {
+		r.git("merge", "--squash", "pr")
+		r.git("commit", "-m", "fix")
+	} else {
+		r.git("merge", "--no-ff", "-m", "Merge pull request", "pr")
+	}
+	mergeSHA := r.git("rev-parse", "HEAD")
+
+	// The remote of the git client is a bare repo like the repo on GitHub.
+	bareDir := r.dir + ".git"
+	r.git("clone", "--bare", r.dir, bareDir)
+	if err := os.RemoveAll(r.dir); err != nil {
+		t.Fatalf("failed to remove the repo: %v", err)
+	}
+	if err := os.Rename(bareDir, r.dir); err != nil {
+		t.Fatalf("failed to move the bare repo: %v", err)
+	}
+	return r, mergeSHA, gitClient
+}
+
+func (r *fakeRepo) git(args ...string) string {
+	cmd := exec.Command(r.lg.Git, args...)
+	cmd.Dir = r.dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("git %v failed: %v, %s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func (r *fakeRepo) commit(file, content string) {
+	if err := r.lg.AddCommit(testOrg, testRepo, map[string][]byte{file: []byte(content)}); err != nil {
+		r.t.Fatalf("failed to add commit: %v", err)
+	}
+}
+
+func newPullRequest(mergeSHA string, labels ...string) github.PullRequest {
+	pr := github.PullRequest{
+		Number:   testNumber,
+		Title:    "Fix the bug",
+		Body:     "The description.",
+		User:     github.User{Login: "author"},
+		Merged:   true,
+		MergeSHA: &mergeSHA,
+		Base: github.PullRequestBranch{
+			Ref:  "master",
+			Repo: github.Repo{Owner: github.User{Login: testOrg}, Name: testRepo},
+		},
+		RequestedReviewers: []github.User{{Login: "reviewer2"}},
+	}
+	for _, label := range labels {
+		pr.Labels = append(pr.Labels, github.Label{Name: label})
+	}
+	return pr
+}
+
+func newFakeGitHubClient() *fakeGitHubClient {
+	fc := fakegithub.NewFakeClient()
+	fc.OrgMembers[testOrg] = []string{"member"}
+	fc.Reviews[testNumber] = []github.Review{
+		{User: github.User{Login: "reviewer1"}, State: github.ReviewStateApproved},
+		{User: github.User{Login: "author"}, State: github.ReviewStateCommented},
+		{User: github.User{Login: fakegithub.Bot}, State: github.ReviewStateCommented},
+	}
+	return &fakeGitHubClient{FakeClient: fc}
+}
+
+func TestHandlePullRequestEvent(t *testing.T) {
+	canMergeLabel := externalplugins.CanMergeLabel
+	lgtmLabel := fmt.Sprintf("%s%d", externalplugins.LgtmLabelPrefix, 2)
+	cherryPickLabel := "needs-cherry-pick-" + releaseBranch
+
+	testcases := []struct {
+		name     string
+		action   github.PullRequestEventAction
+		label    string
+		merged   bool
+		squash   bool
+		conflict bool
+		labels   []string
+
+		expectCreated       bool
+		expectDraft         bool
+		expectContent       string
+		expectComment       string
+		expectBodyContains  []string
+		expectLabelsCopied  []string
+		expectFailedComment string
+	}{
+		{
+			name:   "Merged by a merge commit",
+			action: github.PullRequestActionClosed,
+			merged: true,
+			labels: []string{cherryPickLabel, "sig/planner", "type/bug", canMergeLabel, lgtmLabel},
+
+			expectCreated:      true,
+			expectContent:      "1\nfix\n3\n",
+			expectComment:      "The cherry-pick to `release-5.0` is opened in #0.",
+			expectBodyContains: []string{"This is an automated cherry-pick of #101", "The description."},
+			expectLabelsCopied: []string{"sig/planner", "type/bug"},
+		},
+		{
+			name:   "Squashed",
+			action: github.PullRequestActionClosed,
+			merged: true,
+			squash: true,
+			labels: []string{cherryPickLabel},
+
+			expectCreated: true,
+			expectContent: "1\nfix\n3\n",
+			expectComment: "The cherry-pick to `release-5.0` is opened in #0.",
+		},
+		{
+			name:     "Conflicts",
+			action:   github.PullRequestActionClosed,
+			merged:   true,
+			conflict: true,
+			labels:   []string{cherryPickLabel, "sig/planner"},
+
+			expectCreated:      true,
+			expectDraft:        true,
+			expectContent:      "1\n<<<<<<<",
+			expectComment:      "the draft pull request #0 is opened with the conflicts",
+			expectBodyContains: []string{"The cherry-pick conflicts", "- `file`"},
+			expectLabelsCopied: []string{"sig/planner"},
+		},
+		{
+			name:   "Label is added after merged",
+			action: github.PullRequestActionLabeled,
+			label:  cherryPickLabel,
+			merged: true,
+			labels: []string{cherryPickLabel},
+
+			expectCreated: true,
+			expectContent: "1\nfix\n3\n",
+			expectComment: "The cherry-pick to `release-5.0` is opened in #0.",
+		},
+		{
+			name:   "Other label is added after merged",
+			action: github.PullRequestActionLabeled,
+			label:  "type/bug",
+			merged: true,
+			labels: []string{cherryPickLabel, "type/bug"},
+		},
+		{
+			name:   "Closed without merged",
+			action: github.PullRequestActionClosed,
+			labels: []string{cherryPickLabel},
+		},
+		{
+			name:   "Branch does not exist",
+			action: github.PullRequestActionClosed,
+			merged: true,
+			labels: []string{"needs-cherry-pick-release-9.9"},
+
+			expectFailedComment: "Failed to cherry-pick this pull request to `release-9.9`: " +
+				"the branch release-9.9 does not exist",
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			r, mergeSHA, gitClient := newFakeRepo(t, tc.squash, tc.conflict)
+			fc := newFakeGitHubClient()
+			pr := newPullRequest(mergeSHA, tc.labels...)
+			pr.Merged = tc.merged
+			e := &github.PullRequestEvent{
+				Action:      tc.action,
+				Number:      testNumber,
+				Label:       github.Label{Name: tc.label},
+				PullRequest: pr,
+			}
+			cfg := &externalplugins.Configuration{}
+			cfg.TiCommunityCherrypicker = []externalplugins.TiCommunityCherrypicker{
+				{
+					Repos: []string{"org/repo"},
+				},
+			}
+
+			err := HandlePullRequestEvent(logrus.WithField("plugin", PluginName), fc, gitClient, e, cfg)
+			if err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+
+			if tc.expectFailedComment != "" {
+				if len(fc.created) != 0 {
+					t.Errorf("unexpected pull requests: %v", fc.created)
+				}
+				expect := []string{"org/repo#101:" + tc.expectFailedComment}
+				if !reflect.DeepEqual(fc.IssueCommentsAdded, expect) {
+					t.Errorf("comments mismatch: got %v, want %v", fc.IssueCommentsAdded, expect)
+				}
+				return
+			}
+			if !tc.expectCreated {
+				if len(fc.created) != 0 || len(fc.IssueCommentsAdded) != 0 {
+					t.Errorf("unexpected pull requests %v or comments %v", fc.created, fc.IssueCommentsAdded)
+				}
+				return
+			}
+
+			if len(fc.created) != 1 {
+				t.Fatalf("expected one pull request, got %v", fc.created)
+			}
+			created := fc.created[0]
+			expectHead := "cherry-pick-101-to-release-5.0"
+			if created.head != expectHead || created.base != releaseBranch || created.draft != tc.expectDraft {
+				t.Errorf("pull request mismatch: got %+v", created)
+			}
+			if created.title != "Fix the bug (#101)" {
+				t.Errorf("title mismatch: got %q", created.title)
+			}
+			for _, expect := range tc.expectBodyContains {
+				if !strings.Contains(created.body, expect) {
+					t.Errorf("body %q does not contain %q", created.body, expect)
+				}
+			}
+
+			content := r.git("show", expectHead+":file")
+			if !strings.HasPrefix(content+"\n", tc.expectContent) {
+				t.Errorf("content of the cherry-pick mismatch: got %q, want %q", content, tc.expectContent)
+			}
+			if base := r.git("rev-parse", expectHead+"~1"); base != r.git("rev-parse", releaseBranch) {
+				t.Errorf("the cherry-pick is not based on %s", releaseBranch)
+			}
+
+			var expectLabels []string
+			for _, label := range tc.expectLabelsCopied {
+				expectLabels = append(expectLabels, "org/repo#0:"+label)
+			}
+			if !reflect.DeepEqual(fc.IssueLabelsAdded, expectLabels) {
+				t.Errorf("labels mismatch: got %v, want %v", fc.IssueLabelsAdded, expectLabels)
+			}
+			expectReviewers := []string{"reviewer1", "reviewer2"}
+			if !reflect.DeepEqual(fc.requested[0], expectReviewers) {
+				t.Errorf("reviewers mismatch: got %v, want %v", fc.requested[0], expectReviewers)
+			}
+			if !reflect.DeepEqual(fc.AssigneesAdded, []string{"org/repo#0:author"}) {
+				t.Errorf("assignees mismatch: got %v", fc.AssigneesAdded)
+			}
+			if len(fc.IssueCommentsAdded) != 1 || !strings.Contains(fc.IssueCommentsAdded[0], tc.expectComment) {
+				t.Errorf("comments mismatch: got %v, want %q", fc.IssueCommentsAdded, tc.expectComment)
+			}
+		})
+	}
+}
+
+func TestHandleIssueCommentEvent(t *testing.T) {
+	cherryPickLabel := "needs-cherry-pick-" + releaseBranch
+
+	testcases := []struct {
+		name     string
+		body     string
+		author   string
+		merged   bool
+		allowAll bool
+		labels   []string
+
+		expectCreated      bool
+		expectLabelsAdded  []string
+		expectCommentParts []string
+	}{
+		{
+			name:   "Member cherry-picks the merged PR",
+			body:   "/cherry-pick release-5.0",
+			author: "member",
+			merged: true,
+
+			expectCreated:      true,
+			expectCommentParts: []string{"The cherry-pick to `release-5.0` is opened in #0."},
+		},
+		{
+			name:   "Member cherry-picks the open PR",
+			body:   "/cherrypick release-5.0",
+			author: "member",
+
+			expectLabelsAdded: []string{"org/repo#101:" + cherryPickLabel},
+			expectCommentParts: []string{
+				"This pull request will be cherry-picked to `release-5.0` after it is merged.",
+			},
+		},
+		{
+			name:   "Open PR already has the label",
+			body:   "/cherry-pick release-5.0",
+			author: "member",
+			labels: []string{cherryPickLabel},
+
+			expectCommentParts: []string{
+				"This pull request will be cherry-picked to `release-5.0` after it is merged.",
+			},
+		},
+		{
+			name:   "Non-member cannot cherry-pick",
+			body:   "/cherry-pick release-5.0",
+			author: "someone",
+			merged: true,
+
+			expectCommentParts: []string{"`/cherry-pick` is only allowed for the members of org."},
+		},
+		{
+			name:     "Everyone can cherry-pick",
+			body:     "/cherry-pick release-5.0",
+			author:   "someone",
+			merged:   true,
+			allowAll: true,
+
+			expectCreated:      true,
+			expectCommentParts: []string{"The cherry-pick to `release-5.0` is opened in #0."},
+		},
+		{
+			name:   "Not a command",
+			body:   "please /cherry-pick release-5.0",
+			author: "member",
+			merged: true,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			_, mergeSHA, gitClient := newFakeRepo(t, false, false)
+			fc := newFakeGitHubClient()
+			pr := newPullRequest(mergeSHA, tc.labels...)
+			pr.Merged = tc.merged
+			fc.PullRequests[testNumber] = &pr
+
+			e := &github.IssueCommentEvent{
+				Action: github.IssueCommentActionCreated,
+				Issue: github.Issue{
+					Number:      testNumber,
+					PullRequest: &struct{}{},
+				},
+				Comment: github.IssueComment{
+					Body: tc.body,
+					User: github.User{Login: tc.author},
+				},
+				Repo: github.Repo{Owner: github.User{Login: testOrg}, Name: testRepo},
+			}
+			cfg := &externalplugins.Configuration{}
+			cfg.TiCommunityCherrypicker = []externalplugins.TiCommunityCherrypicker{
+				{
+					Repos:    []string{"org/repo"},
+					AllowAll: tc.allowAll,
+				},
+			}
+
+			err := HandleIssueCommentEvent(logrus.WithField("plugin", PluginName), fc, gitClient, e, cfg)
+			if err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+
+			if created := len(fc.created) == 1; created != tc.expectCreated {
+				t.Errorf("pull requests mismatch: got %v, expect created %v", fc.created, tc.expectCreated)
+			}
+			if !reflect.DeepEqual(fc.IssueLabelsAdded, tc.expectLabelsAdded) {
+				t.Errorf("labels mismatch: got %v, want %v", fc.IssueLabelsAdded, tc.expectLabelsAdded)
+			}
+			if len(tc.expectCommentParts) == 0 {
+				if len(fc.IssueCommentsAdded) != 0 {
+					t.Errorf("unexpected comments: %v", fc.IssueCommentsAdded)
+				}
+				return
+			}
+			if len(fc.IssueCommentsAdded) != 1 {
+				t.Fatalf("expected one comment, got %v", fc.IssueCommentsAdded)
+			}
+			for _, part := range tc.expectCommentParts {
+				if !strings.Contains(fc.IssueCommentsAdded[0], part) {
+					t.Errorf("comment %q does not contain %q", fc.IssueCommentsAdded[0], part)
+				}
+			}
+		})
+	}
+}
+
+func TestCherryPickTwice(t *testing.T) {
+	_, mergeSHA, gitClient := newFakeRepo(t, false, false)
+	fc := newFakeGitHubClient()
+	pr := newPullRequest(mergeSHA)
+	cfg := &externalplugins.Configuration{}
+	opts := cfg.CherrypickerFor(testOrg, testRepo)
+	log := logrus.WithField("plugin", PluginName)
+
+	if _, _, err := cherryPick(fc, gitClient, cfg, opts, &pr, releaseBranch, log); err != nil {
+		t.Fatalf("didn't expect error: %v", err)
+	}
+	_, _, err := cherryPick(fc, gitClient, cfg, opts, &pr, releaseBranch, log)
+	if err == nil || !strings.Contains(err.Error(), "already exists and is used by #0") {
+		t.Errorf("expected the existing branch error, got %v", err)
+	}
+}
+
+func TestCherryPickRetry(t *testing.T) {
+	r, mergeSHA, gitClient := newFakeRepo(t, false, false)
+	fc := newFakeGitHubClient()
+	pr := newPullRequest(mergeSHA)
+	cfg := &externalplugins.Configuration{}
+	opts := cfg.CherrypickerFor(testOrg, testRepo)
+	log := logrus.WithField("plugin", PluginName)
+
+	// The branch is pushed but the pull request fails to be opened.
+	fc.createErr = fmt.Errorf("injected error")
+	if _, _, err := cherryPick(fc, gitClient, cfg, opts, &pr, releaseBranch, log); err == nil {
+		t.Fatalf("expected the error of opening the pull request")
+	}
+	newBranch := fmt.Sprintf(cherryPickBranchFormat, testNumber, releaseBranch)
+	// Point the left branch to an unrelated commit so that the retry has to overwrite it.
+	r.git("update-ref", "refs/heads/"+newBranch, "master")
+
+	fc.createErr = nil
+	if _, _, err := cherryPick(fc, gitClient, cfg, opts, &pr, releaseBranch, log); err != nil {
+		t.Fatalf("didn't expect error: %v", err)
+	}
+	if len(fc.created) != 1 || fc.created[0].head != newBranch {
+		t.Fatalf("expected the cherry-pick pull request from %s, got %v", newBranch, fc.created)
+	}
+	if content := r.git("show", newBranch+":file"); content != "1\nfix\n3" {
+		t.Errorf("content mismatch: got %q", content)
+	}
+}
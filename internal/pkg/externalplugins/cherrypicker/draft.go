@@ -0,0 +1,71 @@
+package cherrypicker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// DraftClient opens the draft PRs through the GitHub REST API, the prow GitHub client does not support
+// opening draft PRs.
+type DraftClient struct {
+	// Endpoint is the GitHub REST API endpoint.
+	Endpoint string
+	// Token returns the GitHub token.
+	Token func() []byte
+	// Client is the HTTP client used to send the requests.
+	Client *http.Client
+	// DryRun specifies that no PR is opened.
+	DryRun bool
+}
+
+// CreateDraftPullRequest opens a draft PR and returns its number.
+func (c *DraftClient) CreateDraftPullRequest(org, repo, title, body, head, base string) (int, error) {
+	if c.DryRun {
+		return 0, nil
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"title":                 title,
+		"body":                  body,
+		"head":                  head,
+		"base":                  base,
+		"draft":                 true,
+		"maintainer_can_modify": true,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	url := fmt.Sprintf("%s/repos/%s/%s/pulls", strings.TrimSuffix(c.Endpoint, "/"), org, repo)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "token "+strings.TrimSpace(string(c.Token())))
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return 0, fmt.Errorf("failed to open the draft pull request, status %d: %s", resp.StatusCode, respBody)
+	}
+
+	var pr struct {
+		Number int `json:"number"`
+	}
+	if err := json.Unmarshal(respBody, &pr); err != nil {
+		return 0, err
+	}
+	return pr.Number, nil
+}
@@ -0,0 +1,42 @@
+package cherrypicker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCreateDraftPullRequest(t *testing.T) {
+	var request map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/repos/org/repo/pulls" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "token abc" {
+			t.Errorf("unexpected authorization: %q", auth)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("failed to decode the request: %v", err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"number": 42}`))
+	}))
+	defer server.Close()
+
+	c := &DraftClient{
+		Endpoint: server.URL + "/",
+		Token:    func() []byte { return []byte("abc\n") },
+		Client:   server.Client(),
+	}
+	number, err := c.CreateDraftPullRequest("org", "repo", "title", "body", "head", "base")
+	if err != nil {
+		t.Fatalf("didn't expect error: %v", err)
+	}
+	if number != 42 {
+		t.Errorf("number mismatch: got %d, want 42", number)
+	}
+	if request["draft"] != true || request["head"] != "head" || request["base"] != "base" {
+		t.Errorf("unexpected request: %v", request)
+	}
+}
@@ -0,0 +1,169 @@
+package externalplugins
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/test-infra/prow/github"
+)
+
+var (
+	// htmlCommentRe matches the HTML comments in the PR body, such as the hints of the PR template.
+	htmlCommentRe = regexp.MustCompile(`(?s)<!--.*?-->`)
+	// sectionHeadingRe matches the markdown headings which split the PR body into sections.
+	sectionHeadingRe = regexp.MustCompile(`^#{1,6}\s+(.*?)\s*#*\s*$`)
+	// coAuthoredByRe matches the co-author trailers in the commit messages.
+	coAuthoredByRe = regexp.MustCompile(`(?mi)^co-authored-by:\s*(.+?\s*<[^>]+>)\s*$`)
+)
+
+// CommitMessageTemplate specifies the templates of the commit title and the commit message.
+//
+// The templates are rendered with CommitMessageData, for example:
+//
+//	title: "{{ .Title }} (#{{ .Number }})"
+//	body: "{{ index .Sections \"Release note\" }}"
+type CommitMessageTemplate struct {
+	// Title is the template of the commit title.
+	Title string `json:"title,omitempty"`
+	// Body is the template of the commit message, the Co-authored-by trailers are appended to it.
+	Body string `json:"body,omitempty"`
+}
+
+// CommitMessageData is the data used to render the CommitMessageTemplate.
+type CommitMessageData struct {
+	// Number is the number of the PR.
+	Number int
+	// Title is the title of the PR.
+	Title string
+	// Body is the body of the PR without the HTML comments.
+	Body string
+	// Author is the login of the PR author.
+	Author string
+	// Sections contains the content of the PR body under each markdown heading, the key is the heading text.
+	Sections map[string]string
+	// CoAuthors contains the authors of the commits other than the PR author, in the form of `name <email>`.
+	CoAuthors []string
+}
+
+// NewCommitMessageData collects the data of the commit message from the PR and its commits.
+func NewCommitMessageData(pr *github.PullRequest, commits []github.RepositoryCommit) *CommitMessageData {
+	body := strings.TrimSpace(htmlCommentRe.ReplaceAllString(pr.Body, ""))
+	return &CommitMessageData{
+		Number:    pr.Number,
+		Title:     pr.Title,
+		Body:      body,
+		Author:    pr.User.Login,
+		Sections:  parseSections(body),
+		CoAuthors: collectCoAuthors(pr.User.Login, commits),
+	}
+}
+
+// parseSections splits the markdown into sections by headings.
+func parseSections(body string) map[string]string {
+	sections := map[string]string{}
+	var heading string
+	var lines []string
+	flush := func() {
+		if heading != "" {
+			sections[heading] = strings.TrimSpace(strings.Join(lines, "\n"))
+		}
+	}
+	for _, line := range strings.Split(body, "\n") {
+		if m := sectionHeadingRe.FindStringSubmatch(strings.TrimRight(line, "\r")); m != nil {
+			flush()
+			heading = m[1]
+			lines = nil
+			continue
+		}
+		lines = append(lines, line)
+	}
+	flush()
+	return sections
+}
+
+// collectCoAuthors returns the authors of the commits and the co-authors in the commit messages,
+// the PR author and the duplicate emails are excluded.
+func collectCoAuthors(author string, commits []github.RepositoryCommit) []string {
+	var coAuthors []string
+	seen := sets.NewString()
+	add := func(name, email string) {
+		key := strings.ToLower(email)
+		if email == "" || seen.Has(key) {
+			return
+		}
+		seen.Insert(key)
+		coAuthors = append(coAuthors, fmt.Sprintf("%s <%s>", name, email))
+	}
+
+	for _, commit := range commits {
+		commitAuthor := commit.Commit.Author
+		if commit.Author.Login == author {
+			// Skip the PR author but keep the other emails of the author from being added as co-authors.
+			seen.Insert(strings.ToLower(commitAuthor.Email))
+		} else {
+			add(commitAuthor.Name, commitAuthor.Email)
+		}
+	}
+	for _, commit := range commits {
+		for _, m := range coAuthoredByRe.FindAllStringSubmatch(commit.Commit.Message, -1) {
+			index := strings.LastIndex(m[1], "<")
+			add(strings.TrimSpace(m[1][:index]), strings.Trim(m[1][index:], "<>"))
+		}
+	}
+	return coAuthors
+}
+
+// Render renders the commit title and the commit message, the Co-authored-by trailers are appended
+// to the commit message.
+func (t *CommitMessageTemplate) Render(data *CommitMessageData) (string, string, error) {
+	title, err := renderCommitTemplate("title", t.Title, data)
+	if err != nil {
+		return "", "", err
+	}
+	body, err := renderCommitTemplate("body", t.Body, data)
+	if err != nil {
+		return "", "", err
+	}
+
+	var trailers []string
+	for _, coAuthor := range data.CoAuthors {
+		trailer := "Co-authored-by: " + coAuthor
+		if !strings.Contains(body, trailer) {
+			trailers = append(trailers, trailer)
+		}
+	}
+	if len(trailers) != 0 {
+		if body != "" {
+			body += "\n\n"
+		}
+		body += strings.Join(trailers, "\n")
+	}
+	return strings.TrimSpace(title), body, nil
+}
+
+func renderCommitTemplate(name, text string, data *CommitMessageData) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the commit %s template: %v", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render the commit %s template: %v", name, err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// validate will return an error if the templates cannot be parsed.
+func (t *CommitMessageTemplate) validate() error {
+	if _, err := template.New("title").Parse(t.Title); err != nil {
+		return fmt.Errorf("invalid commit title template: %v", err)
+	}
+	if _, err := template.New("body").Parse(t.Body); err != nil {
+		return fmt.Errorf("invalid commit body template: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package externalplugins
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/test-infra/prow/github"
+)
+
+func newCommit(login, name, email, message string) github.RepositoryCommit {
+	return github.RepositoryCommit{
+		Author: github.User{Login: login},
+		Commit: github.GitCommit{
+			Author:  github.CommitAuthor{Name: name, Email: email},
+			Message: message,
+		},
+	}
+}
+
+func TestNewCommitMessageData(t *testing.T) {
+	pr := &github.PullRequest{
+		Number: 10,
+		Title:  "Fix the bug",
+		User:   github.User{Login: "author"},
+		Body: "<!-- Thank you for contributing! -->\n" +
+			"### What problem does this PR solve?\n\nThe bug.\n\n" +
+			"### Release note <!-- bugfixes only -->\n\n```release-note\nFix the bug.\n```\n",
+	}
+	commits := []github.RepositoryCommit{
+		newCommit("author", "Author", "author@example.com", "fix"),
+		newCommit("", "Author", "AUTHOR@example.com", "fix again"),
+		newCommit("helper", "Helper", "helper@example.com", "add tests\n\nCo-authored-by: Pair <pair@example.com>"),
+		newCommit("helper", "Helper", "helper@example.com", "address comments"),
+		newCommit("", "Anonymous", "", "no email"),
+	}
+
+	data := NewCommitMessageData(pr, commits)
+
+	expectSections := map[string]string{
+		"What problem does this PR solve?": "The bug.",
+		"Release note":                     "```release-note\nFix the bug.\n```",
+	}
+	if !reflect.DeepEqual(data.Sections, expectSections) {
+		t.Errorf("sections mismatch: got %q, want %q", data.Sections, expectSections)
+	}
+	expectCoAuthors := []string{"Helper <helper@example.com>", "Pair <pair@example.com>"}
+	if !reflect.DeepEqual(data.CoAuthors, expectCoAuthors) {
+		t.Errorf("co-authors mismatch: got %v, want %v", data.CoAuthors, expectCoAuthors)
+	}
+	if data.Number != 10 || data.Title != "Fix the bug" || data.Author != "author" {
+		t.Errorf("unexpected data: %+v", data)
+	}
+}
+
+func TestRenderCommitMessage(t *testing.T) {
+	data := &CommitMessageData{
+		Number: 10,
+		Title:  "Fix the bug",
+		Body:   "The bug.",
+		Sections: map[string]string{
+			"Release note": "Fix the bug.",
+		},
+		CoAuthors: []string{"Helper <helper@example.com>", "Pair <pair@example.com>"},
+	}
+
+	testcases := []struct {
+		name     string
+		template CommitMessageTemplate
+
+		expectTitle string
+		expectBody  string
+	}{
+		{
+			name: "title and release note",
+			template: CommitMessageTemplate{
+				Title: "{{ .Title }} (#{{ .Number }})",
+				Body:  "{{ index .Sections \"Release note\" }}",
+			},
+			expectTitle: "Fix the bug (#10)",
+			expectBody:  "Fix the bug.\n\nCo-authored-by: Helper <helper@example.com>\nCo-authored-by: Pair <pair@example.com>",
+		},
+		{
+			name: "missing section",
+			template: CommitMessageTemplate{
+				Body: "{{ index .Sections \"Unknown\" }}",
+			},
+			expectBody: "Co-authored-by: Helper <helper@example.com>\nCo-authored-by: Pair <pair@example.com>",
+		},
+		{
+			name: "trailers in the template",
+			template: CommitMessageTemplate{
+				Body: "{{ .Body }}\n\n{{ range .CoAuthors }}Co-authored-by: {{ . }}\n{{ end }}",
+			},
+			expectBody: "The bug.\n\nCo-authored-by: Helper <helper@example.com>\nCo-authored-by: Pair <pair@example.com>",
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			title, body, err := tc.template.Render(data)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if title != tc.expectTitle {
+				t.Errorf("title mismatch: got %q, want %q", title, tc.expectTitle)
+			}
+			if body != tc.expectBody {
+				t.Errorf("body mismatch: got %q, want %q", body, tc.expectBody)
+			}
+		})
+	}
+}
@@ -5,28 +5,78 @@ import (
 	"fmt"
 	"net/url"
 	"regexp"
+	"strconv"
+	"time"
 
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/test-infra/prow/github"
 )
 
 const (
 	// defaultGracePeriodDuration define the time for blunderbuss plugin to wait
 	// before requesting a review (default five seconds).
 	defaultGracePeriodDuration = 5
+	// defaultMaxBatchSize specifies the maximum number of PRs tested together in the merge queue.
+	defaultMaxBatchSize = 5
+	// defaultStagingBranchPrefix specifies the prefix of the branches where the merge queue tests the batches.
+	defaultStagingBranchPrefix = "tichi-merge-queue/"
+	// defaultBatchTimeout specifies the minutes the merge queue waits for the required checks of a batch.
+	defaultBatchTimeout = 60
+	// defaultCherryPickLabelPrefix specifies the prefix of the labels which request the cherry-picks.
+	defaultCherryPickLabelPrefix = "needs-cherry-pick-"
+	// defaultReviewAttentionLabel specifies the label added to the PRs whose review is escalated.
+	defaultReviewAttentionLabel = "needs-review-attention"
+	// defaultFirstTimeContributorLabel specifies the label added to the PRs of the first-time contributors.
+	defaultFirstTimeContributorLabel = "first-time-contributor"
+	// defaultEscalationReviewerCount specifies the number of the reviewers requested when the review is escalated.
+	defaultEscalationReviewerCount = 1
 )
 
+// defaultAutoMergeBlockingLabels specifies the labels which prevent the auto-merge, they are the labels
+// added by the hold plugin and the wip plugin.
+var defaultAutoMergeBlockingLabels = []string{"do-not-merge/hold", "do-not-merge/work-in-progress"}
+
 // Allowed value of the action configuration of the label blocker plugin.
 const (
 	LabeledAction   = "labeled"
 	UnlabeledAction = "unlabeled"
 )
 
+// Allowed value of the reviewer selection configuration of the blunderbuss plugin.
+const (
+	// ReviewerSelectionRandom selects the reviewers randomly.
+	ReviewerSelectionRandom = "random"
+	// ReviewerSelectionLoad prefers the reviewers with the fewest pending review requests.
+	ReviewerSelectionLoad = "load"
+	// ReviewerSelectionExpertise prefers the reviewers who recently authored or reviewed the changed files.
+	ReviewerSelectionExpertise = "expertise"
+)
+
+// Allowed value of the push reset policy configuration of the lgtm plugin.
+const (
+	// LgtmResetPolicyKeep keeps all approvals when new commits are pushed.
+	LgtmResetPolicyKeep = "keep"
+	// LgtmResetPolicyReset resets all approvals when new commits are pushed.
+	LgtmResetPolicyReset = "reset"
+	// LgtmResetPolicyResetUnlessTrivial resets all approvals unless the new commits only
+	// merge the base branch or leave the tree unchanged.
+	LgtmResetPolicyResetUnlessTrivial = "reset-unless-trivial"
+)
+
 // Configuration is the top-level serialization target for external plugin Configuration.
 type Configuration struct {
 	TichiWebURL     string `json:"tichi-web-url,omitempty"`
 	PRProcessLink   string `json:"pr-process-link,omitempty"`
 	CommandHelpLink string `json:"command-help-link,omitempty"`
 
+	// Locale specifies the default locale of the bot messages, defaults to `en`.
+	Locale string `json:"locale,omitempty"`
+	// Templates specifies the message templates of the repos or organizations.
+	Templates []MessageTemplates `json:"templates,omitempty"`
+	// LabelSchemes specifies the label names used by the lgtm and merge plugins of the repos or organizations.
+	LabelSchemes []LabelScheme `json:"label-schemes,omitempty"`
+
 	TiCommunityLgtm          []TiCommunityLgtm          `json:"ti-community-lgtm,omitempty"`
 	TiCommunityMerge         []TiCommunityMerge         `json:"ti-community-merge,omitempty"`
 	TiCommunityOwners        []TiCommunityOwners        `json:"ti-community-owners,omitempty"`
@@ -35,6 +85,7 @@ type Configuration struct {
 	TiCommunityBlunderbuss   []TiCommunityBlunderbuss   `json:"ti-community-blunderbuss,omitempty"`
 	TiCommunityTars          []TiCommunityTars          `json:"ti-community-tars,omitempty"`
 	TiCommunityLabelBlocker  []TiCommunityLabelBlocker  `json:"ti-community-label-blocker,omitempty"`
+	TiCommunityCherrypicker  []TiCommunityCherrypicker  `json:"ti-community-cherrypicker,omitempty"`
 }
 
 // TiCommunityLgtm specifies a configuration for a single ti community lgtm.
@@ -47,6 +98,21 @@ type TiCommunityLgtm struct {
 	ReviewActsAsLgtm bool `json:"review_acts_as_lgtm,omitempty"`
 	// PullOwnersEndpoint specifies the URL of the reviewer of pull request.
 	PullOwnersEndpoint string `json:"pull_owners_endpoint,omitempty"`
+	// PushResetPolicy specifies what happens to the approvals when new commits are pushed,
+	// it can be `keep`, `reset` or `reset-unless-trivial`, defaults to `keep`.
+	PushResetPolicy string `json:"push_reset_policy,omitempty"`
+	// RoleWeights specifies how many approvals the LGTM of each role counts for, the role can be
+	// `leader`, `co-leader`, `committer` or `reviewer`, and the weight of a role defaults to 1.
+	RoleWeights map[string]int `json:"role_weights,omitempty"`
+	// RequiredCommitterLgtm specifies the minimum number of LGTMs from committers.
+	RequiredCommitterLgtm int `json:"required_committer_lgtm,omitempty"`
+	// RequiredAffiliations specifies the minimum number of distinct affiliations among the approvers.
+	RequiredAffiliations int `json:"required_affiliations,omitempty"`
+	// ReviewStatus specifies whether to publish the review progress as a commit status on the head commit.
+	ReviewStatus bool `json:"review_status,omitempty"`
+	// RecreateNotification specifies whether to create a new review notification and delete the old ones
+	// instead of editing the notification in place.
+	RecreateNotification bool `json:"recreate_notification,omitempty"`
 }
 
 // TiCommunityMerge specifies a configuration for a single merge.
@@ -55,11 +121,125 @@ type TiCommunityLgtm struct {
 type TiCommunityMerge struct {
 	// Repos is either of the form org/repos or just org.
 	Repos []string `json:"repos,omitempty"`
-	// StoreTreeHash indicates if tree_hash should be stored inside a comment to detect
-	// guaranteed commits before removing can merge labels.
+	// StoreTreeHash indicates if the tree hash and the base commit should be stored inside a comment to
+	// detect the new commits with the approved diff before removing can merge labels.
 	StoreTreeHash bool `json:"store_tree_hash,omitempty"`
 	// PullOwnersEndpoint specifies the URL of the reviewer of pull request.
 	PullOwnersEndpoint string `json:"pull_owners_endpoint,omitempty"`
+	// ReviewStatus specifies whether to publish the review progress as a commit status on the head commit,
+	// the status is successful only if the PR can be merged when it is enabled.
+	ReviewStatus bool `json:"review_status,omitempty"`
+	// RecreateNotification specifies whether to create a new merge notification and delete the old ones
+	// instead of editing the notification in place.
+	RecreateNotification bool `json:"recreate_notification,omitempty"`
+	// RequireContexts specifies the status contexts and the check runs that must pass before the PR can be merged.
+	RequireContexts []string `json:"require_contexts,omitempty"`
+	// LabelWhenChecksPass specifies whether to add the 'can-merge' label automatically when the required
+	// checks pass after a committer has commented `/merge`.
+	LabelWhenChecksPass bool `json:"label_when_checks_pass,omitempty"`
+	// MergeQueue specifies whether the PRs with the 'can-merge' label are merged by the merge queue,
+	// the required contexts are checked against the staging branch before the PRs are merged.
+	MergeQueue bool `json:"merge_queue,omitempty"`
+	// MaxBatchSize specifies the maximum number of PRs tested together in the merge queue.
+	MaxBatchSize int `json:"max_batch_size,omitempty"`
+	// StagingBranchPrefix specifies the prefix of the branches where the merge queue tests the batches,
+	// the staging branch of a base branch is the prefix followed by the name of the base branch.
+	StagingBranchPrefix string `json:"staging_branch_prefix,omitempty"`
+	// BatchTimeout specifies the minutes the merge queue waits for the required checks of a batch,
+	// the batch is regarded as failed after the timeout.
+	BatchTimeout int `json:"batch_timeout,omitempty"`
+	// MergeMethods specifies the merge methods that committers can choose by `/merge <method>`,
+	// the chosen method is recorded on the PR as a label.
+	MergeMethods []string `json:"merge_methods,omitempty"`
+	// MergeMethod specifies the merge method used by the merge queue when the PR does not choose one.
+	MergeMethod string `json:"merge_method,omitempty"`
+	// SquashCommitTemplate specifies the commit title and message used by the merge queue
+	// when a PR is squashed, it requires the merge queue because Tide uses its own merge commit template.
+	SquashCommitTemplate *CommitMessageTemplate `json:"squash_commit_template,omitempty"`
+	// Freezes specifies the code freezes during which the PRs to the frozen branches can not be merged.
+	Freezes []MergeFreeze `json:"freezes,omitempty"`
+	// TrackDependencies specifies whether to withhold the 'can-merge' label while the PRs which the PR
+	// depends on are open, the dependencies are declared by "depends on #1234" in the PR body or `/depends-on`.
+	TrackDependencies bool `json:"track_dependencies,omitempty"`
+	// AutoMerge specifies whether the 'can-merge' label is added automatically once the approvals are satisfied
+	// and the required checks pass, it can be changed for a PR by `/auto-merge [cancel]`.
+	AutoMerge bool `json:"auto_merge,omitempty"`
+	// AutoMergeBlockingLabels specifies the labels which prevent the 'can-merge' label from being added
+	// automatically, such as the hold label.
+	AutoMergeBlockingLabels []string `json:"auto_merge_blocking_labels,omitempty"`
+}
+
+// MergeFreeze specifies a code freeze of the base branches, such as the code freeze before a release.
+type MergeFreeze struct {
+	// Name identifies the freeze in the announcements, such as the version to release.
+	Name string `json:"name,omitempty"`
+	// Branches specifies the regexes of the frozen base branches.
+	Branches []string `json:"branches,omitempty"`
+	// Start specifies when the freeze starts, the freeze is only started by the freeze label if it is empty.
+	Start *time.Time `json:"start,omitempty"`
+	// End specifies when the freeze ends, the freeze lasts until it is removed from the config if it is empty.
+	End *time.Time `json:"end,omitempty"`
+	// TrackingIssue specifies the issue which tracks the freeze in the form of org/repo#number,
+	// the freeze is announced on it.
+	TrackingIssue string `json:"tracking_issue,omitempty"`
+	// FreezeLabel specifies the label of the tracking issue which starts the freeze,
+	// it is added by `/freeze` and removed by `/freeze cancel` on the tracking issue.
+	FreezeLabel string `json:"freeze_label,omitempty"`
+	// ExemptLabels specifies the labels of the PRs which can still be merged during the freeze.
+	ExemptLabels []string `json:"exempt_labels,omitempty"`
+	// TrustedTeams specifies the teams whose members can start the freeze by `/freeze`
+	// and can still `/merge` the PRs during the freeze.
+	TrustedTeams []string `json:"trusted_teams,omitempty"`
+}
+
+// trackingIssueRe matches the tracking issue of the freeze.
+var trackingIssueRe = regexp.MustCompile(`^([^/\s]+)/([^#\s]+)#(\d+)$`)
+
+// ParseTrackingIssue returns the org, the repo and the number of the tracking issue.
+func (f *MergeFreeze) ParseTrackingIssue() (string, string, int, error) {
+	m := trackingIssueRe.FindStringSubmatch(f.TrackingIssue)
+	if m == nil {
+		return "", "", 0, fmt.Errorf("invalid tracking issue %q", f.TrackingIssue)
+	}
+	number, err := strconv.Atoi(m[3])
+	if err != nil {
+		return "", "", 0, err
+	}
+	return m[1], m[2], number, nil
+}
+
+// MatchBranch returns true if the branch is frozen by the freeze.
+func (f *MergeFreeze) MatchBranch(branch string) bool {
+	for _, pattern := range f.Branches {
+		if re, err := regexp.Compile("^(?:" + pattern + ")$"); err == nil && re.MatchString(branch) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsScheduled returns true if the time is in the scheduled time range of the freeze.
+func (f *MergeFreeze) IsScheduled(now time.Time) bool {
+	return f.Start != nil && !now.Before(*f.Start) && (f.End == nil || now.Before(*f.End))
+}
+
+// setDefaults will set the default value for the config of merge plugin.
+func (c *TiCommunityMerge) setDefaults() {
+	if c.MaxBatchSize == 0 {
+		c.MaxBatchSize = defaultMaxBatchSize
+	}
+	if c.StagingBranchPrefix == "" {
+		c.StagingBranchPrefix = defaultStagingBranchPrefix
+	}
+	if c.BatchTimeout == 0 {
+		c.BatchTimeout = defaultBatchTimeout
+	}
+	if c.MergeMethod == "" {
+		c.MergeMethod = string(github.MergeMerge)
+	}
+	if c.AutoMergeBlockingLabels == nil {
+		c.AutoMergeBlockingLabels = defaultAutoMergeBlockingLabels
+	}
 }
 
 // TiCommunityOwners specifies a configuration for a single ti community owners plugin.
@@ -151,6 +331,164 @@ type TiCommunityBlunderbuss struct {
 	GracePeriodDuration int `json:"grace_period_duration,omitempty"`
 	// RequireSigLabel specifies whether the PR is required to have a sig label before requesting reviewers.
 	RequireSigLabel bool `json:"require_sig_label,omitempty"`
+	// ReviewerSelection specifies how the reviewers are selected from the candidates, defaults to random.
+	ReviewerSelection string `json:"reviewer_selection,omitempty"`
+	// MaxPendingReviews specifies the maximum number of the pending review requests of a reviewer,
+	// the reviewers who reach it are not requested by the load-aware selection. Defaults to 0 meaning no limit.
+	MaxPendingReviews int `json:"max_pending_reviews,omitempty"`
+	// SigReviewerCount specifies the number of the reviewers requested from each SIG of the PR,
+	// the reviewers requested before are counted and the total is still limited by the MaxReviewerCount.
+	// Defaults to 0 meaning the reviewers are selected from all the SIGs together.
+	SigReviewerCount int `json:"sig_reviewer_count,omitempty"`
+	// ReviewReminder specifies how to remind the requested reviewers who have not reviewed the PRs in time,
+	// the reminders are disabled if it is empty.
+	ReviewReminder *ReviewReminder `json:"review_reminder,omitempty"`
+	// FirstTimeContributor specifies how to welcome the first-time contributors and pair them with mentors,
+	// the first-time contributors are not treated specially if it is empty.
+	FirstTimeContributor *FirstTimeContributor `json:"first_time_contributor,omitempty"`
+	// AssignCommitterOnLgtm specifies whether a committer is assigned to the PR to help merge it once the PR
+	// has acquired the required number of LGTMs, the committer is selected like the load-aware reviewers.
+	AssignCommitterOnLgtm bool `json:"assign_committer_on_lgtm,omitempty"`
+}
+
+// FirstTimeContributor specifies the mentors of the first-time contributors and the welcome comment.
+type FirstTimeContributor struct {
+	// Mentors specifies the mentors of the repos, a mentor is requested to review the PR of a first-time
+	// contributor if none of the SIG mentors matches the PR.
+	Mentors []string `json:"mentors,omitempty"`
+	// SigMentors specifies the mentors of the SIGs, they are preferred for the PRs with the sig labels.
+	SigMentors []SigMentors `json:"sig_mentors,omitempty"`
+	// Label specifies the label added to the PRs of the first-time contributors,
+	// defaults to `first-time-contributor`.
+	Label string `json:"label,omitempty"`
+	// Links specifies the contribution links in the welcome comment.
+	Links []ContributionLink `json:"links,omitempty"`
+}
+
+// SigMentors specifies the mentors of a SIG.
+type SigMentors struct {
+	// Sig specifies the name of the SIG, such as `planner` for the `sig/planner` label.
+	Sig string `json:"sig,omitempty"`
+	// Mentors specifies the mentors of the SIG.
+	Mentors []string `json:"mentors,omitempty"`
+}
+
+// ContributionLink is a link which helps the first-time contributors, such as the contributing guide.
+type ContributionLink struct {
+	// Name specifies the text of the link.
+	Name string `json:"name,omitempty"`
+	// URL specifies the address of the link.
+	URL string `json:"url,omitempty"`
+}
+
+// MentorsFor returns the mentors of the sigs, the mentors of the repos are returned if none of the sigs
+// has mentors.
+func (f *FirstTimeContributor) MentorsFor(sigs []string) []string {
+	sigSet := sets.NewString(sigs...)
+	mentors := sets.NewString()
+	for _, sigMentors := range f.SigMentors {
+		if sigSet.Has(sigMentors.Sig) {
+			mentors.Insert(sigMentors.Mentors...)
+		}
+	}
+	if mentors.Len() == 0 {
+		return f.Mentors
+	}
+	return mentors.List()
+}
+
+// AllMentors returns the mentors of the repos and all the SIGs.
+func (f *FirstTimeContributor) AllMentors() []string {
+	mentors := sets.NewString(f.Mentors...)
+	for _, sigMentors := range f.SigMentors {
+		mentors.Insert(sigMentors.Mentors...)
+	}
+	return mentors.List()
+}
+
+// ReviewReminder specifies when to remind the requested reviewers and when to escalate the review.
+type ReviewReminder struct {
+	// RemindAfter specifies the hours after the review request when the reviewer who has not reviewed is pinged,
+	// defaults to 0 meaning the reviewers are not pinged.
+	RemindAfter int `json:"remind_after,omitempty"`
+	// EscalateAfter specifies the hours after the review request when the review is escalated,
+	// defaults to 0 meaning the review is not escalated.
+	EscalateAfter int `json:"escalate_after,omitempty"`
+	// EscalateToCommitters specifies whether the review is escalated to the committers of the SIGs,
+	// otherwise it is escalated to the other reviewers.
+	EscalateToCommitters bool `json:"escalate_to_committers,omitempty"`
+	// EscalationReviewerCount specifies the number of the reviewers requested when the review is escalated,
+	// defaults to 1.
+	EscalationReviewerCount int `json:"escalation_reviewer_count,omitempty"`
+	// AttentionLabel specifies the label added to the PR when the review is escalated,
+	// defaults to `needs-review-attention`.
+	AttentionLabel string `json:"attention_label,omitempty"`
+	// Sigs specifies the thresholds of the PRs with the sig labels, the smallest thresholds are used
+	// if the PR has several sig labels.
+	Sigs []SigReviewReminder `json:"sigs,omitempty"`
+	// QuietHours specifies the hours during which the reviewers are not reminded.
+	QuietHours *QuietHours `json:"quiet_hours,omitempty"`
+}
+
+// SigReviewReminder specifies the thresholds of the reminder for a SIG.
+type SigReviewReminder struct {
+	// Sig specifies the name of the SIG, such as `planner` for the `sig/planner` label.
+	Sig string `json:"sig,omitempty"`
+	// RemindAfter overrides the hours after which the reviewers are pinged if it is not 0.
+	RemindAfter int `json:"remind_after,omitempty"`
+	// EscalateAfter overrides the hours after which the review is escalated if it is not 0.
+	EscalateAfter int `json:"escalate_after,omitempty"`
+}
+
+// QuietHours specifies the hours of the day during which the reviewers are not reminded.
+type QuietHours struct {
+	// Start specifies the hour when the quiet hours start, from 0 to 23.
+	Start int `json:"start,omitempty"`
+	// End specifies the hour when the quiet hours end, from 0 to 23.
+	// The quiet hours cross midnight if it is less than the start.
+	End int `json:"end,omitempty"`
+	// TimeZone specifies the IANA time zone of the hours, such as `Asia/Shanghai`, defaults to UTC.
+	TimeZone string `json:"time_zone,omitempty"`
+}
+
+// Contains returns true if the time is in the quiet hours.
+func (q *QuietHours) Contains(now time.Time) bool {
+	location, err := time.LoadLocation(q.TimeZone)
+	if err != nil {
+		return false
+	}
+	hour := now.In(location).Hour()
+	if q.Start <= q.End {
+		return hour >= q.Start && hour < q.End
+	}
+	return hour >= q.Start || hour < q.End
+}
+
+// ThresholdsFor returns the hours after which the reviewers are pinged and the review is escalated
+// for the PR with the sigs.
+func (r *ReviewReminder) ThresholdsFor(sigs []string) (int, int) {
+	remindAfter := r.RemindAfter
+	escalateAfter := r.EscalateAfter
+	sigSet := sets.NewString(sigs...)
+	var sigRemindAfter, sigEscalateAfter int
+	for _, sig := range r.Sigs {
+		if !sigSet.Has(sig.Sig) {
+			continue
+		}
+		if sig.RemindAfter > 0 && (sigRemindAfter == 0 || sig.RemindAfter < sigRemindAfter) {
+			sigRemindAfter = sig.RemindAfter
+		}
+		if sig.EscalateAfter > 0 && (sigEscalateAfter == 0 || sig.EscalateAfter < sigEscalateAfter) {
+			sigEscalateAfter = sig.EscalateAfter
+		}
+	}
+	if sigRemindAfter > 0 {
+		remindAfter = sigRemindAfter
+	}
+	if sigEscalateAfter > 0 {
+		escalateAfter = sigEscalateAfter
+	}
+	return remindAfter, escalateAfter
 }
 
 // setDefaults will set the default value for the config of blunderbuss plugin.
@@ -158,6 +496,20 @@ func (c *TiCommunityBlunderbuss) setDefaults() {
 	if c.GracePeriodDuration == 0 {
 		c.GracePeriodDuration = defaultGracePeriodDuration
 	}
+	if c.ReviewerSelection == "" {
+		c.ReviewerSelection = ReviewerSelectionRandom
+	}
+	if c.FirstTimeContributor != nil && c.FirstTimeContributor.Label == "" {
+		c.FirstTimeContributor.Label = defaultFirstTimeContributorLabel
+	}
+	if c.ReviewReminder != nil {
+		if c.ReviewReminder.EscalationReviewerCount == 0 {
+			c.ReviewReminder.EscalationReviewerCount = defaultEscalationReviewerCount
+		}
+		if c.ReviewReminder.AttentionLabel == "" {
+			c.ReviewReminder.AttentionLabel = defaultReviewAttentionLabel
+		}
+	}
 }
 
 // TiCommunityTars is the config for the tars plugin.
@@ -168,6 +520,9 @@ type TiCommunityTars struct {
 	Message string `json:"message,omitempty"`
 	// OnlyWhenLabel specifies that the automatic update is triggered only when the PR has this label.
 	OnlyWhenLabel string `json:"only_when_label,omitempty"`
+	// EditMessage specifies whether to edit the message in place instead of creating a new message and
+	// deleting the old ones, the commands in the message will not be triggered again if it is edited.
+	EditMessage bool `json:"edit_message,omitempty"`
 }
 
 // TiCommunityLabelBlocker is the config for the label blocker plugin.
@@ -192,6 +547,27 @@ type BlockLabel struct {
 	Message string `json:"message,omitempty"`
 }
 
+// TiCommunityCherrypicker is the config for the cherrypicker plugin.
+type TiCommunityCherrypicker struct {
+	// Repos is either of the form org/repos or just org.
+	Repos []string `json:"repos,omitempty"`
+	// LabelPrefix specifies the prefix of the labels which request the cherry-picks to the branches,
+	// the rest of the label is the branch name, defaults to `needs-cherry-pick-`.
+	LabelPrefix string `json:"label_prefix,omitempty"`
+	// AllowAll specifies whether everyone can use `/cherry-pick`, only the members of the org can use it by default.
+	AllowAll bool `json:"allow_all,omitempty"`
+	// ExcludeLabels specifies the labels which are not copied to the cherry-pick PRs,
+	// the LGTM labels, the 'can-merge' label and the cherry-pick labels are never copied.
+	ExcludeLabels []string `json:"exclude_labels,omitempty"`
+}
+
+// setDefaults will set the default value for the config of cherrypicker plugin.
+func (c *TiCommunityCherrypicker) setDefaults() {
+	if c.LabelPrefix == "" {
+		c.LabelPrefix = defaultCherryPickLabelPrefix
+	}
+}
+
 // LgtmFor finds the Lgtm for a repo, if one exists
 // a trigger can be listed for the repo itself or for the
 // owning organization
@@ -360,11 +736,43 @@ func (c *Configuration) LabelBlockerFor(org, repo string) *TiCommunityLabelBlock
 	return &TiCommunityLabelBlocker{}
 }
 
+// CherrypickerFor finds the TiCommunityCherrypicker for a repo, if one exists.
+// TiCommunityCherrypicker configuration can be listed for a repository
+// or an organization, the default values are set if it is not found.
+func (c *Configuration) CherrypickerFor(org, repo string) *TiCommunityCherrypicker {
+	fullName := fmt.Sprintf("%s/%s", org, repo)
+	cherrypicker := TiCommunityCherrypicker{}
+	found := false
+	for _, cfg := range c.TiCommunityCherrypicker {
+		if sets.NewString(cfg.Repos...).Has(fullName) {
+			cherrypicker, found = cfg, true
+			break
+		}
+	}
+	// If you don't find anything, loop again looking for an org config
+	if !found {
+		for _, cfg := range c.TiCommunityCherrypicker {
+			if sets.NewString(cfg.Repos...).Has(org) {
+				cherrypicker = cfg
+				break
+			}
+		}
+	}
+	cherrypicker.setDefaults()
+	return &cherrypicker
+}
+
 // setDefaults will set the default value for the configuration of all plugins.
 func (c *Configuration) setDefaults() {
 	for i := range c.TiCommunityBlunderbuss {
 		c.TiCommunityBlunderbuss[i].setDefaults()
 	}
+	for i := range c.TiCommunityMerge {
+		c.TiCommunityMerge[i].setDefaults()
+	}
+	for i := range c.TiCommunityCherrypicker {
+		c.TiCommunityCherrypicker[i].setDefaults()
+	}
 }
 
 // Validate will return an error if there are any invalid external plugin config.
@@ -388,6 +796,14 @@ func (c *Configuration) Validate() error {
 		return err
 	}
 
+	if err := validateTemplates(c.Locale, c.Templates); err != nil {
+		return err
+	}
+
+	if err := validateLabelSchemes(c.LabelSchemes); err != nil {
+		return err
+	}
+
 	if err := validateLgtm(c.TiCommunityLgtm); err != nil {
 		return err
 	}
@@ -415,13 +831,36 @@ func (c *Configuration) Validate() error {
 	return nil
 }
 
-// validateLgtm will return an error if the URL configured by lgtm is invalid.
+// validateLgtm will return an error if the URL or the push reset policy configured by lgtm is invalid.
 func validateLgtm(lgtms []TiCommunityLgtm) error {
+	allowPolicySet := sets.NewString(LgtmResetPolicyKeep, LgtmResetPolicyReset, LgtmResetPolicyResetUnlessTrivial)
+
 	for _, lgtm := range lgtms {
 		_, err := url.ParseRequestURI(lgtm.PullOwnersEndpoint)
 		if err != nil {
 			return err
 		}
+
+		if lgtm.PushResetPolicy != "" && !allowPolicySet.Has(lgtm.PushResetPolicy) {
+			return fmt.Errorf("push reset policy contains illegal value %s", lgtm.PushResetPolicy)
+		}
+
+		for role, weight := range lgtm.RoleWeights {
+			if !ownersclient.IsKnownRole(role) {
+				return fmt.Errorf("role weights contain illegal role %s", role)
+			}
+			if weight <= 0 {
+				return fmt.Errorf("weight of role %s must be positive", role)
+			}
+		}
+
+		if lgtm.RequiredCommitterLgtm < 0 {
+			return fmt.Errorf("required committer lgtm cannot be negative")
+		}
+
+		if lgtm.RequiredAffiliations < 0 {
+			return fmt.Errorf("required affiliations cannot be negative")
+		}
 	}
 
 	return nil
@@ -434,11 +873,92 @@ func validateMerge(merges []TiCommunityMerge) error {
 		if err != nil {
 			return err
 		}
+
+		if merge.LabelWhenChecksPass && len(merge.RequireContexts) == 0 {
+			return fmt.Errorf("label when checks pass requires the required contexts")
+		}
+
+		if merge.MergeQueue && len(merge.RequireContexts) == 0 {
+			return fmt.Errorf("merge queue requires the required contexts")
+		}
+		if merge.MaxBatchSize < 0 {
+			return errors.New("max batch size must not less than 0")
+		}
+		if merge.BatchTimeout < 0 {
+			return errors.New("batch timeout must not less than 0")
+		}
+
+		for _, method := range merge.MergeMethods {
+			if !isMergeMethod(method) {
+				return fmt.Errorf("unsupported merge method %s", method)
+			}
+		}
+		if merge.MergeMethod != "" && !isMergeMethod(merge.MergeMethod) {
+			return fmt.Errorf("unsupported merge method %s", merge.MergeMethod)
+		}
+		if merge.SquashCommitTemplate != nil {
+			// Tide squashes the PRs with its own merge commit template, so only the merge queue uses it.
+			if !merge.MergeQueue {
+				return fmt.Errorf("squash commit template requires the merge queue")
+			}
+			if err := merge.SquashCommitTemplate.validate(); err != nil {
+				return err
+			}
+		}
 	}
 
+	return validateMergeFreezes(merges)
+}
+
+// validateMergeFreezes will return an error if the freezes configured by merge are invalid.
+func validateMergeFreezes(merges []TiCommunityMerge) error {
+	names := sets.NewString()
+	for _, merge := range merges {
+		for _, freeze := range merge.Freezes {
+			if freeze.Name == "" {
+				return errors.New("freeze name is required")
+			}
+			// The announcements of the freeze are identified by the name.
+			if names.Has(freeze.Name) {
+				return fmt.Errorf("duplicate freeze %s", freeze.Name)
+			}
+			names.Insert(freeze.Name)
+
+			if len(freeze.Branches) == 0 {
+				return fmt.Errorf("freeze %s requires the branches", freeze.Name)
+			}
+			for _, pattern := range freeze.Branches {
+				if _, err := regexp.Compile(pattern); err != nil {
+					return err
+				}
+			}
+			if _, _, _, err := freeze.ParseTrackingIssue(); err != nil {
+				return fmt.Errorf("freeze %s: %v", freeze.Name, err)
+			}
+			if freeze.Start == nil && freeze.FreezeLabel == "" {
+				return fmt.Errorf("freeze %s requires the start time or the freeze label", freeze.Name)
+			}
+			if freeze.Start != nil && freeze.End != nil && !freeze.Start.Before(*freeze.End) {
+				return fmt.Errorf("freeze %s must start before it ends", freeze.Name)
+			}
+			if freeze.FreezeLabel != "" && len(freeze.TrustedTeams) == 0 {
+				return fmt.Errorf("freeze %s requires the trusted teams to use the freeze label", freeze.Name)
+			}
+		}
+	}
 	return nil
 }
 
+// isMergeMethod returns true if the method is a supported merge method.
+func isMergeMethod(method string) bool {
+	for _, m := range MergeMethods {
+		if method == string(m) {
+			return true
+		}
+	}
+	return false
+}
+
 // validateOwners will return an error if the endpoint configured by merge is invalid.
 func validateOwners(owners []TiCommunityOwners) error {
 	for _, merge := range owners {
@@ -478,11 +998,80 @@ func validateBlunderbuss(blunderbusses []TiCommunityBlunderbuss) error {
 		if blunderbuss.GracePeriodDuration < 0 {
 			return errors.New("grace period duration must not less than 0")
 		}
+		if !sets.NewString(ReviewerSelectionRandom, ReviewerSelectionLoad,
+			ReviewerSelectionExpertise).Has(blunderbuss.ReviewerSelection) {
+			return fmt.Errorf("invalid reviewer selection %q", blunderbuss.ReviewerSelection)
+		}
+		if blunderbuss.MaxPendingReviews < 0 {
+			return errors.New("max pending reviews must not less than 0")
+		}
+		if blunderbuss.SigReviewerCount < 0 {
+			return errors.New("sig reviewer count must not less than 0")
+		}
+		if blunderbuss.ReviewReminder != nil {
+			if err := validateReviewReminder(blunderbuss.ReviewReminder); err != nil {
+				return err
+			}
+		}
+		if blunderbuss.FirstTimeContributor != nil {
+			if err := validateFirstTimeContributor(blunderbuss.FirstTimeContributor); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
+// validateReviewReminder will return an error if the thresholds or the quiet hours are invalid.
+func validateReviewReminder(reminder *ReviewReminder) error {
+	if reminder.RemindAfter < 0 || reminder.EscalateAfter < 0 {
+		return errors.New("review reminder thresholds must not less than 0")
+	}
+	if reminder.EscalationReviewerCount < 0 {
+		return errors.New("escalation reviewer count must not less than 0")
+	}
+	for _, sig := range reminder.Sigs {
+		if sig.Sig == "" {
+			return errors.New("review reminder sig must not be empty")
+		}
+		if sig.RemindAfter < 0 || sig.EscalateAfter < 0 {
+			return fmt.Errorf("review reminder thresholds of sig %s must not less than 0", sig.Sig)
+		}
+	}
+	if reminder.QuietHours != nil {
+		quietHours := reminder.QuietHours
+		if quietHours.Start < 0 || quietHours.Start > 23 || quietHours.End < 0 || quietHours.End > 23 {
+			return errors.New("quiet hours must be from 0 to 23")
+		}
+		if _, err := time.LoadLocation(quietHours.TimeZone); err != nil {
+			return fmt.Errorf("invalid quiet hours time zone %q: %v", quietHours.TimeZone, err)
+		}
+	}
+	return nil
+}
+
+// validateFirstTimeContributor will return an error if the mentors or the links are invalid.
+func validateFirstTimeContributor(firstTimeContributor *FirstTimeContributor) error {
+	if len(firstTimeContributor.AllMentors()) == 0 {
+		return errors.New("first-time contributor mentors must not be empty")
+	}
+	for _, sigMentors := range firstTimeContributor.SigMentors {
+		if sigMentors.Sig == "" {
+			return errors.New("first-time contributor mentor sig must not be empty")
+		}
+	}
+	for _, link := range firstTimeContributor.Links {
+		if link.Name == "" {
+			return errors.New("contribution link name must not be empty")
+		}
+		if _, err := url.ParseRequestURI(link.URL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // validateLabelBlocker will return an error if the regex cannot compile or actions is illegal.
 func validateLabelBlocker(labelBlockers []TiCommunityLabelBlocker) error {
 	for _, labelBlocker := range labelBlockers {
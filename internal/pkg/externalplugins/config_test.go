@@ -3,7 +3,9 @@ package externalplugins
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
+	"time"
 
 	"gotest.tools/assert"
 )
@@ -438,6 +440,304 @@ func TestValidateConfig(t *testing.T) {
 			},
 			expected: fmt.Errorf("grace period duration must not less than 0"),
 		},
+		{
+			name:            "invalid blunderbuss reviewer selection",
+			tichiWebURL:     "https://tichiWebURL",
+			commandHelpLink: "https://commandHelpLink",
+			prProcessLink:   "https://prProcessLink",
+			lgtm: &TiCommunityLgtm{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				ReviewActsAsLgtm:   true,
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			merge: &TiCommunityMerge{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			owners: &TiCommunityOwners{
+				Repos:       []string{"tidb-community-bots/test-dev"},
+				SigEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			autoresponders: &TiCommunityAutoresponder{
+				Repos: []string{"tidb-community-bots/test-dev"},
+				AutoResponds: []AutoRespond{
+					{
+						Regex:   `(?mi)^/merge\s*$`,
+						Message: "/run-all-test",
+					},
+				},
+			},
+			blunderbuss: &TiCommunityBlunderbuss{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				MaxReviewerCount:   2,
+				ExcludeReviewers:   []string{},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+				ReviewerSelection:  "busiest",
+			},
+			labelBlocker: &TiCommunityLabelBlocker{
+				Repos: []string{"ti-community-infra/test-dev"},
+				BlockLabels: []BlockLabel{
+					{
+						Regex:        `^status/can-merge$`,
+						Actions:      []string{"labeled", "unlabeled"},
+						TrustedTeams: []string{"release-team"},
+						TrustedUsers: []string{"ti-chi-bot"},
+					},
+				},
+			},
+			expected: fmt.Errorf("invalid reviewer selection \"busiest\""),
+		},
+		{
+			name:            "blunderbuss first-time contributor without mentors",
+			tichiWebURL:     "https://tichiWebURL",
+			commandHelpLink: "https://commandHelpLink",
+			prProcessLink:   "https://prProcessLink",
+			lgtm: &TiCommunityLgtm{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				ReviewActsAsLgtm:   true,
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			merge: &TiCommunityMerge{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			owners: &TiCommunityOwners{
+				Repos:       []string{"tidb-community-bots/test-dev"},
+				SigEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			autoresponders: &TiCommunityAutoresponder{
+				Repos: []string{"tidb-community-bots/test-dev"},
+				AutoResponds: []AutoRespond{
+					{
+						Regex:   `(?mi)^/merge\s*$`,
+						Message: "/run-all-test",
+					},
+				},
+			},
+			blunderbuss: &TiCommunityBlunderbuss{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				MaxReviewerCount:   2,
+				ExcludeReviewers:   []string{},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+				ReviewerSelection:  ReviewerSelectionRandom,
+				FirstTimeContributor: &FirstTimeContributor{
+					SigMentors: []SigMentors{{Sig: "planner"}},
+				},
+			},
+			labelBlocker: &TiCommunityLabelBlocker{
+				Repos: []string{"ti-community-infra/test-dev"},
+				BlockLabels: []BlockLabel{
+					{
+						Regex:        `^status/can-merge$`,
+						Actions:      []string{"labeled", "unlabeled"},
+						TrustedTeams: []string{"release-team"},
+						TrustedUsers: []string{"ti-chi-bot"},
+					},
+				},
+			},
+			expected: fmt.Errorf("first-time contributor mentors must not be empty"),
+		},
+		{
+			name:            "invalid blunderbuss contribution link",
+			tichiWebURL:     "https://tichiWebURL",
+			commandHelpLink: "https://commandHelpLink",
+			prProcessLink:   "https://prProcessLink",
+			lgtm: &TiCommunityLgtm{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				ReviewActsAsLgtm:   true,
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			merge: &TiCommunityMerge{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			owners: &TiCommunityOwners{
+				Repos:       []string{"tidb-community-bots/test-dev"},
+				SigEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			autoresponders: &TiCommunityAutoresponder{
+				Repos: []string{"tidb-community-bots/test-dev"},
+				AutoResponds: []AutoRespond{
+					{
+						Regex:   `(?mi)^/merge\s*$`,
+						Message: "/run-all-test",
+					},
+				},
+			},
+			blunderbuss: &TiCommunityBlunderbuss{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				MaxReviewerCount:   2,
+				ExcludeReviewers:   []string{},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+				ReviewerSelection:  ReviewerSelectionRandom,
+				FirstTimeContributor: &FirstTimeContributor{
+					Mentors: []string{"mentor1"},
+					Links:   []ContributionLink{{URL: "https://example.com"}},
+				},
+			},
+			labelBlocker: &TiCommunityLabelBlocker{
+				Repos: []string{"ti-community-infra/test-dev"},
+				BlockLabels: []BlockLabel{
+					{
+						Regex:        `^status/can-merge$`,
+						Actions:      []string{"labeled", "unlabeled"},
+						TrustedTeams: []string{"release-team"},
+						TrustedUsers: []string{"ti-chi-bot"},
+					},
+				},
+			},
+			expected: fmt.Errorf("contribution link name must not be empty"),
+		},
+		{
+			name:            "invalid blunderbuss sig reviewer count",
+			tichiWebURL:     "https://tichiWebURL",
+			commandHelpLink: "https://commandHelpLink",
+			prProcessLink:   "https://prProcessLink",
+			lgtm: &TiCommunityLgtm{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				ReviewActsAsLgtm:   true,
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			merge: &TiCommunityMerge{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			owners: &TiCommunityOwners{
+				Repos:       []string{"tidb-community-bots/test-dev"},
+				SigEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			autoresponders: &TiCommunityAutoresponder{
+				Repos: []string{"tidb-community-bots/test-dev"},
+				AutoResponds: []AutoRespond{
+					{
+						Regex:   `(?mi)^/merge\s*$`,
+						Message: "/run-all-test",
+					},
+				},
+			},
+			blunderbuss: &TiCommunityBlunderbuss{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				MaxReviewerCount:   2,
+				ExcludeReviewers:   []string{},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+				ReviewerSelection:  ReviewerSelectionRandom,
+				SigReviewerCount:   -1,
+			},
+			labelBlocker: &TiCommunityLabelBlocker{
+				Repos: []string{"ti-community-infra/test-dev"},
+				BlockLabels: []BlockLabel{
+					{
+						Regex:        `^status/can-merge$`,
+						Actions:      []string{"labeled", "unlabeled"},
+						TrustedTeams: []string{"release-team"},
+						TrustedUsers: []string{"ti-chi-bot"},
+					},
+				},
+			},
+			expected: fmt.Errorf("sig reviewer count must not less than 0"),
+		},
+		{
+			name:            "invalid blunderbuss quiet hours",
+			tichiWebURL:     "https://tichiWebURL",
+			commandHelpLink: "https://commandHelpLink",
+			prProcessLink:   "https://prProcessLink",
+			lgtm: &TiCommunityLgtm{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				ReviewActsAsLgtm:   true,
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			merge: &TiCommunityMerge{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			owners: &TiCommunityOwners{
+				Repos:       []string{"tidb-community-bots/test-dev"},
+				SigEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			autoresponders: &TiCommunityAutoresponder{
+				Repos: []string{"tidb-community-bots/test-dev"},
+				AutoResponds: []AutoRespond{
+					{
+						Regex:   `(?mi)^/merge\s*$`,
+						Message: "/run-all-test",
+					},
+				},
+			},
+			blunderbuss: &TiCommunityBlunderbuss{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				MaxReviewerCount:   2,
+				ExcludeReviewers:   []string{},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+				ReviewerSelection:  ReviewerSelectionRandom,
+				ReviewReminder: &ReviewReminder{
+					RemindAfter: 24,
+					QuietHours:  &QuietHours{Start: 22, End: 24},
+				},
+			},
+			labelBlocker: &TiCommunityLabelBlocker{
+				Repos: []string{"ti-community-infra/test-dev"},
+				BlockLabels: []BlockLabel{
+					{
+						Regex:        `^status/can-merge$`,
+						Actions:      []string{"labeled", "unlabeled"},
+						TrustedTeams: []string{"release-team"},
+						TrustedUsers: []string{"ti-chi-bot"},
+					},
+				},
+			},
+			expected: fmt.Errorf("quiet hours must be from 0 to 23"),
+		},
+		{
+			name:            "invalid blunderbuss sig reminder thresholds",
+			tichiWebURL:     "https://tichiWebURL",
+			commandHelpLink: "https://commandHelpLink",
+			prProcessLink:   "https://prProcessLink",
+			lgtm: &TiCommunityLgtm{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				ReviewActsAsLgtm:   true,
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			merge: &TiCommunityMerge{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			owners: &TiCommunityOwners{
+				Repos:       []string{"tidb-community-bots/test-dev"},
+				SigEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			autoresponders: &TiCommunityAutoresponder{
+				Repos: []string{"tidb-community-bots/test-dev"},
+				AutoResponds: []AutoRespond{
+					{
+						Regex:   `(?mi)^/merge\s*$`,
+						Message: "/run-all-test",
+					},
+				},
+			},
+			blunderbuss: &TiCommunityBlunderbuss{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				MaxReviewerCount:   2,
+				ExcludeReviewers:   []string{},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+				ReviewerSelection:  ReviewerSelectionRandom,
+				ReviewReminder: &ReviewReminder{
+					RemindAfter: 24,
+					Sigs:        []SigReviewReminder{{Sig: "planner", EscalateAfter: -1}},
+				},
+			},
+			labelBlocker: &TiCommunityLabelBlocker{
+				Repos: []string{"ti-community-infra/test-dev"},
+				BlockLabels: []BlockLabel{
+					{
+						Regex:        `^status/can-merge$`,
+						Actions:      []string{"labeled", "unlabeled"},
+						TrustedTeams: []string{"release-team"},
+						TrustedUsers: []string{"ti-chi-bot"},
+					},
+				},
+			},
+			expected: fmt.Errorf("review reminder thresholds of sig planner must not less than 0"),
+		},
 		{
 			name:            "invalid tichiWebURL",
 			tichiWebURL:     "https//tichiWebURL",
@@ -445,12 +745,524 @@ func TestValidateConfig(t *testing.T) {
 			prProcessLink:   "https://prProcessLink",
 			lgtm: &TiCommunityLgtm{
 				Repos:              []string{"ti-community-infra/test-dev"},
-				ReviewActsAsLgtm:   true,
+				ReviewActsAsLgtm:   true,
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			merge: &TiCommunityMerge{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			owners: &TiCommunityOwners{
+				Repos:       []string{"ti-community-infra/test-dev"},
+				SigEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			autoresponders: &TiCommunityAutoresponder{
+				Repos: []string{"ti-community-infra/test-dev"},
+				AutoResponds: []AutoRespond{
+					{
+						Regex:   `(?mi)^/merge\s*$`,
+						Message: "/run-all-test",
+					},
+				},
+			},
+			blunderbuss: &TiCommunityBlunderbuss{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				MaxReviewerCount:   2,
+				ExcludeReviewers:   []string{},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			labelBlocker: &TiCommunityLabelBlocker{
+				Repos: []string{"ti-community-infra/test-dev"},
+				BlockLabels: []BlockLabel{
+					{
+						Regex:        `^status/can-merge$`,
+						Actions:      []string{"labeled", "unlabeled"},
+						TrustedTeams: []string{"release-team"},
+						TrustedUsers: []string{"ti-chi-bot"},
+					},
+				},
+			},
+			expected: fmt.Errorf("parse \"https//tichiWebURL\": invalid URI for request"),
+		},
+		{
+			name:            "invalid prProcessLink",
+			tichiWebURL:     "https://tichiWebURL",
+			prProcessLink:   "https//prProcessLink",
+			commandHelpLink: "https://commandHelpLink",
+			lgtm: &TiCommunityLgtm{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				ReviewActsAsLgtm:   true,
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			merge: &TiCommunityMerge{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			owners: &TiCommunityOwners{
+				Repos:       []string{"ti-community-infra/test-dev"},
+				SigEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			autoresponders: &TiCommunityAutoresponder{
+				Repos: []string{"ti-community-infra/test-dev"},
+				AutoResponds: []AutoRespond{
+					{
+						Regex:   `(?mi)^/merge\s*$`,
+						Message: "/run-all-test",
+					},
+				},
+			},
+			blunderbuss: &TiCommunityBlunderbuss{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				MaxReviewerCount:   2,
+				ExcludeReviewers:   []string{},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			labelBlocker: &TiCommunityLabelBlocker{
+				Repos: []string{"ti-community-infra/test-dev"},
+				BlockLabels: []BlockLabel{
+					{
+						Regex:        `^status/can-merge$`,
+						Actions:      []string{"labeled", "unlabeled"},
+						TrustedTeams: []string{"release-team"},
+						TrustedUsers: []string{"ti-chi-bot"},
+					},
+				},
+			},
+			expected: fmt.Errorf("parse \"https//prProcessLink\": invalid URI for request"),
+		},
+		{
+			name:            "invalid commandHelpLink",
+			tichiWebURL:     "https://tichiWebURL",
+			prProcessLink:   "https://prProcessLink",
+			commandHelpLink: "https//commandHelpLink",
+			lgtm: &TiCommunityLgtm{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				ReviewActsAsLgtm:   true,
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			merge: &TiCommunityMerge{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			owners: &TiCommunityOwners{
+				Repos:       []string{"ti-community-infra/test-dev"},
+				SigEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			autoresponders: &TiCommunityAutoresponder{
+				Repos: []string{"ti-community-infra/test-dev"},
+				AutoResponds: []AutoRespond{
+					{
+						Regex:   `(?mi)^/merge\s*$`,
+						Message: "/run-all-test",
+					},
+				},
+			},
+			blunderbuss: &TiCommunityBlunderbuss{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				MaxReviewerCount:   2,
+				ExcludeReviewers:   []string{},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			labelBlocker: &TiCommunityLabelBlocker{
+				Repos: []string{"ti-community-infra/test-dev"},
+				BlockLabels: []BlockLabel{
+					{
+						Regex:        `^status/can-merge$`,
+						Actions:      []string{"labeled", "unlabeled"},
+						TrustedTeams: []string{"release-team"},
+						TrustedUsers: []string{"ti-chi-bot"},
+					},
+				},
+			},
+			expected: fmt.Errorf("parse \"https//commandHelpLink\": invalid URI for request"),
+		},
+		{
+			name:            "invalid label blocker regex",
+			tichiWebURL:     "https://tichiWebURL",
+			commandHelpLink: "https://commandHelpLink",
+			prProcessLink:   "https://prProcessLink",
+			lgtm: &TiCommunityLgtm{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				ReviewActsAsLgtm:   true,
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			merge: &TiCommunityMerge{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			owners: &TiCommunityOwners{
+				Repos:       []string{"ti-community-infra/test-dev"},
+				SigEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			autoresponders: &TiCommunityAutoresponder{
+				Repos: []string{"ti-community-infra/test-dev"},
+				AutoResponds: []AutoRespond{
+					{
+						Regex:   `(?mi)^/merge\s*$`,
+						Message: "/run-all-test",
+					},
+				},
+			},
+			blunderbuss: &TiCommunityBlunderbuss{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				MaxReviewerCount:   2,
+				ExcludeReviewers:   []string{},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			labelBlocker: &TiCommunityLabelBlocker{
+				Repos: []string{"ti-community-infra/test-dev"},
+				BlockLabels: []BlockLabel{
+					{
+						Regex:   "?[)",
+						Actions: []string{"labeled", "unlabeled"},
+					},
+				},
+			},
+			expected: fmt.Errorf("error parsing regexp: missing argument to repetition operator: `?`"),
+		},
+		{
+			name:            "invalid empty actions",
+			tichiWebURL:     "https://tichiWebURL",
+			commandHelpLink: "https://commandHelpLink",
+			prProcessLink:   "https://prProcessLink",
+			lgtm: &TiCommunityLgtm{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				ReviewActsAsLgtm:   true,
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			merge: &TiCommunityMerge{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			owners: &TiCommunityOwners{
+				Repos:       []string{"ti-community-infra/test-dev"},
+				SigEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			autoresponders: &TiCommunityAutoresponder{
+				Repos: []string{"ti-community-infra/test-dev"},
+				AutoResponds: []AutoRespond{
+					{
+						Regex:   `(?mi)^/merge\s*$`,
+						Message: "/run-all-test",
+					},
+				},
+			},
+			blunderbuss: &TiCommunityBlunderbuss{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				MaxReviewerCount:   2,
+				ExcludeReviewers:   []string{},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			labelBlocker: &TiCommunityLabelBlocker{
+				Repos: []string{"ti-community-infra/test-dev"},
+				BlockLabels: []BlockLabel{
+					{
+						Regex:   `^status/can-merge$`,
+						Actions: []string{},
+					},
+				},
+			},
+			expected: fmt.Errorf("there must be at least one action"),
+		},
+		{
+			name:            "invalid action value",
+			tichiWebURL:     "https://tichiWebURL",
+			commandHelpLink: "https://commandHelpLink",
+			prProcessLink:   "https://prProcessLink",
+			lgtm: &TiCommunityLgtm{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				ReviewActsAsLgtm:   true,
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			merge: &TiCommunityMerge{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			owners: &TiCommunityOwners{
+				Repos:       []string{"ti-community-infra/test-dev"},
+				SigEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			autoresponders: &TiCommunityAutoresponder{
+				Repos: []string{"ti-community-infra/test-dev"},
+				AutoResponds: []AutoRespond{
+					{
+						Regex:   `(?mi)^/merge\s*$`,
+						Message: "/run-all-test",
+					},
+				},
+			},
+			blunderbuss: &TiCommunityBlunderbuss{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				MaxReviewerCount:   2,
+				ExcludeReviewers:   []string{},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			labelBlocker: &TiCommunityLabelBlocker{
+				Repos: []string{"ti-community-infra/test-dev"},
+				BlockLabels: []BlockLabel{
+					{
+						Regex:   `^status/can-merge$`,
+						Actions: []string{"nop"},
+					},
+				},
+			},
+			expected: fmt.Errorf("actions contain illegal value nop"),
+		},
+		{
+			name:            "invalid lgtm push reset policy",
+			tichiWebURL:     "https://tichiWebURL",
+			commandHelpLink: "https://commandHelpLink",
+			prProcessLink:   "https://prProcessLink",
+			lgtm: &TiCommunityLgtm{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+				PushResetPolicy:    "nop",
+			},
+			merge: &TiCommunityMerge{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			owners: &TiCommunityOwners{
+				Repos:       []string{"ti-community-infra/test-dev"},
+				SigEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			autoresponders: &TiCommunityAutoresponder{
+				Repos: []string{"ti-community-infra/test-dev"},
+			},
+			blunderbuss: &TiCommunityBlunderbuss{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				MaxReviewerCount:   2,
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			labelBlocker: &TiCommunityLabelBlocker{
+				Repos: []string{"ti-community-infra/test-dev"},
+			},
+			expected: fmt.Errorf("push reset policy contains illegal value nop"),
+		},
+		{
+			name:            "merge label when checks pass without required contexts",
+			tichiWebURL:     "https://tichiWebURL",
+			commandHelpLink: "https://commandHelpLink",
+			prProcessLink:   "https://prProcessLink",
+			lgtm: &TiCommunityLgtm{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			merge: &TiCommunityMerge{
+				Repos:               []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint:  "https://bots.tidb.io/ti-community-bot",
+				LabelWhenChecksPass: true,
+			},
+			owners: &TiCommunityOwners{
+				Repos:       []string{"ti-community-infra/test-dev"},
+				SigEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			autoresponders: &TiCommunityAutoresponder{
+				Repos: []string{"ti-community-infra/test-dev"},
+			},
+			blunderbuss: &TiCommunityBlunderbuss{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				MaxReviewerCount:   2,
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			labelBlocker: &TiCommunityLabelBlocker{
+				Repos: []string{"ti-community-infra/test-dev"},
+			},
+			expected: fmt.Errorf("label when checks pass requires the required contexts"),
+		},
+		{
+			name:            "merge queue without required contexts",
+			tichiWebURL:     "https://tichiWebURL",
+			commandHelpLink: "https://commandHelpLink",
+			prProcessLink:   "https://prProcessLink",
+			lgtm: &TiCommunityLgtm{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			merge: &TiCommunityMerge{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+				MergeQueue:         true,
+			},
+			owners: &TiCommunityOwners{
+				Repos:       []string{"ti-community-infra/test-dev"},
+				SigEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			autoresponders: &TiCommunityAutoresponder{
+				Repos: []string{"ti-community-infra/test-dev"},
+			},
+			blunderbuss: &TiCommunityBlunderbuss{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				MaxReviewerCount:   2,
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			labelBlocker: &TiCommunityLabelBlocker{
+				Repos: []string{"ti-community-infra/test-dev"},
+			},
+			expected: fmt.Errorf("merge queue requires the required contexts"),
+		},
+		{
+			name:            "unsupported merge method",
+			tichiWebURL:     "https://tichiWebURL",
+			commandHelpLink: "https://commandHelpLink",
+			prProcessLink:   "https://prProcessLink",
+			lgtm: &TiCommunityLgtm{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			merge: &TiCommunityMerge{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+				MergeMethods:       []string{"squash", "fast-forward"},
+			},
+			owners: &TiCommunityOwners{
+				Repos:       []string{"ti-community-infra/test-dev"},
+				SigEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			autoresponders: &TiCommunityAutoresponder{
+				Repos: []string{"ti-community-infra/test-dev"},
+			},
+			blunderbuss: &TiCommunityBlunderbuss{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				MaxReviewerCount:   2,
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			labelBlocker: &TiCommunityLabelBlocker{
+				Repos: []string{"ti-community-infra/test-dev"},
+			},
+			expected: fmt.Errorf("unsupported merge method fast-forward"),
+		},
+		{
+			name:            "freeze with invalid tracking issue",
+			tichiWebURL:     "https://tichiWebURL",
+			commandHelpLink: "https://commandHelpLink",
+			prProcessLink:   "https://prProcessLink",
+			lgtm: &TiCommunityLgtm{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			merge: &TiCommunityMerge{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+				Freezes: []MergeFreeze{
+					{
+						Name:          "v5.0",
+						Branches:      []string{"release-.*"},
+						TrackingIssue: "ti-community-infra/test-dev/1",
+						FreezeLabel:   "code-freeze",
+						TrustedTeams:  []string{"release-team"},
+					},
+				},
+			},
+			owners: &TiCommunityOwners{
+				Repos:       []string{"ti-community-infra/test-dev"},
+				SigEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			autoresponders: &TiCommunityAutoresponder{
+				Repos: []string{"ti-community-infra/test-dev"},
+			},
+			blunderbuss: &TiCommunityBlunderbuss{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				MaxReviewerCount:   2,
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			labelBlocker: &TiCommunityLabelBlocker{
+				Repos: []string{"ti-community-infra/test-dev"},
+			},
+			expected: fmt.Errorf("freeze v5.0: invalid tracking issue \"ti-community-infra/test-dev/1\""),
+		},
+		{
+			name:            "freeze label without trusted teams",
+			tichiWebURL:     "https://tichiWebURL",
+			commandHelpLink: "https://commandHelpLink",
+			prProcessLink:   "https://prProcessLink",
+			lgtm: &TiCommunityLgtm{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			merge: &TiCommunityMerge{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+				Freezes: []MergeFreeze{
+					{
+						Name:          "v5.0",
+						Branches:      []string{"release-.*"},
+						TrackingIssue: "ti-community-infra/test-dev#1",
+						FreezeLabel:   "code-freeze",
+					},
+				},
+			},
+			owners: &TiCommunityOwners{
+				Repos:       []string{"ti-community-infra/test-dev"},
+				SigEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			autoresponders: &TiCommunityAutoresponder{
+				Repos: []string{"ti-community-infra/test-dev"},
+			},
+			blunderbuss: &TiCommunityBlunderbuss{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				MaxReviewerCount:   2,
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			labelBlocker: &TiCommunityLabelBlocker{
+				Repos: []string{"ti-community-infra/test-dev"},
+			},
+			expected: fmt.Errorf("freeze v5.0 requires the trusted teams to use the freeze label"),
+		},
+		{
+			name:            "freeze never starts",
+			tichiWebURL:     "https://tichiWebURL",
+			commandHelpLink: "https://commandHelpLink",
+			prProcessLink:   "https://prProcessLink",
+			lgtm: &TiCommunityLgtm{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			merge: &TiCommunityMerge{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+				Freezes: []MergeFreeze{
+					{
+						Name:          "v5.0",
+						Branches:      []string{"release-.*"},
+						TrackingIssue: "ti-community-infra/test-dev#1",
+					},
+				},
+			},
+			owners: &TiCommunityOwners{
+				Repos:       []string{"ti-community-infra/test-dev"},
+				SigEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			autoresponders: &TiCommunityAutoresponder{
+				Repos: []string{"ti-community-infra/test-dev"},
+			},
+			blunderbuss: &TiCommunityBlunderbuss{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				MaxReviewerCount:   2,
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			labelBlocker: &TiCommunityLabelBlocker{
+				Repos: []string{"ti-community-infra/test-dev"},
+			},
+			expected: fmt.Errorf("freeze v5.0 requires the start time or the freeze label"),
+		},
+		{
+			name:            "invalid squash commit template",
+			tichiWebURL:     "https://tichiWebURL",
+			commandHelpLink: "https://commandHelpLink",
+			prProcessLink:   "https://prProcessLink",
+			lgtm: &TiCommunityLgtm{
+				Repos:              []string{"ti-community-infra/test-dev"},
 				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
 			},
 			merge: &TiCommunityMerge{
 				Repos:              []string{"ti-community-infra/test-dev"},
 				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+				RequireContexts:    []string{"unit-test"},
+				MergeQueue:         true,
+				SquashCommitTemplate: &CommitMessageTemplate{
+					Title: "{{ .Title }",
+				},
 			},
 			owners: &TiCommunityOwners{
 				Repos:       []string{"ti-community-infra/test-dev"},
@@ -458,45 +1270,32 @@ func TestValidateConfig(t *testing.T) {
 			},
 			autoresponders: &TiCommunityAutoresponder{
 				Repos: []string{"ti-community-infra/test-dev"},
-				AutoResponds: []AutoRespond{
-					{
-						Regex:   `(?mi)^/merge\s*$`,
-						Message: "/run-all-test",
-					},
-				},
 			},
 			blunderbuss: &TiCommunityBlunderbuss{
 				Repos:              []string{"ti-community-infra/test-dev"},
 				MaxReviewerCount:   2,
-				ExcludeReviewers:   []string{},
 				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
 			},
 			labelBlocker: &TiCommunityLabelBlocker{
 				Repos: []string{"ti-community-infra/test-dev"},
-				BlockLabels: []BlockLabel{
-					{
-						Regex:        `^status/can-merge$`,
-						Actions:      []string{"labeled", "unlabeled"},
-						TrustedTeams: []string{"release-team"},
-						TrustedUsers: []string{"ti-chi-bot"},
-					},
-				},
 			},
-			expected: fmt.Errorf("parse \"https//tichiWebURL\": invalid URI for request"),
+			expected: fmt.Errorf("invalid commit title template: template: title:1: unexpected \"}\" in operand"),
 		},
 		{
-			name:            "invalid prProcessLink",
+			name:            "squash commit template without the merge queue",
 			tichiWebURL:     "https://tichiWebURL",
-			prProcessLink:   "https//prProcessLink",
 			commandHelpLink: "https://commandHelpLink",
+			prProcessLink:   "https://prProcessLink",
 			lgtm: &TiCommunityLgtm{
 				Repos:              []string{"ti-community-infra/test-dev"},
-				ReviewActsAsLgtm:   true,
 				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
 			},
 			merge: &TiCommunityMerge{
 				Repos:              []string{"ti-community-infra/test-dev"},
 				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+				SquashCommitTemplate: &CommitMessageTemplate{
+					Title: "{{ .Title }} (#{{ .Number }})",
+				},
 			},
 			owners: &TiCommunityOwners{
 				Repos:       []string{"ti-community-infra/test-dev"},
@@ -504,41 +1303,26 @@ func TestValidateConfig(t *testing.T) {
 			},
 			autoresponders: &TiCommunityAutoresponder{
 				Repos: []string{"ti-community-infra/test-dev"},
-				AutoResponds: []AutoRespond{
-					{
-						Regex:   `(?mi)^/merge\s*$`,
-						Message: "/run-all-test",
-					},
-				},
 			},
 			blunderbuss: &TiCommunityBlunderbuss{
 				Repos:              []string{"ti-community-infra/test-dev"},
 				MaxReviewerCount:   2,
-				ExcludeReviewers:   []string{},
 				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
 			},
 			labelBlocker: &TiCommunityLabelBlocker{
 				Repos: []string{"ti-community-infra/test-dev"},
-				BlockLabels: []BlockLabel{
-					{
-						Regex:        `^status/can-merge$`,
-						Actions:      []string{"labeled", "unlabeled"},
-						TrustedTeams: []string{"release-team"},
-						TrustedUsers: []string{"ti-chi-bot"},
-					},
-				},
 			},
-			expected: fmt.Errorf("parse \"https//prProcessLink\": invalid URI for request"),
+			expected: fmt.Errorf("squash commit template requires the merge queue"),
 		},
 		{
-			name:            "invalid commandHelpLink",
+			name:            "invalid lgtm role weights",
 			tichiWebURL:     "https://tichiWebURL",
+			commandHelpLink: "https://commandHelpLink",
 			prProcessLink:   "https://prProcessLink",
-			commandHelpLink: "https//commandHelpLink",
 			lgtm: &TiCommunityLgtm{
 				Repos:              []string{"ti-community-infra/test-dev"},
-				ReviewActsAsLgtm:   true,
 				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+				RoleWeights:        map[string]int{"nop": 2},
 			},
 			merge: &TiCommunityMerge{
 				Repos:              []string{"ti-community-infra/test-dev"},
@@ -550,41 +1334,26 @@ func TestValidateConfig(t *testing.T) {
 			},
 			autoresponders: &TiCommunityAutoresponder{
 				Repos: []string{"ti-community-infra/test-dev"},
-				AutoResponds: []AutoRespond{
-					{
-						Regex:   `(?mi)^/merge\s*$`,
-						Message: "/run-all-test",
-					},
-				},
 			},
 			blunderbuss: &TiCommunityBlunderbuss{
 				Repos:              []string{"ti-community-infra/test-dev"},
 				MaxReviewerCount:   2,
-				ExcludeReviewers:   []string{},
 				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
 			},
 			labelBlocker: &TiCommunityLabelBlocker{
 				Repos: []string{"ti-community-infra/test-dev"},
-				BlockLabels: []BlockLabel{
-					{
-						Regex:        `^status/can-merge$`,
-						Actions:      []string{"labeled", "unlabeled"},
-						TrustedTeams: []string{"release-team"},
-						TrustedUsers: []string{"ti-chi-bot"},
-					},
-				},
 			},
-			expected: fmt.Errorf("parse \"https//commandHelpLink\": invalid URI for request"),
+			expected: fmt.Errorf("role weights contain illegal role nop"),
 		},
 		{
-			name:            "invalid label blocker regex",
+			name:            "zero lgtm role weight",
 			tichiWebURL:     "https://tichiWebURL",
 			commandHelpLink: "https://commandHelpLink",
 			prProcessLink:   "https://prProcessLink",
 			lgtm: &TiCommunityLgtm{
 				Repos:              []string{"ti-community-infra/test-dev"},
-				ReviewActsAsLgtm:   true,
 				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+				RoleWeights:        map[string]int{"reviewer": 0},
 			},
 			merge: &TiCommunityMerge{
 				Repos:              []string{"ti-community-infra/test-dev"},
@@ -596,39 +1365,26 @@ func TestValidateConfig(t *testing.T) {
 			},
 			autoresponders: &TiCommunityAutoresponder{
 				Repos: []string{"ti-community-infra/test-dev"},
-				AutoResponds: []AutoRespond{
-					{
-						Regex:   `(?mi)^/merge\s*$`,
-						Message: "/run-all-test",
-					},
-				},
 			},
 			blunderbuss: &TiCommunityBlunderbuss{
 				Repos:              []string{"ti-community-infra/test-dev"},
 				MaxReviewerCount:   2,
-				ExcludeReviewers:   []string{},
 				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
 			},
 			labelBlocker: &TiCommunityLabelBlocker{
 				Repos: []string{"ti-community-infra/test-dev"},
-				BlockLabels: []BlockLabel{
-					{
-						Regex:   "?[)",
-						Actions: []string{"labeled", "unlabeled"},
-					},
-				},
 			},
-			expected: fmt.Errorf("error parsing regexp: missing argument to repetition operator: `?`"),
+			expected: fmt.Errorf("weight of role reviewer must be positive"),
 		},
 		{
-			name:            "invalid empty actions",
+			name:            "invalid lgtm required committer lgtm",
 			tichiWebURL:     "https://tichiWebURL",
 			commandHelpLink: "https://commandHelpLink",
 			prProcessLink:   "https://prProcessLink",
 			lgtm: &TiCommunityLgtm{
-				Repos:              []string{"ti-community-infra/test-dev"},
-				ReviewActsAsLgtm:   true,
-				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+				Repos:                 []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint:    "https://bots.tidb.io/ti-community-bot",
+				RequiredCommitterLgtm: -1,
 			},
 			merge: &TiCommunityMerge{
 				Repos:              []string{"ti-community-infra/test-dev"},
@@ -640,39 +1396,26 @@ func TestValidateConfig(t *testing.T) {
 			},
 			autoresponders: &TiCommunityAutoresponder{
 				Repos: []string{"ti-community-infra/test-dev"},
-				AutoResponds: []AutoRespond{
-					{
-						Regex:   `(?mi)^/merge\s*$`,
-						Message: "/run-all-test",
-					},
-				},
 			},
 			blunderbuss: &TiCommunityBlunderbuss{
 				Repos:              []string{"ti-community-infra/test-dev"},
 				MaxReviewerCount:   2,
-				ExcludeReviewers:   []string{},
 				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
 			},
 			labelBlocker: &TiCommunityLabelBlocker{
 				Repos: []string{"ti-community-infra/test-dev"},
-				BlockLabels: []BlockLabel{
-					{
-						Regex:   `^status/can-merge$`,
-						Actions: []string{},
-					},
-				},
 			},
-			expected: fmt.Errorf("there must be at least one action"),
+			expected: fmt.Errorf("required committer lgtm cannot be negative"),
 		},
 		{
-			name:            "invalid action value",
+			name:            "invalid lgtm required affiliations",
 			tichiWebURL:     "https://tichiWebURL",
 			commandHelpLink: "https://commandHelpLink",
 			prProcessLink:   "https://prProcessLink",
 			lgtm: &TiCommunityLgtm{
-				Repos:              []string{"ti-community-infra/test-dev"},
-				ReviewActsAsLgtm:   true,
-				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+				Repos:                []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint:   "https://bots.tidb.io/ti-community-bot",
+				RequiredAffiliations: -1,
 			},
 			merge: &TiCommunityMerge{
 				Repos:              []string{"ti-community-infra/test-dev"},
@@ -684,29 +1427,16 @@ func TestValidateConfig(t *testing.T) {
 			},
 			autoresponders: &TiCommunityAutoresponder{
 				Repos: []string{"ti-community-infra/test-dev"},
-				AutoResponds: []AutoRespond{
-					{
-						Regex:   `(?mi)^/merge\s*$`,
-						Message: "/run-all-test",
-					},
-				},
 			},
 			blunderbuss: &TiCommunityBlunderbuss{
 				Repos:              []string{"ti-community-infra/test-dev"},
 				MaxReviewerCount:   2,
-				ExcludeReviewers:   []string{},
 				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
 			},
 			labelBlocker: &TiCommunityLabelBlocker{
 				Repos: []string{"ti-community-infra/test-dev"},
-				BlockLabels: []BlockLabel{
-					{
-						Regex:   `^status/can-merge$`,
-						Actions: []string{"nop"},
-					},
-				},
 			},
-			expected: fmt.Errorf("actions contain illegal value nop"),
+			expected: fmt.Errorf("required affiliations cannot be negative"),
 		},
 	}
 
@@ -1143,17 +1873,66 @@ func TestSetBlunderbussDefaults(t *testing.T) {
 	testcases := []struct {
 		name                      string
 		gracePeriodDuration       int
+		reviewerSelection         string
+		reviewReminder            *ReviewReminder
+		firstTimeContributor      *FirstTimeContributor
 		expectGracePeriodDuration int
+		expectReviewerSelection   string
+		expectReviewReminder      *ReviewReminder
+		expectFirstTimeLabel      string
 	}{
 		{
 			name:                      "default",
 			gracePeriodDuration:       0,
 			expectGracePeriodDuration: 5,
+			expectReviewerSelection:   ReviewerSelectionRandom,
 		},
 		{
 			name:                      "overwrite",
 			gracePeriodDuration:       3,
+			reviewerSelection:         ReviewerSelectionLoad,
 			expectGracePeriodDuration: 3,
+			expectReviewerSelection:   ReviewerSelectionLoad,
+		},
+		{
+			name:                      "default review reminder",
+			reviewReminder:            &ReviewReminder{RemindAfter: 24},
+			expectGracePeriodDuration: 5,
+			expectReviewerSelection:   ReviewerSelectionRandom,
+			expectReviewReminder: &ReviewReminder{
+				RemindAfter:             24,
+				EscalationReviewerCount: 1,
+				AttentionLabel:          "needs-review-attention",
+			},
+		},
+		{
+			name: "overwrite review reminder",
+			reviewReminder: &ReviewReminder{
+				RemindAfter:             24,
+				EscalationReviewerCount: 2,
+				AttentionLabel:          "status/needs-attention",
+			},
+			expectGracePeriodDuration: 5,
+			expectReviewerSelection:   ReviewerSelectionRandom,
+			expectReviewReminder: &ReviewReminder{
+				RemindAfter:             24,
+				EscalationReviewerCount: 2,
+				AttentionLabel:          "status/needs-attention",
+			},
+		},
+		{
+			name:                      "default first-time contributor label",
+			firstTimeContributor:      &FirstTimeContributor{Mentors: []string{"mentor1"}},
+			expectGracePeriodDuration: 5,
+			expectReviewerSelection:   ReviewerSelectionRandom,
+			expectFirstTimeLabel:      "first-time-contributor",
+		},
+		{
+			name:                      "overwrite first-time contributor label",
+			firstTimeContributor:      &FirstTimeContributor{Mentors: []string{"mentor1"}, Label: "newcomer"},
+			expectGracePeriodDuration: 5,
+			expectReviewerSelection:   ReviewerSelectionRandom,
+			expectFirstTimeLabel:      "newcomer",
 		},
 	}
 
@@ -1163,7 +1942,10 @@ func TestSetBlunderbussDefaults(t *testing.T) {
 			c := &Configuration{
 				TiCommunityBlunderbuss: []TiCommunityBlunderbuss{
 					{
-						GracePeriodDuration: tc.gracePeriodDuration,
+						GracePeriodDuration:  tc.gracePeriodDuration,
+						ReviewerSelection:    tc.reviewerSelection,
+						ReviewReminder:       tc.reviewReminder,
+						FirstTimeContributor: tc.firstTimeContributor,
 					},
 				},
 			}
@@ -1175,7 +1957,230 @@ func TestSetBlunderbussDefaults(t *testing.T) {
 					t.Errorf("unexpected grace_period_duration: %v, expected: %v",
 						blunderbuss.GracePeriodDuration, tc.expectGracePeriodDuration)
 				}
+				if blunderbuss.ReviewerSelection != tc.expectReviewerSelection {
+					t.Errorf("unexpected reviewer_selection: %v, expected: %v",
+						blunderbuss.ReviewerSelection, tc.expectReviewerSelection)
+				}
+				if !reflect.DeepEqual(blunderbuss.ReviewReminder, tc.expectReviewReminder) {
+					t.Errorf("unexpected review_reminder: %v, expected: %v",
+						blunderbuss.ReviewReminder, tc.expectReviewReminder)
+				}
+				if blunderbuss.FirstTimeContributor != nil &&
+					blunderbuss.FirstTimeContributor.Label != tc.expectFirstTimeLabel {
+					t.Errorf("unexpected first_time_contributor label: %v, expected: %v",
+						blunderbuss.FirstTimeContributor.Label, tc.expectFirstTimeLabel)
+				}
+			}
+		})
+	}
+}
+
+func TestQuietHoursContains(t *testing.T) {
+	testcases := []struct {
+		name       string
+		quietHours QuietHours
+		now        time.Time
+
+		expectContains bool
+	}{
+		{
+			name:           "in the quiet hours",
+			quietHours:     QuietHours{Start: 1, End: 8},
+			now:            time.Date(2021, 6, 1, 3, 0, 0, 0, time.UTC),
+			expectContains: true,
+		},
+		{
+			name:           "the end is excluded",
+			quietHours:     QuietHours{Start: 1, End: 8},
+			now:            time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC),
+			expectContains: false,
+		},
+		{
+			name:           "cross midnight",
+			quietHours:     QuietHours{Start: 22, End: 8},
+			now:            time.Date(2021, 6, 1, 23, 0, 0, 0, time.UTC),
+			expectContains: true,
+		},
+		{
+			name:           "out of the quiet hours crossing midnight",
+			quietHours:     QuietHours{Start: 22, End: 8},
+			now:            time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC),
+			expectContains: false,
+		},
+		{
+			name:           "time zone",
+			quietHours:     QuietHours{Start: 22, End: 8, TimeZone: "Asia/Shanghai"},
+			now:            time.Date(2021, 6, 1, 15, 0, 0, 0, time.UTC),
+			expectContains: true,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			if contains := tc.quietHours.Contains(tc.now); contains != tc.expectContains {
+				t.Errorf("contains mismatch: got %v, want %v", contains, tc.expectContains)
+			}
+		})
+	}
+}
+
+func TestReviewReminderThresholdsFor(t *testing.T) {
+	reminder := &ReviewReminder{
+		RemindAfter:   48,
+		EscalateAfter: 96,
+		Sigs: []SigReviewReminder{
+			{Sig: "planner", RemindAfter: 24},
+			{Sig: "execution", RemindAfter: 12, EscalateAfter: 72},
+			{Sig: "storage", RemindAfter: 36, EscalateAfter: 48},
+		},
+	}
+	testcases := []struct {
+		name string
+		sigs []string
+
+		expectRemindAfter   int
+		expectEscalateAfter int
+	}{
+		{
+			name:                "no sigs",
+			expectRemindAfter:   48,
+			expectEscalateAfter: 96,
+		},
+		{
+			name:                "unknown sig",
+			sigs:                []string{"docs"},
+			expectRemindAfter:   48,
+			expectEscalateAfter: 96,
+		},
+		{
+			name:                "sig overrides part of the thresholds",
+			sigs:                []string{"planner"},
+			expectRemindAfter:   24,
+			expectEscalateAfter: 96,
+		},
+		{
+			name:                "smallest thresholds of the sigs",
+			sigs:                []string{"planner", "execution", "storage"},
+			expectRemindAfter:   12,
+			expectEscalateAfter: 48,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			remindAfter, escalateAfter := reminder.ThresholdsFor(tc.sigs)
+			if remindAfter != tc.expectRemindAfter || escalateAfter != tc.expectEscalateAfter {
+				t.Errorf("thresholds mismatch: got %d %d, want %d %d",
+					remindAfter, escalateAfter, tc.expectRemindAfter, tc.expectEscalateAfter)
+			}
+		})
+	}
+}
+
+func TestFirstTimeContributorMentorsFor(t *testing.T) {
+	firstTimeContributor := &FirstTimeContributor{
+		Mentors: []string{"mentor1", "mentor2"},
+		SigMentors: []SigMentors{
+			{Sig: "planner", Mentors: []string{"planner-mentor"}},
+			{Sig: "execution", Mentors: []string{"execution-mentor", "planner-mentor"}},
+			{Sig: "docs"},
+		},
+	}
+	testcases := []struct {
+		name string
+		sigs []string
+
+		expectMentors []string
+	}{
+		{
+			name:          "no sigs",
+			expectMentors: []string{"mentor1", "mentor2"},
+		},
+		{
+			name:          "sig without mentors",
+			sigs:          []string{"docs"},
+			expectMentors: []string{"mentor1", "mentor2"},
+		},
+		{
+			name:          "mentors of the sigs",
+			sigs:          []string{"planner", "execution", "docs"},
+			expectMentors: []string{"execution-mentor", "planner-mentor"},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			mentors := firstTimeContributor.MentorsFor(tc.sigs)
+			if !reflect.DeepEqual(mentors, tc.expectMentors) {
+				t.Errorf("mentors mismatch: got %v, want %v", mentors, tc.expectMentors)
+			}
+		})
+	}
+}
+
+func TestSetMergeDefaults(t *testing.T) {
+	testcases := []struct {
+		name                      string
+		merge                     TiCommunityMerge
+		expectMaxBatchSize        int
+		expectStagingBranchPrefix string
+		expectBatchTimeout        int
+		expectMergeMethod         string
+		expectBlockingLabels      []string
+	}{
+		{
+			name:                      "default",
+			expectMaxBatchSize:        5,
+			expectStagingBranchPrefix: "tichi-merge-queue/",
+			expectBatchTimeout:        60,
+			expectMergeMethod:         "merge",
+			expectBlockingLabels:      []string{"do-not-merge/hold", "do-not-merge/work-in-progress"},
+		},
+		{
+			name: "overwrite",
+			merge: TiCommunityMerge{
+				MaxBatchSize:        1,
+				StagingBranchPrefix: "staging-",
+				BatchTimeout:        30,
+				MergeMethod:         "squash",
+
+				AutoMergeBlockingLabels: []string{},
+			},
+			expectMaxBatchSize:        1,
+			expectStagingBranchPrefix: "staging-",
+			expectBatchTimeout:        30,
+			expectMergeMethod:         "squash",
+			expectBlockingLabels:      []string{},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Configuration{
+				TiCommunityMerge: []TiCommunityMerge{tc.merge},
+			}
+
+			c.setDefaults()
+
+			merge := c.TiCommunityMerge[0]
+			if merge.MaxBatchSize != tc.expectMaxBatchSize {
+				t.Errorf("unexpected max_batch_size: %v, expected: %v", merge.MaxBatchSize, tc.expectMaxBatchSize)
 			}
+			if merge.StagingBranchPrefix != tc.expectStagingBranchPrefix {
+				t.Errorf("unexpected staging_branch_prefix: %v, expected: %v",
+					merge.StagingBranchPrefix, tc.expectStagingBranchPrefix)
+			}
+			if merge.BatchTimeout != tc.expectBatchTimeout {
+				t.Errorf("unexpected batch_timeout: %v, expected: %v", merge.BatchTimeout, tc.expectBatchTimeout)
+			}
+			if merge.MergeMethod != tc.expectMergeMethod {
+				t.Errorf("unexpected merge_method: %v, expected: %v", merge.MergeMethod, tc.expectMergeMethod)
+			}
+			assert.DeepEqual(t, merge.AutoMergeBlockingLabels, tc.expectBlockingLabels)
 		})
 	}
 }
@@ -1247,3 +2252,59 @@ func TestLabelBlockerFor(t *testing.T) {
 		})
 	}
 }
+
+func TestCherrypickerFor(t *testing.T) {
+	testcases := []struct {
+		name              string
+		cherrypicker      *TiCommunityCherrypicker
+		org               string
+		repo              string
+		expectRepos       []string
+		expectLabelPrefix string
+	}{
+		{
+			name: "Full name",
+			cherrypicker: &TiCommunityCherrypicker{
+				Repos:       []string{"ti-community-infra/test-dev"},
+				LabelPrefix: "cherry-pick/",
+			},
+			org:               "ti-community-infra",
+			repo:              "test-dev",
+			expectRepos:       []string{"ti-community-infra/test-dev"},
+			expectLabelPrefix: "cherry-pick/",
+		},
+		{
+			name: "Only org",
+			cherrypicker: &TiCommunityCherrypicker{
+				Repos: []string{"ti-community-infra"},
+			},
+			org:               "ti-community-infra",
+			repo:              "test-dev",
+			expectRepos:       []string{"ti-community-infra"},
+			expectLabelPrefix: defaultCherryPickLabelPrefix,
+		},
+		{
+			name: "Can not find",
+			cherrypicker: &TiCommunityCherrypicker{
+				Repos: []string{"ti-community-infra"},
+			},
+			org:               "ti-community-infra1",
+			repo:              "test-dev1",
+			expectLabelPrefix: defaultCherryPickLabelPrefix,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			config := Configuration{TiCommunityCherrypicker: []TiCommunityCherrypicker{
+				*tc.cherrypicker,
+			}}
+
+			cherrypicker := config.CherrypickerFor(tc.org, tc.repo)
+
+			assert.DeepEqual(t, cherrypicker.Repos, tc.expectRepos)
+			assert.DeepEqual(t, cherrypicker.LabelPrefix, tc.expectLabelPrefix)
+		})
+	}
+}
@@ -1,5 +1,14 @@
 package externalplugins
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/test-infra/prow/github"
+)
+
 const (
 	// CanMergeLabel is the name of the merge label applied by the merge plugin.
 	CanMergeLabel = "status/can-merge"
@@ -12,3 +21,189 @@ const (
 	// SigPrefix is a default sig label prefix.
 	SigPrefix = "sig/"
 )
+
+const (
+	// MergeMethodLabelPrefix is the prefix of the labels which record the merge method chosen by `/merge <method>`,
+	// the labels can be used as the squash_label, rebase_label and merge_label of Tide.
+	MergeMethodLabelPrefix = "tide/merge-method-"
+)
+
+// MergeMethods contains the supported merge methods.
+var MergeMethods = []github.PullRequestMergeType{github.MergeMerge, github.MergeSquash, github.MergeRebase}
+
+const (
+	// LgtmCountPlaceholder is the placeholder of the LGTM label format, which is replaced
+	// by the number of LGTMs.
+	LgtmCountPlaceholder = "{n}"
+	// DefaultLgtmLabel is the default format of the LGTM label.
+	DefaultLgtmLabel = LgtmLabelPrefix + LgtmCountPlaceholder
+)
+
+// LabelScheme specifies the names of the labels applied by the lgtm and merge plugins.
+type LabelScheme struct {
+	// Repos is either of the form org/repos or just org.
+	Repos []string `json:"repos,omitempty"`
+	// LgtmLabel specifies the format of the LGTM label, `{n}` in it will be replaced by the number of LGTMs,
+	// such as `status/LGT{n}`. If it does not contain `{n}`, the label is applied as long as the PR has LGTMs.
+	LgtmLabel string `json:"lgtm_label,omitempty"`
+	// LgtmLabelCountdown specifies whether `{n}` in the LGTM label is replaced by the number of LGTMs the PR
+	// still needs instead, such as `needs-{n}-lgtm`. The number stops at 0 once the PR has enough LGTMs.
+	LgtmLabelCountdown bool `json:"lgtm_label_countdown,omitempty"`
+	// CanMergeLabel specifies the name of the label which indicates the PR can be merged.
+	CanMergeLabel string `json:"can_merge_label,omitempty"`
+}
+
+// setDefaults will fill the default label names.
+func (s *LabelScheme) setDefaults() {
+	if s.LgtmLabel == "" {
+		s.LgtmLabel = DefaultLgtmLabel
+	}
+	if s.CanMergeLabel == "" {
+		s.CanMergeLabel = CanMergeLabel
+	}
+}
+
+// LabelSchemeFor finds the LabelScheme for a repo, the default scheme is returned if none is configured.
+// LabelScheme configuration can be listed for a repository or an organization.
+func (c *Configuration) LabelSchemeFor(org, repo string) *LabelScheme {
+	fullName := fmt.Sprintf("%s/%s", org, repo)
+	scheme := &LabelScheme{}
+	found := false
+	for _, s := range c.LabelSchemes {
+		if sets.NewString(s.Repos...).Has(fullName) {
+			scheme = &s
+			found = true
+			break
+		}
+	}
+	// If you don't find anything, loop again looking for an org config
+	if !found {
+		for _, s := range c.LabelSchemes {
+			if sets.NewString(s.Repos...).Has(org) {
+				scheme = &s
+				break
+			}
+		}
+	}
+
+	result := *scheme
+	result.setDefaults()
+	return &result
+}
+
+// IsLgtmLabelNumbered returns true if the LGTM label contains the number of LGTMs.
+func (s *LabelScheme) IsLgtmLabelNumbered() bool {
+	return strings.Contains(s.LgtmLabel, LgtmCountPlaceholder)
+}
+
+// LgtmLabelName returns the name of the LGTM label for the number of LGTMs, the number of required LGTMs
+// is used to count down the LGTMs the PR still needs.
+func (s *LabelScheme) LgtmLabelName(count, needsLgtm int) string {
+	if s.LgtmLabelCountdown {
+		count = needsLgtm - count
+		if count < 0 {
+			count = 0
+		}
+	}
+	return strings.Replace(s.LgtmLabel, LgtmCountPlaceholder, strconv.Itoa(count), 1)
+}
+
+// ParseLgtmLabel returns the number of LGTMs recorded by the label, the second return value is false if
+// the label is not a LGTM label. The number is always 0 if the LGTM label is not numbered. The countdown
+// label is parsed back with the number of required LGTMs.
+func (s *LabelScheme) ParseLgtmLabel(name string, needsLgtm int) (int, bool) {
+	number, ok := s.parseLgtmLabelNumber(name)
+	if !ok || !s.LgtmLabelCountdown || !s.IsLgtmLabelNumbered() {
+		return number, ok
+	}
+	// The label counted down from more required LGTMs does not record any LGTM.
+	if number > needsLgtm {
+		return 0, true
+	}
+	return needsLgtm - number, true
+}
+
+// IsLgtmLabel returns true if the label is a LGTM label.
+func (s *LabelScheme) IsLgtmLabel(name string) bool {
+	_, ok := s.parseLgtmLabelNumber(name)
+	return ok
+}
+
+// parseLgtmLabelNumber returns the number in the LGTM label, the second return value is false if
+// the label is not a LGTM label. The countdown label can contain 0 once the PR has enough LGTMs.
+func (s *LabelScheme) parseLgtmLabelNumber(name string) (int, bool) {
+	index := strings.Index(s.LgtmLabel, LgtmCountPlaceholder)
+	if index < 0 {
+		return 0, name == s.LgtmLabel
+	}
+
+	prefix := s.LgtmLabel[:index]
+	suffix := s.LgtmLabel[index+len(LgtmCountPlaceholder):]
+	if len(name) <= len(prefix)+len(suffix) || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+		return 0, false
+	}
+
+	number := name[len(prefix) : len(name)-len(suffix)]
+	for _, c := range number {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+	count, err := strconv.Atoi(number)
+	if err != nil || count < 0 || (count == 0 && !s.LgtmLabelCountdown) {
+		return 0, false
+	}
+	return count, true
+}
+
+// GetLgtmLabels returns the LGTM labels among the labels.
+func (s *LabelScheme) GetLgtmLabels(labels []github.Label) []string {
+	var lgtmLabels []string
+	for _, label := range labels {
+		if s.IsLgtmLabel(label.Name) {
+			lgtmLabels = append(lgtmLabels, label.Name)
+		}
+	}
+	return lgtmLabels
+}
+
+// MergeMethodLabel returns the label which records the merge method.
+func MergeMethodLabel(method github.PullRequestMergeType) string {
+	return MergeMethodLabelPrefix + string(method)
+}
+
+// GetMergeMethod returns the merge method recorded by the labels, the second return value is false if
+// no merge method is recorded.
+func GetMergeMethod(labels []github.Label) (github.PullRequestMergeType, bool) {
+	for _, label := range labels {
+		for _, method := range MergeMethods {
+			if label.Name == MergeMethodLabel(method) {
+				return method, true
+			}
+		}
+	}
+	return "", false
+}
+
+// validateLabelSchemes will return an error if the label names are illegal.
+func validateLabelSchemes(schemes []LabelScheme) error {
+	for _, scheme := range schemes {
+		s := scheme
+		s.setDefaults()
+
+		if strings.Count(s.LgtmLabel, LgtmCountPlaceholder) > 1 {
+			return fmt.Errorf("lgtm label %s contains more than one placeholder", s.LgtmLabel)
+		}
+		if s.LgtmLabel == LgtmCountPlaceholder {
+			return fmt.Errorf("lgtm label %s only contains the placeholder", s.LgtmLabel)
+		}
+		if s.LgtmLabelCountdown && !s.IsLgtmLabelNumbered() {
+			return fmt.Errorf("countdown lgtm label %s does not contain the placeholder", s.LgtmLabel)
+		}
+		if s.IsLgtmLabel(s.CanMergeLabel) || s.LgtmLabel == s.CanMergeLabel {
+			return fmt.Errorf("can merge label %s conflicts with lgtm label %s", s.CanMergeLabel, s.LgtmLabel)
+		}
+	}
+
+	return nil
+}
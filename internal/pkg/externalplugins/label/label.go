@@ -16,11 +16,10 @@ import (
 const PluginName = "ti-community-label"
 
 var (
-	labelRegexp             = `(?m)^/(%s)\s*(.*)$`
-	removeLabelRegexp       = `(?m)^/remove-(%s)\s*(.*)$`
-	customLabelRegex        = regexp.MustCompile(`(?m)^/label\s*(.*)$`)
-	customRemoveLabelRegex  = regexp.MustCompile(`(?m)^/remove-label\s*(.*)$`)
-	nonExistentLabelOnIssue = "Those labels are not set on the issue: `%v`"
+	labelRegexp            = `(?m)^/(%s)\s*(.*)$`
+	removeLabelRegexp      = `(?m)^/remove-(%s)\s*(.*)$`
+	customLabelRegex       = regexp.MustCompile(`(?m)^/label\s*(.*)$`)
+	customRemoveLabelRegex = regexp.MustCompile(`(?m)^/remove-label\s*(.*)$`)
 )
 
 type githubClient interface {
@@ -90,7 +89,7 @@ func HandleIssueCommentEvent(gc githubClient, ice *github.IssueCommentEvent,
 	if opts.ExcludeLabels != nil {
 		excludeLabels = opts.ExcludeLabels
 	}
-	return handle(gc, log, additionalLabels, prefixes, excludeLabels, ice)
+	return handle(gc, log, cfg, additionalLabels, prefixes, excludeLabels, ice)
 }
 
 // Get Labels from Regexp matches
@@ -125,7 +124,7 @@ func getLabelsFromGenericMatches(matches [][]string, additionalLabels []string)
 	return labels
 }
 
-func handle(gc githubClient, log *logrus.Entry, additionalLabels,
+func handle(gc githubClient, log *logrus.Entry, cfg *externalplugins.Configuration, additionalLabels,
 	prefixes, excludeLabels []string, e *github.IssueCommentEvent) error {
 	// arrange prefixes in the format "sig|kind|priority|..."
 	// so that they can be used to create labelRegex and removeLabelRegex
@@ -232,9 +231,14 @@ func handle(gc githubClient, log *logrus.Entry, additionalLabels,
 
 	// Tried to remove Labels that were not present on the Issue
 	if len(noSuchLabelsOnIssue) > 0 {
-		msg := fmt.Sprintf(nonExistentLabelOnIssue, strings.Join(noSuchLabelsOnIssue, ", "))
+		msg, err := cfg.RenderMessage(org, repo, externalplugins.MessageLabelNotSet, map[string]interface{}{
+			"labels": noSuchLabelsOnIssue,
+		})
+		if err != nil {
+			return err
+		}
 		return gc.CreateComment(org, repo, e.Issue.Number,
-			externalplugins.FormatResponseRaw(e.Comment.Body, e.Comment.HTMLURL, e.Comment.User.Login, msg))
+			cfg.FormatResponseRaw(org, repo, e.Comment.Body, e.Comment.HTMLURL, e.Comment.User.Login, msg))
 	}
 
 	return nil
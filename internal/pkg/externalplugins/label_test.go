@@ -0,0 +1,297 @@
+package externalplugins
+
+import (
+	"fmt"
+	"testing"
+
+	"k8s.io/test-infra/prow/github"
+)
+
+func TestLabelSchemeFor(t *testing.T) {
+	config := &Configuration{
+		LabelSchemes: []LabelScheme{
+			{
+				Repos:     []string{"org"},
+				LgtmLabel: "lgtm",
+			},
+			{
+				Repos:         []string{"org/repo"},
+				LgtmLabel:     "approved/{n}",
+				CanMergeLabel: "ready-to-merge",
+			},
+		},
+	}
+
+	testcases := []struct {
+		name                string
+		org                 string
+		repo                string
+		expectLgtmLabel     string
+		expectCanMergeLabel string
+	}{
+		{
+			name:                "Repo scheme",
+			org:                 "org",
+			repo:                "repo",
+			expectLgtmLabel:     "approved/{n}",
+			expectCanMergeLabel: "ready-to-merge",
+		},
+		{
+			name:                "Org scheme with default can merge label",
+			org:                 "org",
+			repo:                "repo2",
+			expectLgtmLabel:     "lgtm",
+			expectCanMergeLabel: CanMergeLabel,
+		},
+		{
+			name:                "Default scheme",
+			org:                 "org2",
+			repo:                "repo",
+			expectLgtmLabel:     DefaultLgtmLabel,
+			expectCanMergeLabel: CanMergeLabel,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			scheme := config.LabelSchemeFor(tc.org, tc.repo)
+			if scheme.LgtmLabel != tc.expectLgtmLabel {
+				t.Errorf("lgtm label mismatch: got %s, want %s", scheme.LgtmLabel, tc.expectLgtmLabel)
+			}
+			if scheme.CanMergeLabel != tc.expectCanMergeLabel {
+				t.Errorf("can merge label mismatch: got %s, want %s", scheme.CanMergeLabel, tc.expectCanMergeLabel)
+			}
+		})
+	}
+}
+
+func TestParseLgtmLabel(t *testing.T) {
+	testcases := []struct {
+		name        string
+		lgtmLabel   string
+		countdown   bool
+		label       string
+		expectCount int
+		expectOk    bool
+	}{
+		{
+			name:        "Default label",
+			lgtmLabel:   DefaultLgtmLabel,
+			label:       "status/LGT2",
+			expectCount: 2,
+			expectOk:    true,
+		},
+		{
+			name:        "Default label with multiple digits",
+			lgtmLabel:   DefaultLgtmLabel,
+			label:       "status/LGT12",
+			expectCount: 12,
+			expectOk:    true,
+		},
+		{
+			name:      "Label similar to default label",
+			lgtmLabel: DefaultLgtmLabel,
+			label:     "status/LGTM",
+		},
+		{
+			name:      "Label containing the prefix",
+			lgtmLabel: DefaultLgtmLabel,
+			label:     "needs/status/LGT1",
+		},
+		{
+			name:      "Zero LGTM",
+			lgtmLabel: DefaultLgtmLabel,
+			label:     "status/LGT0",
+		},
+		{
+			name:      "Signed number",
+			lgtmLabel: DefaultLgtmLabel,
+			label:     "status/LGT+1",
+		},
+		{
+			name:        "Label with suffix",
+			lgtmLabel:   "approved-by-{n}-reviewers",
+			label:       "approved-by-3-reviewers",
+			expectCount: 3,
+			expectOk:    true,
+		},
+		{
+			name:      "Label with wrong suffix",
+			lgtmLabel: "approved-by-{n}-reviewers",
+			label:     "approved-by-3-committers",
+		},
+		{
+			name:      "Label without number",
+			lgtmLabel: "lgtm",
+			label:     "lgtm",
+			expectOk:  true,
+		},
+		{
+			name:      "Other label without number",
+			lgtmLabel: "lgtm",
+			label:     "lgtm2",
+		},
+		{
+			name:        "Countdown label",
+			lgtmLabel:   "needs-{n}-lgtm",
+			countdown:   true,
+			label:       "needs-1-lgtm",
+			expectCount: 1,
+			expectOk:    true,
+		},
+		{
+			name:        "Countdown label with enough LGTMs",
+			lgtmLabel:   "needs-{n}-lgtm",
+			countdown:   true,
+			label:       "needs-0-lgtm",
+			expectCount: 2,
+			expectOk:    true,
+		},
+		{
+			name:      "Countdown label from more required LGTMs",
+			lgtmLabel: "needs-{n}-lgtm",
+			countdown: true,
+			label:     "needs-3-lgtm",
+			expectOk:  true,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			scheme := &LabelScheme{LgtmLabel: tc.lgtmLabel, LgtmLabelCountdown: tc.countdown}
+			// The PRs need 2 LGTMs.
+			count, ok := scheme.ParseLgtmLabel(tc.label, 2)
+			if count != tc.expectCount || ok != tc.expectOk {
+				t.Errorf("parse result mismatch: got (%d, %v), want (%d, %v)", count, ok, tc.expectCount, tc.expectOk)
+			}
+		})
+	}
+}
+
+func TestLgtmLabelName(t *testing.T) {
+	testcases := []struct {
+		name        string
+		lgtmLabel   string
+		countdown   bool
+		count       int
+		expectLabel string
+	}{
+		{
+			name:        "Default label",
+			lgtmLabel:   DefaultLgtmLabel,
+			count:       1,
+			expectLabel: "status/LGT1",
+		},
+		{
+			name:        "Countdown label",
+			lgtmLabel:   "needs-{n}-lgtm",
+			countdown:   true,
+			count:       1,
+			expectLabel: "needs-1-lgtm",
+		},
+		{
+			name:        "Countdown label stops at zero",
+			lgtmLabel:   "needs-{n}-lgtm",
+			countdown:   true,
+			count:       3,
+			expectLabel: "needs-0-lgtm",
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			scheme := &LabelScheme{LgtmLabel: tc.lgtmLabel, LgtmLabelCountdown: tc.countdown}
+			if label := scheme.LgtmLabelName(tc.count, 2); label != tc.expectLabel {
+				t.Errorf("label mismatch: got %s, want %s", label, tc.expectLabel)
+			}
+		})
+	}
+}
+
+func TestGetLgtmLabels(t *testing.T) {
+	scheme := &LabelScheme{LgtmLabel: DefaultLgtmLabel}
+	labels := []github.Label{
+		{Name: "sig/testing"},
+		{Name: "status/LGT1"},
+		{Name: "status/LGTM"},
+		{Name: "status/LGT2"},
+	}
+
+	lgtmLabels := scheme.GetLgtmLabels(labels)
+	if len(lgtmLabels) != 2 || lgtmLabels[0] != "status/LGT1" || lgtmLabels[1] != "status/LGT2" {
+		t.Errorf("lgtm labels mismatch: got %v", lgtmLabels)
+	}
+}
+
+func TestValidateLabelSchemes(t *testing.T) {
+	testcases := []struct {
+		name     string
+		schemes  []LabelScheme
+		expected error
+	}{
+		{
+			name: "Valid schemes",
+			schemes: []LabelScheme{
+				{Repos: []string{"org"}},
+				{Repos: []string{"org/repo"}, LgtmLabel: "lgtm", CanMergeLabel: "approved"},
+			},
+		},
+		{
+			name: "More than one placeholder",
+			schemes: []LabelScheme{
+				{Repos: []string{"org"}, LgtmLabel: "lgtm-{n}-{n}"},
+			},
+			expected: fmt.Errorf("lgtm label lgtm-{n}-{n} contains more than one placeholder"),
+		},
+		{
+			name: "Countdown label",
+			schemes: []LabelScheme{
+				{Repos: []string{"org"}, LgtmLabel: "needs-{n}-lgtm", LgtmLabelCountdown: true},
+			},
+		},
+		{
+			name: "Countdown label without placeholder",
+			schemes: []LabelScheme{
+				{Repos: []string{"org"}, LgtmLabel: "needs-lgtm", LgtmLabelCountdown: true},
+			},
+			expected: fmt.Errorf("countdown lgtm label needs-lgtm does not contain the placeholder"),
+		},
+		{
+			name: "Only placeholder",
+			schemes: []LabelScheme{
+				{Repos: []string{"org"}, LgtmLabel: "{n}"},
+			},
+			expected: fmt.Errorf("lgtm label {n} only contains the placeholder"),
+		},
+		{
+			name: "Can merge label conflicts with lgtm label",
+			schemes: []LabelScheme{
+				{Repos: []string{"org"}, LgtmLabel: "lgtm", CanMergeLabel: "lgtm"},
+			},
+			expected: fmt.Errorf("can merge label lgtm conflicts with lgtm label lgtm"),
+		},
+		{
+			name: "Can merge label matches numbered lgtm label",
+			schemes: []LabelScheme{
+				{Repos: []string{"org"}, LgtmLabel: "status/{n}", CanMergeLabel: "status/1"},
+			},
+			expected: fmt.Errorf("can merge label status/1 conflicts with lgtm label status/{n}"),
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateLabelSchemes(tc.schemes)
+			if err == nil && tc.expected == nil {
+				return
+			}
+			if err == nil || tc.expected == nil || err.Error() != tc.expected.Error() {
+				t.Errorf("error mismatch: got %v, want %v", err, tc.expected)
+			}
+		})
+	}
+}
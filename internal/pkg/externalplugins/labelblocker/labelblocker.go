@@ -171,16 +171,16 @@ func handle(cfg *externalplugins.Configuration, ctx labelCtx, gc githubClient, l
 
 		// Reply to a message explaining why robot do this.
 		if len(blockLabel.Message) != 0 {
-			var operate string
-			if ctx.action == LabeledAction {
-				operate = "adding"
-			} else if ctx.action == UnlabeledAction {
-				operate = "removing"
+			reason, err := cfg.RenderMessage(owner, repo, externalplugins.MessageLabelBlockerReason,
+				map[string]interface{}{
+					"action": ctx.action,
+					"label":  ctx.label,
+				})
+			if err != nil {
+				return err
 			}
-
-			reason := fmt.Sprintf("In response to %s label named %s.", operate, ctx.label)
-			response := externalplugins.FormatResponse(ctx.sender, blockLabel.Message, reason)
-			err := gc.CreateComment(owner, repo, ctx.number, response)
+			response := cfg.FormatResponse(owner, repo, ctx.sender, blockLabel.Message, reason)
+			err = gc.CreateComment(owner, repo, ctx.number, response)
 
 			if err != nil {
 				return fmt.Errorf("failed to respond message, %s", err)
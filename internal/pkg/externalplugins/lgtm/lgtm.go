@@ -2,12 +2,11 @@
 package lgtm
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"regexp"
-	"strconv"
+	"sort"
 	"strings"
-	"text/template"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -15,31 +14,32 @@ import (
 	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/test-infra/prow/config"
+	git "k8s.io/test-infra/prow/git/v2"
 	"k8s.io/test-infra/prow/github"
 	"k8s.io/test-infra/prow/pluginhelp"
+	"k8s.io/test-infra/prow/plugins"
 )
 
 const (
 	// PluginName will register into prow.
 	PluginName = "ti-community-lgtm"
-	// ReviewNotificationName defines the name used in the title for the review notifications.
-	ReviewNotificationName = "Review Notification"
-	// ReviewNotificationIdentifier defines the identifier for the review notifications.
-	ReviewNotificationIdentifier = "Review Notification Identifier"
 )
 
 var (
 	configInfoReviewActsAsLgtm = "'Approve' review action will add a LGTM " +
 		"and 'Request Changes' review action will remove the LGTM."
+	configInfoPushResetPolicyPrefix = "The approvals are handled when new commits are pushed with the policy: "
+	configInfoReviewStatus          = "The review progress is published as the '" +
+		externalplugins.ReviewStatusContext + "' commit status."
+	configInfoRecreateNotification = "A new review notification is created and the old ones are deleted " +
+		"instead of editing the notification in place."
 
 	// lgtmRe is the regex that matches lgtm comments.
 	lgtmRe = regexp.MustCompile(`(?mi)^/lgtm\s*$`)
 	// lgtmCancelRe is the regex that matches lgtm cancel comments.
 	lgtmCancelRe = regexp.MustCompile(`(?mi)^/lgtm cancel\s*$`)
-	// notificationRegex is the regex that matches the notifications.
-	notificationRegex = regexp.MustCompile("<!--" + ReviewNotificationIdentifier + "-->$")
-	// reviewersRegex is the regex that matches the reviewers, such as: - hi-rustin.
-	reviewersRegex = regexp.MustCompile(`(?i)- [@]*([a-z0-9](?:-?[a-z0-9]){0,38})`)
+	// lgtmRefreshRe is the regex that matches lgtm refresh comments.
+	lgtmRefreshRe = regexp.MustCompile(`(?mi)^/lgtm refresh\s*$`)
 )
 
 // HelpProvider constructs the PluginHelp for this plugin that takes into account enabled repositories.
@@ -58,6 +58,19 @@ func HelpProvider(epa *externalplugins.ConfigAgent) func(
 				configInfoStrings = append(configInfoStrings, "<li>"+configInfoReviewActsAsLgtm+"</li>")
 				isConfigured = true
 			}
+			if opts.PushResetPolicy != "" && opts.PushResetPolicy != externalplugins.LgtmResetPolicyKeep {
+				configInfoStrings = append(configInfoStrings,
+					"<li>"+configInfoPushResetPolicyPrefix+opts.PushResetPolicy+"</li>")
+				isConfigured = true
+			}
+			if opts.ReviewStatus {
+				configInfoStrings = append(configInfoStrings, "<li>"+configInfoReviewStatus+"</li>")
+				isConfigured = true
+			}
+			if opts.RecreateNotification {
+				configInfoStrings = append(configInfoStrings, "<li>"+configInfoRecreateNotification+"</li>")
+				isConfigured = true
+			}
 			configInfoStrings = append(configInfoStrings, "</ul>")
 			if isConfigured {
 				configInfo[repo.String()] = strings.Join(configInfoStrings, "\n")
@@ -78,6 +91,14 @@ func HelpProvider(epa *externalplugins.ConfigAgent) func(
 				"/lgtm cancel",
 				"<a href=\"https://help.github.com/articles/about-pull-request-reviews/\">'Approve' or 'Request Changes'</a>"},
 		})
+		pluginHelp.AddCommand(pluginhelp.Command{
+			Usage: "/lgtm refresh",
+			Description: "Recompute the approvals from the review history " +
+				"and correct the 'status/LGT{number}' label and the review notification.",
+			Featured:  false,
+			WhoCanUse: "Everyone",
+			Examples:  []string{"/lgtm refresh"},
+		})
 		return pluginHelp, nil
 	}
 }
@@ -89,7 +110,13 @@ type githubClient interface {
 	GetIssueLabels(org, repo string, number int) ([]github.Label, error)
 	BotUserChecker() (func(candidate string) bool, error)
 	ListIssueComments(org, repo string, number int) ([]github.IssueComment, error)
+	EditComment(org, repo string, ID int, comment string) error
 	DeleteComment(org, repo string, ID int) error
+	ListReviews(org, repo string, number int) ([]github.Review, error)
+	ListPullRequestComments(org, repo string, number int) ([]github.ReviewComment, error)
+	Query(context.Context, interface{}, map[string]interface{}) error
+	GetPullRequest(org, repo string, number int) (*github.PullRequest, error)
+	CreateStatus(org, repo, SHA string, s github.Status) error
 }
 
 // reviewCtx contains information about each review event.
@@ -103,8 +130,19 @@ type reviewCtx struct {
 // "status/LGT{number}" label.
 func HandleIssueCommentEvent(gc githubClient, ice *github.IssueCommentEvent, cfg *externalplugins.Configuration,
 	ol ownersclient.OwnersLoader, log *logrus.Entry) error {
-	// Only consider open PRs and new comments.
-	if !ice.Issue.IsPullRequest() || ice.Issue.State != "open" || ice.Action != github.IssueCommentActionCreated {
+	// Only consider open PRs.
+	if !ice.Issue.IsPullRequest() || ice.Issue.State != "open" {
+		return nil
+	}
+
+	// Edited or deleted comments may change the approvals.
+	if ice.Action == github.IssueCommentActionEdited || ice.Action == github.IssueCommentActionDeleted {
+		return handleCommentChanged(gc, cfg, ol, ice.Repo, ice.Issue.Number, ice.Issue.User.Login,
+			ice.Comment.User.Login, ice.Comment.Body, ice.Action == github.IssueCommentActionDeleted, log)
+	}
+
+	// Only consider new comments.
+	if ice.Action != github.IssueCommentActionCreated {
 		return nil
 	}
 
@@ -117,6 +155,11 @@ func HandleIssueCommentEvent(gc githubClient, ice *github.IssueCommentEvent, cfg
 		number:      ice.Issue.Number,
 	}
 
+	// If we create a "/lgtm refresh" comment, reconcile the LGTM state from the review history.
+	if lgtmRefreshRe.MatchString(rc.body) {
+		return reconcile(gc, cfg, ol, ice.Repo.Owner.Login, ice.Repo.Name, ice.Issue.Number, rc.issueAuthor, log)
+	}
+
 	// If we create an "/lgtm" comment, add lgtm if necessary.
 	// If we create a "/lgtm cancel" comment, remove lgtm if necessary.
 	wantLGTM := false
@@ -149,7 +192,15 @@ func HandlePullReviewEvent(gc githubClient, pullReviewEvent *github.ReviewEvent,
 		htmlURL:     pullReviewEvent.Review.HTMLURL,
 	}
 
-	// Only react to reviews that are being submitted (not editted or dismissed).
+	// Dismissed or edited reviews may change the approvals, reconcile the LGTM state from the review history.
+	if pullReviewEvent.Action == github.ReviewActionDismissed || pullReviewEvent.Action == github.ReviewActionEdited {
+		if pullReviewEvent.PullRequest.State != "open" {
+			return nil
+		}
+		return reconcile(gc, cfg, ol, rc.repo.Owner.Login, rc.repo.Name, rc.number, rc.issueAuthor, log)
+	}
+
+	// Only react to reviews that are being submitted.
 	if pullReviewEvent.Action != github.ReviewActionSubmitted {
 		return nil
 	}
@@ -176,9 +227,21 @@ func HandlePullReviewEvent(gc githubClient, pullReviewEvent *github.ReviewEvent,
 
 func HandlePullReviewCommentEvent(gc githubClient, pullReviewCommentEvent *github.ReviewCommentEvent,
 	cfg *externalplugins.Configuration, ol ownersclient.OwnersLoader, log *logrus.Entry) error {
-	// Only consider open PRs and new comments.
-	if pullReviewCommentEvent.PullRequest.State != "open" ||
-		pullReviewCommentEvent.Action != github.ReviewCommentActionCreated {
+	// Only consider open PRs.
+	if pullReviewCommentEvent.PullRequest.State != "open" {
+		return nil
+	}
+
+	// Edited or deleted comments may change the approvals.
+	if pullReviewCommentEvent.Action == github.ReviewCommentActionEdited ||
+		pullReviewCommentEvent.Action == github.ReviewCommentActionDeleted {
+		return handleCommentChanged(gc, cfg, ol, pullReviewCommentEvent.Repo, pullReviewCommentEvent.PullRequest.Number,
+			pullReviewCommentEvent.PullRequest.User.Login, pullReviewCommentEvent.Comment.User.Login,
+			pullReviewCommentEvent.Comment.Body, pullReviewCommentEvent.Action == github.ReviewCommentActionDeleted, log)
+	}
+
+	// Only consider new comments.
+	if pullReviewCommentEvent.Action != github.ReviewCommentActionCreated {
 		return nil
 	}
 
@@ -206,24 +269,170 @@ func HandlePullReviewCommentEvent(gc githubClient, pullReviewCommentEvent *githu
 	return handle(wantLGTM, cfg, rc, gc, ol, log)
 }
 
-func HandlePullRequestEvent(gc githubClient, pe *github.PullRequestEvent,
-	config *externalplugins.Configuration, log *logrus.Entry) error {
+func HandlePullRequestEvent(gc githubClient, gitClient git.ClientFactory, pe *github.PullRequestEvent,
+	config *externalplugins.Configuration, ol ownersclient.OwnersLoader, log *logrus.Entry) error {
+	org := pe.PullRequest.Base.Repo.Owner.Login
+	repo := pe.PullRequest.Base.Repo.Name
+	number := pe.PullRequest.Number
+
+	if pe.Action == github.PullRequestActionSynchronize {
+		if err := handlePullRequestSynchronize(gc, gitClient, pe, config, log); err != nil {
+			return err
+		}
+		// The review status needs to be published on the new head commit.
+		if !pe.PullRequest.Merged {
+			reportReviewStatus(gc, config, ol, org, repo, number, log)
+		}
+		return nil
+	}
+
 	if pe.Action != github.PullRequestActionOpened {
 		log.Debug("Not a pull request opened action, skipping...")
 		return nil
 	}
 
+	tichiURL := fmt.Sprintf(ownersclient.OwnersURLFmt, config.TichiWebURL, org, repo, number)
+
+	reviewMsg, err := getMessage(config, nil, nil, tichiURL, org, repo)
+	if err != nil {
+		return err
+	}
+
+	if err := gc.CreateComment(org, repo, number, *reviewMsg); err != nil {
+		return err
+	}
+	reportReviewStatus(gc, config, ol, org, repo, number, log)
+	return nil
+}
+
+// handleCommentChanged reconciles the LGTM state when an edited or deleted comment may change the approvals.
+func handleCommentChanged(gc githubClient, cfg *externalplugins.Configuration, ol ownersclient.OwnersLoader,
+	repo github.Repo, number int, issueAuthor, commenter, body string, deleted bool, log *logrus.Entry) error {
+	org := repo.Owner.Login
+	repoName := repo.Name
+
+	botUserChecker, err := gc.BotUserChecker()
+	if err != nil {
+		return fmt.Errorf("failed to get bot name for %s/%s#%d: %v", org, repoName, number, err)
+	}
+	// Ignore the notifications edited or deleted by the bot itself.
+	if botUserChecker(commenter) {
+		return nil
+	}
+
+	hasCommand := lgtmRe.MatchString(body) || lgtmCancelRe.MatchString(body)
+	// A deleted comment only matters if it contains a command.
+	if deleted && !hasCommand {
+		return nil
+	}
+
+	// The previous body of an edited comment is unknown, it may have contained a command
+	// if the commenter is one of the approvers.
+	if !deleted && !hasCommand {
+		issueComments, err := gc.ListIssueComments(org, repoName, number)
+		if err != nil {
+			return fmt.Errorf("failed to get issue comments for %s/%s#%d: %v", org, repoName, number, err)
+		}
+		notifications := externalplugins.FilterReviewNotifications(issueComments, botUserChecker)
+		if !getReviewersFromNotification(getLastComment(notifications)).Has(commenter) {
+			return nil
+		}
+	}
+
+	log.Infof("Reconciling LGTM state because a comment of %s is changed.", commenter)
+	return reconcile(gc, cfg, ol, org, repoName, number, issueAuthor, log)
+}
+
+// handlePullRequestSynchronize resets the approvals of the PR according to the push reset policy.
+func handlePullRequestSynchronize(gc githubClient, gitClient git.ClientFactory, pe *github.PullRequestEvent,
+	config *externalplugins.Configuration, log *logrus.Entry) error {
+	if pe.PullRequest.Merged {
+		return nil
+	}
+
 	org := pe.PullRequest.Base.Repo.Owner.Login
 	repo := pe.PullRequest.Base.Repo.Name
 	number := pe.PullRequest.Number
-	tichiURL := fmt.Sprintf(ownersclient.OwnersURLFmt, config.TichiWebURL, org, repo, number)
+	fetchErr := func(context string, err error) error {
+		return fmt.Errorf("failed to get %s for %s/%s#%d: %v", context, org, repo, number, err)
+	}
+
+	opts := config.LgtmFor(org, repo)
+	if opts.PushResetPolicy == "" || opts.PushResetPolicy == externalplugins.LgtmResetPolicyKeep {
+		return nil
+	}
 
-	reviewMsg, err := getMessage(nil, config.CommandHelpLink, config.PRProcessLink, tichiURL, org, repo)
+	// If we don't have the LGTM label, we don't need to reset anything.
+	labels, err := gc.GetIssueLabels(org, repo, number)
 	if err != nil {
+		return fetchErr("issue labels", err)
+	}
+	currentLabel := getCurrentLabel(config.LabelSchemeFor(org, repo), labels)
+	if currentLabel == "" {
+		return nil
+	}
+
+	botUserChecker, err := gc.BotUserChecker()
+	if err != nil {
+		return fetchErr("bot name", err)
+	}
+	issueComments, err := gc.ListIssueComments(org, repo, number)
+	if err != nil {
+		return fetchErr("issue comments", err)
+	}
+	notifications := externalplugins.FilterReviewNotifications(issueComments, botUserChecker)
+	latestNotification := getLastComment(notifications)
+
+	// The new commits are trivial if they only rebase the approved head onto the base branch, merge the
+	// base branch without any other changes or leave the tree unchanged.
+	recorded := externalplugins.ParseReviewNotification(latestNotification)
+	approvedHead := recorded.ApprovedHead
+	if opts.PushResetPolicy == externalplugins.LgtmResetPolicyResetUnlessTrivial && approvedHead != "" {
+		trivial, err := externalplugins.IsDiffApproved(gitClient, org, repo, &pe.PullRequest,
+			&externalplugins.ApprovedDiff{HeadSHA: approvedHead})
+		if err != nil {
+			log.WithError(err).Warnf("Failed to compare the new commits with the approved head %s.", approvedHead)
+		}
+		if trivial {
+			log.Info("Keep the approvals, the new commits are trivial.")
+			return nil
+		}
+	}
+
+	droppedReviewers := recorded.Approvers
+
+	// The reset push is recorded, so that the approvals before it are not restored by the reconciliation.
+	state := &externalplugins.ReviewNotification{
+		Committers: recorded.Committers,
+		ResetHead:  pe.PullRequest.Head.SHA,
+		ResetAt:    time.Now(),
+	}
+	tichiURL := fmt.Sprintf(ownersclient.OwnersURLFmt, config.TichiWebURL, org, repo, number)
+	newMsg, err := config.RenderReviewNotification(org, repo, nil, state, tichiURL)
+	if err != nil {
+		return err
+	}
+	err = externalplugins.UpdateStickyComment(gc, org, repo, number, notifications, newMsg,
+		opts.RecreateNotification, log)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Removing LGTM label because new commits are pushed.")
+	if err := gc.RemoveLabel(org, repo, number, currentLabel); err != nil {
 		return err
 	}
 
-	return gc.CreateComment(org, repo, number, *reviewMsg)
+	resp, err := config.RenderMessage(org, repo, externalplugins.MessageLgtmApprovalsReset,
+		map[string]interface{}{
+			"reviewers": droppedReviewers,
+		})
+	if err != nil {
+		return err
+	}
+	log.Infof("Commenting \"%s\".", resp)
+	return gc.CreateComment(org, repo, number,
+		config.FormatSimpleResponse(org, repo, pe.PullRequest.User.Login, resp))
 }
 
 func handle(wantLGTM bool, config *externalplugins.Configuration, rc reviewCtx,
@@ -247,15 +456,20 @@ func handle(wantLGTM bool, config *externalplugins.Configuration, rc reviewCtx,
 	// Author cannot LGTM own PR, comment and abort.
 	isAuthor := author == issueAuthor
 	if isAuthor && wantLGTM {
-		resp := "you cannot `/lgtm` your own PR."
+		resp, err := config.RenderMessage(org, repo, externalplugins.MessageLgtmSelfApproval, nil)
+		if err != nil {
+			return err
+		}
 		log.Infof("Commenting \"%s\".", resp)
-		return gc.CreateComment(rc.repo.Owner.Login, rc.repo.Name, rc.number,
-			externalplugins.FormatResponseRaw(rc.body, rc.htmlURL, rc.author, resp))
+		return gc.CreateComment(org, repo, number, config.FormatResponseRaw(org, repo, body, htmlURL, author, resp))
 	}
 
 	// Get ti-community-lgtm config.
-	opts := config.LgtmFor(rc.repo.Owner.Login, rc.repo.Name)
+	opts := config.LgtmFor(org, repo)
 	tichiURL := fmt.Sprintf(ownersclient.OwnersURLFmt, config.TichiWebURL, org, repo, number)
+	ownersLinkData := map[string]interface{}{
+		"ownersLink": tichiURL,
+	}
 	reviewersAndNeedsLGTM, err := ol.LoadOwners(opts.PullOwnersEndpoint, org, repo, number)
 	if err != nil {
 		return fetchErr("owners info", err)
@@ -268,16 +482,22 @@ func handle(wantLGTM bool, config *externalplugins.Configuration, rc reviewCtx,
 
 	// Not reviewers but want to add LGTM.
 	if !reviewers.Has(author) && wantLGTM {
-		resp := "`/lgtm` is only allowed for the reviewers in [list](" + tichiURL + ")."
+		resp, err := config.RenderMessage(org, repo, externalplugins.MessageLgtmOnlyReviewers, ownersLinkData)
+		if err != nil {
+			return err
+		}
 		log.Infof("Reply /lgtm request in comment: \"%s\"", resp)
-		return gc.CreateComment(org, repo, number, externalplugins.FormatResponseRaw(body, htmlURL, author, resp))
+		return gc.CreateComment(org, repo, number, config.FormatResponseRaw(org, repo, body, htmlURL, author, resp))
 	}
 
 	// Not author or reviewers but want to remove LGTM.
 	if !reviewers.Has(author) && !isAuthor && !wantLGTM {
-		resp := "`/lgtm cancel` is only allowed for the PR author or the reviewers in [list](" + tichiURL + ")."
+		resp, err := config.RenderMessage(org, repo, externalplugins.MessageLgtmCancelOnlyReviewers, ownersLinkData)
+		if err != nil {
+			return err
+		}
 		log.Infof("Reply /lgtm cancel request in comment: \"%s\"", resp)
-		return gc.CreateComment(org, repo, number, externalplugins.FormatResponseRaw(body, htmlURL, author, resp))
+		return gc.CreateComment(org, repo, number, config.FormatResponseRaw(org, repo, body, htmlURL, author, resp))
 	}
 
 	labels, err := gc.GetIssueLabels(org, repo, number)
@@ -292,27 +512,24 @@ func handle(wantLGTM bool, config *externalplugins.Configuration, rc reviewCtx,
 	if err != nil {
 		return fetchErr("issue comments", err)
 	}
-	notifications := filterComments(issueComments, notificationMatcher(botUserChecker))
-	cleanupOldNotifications := func() {
-		for _, notification := range notifications {
-			notif := notification
-			if err := gc.DeleteComment(org, repo, notif.ID); err != nil {
-				log.WithError(err).Errorf("Failed to delete comment from %s/%s#%d, ID: %d.", org, repo, number, notif.ID)
-			}
-		}
-	}
+	notifications := externalplugins.FilterReviewNotifications(issueComments, botUserChecker)
 
 	// Now we update the LGTM labels, having checked all cases where changing.
 	// Only add the label if it doesn't have it, and vice versa.
-	currentLabel, nextLabel := getCurrentAndNextLabel(externalplugins.LgtmLabelPrefix, labels,
-		reviewersAndNeedsLGTM.NeedsLgtm)
+	labelScheme := config.LabelSchemeFor(org, repo)
+	currentLabel := getCurrentLabel(labelScheme, labels)
 	// Remove the label if necessary, we're done after this.
 	if currentLabel != "" && !wantLGTM {
-		newMsg, err := getMessage(nil, config.CommandHelpLink, config.PRProcessLink, tichiURL, org, repo)
+		// The approvals are removed and the other state is kept.
+		state := externalplugins.ParseReviewNotification(getLastComment(notifications))
+		state.Approvers = nil
+		state.ApprovedHead = ""
+		newMsg, err := config.RenderReviewNotification(org, repo, nil, state, tichiURL)
 		if err != nil {
 			return err
 		}
-		err = gc.CreateComment(org, repo, number, *newMsg)
+		err = externalplugins.UpdateStickyComment(gc, org, repo, number, notifications, newMsg,
+			opts.RecreateNotification, log)
 		if err != nil {
 			return err
 		}
@@ -320,10 +537,7 @@ func handle(wantLGTM bool, config *externalplugins.Configuration, rc reviewCtx,
 		if err := gc.RemoveLabel(org, repo, number, currentLabel); err != nil {
 			return err
 		}
-
-		// Clean up old notifications after we added the new notification.
-		cleanupOldNotifications()
-	} else if nextLabel != "" && wantLGTM {
+	} else if wantLGTM {
 		latestNotification := getLastComment(notifications)
 		reviewedReviewers := getReviewersFromNotification(latestNotification)
 		// Ignore already reviewed reviewer.
@@ -331,180 +545,351 @@ func handle(wantLGTM bool, config *externalplugins.Configuration, rc reviewCtx,
 			log.Infof("Ignore %s's multiple reviews.", author)
 			return nil
 		}
+		// Ignore the LGTM when the PR has acquired enough LGTMs.
+		currentLgtmCount := getLgtmCountFromLabel(labelScheme, currentLabel, reviewersAndNeedsLGTM.NeedsLgtm)
+		// The label without the number cannot record the approvals, so count them from the notification.
+		if currentLabel != "" && !labelScheme.IsLgtmLabelNumbered() {
+			currentLgtmCount = opts.CountLgtm(reviewersAndNeedsLGTM, reviewedReviewers.List())
+		}
+		if opts.IsLgtmSatisfied(reviewersAndNeedsLGTM, currentLgtmCount, reviewedReviewers.List()) {
+			log.Infof("Ignore %s's review because the approval rules have been satisfied.", author)
+			return nil
+		}
 
 		// Add author as reviewers and create new notification.
 		reviewedReviewers.Insert(author)
-		newMsg, err := getMessage(reviewedReviewers.List(), config.CommandHelpLink, config.PRProcessLink, tichiURL, org, repo)
+		nextLabel := labelScheme.LgtmLabelName(currentLgtmCount+opts.LgtmWeight(reviewersAndNeedsLGTM, author),
+			reviewersAndNeedsLGTM.NeedsLgtm)
+		state := externalplugins.ParseReviewNotification(latestNotification)
+		state.Approvers = reviewedReviewers.List()
+		// The approved head is recorded to find out whether the commits pushed later are trivial.
+		if opts.PushResetPolicy == externalplugins.LgtmResetPolicyResetUnlessTrivial {
+			pr, err := gc.GetPullRequest(org, repo, number)
+			if err != nil {
+				return fetchErr("pull request", err)
+			}
+			state.ApprovedHead = pr.Head.SHA
+		}
+		newMsg, err := config.RenderReviewNotification(org, repo, reviewersAndNeedsLGTM, state, tichiURL)
 		if err != nil {
 			return err
 		}
 
-		err = gc.CreateComment(org, repo, number, *newMsg)
+		err = externalplugins.UpdateStickyComment(gc, org, repo, number, notifications, newMsg,
+			opts.RecreateNotification, log)
 		if err != nil {
 			return err
 		}
 
 		log.Info("Adding LGTM label.")
+		// Add the next label before removing the current label, so that the PR is never seen without
+		// the LGTM label while the label is being changed.
+		if currentLabel != nextLabel {
+			if err := gc.AddLabel(org, repo, number, nextLabel); err != nil {
+				return err
+			}
+		}
 		// Remove current label.
-		if currentLabel != "" {
+		if currentLabel != "" && currentLabel != nextLabel {
 			if err := gc.RemoveLabel(org, repo, number, currentLabel); err != nil {
 				return err
 			}
 		}
-		if err := gc.AddLabel(org, repo, number, nextLabel); err != nil {
-			return err
-		}
-
-		// Clean up old notifications after we added the new notification.
-		cleanupOldNotifications()
 	}
 
+	reportReviewStatus(gc, config, ol, org, repo, number, log)
 	return nil
 }
 
-// getCurrentAndNextLabel returns pull request current label and next required label.
-func getCurrentAndNextLabel(prefix string, labels []github.Label, needsLgtm int) (string, string) {
-	currentLabel := ""
-	nextLabel := ""
-	for _, label := range labels {
-		if strings.Contains(label.Name, prefix) {
-			currentLabel = label.Name
-			currentLgtmNumber, _ := strconv.Atoi(strings.Trim(label.Name, prefix))
-			if currentLgtmNumber < needsLgtm {
-				nextLabel = fmt.Sprintf("%s%d", prefix, currentLgtmNumber+1)
-			}
-		}
+// HandleAll reconciles the LGTM state of all open PRs in the orgs and repos that enabled this plugin.
+func HandleAll(log *logrus.Entry, gc githubClient, config *plugins.Configuration,
+	externalConfig *externalplugins.Configuration, ol ownersclient.OwnersLoader) error {
+	log.Info("Reconciling all PRs.")
+	orgs, repos := config.EnabledReposForExternalPlugin(PluginName)
+	if len(orgs) == 0 && len(repos) == 0 {
+		log.Warnf("No repos have been configured for the %s plugin", PluginName)
+		return nil
 	}
-	if currentLabel == "" {
-		nextLabel = fmt.Sprintf("%s%d", prefix, 1)
+
+	prs, err := externalplugins.SearchOpenPullRequests(context.Background(), log, gc, orgs, repos)
+	if err != nil {
+		return err
 	}
+	log.Infof("Considering %d PRs.", len(prs))
+	for _, pr := range prs {
+		org := string(pr.Repository.Owner.Login)
+		repo := string(pr.Repository.Name)
+		num := int(pr.Number)
+		l := log.WithFields(logrus.Fields{
+			"org":  org,
+			"repo": repo,
+			"pr":   num,
+		})
 
-	return currentLabel, nextLabel
+		err := reconcile(gc, externalConfig, ol, org, repo, num, string(pr.Author.Login), l)
+		if err != nil {
+			l.WithError(err).Error("Error reconciling PR.")
+		}
+	}
+	return nil
 }
 
-// getReviewersFromNotification get the reviewers from latest notification.
-func getReviewersFromNotification(latestNotification *github.IssueComment) sets.String {
-	result := sets.String{}
-	if latestNotification == nil {
-		return result
+// lgtmAction is an action that adds or cancels a LGTM found in the review history.
+type lgtmAction struct {
+	login    string
+	wantLGTM bool
+	at       time.Time
+}
+
+// reconcile recomputes the approvers from the reviews, the `/lgtm` comments and the current owners,
+// then corrects the LGTM label and the review notification if they drift from the approvers.
+func reconcile(gc githubClient, config *externalplugins.Configuration, ol ownersclient.OwnersLoader,
+	org, repo string, number int, issueAuthor string, log *logrus.Entry) error {
+	fetchErr := func(context string, err error) error {
+		return fmt.Errorf("failed to get %s for %s/%s#%d: %v", context, org, repo, number, err)
 	}
 
-	reviewers := reviewersRegex.FindAllStringSubmatch(latestNotification.Body, -1)
+	opts := config.LgtmFor(org, repo)
+	owners, err := ol.LoadOwners(opts.PullOwnersEndpoint, org, repo, number)
+	if err != nil {
+		return fetchErr("owners info", err)
+	}
+	reviewers := sets.NewString(owners.Reviewers...)
 
-	reviewerNameIndex := 1
-	for _, reviewer := range reviewers {
-		// Example: - a => [[- a a]]
-		if len(reviewer) == reviewerNameIndex+1 {
-			result.Insert(reviewer[reviewerNameIndex])
+	botUserChecker, err := gc.BotUserChecker()
+	if err != nil {
+		return fetchErr("bot name", err)
+	}
+	issueComments, err := gc.ListIssueComments(org, repo, number)
+	if err != nil {
+		return fetchErr("issue comments", err)
+	}
+	reviewComments, err := gc.ListPullRequestComments(org, repo, number)
+	if err != nil {
+		return fetchErr("review comments", err)
+	}
+	var reviews []github.Review
+	if opts.ReviewActsAsLgtm {
+		reviews, err = gc.ListReviews(org, repo, number)
+		if err != nil {
+			return fetchErr("reviews", err)
 		}
 	}
-	return result
-}
 
-// filterComments will filtering the issue comments by filter.
-func filterComments(comments []github.IssueComment,
-	filter func(comment *github.IssueComment) bool) []*github.IssueComment {
-	filtered := make([]*github.IssueComment, 0, len(comments))
-	for _, comment := range comments {
-		c := comment
-		if filter(&c) {
-			filtered = append(filtered, &c)
+	notifications := externalplugins.FilterReviewNotifications(issueComments, botUserChecker)
+	latestNotification := getLastComment(notifications)
+	recorded := externalplugins.ParseReviewNotification(latestNotification)
+
+	var actions []lgtmAction
+	// The commands in the edited comments take effect at the time of the last edit.
+	for i := range issueComments {
+		comment := issueComments[i]
+		if action, ok := getLgtmActionFromComment(comment.User.Login, comment.Body, getCommentTime(&comment)); ok {
+			actions = append(actions, action)
 		}
 	}
-	return filtered
-}
+	for _, comment := range reviewComments {
+		at := comment.CreatedAt
+		if comment.UpdatedAt.After(at) {
+			at = comment.UpdatedAt
+		}
+		if action, ok := getLgtmActionFromComment(comment.User.Login, comment.Body, at); ok {
+			actions = append(actions, action)
+		}
+	}
+	for _, review := range reviews {
+		reviewState := github.ReviewState(strings.ToUpper(string(review.State)))
+		if reviewState == github.ReviewStateApproved {
+			actions = append(actions, lgtmAction{login: review.User.Login, wantLGTM: true, at: review.SubmittedAt})
+		} else if reviewState == github.ReviewStateChangesRequested {
+			actions = append(actions, lgtmAction{login: review.User.Login, wantLGTM: false, at: review.SubmittedAt})
+		}
+	}
+	sort.SliceStable(actions, func(i, j int) bool {
+		return actions[i].at.Before(actions[j].at)
+	})
 
-// getLastComment get the last issue comment.
-func getLastComment(issueComments []*github.IssueComment) *github.IssueComment {
-	if len(issueComments) == 0 {
-		return nil
+	isSatisfied := func(approvers sets.String) bool {
+		return opts.IsLgtmSatisfied(owners, opts.CountLgtm(owners, approvers.List()), approvers.List())
 	}
-	return issueComments[len(issueComments)-1]
+	// The approvals before the last reset push recorded in the notification are dropped.
+	approvers := getApprovers(actions, issueAuthor, reviewers, isSatisfied, recorded.ResetAt, botUserChecker)
+
+	labels, err := gc.GetIssueLabels(org, repo, number)
+	if err != nil {
+		return fetchErr("issue labels", err)
+	}
+	labelScheme := config.LabelSchemeFor(org, repo)
+
+	// Correct the notification if it does not match the approvers.
+	if latestNotification == nil || len(notifications) > 1 ||
+		!sets.NewString(recorded.Approvers...).Equal(approvers) {
+		state := recorded
+		state.Approvers = approvers.List()
+		if approvers.Len() == 0 {
+			state.ApprovedHead = ""
+		} else if opts.PushResetPolicy == externalplugins.LgtmResetPolicyResetUnlessTrivial {
+			if state.ApprovedHead == "" {
+				pr, err := gc.GetPullRequest(org, repo, number)
+				if err != nil {
+					return fetchErr("pull request", err)
+				}
+				state.ApprovedHead = pr.Head.SHA
+			}
+		}
+		tichiURL := fmt.Sprintf(ownersclient.OwnersURLFmt, config.TichiWebURL, org, repo, number)
+		newMsg, err := config.RenderReviewNotification(org, repo, owners, state, tichiURL)
+		if err != nil {
+			return err
+		}
+		log.Infof("Correcting the review notification with approvers %v.", approvers.List())
+		err = externalplugins.UpdateStickyComment(gc, org, repo, number, notifications, newMsg,
+			opts.RecreateNotification, log)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Correct the LGTM label if it does not match the approvers.
+	expectLabel := ""
+	if approvers.Len() > 0 {
+		expectLabel = labelScheme.LgtmLabelName(opts.CountLgtm(owners, approvers.List()), owners.NeedsLgtm)
+	}
+	lgtmLabels := labelScheme.GetLgtmLabels(labels)
+	if expectLabel != "" && !sets.NewString(lgtmLabels...).Has(expectLabel) {
+		log.Infof("Adding LGTM label %s.", expectLabel)
+		if err := gc.AddLabel(org, repo, number, expectLabel); err != nil {
+			return err
+		}
+	}
+	for _, label := range lgtmLabels {
+		if label == expectLabel {
+			continue
+		}
+		log.Infof("Removing stale LGTM label %s.", label)
+		if err := gc.RemoveLabel(org, repo, number, label); err != nil {
+			return err
+		}
+	}
+
+	reportReviewStatus(gc, config, ol, org, repo, number, log)
+	return nil
 }
 
-// getMessage returns the comment body that we want the approve plugin to display on PRs
-// The comment shows:
-// 	- a list of reviewed reviewers
-// 	- how an approver can indicate their lgtm
-// 	- how an approver can cancel their lgtm
-func getMessage(reviewedReviewers []string, commandHelpLink,
-	prProcessLink, ownersLink, org, repo string) (*string, error) {
-	// nolint:lll
-	message, err := generateTemplate(`
-{{if .reviewers}}
-This pull request has been approved by:
-
-{{range $index, $reviewer := .reviewers}}- {{$reviewer}}`+"\n"+`{{end}}
-
-{{else}}
-This pull request has not been approved.
-{{end}}
-
-To complete the [pull request process]({{ .prProcessLink }}), please ask the reviewers in the [list]({{ .ownersLink }}) to review by filling `+"`/cc @reviewer`"+` in the comment.
-After your PR has acquired the required number of LGTMs, you can assign this pull request to the committer in the [list]({{ .ownersLink }}) by filling  `+"`/assign @committer`"+` in the comment to help you merge this pull request.
-
-The full list of commands accepted by this bot can be found [here]({{ .commandHelpLink }}?repo={{ .org }}%2F{{ .repo }}).
-
-<details>
-
-Reviewer can indicate their review by writing `+"`/lgtm`"+` in a comment.
-Reviewer can cancel approval by writing `+"`/lgtm cancel`"+` in a comment.
-</details>
-
-<!--{{ .reviewNotificationIdentifier }}-->
-`, "message", map[string]interface{}{
-		"reviewers":                    reviewedReviewers,
-		"commandHelpLink":              commandHelpLink,
-		"prProcessLink":                prProcessLink,
-		"ownersLink":                   ownersLink,
-		"org":                          org,
-		"repo":                         repo,
-		"reviewNotificationIdentifier": ReviewNotificationIdentifier,
-	})
+// reportReviewStatus publishes the review status on the head commit of the PR if the repo enables it.
+// The failure is only logged because the status does not affect the approvals.
+func reportReviewStatus(gc githubClient, config *externalplugins.Configuration, ol ownersclient.OwnersLoader,
+	org, repo string, number int, log *logrus.Entry) {
+	opts := config.LgtmFor(org, repo)
+	if !opts.ReviewStatus {
+		return
+	}
+
+	owners, err := ol.LoadOwners(opts.PullOwnersEndpoint, org, repo, number)
 	if err != nil {
-		return nil, err
+		log.WithError(err).Error("Failed to get owners info.")
+		return
 	}
+	botUserChecker, err := gc.BotUserChecker()
+	if err != nil {
+		log.WithError(err).Error("Failed to get bot name.")
+		return
+	}
+	issueComments, err := gc.ListIssueComments(org, repo, number)
+	if err != nil {
+		log.WithError(err).Error("Failed to get issue comments.")
+		return
+	}
+	approvers, _ := externalplugins.GetApproversFromComments(issueComments, botUserChecker)
 
-	return notification(ReviewNotificationName, "", message), nil
+	log.Info("Reporting the review status.")
+	if err := config.ReportReviewStatus(gc, org, repo, number, owners, approvers.List()); err != nil {
+		log.WithError(err).Error("Failed to report the review status.")
+	}
 }
 
-// generateTemplate takes a template, name and data, and generates
-// the corresponding string.
-func generateTemplate(templ, name string, data interface{}) (string, error) {
-	buf := bytes.NewBufferString("")
-	if messageTemplate, err := template.New(name).Parse(templ); err != nil {
-		return "", fmt.Errorf("failed to parse template for %s: %v", name, err)
-	} else if err := messageTemplate.Execute(buf, data); err != nil {
-		return "", fmt.Errorf("failed to execute template for %s: %v", name, err)
+// getLgtmActionFromComment returns the LGTM action of the comment if it contains a lgtm command.
+func getLgtmActionFromComment(login, body string, at time.Time) (lgtmAction, bool) {
+	if lgtmRe.MatchString(body) {
+		return lgtmAction{login: login, wantLGTM: true, at: at}, true
+	}
+	if lgtmCancelRe.MatchString(body) {
+		return lgtmAction{login: login, wantLGTM: false, at: at}, true
 	}
-	return buf.String(), nil
+	return lgtmAction{}, false
 }
 
-// notification create a notification message.
-func notification(name, arguments, context string) *string {
-	str := "[" + strings.ToUpper(name) + "]"
+// getApprovers replays the LGTM actions in order with the same rules as handling the events,
+// and returns the reviewers who approve the PR.
+func getApprovers(actions []lgtmAction, issueAuthor string, reviewers sets.String,
+	isSatisfied func(sets.String) bool, resetAt time.Time, isBot func(string) bool) sets.String {
+	approvers := sets.NewString()
+	for _, action := range actions {
+		if isBot(action.login) || action.at.Before(resetAt) {
+			continue
+		}
+		isAuthor := action.login == issueAuthor
+		if action.wantLGTM {
+			// Author cannot LGTM own PR and only reviewers can add LGTM.
+			if isAuthor || !reviewers.Has(action.login) {
+				continue
+			}
+			// The LGTM is ignored when the PR has acquired enough LGTMs.
+			if isSatisfied(approvers) {
+				continue
+			}
+			approvers.Insert(action.login)
+		} else if isAuthor || reviewers.Has(action.login) {
+			// Cancel removes all approvals.
+			approvers = sets.NewString()
+		}
+	}
+	return approvers
+}
 
-	args := strings.TrimSpace(arguments)
-	if args != "" {
-		str += " " + args
+// getLgtmCountFromLabel returns the number of approvals recorded by the LGTM label.
+func getLgtmCountFromLabel(scheme *externalplugins.LabelScheme, label string, needsLgtm int) int {
+	count, _ := scheme.ParseLgtmLabel(label, needsLgtm)
+	return count
+}
+
+// getCurrentLabel returns pull request current LGTM label.
+func getCurrentLabel(scheme *externalplugins.LabelScheme, labels []github.Label) string {
+	currentLabel := ""
+	for _, label := range scheme.GetLgtmLabels(labels) {
+		currentLabel = label
 	}
+	return currentLabel
+}
 
-	ctx := strings.TrimSpace(context)
-	if ctx != "" {
-		str += "\n\n" + ctx
+// getCommentTime returns the last time the comment is created or updated.
+func getCommentTime(comment *github.IssueComment) time.Time {
+	if comment.UpdatedAt.After(comment.CreatedAt) {
+		return comment.UpdatedAt
 	}
+	return comment.CreatedAt
+}
 
-	return &str
+// getReviewersFromNotification get the reviewers from latest notification.
+func getReviewersFromNotification(latestNotification *github.IssueComment) sets.String {
+	return sets.NewString(externalplugins.ParseReviewNotification(latestNotification).Approvers...)
 }
 
-// notificationMatcher matches issue comments for notifications.
-func notificationMatcher(isBot func(string) bool) func(comment *github.IssueComment) bool {
-	return func(c *github.IssueComment) bool {
-		// Only match robot's comment.
-		if !isBot(c.User.Login) {
-			return false
-		}
-		match := notificationRegex.FindStringSubmatch(c.Body)
-		return len(match) > 0
+// getLastComment get the last issue comment.
+func getLastComment(issueComments []*github.IssueComment) *github.IssueComment {
+	if len(issueComments) == 0 {
+		return nil
+	}
+	return issueComments[len(issueComments)-1]
+}
+
+// getMessage returns the review notification which shows the reviewed reviewers.
+func getMessage(config *externalplugins.Configuration, owners *ownersclient.Owners, reviewedReviewers []string,
+	ownersLink, org, repo string) (*string, error) {
+	state := &externalplugins.ReviewNotification{Approvers: reviewedReviewers}
+	message, err := config.RenderReviewNotification(org, repo, owners, state, ownersLink)
+	if err != nil {
+		return nil, err
 	}
+	return &message, nil
 }
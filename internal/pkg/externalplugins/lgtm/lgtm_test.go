@@ -5,10 +5,13 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins/testutil"
 	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/github"
 	"k8s.io/test-infra/prow/github/fakegithub"
@@ -20,18 +23,44 @@ var (
 )
 
 type fakeOwnersClient struct {
-	reviewers []string
-	needsLgtm int
+	committers   []string
+	reviewers    []string
+	needsLgtm    int
+	roles        map[string]string
+	affiliations map[string]string
 }
 
 func (f *fakeOwnersClient) LoadOwners(_ string,
 	_, _ string, _ int) (*ownersclient.Owners, error) {
 	return &ownersclient.Owners{
-		Reviewers: f.reviewers,
-		NeedsLgtm: f.needsLgtm,
+		Committers:   f.committers,
+		Reviewers:    f.reviewers,
+		NeedsLgtm:    f.needsLgtm,
+		Roles:        f.roles,
+		Affiliations: f.affiliations,
 	}, nil
 }
 
+// fakeGitHubClient records the edited comments because the fake client does not edit comments.
+type fakeGitHubClient struct {
+	*fakegithub.FakeClient
+	IssueCommentsEdited []string
+}
+
+func (f *fakeGitHubClient) EditComment(org, repo string, id int, comment string) error {
+	for number, comments := range f.IssueComments {
+		for i := range comments {
+			if comments[i].ID == id {
+				comments[i].Body = comment
+				f.IssueCommentsEdited = append(f.IssueCommentsEdited,
+					fmt.Sprintf("%s/%s#%d:%s", org, repo, number, comment))
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("could not find issue comment %d", id)
+}
+
 func TestLGTMIssueAndReviewComment(t *testing.T) {
 	type commentCase struct {
 		name         string
@@ -41,10 +70,10 @@ func TestLGTMIssueAndReviewComment(t *testing.T) {
 		lgtmComment  string
 		isCancel     bool
 
-		shouldToggle        bool
-		shouldComment       bool
-		expectComment       string
-		shouldDeleteComment bool
+		shouldToggle      bool
+		shouldComment     bool
+		expectComment     string
+		shouldEditComment bool
 	}
 
 	var testcases = []commentCase{
@@ -72,15 +101,15 @@ func TestLGTMIssueAndReviewComment(t *testing.T) {
 			expectComment: "org/repo#5:[REVIEW NOTIFICATION]\n\nThis pull request has been approved by:\n\n- collab1\n\n\n\n\nTo complete the [pull request process](https://prProcessLink), please ask the reviewers in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) to review by filling `/cc @reviewer` in the comment.\nAfter your PR has acquired the required number of LGTMs, you can assign this pull request to the committer in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) by filling  `/assign @committer` in the comment to help you merge this pull request.\n\nThe full list of commands accepted by this bot can be found [here](https://commandHelpLink?repo=org%2Frepo).\n\n<details>\n\nReviewer can indicate their review by writing `/lgtm` in a comment.\nReviewer can cancel approval by writing `/lgtm cancel` in a comment.\n</details>\n\n<!--Review Notification Identifier-->",
 		},
 		{
-			name:                "lgtm comment by reviewer collab1, lgtm on pr",
-			body:                "/lgtm",
-			commenter:           "collab1",
-			currentLabel:        lgtmOne,
-			lgtmComment:         "[REVIEW NOTIFICATION]\n\nThis pull request has been approved by:\n\n- collab2\n\n\n\n\nTo complete the [pull request process](https://prProcessLink), please ask the reviewers in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) to review by filling `/cc @reviewer` in the comment.\nAfter your PR has acquired the required number of LGTMs, you can assign this pull request to the committer in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) by filling  `/assign @committer` in the comment to help you merge this pull request.\n\nThe full list of commands accepted by this bot can be found [here](https://commandHelpLink?repo=org%2Frepo).\n\n<details>\n\nReviewer can indicate their review by writing `/lgtm` in a comment.\nReviewer can cancel approval by writing `/lgtm cancel` in a comment.\n</details>\n\n<!--Review Notification Identifier-->",
-			shouldToggle:        true,
-			shouldComment:       true,
-			shouldDeleteComment: true,
-			expectComment:       "org/repo#5:[REVIEW NOTIFICATION]\n\nThis pull request has been approved by:\n\n- collab1\n- collab2\n\n\n\n\nTo complete the [pull request process](https://prProcessLink), please ask the reviewers in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) to review by filling `/cc @reviewer` in the comment.\nAfter your PR has acquired the required number of LGTMs, you can assign this pull request to the committer in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) by filling  `/assign @committer` in the comment to help you merge this pull request.\n\nThe full list of commands accepted by this bot can be found [here](https://commandHelpLink?repo=org%2Frepo).\n\n<details>\n\nReviewer can indicate their review by writing `/lgtm` in a comment.\nReviewer can cancel approval by writing `/lgtm cancel` in a comment.\n</details>\n\n<!--Review Notification Identifier-->",
+			name:              "lgtm comment by reviewer collab1, lgtm on pr",
+			body:              "/lgtm",
+			commenter:         "collab1",
+			currentLabel:      lgtmOne,
+			lgtmComment:       "[REVIEW NOTIFICATION]\n\nThis pull request has been approved by:\n\n- collab2\n\n\n\n\nTo complete the [pull request process](https://prProcessLink), please ask the reviewers in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) to review by filling `/cc @reviewer` in the comment.\nAfter your PR has acquired the required number of LGTMs, you can assign this pull request to the committer in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) by filling  `/assign @committer` in the comment to help you merge this pull request.\n\nThe full list of commands accepted by this bot can be found [here](https://commandHelpLink?repo=org%2Frepo).\n\n<details>\n\nReviewer can indicate their review by writing `/lgtm` in a comment.\nReviewer can cancel approval by writing `/lgtm cancel` in a comment.\n</details>\n\n<!--Review Notification Identifier-->",
+			shouldToggle:      true,
+			shouldComment:     true,
+			shouldEditComment: true,
+			expectComment:     "org/repo#5:[REVIEW NOTIFICATION]\n\nThis pull request has been approved by:\n\n- collab1\n- collab2\n\n\n\n\nTo complete the [pull request process](https://prProcessLink), please ask the reviewers in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) to review by filling `/cc @reviewer` in the comment.\nAfter your PR has acquired the required number of LGTMs, you can assign this pull request to the committer in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) by filling  `/assign @committer` in the comment to help you merge this pull request.\n\nThe full list of commands accepted by this bot can be found [here](https://commandHelpLink?repo=org%2Frepo).\n\n<details>\n\nReviewer can indicate their review by writing `/lgtm` in a comment.\nReviewer can cancel approval by writing `/lgtm cancel` in a comment.\n</details>\n\n<!--Review Notification Identifier-->",
 		},
 		{
 			name:          "lgtm comment by author",
@@ -88,7 +117,7 @@ func TestLGTMIssueAndReviewComment(t *testing.T) {
 			commenter:     "author",
 			shouldToggle:  false,
 			shouldComment: true,
-			expectComment: "org/repo#5:@author: you cannot `/lgtm` your own PR.\n\n<details>\n\nIn response to [this](<url>):\n\n>/lgtm\n\n\nInstructions for interacting with me using PR comments are available [here](https://prow.tidb.io/command-help).  If you have questions or suggestions related to my behavior, please file an issue against the [ti-community-infra/tichi](https://github.com/ti-community-infra/tichi/issues/new?title=Prow%20issue:) repository.\n</details>",
+			expectComment: "org/repo#5:@author: you cannot `/lgtm` your own PR.\n\n<details>\n\nIn response to [this](<url>):\n\n>/lgtm\n\n\nInstructions for interacting with me using PR comments are available [here](https://commandHelpLink).  If you have questions or suggestions related to my behavior, please file an issue against the [ti-community-infra/tichi](https://github.com/ti-community-infra/tichi/issues/new?title=Prow%20issue:) repository.\n</details>",
 		},
 		{
 			name:          "lgtm cancel by reviewer author",
@@ -122,19 +151,19 @@ func TestLGTMIssueAndReviewComment(t *testing.T) {
 			commenter:     "not-in-the-org",
 			shouldToggle:  false,
 			shouldComment: true,
-			expectComment: "org/repo#5:@not-in-the-org: `/lgtm` is only allowed for the reviewers in [list](https://tichiWebLink/repos/org/repo/pulls/5/owners).\n\n<details>\n\nIn response to [this](<url>):\n\n>/lgtm\n\n\nInstructions for interacting with me using PR comments are available [here](https://prow.tidb.io/command-help).  If you have questions or suggestions related to my behavior, please file an issue against the [ti-community-infra/tichi](https://github.com/ti-community-infra/tichi/issues/new?title=Prow%20issue:) repository.\n</details>",
+			expectComment: "org/repo#5:@not-in-the-org: `/lgtm` is only allowed for the reviewers in [list](https://tichiWebLink/repos/org/repo/pulls/5/owners).\n\n<details>\n\nIn response to [this](<url>):\n\n>/lgtm\n\n\nInstructions for interacting with me using PR comments are available [here](https://commandHelpLink).  If you have questions or suggestions related to my behavior, please file an issue against the [ti-community-infra/tichi](https://github.com/ti-community-infra/tichi/issues/new?title=Prow%20issue:) repository.\n</details>",
 		},
 		{
-			name:                "lgtm cancel by reviewer collab2",
-			body:                "/lgtm cancel",
-			commenter:           "collab2",
-			currentLabel:        lgtmOne,
-			lgtmComment:         "[REVIEW NOTIFICATION]\n\nThis pull request has been approved by:\n\n- collab1\n\n\n\n\nTo complete the [pull request process](https://prProcessLink), please ask the reviewers in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) to review by filling `/cc @reviewer` in the comment.\nAfter your PR has acquired the required number of LGTMs, you can assign this pull request to the committer in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) by filling  `/assign @committer` in the comment to help you merge this pull request.\n\nThe full list of commands accepted by this bot can be found [here](https://commandHelpLink?repo=org%2Frepo).\n\n<details>\n\nReviewer can indicate their review by writing `/lgtm` in a comment.\nReviewer can cancel approval by writing `/lgtm cancel` in a comment.\n</details>\n\n<!--Review Notification Identifier-->",
-			isCancel:            true,
-			shouldToggle:        true,
-			shouldComment:       true,
-			shouldDeleteComment: true,
-			expectComment:       "org/repo#5:[REVIEW NOTIFICATION]\n\nThis pull request has not been approved.\n\n\nTo complete the [pull request process](https://prProcessLink), please ask the reviewers in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) to review by filling `/cc @reviewer` in the comment.\nAfter your PR has acquired the required number of LGTMs, you can assign this pull request to the committer in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) by filling  `/assign @committer` in the comment to help you merge this pull request.\n\nThe full list of commands accepted by this bot can be found [here](https://commandHelpLink?repo=org%2Frepo).\n\n<details>\n\nReviewer can indicate their review by writing `/lgtm` in a comment.\nReviewer can cancel approval by writing `/lgtm cancel` in a comment.\n</details>\n\n<!--Review Notification Identifier-->",
+			name:              "lgtm cancel by reviewer collab2",
+			body:              "/lgtm cancel",
+			commenter:         "collab2",
+			currentLabel:      lgtmOne,
+			lgtmComment:       "[REVIEW NOTIFICATION]\n\nThis pull request has been approved by:\n\n- collab1\n\n\n\n\nTo complete the [pull request process](https://prProcessLink), please ask the reviewers in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) to review by filling `/cc @reviewer` in the comment.\nAfter your PR has acquired the required number of LGTMs, you can assign this pull request to the committer in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) by filling  `/assign @committer` in the comment to help you merge this pull request.\n\nThe full list of commands accepted by this bot can be found [here](https://commandHelpLink?repo=org%2Frepo).\n\n<details>\n\nReviewer can indicate their review by writing `/lgtm` in a comment.\nReviewer can cancel approval by writing `/lgtm cancel` in a comment.\n</details>\n\n<!--Review Notification Identifier-->",
+			isCancel:          true,
+			shouldToggle:      true,
+			shouldComment:     true,
+			shouldEditComment: true,
+			expectComment:     "org/repo#5:[REVIEW NOTIFICATION]\n\nThis pull request has not been approved.\n\n\nTo complete the [pull request process](https://prProcessLink), please ask the reviewers in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) to review by filling `/cc @reviewer` in the comment.\nAfter your PR has acquired the required number of LGTMs, you can assign this pull request to the committer in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) by filling  `/assign @committer` in the comment to help you merge this pull request.\n\nThe full list of commands accepted by this bot can be found [here](https://commandHelpLink?repo=org%2Frepo).\n\n<details>\n\nReviewer can indicate their review by writing `/lgtm` in a comment.\nReviewer can cancel approval by writing `/lgtm cancel` in a comment.\n</details>\n\n<!--Review Notification Identifier-->",
 		},
 		{
 			name:          "lgtm cancel by random",
@@ -145,31 +174,31 @@ func TestLGTMIssueAndReviewComment(t *testing.T) {
 			isCancel:      true,
 			shouldToggle:  false,
 			shouldComment: true,
-			expectComment: "org/repo#5:@not-in-the-org: `/lgtm cancel` is only allowed for the PR author or the reviewers in [list](https://tichiWebLink/repos/org/repo/pulls/5/owners).\n\n<details>\n\nIn response to [this](<url>):\n\n>/lgtm cancel\n\n\nInstructions for interacting with me using PR comments are available [here](https://prow.tidb.io/command-help).  If you have questions or suggestions related to my behavior, please file an issue against the [ti-community-infra/tichi](https://github.com/ti-community-infra/tichi/issues/new?title=Prow%20issue:) repository.\n</details>",
+			expectComment: "org/repo#5:@not-in-the-org: `/lgtm cancel` is only allowed for the PR author or the reviewers in [list](https://tichiWebLink/repos/org/repo/pulls/5/owners).\n\n<details>\n\nIn response to [this](<url>):\n\n>/lgtm cancel\n\n\nInstructions for interacting with me using PR comments are available [here](https://commandHelpLink).  If you have questions or suggestions related to my behavior, please file an issue against the [ti-community-infra/tichi](https://github.com/ti-community-infra/tichi/issues/new?title=Prow%20issue:) repository.\n</details>",
 		},
 		{
-			name:                "lgtm cancel comment by reviewer collab1",
-			body:                "/lgtm cancel",
-			commenter:           "collab1",
-			currentLabel:        lgtmOne,
-			lgtmComment:         "[REVIEW NOTIFICATION]\n\nThis pull request has been approved by:\n\n- collab1\n\n\n\n\nTo complete the [pull request process](https://prProcessLink), please ask the reviewers in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) to review by filling `/cc @reviewer` in the comment.\nAfter your PR has acquired the required number of LGTMs, you can assign this pull request to the committer in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) by filling  `/assign @committer` in the comment to help you merge this pull request.\n\nThe full list of commands accepted by this bot can be found [here](https://commandHelpLink?repo=org%2Frepo).\n\n<details>\n\nReviewer can indicate their review by writing `/lgtm` in a comment.\nReviewer can cancel approval by writing `/lgtm cancel` in a comment.\n</details>\n\n<!--Review Notification Identifier-->",
-			isCancel:            true,
-			shouldToggle:        true,
-			shouldComment:       true,
-			shouldDeleteComment: true,
-			expectComment:       "org/repo#5:[REVIEW NOTIFICATION]\n\nThis pull request has not been approved.\n\n\nTo complete the [pull request process](https://prProcessLink), please ask the reviewers in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) to review by filling `/cc @reviewer` in the comment.\nAfter your PR has acquired the required number of LGTMs, you can assign this pull request to the committer in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) by filling  `/assign @committer` in the comment to help you merge this pull request.\n\nThe full list of commands accepted by this bot can be found [here](https://commandHelpLink?repo=org%2Frepo).\n\n<details>\n\nReviewer can indicate their review by writing `/lgtm` in a comment.\nReviewer can cancel approval by writing `/lgtm cancel` in a comment.\n</details>\n\n<!--Review Notification Identifier-->",
+			name:              "lgtm cancel comment by reviewer collab1",
+			body:              "/lgtm cancel",
+			commenter:         "collab1",
+			currentLabel:      lgtmOne,
+			lgtmComment:       "[REVIEW NOTIFICATION]\n\nThis pull request has been approved by:\n\n- collab1\n\n\n\n\nTo complete the [pull request process](https://prProcessLink), please ask the reviewers in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) to review by filling `/cc @reviewer` in the comment.\nAfter your PR has acquired the required number of LGTMs, you can assign this pull request to the committer in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) by filling  `/assign @committer` in the comment to help you merge this pull request.\n\nThe full list of commands accepted by this bot can be found [here](https://commandHelpLink?repo=org%2Frepo).\n\n<details>\n\nReviewer can indicate their review by writing `/lgtm` in a comment.\nReviewer can cancel approval by writing `/lgtm cancel` in a comment.\n</details>\n\n<!--Review Notification Identifier-->",
+			isCancel:          true,
+			shouldToggle:      true,
+			shouldComment:     true,
+			shouldEditComment: true,
+			expectComment:     "org/repo#5:[REVIEW NOTIFICATION]\n\nThis pull request has not been approved.\n\n\nTo complete the [pull request process](https://prProcessLink), please ask the reviewers in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) to review by filling `/cc @reviewer` in the comment.\nAfter your PR has acquired the required number of LGTMs, you can assign this pull request to the committer in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) by filling  `/assign @committer` in the comment to help you merge this pull request.\n\nThe full list of commands accepted by this bot can be found [here](https://commandHelpLink?repo=org%2Frepo).\n\n<details>\n\nReviewer can indicate their review by writing `/lgtm` in a comment.\nReviewer can cancel approval by writing `/lgtm cancel` in a comment.\n</details>\n\n<!--Review Notification Identifier-->",
 		},
 		{
-			name:                "lgtm cancel comment by reviewer collab1, with trailing space",
-			body:                "/lgtm cancel \r",
-			commenter:           "collab1",
-			lgtmComment:         "[REVIEW NOTIFICATION]\n\nThis pull request has been approved by:\n\n- collab1\n\n\n\n\nTo complete the [pull request process](https://prProcessLink), please ask the reviewers in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) to review by filling `/cc @reviewer` in the comment.\nAfter your PR has acquired the required number of LGTMs, you can assign this pull request to the committer in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) by filling  `/assign @committer` in the comment to help you merge this pull request.\n\nThe full list of commands accepted by this bot can be found [here](https://commandHelpLink?repo=org%2Frepo).\n\n<details>\n\nReviewer can indicate their review by writing `/lgtm` in a comment.\nReviewer can cancel approval by writing `/lgtm cancel` in a comment.\n</details>\n\n<!--Review Notification Identifier-->",
-			currentLabel:        lgtmOne,
-			isCancel:            true,
-			shouldToggle:        true,
-			shouldComment:       true,
-			shouldDeleteComment: true,
-			expectComment:       "org/repo#5:[REVIEW NOTIFICATION]\n\nThis pull request has not been approved.\n\n\nTo complete the [pull request process](https://prProcessLink), please ask the reviewers in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) to review by filling `/cc @reviewer` in the comment.\nAfter your PR has acquired the required number of LGTMs, you can assign this pull request to the committer in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) by filling  `/assign @committer` in the comment to help you merge this pull request.\n\nThe full list of commands accepted by this bot can be found [here](https://commandHelpLink?repo=org%2Frepo).\n\n<details>\n\nReviewer can indicate their review by writing `/lgtm` in a comment.\nReviewer can cancel approval by writing `/lgtm cancel` in a comment.\n</details>\n\n<!--Review Notification Identifier-->",
+			name:              "lgtm cancel comment by reviewer collab1, with trailing space",
+			body:              "/lgtm cancel \r",
+			commenter:         "collab1",
+			lgtmComment:       "[REVIEW NOTIFICATION]\n\nThis pull request has been approved by:\n\n- collab1\n\n\n\n\nTo complete the [pull request process](https://prProcessLink), please ask the reviewers in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) to review by filling `/cc @reviewer` in the comment.\nAfter your PR has acquired the required number of LGTMs, you can assign this pull request to the committer in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) by filling  `/assign @committer` in the comment to help you merge this pull request.\n\nThe full list of commands accepted by this bot can be found [here](https://commandHelpLink?repo=org%2Frepo).\n\n<details>\n\nReviewer can indicate their review by writing `/lgtm` in a comment.\nReviewer can cancel approval by writing `/lgtm cancel` in a comment.\n</details>\n\n<!--Review Notification Identifier-->",
+			currentLabel:      lgtmOne,
+			isCancel:          true,
+			shouldToggle:      true,
+			shouldComment:     true,
+			shouldEditComment: true,
+			expectComment:     "org/repo#5:[REVIEW NOTIFICATION]\n\nThis pull request has not been approved.\n\n\nTo complete the [pull request process](https://prProcessLink), please ask the reviewers in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) to review by filling `/cc @reviewer` in the comment.\nAfter your PR has acquired the required number of LGTMs, you can assign this pull request to the committer in the [list](https://tichiWebLink/repos/org/repo/pulls/5/owners) by filling  `/assign @committer` in the comment to help you merge this pull request.\n\nThe full list of commands accepted by this bot can be found [here](https://commandHelpLink?repo=org%2Frepo).\n\n<details>\n\nReviewer can indicate their review by writing `/lgtm` in a comment.\nReviewer can cancel approval by writing `/lgtm cancel` in a comment.\n</details>\n\n<!--Review Notification Identifier-->",
 		},
 		{
 			name:          "lgtm cancel comment by reviewer collab1, no lgtm",
@@ -194,7 +223,7 @@ func TestLGTMIssueAndReviewComment(t *testing.T) {
 			currentLabel:  lgtmTwo,
 			shouldToggle:  false,
 			shouldComment: true,
-			expectComment: "org/repo#5:@not-in-the-org: `/lgtm` is only allowed for the reviewers in [list](https://tichiWebLink/repos/org/repo/pulls/5/owners).\n\n<details>\n\nIn response to [this](<url>):\n\n>/lgtm \n\n\nInstructions for interacting with me using PR comments are available [here](https://prow.tidb.io/command-help).  If you have questions or suggestions related to my behavior, please file an issue against the [ti-community-infra/tichi](https://github.com/ti-community-infra/tichi/issues/new?title=Prow%20issue:) repository.\n</details>",
+			expectComment: "org/repo#5:@not-in-the-org: `/lgtm` is only allowed for the reviewers in [list](https://tichiWebLink/repos/org/repo/pulls/5/owners).\n\n<details>\n\nIn response to [this](<url>):\n\n>/lgtm \n\n\nInstructions for interacting with me using PR comments are available [here](https://commandHelpLink).  If you have questions or suggestions related to my behavior, please file an issue against the [ti-community-infra/tichi](https://github.com/ti-community-infra/tichi/issues/new?title=Prow%20issue:) repository.\n</details>",
 		},
 		{
 			name:          "lgtm comment by reviewer collab1, lgtm twice",
@@ -228,20 +257,21 @@ func TestLGTMIssueAndReviewComment(t *testing.T) {
 			needsLgtm: 2,
 		}
 
-		checkResult := func(tc *commentCase, fc *fakegithub.FakeClient) {
-			if !tc.shouldComment && len(fc.IssueCommentsAdded) != 0 {
-				t.Errorf("unexpected comment %v", fc.IssueCommentsAdded)
+		checkResult := func(tc *commentCase, fc *fakeGitHubClient) {
+			if !tc.shouldComment && len(fc.IssueCommentsAdded)+len(fc.IssueCommentsEdited) != 0 {
+				t.Errorf("unexpected comment %v %v", fc.IssueCommentsAdded, fc.IssueCommentsEdited)
 			}
 
-			if tc.shouldComment && tc.expectComment != fc.IssueCommentsAdded[0] {
-				t.Fatalf("review notifications mismatch: got %q, want %q", fc.IssueCommentsAdded[0], tc.expectComment)
+			// The existing notification is edited in place.
+			comments := fc.IssueCommentsAdded
+			if tc.shouldEditComment {
+				comments = fc.IssueCommentsEdited
 			}
-
-			if tc.shouldDeleteComment && len(fc.IssueCommentsDeleted) == 0 {
-				t.Errorf("expected to delete comments but didn't.")
+			if tc.shouldComment && (len(comments) == 0 || tc.expectComment != comments[0]) {
+				t.Fatalf("review notifications mismatch: got %q, want %q", comments, tc.expectComment)
 			}
 
-			if !tc.shouldDeleteComment && len(fc.IssueCommentsDeleted) != 0 {
+			if len(fc.IssueCommentsDeleted) != 0 {
 				t.Errorf("expected not to delete comments but deleted.")
 			}
 
@@ -266,7 +296,7 @@ func TestLGTMIssueAndReviewComment(t *testing.T) {
 
 		// Test issue comments.
 		{
-			fc := &fakegithub.FakeClient{
+			fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
 				IssueComments: map[int][]github.IssueComment{
 					5: {{
 						Body: tc.lgtmComment,
@@ -294,7 +324,7 @@ func TestLGTMIssueAndReviewComment(t *testing.T) {
 					},
 				},
 				Collaborators: []string{"collab1", "collab2"},
-			}
+			}}
 
 			e := &github.IssueCommentEvent{
 				Action: github.IssueCommentActionCreated,
@@ -326,7 +356,7 @@ func TestLGTMIssueAndReviewComment(t *testing.T) {
 
 		// Test review comments.
 		{
-			fc := &fakegithub.FakeClient{
+			fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
 				IssueComments: map[int][]github.IssueComment{
 					5: {{
 						Body: tc.lgtmComment,
@@ -354,7 +384,7 @@ func TestLGTMIssueAndReviewComment(t *testing.T) {
 					},
 				},
 				Collaborators: []string{"collab1", "collab2"},
-			}
+			}}
 
 			e := &github.ReviewCommentEvent{
 				Action: github.ReviewCommentActionCreated,
@@ -487,7 +517,7 @@ func TestLGTMFromApproveReview(t *testing.T) {
 	}
 	SHA := "0bd3ed50c88cd53a09316bf7a298f900e9371652"
 	for _, tc := range testcases {
-		fc := &fakegithub.FakeClient{
+		fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
 			IssueComments:    make(map[int][]github.IssueComment),
 			IssueLabelsAdded: []string{},
 			PullRequests: map[int]*github.PullRequest{
@@ -498,7 +528,7 @@ func TestLGTMFromApproveReview(t *testing.T) {
 				},
 			},
 			Collaborators: []string{"collab1", "collab2"},
-		}
+		}}
 		e := &github.ReviewEvent{
 			Action: tc.action,
 			Review: github.Review{Body: tc.body, State: tc.state, HTMLURL: "<url>", User: github.User{Login: tc.reviewer}},
@@ -610,20 +640,20 @@ func TestHandlePullRequest(t *testing.T) {
 
 	for _, testcase := range testcases {
 		tc := testcase
-		fc := &fakegithub.FakeClient{
+		fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
 			IssueComments:    make(map[int][]github.IssueComment),
 			IssueLabelsAdded: []string{},
 			PullRequests: map[int]*github.PullRequest{
 				101: &tc.event.PullRequest,
 			},
-		}
+		}}
 		cfg := &externalplugins.Configuration{
 			TichiWebURL:     "https://tichiWebLink",
 			CommandHelpLink: "https://commandHelpLink",
 			PRProcessLink:   "https://prProcessLink",
 		}
 
-		err := HandlePullRequestEvent(fc, &tc.event, cfg, logrus.WithField("plugin", PluginName))
+		err := HandlePullRequestEvent(fc, nil, &tc.event, cfg, &fakeOwnersClient{}, logrus.WithField("plugin", PluginName))
 		if err != nil {
 			t.Errorf("For case %s, didn't expect error: %v", tc.name, err)
 		}
@@ -638,75 +668,860 @@ func TestHandlePullRequest(t *testing.T) {
 	}
 }
 
-func TestGetCurrentAndNextLabel(t *testing.T) {
+func TestHandlePullRequestSynchronize(t *testing.T) {
+	notificationBody := "[REVIEW NOTIFICATION]\n\nThis pull request has been approved by:\n\n- collab1\n- collab2\n\n" +
+		"%s<!--Review Notification Identifier-->"
+
+	testcases := []struct {
+		name                string
+		policy              string
+		currentLabel        string
+		withoutApprovedHead bool
+		push                func(r *testutil.FakeRepo)
+
+		shouldRemoveLabel bool
+		expectComment     string
+	}{
+		{
+			name:         "keep policy",
+			policy:       externalplugins.LgtmResetPolicyKeep,
+			currentLabel: lgtmTwo,
+			push: func(r *testutil.FakeRepo) {
+				r.Checkout(testutil.PRBranch)
+				r.Commit(map[string]string{"pr": "b\n"})
+			},
+			shouldRemoveLabel: false,
+		},
+		{
+			name:              "reset policy without LGTM label",
+			policy:            externalplugins.LgtmResetPolicyReset,
+			shouldRemoveLabel: false,
+		},
+		{
+			name:         "reset policy",
+			policy:       externalplugins.LgtmResetPolicyReset,
+			currentLabel: lgtmTwo,
+			push: func(r *testutil.FakeRepo) {
+				r.Checkout(testutil.PRBranch)
+				r.Git("commit", "--allow-empty", "-m", "empty")
+			},
+			shouldRemoveLabel: true,
+			expectComment:     "org/repo#101:@author: New commits have been pushed, so the approvals from collab1, collab2 have been reset.",
+		},
+		{
+			name:         "reset unless trivial policy, new commit changes the tree",
+			policy:       externalplugins.LgtmResetPolicyResetUnlessTrivial,
+			currentLabel: lgtmTwo,
+			push: func(r *testutil.FakeRepo) {
+				r.Checkout(testutil.PRBranch)
+				r.Commit(map[string]string{"pr": "b\n"})
+			},
+			shouldRemoveLabel: true,
+			expectComment:     "org/repo#101:@author: New commits have been pushed, so the approvals from collab1, collab2 have been reset.",
+		},
+		{
+			name:         "reset unless trivial policy, new commit keeps the tree",
+			policy:       externalplugins.LgtmResetPolicyResetUnlessTrivial,
+			currentLabel: lgtmTwo,
+			push: func(r *testutil.FakeRepo) {
+				r.Checkout(testutil.PRBranch)
+				r.Git("commit", "--allow-empty", "-m", "empty")
+			},
+			shouldRemoveLabel: false,
+		},
+		{
+			name:         "reset unless trivial policy, new commit merges the base",
+			policy:       externalplugins.LgtmResetPolicyResetUnlessTrivial,
+			currentLabel: lgtmTwo,
+			push: func(r *testutil.FakeRepo) {
+				r.Commit(map[string]string{"base": "0\n1\n2\n3\n"})
+				r.Checkout(testutil.PRBranch)
+				r.Git("merge", "--no-edit", "master")
+			},
+			shouldRemoveLabel: false,
+		},
+		{
+			name:         "reset unless trivial policy, rebased onto the base",
+			policy:       externalplugins.LgtmResetPolicyResetUnlessTrivial,
+			currentLabel: lgtmTwo,
+			push: func(r *testutil.FakeRepo) {
+				r.Commit(map[string]string{"base": "0\n1\n2\n3\n"})
+				r.Checkout(testutil.PRBranch)
+				r.Git("rebase", "master")
+			},
+			shouldRemoveLabel: false,
+		},
+		{
+			name:         "reset unless trivial policy, merge commit contains other changes",
+			policy:       externalplugins.LgtmResetPolicyResetUnlessTrivial,
+			currentLabel: lgtmTwo,
+			push: func(r *testutil.FakeRepo) {
+				r.Commit(map[string]string{"base": "0\n1\n2\n3\n"})
+				r.Checkout(testutil.PRBranch)
+				r.Git("merge", "--no-commit", "master")
+				r.Commit(map[string]string{"pr": "b\n"})
+			},
+			shouldRemoveLabel: true,
+			expectComment:     "org/repo#101:@author: New commits have been pushed, so the approvals from collab1, collab2 have been reset.",
+		},
+		{
+			name:                "reset unless trivial policy, the approved head is unknown",
+			policy:              externalplugins.LgtmResetPolicyResetUnlessTrivial,
+			currentLabel:        lgtmTwo,
+			withoutApprovedHead: true,
+			push: func(r *testutil.FakeRepo) {
+				r.Checkout(testutil.PRBranch)
+				r.Git("commit", "--allow-empty", "-m", "empty")
+			},
+			shouldRemoveLabel: true,
+			expectComment:     "org/repo#101:@author: New commits have been pushed, so the approvals from collab1, collab2 have been reset.",
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			r, gitClient := testutil.NewFakeRepo(t)
+			approvedHead := fmt.Sprintf("<!--Approved Head: %s-->\n\n", r.Git("rev-parse", "pr"))
+			if tc.withoutApprovedHead {
+				approvedHead = ""
+			}
+			if tc.push != nil {
+				tc.push(r)
+				r.UpdatePR()
+			}
+
+			event := github.PullRequestEvent{
+				Action: github.PullRequestActionSynchronize,
+				PullRequest: github.PullRequest{
+					Number: 101,
+					User:   github.User{Login: "author"},
+					Base: github.PullRequestBranch{
+						Ref: "master",
+						Repo: github.Repo{
+							Owner: github.User{Login: "org"},
+							Name:  "repo",
+						},
+					},
+					Head: github.PullRequestBranch{SHA: r.Git("rev-parse", "refs/pull/101/head")},
+				},
+			}
+			fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
+				IssueComments: map[int][]github.IssueComment{
+					101: {
+						{
+							ID:   1,
+							Body: fmt.Sprintf(notificationBody, approvedHead),
+							User: github.User{Login: fakegithub.Bot},
+						},
+					},
+				},
+			}}
+			if tc.currentLabel != "" {
+				fc.IssueLabelsAdded = []string{"org/repo#101:" + tc.currentLabel}
+			}
+			cfg := &externalplugins.Configuration{
+				TichiWebURL:     "https://tichiWebLink",
+				CommandHelpLink: "https://commandHelpLink",
+				PRProcessLink:   "https://prProcessLink",
+				TiCommunityLgtm: []externalplugins.TiCommunityLgtm{
+					{
+						Repos:           []string{"org/repo"},
+						PushResetPolicy: tc.policy,
+					},
+				},
+			}
+
+			err := HandlePullRequestEvent(fc, gitClient, &event, cfg, &fakeOwnersClient{},
+				logrus.WithField("plugin", PluginName))
+			if err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+
+			if tc.shouldRemoveLabel {
+				if len(fc.IssueLabelsRemoved) != 1 || fc.IssueLabelsRemoved[0] != "org/repo#101:"+tc.currentLabel {
+					t.Fatalf("expected the LGTM label to be removed, got %v", fc.IssueLabelsRemoved)
+				}
+				if len(fc.IssueCommentsEdited) != 1 {
+					t.Fatalf("expected the old notification to be edited, got %v", fc.IssueCommentsEdited)
+				}
+				if strings.Contains(fc.IssueCommentsEdited[0], "Approved Head") {
+					t.Fatalf("expected the approved head to be dropped, got %q", fc.IssueCommentsEdited[0])
+				}
+				if !strings.Contains(fc.IssueCommentsEdited[0], "<!--Approvals Reset: "+event.PullRequest.Head.SHA+" ") {
+					t.Fatalf("expected the reset push to be recorded, got %q", fc.IssueCommentsEdited[0])
+				}
+				if len(fc.IssueCommentsAdded) != 1 {
+					t.Fatalf("expected a reset comment, got %v", fc.IssueCommentsAdded)
+				}
+				if !strings.HasPrefix(fc.IssueCommentsAdded[0], tc.expectComment) {
+					t.Fatalf("reset comment mismatch: got %q, want prefix %q", fc.IssueCommentsAdded[0], tc.expectComment)
+				}
+			} else {
+				if len(fc.IssueLabelsRemoved) != 0 {
+					t.Fatalf("unexpected label removed: %v", fc.IssueLabelsRemoved)
+				}
+				if len(fc.IssueCommentsAdded)+len(fc.IssueCommentsEdited) != 0 {
+					t.Fatalf("unexpected comment: %v %v", fc.IssueCommentsAdded, fc.IssueCommentsEdited)
+				}
+			}
+		})
+	}
+}
+
+func TestWeightedLGTM(t *testing.T) {
+	linkConfig := &externalplugins.Configuration{
+		CommandHelpLink: "https://commandHelpLink",
+		PRProcessLink:   "https://prProcessLink",
+	}
+	notificationFor := func(reviewers ...string) string {
+		msg, err := getMessage(linkConfig, nil, reviewers, "https://tichiWebLink/repos/org/repo/pulls/5/owners", "org", "repo")
+		if err != nil {
+			t.Fatalf("failed to generate notification: %v", err)
+		}
+		return *msg
+	}
+
+	testcases := []struct {
+		name                  string
+		commenter             string
+		currentLabel          string
+		approvers             []string
+		roleWeights           map[string]int
+		requiredCommitterLgtm int
+		lgtmLabel             string
+		lgtmCountdown         bool
+
+		expectAddedLabel string
+	}{
+		{
+			name:             "leader's LGTM counts double",
+			commenter:        "leader1",
+			roleWeights:      map[string]int{ownersclient.RoleLeader: 2},
+			expectAddedLabel: lgtmTwo,
+		},
+		{
+			name:             "reviewer's LGTM counts once",
+			commenter:        "reviewer1",
+			roleWeights:      map[string]int{ownersclient.RoleLeader: 2},
+			expectAddedLabel: lgtmOne,
+		},
+		{
+			name:                  "enough LGTMs but no committer LGTM",
+			commenter:             "committer1",
+			currentLabel:          lgtmTwo,
+			approvers:             []string{"reviewer1", "reviewer2"},
+			requiredCommitterLgtm: 1,
+			expectAddedLabel:      externalplugins.LgtmLabelPrefix + "3",
+		},
+		{
+			name:                  "enough LGTMs including committer LGTM",
+			commenter:             "reviewer2",
+			currentLabel:          lgtmTwo,
+			approvers:             []string{"committer1", "reviewer1"},
+			requiredCommitterLgtm: 1,
+		},
+		{
+			name:             "leader's LGTM with custom label",
+			commenter:        "leader1",
+			roleWeights:      map[string]int{ownersclient.RoleLeader: 2},
+			lgtmLabel:        "approved-by-{n}",
+			expectAddedLabel: "approved-by-2",
+		},
+		{
+			name:             "first LGTM with label without number",
+			commenter:        "reviewer1",
+			lgtmLabel:        "lgtm",
+			expectAddedLabel: "lgtm",
+		},
+		{
+			name:             "first LGTM with countdown label",
+			commenter:        "reviewer1",
+			lgtmLabel:        "needs-{n}-lgtm",
+			lgtmCountdown:    true,
+			expectAddedLabel: "needs-1-lgtm",
+		},
+		{
+			name:             "enough LGTMs with countdown label",
+			commenter:        "reviewer2",
+			currentLabel:     "needs-1-lgtm",
+			approvers:        []string{"reviewer1"},
+			lgtmLabel:        "needs-{n}-lgtm",
+			lgtmCountdown:    true,
+			expectAddedLabel: "needs-0-lgtm",
+		},
+		{
+			name:          "LGTM ignored when countdown label reaches zero",
+			commenter:     "reviewer2",
+			currentLabel:  "needs-0-lgtm",
+			approvers:     []string{"leader1"},
+			roleWeights:   map[string]int{ownersclient.RoleLeader: 2},
+			lgtmLabel:     "needs-{n}-lgtm",
+			lgtmCountdown: true,
+		},
+		{
+			name:         "enough LGTMs counted from notification with label without number",
+			commenter:    "reviewer2",
+			currentLabel: "lgtm",
+			approvers:    []string{"leader1"},
+			roleWeights:  map[string]int{ownersclient.RoleLeader: 2},
+			lgtmLabel:    "lgtm",
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
+				IssueComments: map[int][]github.IssueComment{},
+			}}
+			if tc.currentLabel != "" {
+				fc.IssueLabelsAdded = []string{"org/repo#5:" + tc.currentLabel}
+			}
+			if tc.approvers != nil {
+				fc.IssueComments[5] = []github.IssueComment{
+					{ID: 1, Body: notificationFor(tc.approvers...), User: github.User{Login: fakegithub.Bot}},
+				}
+			}
+			cfg := &externalplugins.Configuration{
+				TichiWebURL:     "https://tichiWebLink",
+				CommandHelpLink: "https://commandHelpLink",
+				PRProcessLink:   "https://prProcessLink",
+				TiCommunityLgtm: []externalplugins.TiCommunityLgtm{
+					{
+						Repos:                 []string{"org/repo"},
+						RoleWeights:           tc.roleWeights,
+						RequiredCommitterLgtm: tc.requiredCommitterLgtm,
+					},
+				},
+				LabelSchemes: []externalplugins.LabelScheme{
+					{
+						Repos:              []string{"org/repo"},
+						LgtmLabel:          tc.lgtmLabel,
+						LgtmLabelCountdown: tc.lgtmCountdown,
+					},
+				},
+			}
+			foc := &fakeOwnersClient{
+				committers: []string{"leader1", "committer1"},
+				reviewers:  []string{"leader1", "committer1", "reviewer1", "reviewer2"},
+				needsLgtm:  2,
+				roles: map[string]string{
+					"leader1":    ownersclient.RoleLeader,
+					"committer1": ownersclient.RoleCommitter,
+					"reviewer1":  ownersclient.RoleReviewer,
+					"reviewer2":  ownersclient.RoleReviewer,
+				},
+			}
+
+			err := HandleIssueCommentEvent(fc, newIssueCommentEvent(github.IssueCommentActionCreated, tc.commenter, "/lgtm"),
+				cfg, foc, logrus.WithField("plugin", PluginName))
+			if err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+
+			var addedLabels []string
+			for _, label := range fc.IssueLabelsAdded {
+				if tc.currentLabel == "" || label != "org/repo#5:"+tc.currentLabel {
+					addedLabels = append(addedLabels, label)
+				}
+			}
+			if tc.expectAddedLabel == "" {
+				if len(addedLabels) != 0 {
+					t.Errorf("expected no label added, but got %v", addedLabels)
+				}
+				return
+			}
+			if len(addedLabels) != 1 || addedLabels[0] != "org/repo#5:"+tc.expectAddedLabel {
+				t.Errorf("added labels mismatch: got %v, want %s", addedLabels, tc.expectAddedLabel)
+			}
+		})
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	baseTime := time.Date(2021, 2, 21, 12, 30, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return baseTime.Add(time.Duration(minutes) * time.Minute)
+	}
+	resetNotificationFor := func(resetAt time.Time, reviewers ...string) string {
+		linkConfig := &externalplugins.Configuration{
+			CommandHelpLink: "https://commandHelpLink",
+			PRProcessLink:   "https://prProcessLink",
+		}
+		state := &externalplugins.ReviewNotification{Approvers: reviewers}
+		if !resetAt.IsZero() {
+			state.ResetHead = "1a2b3c"
+			state.ResetAt = resetAt
+		}
+		msg, err := linkConfig.RenderReviewNotification("org", "repo", nil, state, "https://tichiWebLink/repos/org/repo/pulls/5/owners")
+		if err != nil {
+			t.Fatalf("failed to generate notification: %v", err)
+		}
+		return msg
+	}
+	notificationFor := func(reviewers ...string) string {
+		return resetNotificationFor(time.Time{}, reviewers...)
+	}
+
+	testcases := []struct {
+		name           string
+		currentLabel   string
+		issueComments  []github.IssueComment
+		reviewComments []github.ReviewComment
+		reviews        []github.Review
+		resetPolicy    string
+
+		expectAddedLabels      []string
+		expectRemovedLabels    []string
+		expectNotification     bool
+		expectNotificationBody string
+	}{
+		{
+			name:         "consistent state",
+			currentLabel: lgtmOne,
+			issueComments: []github.IssueComment{
+				{ID: 1, Body: "/lgtm", User: github.User{Login: "collab1"}, CreatedAt: at(1)},
+				{ID: 2, Body: notificationFor("collab1"), User: github.User{Login: fakegithub.Bot}, CreatedAt: at(2)},
+			},
+		},
+		{
+			name:         "label is not added after a crash",
+			currentLabel: lgtmOne,
+			issueComments: []github.IssueComment{
+				{ID: 1, Body: "/lgtm", User: github.User{Login: "collab1"}, CreatedAt: at(1)},
+				{ID: 2, Body: "/lgtm", User: github.User{Login: "collab2"}, CreatedAt: at(2)},
+				{ID: 3, Body: notificationFor("collab1", "collab2"), User: github.User{Login: fakegithub.Bot}, CreatedAt: at(3)},
+			},
+			expectAddedLabels:   []string{"org/repo#5:" + lgtmTwo},
+			expectRemovedLabels: []string{"org/repo#5:" + lgtmOne},
+		},
+		{
+			name: "missed webhook of review comment",
+			issueComments: []github.IssueComment{
+				{ID: 2, Body: notificationFor(), User: github.User{Login: fakegithub.Bot}, CreatedAt: at(2)},
+			},
+			reviewComments: []github.ReviewComment{
+				{ID: 1, Body: "/lgtm", User: github.User{Login: "collab1"}, CreatedAt: at(1)},
+			},
+			expectAddedLabels:      []string{"org/repo#5:" + lgtmOne},
+			expectNotification:     true,
+			expectNotificationBody: notificationFor("collab1"),
+		},
+		{
+			name:         "lgtm is canceled by the author",
+			currentLabel: lgtmOne,
+			issueComments: []github.IssueComment{
+				{ID: 1, Body: "/lgtm", User: github.User{Login: "collab1"}, CreatedAt: at(1)},
+				{ID: 2, Body: notificationFor("collab1"), User: github.User{Login: fakegithub.Bot}, CreatedAt: at(2)},
+				{ID: 3, Body: "/lgtm cancel", User: github.User{Login: "author"}, CreatedAt: at(3)},
+			},
+			expectRemovedLabels:    []string{"org/repo#5:" + lgtmOne},
+			expectNotification:     true,
+			expectNotificationBody: notificationFor(),
+		},
+		{
+			name: "lgtm from the author and random users are ignored",
+			issueComments: []github.IssueComment{
+				{ID: 1, Body: "/lgtm", User: github.User{Login: "author"}, CreatedAt: at(1)},
+				{ID: 2, Body: "/lgtm", User: github.User{Login: "not-in-the-org"}, CreatedAt: at(2)},
+				{ID: 3, Body: notificationFor(), User: github.User{Login: fakegithub.Bot}, CreatedAt: at(3)},
+			},
+		},
+		{
+			name: "approved review",
+			issueComments: []github.IssueComment{
+				{ID: 2, Body: notificationFor(), User: github.User{Login: fakegithub.Bot}, CreatedAt: at(2)},
+			},
+			reviews: []github.Review{
+				{ID: 1, State: github.ReviewStateApproved, User: github.User{Login: "collab2"}, SubmittedAt: at(1)},
+			},
+			expectAddedLabels:      []string{"org/repo#5:" + lgtmOne},
+			expectNotification:     true,
+			expectNotificationBody: notificationFor("collab2"),
+		},
+		{
+			name:        "approvals before the push are not restored",
+			resetPolicy: externalplugins.LgtmResetPolicyReset,
+			issueComments: []github.IssueComment{
+				{ID: 1, Body: "/lgtm", User: github.User{Login: "collab1"}, CreatedAt: at(1)},
+				// The notification is edited later, which does not move the reset point.
+				{
+					ID: 2, Body: resetNotificationFor(at(3)), User: github.User{Login: fakegithub.Bot},
+					CreatedAt: at(2), UpdatedAt: at(10),
+				},
+			},
+		},
+		{
+			name:        "approvals after the push are kept",
+			resetPolicy: externalplugins.LgtmResetPolicyReset,
+			issueComments: []github.IssueComment{
+				{ID: 1, Body: "/lgtm", User: github.User{Login: "collab1"}, CreatedAt: at(1)},
+				{ID: 2, Body: resetNotificationFor(at(3)), User: github.User{Login: fakegithub.Bot}, CreatedAt: at(2)},
+			},
+			reviewComments: []github.ReviewComment{
+				{ID: 3, Body: "/lgtm", User: github.User{Login: "collab2"}, CreatedAt: at(4)},
+			},
+			expectAddedLabels:      []string{"org/repo#5:" + lgtmOne},
+			expectNotification:     true,
+			expectNotificationBody: resetNotificationFor(at(3), "collab2"),
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
+				IssueComments: map[int][]github.IssueComment{
+					5: tc.issueComments,
+				},
+				PullRequestComments: map[int][]github.ReviewComment{
+					5: tc.reviewComments,
+				},
+				Reviews: map[int][]github.Review{
+					5: tc.reviews,
+				},
+			}}
+			if tc.currentLabel != "" {
+				fc.IssueLabelsAdded = []string{"org/repo#5:" + tc.currentLabel}
+			}
+			cfg := &externalplugins.Configuration{
+				TichiWebURL:     "https://tichiWebLink",
+				CommandHelpLink: "https://commandHelpLink",
+				PRProcessLink:   "https://prProcessLink",
+				TiCommunityLgtm: []externalplugins.TiCommunityLgtm{
+					{
+						Repos:            []string{"org/repo"},
+						ReviewActsAsLgtm: true,
+						PushResetPolicy:  tc.resetPolicy,
+					},
+				},
+			}
+			foc := &fakeOwnersClient{
+				reviewers: []string{"collab1", "collab2"},
+				needsLgtm: 2,
+			}
+
+			e := &github.IssueCommentEvent{
+				Action: github.IssueCommentActionCreated,
+				Issue: github.Issue{
+					User:        github.User{Login: "author"},
+					Number:      5,
+					State:       "open",
+					PullRequest: &struct{}{},
+				},
+				Comment: github.IssueComment{
+					Body: "/lgtm refresh",
+					User: github.User{Login: "random"},
+				},
+				Repo: github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+			}
+			if err := HandleIssueCommentEvent(fc, e, cfg, foc, logrus.WithField("plugin", PluginName)); err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+
+			expectAdded := []string{}
+			if tc.currentLabel != "" {
+				expectAdded = append(expectAdded, "org/repo#5:"+tc.currentLabel)
+			}
+			expectAdded = append(expectAdded, tc.expectAddedLabels...)
+			if got, want := strings.Join(fc.IssueLabelsAdded, ","), strings.Join(expectAdded, ","); got != want {
+				t.Errorf("added labels mismatch: got %v, want %v", got, want)
+			}
+			if got, want := strings.Join(fc.IssueLabelsRemoved, ","), strings.Join(tc.expectRemovedLabels, ","); got != want {
+				t.Errorf("removed labels mismatch: got %v, want %v", got, want)
+			}
+
+			if !tc.expectNotification {
+				if len(fc.IssueCommentsAdded)+len(fc.IssueCommentsEdited) != 0 {
+					t.Errorf("unexpected comments: %v %v", fc.IssueCommentsAdded, fc.IssueCommentsEdited)
+				}
+				return
+			}
+			if len(fc.IssueCommentsEdited) != 1 || fc.IssueCommentsEdited[0] != "org/repo#5:"+tc.expectNotificationBody {
+				t.Errorf("notification mismatch: got %v, want %q", fc.IssueCommentsEdited, tc.expectNotificationBody)
+			}
+			if len(fc.IssueCommentsAdded) != 0 {
+				t.Errorf("expected the old notification to be edited in place, got comments %v", fc.IssueCommentsAdded)
+			}
+
+			// Reconciling again should be a no-op.
+			fc.IssueCommentsEdited = nil
+			fc.IssueComments[5] = []github.IssueComment{
+				{ID: 100, Body: tc.expectNotificationBody, User: github.User{Login: fakegithub.Bot}},
+			}
+			if err := HandleIssueCommentEvent(fc, e, cfg, foc, logrus.WithField("plugin", PluginName)); err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+			if len(fc.IssueCommentsAdded)+len(fc.IssueCommentsEdited) != 0 {
+				t.Errorf("expected reconciliation to be idempotent, got comments %v %v",
+					fc.IssueCommentsAdded, fc.IssueCommentsEdited)
+			}
+		})
+	}
+}
+
+func TestHandleChangedEvents(t *testing.T) {
+	baseTime := time.Date(2021, 2, 21, 12, 30, 0, 0, time.UTC)
+	linkConfig := &externalplugins.Configuration{
+		CommandHelpLink: "https://commandHelpLink",
+		PRProcessLink:   "https://prProcessLink",
+	}
+	notificationWithCollab1, err := getMessage(linkConfig, nil, []string{"collab1"},
+		"https://tichiWebLink/repos/org/repo/pulls/5/owners", "org", "repo")
+	if err != nil {
+		t.Fatalf("failed to generate notification: %v", err)
+	}
+
+	testcases := []struct {
+		name          string
+		issueComments []github.IssueComment
+		reviews       []github.Review
+		handle        func(fc *fakeGitHubClient, cfg *externalplugins.Configuration, foc *fakeOwnersClient) error
+
+		expectRemoveLabel bool
+		expectReconcile   bool
+	}{
+		{
+			name: "delete the lgtm comment",
+			issueComments: []github.IssueComment{
+				{ID: 2, Body: *notificationWithCollab1, User: github.User{Login: fakegithub.Bot}, CreatedAt: baseTime},
+			},
+			handle: func(fc *fakeGitHubClient, cfg *externalplugins.Configuration, foc *fakeOwnersClient) error {
+				return HandleIssueCommentEvent(fc, newIssueCommentEvent(github.IssueCommentActionDeleted, "collab1", "/lgtm"),
+					cfg, foc, logrus.WithField("plugin", PluginName))
+			},
+			expectRemoveLabel: true,
+			expectReconcile:   true,
+		},
+		{
+			name: "delete a normal comment",
+			issueComments: []github.IssueComment{
+				{ID: 1, Body: "/lgtm", User: github.User{Login: "collab1"}, CreatedAt: baseTime},
+				{ID: 2, Body: *notificationWithCollab1, User: github.User{Login: fakegithub.Bot}, CreatedAt: baseTime},
+			},
+			handle: func(fc *fakeGitHubClient, cfg *externalplugins.Configuration, foc *fakeOwnersClient) error {
+				return HandleIssueCommentEvent(fc, newIssueCommentEvent(github.IssueCommentActionDeleted, "collab1", "nice"),
+					cfg, foc, logrus.WithField("plugin", PluginName))
+			},
+			expectReconcile: false,
+		},
+		{
+			name: "the bot deletes its notification",
+			issueComments: []github.IssueComment{
+				{ID: 1, Body: "/lgtm", User: github.User{Login: "collab1"}, CreatedAt: baseTime},
+			},
+			handle: func(fc *fakeGitHubClient, cfg *externalplugins.Configuration, foc *fakeOwnersClient) error {
+				return HandleIssueCommentEvent(fc,
+					newIssueCommentEvent(github.IssueCommentActionDeleted, fakegithub.Bot, *notificationWithCollab1),
+					cfg, foc, logrus.WithField("plugin", PluginName))
+			},
+			expectReconcile: false,
+		},
+		{
+			name: "edit the lgtm comment of an approver to remove the command",
+			issueComments: []github.IssueComment{
+				{ID: 1, Body: "looks good", User: github.User{Login: "collab1"}, CreatedAt: baseTime},
+				{ID: 2, Body: *notificationWithCollab1, User: github.User{Login: fakegithub.Bot}, CreatedAt: baseTime},
+			},
+			handle: func(fc *fakeGitHubClient, cfg *externalplugins.Configuration, foc *fakeOwnersClient) error {
+				return HandleIssueCommentEvent(fc, newIssueCommentEvent(github.IssueCommentActionEdited, "collab1", "looks good"),
+					cfg, foc, logrus.WithField("plugin", PluginName))
+			},
+			expectRemoveLabel: true,
+			expectReconcile:   true,
+		},
+		{
+			name: "edit a normal comment of a non-approver",
+			issueComments: []github.IssueComment{
+				{ID: 1, Body: "/lgtm", User: github.User{Login: "collab1"}, CreatedAt: baseTime},
+				{ID: 2, Body: *notificationWithCollab1, User: github.User{Login: fakegithub.Bot}, CreatedAt: baseTime},
+			},
+			handle: func(fc *fakeGitHubClient, cfg *externalplugins.Configuration, foc *fakeOwnersClient) error {
+				return HandleIssueCommentEvent(fc, newIssueCommentEvent(github.IssueCommentActionEdited, "collab2", "nice"),
+					cfg, foc, logrus.WithField("plugin", PluginName))
+			},
+			expectReconcile: false,
+		},
+		{
+			name: "dismiss the approved review",
+			issueComments: []github.IssueComment{
+				{ID: 2, Body: *notificationWithCollab1, User: github.User{Login: fakegithub.Bot}, CreatedAt: baseTime},
+			},
+			reviews: []github.Review{
+				{ID: 1, State: "DISMISSED", User: github.User{Login: "collab1"}, SubmittedAt: baseTime},
+			},
+			handle: func(fc *fakeGitHubClient, cfg *externalplugins.Configuration, foc *fakeOwnersClient) error {
+				return HandlePullReviewEvent(fc, &github.ReviewEvent{
+					Action: github.ReviewActionDismissed,
+					Review: github.Review{
+						User:  github.User{Login: "collab1"},
+						State: "dismissed",
+					},
+					PullRequest: github.PullRequest{
+						User:   github.User{Login: "author"},
+						Number: 5,
+						State:  "open",
+					},
+					Repo: github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				}, cfg, foc, logrus.WithField("plugin", PluginName))
+			},
+			expectRemoveLabel: true,
+			expectReconcile:   true,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
+				IssueComments: map[int][]github.IssueComment{
+					5: tc.issueComments,
+				},
+				Reviews: map[int][]github.Review{
+					5: tc.reviews,
+				},
+				IssueLabelsAdded: []string{"org/repo#5:" + lgtmOne},
+			}}
+			cfg := &externalplugins.Configuration{
+				TichiWebURL:     "https://tichiWebLink",
+				CommandHelpLink: "https://commandHelpLink",
+				PRProcessLink:   "https://prProcessLink",
+				TiCommunityLgtm: []externalplugins.TiCommunityLgtm{
+					{
+						Repos:            []string{"org/repo"},
+						ReviewActsAsLgtm: true,
+					},
+				},
+			}
+			foc := &fakeOwnersClient{
+				reviewers: []string{"collab1", "collab2"},
+				needsLgtm: 2,
+			}
+
+			if err := tc.handle(fc, cfg, foc); err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+
+			if tc.expectRemoveLabel {
+				if len(fc.IssueLabelsRemoved) != 1 || fc.IssueLabelsRemoved[0] != "org/repo#5:"+lgtmOne {
+					t.Errorf("expected the LGTM label to be removed, got %v", fc.IssueLabelsRemoved)
+				}
+			} else if len(fc.IssueLabelsRemoved) != 0 {
+				t.Errorf("unexpected label removed: %v", fc.IssueLabelsRemoved)
+			}
+
+			if tc.expectReconcile && len(fc.IssueCommentsEdited) != 1 {
+				t.Errorf("expected the notification to be edited, got %v", fc.IssueCommentsEdited)
+			}
+			if !tc.expectReconcile && len(fc.IssueCommentsAdded)+len(fc.IssueCommentsEdited) != 0 {
+				t.Errorf("unexpected comments: %v %v", fc.IssueCommentsAdded, fc.IssueCommentsEdited)
+			}
+		})
+	}
+}
+
+func newIssueCommentEvent(action github.IssueCommentEventAction, commenter, body string) *github.IssueCommentEvent {
+	return &github.IssueCommentEvent{
+		Action: action,
+		Issue: github.Issue{
+			User:        github.User{Login: "author"},
+			Number:      5,
+			State:       "open",
+			PullRequest: &struct{}{},
+		},
+		Comment: github.IssueComment{
+			Body: body,
+			User: github.User{Login: commenter},
+		},
+		Repo: github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+	}
+}
+
+func TestGetCurrentLabel(t *testing.T) {
 	var testcases = []struct {
 		name               string
+		scheme             *externalplugins.LabelScheme
 		labels             []github.Label
-		needsLgtm          int
 		expectCurrentLabel string
-		expectNextLabel    string
 	}{
 		{
-			name:               "Current no LGTM, needs 1 LGTM",
+			name:               "Current no LGTM",
 			labels:             []github.Label{},
-			needsLgtm:          1,
 			expectCurrentLabel: "",
-			expectNextLabel:    lgtmOne,
 		},
 		{
-			name:               "Current no LGTM, needs 2 LGTM",
-			labels:             []github.Label{},
-			needsLgtm:          2,
-			expectCurrentLabel: "",
-			expectNextLabel:    lgtmOne,
-		},
-		{
-			name: "Current LGT1, needs 1 LGTM",
+			name: "Current LGT1",
 			labels: []github.Label{
+				{
+					Name: "sig/testing",
+				},
 				{
 					Name: lgtmOne,
 				},
 			},
-			needsLgtm:          1,
 			expectCurrentLabel: lgtmOne,
-			expectNextLabel:    "",
 		},
 		{
-			name: "Current LGT1, needs 2 LGTM",
+			name: "Current LGT2",
+			labels: []github.Label{
+				{
+					Name: lgtmTwo,
+				},
+			},
+			expectCurrentLabel: lgtmTwo,
+		},
+		{
+			name: "Label similar to LGTM label",
+			labels: []github.Label{
+				{
+					Name: "status/LGTM-needed",
+				},
+			},
+			expectCurrentLabel: "",
+		},
+		{
+			name: "Custom numbered LGTM label",
+			scheme: &externalplugins.LabelScheme{
+				LgtmLabel: "lgtm/{n}-approvals",
+			},
 			labels: []github.Label{
 				{
 					Name: lgtmOne,
 				},
+				{
+					Name: "lgtm/2-approvals",
+				},
 			},
-			needsLgtm:          2,
-			expectCurrentLabel: lgtmOne,
-			expectNextLabel:    lgtmTwo,
+			expectCurrentLabel: "lgtm/2-approvals",
 		},
 		{
-			name: "Current LGT2, needs 2 LGTM",
+			name: "Custom LGTM label without number",
+			scheme: &externalplugins.LabelScheme{
+				LgtmLabel: "lgtm",
+			},
 			labels: []github.Label{
 				{
-					Name: lgtmTwo,
+					Name: "lgtm",
 				},
 			},
-			needsLgtm:          2,
-			expectCurrentLabel: lgtmTwo,
-			expectNextLabel:    "",
+			expectCurrentLabel: "lgtm",
 		},
 	}
 
 	for _, testcase := range testcases {
 		tc := testcase
 		t.Run(tc.name, func(t *testing.T) {
-			currentLabel, nextLabel := getCurrentAndNextLabel(externalplugins.LgtmLabelPrefix, tc.labels, tc.needsLgtm)
+			cfg := &externalplugins.Configuration{}
+			if tc.scheme != nil {
+				tc.scheme.Repos = []string{"org/repo"}
+				cfg.LabelSchemes = []externalplugins.LabelScheme{*tc.scheme}
+			}
+			currentLabel := getCurrentLabel(cfg.LabelSchemeFor("org", "repo"), tc.labels)
 
 			if currentLabel != tc.expectCurrentLabel {
 				t.Fatalf("currentLabel mismatch: got %v, want %v", currentLabel, tc.expectCurrentLabel)
 			}
-
-			if nextLabel != tc.expectNextLabel {
-				t.Fatalf("nextLabel mismatch: got %v, want %v", nextLabel, tc.expectNextLabel)
-			}
 		})
 	}
 }
@@ -769,3 +1584,285 @@ func TestHelpProvider(t *testing.T) {
 		})
 	}
 }
+
+func TestAffiliationLGTM(t *testing.T) {
+	cfg := &externalplugins.Configuration{
+		TichiWebURL:     "https://tichiWebLink",
+		CommandHelpLink: "https://commandHelpLink",
+		PRProcessLink:   "https://prProcessLink",
+		TiCommunityLgtm: []externalplugins.TiCommunityLgtm{
+			{
+				Repos:                []string{"org/repo"},
+				RequiredAffiliations: 2,
+			},
+		},
+	}
+	foc := &fakeOwnersClient{
+		reviewers: []string{"reviewer1", "reviewer2", "reviewer3", "reviewer4"},
+		needsLgtm: 2,
+		affiliations: map[string]string{
+			"reviewer1": "company-a",
+			"reviewer2": "company-a",
+			"reviewer3": "company-b",
+		},
+	}
+	owners, _ := foc.LoadOwners("", "org", "repo", 5)
+	notificationFor := func(reviewers ...string) string {
+		msg, err := getMessage(cfg, owners, reviewers, "https://tichiWebLink/repos/org/repo/pulls/5/owners", "org", "repo")
+		if err != nil {
+			t.Fatalf("failed to generate notification: %v", err)
+		}
+		return *msg
+	}
+
+	testcases := []struct {
+		name         string
+		commenter    string
+		currentLabel string
+		approvers    []string
+
+		expectAddedLabel  string
+		expectGroups      []string
+		expectMissing     bool
+		expectApprovedSet []string
+	}{
+		{
+			name:              "LGTM from the same affiliation",
+			commenter:         "reviewer2",
+			currentLabel:      lgtmOne,
+			approvers:         []string{"reviewer1"},
+			expectAddedLabel:  lgtmTwo,
+			expectGroups:      []string{"**company-a**\n- reviewer1\n- reviewer2\n"},
+			expectMissing:     true,
+			expectApprovedSet: []string{"reviewer1", "reviewer2"},
+		},
+		{
+			name:             "LGTM from another affiliation",
+			commenter:        "reviewer3",
+			currentLabel:     lgtmTwo,
+			approvers:        []string{"reviewer1", "reviewer2"},
+			expectAddedLabel: externalplugins.LgtmLabelPrefix + "3",
+			expectGroups: []string{
+				"**company-a**\n- reviewer1\n- reviewer2\n",
+				"**company-b**\n- reviewer3\n",
+			},
+			expectApprovedSet: []string{"reviewer1", "reviewer2", "reviewer3"},
+		},
+		{
+			name:             "LGTM from unknown affiliation",
+			commenter:        "reviewer4",
+			currentLabel:     lgtmTwo,
+			approvers:        []string{"reviewer1", "reviewer2"},
+			expectAddedLabel: externalplugins.LgtmLabelPrefix + "3",
+			expectGroups: []string{
+				"**company-a**\n- reviewer1\n- reviewer2\n",
+				"**Unknown affiliation**\n- reviewer4\n",
+			},
+			expectMissing:     true,
+			expectApprovedSet: []string{"reviewer1", "reviewer2", "reviewer4"},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
+				IssueComments: map[int][]github.IssueComment{
+					5: {
+						{ID: 1, Body: notificationFor(tc.approvers...), User: github.User{Login: fakegithub.Bot}},
+					},
+				},
+				IssueLabelsAdded: []string{"org/repo#5:" + tc.currentLabel},
+			}}
+
+			err := HandleIssueCommentEvent(fc, newIssueCommentEvent(github.IssueCommentActionCreated, tc.commenter, "/lgtm"),
+				cfg, foc, logrus.WithField("plugin", PluginName))
+			if err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+
+			if len(fc.IssueLabelsAdded) != 2 || fc.IssueLabelsAdded[1] != "org/repo#5:"+tc.expectAddedLabel {
+				t.Errorf("added labels mismatch: got %v, want %s", fc.IssueLabelsAdded, tc.expectAddedLabel)
+			}
+			if len(fc.IssueCommentsEdited) != 1 {
+				t.Fatalf("expected the notification to be edited, got %v", fc.IssueCommentsEdited)
+			}
+			notification := fc.IssueCommentsEdited[0]
+			for _, group := range tc.expectGroups {
+				if !strings.Contains(notification, group) {
+					t.Errorf("expected the notification to contain %q, got %s", group, notification)
+				}
+			}
+			missing := strings.Contains(notification, "more affiliation(s) are still required")
+			if missing != tc.expectMissing {
+				t.Errorf("missing affiliations mismatch: got %v, want %v", missing, tc.expectMissing)
+			}
+			reviewers := getReviewersFromNotification(&github.IssueComment{Body: notification})
+			if !reviewers.Equal(sets.NewString(tc.expectApprovedSet...)) {
+				t.Errorf("approvers mismatch: got %v, want %v", reviewers.List(), tc.expectApprovedSet)
+			}
+		})
+	}
+}
+
+func TestLGTMReviewStatus(t *testing.T) {
+	cfg := &externalplugins.Configuration{
+		TichiWebURL: "https://tichiWebLink",
+		TiCommunityLgtm: []externalplugins.TiCommunityLgtm{
+			{
+				Repos:        []string{"org/repo"},
+				ReviewStatus: true,
+			},
+		},
+	}
+	foc := &fakeOwnersClient{
+		reviewers: []string{"reviewer1", "reviewer2"},
+		needsLgtm: 2,
+	}
+	newClient := func() *fakeGitHubClient {
+		return &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
+			IssueComments: map[int][]github.IssueComment{},
+			PullRequests: map[int]*github.PullRequest{
+				5: {Number: 5, Head: github.PullRequestBranch{SHA: "head-sha"}},
+			},
+			CombinedStatuses: map[string]*github.CombinedStatus{},
+		}}
+	}
+
+	t.Run("LGTM reports the review status", func(t *testing.T) {
+		fc := newClient()
+		err := HandleIssueCommentEvent(fc, newIssueCommentEvent(github.IssueCommentActionCreated, "reviewer1", "/lgtm"),
+			cfg, foc, logrus.WithField("plugin", PluginName))
+		if err != nil {
+			t.Fatalf("didn't expect error: %v", err)
+		}
+
+		statuses := fc.CreatedStatuses["head-sha"]
+		if len(statuses) != 1 {
+			t.Fatalf("expected one review status, got %v", fc.CreatedStatuses)
+		}
+		if statuses[0].Context != externalplugins.ReviewStatusContext || statuses[0].State != "pending" ||
+			!strings.HasPrefix(statuses[0].Description, "LGTM: 1/2 (reviewer1)") {
+			t.Errorf("unexpected review status: %+v", statuses[0])
+		}
+	})
+
+	t.Run("New commits report the review status on the new head", func(t *testing.T) {
+		fc := newClient()
+		event := github.PullRequestEvent{
+			Action: github.PullRequestActionSynchronize,
+			PullRequest: github.PullRequest{
+				Number: 5,
+				Base: github.PullRequestBranch{
+					Repo: github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				},
+				Head: github.PullRequestBranch{SHA: "head-sha"},
+			},
+		}
+		err := HandlePullRequestEvent(fc, nil, &event, cfg, foc, logrus.WithField("plugin", PluginName))
+		if err != nil {
+			t.Fatalf("didn't expect error: %v", err)
+		}
+
+		if len(fc.CreatedStatuses["head-sha"]) != 1 {
+			t.Errorf("expected one review status, got %v", fc.CreatedStatuses)
+		}
+	})
+}
+
+func TestLGTMNotificationUpdate(t *testing.T) {
+	foc := &fakeOwnersClient{
+		reviewers: []string{"collab1", "collab2", "collab3"},
+		needsLgtm: 3,
+	}
+	committersNotificationFor := func(committers []string, reviewers ...string) string {
+		state := &externalplugins.ReviewNotification{Approvers: reviewers, Committers: committers}
+		cfg := &externalplugins.Configuration{}
+		msg, err := cfg.RenderReviewNotification("org", "repo", nil, state, "/repos/org/repo/pulls/5/owners")
+		if err != nil {
+			t.Fatalf("failed to generate notification: %v", err)
+		}
+		return msg
+	}
+	notificationFor := func(reviewers ...string) string {
+		return committersNotificationFor(nil, reviewers...)
+	}
+
+	testcases := []struct {
+		name                 string
+		notifications        []string
+		recreateNotification bool
+
+		expectAdded   []string
+		expectEdited  []string
+		expectDeleted []string
+	}{
+		{
+			name:          "Edit the notification in place",
+			notifications: []string{notificationFor("collab1")},
+			expectEdited:  []string{"org/repo#5:" + notificationFor("collab1", "collab2")},
+		},
+		{
+			name:          "Edit the latest notification and delete the duplicated ones",
+			notifications: []string{notificationFor(), notificationFor("collab1")},
+			expectEdited:  []string{"org/repo#5:" + notificationFor("collab1", "collab2")},
+			expectDeleted: []string{"org/repo#1"},
+		},
+		{
+			name:          "Keep the assigned committers",
+			notifications: []string{committersNotificationFor([]string{"committer1"}, "collab1")},
+			expectEdited:  []string{"org/repo#5:" + committersNotificationFor([]string{"committer1"}, "collab1", "collab2")},
+		},
+		{
+			name:                 "Recreate the notification",
+			notifications:        []string{notificationFor("collab1")},
+			recreateNotification: true,
+			expectAdded:          []string{"org/repo#5:" + notificationFor("collab1", "collab2")},
+			expectDeleted:        []string{"org/repo#1"},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &externalplugins.Configuration{
+				TiCommunityLgtm: []externalplugins.TiCommunityLgtm{
+					{
+						Repos:                []string{"org/repo"},
+						RecreateNotification: tc.recreateNotification,
+					},
+				},
+			}
+			var comments []github.IssueComment
+			for i, notification := range tc.notifications {
+				comments = append(comments, github.IssueComment{
+					ID: i + 1, Body: notification, User: github.User{Login: fakegithub.Bot},
+				})
+			}
+			fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
+				IssueComments:    map[int][]github.IssueComment{5: comments},
+				IssueCommentID:   len(comments),
+				IssueLabelsAdded: []string{"org/repo#5:" + lgtmOne},
+			}}
+
+			err := HandleIssueCommentEvent(fc, newIssueCommentEvent(github.IssueCommentActionCreated, "collab2", "/lgtm"),
+				cfg, foc, logrus.WithField("plugin", PluginName))
+			if err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+
+			if got, want := strings.Join(fc.IssueCommentsAdded, ","), strings.Join(tc.expectAdded, ","); got != want {
+				t.Errorf("added comments mismatch: got %q, want %q", got, want)
+			}
+			if got, want := strings.Join(fc.IssueCommentsEdited, ","), strings.Join(tc.expectEdited, ","); got != want {
+				t.Errorf("edited comments mismatch: got %q, want %q", got, want)
+			}
+			if got, want := strings.Join(fc.IssueCommentsDeleted, ","), strings.Join(tc.expectDeleted, ","); got != want {
+				t.Errorf("deleted comments mismatch: got %q, want %q", got, want)
+			}
+			if len(fc.IssueComments[5]) != 1 {
+				t.Errorf("expected only one notification left, got %v", fc.IssueComments[5])
+			}
+		})
+	}
+}
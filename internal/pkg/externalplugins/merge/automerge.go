@@ -0,0 +1,178 @@
+package merge
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+	git "k8s.io/test-infra/prow/git/v2"
+	"k8s.io/test-infra/prow/github"
+)
+
+// autoMergeIdentifier identifies the sticky comment which records whether the auto-merge is enabled for the PR.
+const autoMergeIdentifier = "Merge Auto Merge"
+
+var (
+	// AutoMergeRe is the regex that matches the `/auto-merge` and `/auto-merge cancel` comments.
+	AutoMergeRe = regexp.MustCompile(`(?mi)^/auto-merge(?:\s+(cancel))?\s*$`)
+	// autoMergeRe matches the sticky comment of the auto-merge.
+	autoMergeRe = regexp.MustCompile("<!--" + autoMergeIdentifier + ": (enabled|disabled)-->")
+)
+
+// getAutoMergeComments returns the sticky comments of the auto-merge and whether the auto-merge is enabled,
+// the repo default is used if it has not been changed by `/auto-merge [cancel]`.
+func getAutoMergeComments(gc githubClient, opts *externalplugins.TiCommunityMerge, org, repo string,
+	number int) ([]*github.IssueComment, bool, error) {
+	botUserChecker, err := gc.BotUserChecker()
+	if err != nil {
+		return nil, false, err
+	}
+	comments, err := gc.ListIssueComments(org, repo, number)
+	if err != nil {
+		return nil, false, err
+	}
+	stickyComments := externalplugins.FilterStickyComments(comments, botUserChecker, func(body string) bool {
+		return autoMergeRe.MatchString(body)
+	})
+	if len(stickyComments) == 0 {
+		return nil, opts.AutoMerge, nil
+	}
+	m := autoMergeRe.FindStringSubmatch(stickyComments[len(stickyComments)-1].Body)
+	return stickyComments, m[1] == "enabled", nil
+}
+
+// handleAutoMergeCommand enables or disables the auto-merge of the PR by `/auto-merge [cancel]`.
+func handleAutoMergeCommand(gc githubClient, gitClient git.ClientFactory, ice *github.IssueCommentEvent,
+	cfg *externalplugins.Configuration, ol ownersclient.OwnersLoader, cancel bool, log *logrus.Entry) error {
+	org := ice.Repo.Owner.Login
+	repo := ice.Repo.Name
+	number := ice.Issue.Number
+	allowed, err := checkAuthorOrCommitter(gc, ice, cfg, ol,
+		externalplugins.MessageMergeAutoMergeOnlyAuthorAndCommitters, log)
+	if err != nil || !allowed {
+		return err
+	}
+
+	stickyComments, _, err := getAutoMergeComments(gc, cfg.MergeFor(org, repo), org, repo, number)
+	if err != nil {
+		return err
+	}
+	log.Infof("The auto-merge is changed by %s.", ice.Comment.User.Login)
+	if err := updateAutoMerge(gc, cfg, org, repo, number, stickyComments, !cancel, log); err != nil {
+		return err
+	}
+	if cancel {
+		return nil
+	}
+
+	pr, err := gc.GetPullRequest(org, repo, number)
+	if err != nil {
+		return err
+	}
+	return tryAutoMerge(gc, gitClient, cfg, ol, pr, log)
+}
+
+// updateAutoMerge records whether the auto-merge is enabled for the PR in the sticky comment.
+func updateAutoMerge(gc githubClient, cfg *externalplugins.Configuration, org, repo string, number int,
+	stickyComments []*github.IssueComment, enabled bool, log *logrus.Entry) error {
+	resp, err := cfg.RenderMessage(org, repo, externalplugins.MessageMergeAutoMerge, map[string]interface{}{
+		"enabled": enabled,
+		"label":   cfg.LabelSchemeFor(org, repo).CanMergeLabel,
+	})
+	if err != nil {
+		return err
+	}
+	state := "enabled"
+	if !enabled {
+		state = "disabled"
+	}
+	body := fmt.Sprintf("%s\n\n<!--%s: %s-->", resp, autoMergeIdentifier, state)
+	return externalplugins.UpdateStickyComment(gc, org, repo, number, stickyComments, body, false, log)
+}
+
+// disableAutoMerge disables the auto-merge of the PR when the merge is canceled by `/merge cancel`,
+// otherwise the 'can-merge' label would be added again automatically.
+func disableAutoMerge(gc githubClient, cfg *externalplugins.Configuration, org, repo string, number int,
+	log *logrus.Entry) error {
+	stickyComments, enabled, err := getAutoMergeComments(gc, cfg.MergeFor(org, repo), org, repo, number)
+	if err != nil || !enabled {
+		return err
+	}
+	log.Info("Disabling the auto-merge because the merge is canceled.")
+	return updateAutoMerge(gc, cfg, org, repo, number, stickyComments, false, log)
+}
+
+// tryAutoMerge adds the 'can-merge' label if the auto-merge is enabled for the PR, the approvals are satisfied,
+// the required checks have passed and nothing else prevents the PR from being merged.
+func tryAutoMerge(gc githubClient, gitClient git.ClientFactory, cfg *externalplugins.Configuration,
+	ol ownersclient.OwnersLoader, pr *github.PullRequest, log *logrus.Entry) error {
+	org := pr.Base.Repo.Owner.Login
+	repo := pr.Base.Repo.Name
+	number := pr.Number
+	if pr.State != "open" || pr.Draft {
+		return nil
+	}
+
+	opts := cfg.MergeFor(org, repo)
+	_, enabled, err := getAutoMergeComments(gc, opts, org, repo, number)
+	if err != nil || !enabled {
+		return err
+	}
+
+	labels, err := gc.GetIssueLabels(org, repo, number)
+	if err != nil {
+		return err
+	}
+	if github.HasLabel(cfg.LabelSchemeFor(org, repo).CanMergeLabel, labels) {
+		return nil
+	}
+	for _, label := range opts.AutoMergeBlockingLabels {
+		if github.HasLabel(label, labels) {
+			log.Infof("Skip the auto-merge because of the '%s' label.", label)
+			return nil
+		}
+	}
+
+	// The committer who wants to merge is unknown, so only the exempt labels are considered.
+	freeze, err := getBlockingFreeze(gc, opts, org, pr.Base.Ref, labels, "", time.Now(), log)
+	if err != nil {
+		return err
+	}
+	if freeze != nil {
+		log.Infof("Skip the auto-merge because the branch is frozen by %s.", freeze.Name)
+		return nil
+	}
+
+	if opts.TrackDependencies {
+		open, err := syncDependencies(gc, cfg, pr, log)
+		if err != nil {
+			return err
+		}
+		if len(open) != 0 {
+			log.Infof("Skip the auto-merge because the dependencies are open: %v.", open)
+			return nil
+		}
+	}
+
+	if len(opts.RequireContexts) != 0 {
+		result, err := externalplugins.GetRequiredChecksResult(gc, org, repo, pr.Head.SHA, opts.RequireContexts)
+		if err != nil {
+			return err
+		}
+		if !result.Passed() {
+			log.Infof("Skip the auto-merge because some of the required checks have not passed: %v %v.",
+				result.Failed, result.Pending)
+			return nil
+		}
+	}
+
+	botUserChecker, err := gc.BotUserChecker()
+	if err != nil {
+		return err
+	}
+	cp := &issueCommentPruner{gc: gc, org: org, repo: repo, number: number, isBot: botUserChecker, log: log}
+	return addCanMergeLabelIfApproved(gc, gitClient, cfg, ol, org, repo, number, labels, cp, log)
+}
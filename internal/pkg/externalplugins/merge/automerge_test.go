@@ -0,0 +1,374 @@
+package merge
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+)
+
+func TestAutoMergeCommand(t *testing.T) {
+	testcases := []struct {
+		name      string
+		body      string
+		commenter string
+		labels    []string
+
+		shouldAddLabel bool
+		expectState    string
+		expectRejected bool
+	}{
+		{
+			name:           "Author enables the auto-merge",
+			body:           "/auto-merge",
+			commenter:      "author",
+			labels:         []string{lgtmTwo},
+			shouldAddLabel: true,
+			expectState:    "enabled",
+		},
+		{
+			name:        "Committer enables the auto-merge before the approvals are satisfied",
+			body:        "/auto-merge",
+			commenter:   "collab1",
+			labels:      []string{lgtmOne},
+			expectState: "enabled",
+		},
+		{
+			name:        "Auto-merge is held",
+			body:        "/auto-merge",
+			commenter:   "author",
+			labels:      []string{lgtmTwo, "do-not-merge/hold"},
+			expectState: "enabled",
+		},
+		{
+			name:        "Author disables the auto-merge",
+			body:        "/auto-merge cancel",
+			commenter:   "author",
+			labels:      []string{lgtmTwo},
+			expectState: "disabled",
+		},
+		{
+			name:           "Others can not enable the auto-merge",
+			body:           "/auto-merge",
+			commenter:      "collab2",
+			labels:         []string{lgtmTwo},
+			expectRejected: true,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			var labels []string
+			for _, label := range tc.labels {
+				labels = append(labels, "org/repo#5:"+label)
+			}
+			fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
+				IssueComments: make(map[int][]github.IssueComment),
+				PullRequests: map[int]*github.PullRequest{
+					5: newDependencyPullRequest(5, "open", false, ""),
+				},
+				IssueLabelsExisting: labels,
+			}}
+			e := &github.IssueCommentEvent{
+				Action: github.IssueCommentActionCreated,
+				Issue: github.Issue{
+					User:        github.User{Login: "author"},
+					Number:      5,
+					State:       "open",
+					PullRequest: &struct{}{},
+				},
+				Comment: github.IssueComment{
+					Body: tc.body,
+					User: github.User{Login: tc.commenter},
+				},
+				Repo: github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+			}
+			cfg := &externalplugins.Configuration{
+				TiCommunityMerge: []externalplugins.TiCommunityMerge{
+					{
+						Repos:                   []string{"org/repo"},
+						AutoMergeBlockingLabels: []string{"do-not-merge/hold"},
+					},
+				},
+			}
+			foc := &fakeOwnersClient{
+				committers: []string{"collab1"},
+				needsLgtm:  2,
+			}
+			cp := &fakePruner{GitHubClient: fc.FakeClient}
+
+			if err := HandleIssueCommentEvent(fc, nil, e, cfg, foc, cp, logrus.WithField("plugin", PluginName)); err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+
+			hasLabel := false
+			for _, label := range fc.IssueLabelsAdded {
+				if label == "org/repo#5:"+externalplugins.CanMergeLabel {
+					hasLabel = true
+				}
+			}
+			if hasLabel != tc.shouldAddLabel {
+				t.Errorf("label added mismatch: got %v, want %v", hasLabel, tc.shouldAddLabel)
+			}
+
+			state := ""
+			rejected := false
+			for _, comment := range fc.IssueComments[5] {
+				if m := autoMergeRe.FindStringSubmatch(comment.Body); m != nil {
+					state = m[1]
+				}
+				if strings.Contains(comment.Body, "`/auto-merge` is only allowed") {
+					rejected = true
+				}
+			}
+			if state != tc.expectState {
+				t.Errorf("auto-merge state mismatch: got %q, want %q", state, tc.expectState)
+			}
+			if rejected != tc.expectRejected {
+				t.Errorf("rejected mismatch: got %v, want %v", rejected, tc.expectRejected)
+			}
+		})
+	}
+}
+
+func TestAutoMergeOnLabelChanged(t *testing.T) {
+	disabledComment := fmt.Sprintf("Auto-merge is disabled.\n\n<!--%s: disabled-->", autoMergeIdentifier)
+
+	testcases := []struct {
+		name      string
+		action    github.PullRequestEventAction
+		label     string
+		labels    []string
+		draft     bool
+		comments  []github.IssueComment
+		statuses  []github.Status
+		autoMerge bool
+
+		shouldAddLabel bool
+	}{
+		{
+			name:           "LGTM label satisfies the approvals",
+			action:         github.PullRequestActionLabeled,
+			label:          lgtmTwo,
+			labels:         []string{lgtmTwo},
+			statuses:       []github.Status{{Context: "ci/test", State: github.StatusSuccess}},
+			autoMerge:      true,
+			shouldAddLabel: true,
+		},
+		{
+			name:           "Hold label is removed",
+			action:         github.PullRequestActionUnlabeled,
+			label:          "do-not-merge/hold",
+			labels:         []string{lgtmTwo},
+			statuses:       []github.Status{{Context: "ci/test", State: github.StatusSuccess}},
+			autoMerge:      true,
+			shouldAddLabel: true,
+		},
+		{
+			name:      "Approvals are not satisfied",
+			action:    github.PullRequestActionLabeled,
+			label:     lgtmOne,
+			labels:    []string{lgtmOne},
+			statuses:  []github.Status{{Context: "ci/test", State: github.StatusSuccess}},
+			autoMerge: true,
+		},
+		{
+			name:      "Required checks are pending",
+			action:    github.PullRequestActionLabeled,
+			label:     lgtmTwo,
+			labels:    []string{lgtmTwo},
+			statuses:  []github.Status{{Context: "ci/test", State: github.StatusPending}},
+			autoMerge: true,
+		},
+		{
+			name:      "Work in progress",
+			action:    github.PullRequestActionLabeled,
+			label:     lgtmTwo,
+			labels:    []string{lgtmTwo, "do-not-merge/work-in-progress"},
+			statuses:  []github.Status{{Context: "ci/test", State: github.StatusSuccess}},
+			autoMerge: true,
+		},
+		{
+			name:      "Draft pull request",
+			action:    github.PullRequestActionLabeled,
+			label:     lgtmTwo,
+			labels:    []string{lgtmTwo},
+			draft:     true,
+			statuses:  []github.Status{{Context: "ci/test", State: github.StatusSuccess}},
+			autoMerge: true,
+		},
+		{
+			name:   "Auto-merge is disabled by the command",
+			action: github.PullRequestActionLabeled,
+			label:  lgtmTwo,
+			labels: []string{lgtmTwo},
+			comments: []github.IssueComment{
+				{ID: 1, Body: disabledComment, User: github.User{Login: "k8s-ci-robot"}},
+			},
+			statuses:  []github.Status{{Context: "ci/test", State: github.StatusSuccess}},
+			autoMerge: true,
+		},
+		{
+			name:     "Auto-merge is not enabled",
+			action:   github.PullRequestActionLabeled,
+			label:    lgtmTwo,
+			labels:   []string{lgtmTwo},
+			statuses: []github.Status{{Context: "ci/test", State: github.StatusSuccess}},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			var labels []string
+			for _, label := range tc.labels {
+				labels = append(labels, "org/repo#5:"+label)
+			}
+			pr := newDependencyPullRequest(5, "open", false, "")
+			pr.Draft = tc.draft
+			fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
+				IssueComments:       map[int][]github.IssueComment{5: tc.comments},
+				IssueCommentID:      1,
+				PullRequests:        map[int]*github.PullRequest{5: pr},
+				IssueLabelsExisting: labels,
+				CombinedStatuses: map[string]*github.CombinedStatus{
+					headSHA: {SHA: headSHA, Statuses: tc.statuses},
+				},
+			}}
+			e := &github.PullRequestEvent{
+				Action:      tc.action,
+				Number:      5,
+				PullRequest: *pr,
+				Label:       github.Label{Name: tc.label},
+				Sender:      github.User{Login: "k8s-ci-robot"},
+			}
+			cfg := &externalplugins.Configuration{
+				TiCommunityMerge: []externalplugins.TiCommunityMerge{
+					{
+						Repos:           []string{"org/repo"},
+						RequireContexts: []string{"ci/test"},
+						AutoMerge:       tc.autoMerge,
+						AutoMergeBlockingLabels: []string{
+							"do-not-merge/hold",
+							"do-not-merge/work-in-progress",
+						},
+					},
+				},
+			}
+			foc := &fakeOwnersClient{
+				committers: []string{"collab1"},
+				needsLgtm:  2,
+			}
+
+			if err := HandlePullRequestEvent(fc, nil, e, cfg, foc, logrus.WithField("plugin", PluginName)); err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+
+			hasLabel := false
+			for _, label := range fc.IssueLabelsAdded {
+				if label == "org/repo#5:"+externalplugins.CanMergeLabel {
+					hasLabel = true
+				}
+			}
+			if hasLabel != tc.shouldAddLabel {
+				t.Errorf("label added mismatch: got %v, want %v", hasLabel, tc.shouldAddLabel)
+			}
+		})
+	}
+}
+
+func TestAutoMergeOnCheckPassed(t *testing.T) {
+	fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
+		IssueComments: make(map[int][]github.IssueComment),
+		PullRequests: map[int]*github.PullRequest{
+			5: newDependencyPullRequest(5, "open", false, ""),
+		},
+		IssueLabelsExisting: []string{"org/repo#5:" + lgtmTwo},
+		CombinedStatuses: map[string]*github.CombinedStatus{
+			headSHA: {SHA: headSHA, Statuses: []github.Status{{Context: "ci/test", State: github.StatusSuccess}}},
+		},
+	}}
+	se := &github.StatusEvent{
+		SHA:     headSHA,
+		State:   github.StatusSuccess,
+		Context: "ci/test",
+		Repo:    github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+	}
+	cfg := &externalplugins.Configuration{
+		TiCommunityMerge: []externalplugins.TiCommunityMerge{
+			{
+				Repos:           []string{"org/repo"},
+				RequireContexts: []string{"ci/test"},
+				AutoMerge:       true,
+			},
+		},
+	}
+	foc := &fakeOwnersClient{
+		committers: []string{"collab1"},
+		needsLgtm:  2,
+	}
+
+	if err := HandleStatusEvent(fc, nil, se, cfg, foc, logrus.WithField("plugin", PluginName)); err != nil {
+		t.Fatalf("didn't expect error: %v", err)
+	}
+	if len(fc.IssueLabelsAdded) != 1 || fc.IssueLabelsAdded[0] != "org/repo#5:"+externalplugins.CanMergeLabel {
+		t.Errorf("expected the 'can-merge' label added, got %v", fc.IssueLabelsAdded)
+	}
+}
+
+func TestMergeCancelDisablesAutoMerge(t *testing.T) {
+	fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
+		IssueComments: make(map[int][]github.IssueComment),
+		PullRequests: map[int]*github.PullRequest{
+			5: newDependencyPullRequest(5, "open", false, ""),
+		},
+		IssueLabelsExisting: []string{"org/repo#5:" + lgtmTwo, "org/repo#5:" + externalplugins.CanMergeLabel},
+	}}
+	e := &github.IssueCommentEvent{
+		Action: github.IssueCommentActionCreated,
+		Issue: github.Issue{
+			User:        github.User{Login: "author"},
+			Number:      5,
+			State:       "open",
+			PullRequest: &struct{}{},
+		},
+		Comment: github.IssueComment{
+			Body: "/merge cancel",
+			User: github.User{Login: "collab1"},
+		},
+		Repo: github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+	}
+	cfg := &externalplugins.Configuration{
+		TiCommunityMerge: []externalplugins.TiCommunityMerge{
+			{
+				Repos:     []string{"org/repo"},
+				AutoMerge: true,
+			},
+		},
+	}
+	foc := &fakeOwnersClient{
+		committers: []string{"collab1"},
+		needsLgtm:  2,
+	}
+	cp := &fakePruner{GitHubClient: fc.FakeClient}
+
+	if err := HandleIssueCommentEvent(fc, nil, e, cfg, foc, cp, logrus.WithField("plugin", PluginName)); err != nil {
+		t.Fatalf("didn't expect error: %v", err)
+	}
+
+	_, enabled, err := getAutoMergeComments(fc, cfg.MergeFor("org", "repo"), "org", "repo", 5)
+	if err != nil {
+		t.Fatalf("didn't expect error: %v", err)
+	}
+	if enabled {
+		t.Error("expected the auto-merge disabled by /merge cancel")
+	}
+	if len(fc.IssueLabelsRemoved) != 1 {
+		t.Errorf("expected the 'can-merge' label removed, got %v", fc.IssueLabelsRemoved)
+	}
+}
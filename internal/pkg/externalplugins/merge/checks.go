@@ -0,0 +1,241 @@
+package merge
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+	"k8s.io/apimachinery/pkg/util/sets"
+	git "k8s.io/test-infra/prow/git/v2"
+	"k8s.io/test-infra/prow/github"
+)
+
+// waitingForChecksIdentifier identifies the responses to the `/merge` that waits for the required checks.
+const waitingForChecksIdentifier = "Merge Waiting For Checks"
+
+// waitingForChecksRe matches the responses to the `/merge` that waits for the required checks of the commit.
+var waitingForChecksRe = regexp.MustCompile("<!--" + waitingForChecksIdentifier + ": ([0-9a-f]+)-->")
+
+// CheckRunEvent fires when a check run is created, completed or requested again.
+//
+// See https://docs.github.com/en/developers/webhooks-and-events/webhook-events-and-payloads#check_run
+type CheckRunEvent struct {
+	Action   string          `json:"action"`
+	CheckRun github.CheckRun `json:"check_run"`
+	Repo     github.Repo     `json:"repository"`
+}
+
+// getChecksNotPassedResponse returns the response to the `/merge` when the required checks have not passed.
+// If the label will be added once the checks pass, the response records the commit it is waiting for.
+func getChecksNotPassedResponse(config *externalplugins.Configuration, org, repo, sha string,
+	result *externalplugins.ChecksResult) (string, error) {
+	opts := config.MergeFor(org, repo)
+	resp, err := config.RenderMessage(org, repo, externalplugins.MessageMergeChecksNotPassed,
+		map[string]interface{}{
+			"failedContexts":      result.Failed,
+			"pendingContexts":     result.Pending,
+			"labelWhenChecksPass": opts.LabelWhenChecksPass,
+		})
+	if err != nil {
+		return "", err
+	}
+	if opts.LabelWhenChecksPass {
+		resp = fmt.Sprintf("%s\n\n<!--%s: %s-->", resp, waitingForChecksIdentifier, sha)
+	}
+	return resp, nil
+}
+
+// HandleStatusEvent adds the 'can-merge' label to the PRs waiting for the required checks
+// when a required status context succeeds.
+func HandleStatusEvent(gc githubClient, gitClient git.ClientFactory, se *github.StatusEvent,
+	cfg *externalplugins.Configuration, ol ownersclient.OwnersLoader, log *logrus.Entry) error {
+	if se.State != github.StatusSuccess {
+		return nil
+	}
+	return handleCheckPassed(gc, gitClient, cfg, ol, se.Repo.Owner.Login, se.Repo.Name, se.SHA, se.Context, log)
+}
+
+// HandleCheckRunEvent adds the 'can-merge' label to the PRs waiting for the required checks
+// when a required check run succeeds.
+func HandleCheckRunEvent(gc githubClient, gitClient git.ClientFactory, ce *CheckRunEvent,
+	cfg *externalplugins.Configuration, ol ownersclient.OwnersLoader, log *logrus.Entry) error {
+	run := ce.CheckRun
+	if ce.Action != externalplugins.CheckRunStatusCompleted || !externalplugins.IsCheckRunPassed(run) {
+		return nil
+	}
+	return handleCheckPassed(gc, gitClient, cfg, ol, ce.Repo.Owner.Login, ce.Repo.Name, run.HeadSHA, run.Name, log)
+}
+
+// handleCheckPassed handles the open PRs whose head commit is the commit of the passed check,
+// the PRs waiting for the required checks or enabling the auto-merge may be labeled.
+func handleCheckPassed(gc githubClient, gitClient git.ClientFactory, cfg *externalplugins.Configuration,
+	ol ownersclient.OwnersLoader, org, repo, sha, context string, log *logrus.Entry) error {
+	opts := cfg.MergeFor(org, repo)
+	if !sets.NewString(opts.RequireContexts...).Has(context) {
+		return nil
+	}
+
+	query := fmt.Sprintf("%s repo:%s/%s type:pr state:open", sha, org, repo)
+	issues, err := gc.FindIssues(query, "", false)
+	if err != nil {
+		return fmt.Errorf("failed to find the pull requests of %s: %v", sha, err)
+	}
+	for _, issue := range issues {
+		l := log.WithField("pr", issue.Number)
+		if opts.LabelWhenChecksPass {
+			if err := handleWaitingForChecks(gc, gitClient, cfg, ol, org, repo, issue.Number, sha, l); err != nil {
+				l.WithError(err).Error("Failed to handle the merge waiting for the required checks.")
+			}
+		}
+		pr, err := gc.GetPullRequest(org, repo, issue.Number)
+		if err != nil {
+			l.WithError(err).Error("Failed to get the pull request.")
+			continue
+		}
+		if pr.Head.SHA != sha {
+			continue
+		}
+		if err := tryAutoMerge(gc, gitClient, cfg, ol, pr, l); err != nil {
+			l.WithError(err).Error("Failed to auto-merge.")
+		}
+	}
+	return nil
+}
+
+// handleWaitingForChecks adds the 'can-merge' label if the PR has been waiting for the required checks of
+// its head commit, all the required checks have passed and the approval rules are still satisfied.
+func handleWaitingForChecks(gc githubClient, gitClient git.ClientFactory, cfg *externalplugins.Configuration,
+	ol ownersclient.OwnersLoader, org, repo string, number int, sha string, log *logrus.Entry) error {
+	pr, err := gc.GetPullRequest(org, repo, number)
+	if err != nil {
+		return err
+	}
+	if pr.Head.SHA != sha {
+		return nil
+	}
+
+	labels, err := gc.GetIssueLabels(org, repo, number)
+	if err != nil {
+		return err
+	}
+	labelScheme := cfg.LabelSchemeFor(org, repo)
+	if github.HasLabel(labelScheme.CanMergeLabel, labels) {
+		return nil
+	}
+
+	botUserChecker, err := gc.BotUserChecker()
+	if err != nil {
+		return err
+	}
+	comments, err := gc.ListIssueComments(org, repo, number)
+	if err != nil {
+		return err
+	}
+	waiting := false
+	for _, comment := range comments {
+		m := waitingForChecksRe.FindStringSubmatch(comment.Body)
+		if botUserChecker(comment.User.Login) && m != nil && m[1] == sha {
+			waiting = true
+		}
+	}
+	if !waiting {
+		return nil
+	}
+
+	opts := cfg.MergeFor(org, repo)
+	// The committer who wants to merge is unknown, so only the exempt labels are considered.
+	freeze, err := getBlockingFreeze(gc, opts, org, pr.Base.Ref, labels, "", time.Now(), log)
+	if err != nil {
+		return err
+	}
+	if freeze != nil {
+		log.Infof("Skip adding the label because the branch is frozen by %s.", freeze.Name)
+		return nil
+	}
+
+	result, err := externalplugins.GetRequiredChecksResult(gc, org, repo, sha, opts.RequireContexts)
+	if err != nil {
+		return err
+	}
+	if !result.Passed() {
+		log.Infof("Some of the required checks have not passed: %v %v.", result.Failed, result.Pending)
+		return nil
+	}
+
+	if opts.TrackDependencies {
+		open, err := syncDependencies(gc, cfg, pr, log)
+		if err != nil {
+			return err
+		}
+		if len(open) != 0 {
+			log.Infof("Skip adding the label because the dependencies are open: %v.", open)
+			return waitForDependencies(gc, cfg, pr, open, false, log)
+		}
+	}
+
+	log.Info("All the required checks have passed.")
+	cp := &issueCommentPruner{gc: gc, org: org, repo: repo, number: number, isBot: botUserChecker, log: log}
+	return addCanMergeLabelIfApproved(gc, gitClient, cfg, ol, org, repo, number, labels, cp, log)
+}
+
+// addCanMergeLabelIfApproved adds the 'can-merge' label if the approval rules are still satisfied,
+// it is used when the label is added without the `/merge` of a committer.
+func addCanMergeLabelIfApproved(gc githubClient, gitClient git.ClientFactory, cfg *externalplugins.Configuration,
+	ol ownersclient.OwnersLoader, org, repo string, number int, labels []github.Label, cp commentPruner,
+	log *logrus.Entry) error {
+	opts := cfg.MergeFor(org, repo)
+	owners, err := ol.LoadOwners(opts.PullOwnersEndpoint, org, repo, number)
+	if err != nil {
+		return err
+	}
+	labelScheme := cfg.LabelSchemeFor(org, repo)
+	lgtmOpts := cfg.LgtmFor(org, repo)
+	var approvers []string
+	if lgtmOpts.NeedsApprovers() || !labelScheme.IsLgtmLabelNumbered() {
+		approvers, err = getApprovers(gc, org, repo, number)
+		if err != nil {
+			return err
+		}
+	}
+	if !isLGTMSatisfy(lgtmOpts, labelScheme, owners, labels, approvers) {
+		log.Info("The approval rules are not satisfied.")
+		return nil
+	}
+
+	if err := addCanMergeLabel(gc, gitClient, cfg, org, repo, number, cp, log); err != nil {
+		return err
+	}
+	reportReviewStatus(gc, cfg, ol, org, repo, number, log)
+	return nil
+}
+
+// issueCommentPruner deletes the bot comments of the issue, it is used when
+// the event is not triggered by a comment.
+type issueCommentPruner struct {
+	gc     githubClient
+	org    string
+	repo   string
+	number int
+	isBot  func(string) bool
+	log    *logrus.Entry
+}
+
+// PruneComments deletes the bot comments which should be pruned.
+func (p *issueCommentPruner) PruneComments(shouldPrune func(github.IssueComment) bool) {
+	comments, err := p.gc.ListIssueComments(p.org, p.repo, p.number)
+	if err != nil {
+		p.log.WithError(err).Error("Failed to list comments.")
+		return
+	}
+	for _, comment := range comments {
+		if !p.isBot(comment.User.Login) || !shouldPrune(comment) {
+			continue
+		}
+		if err := p.gc.DeleteComment(p.org, p.repo, comment.ID); err != nil {
+			p.log.WithError(err).Errorf("Failed to delete comment, ID: %d.", comment.ID)
+		}
+	}
+}
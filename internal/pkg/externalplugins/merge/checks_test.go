@@ -0,0 +1,306 @@
+package merge
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+)
+
+const headSHA = "abc123"
+
+func TestMergeWithRequiredChecks(t *testing.T) {
+	testcases := []struct {
+		name                string
+		statuses            []github.Status
+		labelWhenChecksPass bool
+
+		shouldAddLabel bool
+		expectComment  []string
+		expectWaiting  bool
+	}{
+		{
+			name: "Required checks passed",
+			statuses: []github.Status{
+				{Context: "ci/build", State: github.StatusSuccess},
+				{Context: "ci/test", State: github.StatusSuccess},
+			},
+			shouldAddLabel: true,
+		},
+		{
+			name: "Required checks failed or pending",
+			statuses: []github.Status{
+				{Context: "ci/build", State: github.StatusFailure},
+			},
+			expectComment: []string{"Failed: ci/build.", "Pending: ci/test."},
+		},
+		{
+			name: "Required checks pending and wait for them",
+			statuses: []github.Status{
+				{Context: "ci/build", State: github.StatusSuccess},
+			},
+			labelWhenChecksPass: true,
+			expectComment: []string{"Pending: ci/test.",
+				"The pull request will be accepted automatically once the required checks pass."},
+			expectWaiting: true,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
+				IssueComments: make(map[int][]github.IssueComment),
+				PullRequests: map[int]*github.PullRequest{
+					5: {Number: 5, Head: github.PullRequestBranch{SHA: headSHA}},
+				},
+				IssueLabelsExisting: []string{"org/repo#5:" + lgtmTwo},
+				CombinedStatuses: map[string]*github.CombinedStatus{
+					headSHA: {SHA: headSHA, Statuses: tc.statuses},
+				},
+			}}
+			e := &github.IssueCommentEvent{
+				Action: github.IssueCommentActionCreated,
+				Issue: github.Issue{
+					User:        github.User{Login: "author"},
+					Number:      5,
+					State:       "open",
+					PullRequest: &struct{}{},
+				},
+				Comment: github.IssueComment{
+					Body: "/merge",
+					User: github.User{Login: "collab1"},
+				},
+				Repo: github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+			}
+			cfg := &externalplugins.Configuration{
+				TiCommunityMerge: []externalplugins.TiCommunityMerge{
+					{
+						Repos:               []string{"org/repo"},
+						RequireContexts:     []string{"ci/build", "ci/test"},
+						LabelWhenChecksPass: tc.labelWhenChecksPass,
+					},
+				},
+			}
+			foc := &fakeOwnersClient{
+				committers: []string{"collab1"},
+				needsLgtm:  2,
+			}
+			cp := &fakePruner{GitHubClient: fc.FakeClient}
+
+			if err := HandleIssueCommentEvent(fc, nil, e, cfg, foc, cp, logrus.WithField("plugin", PluginName)); err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+
+			canMerge := "org/repo#5:" + externalplugins.CanMergeLabel
+			hasLabel := false
+			for _, label := range fc.IssueLabelsAdded {
+				if label == canMerge {
+					hasLabel = true
+				}
+			}
+			if hasLabel != tc.shouldAddLabel {
+				t.Errorf("label added mismatch: got %v, want %v", hasLabel, tc.shouldAddLabel)
+			}
+
+			comments := fc.IssueComments[5]
+			if len(tc.expectComment) == 0 {
+				if len(comments) != 0 {
+					t.Errorf("expected no comment, got %v", comments)
+				}
+				return
+			}
+			if len(comments) != 1 {
+				t.Fatalf("expected one comment, got %v", comments)
+			}
+			for _, expect := range tc.expectComment {
+				if !strings.Contains(comments[0].Body, expect) {
+					t.Errorf("expected the comment contains %q, got %q", expect, comments[0].Body)
+				}
+			}
+			m := waitingForChecksRe.FindStringSubmatch(comments[0].Body)
+			if waiting := m != nil && m[1] == headSHA; waiting != tc.expectWaiting {
+				t.Errorf("waiting marker mismatch: got %v, want %v", waiting, tc.expectWaiting)
+			}
+		})
+	}
+}
+
+func TestHandleCheckPassed(t *testing.T) {
+	waitingComment := fmt.Sprintf("Pending: ci/test.\n\n<!--%s: %s-->", waitingForChecksIdentifier, headSHA)
+	passedStatuses := []github.Status{
+		{Context: "ci/build", State: github.StatusSuccess},
+	}
+	passedCheckRuns := []github.CheckRun{
+		{
+			ID:         1,
+			Name:       "ci/test",
+			Status:     externalplugins.CheckRunStatusCompleted,
+			Conclusion: externalplugins.CheckRunConclusionSuccess,
+		},
+	}
+
+	testcases := []struct {
+		name                string
+		statusEvent         *github.StatusEvent
+		checkRunEvent       *CheckRunEvent
+		comments            []github.IssueComment
+		labels              []string
+		checkRuns           []github.CheckRun
+		labelWhenChecksPass bool
+
+		shouldAddLabel bool
+	}{
+		{
+			name:        "Status passed and all the checks passed",
+			statusEvent: &github.StatusEvent{SHA: headSHA, State: github.StatusSuccess, Context: "ci/build"},
+			comments: []github.IssueComment{
+				{ID: 1, Body: waitingComment, User: github.User{Login: "k8s-ci-robot"}},
+			},
+			labels:              []string{lgtmTwo},
+			checkRuns:           passedCheckRuns,
+			labelWhenChecksPass: true,
+			shouldAddLabel:      true,
+		},
+		{
+			name: "Check run passed and all the checks passed",
+			checkRunEvent: &CheckRunEvent{
+				Action: externalplugins.CheckRunStatusCompleted,
+				CheckRun: github.CheckRun{
+					HeadSHA:    headSHA,
+					Name:       "ci/test",
+					Status:     externalplugins.CheckRunStatusCompleted,
+					Conclusion: externalplugins.CheckRunConclusionSuccess,
+				},
+			},
+			comments: []github.IssueComment{
+				{ID: 1, Body: waitingComment, User: github.User{Login: "k8s-ci-robot"}},
+			},
+			labels:              []string{lgtmTwo},
+			checkRuns:           passedCheckRuns,
+			labelWhenChecksPass: true,
+			shouldAddLabel:      true,
+		},
+		{
+			name:        "Some checks are still pending",
+			statusEvent: &github.StatusEvent{SHA: headSHA, State: github.StatusSuccess, Context: "ci/build"},
+			comments: []github.IssueComment{
+				{ID: 1, Body: waitingComment, User: github.User{Login: "k8s-ci-robot"}},
+			},
+			labels:              []string{lgtmTwo},
+			labelWhenChecksPass: true,
+		},
+		{
+			name:        "Not waiting for the checks",
+			statusEvent: &github.StatusEvent{SHA: headSHA, State: github.StatusSuccess, Context: "ci/build"},
+			comments: []github.IssueComment{
+				{ID: 1, Body: waitingComment, User: github.User{Login: "collab1"}},
+			},
+			labels:              []string{lgtmTwo},
+			checkRuns:           passedCheckRuns,
+			labelWhenChecksPass: true,
+		},
+		{
+			name:        "Label when checks pass is disabled",
+			statusEvent: &github.StatusEvent{SHA: headSHA, State: github.StatusSuccess, Context: "ci/build"},
+			comments: []github.IssueComment{
+				{ID: 1, Body: waitingComment, User: github.User{Login: "k8s-ci-robot"}},
+			},
+			labels:    []string{lgtmTwo},
+			checkRuns: passedCheckRuns,
+		},
+		{
+			name:        "Status is not required",
+			statusEvent: &github.StatusEvent{SHA: headSHA, State: github.StatusSuccess, Context: "ci/optional"},
+			comments: []github.IssueComment{
+				{ID: 1, Body: waitingComment, User: github.User{Login: "k8s-ci-robot"}},
+			},
+			labels:              []string{lgtmTwo},
+			checkRuns:           passedCheckRuns,
+			labelWhenChecksPass: true,
+		},
+		{
+			name:        "Approval rules are no longer satisfied",
+			statusEvent: &github.StatusEvent{SHA: headSHA, State: github.StatusSuccess, Context: "ci/build"},
+			comments: []github.IssueComment{
+				{ID: 1, Body: waitingComment, User: github.User{Login: "k8s-ci-robot"}},
+			},
+			labels:              []string{lgtmOne},
+			checkRuns:           passedCheckRuns,
+			labelWhenChecksPass: true,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			var labels []string
+			for _, label := range tc.labels {
+				labels = append(labels, "org/repo#5:"+label)
+			}
+			fc := &fakeGitHubClient{
+				FakeClient: &fakegithub.FakeClient{
+					IssueComments: map[int][]github.IssueComment{5: tc.comments},
+					PullRequests: map[int]*github.PullRequest{
+						5: {Number: 5, Head: github.PullRequestBranch{SHA: headSHA}},
+					},
+					IssueLabelsExisting: labels,
+					CombinedStatuses: map[string]*github.CombinedStatus{
+						headSHA: {SHA: headSHA, Statuses: passedStatuses},
+					},
+				},
+				CheckRuns: map[string]*github.CheckRunList{
+					headSHA: {Total: len(tc.checkRuns), CheckRuns: tc.checkRuns},
+				},
+			}
+			cfg := &externalplugins.Configuration{
+				TiCommunityMerge: []externalplugins.TiCommunityMerge{
+					{
+						Repos:               []string{"org/repo"},
+						RequireContexts:     []string{"ci/build", "ci/test"},
+						LabelWhenChecksPass: tc.labelWhenChecksPass,
+					},
+				},
+			}
+			foc := &fakeOwnersClient{
+				committers: []string{"collab1"},
+				needsLgtm:  2,
+			}
+			repo := github.Repo{Owner: github.User{Login: "org"}, Name: "repo"}
+			log := logrus.WithField("plugin", PluginName)
+
+			var err error
+			if tc.statusEvent != nil {
+				tc.statusEvent.Repo = repo
+				err = HandleStatusEvent(fc, nil, tc.statusEvent, cfg, foc, log)
+			} else {
+				tc.checkRunEvent.Repo = repo
+				err = HandleCheckRunEvent(fc, nil, tc.checkRunEvent, cfg, foc, log)
+			}
+			if err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+
+			canMerge := "org/repo#5:" + externalplugins.CanMergeLabel
+			hasLabel := false
+			for _, label := range fc.IssueLabelsAdded {
+				if label == canMerge {
+					hasLabel = true
+				}
+			}
+			if hasLabel != tc.shouldAddLabel {
+				t.Errorf("label added mismatch: got %v, want %v", hasLabel, tc.shouldAddLabel)
+			}
+
+			waitingDeleted := len(fc.IssueCommentsDeleted) == 1 && fc.IssueCommentsDeleted[0] == "org/repo#1"
+			if waitingDeleted != tc.shouldAddLabel {
+				t.Errorf("expected the waiting response deleted: %v, got deleted comments %v",
+					tc.shouldAddLabel, fc.IssueCommentsDeleted)
+			}
+		})
+	}
+}
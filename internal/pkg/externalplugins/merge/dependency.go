@@ -0,0 +1,492 @@
+package merge
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+	git "k8s.io/test-infra/prow/git/v2"
+	"k8s.io/test-infra/prow/github"
+)
+
+const (
+	// dependenciesIdentifier identifies the sticky comment which shows the status of the dependencies,
+	// it also records the dependencies declared by `/depends-on`.
+	dependenciesIdentifier = "Merge Dependencies"
+	// waitingForDependenciesIdentifier identifies the responses to the `/merge` that waits for the dependencies.
+	waitingForDependenciesIdentifier = "Merge Waiting For Dependencies"
+)
+
+// The states of the dependencies, the open dependencies and the dependencies closed without merging
+// withhold the 'can-merge' label. The dependencies which can not be found are reported as errors.
+const (
+	dependencyMerged  = "merged"
+	dependencyOpen    = "open"
+	dependencyClosed  = "closed"
+	dependencyUnknown = "unknown"
+)
+
+var (
+	// DependsOnRe is the regex that matches the `/depends-on` comments, the dependencies declared by
+	// the comments are removed by `/depends-on cancel`.
+	DependsOnRe = regexp.MustCompile(`(?mi)^/depends-on(?:\s+(cancel))?\s+(.+?)\s*$`)
+	// dependsOnBodyRe matches the dependencies declared in the PR body, such as "depends on #1234, org/repo#5".
+	dependsOnBodyRe = regexp.MustCompile(`(?i)\bdepends[ \t-]+on:?((?:[ \t,]*(?:and[ \t]+)?` +
+		`(?:https://github\.com/[\w.-]+/[\w.-]+/pull/\d+|(?:[\w.-]+/[\w.-]+)?#\d+))+)`)
+	// referenceRe matches a reference to a PR, such as #1234, org/repo#1234 or the URL of the PR.
+	referenceRe = regexp.MustCompile(`https://github\.com/([\w.-]+)/([\w.-]+)/pull/(\d+)|` +
+		`(?:([\w.-]+)/([\w.-]+))?#(\d+)`)
+	// pullRequestURLRe matches the URL of the PR in the search results.
+	pullRequestURLRe = regexp.MustCompile(`/([^/]+)/([^/]+)/pull/(\d+)$`)
+	// dependenciesRe matches the sticky comment which records the dependencies declared by `/depends-on`.
+	dependenciesRe = regexp.MustCompile("<!--" + dependenciesIdentifier + ":([^>]*)-->")
+	// waitingForDependenciesRe matches the responses to the `/merge` that waits for the dependencies.
+	waitingForDependenciesRe = regexp.MustCompile("<!--" + waitingForDependenciesIdentifier + ": ([0-9a-f]+)-->")
+)
+
+// dependency is a PR which another PR depends on.
+type dependency struct {
+	org    string
+	repo   string
+	number int
+}
+
+// String returns the dependency in the form of org/repo#number.
+func (d dependency) String() string {
+	return fmt.Sprintf("%s/%s#%d", d.org, d.repo, d.number)
+}
+
+// dependencyStatus is the status of a dependency shown in the sticky comment.
+type dependencyStatus struct {
+	Ref   string
+	State string
+}
+
+// parseReferences returns the PRs referenced by the text, #number refers to the PR of the repo.
+func parseReferences(text, org, repo string) []dependency {
+	var refs []dependency
+	for _, m := range referenceRe.FindAllStringSubmatch(text, -1) {
+		ref := dependency{org: org, repo: repo}
+		var number string
+		switch {
+		case m[3] != "":
+			ref.org, ref.repo, number = m[1], m[2], m[3]
+		case m[4] != "":
+			ref.org, ref.repo, number = m[4], m[5], m[6]
+		default:
+			number = m[6]
+		}
+		n, err := strconv.Atoi(number)
+		if err != nil {
+			continue
+		}
+		ref.number = n
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// parseBodyDependencies returns the dependencies declared in the PR body.
+func parseBodyDependencies(body, org, repo string) []dependency {
+	var deps []dependency
+	for _, m := range dependsOnBodyRe.FindAllStringSubmatch(body, -1) {
+		deps = append(deps, parseReferences(m[1], org, repo)...)
+	}
+	return deps
+}
+
+// getDependencyComments returns the sticky comments of the dependencies and the dependencies declared
+// by `/depends-on` which are recorded in the latest one.
+func getDependencyComments(gc githubClient, org, repo string, number int) ([]*github.IssueComment,
+	[]dependency, error) {
+	botUserChecker, err := gc.BotUserChecker()
+	if err != nil {
+		return nil, nil, err
+	}
+	comments, err := gc.ListIssueComments(org, repo, number)
+	if err != nil {
+		return nil, nil, err
+	}
+	stickyComments := externalplugins.FilterStickyComments(comments, botUserChecker, func(body string) bool {
+		return dependenciesRe.MatchString(body)
+	})
+	if len(stickyComments) == 0 {
+		return nil, nil, nil
+	}
+	m := dependenciesRe.FindStringSubmatch(stickyComments[len(stickyComments)-1].Body)
+	return stickyComments, parseReferences(m[1], org, repo), nil
+}
+
+// syncDependencies updates the sticky comment of the dependencies, and returns the unmerged dependencies.
+func syncDependencies(gc githubClient, config *externalplugins.Configuration, pr *github.PullRequest,
+	log *logrus.Entry) ([]string, error) {
+	org := pr.Base.Repo.Owner.Login
+	repo := pr.Base.Repo.Name
+	stickyComments, commandDeps, err := getDependencyComments(gc, org, repo, pr.Number)
+	if err != nil {
+		return nil, err
+	}
+	return updateDependencies(gc, config, pr, stickyComments, commandDeps, log)
+}
+
+// updateDependencies shows the status of the dependencies declared in the PR body and by `/depends-on`
+// in the sticky comment, and returns the unmerged dependencies.
+func updateDependencies(gc githubClient, config *externalplugins.Configuration, pr *github.PullRequest,
+	stickyComments []*github.IssueComment, commandDeps []dependency, log *logrus.Entry) ([]string, error) {
+	org := pr.Base.Repo.Owner.Login
+	repo := pr.Base.Repo.Name
+	self := dependency{org: org, repo: repo, number: pr.Number}.String()
+
+	seen := map[string]bool{self: true}
+	var deps []dependency
+	for _, dep := range append(parseBodyDependencies(pr.Body, org, repo), commandDeps...) {
+		if !seen[dep.String()] {
+			seen[dep.String()] = true
+			deps = append(deps, dep)
+		}
+	}
+	if len(deps) == 0 {
+		for _, comment := range stickyComments {
+			if err := gc.DeleteComment(org, repo, comment.ID); err != nil {
+				log.WithError(err).Errorf("Failed to delete comment, ID: %d.", comment.ID)
+			}
+		}
+		return nil, nil
+	}
+
+	var statuses []dependencyStatus
+	var unmerged, closed, unknown []string
+	for _, dep := range deps {
+		state := dependencyOpen
+		depPR, err := gc.GetPullRequest(dep.org, dep.repo, dep.number)
+		switch {
+		case err != nil:
+			log.WithError(err).Warnf("Failed to get the dependency %s.", dep)
+			state = dependencyUnknown
+		case depPR.Merged:
+			state = dependencyMerged
+		case depPR.State != "open":
+			state = dependencyClosed
+		}
+		switch state {
+		case dependencyOpen:
+			unmerged = append(unmerged, dep.String())
+		case dependencyClosed:
+			// The dependency closed without merging is not satisfied, it withholds the label
+			// until it is removed from the dependencies.
+			unmerged = append(unmerged, dep.String())
+			closed = append(closed, dep.String())
+		case dependencyUnknown:
+			unknown = append(unknown, dep.String())
+		}
+		statuses = append(statuses, dependencyStatus{Ref: dep.String(), State: state})
+	}
+
+	resp, err := config.RenderMessage(org, repo, externalplugins.MessageMergeDependencies,
+		map[string]interface{}{
+			"dependencies": statuses,
+			"closed":       closed,
+			"unknown":      unknown,
+			"label":        config.LabelSchemeFor(org, repo).CanMergeLabel,
+		})
+	if err != nil {
+		return nil, err
+	}
+	var commandRefs []string
+	for _, dep := range commandDeps {
+		commandRefs = append(commandRefs, dep.String())
+	}
+	body := fmt.Sprintf("%s\n\n<!--%s: %s-->", resp, dependenciesIdentifier, strings.Join(commandRefs, " "))
+	if err := externalplugins.UpdateStickyComment(gc, org, repo, pr.Number, stickyComments, body,
+		false, log); err != nil {
+		return nil, err
+	}
+	return unmerged, nil
+}
+
+// handleDependsOnCommand adds or removes the dependencies declared by `/depends-on`.
+func handleDependsOnCommand(gc githubClient, gitClient git.ClientFactory, ice *github.IssueCommentEvent,
+	cfg *externalplugins.Configuration, ol ownersclient.OwnersLoader, cancel bool, refs string,
+	log *logrus.Entry) error {
+	org := ice.Repo.Owner.Login
+	repo := ice.Repo.Name
+	number := ice.Issue.Number
+	if !cfg.MergeFor(org, repo).TrackDependencies {
+		return nil
+	}
+
+	allowed, err := checkAuthorOrCommitter(gc, ice, cfg, ol,
+		externalplugins.MessageMergeDependsOnOnlyAuthorAndCommitters, log)
+	if err != nil || !allowed {
+		return err
+	}
+
+	pr, err := gc.GetPullRequest(org, repo, number)
+	if err != nil {
+		return err
+	}
+	stickyComments, commandDeps, err := getDependencyComments(gc, org, repo, number)
+	if err != nil {
+		return err
+	}
+	changed := map[string]bool{}
+	for _, dep := range parseReferences(refs, org, repo) {
+		changed[dep.String()] = true
+	}
+	var deps []dependency
+	for _, dep := range commandDeps {
+		if !changed[dep.String()] {
+			deps = append(deps, dep)
+		}
+	}
+	if !cancel {
+		for _, dep := range parseReferences(refs, org, repo) {
+			if changed[dep.String()] {
+				delete(changed, dep.String())
+				deps = append(deps, dep)
+			}
+		}
+	}
+
+	unmerged, err := updateDependencies(gc, cfg, pr, stickyComments, deps, log)
+	if err != nil {
+		return err
+	}
+	return enforceDependencies(gc, gitClient, cfg, ol, pr, unmerged, log)
+}
+
+// handlePullRequestBodyChanged updates the dependencies when the PR is opened or its body is edited.
+func handlePullRequestBodyChanged(gc githubClient, gitClient git.ClientFactory, pe *github.PullRequestEvent,
+	cfg *externalplugins.Configuration, ol ownersclient.OwnersLoader, log *logrus.Entry) error {
+	pr := &pe.PullRequest
+	if pr.State != "open" || !cfg.MergeFor(pr.Base.Repo.Owner.Login, pr.Base.Repo.Name).TrackDependencies {
+		return nil
+	}
+	unmerged, err := syncDependencies(gc, cfg, pr, log)
+	if err != nil {
+		return err
+	}
+	return enforceDependencies(gc, gitClient, cfg, ol, pr, unmerged, log)
+}
+
+// handleDependencyClosed re-evaluates the open PRs which depend on the closed PR, they are found by searching
+// the sticky comments of the dependencies in the repos which track the dependencies.
+func handleDependencyClosed(gc githubClient, gitClient git.ClientFactory, pe *github.PullRequestEvent,
+	cfg *externalplugins.Configuration, ol ownersclient.OwnersLoader, log *logrus.Entry) error {
+	scopes := getTrackDependenciesScopes(cfg)
+	if len(scopes) == 0 {
+		return nil
+	}
+	closed := dependency{
+		org:    pe.PullRequest.Base.Repo.Owner.Login,
+		repo:   pe.PullRequest.Base.Repo.Name,
+		number: pe.PullRequest.Number,
+	}
+	query := fmt.Sprintf("is:pr is:open in:comments \"%s\" %s", closed, strings.Join(scopes, " "))
+	issues, err := gc.FindIssues(query, "", false)
+	if err != nil {
+		return fmt.Errorf("failed to find the pull requests depending on %s: %v", closed, err)
+	}
+
+	for _, issue := range issues {
+		m := pullRequestURLRe.FindStringSubmatch(issue.HTMLURL)
+		if m == nil {
+			continue
+		}
+		org, repo := m[1], m[2]
+		l := log.WithFields(logrus.Fields{"org": org, "repo": repo, "pr": issue.Number})
+		if !cfg.MergeFor(org, repo).TrackDependencies {
+			continue
+		}
+		pr, err := gc.GetPullRequest(org, repo, issue.Number)
+		if err != nil {
+			l.WithError(err).Error("Failed to get the pull request.")
+			continue
+		}
+		if pr.State != "open" {
+			continue
+		}
+		unmerged, err := syncDependencies(gc, cfg, pr, l)
+		if err != nil {
+			l.WithError(err).Error("Failed to update the dependencies.")
+			continue
+		}
+		if err := enforceDependencies(gc, gitClient, cfg, ol, pr, unmerged, l); err != nil {
+			l.WithError(err).Error("Failed to re-evaluate the dependencies.")
+		}
+	}
+	return nil
+}
+
+// getTrackDependenciesScopes returns the search qualifiers of the orgs and repos which track the dependencies.
+func getTrackDependenciesScopes(cfg *externalplugins.Configuration) []string {
+	var scopes []string
+	for _, opts := range cfg.TiCommunityMerge {
+		if !opts.TrackDependencies {
+			continue
+		}
+		for _, repo := range opts.Repos {
+			if strings.Contains(repo, "/") {
+				scopes = append(scopes, "repo:"+repo)
+			} else {
+				scopes = append(scopes, "org:"+repo)
+			}
+		}
+	}
+	return scopes
+}
+
+// enforceDependencies withholds the 'can-merge' label while any dependency is not merged, and adds the label
+// if the PR has been waiting for the dependencies which are all merged now.
+func enforceDependencies(gc githubClient, gitClient git.ClientFactory, cfg *externalplugins.Configuration,
+	ol ownersclient.OwnersLoader, pr *github.PullRequest, unmerged []string, log *logrus.Entry) error {
+	if len(unmerged) == 0 {
+		if err := handleWaitingForDependencies(gc, gitClient, cfg, ol, pr, log); err != nil {
+			return err
+		}
+		return tryAutoMerge(gc, gitClient, cfg, ol, pr, log)
+	}
+	return withholdCanMergeLabel(gc, cfg, pr, unmerged, log)
+}
+
+// withholdCanMergeLabel removes the 'can-merge' label because of the unmerged dependencies, the label is
+// added again once they are merged.
+func withholdCanMergeLabel(gc githubClient, cfg *externalplugins.Configuration, pr *github.PullRequest,
+	unmerged []string, log *logrus.Entry) error {
+	org := pr.Base.Repo.Owner.Login
+	repo := pr.Base.Repo.Name
+	labels, err := gc.GetIssueLabels(org, repo, pr.Number)
+	if err != nil {
+		return err
+	}
+	canMergeLabel := cfg.LabelSchemeFor(org, repo).CanMergeLabel
+	if !github.HasLabel(canMergeLabel, labels) {
+		return nil
+	}
+	log.Infof("Removing '%s' label because the dependencies are not merged: %v.", canMergeLabel, unmerged)
+	if err := gc.RemoveLabel(org, repo, pr.Number, canMergeLabel); err != nil {
+		return err
+	}
+	return waitForDependencies(gc, cfg, pr, unmerged, true, log)
+}
+
+// waitForDependencies responds that the 'can-merge' label is withheld until the dependencies are merged,
+// the response records the head commit of the PR so that the label is added once they are merged.
+func waitForDependencies(gc githubClient, cfg *externalplugins.Configuration, pr *github.PullRequest,
+	unmerged []string, removed bool, log *logrus.Entry) error {
+	org := pr.Base.Repo.Owner.Login
+	repo := pr.Base.Repo.Name
+	waiting, err := isWaitingForDependencies(gc, pr)
+	if err != nil {
+		return err
+	}
+	if waiting && !removed {
+		return nil
+	}
+	resp, err := getDependenciesNotMergedResponse(cfg, org, repo, pr.Head.SHA, unmerged, removed)
+	if err != nil {
+		return err
+	}
+	log.Infof("Commenting the unmerged dependencies with the message: %s", resp)
+	return gc.CreateComment(org, repo, pr.Number, resp)
+}
+
+// getDependenciesNotMergedResponse returns the response which tells the 'can-merge' label is withheld
+// until the dependencies are merged, the response records the commit it is waiting for.
+func getDependenciesNotMergedResponse(cfg *externalplugins.Configuration, org, repo, sha string,
+	unmerged []string, removed bool) (string, error) {
+	resp, err := cfg.RenderMessage(org, repo, externalplugins.MessageMergeWaitingForDependencies,
+		map[string]interface{}{
+			"dependencies": unmerged,
+			"label":        cfg.LabelSchemeFor(org, repo).CanMergeLabel,
+			"removed":      removed,
+		})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s\n\n<!--%s: %s-->", resp, waitingForDependenciesIdentifier, sha), nil
+}
+
+// isWaitingForDependencies returns true if the head commit of the PR is waiting for the dependencies.
+func isWaitingForDependencies(gc githubClient, pr *github.PullRequest) (bool, error) {
+	botUserChecker, err := gc.BotUserChecker()
+	if err != nil {
+		return false, err
+	}
+	comments, err := gc.ListIssueComments(pr.Base.Repo.Owner.Login, pr.Base.Repo.Name, pr.Number)
+	if err != nil {
+		return false, err
+	}
+	for _, comment := range comments {
+		m := waitingForDependenciesRe.FindStringSubmatch(comment.Body)
+		if botUserChecker(comment.User.Login) && m != nil && m[1] == pr.Head.SHA {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// handleWaitingForDependencies adds the 'can-merge' label if the PR has been waiting for the dependencies
+// which are all merged now, and the PR still satisfies the other merge rules.
+func handleWaitingForDependencies(gc githubClient, gitClient git.ClientFactory, cfg *externalplugins.Configuration,
+	ol ownersclient.OwnersLoader, pr *github.PullRequest, log *logrus.Entry) error {
+	org := pr.Base.Repo.Owner.Login
+	repo := pr.Base.Repo.Name
+	number := pr.Number
+	waiting, err := isWaitingForDependencies(gc, pr)
+	if err != nil || !waiting {
+		return err
+	}
+
+	labels, err := gc.GetIssueLabels(org, repo, number)
+	if err != nil {
+		return err
+	}
+	if github.HasLabel(cfg.LabelSchemeFor(org, repo).CanMergeLabel, labels) {
+		return nil
+	}
+
+	botUserChecker, err := gc.BotUserChecker()
+	if err != nil {
+		return err
+	}
+	cp := &issueCommentPruner{gc: gc, org: org, repo: repo, number: number, isBot: botUserChecker, log: log}
+
+	opts := cfg.MergeFor(org, repo)
+	// The committer who wants to merge is unknown, so only the exempt labels are considered.
+	freeze, err := getBlockingFreeze(gc, opts, org, pr.Base.Ref, labels, "", time.Now(), log)
+	if err != nil {
+		return err
+	}
+	if freeze != nil {
+		log.Infof("Skip adding the label because the branch is frozen by %s.", freeze.Name)
+		return nil
+	}
+
+	if len(opts.RequireContexts) != 0 {
+		result, err := externalplugins.GetRequiredChecksResult(gc, org, repo, pr.Head.SHA, opts.RequireContexts)
+		if err != nil {
+			return err
+		}
+		if !result.Passed() {
+			resp, err := getChecksNotPassedResponse(cfg, org, repo, pr.Head.SHA, result)
+			if err != nil {
+				return err
+			}
+			// The merge waits for the required checks instead.
+			cp.PruneComments(func(comment github.IssueComment) bool {
+				return waitingForDependenciesRe.MatchString(comment.Body)
+			})
+			log.Infof("Commenting the required checks with the message: %s", resp)
+			return gc.CreateComment(org, repo, number, resp)
+		}
+	}
+
+	log.Info("All the dependencies have been merged.")
+	return addCanMergeLabelIfApproved(gc, gitClient, cfg, ol, org, repo, number, labels, cp, log)
+}
@@ -0,0 +1,466 @@
+package merge
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+)
+
+func TestParseBodyDependencies(t *testing.T) {
+	testcases := []struct {
+		name string
+		body string
+
+		expectDependencies []string
+	}{
+		{
+			name:               "Same repo",
+			body:               "Depends on #1234.",
+			expectDependencies: []string{"org/repo#1234"},
+		},
+		{
+			name:               "Cross repo",
+			body:               "This PR depends on other/repo#5",
+			expectDependencies: []string{"other/repo#5"},
+		},
+		{
+			name: "Multiple dependencies",
+			body: "depends-on: #1, other/repo#2 and https://github.com/another/repo/pull/3",
+			expectDependencies: []string{
+				"org/repo#1",
+				"other/repo#2",
+				"another/repo#3",
+			},
+		},
+		{
+			name: "Multiple lines",
+			body: "Depends on #1\n\nclose #10\ndepends on #2",
+			expectDependencies: []string{
+				"org/repo#1",
+				"org/repo#2",
+			},
+		},
+		{
+			name: "References without depends on",
+			body: "close #10, related to #11",
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			var deps []string
+			for _, dep := range parseBodyDependencies(tc.body, "org", "repo") {
+				deps = append(deps, dep.String())
+			}
+			if !equality.Semantic.DeepEqual(deps, tc.expectDependencies) {
+				t.Errorf("dependencies mismatch: got %v, want %v", deps, tc.expectDependencies)
+			}
+		})
+	}
+}
+
+// newDependencyPullRequest returns a PR of org/repo which is used as the dependency or the dependent.
+func newDependencyPullRequest(number int, state string, merged bool, body string) *github.PullRequest {
+	return &github.PullRequest{
+		Number: number,
+		State:  state,
+		Merged: merged,
+		Body:   body,
+		Head:   github.PullRequestBranch{SHA: headSHA},
+		Base: github.PullRequestBranch{
+			Ref:  "master",
+			Repo: github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+		},
+	}
+}
+
+func TestMergeWithDependencies(t *testing.T) {
+	testcases := []struct {
+		name              string
+		body              string
+		dependencyMerged  bool
+		dependencyClosed  bool
+		trackDependencies bool
+
+		shouldAddLabel  bool
+		expectDepsState string
+		expectWaiting   bool
+	}{
+		{
+			name:              "Dependency is open",
+			body:              "Depends on #3",
+			trackDependencies: true,
+			expectDepsState:   "org/repo#3: open",
+			expectWaiting:     true,
+		},
+		{
+			name:              "Dependency is merged",
+			body:              "Depends on #3",
+			dependencyMerged:  true,
+			trackDependencies: true,
+			shouldAddLabel:    true,
+			expectDepsState:   "org/repo#3: merged",
+		},
+		{
+			name:              "Dependency is closed without merging",
+			body:              "Depends on #3",
+			dependencyClosed:  true,
+			trackDependencies: true,
+			expectDepsState:   "org/repo#3: closed",
+			expectWaiting:     true,
+		},
+		{
+			name:              "Dependency can not be found",
+			body:              "Depends on #9",
+			trackDependencies: true,
+			shouldAddLabel:    true,
+			expectDepsState:   "**Error**: failed to get org/repo#9",
+		},
+		{
+			name:              "No dependencies",
+			body:              "Fix a bug",
+			trackDependencies: true,
+			shouldAddLabel:    true,
+		},
+		{
+			name:           "Dependencies are not tracked",
+			body:           "Depends on #3",
+			shouldAddLabel: true,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			dependencyState := "open"
+			if tc.dependencyMerged || tc.dependencyClosed {
+				dependencyState = "closed"
+			}
+			fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
+				IssueComments: make(map[int][]github.IssueComment),
+				PullRequests: map[int]*github.PullRequest{
+					3: newDependencyPullRequest(3, dependencyState, tc.dependencyMerged, ""),
+					5: newDependencyPullRequest(5, "open", false, tc.body),
+				},
+				IssueLabelsExisting: []string{"org/repo#5:" + lgtmTwo},
+			}}
+			e := &github.IssueCommentEvent{
+				Action: github.IssueCommentActionCreated,
+				Issue: github.Issue{
+					User:        github.User{Login: "author"},
+					Number:      5,
+					State:       "open",
+					PullRequest: &struct{}{},
+				},
+				Comment: github.IssueComment{
+					Body: "/merge",
+					User: github.User{Login: "collab1"},
+				},
+				Repo: github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+			}
+			cfg := &externalplugins.Configuration{
+				TiCommunityMerge: []externalplugins.TiCommunityMerge{
+					{
+						Repos:             []string{"org/repo"},
+						TrackDependencies: tc.trackDependencies,
+					},
+				},
+			}
+			foc := &fakeOwnersClient{
+				committers: []string{"collab1"},
+				needsLgtm:  2,
+			}
+			cp := &fakePruner{GitHubClient: fc.FakeClient}
+
+			if err := HandleIssueCommentEvent(fc, nil, e, cfg, foc, cp, logrus.WithField("plugin", PluginName)); err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+
+			hasLabel := false
+			for _, label := range fc.IssueLabelsAdded {
+				if label == "org/repo#5:"+externalplugins.CanMergeLabel {
+					hasLabel = true
+				}
+			}
+			if hasLabel != tc.shouldAddLabel {
+				t.Errorf("label added mismatch: got %v, want %v", hasLabel, tc.shouldAddLabel)
+			}
+
+			depsState := ""
+			waiting := false
+			for _, comment := range fc.IssueComments[5] {
+				if dependenciesRe.MatchString(comment.Body) {
+					depsState = comment.Body
+				}
+				if m := waitingForDependenciesRe.FindStringSubmatch(comment.Body); m != nil && m[1] == headSHA {
+					waiting = true
+				}
+			}
+			if tc.expectDepsState == "" && depsState != "" || !strings.Contains(depsState, tc.expectDepsState) {
+				t.Errorf("expected the dependencies comment contains %q, got %q", tc.expectDepsState, depsState)
+			}
+			if waiting != tc.expectWaiting {
+				t.Errorf("waiting marker mismatch: got %v, want %v", waiting, tc.expectWaiting)
+			}
+		})
+	}
+}
+
+func TestDependsOnCommand(t *testing.T) {
+	existingSticky := fmt.Sprintf("This pull request depends on:\n\n<!--%s: org/repo#3-->", dependenciesIdentifier)
+
+	testcases := []struct {
+		name      string
+		body      string
+		commenter string
+		comments  []github.IssueComment
+		labels    []string
+
+		expectDependencies []string
+		expectRejected     bool
+		expectStickyDelete bool
+		shouldRemoveLabel  bool
+	}{
+		{
+			name:               "Author adds a dependency",
+			body:               "/depends-on #3",
+			commenter:          "author",
+			expectDependencies: []string{"org/repo#3"},
+		},
+		{
+			name:      "Committer adds a cross repo dependency",
+			body:      "/depends-on other/repo#4",
+			commenter: "collab1",
+			comments: []github.IssueComment{
+				{ID: 1, Body: existingSticky, User: github.User{Login: "k8s-ci-robot"}},
+			},
+			expectDependencies: []string{"org/repo#3", "other/repo#4"},
+		},
+		{
+			name:           "Others can not add dependencies",
+			body:           "/depends-on #3",
+			commenter:      "collab2",
+			expectRejected: true,
+		},
+		{
+			name:      "Author cancels the dependency",
+			body:      "/depends-on cancel #3",
+			commenter: "author",
+			comments: []github.IssueComment{
+				{ID: 1, Body: existingSticky, User: github.User{Login: "k8s-ci-robot"}},
+			},
+			expectStickyDelete: true,
+		},
+		{
+			name:               "Withhold the label added before",
+			body:               "/depends-on #3",
+			commenter:          "collab1",
+			labels:             []string{lgtmTwo, externalplugins.CanMergeLabel},
+			expectDependencies: []string{"org/repo#3"},
+			shouldRemoveLabel:  true,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			var labels []string
+			for _, label := range tc.labels {
+				labels = append(labels, "org/repo#5:"+label)
+			}
+			fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
+				IssueComments:  map[int][]github.IssueComment{5: tc.comments},
+				IssueCommentID: 1,
+				PullRequests: map[int]*github.PullRequest{
+					3: newDependencyPullRequest(3, "open", false, ""),
+					4: newDependencyPullRequest(4, "open", false, ""),
+					5: newDependencyPullRequest(5, "open", false, ""),
+				},
+				IssueLabelsExisting: labels,
+			}}
+			e := &github.IssueCommentEvent{
+				Action: github.IssueCommentActionCreated,
+				Issue: github.Issue{
+					User:        github.User{Login: "author"},
+					Number:      5,
+					State:       "open",
+					PullRequest: &struct{}{},
+				},
+				Comment: github.IssueComment{
+					Body: tc.body,
+					User: github.User{Login: tc.commenter},
+				},
+				Repo: github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+			}
+			cfg := &externalplugins.Configuration{
+				TiCommunityMerge: []externalplugins.TiCommunityMerge{
+					{
+						Repos:             []string{"org/repo"},
+						TrackDependencies: true,
+					},
+				},
+			}
+			foc := &fakeOwnersClient{
+				committers: []string{"collab1"},
+				needsLgtm:  2,
+			}
+			cp := &fakePruner{GitHubClient: fc.FakeClient}
+
+			if err := HandleIssueCommentEvent(fc, nil, e, cfg, foc, cp, logrus.WithField("plugin", PluginName)); err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+
+			rejected := false
+			var deps []string
+			for _, comment := range fc.IssueComments[5] {
+				if strings.Contains(comment.Body, "`/depends-on` is only allowed") {
+					rejected = true
+				}
+				if m := dependenciesRe.FindStringSubmatch(comment.Body); m != nil {
+					deps = strings.Fields(m[1])
+				}
+			}
+			if rejected != tc.expectRejected {
+				t.Errorf("rejected mismatch: got %v, want %v", rejected, tc.expectRejected)
+			}
+			if !equality.Semantic.DeepEqual(deps, tc.expectDependencies) {
+				t.Errorf("dependencies mismatch: got %v, want %v", deps, tc.expectDependencies)
+			}
+
+			stickyDeleted := len(fc.IssueCommentsDeleted) == 1 && fc.IssueCommentsDeleted[0] == "org/repo#1"
+			if stickyDeleted != tc.expectStickyDelete {
+				t.Errorf("expected the sticky comment deleted: %v, got deleted comments %v",
+					tc.expectStickyDelete, fc.IssueCommentsDeleted)
+			}
+
+			labelRemoved := len(fc.IssueLabelsRemoved) == 1 &&
+				fc.IssueLabelsRemoved[0] == "org/repo#5:"+externalplugins.CanMergeLabel
+			if labelRemoved != tc.shouldRemoveLabel {
+				t.Errorf("label removed mismatch: got %v, want %v", labelRemoved, tc.shouldRemoveLabel)
+			}
+		})
+	}
+}
+
+func TestHandleDependencyClosed(t *testing.T) {
+	waitingComment := fmt.Sprintf("`/merge` has been accepted.\n\n<!--%s: %s-->",
+		waitingForDependenciesIdentifier, headSHA)
+
+	testcases := []struct {
+		name              string
+		body              string
+		merged            bool
+		comments          []github.IssueComment
+		trackDependencies bool
+
+		shouldAddLabel bool
+	}{
+		{
+			name:   "Last dependency is merged",
+			body:   "Depends on #3",
+			merged: true,
+			comments: []github.IssueComment{
+				{ID: 1, Body: waitingComment, User: github.User{Login: "k8s-ci-robot"}},
+			},
+			trackDependencies: true,
+			shouldAddLabel:    true,
+		},
+		{
+			name: "Last dependency is closed without merging",
+			body: "Depends on #3",
+			comments: []github.IssueComment{
+				{ID: 1, Body: waitingComment, User: github.User{Login: "k8s-ci-robot"}},
+			},
+			trackDependencies: true,
+		},
+		{
+			name:   "Other dependencies are still open",
+			body:   "Depends on #3, #4",
+			merged: true,
+			comments: []github.IssueComment{
+				{ID: 1, Body: waitingComment, User: github.User{Login: "k8s-ci-robot"}},
+			},
+			trackDependencies: true,
+		},
+		{
+			name:              "Not waiting for the dependencies",
+			body:              "Depends on #3",
+			merged:            true,
+			trackDependencies: true,
+		},
+		{
+			name:   "Dependencies are not tracked",
+			body:   "Depends on #3",
+			merged: true,
+			comments: []github.IssueComment{
+				{ID: 1, Body: waitingComment, User: github.User{Login: "k8s-ci-robot"}},
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			closed := newDependencyPullRequest(3, "closed", tc.merged, "")
+			fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
+				IssueComments:  map[int][]github.IssueComment{5: tc.comments},
+				IssueCommentID: 1,
+				Issues: map[int]*github.Issue{
+					5: {Number: 5, State: "open", HTMLURL: "https://github.com/org/repo/pull/5"},
+				},
+				PullRequests: map[int]*github.PullRequest{
+					3: closed,
+					4: newDependencyPullRequest(4, "open", false, ""),
+					5: newDependencyPullRequest(5, "open", false, tc.body),
+				},
+				IssueLabelsExisting: []string{"org/repo#5:" + lgtmTwo},
+			}}
+			e := &github.PullRequestEvent{
+				Action:      github.PullRequestActionClosed,
+				Number:      3,
+				PullRequest: *closed,
+			}
+			cfg := &externalplugins.Configuration{
+				TiCommunityMerge: []externalplugins.TiCommunityMerge{
+					{
+						Repos:             []string{"org/repo"},
+						TrackDependencies: tc.trackDependencies,
+					},
+				},
+			}
+			foc := &fakeOwnersClient{
+				committers: []string{"collab1"},
+				needsLgtm:  2,
+			}
+
+			if err := HandlePullRequestEvent(fc, nil, e, cfg, foc, logrus.WithField("plugin", PluginName)); err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+
+			hasLabel := false
+			for _, label := range fc.IssueLabelsAdded {
+				if label == "org/repo#5:"+externalplugins.CanMergeLabel {
+					hasLabel = true
+				}
+			}
+			if hasLabel != tc.shouldAddLabel {
+				t.Errorf("label added mismatch: got %v, want %v", hasLabel, tc.shouldAddLabel)
+			}
+			// The dependents are only searched in the repos which track the dependencies.
+			expectQueries := ""
+			if tc.trackDependencies {
+				expectQueries = "is:pr is:open in:comments \"org/repo#3\" repo:org/repo"
+			}
+			if got := strings.Join(fc.IssueQueries, ","); got != expectQueries {
+				t.Errorf("search queries mismatch: got %q, want %q", got, expectQueries)
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package merge
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/test-infra/prow/github"
+)
+
+const freezeIdentifier = "Merge Freeze"
+
+var (
+	// FreezeRe is the regex that matches freeze comments, the freeze is canceled by `/freeze cancel`.
+	FreezeRe = regexp.MustCompile(`(?mi)^/freeze(?:\s+(cancel))?\s*$`)
+	// freezeAnnouncementRe matches the announcement of the freeze, it records the freeze is active or not.
+	freezeAnnouncementRe = regexp.MustCompile("<!--" + freezeIdentifier + ": (.+) (active|ended)-->")
+)
+
+// HandleFreezes announces the freezes which start or end on their tracking issues, and removes the
+// 'can-merge' label from the PRs to the frozen branches when the freezes start.
+func HandleFreezes(log *logrus.Entry, gc githubClient, config *externalplugins.Configuration) {
+	for _, merge := range config.TiCommunityMerge {
+		for i := range merge.Freezes {
+			freeze := &merge.Freezes[i]
+			l := log.WithField("freeze", freeze.Name)
+			if err := syncFreeze(gc, config, merge.Repos, freeze, time.Now(), l); err != nil {
+				l.WithError(err).Error("Failed to sync the freeze.")
+			}
+		}
+	}
+}
+
+// handleFreezeCommand starts or ends the freezes tracked by the issue by adding or removing the freeze labels.
+func handleFreezeCommand(gc githubClient, ice *github.IssueCommentEvent, cfg *externalplugins.Configuration,
+	cancel bool, log *logrus.Entry) error {
+	org := ice.Repo.Owner.Login
+	repo := ice.Repo.Name
+	number := ice.Issue.Number
+	author := ice.Comment.User.Login
+	trackingIssue := fmt.Sprintf("%s/%s#%d", org, repo, number)
+
+	for _, merge := range cfg.TiCommunityMerge {
+		for i := range merge.Freezes {
+			freeze := &merge.Freezes[i]
+			if freeze.TrackingIssue != trackingIssue || freeze.FreezeLabel == "" {
+				continue
+			}
+			l := log.WithField("freeze", freeze.Name)
+
+			trusted, err := isTrustedForFreeze(gc, org, freeze, author, l)
+			if err != nil {
+				return err
+			}
+			if !trusted {
+				resp, err := cfg.RenderMessage(org, repo, externalplugins.MessageMergeFreezeOnlyTrusted,
+					map[string]interface{}{
+						"teams": freeze.TrustedTeams,
+					})
+				if err != nil {
+					return err
+				}
+				l.Infof("Reply /freeze request with comment: \"%s\"", resp)
+				return gc.CreateComment(org, repo, number,
+					cfg.FormatResponseRaw(org, repo, ice.Comment.Body, ice.Comment.HTMLURL, author, resp))
+			}
+
+			labels, err := gc.GetIssueLabels(org, repo, number)
+			if err != nil {
+				return err
+			}
+			hasFreezeLabel := github.HasLabel(freeze.FreezeLabel, labels)
+			if cancel && hasFreezeLabel {
+				l.Infof("Removing '%s' label.", freeze.FreezeLabel)
+				if err := gc.RemoveLabel(org, repo, number, freeze.FreezeLabel); err != nil {
+					return err
+				}
+			} else if !cancel && !hasFreezeLabel {
+				l.Infof("Adding '%s' label.", freeze.FreezeLabel)
+				if err := gc.AddLabel(org, repo, number, freeze.FreezeLabel); err != nil {
+					return err
+				}
+			}
+
+			if err := syncFreeze(gc, cfg, merge.Repos, freeze, time.Now(), l); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// syncFreeze announces the freeze on the tracking issue if it starts or ends since the last announcement,
+// the 'can-merge' label is removed from the PRs to the frozen branches of the repos before the freeze
+// is announced.
+func syncFreeze(gc githubClient, config *externalplugins.Configuration, repos []string,
+	freeze *externalplugins.MergeFreeze, now time.Time, log *logrus.Entry) error {
+	org, repo, number, err := freeze.ParseTrackingIssue()
+	if err != nil {
+		return err
+	}
+	active, err := isFreezeActive(gc, freeze, now)
+	if err != nil {
+		return err
+	}
+
+	botUserChecker, err := gc.BotUserChecker()
+	if err != nil {
+		return err
+	}
+	comments, err := gc.ListIssueComments(org, repo, number)
+	if err != nil {
+		return err
+	}
+	announcedActive := false
+	for _, comment := range comments {
+		m := freezeAnnouncementRe.FindStringSubmatch(comment.Body)
+		if botUserChecker(comment.User.Login) && m != nil && m[1] == freeze.Name {
+			announcedActive = m[2] == "active"
+		}
+	}
+	if active == announcedActive {
+		return nil
+	}
+
+	if active {
+		if err := removeFrozenCanMergeLabels(gc, config, repos, freeze, log); err != nil {
+			return err
+		}
+	}
+
+	data := map[string]interface{}{
+		"name":     freeze.Name,
+		"branches": freeze.Branches,
+		"active":   active,
+	}
+	if freeze.End != nil {
+		data["end"] = freeze.End.Format(time.RFC3339)
+	}
+	resp, err := config.RenderMessage(org, repo, externalplugins.MessageMergeFreezeAnnouncement, data)
+	if err != nil {
+		return err
+	}
+	state := "ended"
+	if active {
+		state = "active"
+	}
+	log.Infof("Announcing the freeze with comment: \"%s\"", resp)
+	return gc.CreateComment(org, repo, number,
+		fmt.Sprintf("%s\n\n<!--%s: %s %s-->", resp, freezeIdentifier, freeze.Name, state))
+}
+
+// removeFrozenCanMergeLabels removes the 'can-merge' label from the PRs to the frozen branches,
+// except the PRs with the exempt labels.
+func removeFrozenCanMergeLabels(gc githubClient, config *externalplugins.Configuration, repos []string,
+	freeze *externalplugins.MergeFreeze, log *logrus.Entry) error {
+	var orgs, fullNames []string
+	for _, repo := range repos {
+		if strings.Contains(repo, "/") {
+			fullNames = append(fullNames, repo)
+		} else {
+			orgs = append(orgs, repo)
+		}
+	}
+	prs, err := externalplugins.SearchOpenPullRequests(context.Background(), log, gc, orgs, fullNames)
+	if err != nil {
+		return err
+	}
+
+	exemptLabels := sets.NewString(freeze.ExemptLabels...)
+	for _, pr := range prs {
+		org := string(pr.Repository.Owner.Login)
+		repo := string(pr.Repository.Name)
+		number := int(pr.Number)
+		branch := string(pr.BaseRef.Name)
+		if !freeze.MatchBranch(branch) {
+			continue
+		}
+
+		canMergeLabel := config.LabelSchemeFor(org, repo).CanMergeLabel
+		labels := sets.NewString()
+		for _, label := range pr.Labels.Nodes {
+			labels.Insert(string(label.Name))
+		}
+		if !labels.Has(canMergeLabel) || labels.HasAny(exemptLabels.UnsortedList()...) {
+			continue
+		}
+
+		l := log.WithFields(logrus.Fields{"org": org, "repo": repo, "pr": number})
+		l.Infof("Removing '%s' label because the branch is frozen.", canMergeLabel)
+		if err := gc.RemoveLabel(org, repo, number, canMergeLabel); err != nil {
+			l.WithError(err).Error("Failed to remove the label.")
+			continue
+		}
+		resp, err := config.RenderMessage(org, repo, externalplugins.MessageMergeFrozenLabelRemoved,
+			map[string]interface{}{
+				"label":         canMergeLabel,
+				"branch":        branch,
+				"name":          freeze.Name,
+				"trackingIssue": freeze.TrackingIssue,
+			})
+		if err != nil {
+			return err
+		}
+		if err := gc.CreateComment(org, repo, number, resp); err != nil {
+			l.WithError(err).Error("Failed to comment.")
+		}
+	}
+	return nil
+}
+
+// getBlockingFreeze returns the active freeze of the branch which blocks the PR from being merged,
+// the PRs with the exempt labels and the PRs merged by the trusted members are not blocked.
+func getBlockingFreeze(gc githubClient, opts *externalplugins.TiCommunityMerge, org, branch string,
+	labels []github.Label, login string, now time.Time, log *logrus.Entry) (*externalplugins.MergeFreeze, error) {
+	for i := range opts.Freezes {
+		freeze := &opts.Freezes[i]
+		if !freeze.MatchBranch(branch) {
+			continue
+		}
+		active, err := isFreezeActive(gc, freeze, now)
+		if err != nil {
+			return nil, err
+		}
+		if !active {
+			continue
+		}
+
+		exempt := false
+		for _, label := range freeze.ExemptLabels {
+			if github.HasLabel(label, labels) {
+				exempt = true
+			}
+		}
+		if !exempt && login != "" {
+			exempt, err = isTrustedForFreeze(gc, org, freeze, login, log)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if !exempt {
+			return freeze, nil
+		}
+	}
+	return nil, nil
+}
+
+// isFreezeActive returns true if the freeze is scheduled or the tracking issue has the freeze label.
+func isFreezeActive(gc githubClient, freeze *externalplugins.MergeFreeze, now time.Time) (bool, error) {
+	if freeze.IsScheduled(now) {
+		return true, nil
+	}
+	if freeze.FreezeLabel == "" {
+		return false, nil
+	}
+	org, repo, number, err := freeze.ParseTrackingIssue()
+	if err != nil {
+		return false, err
+	}
+	labels, err := gc.GetIssueLabels(org, repo, number)
+	if err != nil {
+		return false, err
+	}
+	return github.HasLabel(freeze.FreezeLabel, labels), nil
+}
+
+// isTrustedForFreeze returns true if the user is a member of the trusted teams of the freeze in the org.
+func isTrustedForFreeze(gc githubClient, org string, freeze *externalplugins.MergeFreeze, login string,
+	log *logrus.Entry) (bool, error) {
+	for _, slug := range freeze.TrustedTeams {
+		team, err := gc.GetTeamBySlug(slug, org)
+		if err != nil {
+			log.WithError(err).Errorf("Failed to get the team %s.", slug)
+			continue
+		}
+		members, err := gc.ListTeamMembers(org, team.ID, github.RoleAll)
+		if err != nil {
+			return false, err
+		}
+		for _, member := range members {
+			if member.Login == login {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,401 @@
+package merge
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+)
+
+const (
+	freezeName    = "v5.0"
+	trackingIssue = "org/tracking#1000"
+	freezeLabel   = "code-freeze"
+	exemptLabel   = "cherry-pick-approved"
+	// trustedTeam is the team of the fake client whose member is sig-lead.
+	trustedTeam   = "Leads"
+	trustedMember = "sig-lead"
+)
+
+func newSearchResult(number int, branch string, labels ...string) externalplugins.PullRequest {
+	pr := externalplugins.PullRequest{Number: githubql.Int(number)}
+	pr.Repository.Name = "repo"
+	pr.Repository.Owner.Login = "org"
+	pr.BaseRef.Name = githubql.String(branch)
+	for _, label := range labels {
+		pr.Labels.Nodes = append(pr.Labels.Nodes, struct{ Name githubql.String }{Name: githubql.String(label)})
+	}
+	return pr
+}
+
+func newAnnouncement(state string) github.IssueComment {
+	return github.IssueComment{
+		Body: fmt.Sprintf("The freeze.\n\n<!--%s: %s %s-->", freezeIdentifier, freezeName, state),
+		User: github.User{Login: fakegithub.Bot},
+	}
+}
+
+func TestSyncFreeze(t *testing.T) {
+	now := time.Now()
+	hourAgo := now.Add(-time.Hour)
+	hourLater := now.Add(time.Hour)
+	searchResults := []externalplugins.PullRequest{
+		newSearchResult(1, "release-5.0", externalplugins.CanMergeLabel),
+		newSearchResult(2, "master", externalplugins.CanMergeLabel),
+		newSearchResult(3, "release-5.0", externalplugins.CanMergeLabel, exemptLabel),
+		newSearchResult(4, "release-5.0"),
+	}
+
+	testcases := []struct {
+		name           string
+		start          *time.Time
+		end            *time.Time
+		trackingLabels []string
+		comments       []github.IssueComment
+
+		expectRemoved      []string
+		expectAnnouncement string
+	}{
+		{
+			name:               "Scheduled freeze starts",
+			start:              &hourAgo,
+			expectRemoved:      []string{"org/repo#1:" + externalplugins.CanMergeLabel},
+			expectAnnouncement: "active",
+		},
+		{
+			name:           "Freeze is started by the label",
+			trackingLabels: []string{freezeLabel},
+			expectRemoved:  []string{"org/repo#1:" + externalplugins.CanMergeLabel},
+
+			expectAnnouncement: "active",
+		},
+		{
+			name:     "Freeze has been announced",
+			start:    &hourAgo,
+			comments: []github.IssueComment{newAnnouncement("active")},
+		},
+		{
+			name:  "Announcement not created by the bot",
+			start: &hourAgo,
+			comments: []github.IssueComment{
+				{
+					Body: fmt.Sprintf("<!--%s: %s active-->", freezeIdentifier, freezeName),
+					User: github.User{Login: "someone"},
+				},
+			},
+			expectRemoved:      []string{"org/repo#1:" + externalplugins.CanMergeLabel},
+			expectAnnouncement: "active",
+		},
+		{
+			name:               "Scheduled freeze ends",
+			start:              &hourAgo,
+			end:                &hourAgo,
+			comments:           []github.IssueComment{newAnnouncement("active")},
+			expectAnnouncement: "ended",
+		},
+		{
+			name:     "Freeze is ended by removing the label",
+			comments: []github.IssueComment{newAnnouncement("ended"), newAnnouncement("active")},
+
+			expectAnnouncement: "ended",
+		},
+		{
+			name:  "Freeze does not start yet",
+			start: &hourLater,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			var labels []string
+			for _, label := range tc.trackingLabels {
+				labels = append(labels, "org/tracking#1000:"+label)
+			}
+			fc := &fakeGitHubClient{
+				FakeClient: &fakegithub.FakeClient{
+					IssueLabelsExisting: labels,
+					IssueComments:       map[int][]github.IssueComment{1000: tc.comments},
+				},
+				SearchResults: searchResults,
+			}
+			freeze := &externalplugins.MergeFreeze{
+				Name:          freezeName,
+				Branches:      []string{"release-.*"},
+				Start:         tc.start,
+				End:           tc.end,
+				TrackingIssue: trackingIssue,
+				FreezeLabel:   freezeLabel,
+				ExemptLabels:  []string{exemptLabel},
+				TrustedTeams:  []string{trustedTeam},
+			}
+			cfg := &externalplugins.Configuration{}
+
+			err := syncFreeze(fc, cfg, []string{"org"}, freeze, now, logrus.WithField("plugin", PluginName))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !equality.Semantic.DeepEqual(fc.IssueLabelsRemoved, tc.expectRemoved) {
+				t.Errorf("labels removed mismatch: got %v, want %v", fc.IssueLabelsRemoved, tc.expectRemoved)
+			}
+
+			var announcements, others []string
+			for _, comment := range fc.IssueCommentsAdded {
+				if strings.HasPrefix(comment, "org/tracking#1000:") {
+					announcements = append(announcements, comment)
+				} else {
+					others = append(others, comment)
+				}
+			}
+			if len(others) != len(tc.expectRemoved) {
+				t.Errorf("expected a comment for each removed label, got %v", others)
+			}
+			if tc.expectAnnouncement == "" {
+				if len(announcements) != 0 {
+					t.Errorf("unexpected announcements: %v", announcements)
+				}
+				return
+			}
+			marker := fmt.Sprintf("<!--%s: %s %s-->", freezeIdentifier, freezeName, tc.expectAnnouncement)
+			if len(announcements) != 1 || !strings.Contains(announcements[0], marker) {
+				t.Errorf("expected the announcement with %q, got %v", marker, announcements)
+			}
+		})
+	}
+}
+
+func TestFreezeCommand(t *testing.T) {
+	testcases := []struct {
+		name           string
+		body           string
+		commenter      string
+		number         int
+		trackingLabels []string
+		comments       []github.IssueComment
+
+		expectAdded        []string
+		expectRemoved      []string
+		expectComment      string
+		expectAnnouncement string
+	}{
+		{
+			name:               "Trusted member starts the freeze",
+			body:               "/freeze",
+			commenter:          trustedMember,
+			number:             1000,
+			expectAdded:        []string{"org/tracking#1000:" + freezeLabel},
+			expectAnnouncement: "active",
+		},
+		{
+			name:               "Trusted member ends the freeze",
+			body:               "/freeze cancel",
+			commenter:          trustedMember,
+			number:             1000,
+			trackingLabels:     []string{freezeLabel},
+			comments:           []github.IssueComment{newAnnouncement("active")},
+			expectRemoved:      []string{"org/tracking#1000:" + freezeLabel},
+			expectAnnouncement: "ended",
+		},
+		{
+			name:           "Freeze has started",
+			body:           "/freeze",
+			commenter:      trustedMember,
+			number:         1000,
+			trackingLabels: []string{freezeLabel},
+			comments:       []github.IssueComment{newAnnouncement("active")},
+		},
+		{
+			name:          "Untrusted user",
+			body:          "/freeze",
+			commenter:     "someone",
+			number:        1000,
+			expectComment: "`/freeze` is only allowed for the members of the teams: " + trustedTeam,
+		},
+		{
+			name:      "Issue which does not track any freeze",
+			body:      "/freeze",
+			commenter: trustedMember,
+			number:    1001,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			var labels []string
+			for _, label := range tc.trackingLabels {
+				labels = append(labels, "org/tracking#1000:"+label)
+			}
+			fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
+				IssueLabelsExisting: labels,
+				IssueComments:       map[int][]github.IssueComment{1000: tc.comments},
+			}}
+			e := &github.IssueCommentEvent{
+				Action:  github.IssueCommentActionCreated,
+				Comment: github.IssueComment{Body: tc.body, User: github.User{Login: tc.commenter}},
+				Issue:   github.Issue{Number: tc.number, State: "open"},
+				Repo:    github.Repo{Owner: github.User{Login: "org"}, Name: "tracking"},
+			}
+			cfg := &externalplugins.Configuration{}
+			cfg.TiCommunityMerge = []externalplugins.TiCommunityMerge{
+				{
+					Repos: []string{"org/repo"},
+					Freezes: []externalplugins.MergeFreeze{
+						{
+							Name:          freezeName,
+							Branches:      []string{"release-.*"},
+							TrackingIssue: trackingIssue,
+							FreezeLabel:   freezeLabel,
+							TrustedTeams:  []string{trustedTeam},
+						},
+					},
+				},
+			}
+
+			err := HandleIssueCommentEvent(fc, nil, e, cfg, &fakeOwnersClient{}, &fakePruner{},
+				logrus.WithField("plugin", PluginName))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !equality.Semantic.DeepEqual(fc.IssueLabelsAdded, tc.expectAdded) {
+				t.Errorf("labels added mismatch: got %v, want %v", fc.IssueLabelsAdded, tc.expectAdded)
+			}
+			if !equality.Semantic.DeepEqual(fc.IssueLabelsRemoved, tc.expectRemoved) {
+				t.Errorf("labels removed mismatch: got %v, want %v", fc.IssueLabelsRemoved, tc.expectRemoved)
+			}
+
+			switch {
+			case tc.expectComment != "":
+				if len(fc.IssueCommentsAdded) != 1 || !strings.Contains(fc.IssueCommentsAdded[0], tc.expectComment) {
+					t.Errorf("expected the comment %q, got %v", tc.expectComment, fc.IssueCommentsAdded)
+				}
+			case tc.expectAnnouncement != "":
+				marker := fmt.Sprintf("<!--%s: %s %s-->", freezeIdentifier, freezeName, tc.expectAnnouncement)
+				if len(fc.IssueCommentsAdded) != 1 || !strings.Contains(fc.IssueCommentsAdded[0], marker) {
+					t.Errorf("expected the announcement with %q, got %v", marker, fc.IssueCommentsAdded)
+				}
+			default:
+				if len(fc.IssueCommentsAdded) != 0 {
+					t.Errorf("unexpected comments: %v", fc.IssueCommentsAdded)
+				}
+			}
+		})
+	}
+}
+
+func TestMergeFrozenBranch(t *testing.T) {
+	hourAgo := time.Now().Add(-time.Hour)
+
+	testcases := []struct {
+		name      string
+		commenter string
+		branch    string
+		labels    []string
+
+		expectLabelAdded bool
+		expectComment    string
+	}{
+		{
+			name:          "Merge into the frozen branch",
+			commenter:     "collab1",
+			branch:        "release-5.0",
+			labels:        []string{lgtmTwo},
+			expectComment: "`/merge` is not allowed because `release-5.0` is frozen by the code freeze " + freezeName,
+		},
+		{
+			name:             "Merge into the other branch",
+			commenter:        "collab1",
+			branch:           "master",
+			labels:           []string{lgtmTwo},
+			expectLabelAdded: true,
+		},
+		{
+			name:             "PR with the exempt label",
+			commenter:        "collab1",
+			branch:           "release-5.0",
+			labels:           []string{lgtmTwo, exemptLabel},
+			expectLabelAdded: true,
+		},
+		{
+			name:             "Merged by the trusted member",
+			commenter:        trustedMember,
+			branch:           "release-5.0",
+			labels:           []string{lgtmTwo},
+			expectLabelAdded: true,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			var labels []string
+			for _, label := range tc.labels {
+				labels = append(labels, "org/repo#101:"+label)
+			}
+			fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
+				IssueLabelsExisting: labels,
+				IssueComments:       map[int][]github.IssueComment{},
+				PullRequests: map[int]*github.PullRequest{
+					101: {Number: 101, Base: github.PullRequestBranch{Ref: tc.branch}},
+				},
+			}}
+			e := &github.IssueCommentEvent{
+				Action:  github.IssueCommentActionCreated,
+				Comment: github.IssueComment{Body: "/merge", User: github.User{Login: tc.commenter}},
+				Issue: github.Issue{
+					Number:      101,
+					State:       "open",
+					User:        github.User{Login: "author"},
+					PullRequest: &struct{}{},
+				},
+				Repo: github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+			}
+			cfg := &externalplugins.Configuration{}
+			cfg.TiCommunityMerge = []externalplugins.TiCommunityMerge{
+				{
+					Repos: []string{"org/repo"},
+					Freezes: []externalplugins.MergeFreeze{
+						{
+							Name:          freezeName,
+							Branches:      []string{"release-.*"},
+							Start:         &hourAgo,
+							TrackingIssue: trackingIssue,
+							ExemptLabels:  []string{exemptLabel},
+							TrustedTeams:  []string{trustedTeam},
+						},
+					},
+				},
+			}
+			foc := &fakeOwnersClient{
+				committers: []string{"collab1", trustedMember},
+				needsLgtm:  2,
+			}
+
+			err := HandleIssueCommentEvent(fc, nil, e, cfg, foc, &fakePruner{GitHubClient: fc.FakeClient},
+				logrus.WithField("plugin", PluginName))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			labelAdded := len(fc.IssueLabelsAdded) == 1 &&
+				fc.IssueLabelsAdded[0] == "org/repo#101:"+externalplugins.CanMergeLabel
+			if labelAdded != tc.expectLabelAdded {
+				t.Errorf("label added mismatch: got %v, want %v", fc.IssueLabelsAdded, tc.expectLabelAdded)
+			}
+			if tc.expectComment != "" {
+				if len(fc.IssueCommentsAdded) != 1 || !strings.Contains(fc.IssueCommentsAdded[0], tc.expectComment) {
+					t.Errorf("expected the comment %q, got %v", tc.expectComment, fc.IssueCommentsAdded)
+				}
+			}
+		})
+	}
+}
@@ -2,16 +2,18 @@
 package merge
 
 import (
+	"context"
 	"fmt"
 	"regexp"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
 	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/test-infra/prow/config"
+	git "k8s.io/test-infra/prow/git/v2"
 	"k8s.io/test-infra/prow/github"
 	"k8s.io/test-infra/prow/pluginhelp"
 )
@@ -19,19 +21,28 @@ import (
 // PluginName will register into prow.
 const PluginName = "ti-community-merge"
 
-const githubUpdateCommitter = "web-flow"
+const (
+	// canMergeIdentifier identifies the notification which tells the PR is accepted,
+	// the approved diff is stored in it.
+	canMergeIdentifier = "Merge Accepted"
+	// canceledByPushIdentifier identifies the notification which tells the merge is canceled by new commits.
+	canceledByPushIdentifier = "Merge Canceled By Push"
+)
 
 var (
-	addCanMergeLabelNotification = "This pull request has been accepted and is ready to merge. " +
-		"<details>Commit hash: %s</details>"
-	addCanMergeLabelNotificationRe = regexp.MustCompile(fmt.Sprintf(addCanMergeLabelNotification, "(.*)"))
-	configInfoStoreTreeHash        = `Commits that generated by Github will not remove the 'can-merge' label.`
-
-	// CanMergeRe is the regex that matches merge comments
-	CanMergeRe = regexp.MustCompile(`(?mi)^/merge\s*$`)
+	canMergeNotificationRe = regexp.MustCompile("<!--" + canMergeIdentifier +
+		": ([0-9a-f]+) ([0-9a-f]+) ([0-9a-f]+)-->")
+	// The notifications created before the messages are rendered by the templates have no identifier,
+	// they are still recognized to keep the 'can-merge' label of the PRs accepted by them.
+	legacyCanMergeNotificationPrefix = "This pull request has been accepted and is ready to merge."
+	legacyCanMergeNotificationRe     = regexp.MustCompile(regexp.QuoteMeta(legacyCanMergeNotificationPrefix) +
+		" <details>Tree hash: ([0-9a-f]+) Base commit: ([0-9a-f]+) Head commit: ([0-9a-f]+)</details>")
+	legacyCanceledByPushNotification = "Merge canceled because a new commit is pushed."
+
+	// CanMergeRe is the regex that matches merge comments, the merge method is optional.
+	CanMergeRe = regexp.MustCompile(`(?mi)^/merge(?:\s+(squash|rebase|merge))?\s*$`)
 	// CanMergeCancelRe is the regex that matches merge cancel comments
-	CanMergeCancelRe        = regexp.MustCompile(`(?mi)^/merge cancel\s*$`)
-	removeCanMergeLabelNoti = "Merge canceled because a new commit is pushed."
+	CanMergeCancelRe = regexp.MustCompile(`(?mi)^/merge cancel\s*$`)
 )
 
 // HelpProvider constructs the PluginHelp for this plugin that takes into account enabled repositories.
@@ -43,17 +54,37 @@ func HelpProvider(epa *externalplugins.ConfigAgent) func(
 		cfg := epa.Config()
 		for _, repo := range enabledRepos {
 			opts := cfg.MergeFor(repo.Org, repo.Repo)
-			var isConfigured bool
-			var configInfoStrings []string
-			configInfoStrings = append(configInfoStrings, "The plugin has these configurations:<ul>")
-			if opts.StoreTreeHash {
-				configInfoStrings = append(configInfoStrings, "<li>"+configInfoStoreTreeHash+"</li>")
-				isConfigured = true
+			labelScheme := cfg.LabelSchemeFor(repo.Org, repo.Repo)
+			isConfigured := opts.StoreTreeHash || opts.ReviewStatus || opts.RecreateNotification ||
+				len(opts.RequireContexts) != 0 || opts.LabelWhenChecksPass || len(opts.MergeMethods) != 0 ||
+				len(opts.Freezes) != 0 || opts.TrackDependencies || opts.AutoMerge || opts.MergeQueue ||
+				labelScheme.CanMergeLabel != externalplugins.CanMergeLabel
+			if !isConfigured {
+				continue
+			}
+			data := map[string]interface{}{
+				"storeTreeHash":        opts.StoreTreeHash,
+				"reviewStatus":         opts.ReviewStatus,
+				"reviewStatusContext":  externalplugins.ReviewStatusContext,
+				"recreateNotification": opts.RecreateNotification,
+				"requireContexts":      opts.RequireContexts,
+				"labelWhenChecksPass":  opts.LabelWhenChecksPass,
+				"mergeMethods":         opts.MergeMethods,
+				"freezes":              opts.Freezes,
+				"trackDependencies":    opts.TrackDependencies,
+				"autoMerge":            opts.AutoMerge,
+				"mergeQueue":           opts.MergeQueue,
+				"maxBatchSize":         opts.MaxBatchSize,
+				"stagingBranchPrefix":  opts.StagingBranchPrefix,
 			}
-			configInfoStrings = append(configInfoStrings, "</ul>")
-			if isConfigured {
-				configInfo[repo.String()] = strings.Join(configInfoStrings, "\n")
+			if labelScheme.CanMergeLabel != externalplugins.CanMergeLabel {
+				data["canMergeLabel"] = labelScheme.CanMergeLabel
 			}
+			info, err := cfg.RenderMessage(repo.Org, repo.Repo, externalplugins.MessageMergeHelpConfig, data)
+			if err != nil {
+				return nil, err
+			}
+			configInfo[repo.String()] = info
 		}
 		pluginHelp := &pluginhelp.PluginHelp{
 			Description: "The ti-community-merge plugin controls the merge process by adding or removing the '" +
@@ -62,14 +93,44 @@ func HelpProvider(epa *externalplugins.ConfigAgent) func(
 		}
 
 		pluginHelp.AddCommand(pluginhelp.Command{
-			Usage:       "/merge [cancel] or triggers by GitHub review action.",
-			Description: "Start or cancel a merge process.",
+			Usage:       "/merge [cancel|squash|rebase|merge] or triggers by GitHub review action.",
+			Description: "Start or cancel a merge process, the merge method can be chosen if the repository allows it.",
 			Featured:    true,
 			WhoCanUse:   "Collaborators of this repository. Additionally, the PR author can use '/merge cancel'.",
 			Examples: []string{
 				"/merge",
+				"/merge squash",
 				"/merge cancel"},
 		})
+		pluginHelp.AddCommand(pluginhelp.Command{
+			Usage:       "/freeze [cancel]",
+			Description: "Start or end the code freezes tracked by the issue.",
+			Featured:    false,
+			WhoCanUse:   "Members of the trusted teams of the code freezes.",
+			Examples: []string{
+				"/freeze",
+				"/freeze cancel"},
+		})
+		pluginHelp.AddCommand(pluginhelp.Command{
+			Usage: "/depends-on [cancel] <#number|org/repo#number>...",
+			Description: "Declare or remove the PRs which the PR depends on, " +
+				"the 'can-merge' label is withheld until all of them are merged.",
+			Featured:  false,
+			WhoCanUse: "The PR author and committers of this repository.",
+			Examples: []string{
+				"/depends-on #1234",
+				"/depends-on cancel ti-community-infra/tichi#1234"},
+		})
+		pluginHelp.AddCommand(pluginhelp.Command{
+			Usage: "/auto-merge [cancel]",
+			Description: "Enable or disable adding the 'can-merge' label automatically once the approvals " +
+				"are satisfied and the required checks pass.",
+			Featured:  false,
+			WhoCanUse: "The PR author and committers of this repository.",
+			Examples: []string{
+				"/auto-merge",
+				"/auto-merge cancel"},
+		})
 		return pluginHelp, nil
 	}
 }
@@ -81,9 +142,17 @@ type githubClient interface {
 	GetIssueLabels(org, repo string, number int) ([]github.Label, error)
 	GetPullRequest(org, repo string, number int) (*github.PullRequest, error)
 	ListIssueComments(org, repo string, number int) ([]github.IssueComment, error)
+	EditComment(org, repo string, ID int, comment string) error
 	DeleteComment(org, repo string, ID int) error
 	ListPRCommits(org, repo string, number int) ([]github.RepositoryCommit, error)
 	BotUserChecker() (func(candidate string) bool, error)
+	CreateStatus(org, repo, SHA string, s github.Status) error
+	GetCombinedStatus(org, repo, ref string) (*github.CombinedStatus, error)
+	ListCheckRuns(org, repo, ref string) (*github.CheckRunList, error)
+	FindIssues(query, sort string, asc bool) ([]github.Issue, error)
+	Query(context.Context, interface{}, map[string]interface{}) error
+	GetTeamBySlug(slug string, org string) (*github.Team, error)
+	ListTeamMembers(org string, id int, role string) ([]github.TeamMember, error)
 }
 
 // reviewCtx contains information about each review event.
@@ -91,6 +160,8 @@ type reviewCtx struct {
 	author, issueAuthor, body, htmlURL string
 	repo                               github.Repo
 	number                             int
+	// mergeMethod is the merge method chosen by `/merge <method>`, it is empty if none is chosen.
+	mergeMethod string
 }
 
 // commentPruner used to delete bot comment.
@@ -100,13 +171,35 @@ type commentPruner interface {
 
 // HandleIssueCommentEvent handles a GitHub issue comment event and adds or removes a
 // "status/can-merge" label.
-func HandleIssueCommentEvent(gc githubClient, ice *github.IssueCommentEvent, cfg *externalplugins.Configuration,
-	ol ownersclient.OwnersLoader, cp commentPruner, log *logrus.Entry) error {
+func HandleIssueCommentEvent(gc githubClient, gitClient git.ClientFactory, ice *github.IssueCommentEvent,
+	cfg *externalplugins.Configuration, ol ownersclient.OwnersLoader, cp commentPruner, log *logrus.Entry) error {
+	// The freezes are started by `/freeze` on their tracking issues.
+	if m := FreezeRe.FindStringSubmatch(ice.Comment.Body); m != nil && !ice.Issue.IsPullRequest() &&
+		ice.Issue.State == "open" && ice.Action == github.IssueCommentActionCreated {
+		return handleFreezeCommand(gc, ice, cfg, strings.EqualFold(m[1], "cancel"), log)
+	}
+
 	// Only consider open PRs and new comments.
 	if !ice.Issue.IsPullRequest() || ice.Issue.State != "open" || ice.Action != github.IssueCommentActionCreated {
 		return nil
 	}
 
+	// The auto-merge is enabled or disabled by `/auto-merge [cancel]` on the PRs.
+	if m := AutoMergeRe.FindStringSubmatch(ice.Comment.Body); m != nil {
+		if err := handleAutoMergeCommand(gc, gitClient, ice, cfg, ol, strings.EqualFold(m[1], "cancel"),
+			log); err != nil {
+			return err
+		}
+	}
+
+	// The dependencies are declared by `/depends-on` on the PRs.
+	if m := DependsOnRe.FindStringSubmatch(ice.Comment.Body); m != nil {
+		if err := handleDependsOnCommand(gc, gitClient, ice, cfg, ol, strings.EqualFold(m[1], "cancel"), m[2],
+			log); err != nil {
+			return err
+		}
+	}
+
 	rc := reviewCtx{
 		author:      ice.Comment.User.Login,
 		issueAuthor: ice.Issue.User.Login,
@@ -119,8 +212,9 @@ func HandleIssueCommentEvent(gc githubClient, ice *github.IssueCommentEvent, cfg
 	// If we create an "/merge" comment, add status/can-merge if necessary.
 	// If we create a "/merge cancel" comment, remove status/can-merge if necessary.
 	wantMerge := false
-	if CanMergeRe.MatchString(rc.body) {
+	if m := CanMergeRe.FindStringSubmatch(rc.body); m != nil {
 		wantMerge = true
+		rc.mergeMethod = strings.ToLower(m[1])
 	} else if CanMergeCancelRe.MatchString(rc.body) {
 		wantMerge = false
 	} else {
@@ -128,11 +222,12 @@ func HandleIssueCommentEvent(gc githubClient, ice *github.IssueCommentEvent, cfg
 	}
 
 	// Use common handler to do the rest.
-	return handle(wantMerge, cfg, rc, gc, ol, cp, log)
+	return handle(wantMerge, cfg, rc, gc, gitClient, ol, cp, log)
 }
 
-func HandlePullReviewCommentEvent(gc githubClient, pullReviewCommentEvent *github.ReviewCommentEvent,
-	cfg *externalplugins.Configuration, ol ownersclient.OwnersLoader, cp commentPruner, log *logrus.Entry) error {
+func HandlePullReviewCommentEvent(gc githubClient, gitClient git.ClientFactory,
+	pullReviewCommentEvent *github.ReviewCommentEvent, cfg *externalplugins.Configuration,
+	ol ownersclient.OwnersLoader, cp commentPruner, log *logrus.Entry) error {
 	// Only consider open PRs and new comments.
 	if pullReviewCommentEvent.PullRequest.State != "open" ||
 		pullReviewCommentEvent.Action != github.ReviewCommentActionCreated {
@@ -151,8 +246,9 @@ func HandlePullReviewCommentEvent(gc githubClient, pullReviewCommentEvent *githu
 	// If we create an "/merge" comment, add status/can-merge if necessary.
 	// If we create a "/merge cancel" comment, remove status/can-merge if necessary.
 	wantMerge := false
-	if CanMergeRe.MatchString(rc.body) {
+	if m := CanMergeRe.FindStringSubmatch(rc.body); m != nil {
 		wantMerge = true
+		rc.mergeMethod = strings.ToLower(m[1])
 	} else if CanMergeCancelRe.MatchString(rc.body) {
 		wantMerge = false
 	} else {
@@ -160,24 +256,60 @@ func HandlePullReviewCommentEvent(gc githubClient, pullReviewCommentEvent *githu
 	}
 
 	// Use common handler to do the rest.
-	return handle(wantMerge, cfg, rc, gc, ol, cp, log)
+	return handle(wantMerge, cfg, rc, gc, gitClient, ol, cp, log)
 }
 
-func HandlePullRequestEvent(gc githubClient, pe *github.PullRequestEvent,
-	cfg *externalplugins.Configuration, log *logrus.Entry) error {
+func HandlePullRequestEvent(gc githubClient, gitClient git.ClientFactory, pe *github.PullRequestEvent,
+	cfg *externalplugins.Configuration, ol ownersclient.OwnersLoader, log *logrus.Entry) error {
+	// The PRs depending on the closed PR are re-evaluated.
+	if pe.Action == github.PullRequestActionClosed {
+		return handleDependencyClosed(gc, gitClient, pe, cfg, ol, log)
+	}
+
 	if pe.PullRequest.Merged {
 		return nil
 	}
 
+	if pe.Action == github.PullRequestActionOpened || pe.Action == github.PullRequestActionEdited ||
+		pe.Action == github.PullRequestActionReopened {
+		if err := handlePullRequestBodyChanged(gc, gitClient, pe, cfg, ol, log); err != nil {
+			return err
+		}
+	}
+
+	// The PR may be merged automatically once it is ready for review.
+	if pe.Action == github.PullRequestActionOpened || pe.Action == github.PullRequestActionReopened ||
+		pe.Action == github.PullRequestActionReadyForReview {
+		return tryAutoMerge(gc, gitClient, cfg, ol, &pe.PullRequest, log)
+	}
+
+	if pe.Action == github.PullRequestActionLabeled || pe.Action == github.PullRequestActionUnlabeled {
+		return handlePullRequestLabelChanged(gc, gitClient, pe, cfg, ol, log)
+	}
+
 	if pe.Action != github.PullRequestActionSynchronize {
 		return nil
 	}
 
+	if err := handlePullRequestSynchronize(gc, gitClient, pe, cfg, log); err != nil {
+		return err
+	}
+
+	// The review status needs to be published on the new head commit.
+	reportReviewStatus(gc, cfg, ol, pe.PullRequest.Base.Repo.Owner.Login, pe.PullRequest.Base.Repo.Name,
+		pe.PullRequest.Number, log)
+	return nil
+}
+
+// handlePullRequestSynchronize removes the can merge label when new commits are pushed.
+func handlePullRequestSynchronize(gc githubClient, gitClient git.ClientFactory, pe *github.PullRequestEvent,
+	cfg *externalplugins.Configuration, log *logrus.Entry) error {
 	org := pe.PullRequest.Base.Repo.Owner.Login
 	repo := pe.PullRequest.Base.Repo.Name
 	number := pe.PullRequest.Number
 
 	opts := cfg.MergeFor(org, repo)
+	canMergeLabel := cfg.LabelSchemeFor(org, repo).CanMergeLabel
 
 	// If we don't have the 'status/can-merge' label, we don't need to check anything.
 	labels, err := gc.GetIssueLabels(org, repo, number)
@@ -186,7 +318,7 @@ func HandlePullRequestEvent(gc githubClient, pe *github.PullRequestEvent,
 	}
 	hasCanMerge := false
 	for _, label := range labels {
-		if label.Name == externalplugins.CanMergeLabel {
+		if label.Name == canMergeLabel {
 			hasCanMerge = true
 		}
 	}
@@ -196,7 +328,7 @@ func HandlePullRequestEvent(gc githubClient, pe *github.PullRequestEvent,
 
 	if opts.StoreTreeHash {
 		// Check if we have a tree-hash comment.
-		var lastCanMergeTreeHash string
+		var approved *externalplugins.ApprovedDiff
 		botUserChecker, err := gc.BotUserChecker()
 		if err != nil {
 			return err
@@ -209,38 +341,45 @@ func HandlePullRequestEvent(gc githubClient, pe *github.PullRequestEvent,
 		// iterate backwards to find the last 'can-merge' tree-hash.
 		for i := len(comments) - 1; i >= 0; i-- {
 			comment := comments[i]
-			m := addCanMergeLabelNotificationRe.FindStringSubmatch(comment.Body)
-			if botUserChecker(comment.User.Login) && m != nil && comment.UpdatedAt.Equal(comment.CreatedAt) {
-				lastCanMergeTreeHash = m[1]
+			// The notification is edited by the bot itself unless it is recreated every time.
+			unedited := !opts.RecreateNotification || comment.UpdatedAt.Equal(comment.CreatedAt)
+			if botUserChecker(comment.User.Login) && isCanMergeNotification(comment.Body) && unedited {
+				// The notifications of the old format do not contain the tree hash.
+				approved = parseCanMergeNotification(comment.Body)
 				break
 			}
 		}
-		if lastCanMergeTreeHash != "" {
-			prCommits, err := gc.ListPRCommits(org, repo, number)
+		if approved != nil {
+			same, err := externalplugins.IsDiffApproved(gitClient, org, repo, &pe.PullRequest, approved)
 			if err != nil {
-				log.WithField("sha", pe.PullRequest.Head.SHA).WithError(err).Error("Failed to get PR's commits.")
+				log.WithError(err).Error("Failed to compare the diff with the approved diff.")
 			}
-
 			// Don't remove the label, PR code hasn't changed.
-			if isAllGuaranteed(prCommits, lastCanMergeTreeHash, log) {
+			if same {
+				log.Infof("Keep the '%s' label because the diff is not changed.", canMergeLabel)
 				return nil
 			}
 		}
 	}
 
-	if err := gc.RemoveLabel(org, repo, number, externalplugins.CanMergeLabel); err != nil {
+	if err := gc.RemoveLabel(org, repo, number, canMergeLabel); err != nil {
 		return fmt.Errorf("failed to remove 'can-merge' label: %v", err)
 	}
 
 	// Create a comment to inform participants that 'can-merge' label is removed due to new
 	// pull request changes.
+	noti, err := getRemoveCanMergeLabelNoti(cfg, org, repo)
+	if err != nil {
+		return err
+	}
 	log.Infof("Commenting a 'can-merge' removal notification to %s/%s#%d and with the message: %s",
-		org, repo, number, removeCanMergeLabelNoti)
-	return gc.CreateComment(org, repo, number, removeCanMergeLabelNoti)
+		org, repo, number, noti)
+	return updateMergeNotification(gc, cfg, org, repo, number, noti, log)
 }
 
 func handle(wantMerge bool, config *externalplugins.Configuration, rc reviewCtx,
-	gc githubClient, ol ownersclient.OwnersLoader, cp commentPruner, log *logrus.Entry) error {
+	gc githubClient, gitClient git.ClientFactory, ol ownersclient.OwnersLoader, cp commentPruner,
+	log *logrus.Entry) error {
 	author := rc.author
 	issueAuthor := rc.issueAuthor
 	number := rc.number
@@ -253,6 +392,9 @@ func handle(wantMerge bool, config *externalplugins.Configuration, rc reviewCtx,
 	// Get ti-community-merge config.
 	opts := config.MergeFor(rc.repo.Owner.Login, rc.repo.Name)
 	tichiURL := fmt.Sprintf(ownersclient.OwnersURLFmt, config.TichiWebURL, org, repoName, number)
+	ownersLinkData := map[string]interface{}{
+		"ownersLink": tichiURL,
+	}
 	owners, err := ol.LoadOwners(opts.PullOwnersEndpoint, org, repoName, number)
 	if err != nil {
 		return err
@@ -265,16 +407,47 @@ func handle(wantMerge bool, config *externalplugins.Configuration, rc reviewCtx,
 
 	// Not committers but want merge.
 	if !committers.Has(author) && wantMerge {
-		resp := "`/merge` is only allowed for the committers in [list](" + tichiURL + ")."
+		resp, err := config.RenderMessage(org, repoName, externalplugins.MessageMergeOnlyCommitters, ownersLinkData)
+		if err != nil {
+			return err
+		}
 		log.Infof("Reply /merge request in comment: \"%s\"", resp)
-		return gc.CreateComment(org, repoName, number, externalplugins.FormatResponseRaw(body, htmlURL, author, resp))
+		return gc.CreateComment(org, repoName, number,
+			config.FormatResponseRaw(org, repoName, body, htmlURL, author, resp))
 	}
 
 	// Not author or committers but want remove merge.
 	if !committers.Has(author) && !isAuthor && !wantMerge {
-		resp := "`/merge cancel` is only allowed for the PR author and the committers in [list](" + tichiURL + ")."
+		resp, err := config.RenderMessage(org, repoName, externalplugins.MessageMergeCancelOnlyCommitters,
+			ownersLinkData)
+		if err != nil {
+			return err
+		}
 		log.Infof("Reply /merge cancel request with comment: \"%s\"", resp)
-		return gc.CreateComment(org, repoName, number, externalplugins.FormatResponseRaw(body, htmlURL, author, resp))
+		return gc.CreateComment(org, repoName, number,
+			config.FormatResponseRaw(org, repoName, body, htmlURL, author, resp))
+	}
+
+	// The merge method must be allowed by the repo.
+	if wantMerge && rc.mergeMethod != "" && !sets.NewString(opts.MergeMethods...).Has(rc.mergeMethod) {
+		resp, err := config.RenderMessage(org, repoName, externalplugins.MessageMergeMethodNotAllowed,
+			map[string]interface{}{
+				"method":  rc.mergeMethod,
+				"methods": opts.MergeMethods,
+			})
+		if err != nil {
+			return err
+		}
+		log.Infof("Reply /merge %s request with comment: \"%s\"", rc.mergeMethod, resp)
+		return gc.CreateComment(org, repoName, number,
+			config.FormatResponseRaw(org, repoName, body, htmlURL, author, resp))
+	}
+
+	// The canceled merge should not be started again by the auto-merge.
+	if !wantMerge {
+		if err := disableAutoMerge(gc, config, org, repoName, number, log); err != nil {
+			return err
+		}
 	}
 
 	// Now we update the 'status/cam-merge' labels, having checked all cases where changing.
@@ -283,94 +456,359 @@ func handle(wantMerge bool, config *externalplugins.Configuration, rc reviewCtx,
 	if err != nil {
 		log.WithError(err).Error("Failed to get issue labels.")
 	}
+	labelScheme := config.LabelSchemeFor(org, repoName)
+	canMergeLabel := labelScheme.CanMergeLabel
 	hasCanMerge := false
 	for _, label := range labels {
-		if label.Name == externalplugins.CanMergeLabel {
+		if label.Name == canMergeLabel {
 			hasCanMerge = true
 		}
 	}
 
-	isSatisfy := isLGTMSatisfy(externalplugins.LgtmLabelPrefix, labels, owners.NeedsLgtm)
+	// Find out the approvers only when the approval rules depend on who approves
+	// or the LGTM label does not record the number of LGTMs.
+	lgtmOpts := config.LgtmFor(org, repoName)
+	var approvers []string
+	if lgtmOpts.NeedsApprovers() || !labelScheme.IsLgtmLabelNumbered() {
+		approvers, err = getApprovers(gc, org, repoName, number)
+		if err != nil {
+			log.WithError(err).Error("Failed to get approvers.")
+		}
+	}
+	isSatisfy := isLGTMSatisfy(lgtmOpts, labelScheme, owners, labels, approvers)
+
+	// Forget the merge method chosen by the committer when the merge is canceled.
+	if !wantMerge {
+		if err := updateMergeMethodLabels(gc, org, repoName, number, labels, "", log); err != nil {
+			return err
+		}
+	}
 
 	// Remove the label if necessary, we're done after this.
 	if hasCanMerge && !wantMerge {
-		log.Info("Removing '" + externalplugins.CanMergeLabel + "' label.")
-		if err := gc.RemoveLabel(org, repoName, number, externalplugins.CanMergeLabel); err != nil {
+		log.Info("Removing '" + canMergeLabel + "' label.")
+		if err := gc.RemoveLabel(org, repoName, number, canMergeLabel); err != nil {
 			return err
 		}
 		if opts.StoreTreeHash {
 			cp.PruneComments(func(comment github.IssueComment) bool {
-				return addCanMergeLabelNotificationRe.MatchString(comment.Body)
+				return isCanMergeNotification(comment.Body)
 			})
 		}
+	} else if !wantMerge {
+		// The merge waiting for the required checks or the dependencies is canceled.
+		cp.PruneComments(func(comment github.IssueComment) bool {
+			return waitingForChecksRe.MatchString(comment.Body) || waitingForDependenciesRe.MatchString(comment.Body)
+		})
 	} else if !hasCanMerge && wantMerge {
 		if isSatisfy {
-			// Store the tree hash.
-			if opts.StoreTreeHash {
-				pr, err := gc.GetPullRequest(org, repoName, number)
+			var pr *github.PullRequest
+			if len(opts.Freezes) != 0 || len(opts.RequireContexts) != 0 || opts.TrackDependencies {
+				pr, err = gc.GetPullRequest(org, repoName, number)
+				if err != nil {
+					return err
+				}
+			}
+			if len(opts.Freezes) != 0 {
+				freeze, err := getBlockingFreeze(gc, opts, org, pr.Base.Ref, labels, author, time.Now(), log)
 				if err != nil {
-					log.WithError(err).Error("Failed to get pull request.")
+					return err
+				}
+				if freeze != nil {
+					resp, err := config.RenderMessage(org, repoName, externalplugins.MessageMergeFrozen,
+						map[string]interface{}{
+							"branch":        pr.Base.Ref,
+							"name":          freeze.Name,
+							"trackingIssue": freeze.TrackingIssue,
+							"exemptLabels":  freeze.ExemptLabels,
+						})
+					if err != nil {
+						return err
+					}
+					log.Infof("Reply /merge request with comment: \"%s\"", resp)
+					return gc.CreateComment(org, repoName, number,
+						config.FormatResponseRaw(org, repoName, body, htmlURL, author, resp))
 				}
-				prCommits, err := gc.ListPRCommits(org, repoName, number)
+			}
+			if opts.TrackDependencies {
+				unmerged, err := syncDependencies(gc, config, pr, log)
 				if err != nil {
-					log.WithField("sha", pr.Head.SHA).WithError(err).Error("Failed to get commit.")
+					return err
 				}
-				// Store the last commit hash.
-				treeHash := prCommits[len(prCommits)-1].SHA
-				log.WithField("tree", treeHash).Info("Adding comment to store tree-hash.")
-				if err := gc.CreateComment(org, repoName, number, fmt.Sprintf(addCanMergeLabelNotification, treeHash)); err != nil {
-					log.WithError(err).Error("Failed to add comment.")
+				if len(unmerged) != 0 {
+					resp, err := getDependenciesNotMergedResponse(config, org, repoName, pr.Head.SHA, unmerged, false)
+					if err != nil {
+						return err
+					}
+					log.Infof("Reply /merge request with comment: \"%s\"", resp)
+					return gc.CreateComment(org, repoName, number,
+						config.FormatResponseRaw(org, repoName, body, htmlURL, author, resp))
 				}
 			}
-			log.Info("Adding '" + externalplugins.CanMergeLabel + "' label.")
-			if err := gc.AddLabel(org, repoName, number, externalplugins.CanMergeLabel); err != nil {
+			if len(opts.RequireContexts) != 0 {
+				result, err := externalplugins.GetRequiredChecksResult(gc, org, repoName, pr.Head.SHA,
+					opts.RequireContexts)
+				if err != nil {
+					return err
+				}
+				if !result.Passed() {
+					resp, err := getChecksNotPassedResponse(config, org, repoName, pr.Head.SHA, result)
+					if err != nil {
+						return err
+					}
+					log.Infof("Reply /merge request with comment: \"%s\"", resp)
+					return gc.CreateComment(org, repoName, number,
+						config.FormatResponseRaw(org, repoName, body, htmlURL, author, resp))
+				}
+			}
+			// Record the merge method chosen by the committer once the PR is accepted.
+			if rc.mergeMethod != "" {
+				if err := updateMergeMethodLabels(gc, org, repoName, number, labels,
+					github.PullRequestMergeType(rc.mergeMethod), log); err != nil {
+					return err
+				}
+			}
+			if err := addCanMergeLabel(gc, gitClient, config, org, repoName, number, cp, log); err != nil {
 				return err
 			}
-			// Delete the 'status/can-merge' removed noti after the 'status/can-merge' label is added.
-			cp.PruneComments(func(comment github.IssueComment) bool {
-				return strings.Contains(comment.Body, removeCanMergeLabelNoti)
-			})
 		} else {
-			resp := fmt.Sprintf("`/merge` in this pull request requires %d `/lgtm`.", owners.NeedsLgtm)
+			resp, err := config.RenderMessage(org, repoName, externalplugins.MessageMergeNeedsLgtm,
+				map[string]interface{}{
+					"currentLgtm":           lgtmOpts.LgtmCountFromLabels(labelScheme, owners, labels, approvers),
+					"needsLgtm":             owners.NeedsLgtm,
+					"committerLgtm":         externalplugins.CountCommitterLgtm(owners, approvers),
+					"requiredCommitterLgtm": lgtmOpts.RequiredCommitterLgtm,
+					"missingAffiliations":   lgtmOpts.MissingAffiliations(owners, approvers),
+				})
+			if err != nil {
+				return err
+			}
 			log.Infof("Reply /merge request with comment: \"%s\"", resp)
-			return gc.CreateComment(org, repoName, number, externalplugins.FormatResponseRaw(body, htmlURL, author, resp))
+			return gc.CreateComment(org, repoName, number,
+				config.FormatResponseRaw(org, repoName, body, htmlURL, author, resp))
 		}
 	}
 
+	reportReviewStatus(gc, config, ol, org, repoName, number, log)
 	return nil
 }
 
-// isLGTMSatisfy returns pull request current label number.
-func isLGTMSatisfy(prefix string, labels []github.Label, needsLgtm int) bool {
-	currentLgtmNumber := 0
+// addCanMergeLabel adds the 'can-merge' label to the PR and stores the tree hash if necessary.
+func addCanMergeLabel(gc githubClient, gitClient git.ClientFactory, config *externalplugins.Configuration,
+	org, repo string, number int, cp commentPruner, log *logrus.Entry) error {
+	opts := config.MergeFor(org, repo)
+	canMergeLabel := config.LabelSchemeFor(org, repo).CanMergeLabel
+
+	// Store the tree hash.
+	if opts.StoreTreeHash {
+		pr, err := gc.GetPullRequest(org, repo, number)
+		if err != nil {
+			return err
+		}
+		// Store the tree hash and the base commit of the approved diff.
+		approved, err := externalplugins.GetApprovedDiff(gitClient, org, repo, pr)
+		if err != nil {
+			log.WithError(err).Error("Failed to get the tree hash.")
+		} else {
+			log.WithField("tree", approved.TreeHash).Info("Adding comment to store tree-hash.")
+			noti, err := getCanMergeNotification(config, org, repo, approved)
+			if err == nil {
+				err = updateMergeNotification(gc, config, org, repo, number, noti, log)
+			}
+			if err != nil {
+				log.WithError(err).Error("Failed to add comment.")
+			}
+		}
+	}
+	log.Info("Adding '" + canMergeLabel + "' label.")
+	if err := gc.AddLabel(org, repo, number, canMergeLabel); err != nil {
+		return err
+	}
+	// Delete the 'status/can-merge' removed notis and the responses waiting for the required checks
+	// or the dependencies after the 'status/can-merge' label is added.
+	cp.PruneComments(func(comment github.IssueComment) bool {
+		return isCanceledByPushNotification(comment.Body) || isApprovalsChangedNotification(comment.Body) ||
+			waitingForChecksRe.MatchString(comment.Body) || waitingForDependenciesRe.MatchString(comment.Body)
+	})
+	return nil
+}
+
+// updateMergeMethodLabels makes the PR only have the label of the merge method,
+// all the merge method labels are removed if the method is empty.
+func updateMergeMethodLabels(gc githubClient, org, repo string, number int, labels []github.Label,
+	method github.PullRequestMergeType, log *logrus.Entry) error {
+	existing := sets.NewString()
 	for _, label := range labels {
-		if strings.Contains(label.Name, prefix) {
-			currentLgtmNumber, _ = strconv.Atoi(strings.Trim(label.Name, prefix))
+		existing.Insert(label.Name)
+	}
+	for _, m := range externalplugins.MergeMethods {
+		label := externalplugins.MergeMethodLabel(m)
+		if m == method && !existing.Has(label) {
+			log.Info("Adding '" + label + "' label.")
+			if err := gc.AddLabel(org, repo, number, label); err != nil {
+				return err
+			}
+		} else if m != method && existing.Has(label) {
+			log.Info("Removing '" + label + "' label.")
+			if err := gc.RemoveLabel(org, repo, number, label); err != nil {
+				return err
+			}
 		}
 	}
+	return nil
+}
 
-	return needsLgtm == currentLgtmNumber
+// getCanMergeNotification returns the notification of the repo when the 'can-merge' label is added,
+// the approved diff is stored in the identifier so that it is kept however the message is customized.
+func getCanMergeNotification(config *externalplugins.Configuration, org, repo string,
+	approved *externalplugins.ApprovedDiff) (string, error) {
+	resp, err := config.RenderMessage(org, repo, externalplugins.MessageMergeAccepted, map[string]interface{}{
+		"treeHash": approved.TreeHash,
+		"baseSHA":  approved.BaseSHA,
+		"headSHA":  approved.HeadSHA,
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s\n\n<!--%s: %s %s %s-->", resp, canMergeIdentifier,
+		approved.TreeHash, approved.BaseSHA, approved.HeadSHA), nil
 }
 
-func isAllGuaranteed(prCommits []github.RepositoryCommit, lastCanMergeTreeHash string, log *logrus.Entry) bool {
-	guaranteed := true
+// isCanMergeNotification returns true if the comment is the notification which stores the tree hash,
+// including the notifications of the old format.
+func isCanMergeNotification(body string) bool {
+	return canMergeNotificationRe.MatchString(body) || strings.HasPrefix(body, legacyCanMergeNotificationPrefix)
+}
 
-	lastGuaranteedIndex := 0
-	for i, commit := range prCommits {
-		if commit.SHA == lastCanMergeTreeHash {
-			lastGuaranteedIndex = i
-		}
+// parseCanMergeNotification returns the approved diff stored in the notification,
+// it returns nil if the notification of the old format does not contain the tree hash.
+func parseCanMergeNotification(body string) *externalplugins.ApprovedDiff {
+	m := canMergeNotificationRe.FindStringSubmatch(body)
+	if m == nil {
+		m = legacyCanMergeNotificationRe.FindStringSubmatch(body)
+	}
+	if m == nil {
+		return nil
 	}
+	return &externalplugins.ApprovedDiff{TreeHash: m[1], BaseSHA: m[2], HeadSHA: m[3]}
+}
 
-	unCheckCommits := prCommits[lastGuaranteedIndex+1:]
+// getRemoveCanMergeLabelNoti returns the notification of the repo when the 'can-merge' label is removed
+// due to new commits.
+func getRemoveCanMergeLabelNoti(config *externalplugins.Configuration, org, repo string) (string, error) {
+	resp, err := config.RenderMessage(org, repo, externalplugins.MessageMergeCanceledByPush, nil)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s\n\n<!--%s-->", resp, canceledByPushIdentifier), nil
+}
 
-	for _, commit := range unCheckCommits {
-		if commit.Committer.Login != githubUpdateCommitter {
-			guaranteed = false
-		} else {
-			log.Infof("Remove 'can-merge' label; the tree-hash is: %s", commit.SHA)
-		}
+// isCanceledByPushNotification returns true if the comment tells the merge is canceled by new commits,
+// including the notifications of the old format.
+func isCanceledByPushNotification(body string) bool {
+	return strings.Contains(body, "<!--"+canceledByPushIdentifier+"-->") ||
+		strings.Contains(body, legacyCanceledByPushNotification)
+}
+
+// updateMergeNotification updates the notification which tells whether the PR is accepted or the merge is
+// canceled, the notification is edited in place unless the repo recreates the notification every time.
+func updateMergeNotification(gc githubClient, config *externalplugins.Configuration, org, repo string,
+	number int, body string, log *logrus.Entry) error {
+	if config.MergeFor(org, repo).RecreateNotification {
+		return gc.CreateComment(org, repo, number, body)
+	}
+
+	botUserChecker, err := gc.BotUserChecker()
+	if err != nil {
+		return err
+	}
+	comments, err := gc.ListIssueComments(org, repo, number)
+	if err != nil {
+		return err
+	}
+	notifications := externalplugins.FilterStickyComments(comments, botUserChecker, func(body string) bool {
+		return isCanMergeNotification(body) || isCanceledByPushNotification(body) ||
+			isApprovalsChangedNotification(body)
+	})
+	return externalplugins.UpdateStickyComment(gc, org, repo, number, notifications, body, false, log)
+}
+
+// reportReviewStatus publishes the review status on the head commit of the PR if the repo enables it.
+// The failure is only logged because the status does not affect the merge process.
+func reportReviewStatus(gc githubClient, config *externalplugins.Configuration, ol ownersclient.OwnersLoader,
+	org, repo string, number int, log *logrus.Entry) {
+	opts := config.MergeFor(org, repo)
+	if !opts.ReviewStatus {
+		return
+	}
+
+	owners, err := ol.LoadOwners(opts.PullOwnersEndpoint, org, repo, number)
+	if err != nil {
+		log.WithError(err).Error("Failed to get owners info.")
+		return
+	}
+	approvers, err := getApprovers(gc, org, repo, number)
+	if err != nil {
+		log.WithError(err).Error("Failed to get approvers.")
+		return
+	}
+
+	log.Info("Reporting the review status.")
+	if err := config.ReportReviewStatus(gc, org, repo, number, owners, approvers); err != nil {
+		log.WithError(err).Error("Failed to report the review status.")
+	}
+}
+
+// getApprovers returns the approvers listed in the review notification of the lgtm plugin.
+func getApprovers(gc githubClient, org, repo string, number int) ([]string, error) {
+	botUserChecker, err := gc.BotUserChecker()
+	if err != nil {
+		return nil, err
+	}
+	comments, err := gc.ListIssueComments(org, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	approvers, _ := externalplugins.GetApproversFromComments(comments, botUserChecker)
+	return approvers.List(), nil
+}
+
+// isLGTMSatisfy returns true if the weighted number of approvals recorded by the LGTM label
+// and the approvers satisfy the approval rules. If the LGTM label does not record the number,
+// the approvals are counted from the approvers.
+func isLGTMSatisfy(lgtmOpts *externalplugins.TiCommunityLgtm, labelScheme *externalplugins.LabelScheme,
+	owners *ownersclient.Owners, labels []github.Label, approvers []string) bool {
+	currentLgtmNumber := lgtmOpts.LgtmCountFromLabels(labelScheme, owners, labels, approvers)
+	return lgtmOpts.IsLgtmSatisfied(owners, currentLgtmNumber, approvers)
+}
+
+// checkAuthorOrCommitter returns true if the commenter is the PR author or a committer,
+// otherwise it responds to the commenter with the message.
+func checkAuthorOrCommitter(gc githubClient, ice *github.IssueCommentEvent, cfg *externalplugins.Configuration,
+	ol ownersclient.OwnersLoader, message string, log *logrus.Entry) (bool, error) {
+	org := ice.Repo.Owner.Login
+	repo := ice.Repo.Name
+	number := ice.Issue.Number
+	author := ice.Comment.User.Login
+	if author == ice.Issue.User.Login {
+		return true, nil
+	}
+
+	owners, err := ol.LoadOwners(cfg.MergeFor(org, repo).PullOwnersEndpoint, org, repo, number)
+	if err != nil {
+		return false, err
+	}
+	if sets.NewString(owners.Committers...).Has(author) {
+		return true, nil
 	}
 
-	return guaranteed
+	resp, err := cfg.RenderMessage(org, repo, message, map[string]interface{}{
+		"ownersLink": fmt.Sprintf(ownersclient.OwnersURLFmt, cfg.TichiWebURL, org, repo, number),
+	})
+	if err != nil {
+		return false, err
+	}
+	log.Infof("Reply %s with comment: \"%s\"", ice.Comment.Body, resp)
+	return false, gc.CreateComment(org, repo, number,
+		cfg.FormatResponseRaw(org, repo, ice.Comment.Body, ice.Comment.HTMLURL, author, resp))
 }
@@ -1,10 +1,11 @@
 package merge
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"testing"
-	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
@@ -25,6 +26,19 @@ type fakeOwnersClient struct {
 	needsLgtm  int
 }
 
+// canceledByPushNoti is the built-in notification when the merge is canceled by new commits.
+var canceledByPushNoti = "Merge canceled because a new commit is pushed.\n\n<!--" + canceledByPushIdentifier + "-->"
+
+// canMergeNoti returns the built-in notification which stores the approved diff.
+func canMergeNoti(tree, base, head string) string {
+	noti, err := getCanMergeNotification(&externalplugins.Configuration{}, "org", "repo",
+		&externalplugins.ApprovedDiff{TreeHash: tree, BaseSHA: base, HeadSHA: head})
+	if err != nil {
+		panic(err)
+	}
+	return noti
+}
+
 func (f *fakeOwnersClient) LoadOwners(_ string,
 	_, _ string, _ int) (*ownersclient.Owners, error) {
 	return &ownersclient.Owners{
@@ -46,7 +60,61 @@ func (fp *fakePruner) PruneComments(shouldPrune func(github.IssueComment) bool)
 	}
 }
 
+// fakeGitHubClient records the edited comments because the fake client does not edit comments,
+// and provides the check runs and the search results which the fake client does not support.
+type fakeGitHubClient struct {
+	*fakegithub.FakeClient
+	IssueCommentsEdited []string
+	CheckRuns           map[string]*github.CheckRunList
+	SearchResults       []externalplugins.PullRequest
+	IssueQueries        []string
+}
+
+// FindIssues records the search queries.
+func (f *fakeGitHubClient) FindIssues(query, sort string, asc bool) ([]github.Issue, error) {
+	f.IssueQueries = append(f.IssueQueries, query)
+	return f.FakeClient.FindIssues(query, sort, asc)
+}
+
+// Query returns the search results as the only page.
+func (f *fakeGitHubClient) Query(_ context.Context, q interface{}, _ map[string]interface{}) error {
+	var nodes []map[string]interface{}
+	for _, pr := range f.SearchResults {
+		nodes = append(nodes, map[string]interface{}{"PullRequest": pr})
+	}
+	result, err := json.Marshal(map[string]interface{}{
+		"Search": map[string]interface{}{"Nodes": nodes},
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(result, q)
+}
+
+func (f *fakeGitHubClient) ListCheckRuns(org, repo, ref string) (*github.CheckRunList, error) {
+	if runs, ok := f.CheckRuns[ref]; ok {
+		return runs, nil
+	}
+	return &github.CheckRunList{}, nil
+}
+
+func (f *fakeGitHubClient) EditComment(org, repo string, id int, comment string) error {
+	for number, comments := range f.IssueComments {
+		for i := range comments {
+			if comments[i].ID == id {
+				comments[i].Body = comment
+				f.IssueCommentsEdited = append(f.IssueCommentsEdited,
+					fmt.Sprintf("%s/%s#%d:%s", org, repo, number, comment))
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("could not find issue comment %d", id)
+}
+
 func TestMergeIssueAndReviewComment(t *testing.T) {
+	SHA, gitClient := newPullRequestRepo(t, 5)
+	log := logrus.WithField("plugin", PluginName)
 	var testcases = []struct {
 		name             string
 		body             string
@@ -197,13 +265,12 @@ func TestMergeIssueAndReviewComment(t *testing.T) {
 			shouldComment:    true,
 		},
 	}
-	SHA := "0bd3ed50c88cd53a09316bf7a298f900e9371652"
 	prName := "org/repo#5"
 	for _, tc := range testcases {
 		t.Logf("Running scenario %q", tc.name)
 		// Test issue comments.
 		{
-			fc := &fakegithub.FakeClient{
+			fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
 				IssueComments: make(map[int][]github.IssueComment),
 				PullRequests: map[int]*github.PullRequest{
 					5: {
@@ -231,7 +298,7 @@ func TestMergeIssueAndReviewComment(t *testing.T) {
 						},
 					},
 				},
-			}
+			}}
 			e := &github.IssueCommentEvent{
 				Action: github.IssueCommentActionCreated,
 				Issue: github.Issue{
@@ -270,11 +337,11 @@ func TestMergeIssueAndReviewComment(t *testing.T) {
 			}
 
 			cp := &fakePruner{
-				GitHubClient:  fc,
+				GitHubClient:  fc.FakeClient,
 				IssueComments: fc.IssueComments[5],
 			}
 
-			if err := HandleIssueCommentEvent(fc, e, cfg, foc, cp, logrus.WithField("plugin", PluginName)); err != nil {
+			if err := HandleIssueCommentEvent(fc, gitClient, e, cfg, foc, cp, log); err != nil {
 				t.Errorf("didn't expect error from lgtmComment: %v", err)
 				continue
 			}
@@ -306,7 +373,7 @@ func TestMergeIssueAndReviewComment(t *testing.T) {
 
 		// Test review comments.
 		{
-			fc := &fakegithub.FakeClient{
+			fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
 				IssueComments: make(map[int][]github.IssueComment),
 				PullRequests: map[int]*github.PullRequest{
 					5: {
@@ -334,7 +401,7 @@ func TestMergeIssueAndReviewComment(t *testing.T) {
 						},
 					},
 				},
-			}
+			}}
 			e := &github.ReviewCommentEvent{
 				Action: github.ReviewCommentActionCreated,
 				Comment: github.ReviewComment{
@@ -367,11 +434,11 @@ func TestMergeIssueAndReviewComment(t *testing.T) {
 			}
 
 			cp := &fakePruner{
-				GitHubClient:  fc,
+				GitHubClient:  fc.FakeClient,
 				IssueComments: fc.IssueComments[5],
 			}
 
-			if err := HandlePullReviewCommentEvent(fc, e, cfg, foc, cp, logrus.WithField("plugin", PluginName)); err != nil {
+			if err := HandlePullReviewCommentEvent(fc, gitClient, e, cfg, foc, cp, log); err != nil {
 				t.Errorf("didn't expect error from lgtmComment: %v", err)
 				continue
 			}
@@ -404,6 +471,8 @@ func TestMergeIssueAndReviewComment(t *testing.T) {
 }
 
 func TestMergeReviewCommentWithMergeNoti(t *testing.T) {
+	SHA, gitClient := newPullRequestRepo(t, 5)
+	log := logrus.WithField("plugin", PluginName)
 	var testcases = []struct {
 		name         string
 		body         string
@@ -459,10 +528,9 @@ func TestMergeReviewCommentWithMergeNoti(t *testing.T) {
 			shouldDelete: false,
 		},
 	}
-	SHA := "0bd3ed50c88cd53a09316bf7a298f900e9371652"
 	prName := "org/repo#5"
 	for _, tc := range testcases {
-		fc := &fakegithub.FakeClient{
+		fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
 			IssueComments: make(map[int][]github.IssueComment),
 			PullRequests: map[int]*github.PullRequest{
 				5: {
@@ -485,7 +553,7 @@ func TestMergeReviewCommentWithMergeNoti(t *testing.T) {
 					},
 				},
 			},
-		}
+		}}
 		e := &github.ReviewCommentEvent{
 			Action: github.ReviewCommentActionCreated,
 			Comment: github.ReviewComment{
@@ -504,7 +572,7 @@ func TestMergeReviewCommentWithMergeNoti(t *testing.T) {
 			User: github.User{
 				Login: botName.Login,
 			},
-			Body: removeCanMergeLabelNoti,
+			Body: canceledByPushNoti,
 		}
 		fc.IssueComments[5] = append(fc.IssueComments[5], ic)
 		fc.IssueLabelsAdded = append(fc.IssueLabelsAdded, prName+":"+lgtmTwo)
@@ -524,18 +592,25 @@ func TestMergeReviewCommentWithMergeNoti(t *testing.T) {
 		}
 
 		cp := &fakePruner{
-			GitHubClient:  fc,
+			GitHubClient:  fc.FakeClient,
 			IssueComments: fc.IssueComments[5],
 		}
 
-		if err := HandlePullReviewCommentEvent(fc, e, cfg, foc, cp, logrus.WithField("plugin", PluginName)); err != nil {
+		if err := HandlePullReviewCommentEvent(fc, gitClient, e, cfg, foc, cp, log); err != nil {
 			t.Errorf("For case %s, didn't expect error from lgtmComment: %v", tc.name, err)
 			continue
 		}
 
 		deleted := false
 		for _, body := range fc.IssueCommentsDeleted {
-			if body == removeCanMergeLabelNoti {
+			if body == canceledByPushNoti {
+				deleted = true
+				break
+			}
+		}
+		// The notification is edited in place to store the tree hash.
+		for _, body := range fc.IssueCommentsEdited {
+			if canMergeNotificationRe.MatchString(body) {
 				deleted = true
 				break
 			}
@@ -567,8 +642,10 @@ func TestHandlePullRequest(t *testing.T) {
 		IssueLabelsAdded   []string
 		IssueLabelsRemoved []string
 		issueComments      map[int][]github.IssueComment
+		recreateNoti       bool
 
-		expectNoComments bool
+		expectNoComments     bool
+		expectEditedComments []string
 	}{
 		{
 			name: "pr_synchronize, no RemoveLabel error",
@@ -600,22 +677,16 @@ func TestHandlePullRequest(t *testing.T) {
 			issueComments: map[int][]github.IssueComment{
 				101: {
 					{
-						Body: removeCanMergeLabelNoti,
+						Body: canceledByPushNoti,
 						User: github.User{Login: fakegithub.Bot},
 					},
 				},
 			},
+			recreateNoti:     true,
 			expectNoComments: false,
 		},
 		{
-			name: "pr_assigned",
-			event: github.PullRequestEvent{
-				Action: "assigned",
-			},
-			expectNoComments: true,
-		},
-		{
-			name: "pr_synchronize, same tree-hash, keep label",
+			name: "pr_synchronize, edit the notification in place",
 			event: github.PullRequestEvent{
 				Action: github.PullRequestActionSynchronize,
 				PullRequest: github.PullRequest{
@@ -636,41 +707,8 @@ func TestHandlePullRequest(t *testing.T) {
 			prCommits: map[string][]github.RepositoryCommit{
 				prName: {
 					{
-						SHA: SHA,
+						SHA: "older_treeSHA",
 					},
-				},
-			},
-			issueComments: map[int][]github.IssueComment{
-				101: {
-					{
-						Body: fmt.Sprintf(addCanMergeLabelNotification, treeSHA),
-						User: github.User{Login: fakegithub.Bot},
-					},
-				},
-			},
-			expectNoComments: true,
-		},
-		{
-			name: "pr_synchronize, same tree-hash, keep label, edited comment",
-			event: github.PullRequestEvent{
-				Action: github.PullRequestActionSynchronize,
-				PullRequest: github.PullRequest{
-					Number: 101,
-					Base: github.PullRequestBranch{
-						Repo: github.Repo{
-							Owner: github.User{
-								Login: "kubernetes",
-							},
-							Name: "kubernetes",
-						},
-					},
-					Head: github.PullRequestBranch{
-						SHA: SHA,
-					},
-				},
-			},
-			prCommits: map[string][]github.RepositoryCommit{
-				prName: {
 					{
 						SHA: SHA,
 					},
@@ -680,52 +718,19 @@ func TestHandlePullRequest(t *testing.T) {
 			issueComments: map[int][]github.IssueComment{
 				101: {
 					{
-						Body:      fmt.Sprintf(addCanMergeLabelNotification, treeSHA),
-						User:      github.User{Login: fakegithub.Bot},
-						CreatedAt: time.Date(1981, 2, 21, 12, 30, 0, 0, time.UTC),
-						UpdatedAt: time.Date(1981, 2, 21, 12, 31, 0, 0, time.UTC),
+						ID:   1,
+						Body: canMergeNoti("abc", "def", "123"),
+						User: github.User{Login: fakegithub.Bot},
 					},
 				},
 			},
-			expectNoComments: false,
+			expectNoComments:     true,
+			expectEditedComments: []string{prName + ":" + canceledByPushNoti},
 		},
 		{
-			name: "pr_synchronize, 2 tree-hash comments, keep label",
+			name: "pr_assigned",
 			event: github.PullRequestEvent{
-				Action: github.PullRequestActionSynchronize,
-				PullRequest: github.PullRequest{
-					Number: 101,
-					Base: github.PullRequestBranch{
-						Repo: github.Repo{
-							Owner: github.User{
-								Login: "kubernetes",
-							},
-							Name: "kubernetes",
-						},
-					},
-					Head: github.PullRequestBranch{
-						SHA: SHA,
-					},
-				},
-			},
-			prCommits: map[string][]github.RepositoryCommit{
-				prName: {
-					{
-						SHA: SHA,
-					},
-				},
-			},
-			issueComments: map[int][]github.IssueComment{
-				101: {
-					{
-						Body: fmt.Sprintf(addCanMergeLabelNotification, "older_treeSHA"),
-						User: github.User{Login: fakegithub.Bot},
-					},
-					{
-						Body: fmt.Sprintf(addCanMergeLabelNotification, treeSHA),
-						User: github.User{Login: fakegithub.Bot},
-					},
-				},
+				Action: "assigned",
 			},
 			expectNoComments: true,
 		},
@@ -733,7 +738,7 @@ func TestHandlePullRequest(t *testing.T) {
 	for _, testcase := range testcases {
 		tc := testcase
 		t.Run(tc.name, func(t *testing.T) {
-			fakeGitHub := &fakegithub.FakeClient{
+			fakeGitHub := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
 				IssueComments: tc.issueComments,
 				PullRequests: map[int]*github.PullRequest{
 					101: {
@@ -749,7 +754,7 @@ func TestHandlePullRequest(t *testing.T) {
 				Collaborators:    []string{"collab"},
 				IssueLabelsAdded: tc.IssueLabelsAdded,
 				CommitMap:        tc.prCommits,
-			}
+			}}
 			fakeGitHub.IssueLabelsAdded = append(fakeGitHub.IssueLabelsAdded, prName+":"+externalplugins.CanMergeLabel)
 			commit := github.RepositoryCommit{}
 			commit.Commit.Tree.SHA = treeSHA
@@ -758,16 +763,19 @@ func TestHandlePullRequest(t *testing.T) {
 			cfg := &externalplugins.Configuration{}
 			cfg.TiCommunityMerge = []externalplugins.TiCommunityMerge{
 				{
-					Repos:              []string{"kubernetes/kubernetes"},
-					StoreTreeHash:      true,
-					PullOwnersEndpoint: "https://fake/ti-community-bot",
+					Repos:                []string{"kubernetes/kubernetes"},
+					StoreTreeHash:        true,
+					PullOwnersEndpoint:   "https://fake/ti-community-bot",
+					RecreateNotification: tc.recreateNoti,
 				},
 			}
 
 			err := HandlePullRequestEvent(
 				fakeGitHub,
+				newGitClient(t),
 				&tc.event,
 				cfg,
+				&fakeOwnersClient{},
 				logrus.WithField("plugin", PluginName),
 			)
 
@@ -797,97 +805,13 @@ func TestHandlePullRequest(t *testing.T) {
 			if !tc.expectNoComments && len(fakeGitHub.IssueCommentsAdded) == 0 {
 				t.Fatalf("expected comments but got none")
 			}
+			if got, want := fakeGitHub.IssueCommentsEdited, tc.expectEditedComments; !equality.Semantic.DeepEqual(got, want) {
+				t.Fatalf("edited comments mismatch: got %v, want %v", got, want)
+			}
 		})
 	}
 }
 
-func TestAddTreeHashComment(t *testing.T) {
-	c := struct {
-		name          string
-		author        string
-		trustedTeam   string
-		expectTreeSha bool
-	}{
-		name:          "Tree SHA added",
-		author:        "Bob",
-		expectTreeSha: true,
-	}
-
-	SHA := "0bd3ed50c88cd53a09316bf7a298f900e9371652"
-	treeSHA := "6dcb09b5b57875f334f61aebed695e2e4193db5e"
-	prName := "kubernetes/kubernetes#101"
-	cfg := &externalplugins.Configuration{}
-	cfg.TiCommunityMerge = []externalplugins.TiCommunityMerge{
-		{
-			Repos:              []string{"kubernetes/kubernetes"},
-			StoreTreeHash:      true,
-			PullOwnersEndpoint: "https://fake/ti-community-bot",
-		},
-	}
-	rc := reviewCtx{
-		author:      "collab1",
-		issueAuthor: c.author,
-		repo: github.Repo{
-			Owner: github.User{
-				Login: "kubernetes",
-			},
-			Name: "kubernetes",
-		},
-		number: 101,
-		body:   "/merge",
-	}
-	fc := &fakegithub.FakeClient{
-		Commits:       make(map[string]github.RepositoryCommit),
-		IssueComments: map[int][]github.IssueComment{},
-		PullRequests: map[int]*github.PullRequest{
-			101: {
-				Base: github.PullRequestBranch{
-					Ref: "master",
-				},
-				Head: github.PullRequestBranch{
-					SHA: SHA,
-				},
-			},
-		},
-		Collaborators: []string{"collab1", "collab2"},
-		CommitMap: map[string][]github.RepositoryCommit{
-			prName: {
-				{
-					SHA: SHA,
-				},
-			},
-		},
-	}
-	fc.IssueLabelsAdded = []string{prName + ":" + lgtmTwo}
-
-	commit := github.RepositoryCommit{}
-	commit.Commit.Tree.SHA = treeSHA
-	fc.Commits[SHA] = commit
-
-	foc := &fakeOwnersClient{
-		committers: []string{"collab1"},
-		needsLgtm:  2,
-	}
-
-	_ = handle(true, cfg, rc, fc, foc, &fakePruner{}, logrus.WithField("plugin", PluginName))
-	found := false
-	for _, body := range fc.IssueCommentsAdded {
-		if addCanMergeLabelNotificationRe.MatchString(body) {
-			found = true
-			break
-		}
-	}
-	if c.expectTreeSha {
-		if !found {
-			t.Fatalf("expected tree_hash comment but got none")
-		}
-	} else {
-		if found {
-			t.Fatalf("expected no tree_hash comment but got one")
-		}
-	}
-}
-
 func TestRemoveTreeHashComment(t *testing.T) {
 	treeSHA := "6dcb09b5b57875f334f61aebed695e2e4193db5e"
 	cfg := &externalplugins.Configuration{}
@@ -910,20 +834,20 @@ func TestRemoveTreeHashComment(t *testing.T) {
 		number: 101,
 		body:   "/merge cancel",
 	}
-	fc := &fakegithub.FakeClient{
+	fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
 		IssueComments: map[int][]github.IssueComment{
 			101: {
 				{
-					Body: fmt.Sprintf(addCanMergeLabelNotification, treeSHA),
+					Body: canMergeNoti(treeSHA, treeSHA, treeSHA),
 					User: github.User{Login: fakegithub.Bot},
 				},
 			},
 		},
 		Collaborators: []string{"collab1", "collab2"},
-	}
+	}}
 	fc.IssueLabelsAdded = []string{"kubernetes/kubernetes#101:" + externalplugins.CanMergeLabel}
 	fp := &fakePruner{
-		GitHubClient:  fc,
+		GitHubClient:  fc.FakeClient,
 		IssueComments: fc.IssueComments[101],
 	}
 
@@ -932,10 +856,10 @@ func TestRemoveTreeHashComment(t *testing.T) {
 		needsLgtm:  2,
 	}
 
-	_ = handle(false, cfg, rc, fc, foc, fp, logrus.WithField("plugin", PluginName))
+	_ = handle(false, cfg, rc, fc, nil, foc, fp, logrus.WithField("plugin", PluginName))
 	found := false
 	for _, body := range fc.IssueCommentsDeleted {
-		if addCanMergeLabelNotificationRe.MatchString(body) {
+		if canMergeNotificationRe.MatchString(body) {
 			found = true
 			break
 		}
@@ -945,12 +869,16 @@ func TestRemoveTreeHashComment(t *testing.T) {
 	}
 }
 
-func TestGetCurrentLabelNumber(t *testing.T) {
+func TestIsLGTMSatisfy(t *testing.T) {
 	var testcases = []struct {
-		name      string
-		labels    []github.Label
-		needsLgtm int
-		isSatisfy bool
+		name                  string
+		labels                []github.Label
+		needsLgtm             int
+		requiredCommitterLgtm int
+		requiredAffiliations  int
+		approvers             []string
+		lgtmLabel             string
+		isSatisfy             bool
 	}{
 		{
 			name:      "Current no LGTM",
@@ -994,12 +922,130 @@ func TestGetCurrentLabelNumber(t *testing.T) {
 			needsLgtm: 2,
 			isSatisfy: true,
 		},
+		{
+			name: "Current weighted LGT3, needs 2 LGTM",
+			labels: []github.Label{
+				{
+					Name: externalplugins.LgtmLabelPrefix + "3",
+				},
+			},
+			needsLgtm: 2,
+			isSatisfy: true,
+		},
+		{
+			name: "Current LGT2, needs 2 LGTM and 1 from committers, approved by reviewers",
+			labels: []github.Label{
+				{
+					Name: lgtmTwo,
+				},
+			},
+			needsLgtm:             2,
+			requiredCommitterLgtm: 1,
+			approvers:             []string{"reviewer1", "reviewer2"},
+			isSatisfy:             false,
+		},
+		{
+			name: "Current LGT2, needs 2 LGTM and 1 from committers, approved by a committer",
+			labels: []github.Label{
+				{
+					Name: lgtmTwo,
+				},
+			},
+			needsLgtm:             2,
+			requiredCommitterLgtm: 1,
+			approvers:             []string{"committer1", "reviewer1"},
+			isSatisfy:             true,
+		},
+		{
+			name: "Custom LGTM label, needs 2 LGTM",
+			labels: []github.Label{
+				{
+					Name: lgtmTwo,
+				},
+				{
+					Name: "lgtm-1",
+				},
+			},
+			needsLgtm: 2,
+			lgtmLabel: "lgtm-{n}",
+			isSatisfy: false,
+		},
+		{
+			name: "LGTM label without number, needs 2 LGTM and approved by 2 reviewers",
+			labels: []github.Label{
+				{
+					Name: "lgtm",
+				},
+			},
+			needsLgtm: 2,
+			lgtmLabel: "lgtm",
+			approvers: []string{"reviewer1", "reviewer2"},
+			isSatisfy: true,
+		},
+		{
+			name: "LGTM label without number, needs 2 LGTM and approved by 1 reviewer",
+			labels: []github.Label{
+				{
+					Name: "lgtm",
+				},
+			},
+			needsLgtm: 2,
+			lgtmLabel: "lgtm",
+			approvers: []string{"reviewer1"},
+			isSatisfy: false,
+		},
+		{
+			name: "Current LGT2, needs 2 affiliations, approved by the same affiliation",
+			labels: []github.Label{
+				{
+					Name: lgtmTwo,
+				},
+			},
+			needsLgtm:            2,
+			requiredAffiliations: 2,
+			approvers:            []string{"committer1", "reviewer1"},
+			isSatisfy:            false,
+		},
+		{
+			name: "Current LGT2, needs 2 affiliations, approved by different affiliations",
+			labels: []github.Label{
+				{
+					Name: lgtmTwo,
+				},
+			},
+			needsLgtm:            2,
+			requiredAffiliations: 2,
+			approvers:            []string{"committer1", "reviewer2"},
+			isSatisfy:            true,
+		},
 	}
 
 	for _, testcase := range testcases {
 		tc := testcase
 		t.Run(tc.name, func(t *testing.T) {
-			isSatisfy := isLGTMSatisfy(externalplugins.LgtmLabelPrefix, tc.labels, tc.needsLgtm)
+			cfg := &externalplugins.Configuration{
+				LabelSchemes: []externalplugins.LabelScheme{
+					{
+						Repos:     []string{"org/repo"},
+						LgtmLabel: tc.lgtmLabel,
+					},
+				},
+			}
+			lgtmOpts := &externalplugins.TiCommunityLgtm{
+				RequiredCommitterLgtm: tc.requiredCommitterLgtm,
+				RequiredAffiliations:  tc.requiredAffiliations,
+			}
+			owners := &ownersclient.Owners{
+				Committers: []string{"committer1"},
+				Reviewers:  []string{"committer1", "reviewer1", "reviewer2"},
+				NeedsLgtm:  tc.needsLgtm,
+				Affiliations: map[string]string{
+					"committer1": "company-a",
+					"reviewer1":  "company-a",
+					"reviewer2":  "company-b",
+				},
+			}
+			isSatisfy := isLGTMSatisfy(lgtmOpts, cfg.LabelSchemeFor("org", "repo"), owners, tc.labels, tc.approvers)
 
 			if isSatisfy != tc.isSatisfy {
 				t.Fatalf("satisify mismatch: got %v, want %v", isSatisfy, tc.isSatisfy)
@@ -1009,6 +1055,7 @@ func TestGetCurrentLabelNumber(t *testing.T) {
 }
 
 func TestHelpProvider(t *testing.T) {
+	configInfoStoreTreeHash := "New commits will not remove the 'can-merge' label"
 	enabledRepos := []config.OrgRepo{
 		{Org: "org1", Repo: "repo"},
 		{Org: "org2", Repo: "repo"},
@@ -1040,6 +1087,21 @@ func TestHelpProvider(t *testing.T) {
 			enabledRepos:       enabledRepos,
 			configInfoIncludes: []string{configInfoStoreTreeHash},
 		},
+		{
+			name: "StoreTreeHash enabled in Chinese",
+			config: &externalplugins.Configuration{
+				Locale: externalplugins.LocaleChinese,
+				TiCommunityMerge: []externalplugins.TiCommunityMerge{
+					{
+						Repos:         []string{"org2/repo"},
+						StoreTreeHash: true,
+					},
+				},
+			},
+			enabledRepos:       enabledRepos,
+			configInfoIncludes: []string{"该插件有以下配置", "新的提交不会移除 'can-merge' 标签"},
+			configInfoExcludes: []string{configInfoStoreTreeHash},
+		},
 		{
 			name: "All configs enabled",
 			config: &externalplugins.Configuration{
@@ -1080,104 +1142,266 @@ func TestHelpProvider(t *testing.T) {
 	}
 }
 
-func TestAllGuaranteed(t *testing.T) {
-	treeSHA := "6dcb09b5b57875f334f61aebed695e2e4193db5e"
+func TestMergeReviewStatus(t *testing.T) {
+	fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
+		IssueComments: make(map[int][]github.IssueComment),
+		PullRequests: map[int]*github.PullRequest{
+			5: {Number: 5, Head: github.PullRequestBranch{SHA: "head-sha"}},
+		},
+		IssueLabelsExisting: []string{"org/repo#5:" + lgtmTwo},
+		CombinedStatuses:    map[string]*github.CombinedStatus{},
+	}}
+	e := &github.IssueCommentEvent{
+		Action: github.IssueCommentActionCreated,
+		Issue: github.Issue{
+			User:        github.User{Login: "author"},
+			Number:      5,
+			State:       "open",
+			PullRequest: &struct{}{},
+		},
+		Comment: github.IssueComment{
+			Body: "/merge",
+			User: github.User{Login: "collab1"},
+		},
+		Repo: github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+	}
+	cfg := &externalplugins.Configuration{
+		TiCommunityMerge: []externalplugins.TiCommunityMerge{
+			{
+				Repos:        []string{"org/repo"},
+				ReviewStatus: true,
+			},
+		},
+	}
+	foc := &fakeOwnersClient{
+		committers: []string{"collab1"},
+		needsLgtm:  2,
+	}
+	cp := &fakePruner{GitHubClient: fc.FakeClient}
 
-	var testcases = []struct {
-		name             string
-		lastCanMergeSha  string
-		commits          []github.RepositoryCommit
-		expectGuaranteed bool
+	if err := HandleIssueCommentEvent(fc, nil, e, cfg, foc, cp, logrus.WithField("plugin", PluginName)); err != nil {
+		t.Fatalf("didn't expect error: %v", err)
+	}
+
+	statuses := fc.CreatedStatuses["head-sha"]
+	if len(statuses) != 1 {
+		t.Fatalf("expected one review status, got %v", fc.CreatedStatuses)
+	}
+	if statuses[0].Context != externalplugins.ReviewStatusContext || statuses[0].State != "success" ||
+		!strings.HasSuffix(statuses[0].Description, "can-merge: yes") {
+		t.Errorf("unexpected review status: %+v", statuses[0])
+	}
+}
+
+func TestMergeNeedsLgtm(t *testing.T) {
+	testcases := []struct {
+		name                  string
+		lgtmLabel             string
+		requiredCommitterLgtm int
+
+		expectResponse string
 	}{
 		{
-			name:            "Only one commit",
-			lastCanMergeSha: treeSHA,
-			commits: []github.RepositoryCommit{
-				{
-					SHA: treeSHA,
-				},
-			},
-			expectGuaranteed: true,
+			name:           "not enough LGTMs",
+			lgtmLabel:      lgtmOne,
+			expectResponse: "`/merge` in this pull request requires 2 `/lgtm`.",
 		},
 		{
-			name:            "All authored commits",
-			lastCanMergeSha: treeSHA,
-			commits: []github.RepositoryCommit{
-				{
-					SHA: "some-sha",
+			name:                  "not enough LGTMs from committers",
+			lgtmLabel:             lgtmTwo,
+			requiredCommitterLgtm: 1,
+			expectResponse: "`/merge` in this pull request requires 2 `/lgtm`, " +
+				"including 1 from committers while it has 0.",
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
+				IssueComments: make(map[int][]github.IssueComment),
+				PullRequests: map[int]*github.PullRequest{
+					5: {Number: 5, Head: github.PullRequestBranch{SHA: "head-sha"}},
 				},
-				{
-					SHA: treeSHA,
+				IssueLabelsExisting: []string{"org/repo#5:" + tc.lgtmLabel},
+			}}
+			e := &github.IssueCommentEvent{
+				Action: github.IssueCommentActionCreated,
+				Issue: github.Issue{
+					User:        github.User{Login: "author"},
+					Number:      5,
+					State:       "open",
+					PullRequest: &struct{}{},
 				},
-			},
-			expectGuaranteed: true,
-		},
-		{
-			name:            "Guaranteed by github",
-			lastCanMergeSha: treeSHA,
-			commits: []github.RepositoryCommit{
-				{
-					SHA: "some-sha",
+				Comment: github.IssueComment{
+					Body: "/merge",
+					User: github.User{Login: "collab1"},
 				},
-				{
-					SHA: treeSHA,
+				Repo: github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+			}
+			cfg := &externalplugins.Configuration{
+				TiCommunityLgtm: []externalplugins.TiCommunityLgtm{
+					{
+						Repos:                 []string{"org/repo"},
+						RequiredCommitterLgtm: tc.requiredCommitterLgtm,
+					},
 				},
-				{
-					SHA: "some-sha",
-					Committer: github.User{
-						Login: githubUpdateCommitter,
+				TiCommunityMerge: []externalplugins.TiCommunityMerge{
+					{
+						Repos: []string{"org/repo"},
 					},
 				},
-			},
-			expectGuaranteed: true,
+			}
+			foc := &fakeOwnersClient{
+				committers: []string{"collab1"},
+				needsLgtm:  2,
+			}
+			cp := &fakePruner{GitHubClient: fc.FakeClient}
+
+			err := HandleIssueCommentEvent(fc, nil, e, cfg, foc, cp, logrus.WithField("plugin", PluginName))
+			if err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+
+			if len(fc.IssueLabelsAdded) != 0 {
+				t.Errorf("unexpected labels added: %v", fc.IssueLabelsAdded)
+			}
+			if len(fc.IssueComments[5]) != 1 {
+				t.Fatalf("expected one response, got %v", fc.IssueComments[5])
+			}
+			if body := fc.IssueComments[5][0].Body; !strings.Contains(body, tc.expectResponse) {
+				t.Errorf("expected response %q, got %q", tc.expectResponse, body)
+			}
+		})
+	}
+}
+
+func TestMergeMethod(t *testing.T) {
+	squashLabel := externalplugins.MergeMethodLabel(github.MergeSquash)
+	rebaseLabel := externalplugins.MergeMethodLabel(github.MergeRebase)
+
+	testcases := []struct {
+		name   string
+		body   string
+		labels []string
+
+		expectAddedLabels   []string
+		expectRemovedLabels []string
+		expectComment       string
+	}{
+		{
+			name:              "Merge with an allowed method",
+			body:              "/merge squash",
+			labels:            []string{lgtmTwo},
+			expectAddedLabels: []string{squashLabel, externalplugins.CanMergeLabel},
 		},
 		{
-			name:            "New commit not guaranteed",
-			lastCanMergeSha: treeSHA,
-			commits: []github.RepositoryCommit{
-				{
-					SHA: "some-sha",
-				},
-				{
-					SHA: treeSHA,
-				},
-				{
-					SHA: "some-sha",
-				},
-			},
-			expectGuaranteed: false,
+			name:                "Change the merge method",
+			body:                "/merge squash",
+			labels:              []string{lgtmTwo, rebaseLabel},
+			expectAddedLabels:   []string{squashLabel, externalplugins.CanMergeLabel},
+			expectRemovedLabels: []string{rebaseLabel},
 		},
 		{
-			name:            "New commit and github update",
-			lastCanMergeSha: treeSHA,
-			commits: []github.RepositoryCommit{
-				{
-					SHA: "some-sha",
-				},
-				{
-					SHA: treeSHA,
-				},
-				{
-					SHA: "some-sha",
-					Committer: github.User{
-						Login: githubUpdateCommitter,
-					},
-				},
-				{
-					SHA: "some-sha",
-				},
-			},
-			expectGuaranteed: false,
+			name:          "Merge method is not recorded without enough LGTMs",
+			body:          "/merge squash",
+			labels:        []string{lgtmOne},
+			expectComment: "`/merge` in this pull request requires 2 `/lgtm`.",
+		},
+		{
+			name:   "Merge method is not recorded when the PR is already accepted",
+			body:   "/merge squash",
+			labels: []string{lgtmTwo, externalplugins.CanMergeLabel, rebaseLabel},
+		},
+		{
+			name:          "Merge with a method not allowed",
+			body:          "/merge merge",
+			labels:        []string{lgtmTwo},
+			expectComment: "`/merge merge` is not allowed in this repository. The allowed merge methods are: squash, rebase.",
+		},
+		{
+			name:              "Merge without a method keeps the chosen method",
+			body:              "/merge",
+			labels:            []string{lgtmTwo, rebaseLabel},
+			expectAddedLabels: []string{externalplugins.CanMergeLabel},
+		},
+		{
+			name:                "Cancel the merge forgets the method",
+			body:                "/merge cancel",
+			labels:              []string{lgtmTwo, externalplugins.CanMergeLabel, squashLabel},
+			expectRemovedLabels: []string{squashLabel, externalplugins.CanMergeLabel},
 		},
 	}
 
 	for _, testcase := range testcases {
 		tc := testcase
 		t.Run(tc.name, func(t *testing.T) {
-			isGuaranteed := isAllGuaranteed(tc.commits, tc.lastCanMergeSha, logrus.WithField("plugin", PluginName))
+			var labels []string
+			for _, label := range tc.labels {
+				labels = append(labels, "org/repo#5:"+label)
+			}
+			fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
+				IssueComments: make(map[int][]github.IssueComment),
+				PullRequests: map[int]*github.PullRequest{
+					5: {Number: 5, Head: github.PullRequestBranch{SHA: "abc"}},
+				},
+				IssueLabelsExisting: labels,
+			}}
+			e := &github.IssueCommentEvent{
+				Action: github.IssueCommentActionCreated,
+				Issue: github.Issue{
+					User:        github.User{Login: "author"},
+					Number:      5,
+					State:       "open",
+					PullRequest: &struct{}{},
+				},
+				Comment: github.IssueComment{
+					Body: tc.body,
+					User: github.User{Login: "collab1"},
+				},
+				Repo: github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+			}
+			cfg := &externalplugins.Configuration{
+				TiCommunityMerge: []externalplugins.TiCommunityMerge{
+					{
+						Repos:        []string{"org/repo"},
+						MergeMethods: []string{"squash", "rebase"},
+					},
+				},
+			}
+			foc := &fakeOwnersClient{
+				committers: []string{"collab1"},
+				needsLgtm:  2,
+			}
+			cp := &fakePruner{GitHubClient: fc.FakeClient}
+
+			if err := HandleIssueCommentEvent(fc, nil, e, cfg, foc, cp, logrus.WithField("plugin", PluginName)); err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
 
-			if isGuaranteed != tc.expectGuaranteed {
-				t.Fatalf("=guarantee mismatch: got %v, want %v", isGuaranteed, tc.expectGuaranteed)
+			var expectAdded, expectRemoved []string
+			for _, label := range tc.expectAddedLabels {
+				expectAdded = append(expectAdded, "org/repo#5:"+label)
+			}
+			for _, label := range tc.expectRemovedLabels {
+				expectRemoved = append(expectRemoved, "org/repo#5:"+label)
+			}
+			if !equality.Semantic.DeepEqual(fc.IssueLabelsAdded, expectAdded) {
+				t.Errorf("added labels mismatch: got %v, want %v", fc.IssueLabelsAdded, expectAdded)
+			}
+			if !equality.Semantic.DeepEqual(fc.IssueLabelsRemoved, expectRemoved) {
+				t.Errorf("removed labels mismatch: got %v, want %v", fc.IssueLabelsRemoved, expectRemoved)
+			}
+
+			comments := fc.IssueComments[5]
+			if tc.expectComment == "" {
+				if len(comments) != 0 {
+					t.Errorf("expected no comment, got %v", comments)
+				}
+				return
+			}
+			if len(comments) != 1 || !strings.Contains(comments[0].Body, tc.expectComment) {
+				t.Errorf("expected the comment contains %q, got %v", tc.expectComment, comments)
 			}
 		})
 	}
@@ -0,0 +1,197 @@
+package merge
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+	git "k8s.io/test-infra/prow/git/v2"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+// approvalsChangedIdentifier identifies the notification which explains why the 'can-merge' label is removed
+// by the reconciliation.
+const approvalsChangedIdentifier = "Merge Approvals Changed"
+
+// HandleAll reconciles the 'can-merge' label of all open PRs in the orgs and repos that enabled this plugin.
+func HandleAll(log *logrus.Entry, gc githubClient, config *plugins.Configuration,
+	externalConfig *externalplugins.Configuration, ol ownersclient.OwnersLoader) error {
+	log.Info("Reconciling all PRs.")
+	orgs, repos := config.EnabledReposForExternalPlugin(PluginName)
+	if len(orgs) == 0 && len(repos) == 0 {
+		log.Warnf("No repos have been configured for the %s plugin", PluginName)
+		return nil
+	}
+
+	prs, err := externalplugins.SearchOpenPullRequests(context.Background(), log, gc, orgs, repos)
+	if err != nil {
+		return err
+	}
+	log.Infof("Considering %d PRs.", len(prs))
+	for _, pr := range prs {
+		org := string(pr.Repository.Owner.Login)
+		repo := string(pr.Repository.Name)
+		num := int(pr.Number)
+
+		// Only the PRs with the 'can-merge' label need to be reconciled.
+		canMergeLabel := externalConfig.LabelSchemeFor(org, repo).CanMergeLabel
+		hasCanMerge := false
+		for _, label := range pr.Labels.Nodes {
+			if string(label.Name) == canMergeLabel {
+				hasCanMerge = true
+			}
+		}
+		if !hasCanMerge {
+			continue
+		}
+
+		l := log.WithFields(logrus.Fields{
+			"org":  org,
+			"repo": repo,
+			"pr":   num,
+		})
+		if err := reconcile(gc, externalConfig, ol, org, repo, num, "", l); err != nil {
+			l.WithError(err).Error("Error reconciling PR.")
+		}
+	}
+	return nil
+}
+
+// handlePullRequestLabelChanged reconciles the 'can-merge' label when the labels of the PR are changed,
+// because the LGTM label may be changed by `/lgtm cancel`, the number of required LGTMs may be changed
+// by the new labels and the 'can-merge' label may be added manually. The PR may also be merged automatically
+// because of the new LGTM label or the removal of the hold label.
+func handlePullRequestLabelChanged(gc githubClient, gitClient git.ClientFactory, pe *github.PullRequestEvent,
+	cfg *externalplugins.Configuration, ol ownersclient.OwnersLoader, log *logrus.Entry) error {
+	if pe.PullRequest.State != "open" {
+		return nil
+	}
+	org := pe.PullRequest.Base.Repo.Owner.Login
+	repo := pe.PullRequest.Base.Repo.Name
+	canMergeLabel := cfg.LabelSchemeFor(org, repo).CanMergeLabel
+
+	// There is nothing to reconcile if the 'can-merge' label is removed.
+	if pe.Action == github.PullRequestActionUnlabeled && pe.Label.Name == canMergeLabel {
+		return nil
+	}
+
+	// Tell who added the 'can-merge' label if it is not added by the bot.
+	addedBy := ""
+	if pe.Action == github.PullRequestActionLabeled && pe.Label.Name == canMergeLabel {
+		botUserChecker, err := gc.BotUserChecker()
+		if err != nil {
+			return err
+		}
+		if !botUserChecker(pe.Sender.Login) {
+			addedBy = pe.Sender.Login
+		}
+	}
+
+	if err := reconcile(gc, cfg, ol, org, repo, pe.Number, addedBy, log); err != nil {
+		return err
+	}
+
+	// The 'can-merge' label added manually is also withheld until all the dependencies are merged.
+	if addedBy != "" && cfg.MergeFor(org, repo).TrackDependencies {
+		unmerged, err := syncDependencies(gc, cfg, &pe.PullRequest, log)
+		if err != nil || len(unmerged) == 0 {
+			return err
+		}
+		return withholdCanMergeLabel(gc, cfg, &pe.PullRequest, unmerged, log)
+	}
+
+	if pe.Label.Name != canMergeLabel {
+		return tryAutoMerge(gc, gitClient, cfg, ol, &pe.PullRequest, log)
+	}
+	return nil
+}
+
+// reconcile recomputes whether the approvals of the PR satisfy the approval rules with the current owners,
+// and removes the 'can-merge' label with an explanation if they do not.
+func reconcile(gc githubClient, config *externalplugins.Configuration, ol ownersclient.OwnersLoader,
+	org, repo string, number int, addedBy string, log *logrus.Entry) error {
+	fetchErr := func(context string, err error) error {
+		return fmt.Errorf("failed to get %s for %s/%s#%d: %v", context, org, repo, number, err)
+	}
+
+	labels, err := gc.GetIssueLabels(org, repo, number)
+	if err != nil {
+		return fetchErr("issue labels", err)
+	}
+	labelScheme := config.LabelSchemeFor(org, repo)
+	canMergeLabel := labelScheme.CanMergeLabel
+	hasCanMerge := false
+	for _, label := range labels {
+		if label.Name == canMergeLabel {
+			hasCanMerge = true
+		}
+	}
+	if !hasCanMerge {
+		return nil
+	}
+	// The lgtm plugin adds the new LGTM label before removing the old one,
+	// the label event of the removal will reconcile the PR again.
+	if len(labelScheme.GetLgtmLabels(labels)) > 1 {
+		log.Info("Skip the reconciliation because the LGTM label is being changed.")
+		return nil
+	}
+
+	opts := config.MergeFor(org, repo)
+	owners, err := ol.LoadOwners(opts.PullOwnersEndpoint, org, repo, number)
+	if err != nil {
+		return fetchErr("owners info", err)
+	}
+	lgtmOpts := config.LgtmFor(org, repo)
+	var approvers []string
+	if lgtmOpts.NeedsApprovers() || !labelScheme.IsLgtmLabelNumbered() {
+		approvers, err = getApprovers(gc, org, repo, number)
+		if err != nil {
+			return fetchErr("approvers", err)
+		}
+	}
+	if isLGTMSatisfy(lgtmOpts, labelScheme, owners, labels, approvers) {
+		return nil
+	}
+
+	log.Infof("Removing '%s' label because the approvals no longer satisfy the requirements.", canMergeLabel)
+	if err := gc.RemoveLabel(org, repo, number, canMergeLabel); err != nil {
+		return err
+	}
+
+	resp, err := config.RenderMessage(org, repo, externalplugins.MessageMergeCanceledByApprovals,
+		map[string]interface{}{
+			"label":                 canMergeLabel,
+			"addedBy":               addedBy,
+			"currentLgtm":           lgtmOpts.LgtmCountFromLabels(labelScheme, owners, labels, approvers),
+			"needsLgtm":             owners.NeedsLgtm,
+			"committerLgtm":         externalplugins.CountCommitterLgtm(owners, approvers),
+			"requiredCommitterLgtm": lgtmOpts.RequiredCommitterLgtm,
+			"missingAffiliations":   lgtmOpts.MissingAffiliations(owners, approvers),
+		})
+	if err != nil {
+		return err
+	}
+	log.Infof("Commenting a 'can-merge' removal notification with the message: %s", resp)
+	err = updateMergeNotification(gc, config, org, repo, number, getApprovalsChangedNoti(resp), log)
+	if err != nil {
+		return err
+	}
+
+	reportReviewStatus(gc, config, ol, org, repo, number, log)
+	return nil
+}
+
+// getApprovalsChangedNoti returns the notification with the identifier which explains why the 'can-merge'
+// label is removed by the reconciliation.
+func getApprovalsChangedNoti(resp string) string {
+	return fmt.Sprintf("%s\n\n<!--%s-->", resp, approvalsChangedIdentifier)
+}
+
+// isApprovalsChangedNotification returns true if the comment is created by the reconciliation.
+func isApprovalsChangedNotification(body string) bool {
+	return strings.Contains(body, "<!--"+approvalsChangedIdentifier+"-->")
+}
@@ -0,0 +1,215 @@
+package merge
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+)
+
+func TestHandlePullRequestLabelChanged(t *testing.T) {
+	canMergeLabel := externalplugins.CanMergeLabel
+	lgtmThree := fmt.Sprintf("%s%d", externalplugins.LgtmLabelPrefix, 3)
+
+	testcases := []struct {
+		name      string
+		action    github.PullRequestEventAction
+		label     string
+		sender    string
+		state     string
+		labels    []string
+		needsLgtm int
+
+		expectLabelRemoved bool
+		expectComment      []string
+	}{
+		{
+			name:      "LGTM label is added, approvals still satisfied",
+			action:    github.PullRequestActionLabeled,
+			label:     lgtmThree,
+			labels:    []string{lgtmThree, canMergeLabel},
+			needsLgtm: 2,
+		},
+		{
+			name:      "LGTM label is removed by /lgtm cancel",
+			action:    github.PullRequestActionUnlabeled,
+			label:     lgtmTwo,
+			labels:    []string{canMergeLabel},
+			needsLgtm: 2,
+
+			expectLabelRemoved: true,
+			expectComment: []string{
+				"The `" + canMergeLabel + "` label has been removed",
+				"it has 0 LGTM while 2 LGTM are required",
+			},
+		},
+		{
+			name:      "Required LGTMs rise because of a new label",
+			action:    github.PullRequestActionLabeled,
+			label:     "sig/planner",
+			labels:    []string{lgtmTwo, canMergeLabel, "sig/planner"},
+			needsLgtm: 3,
+
+			expectLabelRemoved: true,
+			expectComment:      []string{"it has 2 LGTM while 3 LGTM are required"},
+		},
+		{
+			name:      "Label is added manually without enough LGTMs",
+			action:    github.PullRequestActionLabeled,
+			label:     canMergeLabel,
+			sender:    "someone",
+			labels:    []string{lgtmOne, canMergeLabel},
+			needsLgtm: 2,
+
+			expectLabelRemoved: true,
+			expectComment: []string{
+				"The `" + canMergeLabel + "` label added by someone has been removed",
+				"it has 1 LGTM while 2 LGTM are required",
+			},
+		},
+		{
+			name:      "Label is added by the bot",
+			action:    github.PullRequestActionLabeled,
+			label:     canMergeLabel,
+			sender:    fakegithub.Bot,
+			labels:    []string{lgtmTwo, canMergeLabel},
+			needsLgtm: 2,
+		},
+		{
+			name:      "LGTM label is being changed",
+			action:    github.PullRequestActionLabeled,
+			label:     lgtmOne,
+			labels:    []string{lgtmOne, lgtmTwo, canMergeLabel},
+			needsLgtm: 2,
+		},
+		{
+			name:      "PR without the can-merge label",
+			action:    github.PullRequestActionUnlabeled,
+			label:     lgtmTwo,
+			labels:    []string{},
+			needsLgtm: 2,
+		},
+		{
+			name:      "Can-merge label is removed",
+			action:    github.PullRequestActionUnlabeled,
+			label:     canMergeLabel,
+			labels:    []string{},
+			needsLgtm: 2,
+		},
+		{
+			name:      "Closed PR",
+			action:    github.PullRequestActionUnlabeled,
+			label:     lgtmTwo,
+			state:     "closed",
+			labels:    []string{canMergeLabel},
+			needsLgtm: 2,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			var labels []string
+			for _, label := range tc.labels {
+				labels = append(labels, fmt.Sprintf("org/repo#101:%s", label))
+			}
+			fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
+				IssueLabelsExisting: labels,
+				IssueComments:       map[int][]github.IssueComment{},
+			}}
+			state := tc.state
+			if state == "" {
+				state = "open"
+			}
+			e := &github.PullRequestEvent{
+				Action: tc.action,
+				Number: 101,
+				Label:  github.Label{Name: tc.label},
+				Sender: github.User{Login: tc.sender},
+				PullRequest: github.PullRequest{
+					Number: 101,
+					State:  state,
+					Base: github.PullRequestBranch{
+						Repo: github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+					},
+				},
+			}
+			cfg := &externalplugins.Configuration{}
+			cfg.TiCommunityMerge = []externalplugins.TiCommunityMerge{
+				{
+					Repos: []string{"org/repo"},
+				},
+			}
+			foc := &fakeOwnersClient{
+				committers: []string{"collab1"},
+				needsLgtm:  tc.needsLgtm,
+			}
+
+			err := HandlePullRequestEvent(fc, nil, e, cfg, foc, logrus.WithField("plugin", PluginName))
+			if err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+
+			var expectRemoved []string
+			if tc.expectLabelRemoved {
+				expectRemoved = []string{"org/repo#101:" + canMergeLabel}
+			}
+			if !equality.Semantic.DeepEqual(fc.IssueLabelsRemoved, expectRemoved) {
+				t.Errorf("labels removed mismatch: got %v, want %v", fc.IssueLabelsRemoved, expectRemoved)
+			}
+
+			if len(tc.expectComment) == 0 {
+				if len(fc.IssueCommentsAdded) != 0 {
+					t.Errorf("unexpected comments: %v", fc.IssueCommentsAdded)
+				}
+				return
+			}
+			if len(fc.IssueCommentsAdded) != 1 {
+				t.Fatalf("expected one comment, got %v", fc.IssueCommentsAdded)
+			}
+			comment := fc.IssueCommentsAdded[0]
+			if !isApprovalsChangedNotification(comment) {
+				t.Errorf("comment %q is not the notification of the reconciliation", comment)
+			}
+			for _, expect := range tc.expectComment {
+				if !strings.Contains(comment, expect) {
+					t.Errorf("comment %q does not contain %q", comment, expect)
+				}
+			}
+		})
+	}
+}
+
+func TestReconcileEditsNotification(t *testing.T) {
+	fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
+		IssueLabelsExisting: []string{"org/repo#101:" + externalplugins.CanMergeLabel},
+		IssueComments: map[int][]github.IssueComment{
+			101: {
+				{
+					ID:   1,
+					Body: canMergeNoti("abc", "def", "123"),
+					User: github.User{Login: fakegithub.Bot},
+				},
+			},
+		},
+	}}
+	cfg := &externalplugins.Configuration{}
+	foc := &fakeOwnersClient{needsLgtm: 1}
+
+	err := reconcile(fc, cfg, foc, "org", "repo", 101, "", logrus.WithField("plugin", PluginName))
+	if err != nil {
+		t.Fatalf("didn't expect error: %v", err)
+	}
+
+	if len(fc.IssueCommentsAdded) != 0 {
+		t.Errorf("unexpected comments: %v", fc.IssueCommentsAdded)
+	}
+	if len(fc.IssueCommentsEdited) != 1 || !isApprovalsChangedNotification(fc.IssueCommentsEdited[0]) {
+		t.Errorf("expected the accepted notification to be edited, got %v", fc.IssueCommentsEdited)
+	}
+}
@@ -0,0 +1,219 @@
+package merge
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins/testutil"
+	git "k8s.io/test-infra/prow/git/v2"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+)
+
+// newGitClient creates a git client factory without any repo.
+func newGitClient(t *testing.T) git.ClientFactory {
+	_, gitClient := testutil.NewLocalGit(t)
+	return gitClient
+}
+
+// newPullRequestRepo creates the repo with the PR of the number, it returns the head of the PR.
+func newPullRequestRepo(t *testing.T, number int) (string, git.ClientFactory) {
+	r, gitClient := testutil.NewFakeRepo(t)
+	r.Git("update-ref", fmt.Sprintf("refs/pull/%d/head", number), testutil.PRBranch)
+	return r.Git("rev-parse", testutil.PRBranch), gitClient
+}
+
+func TestStoreTreeHash(t *testing.T) {
+	testcases := []struct {
+		name         string
+		recreateNoti bool
+		// legacyNoti creates the approved notification of the format before the templates.
+		legacyNoti bool
+		update     func(r *testutil.FakeRepo)
+		// comments returns the comments of the PR from the approved notification.
+		comments func(noti string) []github.IssueComment
+
+		expectLabelRemoved bool
+	}{
+		{
+			name: "Rebased with the same changes, keep label",
+			update: func(r *testutil.FakeRepo) {
+				r.Commit(map[string]string{"base": "0\n1\n2\n3\n"})
+				r.Checkout(testutil.PRBranch)
+				r.Git("rebase", "master")
+				r.UpdatePR()
+			},
+			comments: func(noti string) []github.IssueComment {
+				return []github.IssueComment{{ID: 1, Body: noti, User: github.User{Login: fakegithub.Bot}}}
+			},
+		},
+		{
+			name: "New changes are pushed, remove label",
+			update: func(r *testutil.FakeRepo) {
+				r.Checkout(testutil.PRBranch)
+				r.Commit(map[string]string{"pr": "b\n"})
+				r.UpdatePR()
+			},
+			comments: func(noti string) []github.IssueComment {
+				return []github.IssueComment{{ID: 1, Body: noti, User: github.User{Login: fakegithub.Bot}}}
+			},
+			expectLabelRemoved: true,
+		},
+		{
+			name:       "Notification created before the templates, keep label",
+			legacyNoti: true,
+			update: func(r *testutil.FakeRepo) {
+				r.Commit(map[string]string{"base": "0\n1\n2\n3\n"})
+				r.Checkout(testutil.PRBranch)
+				r.Git("rebase", "master")
+				r.UpdatePR()
+			},
+			comments: func(noti string) []github.IssueComment {
+				return []github.IssueComment{{ID: 1, Body: noti, User: github.User{Login: fakegithub.Bot}}}
+			},
+		},
+		{
+			name:   "Only the latest notification counts",
+			update: func(r *testutil.FakeRepo) {},
+			comments: func(noti string) []github.IssueComment {
+				return []github.IssueComment{
+					{
+						ID:   1,
+						Body: canMergeNoti("abc", "def", "123"),
+						User: github.User{Login: fakegithub.Bot},
+					},
+					{ID: 2, Body: noti, User: github.User{Login: fakegithub.Bot}},
+				}
+			},
+		},
+		{
+			name:   "Notification of the old format, remove label",
+			update: func(r *testutil.FakeRepo) {},
+			comments: func(noti string) []github.IssueComment {
+				return []github.IssueComment{
+					{ID: 1, Body: noti, User: github.User{Login: fakegithub.Bot}},
+					{
+						ID:   2,
+						Body: legacyCanMergeNotificationPrefix + " <details>Commit hash: abc</details>",
+						User: github.User{Login: fakegithub.Bot},
+					},
+				}
+			},
+			expectLabelRemoved: true,
+		},
+		{
+			name:   "Notification not created by the bot, remove label",
+			update: func(r *testutil.FakeRepo) {},
+			comments: func(noti string) []github.IssueComment {
+				return []github.IssueComment{{ID: 1, Body: noti, User: github.User{Login: "someone"}}}
+			},
+			expectLabelRemoved: true,
+		},
+		{
+			name:         "Edited notification is ignored when notifications are recreated, remove label",
+			recreateNoti: true,
+			update:       func(r *testutil.FakeRepo) {},
+			comments: func(noti string) []github.IssueComment {
+				return []github.IssueComment{
+					{
+						ID:        1,
+						Body:      noti,
+						User:      github.User{Login: fakegithub.Bot},
+						CreatedAt: time.Date(1981, 2, 21, 12, 30, 0, 0, time.UTC),
+						UpdatedAt: time.Date(1981, 2, 21, 12, 31, 0, 0, time.UTC),
+					},
+				}
+			},
+			expectLabelRemoved: true,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			r, gitClient := testutil.NewFakeRepo(t)
+			tree, base, head := r.Git("rev-parse", testutil.PRBranch+"^{tree}"), r.Git("rev-parse", "master"),
+				r.Git("rev-parse", testutil.PRBranch)
+			noti := canMergeNoti(tree, base, head)
+			if tc.legacyNoti {
+				noti = fmt.Sprintf("%s <details>Tree hash: %s Base commit: %s Head commit: %s</details>",
+					legacyCanMergeNotificationPrefix, tree, base, head)
+			}
+			tc.update(r)
+
+			pr := r.PullRequest()
+			fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
+				IssueComments:       map[int][]github.IssueComment{testutil.Number: tc.comments(noti)},
+				PullRequests:        map[int]*github.PullRequest{testutil.Number: pr},
+				IssueLabelsExisting: []string{"org/repo#101:" + externalplugins.CanMergeLabel},
+			}}
+			cfg := &externalplugins.Configuration{
+				TiCommunityMerge: []externalplugins.TiCommunityMerge{
+					{
+						Repos:                []string{"org/repo"},
+						StoreTreeHash:        true,
+						RecreateNotification: tc.recreateNoti,
+					},
+				},
+			}
+			e := &github.PullRequestEvent{
+				Action:      github.PullRequestActionSynchronize,
+				PullRequest: *pr,
+			}
+
+			err := HandlePullRequestEvent(fc, gitClient, e, cfg, &fakeOwnersClient{},
+				logrus.WithField("plugin", PluginName))
+			if err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+
+			removed := len(fc.IssueLabelsRemoved) == 1
+			if removed != tc.expectLabelRemoved {
+				t.Errorf("label removed mismatch: got %v, want %v", fc.IssueLabelsRemoved, tc.expectLabelRemoved)
+			}
+		})
+	}
+}
+
+func TestAddTreeHashComment(t *testing.T) {
+	r, gitClient := testutil.NewFakeRepo(t)
+	pr := r.PullRequest()
+	fc := &fakeGitHubClient{FakeClient: &fakegithub.FakeClient{
+		IssueComments:       map[int][]github.IssueComment{},
+		PullRequests:        map[int]*github.PullRequest{testutil.Number: pr},
+		IssueLabelsExisting: []string{"org/repo#101:" + lgtmTwo},
+	}}
+	cfg := &externalplugins.Configuration{
+		TiCommunityMerge: []externalplugins.TiCommunityMerge{
+			{
+				Repos:         []string{"org/repo"},
+				StoreTreeHash: true,
+			},
+		},
+	}
+	rc := reviewCtx{
+		author:      "collab1",
+		issueAuthor: "author",
+		repo:        pr.Base.Repo,
+		number:      testutil.Number,
+		body:        "/merge",
+	}
+	foc := &fakeOwnersClient{
+		committers: []string{"collab1"},
+		needsLgtm:  2,
+	}
+
+	log := logrus.WithField("plugin", PluginName)
+	if err := handle(true, cfg, rc, fc, gitClient, foc, &fakePruner{}, log); err != nil {
+		t.Fatalf("didn't expect error: %v", err)
+	}
+
+	expected := "org/repo#101:" + canMergeNoti(r.Git("rev-parse", testutil.PRBranch+"^{tree}"),
+		r.Git("rev-parse", "master"), r.Git("rev-parse", testutil.PRBranch))
+	if len(fc.IssueCommentsAdded) != 1 || fc.IssueCommentsAdded[0] != expected {
+		t.Errorf("expected the tree hash comment %q, got %v", expected, fc.IssueCommentsAdded)
+	}
+}
@@ -53,7 +53,9 @@ type Server struct {
 	TokenGenerator func() []byte
 	Gc             githubClient
 	ConfigAgent    *tiexternalplugins.ConfigAgent
-	Log            *logrus.Entry
+	// PreferencesAgent provides the reviewer preferences, no one has any preferences if it is nil.
+	PreferencesAgent *tiexternalplugins.ReviewerPreferencesAgent
+	Log              *logrus.Entry
 }
 
 func (s *Server) listOwnersByAllSigs(opts *tiexternalplugins.TiCommunityOwners,
@@ -90,6 +92,7 @@ func (s *Server) listOwnersByAllSigs(opts *tiexternalplugins.TiCommunityOwners,
 		return nil, err
 	}
 
+	owners := ownersclient.Owners{}
 	members := membersRes.Data.Members
 	for _, member := range members {
 		// Except for activeContributor and reviewer, which are both committers.
@@ -100,6 +103,11 @@ func (s *Server) listOwnersByAllSigs(opts *tiexternalplugins.TiCommunityOwners,
 		if member.Level != activeContributorLevel {
 			reviewers = append(reviewers, member.GithubName)
 		}
+		// The levels of reviewers and above are the same as the roles of owners.
+		if ownersclient.IsKnownRole(member.Level) {
+			owners.InsertRole(member.GithubName, member.Level)
+		}
+		owners.InsertAffiliation(member.GithubName, member.Affiliation)
 	}
 
 	// If require lgtm no setting, use default require lgtm.
@@ -107,12 +115,13 @@ func (s *Server) listOwnersByAllSigs(opts *tiexternalplugins.TiCommunityOwners,
 		requireLgtm = defaultRequireLgtmNum
 	}
 
+	owners.Committers = sets.NewString(committers...).Insert(trustTeamMembers...).List()
+	owners.Reviewers = sets.NewString(reviewers...).Insert(trustTeamMembers...).List()
+	owners.NeedsLgtm = requireLgtm
+	insertTrustTeamMembersRole(&owners, trustTeamMembers)
+
 	return &ownersclient.OwnersResponse{
-		Data: ownersclient.Owners{
-			Committers: sets.NewString(committers...).Insert(trustTeamMembers...).List(),
-			Reviewers:  sets.NewString(reviewers...).Insert(trustTeamMembers...).List(),
-			NeedsLgtm:  requireLgtm,
-		},
+		Data:    owners,
 		Message: listOwnersSuccessMessage,
 	}, nil
 }
@@ -123,6 +132,7 @@ func (s *Server) listOwnersBySigs(sigNames []string,
 	var committers []string
 	var reviewers []string
 	var maxNeedsLgtm int
+	owners := ownersclient.Owners{}
 
 	for _, sigName := range sigNames {
 		url := opts.SigEndpoint + fmt.Sprintf(SigEndpointFmt, sigName)
@@ -150,25 +160,43 @@ func (s *Server) listOwnersBySigs(sigNames []string,
 		}
 
 		sig := sigRes.Data
+		var sigReviewers []string
 
 		for _, leader := range sig.Membership.TechLeaders {
 			committers = append(committers, leader.GithubName)
 			reviewers = append(reviewers, leader.GithubName)
+			owners.InsertRole(leader.GithubName, ownersclient.RoleLeader)
+			owners.InsertAffiliation(leader.GithubName, leader.Affiliation)
+			sigReviewers = append(sigReviewers, leader.GithubName)
 		}
 
 		for _, coLeader := range sig.Membership.CoLeaders {
 			committers = append(committers, coLeader.GithubName)
 			reviewers = append(reviewers, coLeader.GithubName)
+			owners.InsertRole(coLeader.GithubName, ownersclient.RoleCoLeader)
+			owners.InsertAffiliation(coLeader.GithubName, coLeader.Affiliation)
+			sigReviewers = append(sigReviewers, coLeader.GithubName)
 		}
 
 		for _, committer := range sig.Membership.Committers {
 			committers = append(committers, committer.GithubName)
 			reviewers = append(reviewers, committer.GithubName)
+			owners.InsertRole(committer.GithubName, ownersclient.RoleCommitter)
+			owners.InsertAffiliation(committer.GithubName, committer.Affiliation)
+			sigReviewers = append(sigReviewers, committer.GithubName)
 		}
 
 		for _, reviewer := range sig.Membership.Reviewers {
 			reviewers = append(reviewers, reviewer.GithubName)
+			owners.InsertRole(reviewer.GithubName, ownersclient.RoleReviewer)
+			owners.InsertAffiliation(reviewer.GithubName, reviewer.Affiliation)
+			sigReviewers = append(sigReviewers, reviewer.GithubName)
+		}
+
+		if owners.Sigs == nil {
+			owners.Sigs = map[string][]string{}
 		}
+		owners.Sigs[sigName] = sets.NewString(sigReviewers...).List()
 
 		if sig.NeedsLgtm > maxNeedsLgtm {
 			maxNeedsLgtm = sig.NeedsLgtm
@@ -182,12 +210,13 @@ func (s *Server) listOwnersBySigs(sigNames []string,
 		requireLgtm = maxNeedsLgtm
 	}
 
+	owners.Committers = sets.NewString(committers...).Insert(trustTeamMembers...).List()
+	owners.Reviewers = sets.NewString(reviewers...).Insert(trustTeamMembers...).List()
+	owners.NeedsLgtm = requireLgtm
+	insertTrustTeamMembersRole(&owners, trustTeamMembers)
+
 	return &ownersclient.OwnersResponse{
-		Data: ownersclient.Owners{
-			Committers: sets.NewString(committers...).Insert(trustTeamMembers...).List(),
-			Reviewers:  sets.NewString(reviewers...).Insert(trustTeamMembers...).List(),
-			NeedsLgtm:  requireLgtm,
-		},
+		Data:    owners,
 		Message: listOwnersSuccessMessage,
 	}, nil
 }
@@ -213,12 +242,18 @@ func (s *Server) listOwnersByGitHubPermission(org string, repo string,
 		requireLgtm = defaultRequireLgtmNum
 	}
 
+	owners := ownersclient.Owners{
+		Committers: committers,
+		Reviewers:  committers,
+		NeedsLgtm:  requireLgtm,
+	}
+	// The collaborators with write permission are all considered as committers.
+	for _, committer := range committers {
+		owners.InsertRole(committer, ownersclient.RoleCommitter)
+	}
+
 	return &ownersclient.OwnersResponse{
-		Data: ownersclient.Owners{
-			Committers: committers,
-			Reviewers:  committers,
-			NeedsLgtm:  requireLgtm,
-		},
+		Data:    owners,
 		Message: listOwnersSuccessMessage,
 	}, nil
 }
@@ -292,18 +327,49 @@ func (s *Server) ListOwners(org string, repo string, number int,
 		useGitHubPermission = opts.UseGitHubPermission
 	}
 
+	var ownersResponse *ownersclient.OwnersResponse
 	// When we cannot find a sig label for PR and there is no default sig name, we will use a collaborators.
 	if len(sigNames) == 0 {
 		// If we specify to use GitHub permissions,
 		// the people who have write and admin permissions will be reviewers and committers.
 		if useGitHubPermission {
-			return s.listOwnersByGitHubPermission(org, repo, trustTeamMembers.List(), requireLgtm)
+			ownersResponse, err = s.listOwnersByGitHubPermission(org, repo, trustTeamMembers.List(), requireLgtm)
+		} else {
+			ownersResponse, err = s.listOwnersByAllSigs(opts, trustTeamMembers.List(), requireLgtm)
 		}
+	} else {
+		ownersResponse, err = s.listOwnersBySigs(sigNames, opts, trustTeamMembers.List(), requireLgtm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The preferences of the owners are provided so that they can be inspected.
+	if s.PreferencesAgent != nil {
+		insertPreferences(&ownersResponse.Data, s.PreferencesAgent.Preferences())
+	}
+	return ownersResponse, nil
+}
 
-		return s.listOwnersByAllSigs(opts, trustTeamMembers.List(), requireLgtm)
+// insertPreferences records the preferences of the committers and the reviewers who have set them.
+func insertPreferences(owners *ownersclient.Owners, preferences map[string]ownersclient.ReviewerPreferences) {
+	for _, login := range sets.NewString(owners.Committers...).Insert(owners.Reviewers...).List() {
+		preference, ok := preferences[github.NormLogin(login)]
+		if !ok {
+			continue
+		}
+		if owners.Preferences == nil {
+			owners.Preferences = map[string]ownersclient.ReviewerPreferences{}
+		}
+		owners.Preferences[login] = preference
 	}
+}
 
-	return s.listOwnersBySigs(sigNames, opts, trustTeamMembers.List(), requireLgtm)
+// insertTrustTeamMembersRole records the trust team members as committers.
+func insertTrustTeamMembersRole(owners *ownersclient.Owners, trustTeamMembers []string) {
+	for _, member := range trustTeamMembers {
+		owners.InsertRole(member, ownersclient.RoleCommitter)
+	}
 }
 
 // getSigNamesByLabels returns the names of sig when the label prefix matches.
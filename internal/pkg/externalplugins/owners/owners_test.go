@@ -12,6 +12,7 @@ import (
 
 	"github.com/sirupsen/logrus"
 	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
 	"gotest.tools/assert"
 	"k8s.io/test-infra/prow/github"
 )
@@ -87,7 +88,8 @@ func TestListOwners(t *testing.T) {
 			Membership: SigMembership{
 				TechLeaders: []MemberInfo{
 					{
-						GithubName: "leader1",
+						GithubName:  "leader1",
+						Affiliation: "company-a",
 					}, {
 						GithubName: "leader2",
 					},
@@ -108,7 +110,8 @@ func TestListOwners(t *testing.T) {
 				},
 				Reviewers: []MemberInfo{
 					{
-						GithubName: "reviewer1",
+						GithubName:  "reviewer1",
+						Affiliation: "company-b",
 					}, {
 						GithubName: "reviewer2",
 					},
@@ -250,9 +253,12 @@ func TestListOwners(t *testing.T) {
 		useGitHubPermission    bool
 		branchesConfig         map[string]tiexternalplugins.TiCommunityOwnerBranchConfig
 
-		expectCommitters []string
-		expectReviewers  []string
-		expectNeedsLgtm  int
+		expectCommitters   []string
+		expectReviewers    []string
+		expectNeedsLgtm    int
+		expectRoles        map[string]string
+		expectAffiliations map[string]string
+		expectSigs         map[string][]string
 	}{
 		{
 			name:         "has one sig label",
@@ -271,6 +277,26 @@ func TestListOwners(t *testing.T) {
 				"committer1", "committer2", "reviewer1", "reviewer2",
 			},
 			expectNeedsLgtm: defaultRequireLgtmNum,
+			expectRoles: map[string]string{
+				"leader1":    ownersclient.RoleLeader,
+				"leader2":    ownersclient.RoleLeader,
+				"coLeader1":  ownersclient.RoleCoLeader,
+				"coLeader2":  ownersclient.RoleCoLeader,
+				"committer1": ownersclient.RoleCommitter,
+				"committer2": ownersclient.RoleCommitter,
+				"reviewer1":  ownersclient.RoleReviewer,
+				"reviewer2":  ownersclient.RoleReviewer,
+			},
+			expectAffiliations: map[string]string{
+				"leader1":   "company-a",
+				"reviewer1": "company-b",
+			},
+			expectSigs: map[string][]string{
+				"sig1": {
+					"coLeader1", "coLeader2", "committer1", "committer2",
+					"leader1", "leader2", "reviewer1", "reviewer2",
+				},
+			},
 		},
 		{
 			name:         "has one sig label and require one lgtm",
@@ -735,10 +761,72 @@ func TestListOwners(t *testing.T) {
 			if res.Data.NeedsLgtm != tc.expectNeedsLgtm {
 				t.Errorf("Different LGTM: Got \"%v\" expected \"%v\"", res.Data.NeedsLgtm, tc.expectNeedsLgtm)
 			}
+
+			if tc.expectRoles != nil && !reflect.DeepEqual(res.Data.Roles, tc.expectRoles) {
+				t.Errorf("Different roles: Got \"%v\" expected \"%v\"", res.Data.Roles, tc.expectRoles)
+			}
+
+			if tc.expectAffiliations != nil && !reflect.DeepEqual(res.Data.Affiliations, tc.expectAffiliations) {
+				t.Errorf("Different affiliations: Got \"%v\" expected \"%v\"", res.Data.Affiliations, tc.expectAffiliations)
+			}
+
+			if tc.expectSigs != nil && !reflect.DeepEqual(res.Data.Sigs, tc.expectSigs) {
+				t.Errorf("Different sigs: Got \"%v\" expected \"%v\"", res.Data.Sigs, tc.expectSigs)
+			}
 		})
 	}
 }
 
+func TestListOwnersWithPreferences(t *testing.T) {
+	fc := &fakegithub{
+		PullRequests: map[int]*github.PullRequest{
+			1: {
+				Base:   github.PullRequestBranch{Ref: "master"},
+				User:   github.User{Login: "author"},
+				Number: 1,
+				State:  "open",
+			},
+		},
+		Collaborators: []github.User{
+			{Login: "collab1", Permissions: github.RepoPermissions{Push: true}},
+			{Login: "Collab2", Permissions: github.RepoPermissions{Push: true}},
+			{Login: "collab3", Permissions: github.RepoPermissions{Push: true}},
+		},
+	}
+	config := &tiexternalplugins.Configuration{
+		TiCommunityOwners: []tiexternalplugins.TiCommunityOwners{
+			{
+				Repos:               []string{"ti-community-infra/test-dev"},
+				UseGitHubPermission: true,
+			},
+		},
+	}
+	pa := &tiexternalplugins.ReviewerPreferencesAgent{}
+	pa.Set(map[string]ownersclient.ReviewerPreferences{
+		"collab1":  {OptOut: true},
+		"collab2":  {MaxWeeklyRequests: 3},
+		"outsider": {OptOut: true},
+	})
+	ownersServer := Server{
+		Gc:               fc,
+		PreferencesAgent: pa,
+		Log:              logrus.WithField("server", "testing"),
+	}
+
+	res, err := ownersServer.ListOwners("ti-community-infra", "test-dev", 1, config)
+	if err != nil {
+		t.Fatalf("unexpected error: '%v'", err)
+	}
+
+	expectPreferences := map[string]ownersclient.ReviewerPreferences{
+		"collab1": {OptOut: true},
+		"Collab2": {MaxWeeklyRequests: 3},
+	}
+	if !reflect.DeepEqual(res.Data.Preferences, expectPreferences) {
+		t.Errorf("Different preferences: Got \"%v\" expected \"%v\"", res.Data.Preferences, expectPreferences)
+	}
+}
+
 func TestListOwnersFailed(t *testing.T) {
 	org := "ti-community-infra"
 	repoName := "test-dev"
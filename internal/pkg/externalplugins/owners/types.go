@@ -21,6 +21,8 @@ type MemberInfo struct {
 	GithubName string `json:"githubName"`
 	// Level specifies the level of contributor at this sig.
 	Level string `json:"level,omitempty"`
+	// Affiliation specifies the organization that the contributor is affiliated with.
+	Affiliation string `json:"affiliation,omitempty"`
 }
 
 // MemberInfo specifies the sig's membership.
@@ -0,0 +1,122 @@
+package externalplugins
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/test-infra/prow/github"
+	"sigs.k8s.io/yaml"
+)
+
+// ReviewerPreferencesAgent contains the agent mutex and the reviewer preferences loaded from the file
+// in the config repo, the key of the preferences is the normalized GitHub login.
+type ReviewerPreferencesAgent struct {
+	mut         sync.Mutex
+	preferences map[string]ownersclient.ReviewerPreferences
+}
+
+// Load attempts to load the reviewer preferences from the path. It returns an error if either
+// the file can't be read or the preferences are invalid.
+func (pa *ReviewerPreferencesAgent) Load(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	loaded := make(map[string]ownersclient.ReviewerPreferences)
+	if err := yaml.Unmarshal(b, &loaded); err != nil {
+		return err
+	}
+
+	preferences := make(map[string]ownersclient.ReviewerPreferences)
+	for login, preference := range loaded {
+		if err := ValidateReviewerPreferences(preference); err != nil {
+			return fmt.Errorf("reviewer preferences of %s: %v", login, err)
+		}
+		preferences[github.NormLogin(login)] = preference
+	}
+
+	pa.Set(preferences)
+	return nil
+}
+
+// Set attempts to set the reviewer preferences.
+func (pa *ReviewerPreferencesAgent) Set(preferences map[string]ownersclient.ReviewerPreferences) {
+	pa.mut.Lock()
+	defer pa.mut.Unlock()
+	pa.preferences = preferences
+}
+
+// Start starts polling path for the reviewer preferences. If the first attempt fails,
+// then start returns the error. Future errors will halt updates but not stop.
+// Nothing is loaded if the path is empty, so that no one has any preferences.
+func (pa *ReviewerPreferencesAgent) Start(path string) error {
+	if path == "" {
+		return nil
+	}
+	if err := pa.Load(path); err != nil {
+		return err
+	}
+	// nolint:staticcheck
+	ticker := time.Tick(pullDuration)
+	go func() {
+		for range ticker {
+			if err := pa.Load(path); err != nil {
+				logrus.WithField("path", path).WithError(err).Error("Error loading reviewer preferences.")
+			}
+		}
+	}()
+	return nil
+}
+
+// Preferences returns the current reviewer preferences of the agent.
+func (pa *ReviewerPreferencesAgent) Preferences() map[string]ownersclient.ReviewerPreferences {
+	pa.mut.Lock()
+	defer pa.mut.Unlock()
+	return pa.preferences
+}
+
+// ValidateReviewerPreferences will return an error if the limit, the time zone or the sigs are invalid.
+func ValidateReviewerPreferences(preferences ownersclient.ReviewerPreferences) error {
+	if preferences.MaxWeeklyRequests < 0 {
+		return errors.New("max weekly requests must not less than 0")
+	}
+	if _, err := time.LoadLocation(preferences.TimeZone); err != nil {
+		return fmt.Errorf("invalid time zone %q: %v", preferences.TimeZone, err)
+	}
+	for _, sig := range preferences.Sigs {
+		if sig == "" {
+			return errors.New("sig must not be empty")
+		}
+	}
+	return nil
+}
+
+// ReviewerUnavailableReason returns why the review should not be requested from the reviewer automatically
+// for the PR of the sigs at the time, or empty if the reviewer is available. The weekly limit is not checked
+// here because it requires searching the PRs.
+func ReviewerUnavailableReason(preferences ownersclient.ReviewerPreferences, sigs []string, now time.Time) string {
+	if preferences.OptOut {
+		return "opted out"
+	}
+	if preferences.SkipWeekends {
+		location, err := time.LoadLocation(preferences.TimeZone)
+		if err != nil {
+			location = time.UTC
+		}
+		weekday := now.In(location).Weekday()
+		if weekday == time.Saturday || weekday == time.Sunday {
+			return "skips weekends"
+		}
+	}
+	if len(preferences.Sigs) != 0 && !sets.NewString(preferences.Sigs...).HasAny(sigs...) {
+		return "only reviews the sigs " + strings.Join(preferences.Sigs, ", ")
+	}
+	return ""
+}
@@ -0,0 +1,172 @@
+package externalplugins
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+)
+
+func TestLoadReviewerPreferences(t *testing.T) {
+	testcases := []struct {
+		name    string
+		content string
+
+		expectPreferences map[string]ownersclient.ReviewerPreferences
+		expectErr         error
+	}{
+		{
+			name: "valid preferences",
+			content: `
+Reviewer1:
+  skip_weekends: true
+  time_zone: Asia/Shanghai
+  max_weekly_requests: 3
+  sigs:
+    - planner
+reviewer2:
+  opt_out: true
+`,
+			expectPreferences: map[string]ownersclient.ReviewerPreferences{
+				"reviewer1": {
+					SkipWeekends:      true,
+					TimeZone:          "Asia/Shanghai",
+					MaxWeeklyRequests: 3,
+					Sigs:              []string{"planner"},
+				},
+				"reviewer2": {OptOut: true},
+			},
+		},
+		{
+			name:              "empty file",
+			expectPreferences: map[string]ownersclient.ReviewerPreferences{},
+		},
+		{
+			name:      "negative max weekly requests",
+			content:   "reviewer1:\n  max_weekly_requests: -1\n",
+			expectErr: errors.New("reviewer preferences of reviewer1: max weekly requests must not less than 0"),
+		},
+		{
+			name:    "invalid time zone",
+			content: "reviewer1:\n  time_zone: Mars/Olympus\n",
+			expectErr: errors.New("reviewer preferences of reviewer1: invalid time zone \"Mars/Olympus\": " +
+				"unknown time zone Mars/Olympus"),
+		},
+		{
+			name:      "empty sig",
+			content:   "reviewer1:\n  sigs:\n    - \"\"\n",
+			expectErr: errors.New("reviewer preferences of reviewer1: sig must not be empty"),
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			file, err := ioutil.TempFile("", "reviewer_preferences*.yaml")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer os.Remove(file.Name())
+			if _, err := file.WriteString(tc.content); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := file.Close(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// The preferences loaded before are kept if the file is invalid.
+			previous := map[string]ownersclient.ReviewerPreferences{"reviewer3": {NoReminders: true}}
+			pa := &ReviewerPreferencesAgent{preferences: previous}
+			err = pa.Load(file.Name())
+			if !reflect.DeepEqual(err, tc.expectErr) {
+				t.Errorf("error mismatch: got %v, want %v", err, tc.expectErr)
+			}
+			expectPreferences := tc.expectPreferences
+			if tc.expectErr != nil {
+				expectPreferences = previous
+			}
+			if preferences := pa.Preferences(); !reflect.DeepEqual(preferences, expectPreferences) {
+				t.Errorf("preferences mismatch: got %v, want %v", preferences, expectPreferences)
+			}
+		})
+	}
+}
+
+func TestStartReviewerPreferencesWithoutPath(t *testing.T) {
+	pa := &ReviewerPreferencesAgent{}
+	if err := pa.Start(""); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if preferences := pa.Preferences(); len(preferences) != 0 {
+		t.Errorf("expected no preferences, got %v", preferences)
+	}
+}
+
+func TestReviewerUnavailableReason(t *testing.T) {
+	// 2021-06-06 20:00 is still Sunday in UTC, but it is Monday morning in Asia/Shanghai.
+	saturday := time.Date(2021, 6, 5, 12, 0, 0, 0, time.UTC)
+	monday := time.Date(2021, 6, 7, 12, 0, 0, 0, time.UTC)
+	mondayInShanghai := time.Date(2021, 6, 6, 20, 0, 0, 0, time.UTC)
+
+	testcases := []struct {
+		name        string
+		preferences ownersclient.ReviewerPreferences
+		sigs        []string
+		now         time.Time
+
+		expectReason string
+	}{
+		{
+			name: "no preferences",
+			now:  saturday,
+		},
+		{
+			name:         "opted out",
+			preferences:  ownersclient.ReviewerPreferences{OptOut: true},
+			now:          monday,
+			expectReason: "opted out",
+		},
+		{
+			name:         "skip weekends",
+			preferences:  ownersclient.ReviewerPreferences{SkipWeekends: true},
+			now:          saturday,
+			expectReason: "skips weekends",
+		},
+		{
+			name:        "skip weekends on weekdays",
+			preferences: ownersclient.ReviewerPreferences{SkipWeekends: true},
+			now:         monday,
+		},
+		{
+			name:        "skip weekends in the time zone",
+			preferences: ownersclient.ReviewerPreferences{SkipWeekends: true, TimeZone: "Asia/Shanghai"},
+			now:         mondayInShanghai,
+		},
+		{
+			name:        "matched sigs",
+			preferences: ownersclient.ReviewerPreferences{Sigs: []string{"planner", "execution"}},
+			sigs:        []string{"execution"},
+			now:         monday,
+		},
+		{
+			name:         "unmatched sigs",
+			preferences:  ownersclient.ReviewerPreferences{Sigs: []string{"planner", "execution"}},
+			now:          monday,
+			expectReason: "only reviews the sigs planner, execution",
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			reason := ReviewerUnavailableReason(tc.preferences, tc.sigs, tc.now)
+			if reason != tc.expectReason {
+				t.Errorf("reason mismatch: got %q, want %q", reason, tc.expectReason)
+			}
+		})
+	}
+}
@@ -3,13 +3,14 @@ package externalplugins
 import (
 	"fmt"
 	"strings"
-
-	"k8s.io/test-infra/prow/github"
 )
 
+// defaultCommandHelpLink is the link of the commands used when the command help link is not configured.
+const defaultCommandHelpLink = "https://prow.tidb.io/command-help"
+
 // AboutThisBotWithoutCommands contains the message that explains how to interact with the bot.
 const AboutThisBotWithoutCommands = "Instructions for interacting with me using PR comments are available " +
-	"[here](https://prow.tidb.io/command-help).  " +
+	"[here](" + defaultCommandHelpLink + ").  " +
 	"If you have questions or suggestions related to my behavior, " +
 	"please file an issue against the " +
 	"[ti-community-infra/tichi]" +
@@ -21,8 +22,8 @@ const AboutThisBotCommands = "I understand the commands that are listed [here](h
 // AboutThisBot contains the text of both AboutThisBotWithoutCommands and AboutThisBotCommands.
 const AboutThisBot = AboutThisBotWithoutCommands + " " + AboutThisBotCommands
 
-// FormatResponse nicely formats a response to a generic reason.
-func FormatResponse(to, message, reason string) string {
+// FormatResponse nicely formats a response to a generic reason with the messages of the repo.
+func (c *Configuration) FormatResponse(org, repo, to, message, reason string) string {
 	format := `@%s: %s
 
 <details>
@@ -32,12 +33,12 @@ func FormatResponse(to, message, reason string) string {
 %s
 </details>`
 
-	return fmt.Sprintf(format, to, message, reason, AboutThisBotWithoutCommands)
+	return fmt.Sprintf(format, to, message, reason, c.aboutThisBot(org, repo))
 }
 
 // FormatSimpleResponse formats a response that does not warrant additional explanation in the
-// details section.
-func FormatSimpleResponse(to, message string) string {
+// details section with the messages of the repo.
+func (c *Configuration) FormatSimpleResponse(org, repo, to, message string) string {
 	format := `@%s: %s
 
 <details>
@@ -45,24 +46,41 @@ func FormatSimpleResponse(to, message string) string {
 %s
 </details>`
 
-	return fmt.Sprintf(format, to, message, AboutThisBotWithoutCommands)
-}
-
-// FormatICResponse nicely formats a response to an issue comment.
-func FormatICResponse(ic github.IssueComment, s string) string {
-	return FormatResponseRaw(ic.Body, ic.HTMLURL, ic.User.Login, s)
+	return fmt.Sprintf(format, to, message, c.aboutThisBot(org, repo))
 }
 
 // FormatResponseRaw nicely formats a response for one does not have an issue comment
-func FormatResponseRaw(body, bodyURL, login, reply string) string {
-	format := `In response to [this](%s):
+// with the messages of the repo.
+func (c *Configuration) FormatResponseRaw(org, repo, body, bodyURL, login, reply string) string {
+	inResponseTo, err := c.RenderMessage(org, repo, MessageInResponseTo, map[string]interface{}{
+		"url": bodyURL,
+	})
+	if err != nil {
+		inResponseTo = fmt.Sprintf("In response to [this](%s):", bodyURL)
+	}
 
-%s
-`
 	// Quote the user's comment by prepending ">" to each line.
 	var quoted []string
 	for _, l := range strings.Split(body, "\n") {
 		quoted = append(quoted, ">"+l)
 	}
-	return FormatResponse(login, reply, fmt.Sprintf(format, bodyURL, strings.Join(quoted, "\n")))
+	reason := fmt.Sprintf("%s\n\n%s\n", inResponseTo, strings.Join(quoted, "\n"))
+	return c.FormatResponse(org, repo, login, reply, reason)
+}
+
+// aboutThisBot returns the message that explains how to interact with the bot in the repo.
+func (c *Configuration) aboutThisBot(org, repo string) string {
+	commandHelpLink := c.CommandHelpLink
+	if commandHelpLink == "" {
+		commandHelpLink = defaultCommandHelpLink
+	}
+	about, err := c.RenderMessage(org, repo, MessageAboutThisBot, map[string]interface{}{
+		"commandHelpLink": commandHelpLink,
+		"org":             org,
+		"repo":            repo,
+	})
+	if err != nil {
+		return AboutThisBotWithoutCommands
+	}
+	return about
 }
@@ -3,23 +3,27 @@ package externalplugins
 import (
 	"strings"
 	"testing"
-
-	"k8s.io/test-infra/prow/github"
 )
 
-func TestFormatICResponse(t *testing.T) {
-	ic := github.IssueComment{
-		Body:    "Looks neat.\r\nI like it.\r\n",
-		User:    github.User{Login: "ca"},
-		HTMLURL: "happygoodsite.com",
+func TestFormatResponse(t *testing.T) {
+	config := &Configuration{}
+	out := config.FormatResponse("org", "repo", "ca", "you are a nice person.", "Because you are.")
+	if !strings.HasPrefix(out, "@ca: you are a nice person.") {
+		t.Errorf("Expected compliments to the comment author, got:\n%s", out)
+	}
+	if !strings.Contains(out, "Because you are.") || !strings.Contains(out, AboutThisBotWithoutCommands) {
+		t.Errorf("Expected the reason and the about message, got:\n%s", out)
 	}
-	s := "you are a nice person."
-	out := FormatICResponse(ic, s)
+}
+
+func TestFormatSimpleResponse(t *testing.T) {
+	config := &Configuration{}
+	out := config.FormatSimpleResponse("org", "repo", "ca", "you are a nice person.")
 	if !strings.HasPrefix(out, "@ca: you are a nice person.") {
 		t.Errorf("Expected compliments to the comment author, got:\n%s", out)
 	}
-	if !strings.Contains(out, ">I like it.\r\n") {
-		t.Errorf("Expected quotes, got:\n%s", out)
+	if !strings.Contains(out, AboutThisBotWithoutCommands) {
+		t.Errorf("Expected the about message, got:\n%s", out)
 	}
 }
 
@@ -29,7 +33,8 @@ func TestFormatResponseRaw(t *testing.T) {
 	htmlURL := "happygoodsite.com"
 	comment := "you are a nice person."
 
-	out := FormatResponseRaw(body, htmlURL, user, comment)
+	config := &Configuration{}
+	out := config.FormatResponseRaw("org", "repo", body, htmlURL, user, comment)
 	if !strings.HasPrefix(out, "@ca: you are a nice person.") {
 		t.Errorf("Expected compliments to the comment author, got:\n%s", out)
 	}
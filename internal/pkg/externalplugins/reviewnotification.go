@@ -0,0 +1,186 @@
+package externalplugins
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/test-infra/prow/github"
+)
+
+const (
+	// ReviewNotificationName defines the name used in the title for the review notifications.
+	ReviewNotificationName = "Review Notification"
+	// ReviewNotificationIdentifier defines the identifier for the review notifications.
+	ReviewNotificationIdentifier = "Review Notification Identifier"
+	// approvedHeadIdentifier defines the identifier for the head commit approved by the reviewers.
+	approvedHeadIdentifier = "Approved Head"
+	// approvalsResetIdentifier defines the identifier for the push which reset the approvals.
+	approvalsResetIdentifier = "Approvals Reset"
+	// assignedCommittersIdentifier defines the identifier for the committers assigned to help merge the PR.
+	assignedCommittersIdentifier = "Assigned Committers"
+)
+
+var (
+	// reviewNotificationRegex is the regex that matches the review notifications.
+	reviewNotificationRegex = regexp.MustCompile("<!--" + ReviewNotificationIdentifier + "-->$")
+	// reviewNotificationApproversRegex is the regex that matches the approvers, such as: - hi-rustin.
+	reviewNotificationApproversRegex = regexp.MustCompile(`(?im)^- [@]*([a-z0-9](?:-?[a-z0-9]){0,38})`)
+	// approvedHeadRegex is the regex that matches the approved head commit, such as: <!--Approved Head: 1a2b3c-->.
+	approvedHeadRegex = regexp.MustCompile("<!--" + approvedHeadIdentifier + ": ([0-9a-f]+)-->")
+	// approvalsResetRegex is the regex that matches the head commit and the time of the push which reset
+	// the approvals, such as: <!--Approvals Reset: 1a2b3c 2021-02-21T12:30:00Z-->.
+	approvalsResetRegex = regexp.MustCompile("<!--" + approvalsResetIdentifier + ": ([0-9a-f]+) (\\S+)-->")
+	// assignedCommittersRegex is the regex that matches the assigned committers,
+	// such as: <!--Assigned Committers: committer1,committer2-->.
+	assignedCommittersRegex = regexp.MustCompile("<!--" + assignedCommittersIdentifier + ": ([^>]*)-->")
+)
+
+// ReviewNotification is the state of the review shown in the review notification. The approvers are listed
+// in the notification, and the other state is kept in the hidden markers so that the notification can be
+// rendered again without losing it no matter how the template is customized.
+type ReviewNotification struct {
+	// Approvers are the reviewers who approved the PR.
+	Approvers []string
+	// Committers are the committers assigned to help merge the PR.
+	Committers []string
+	// ApprovedHead is the head commit of the PR when it was approved.
+	ApprovedHead string
+	// ResetHead and ResetAt are the head commit and the time of the last push which reset the approvals.
+	ResetHead string
+	ResetAt   time.Time
+}
+
+// ReviewNotificationClient is the GitHub client used to update the review notification.
+type ReviewNotificationClient interface {
+	BotUserChecker() (func(candidate string) bool, error)
+	ListIssueComments(org, repo string, number int) ([]github.IssueComment, error)
+	StickyCommentClient
+}
+
+// FilterReviewNotifications returns the review notifications created by the bot.
+func FilterReviewNotifications(comments []github.IssueComment, isBot func(string) bool) []*github.IssueComment {
+	return FilterStickyComments(comments, isBot, reviewNotificationRegex.MatchString)
+}
+
+// ParseReviewNotification returns the state recorded in the notification,
+// the state is empty if there is no notification.
+func ParseReviewNotification(notification *github.IssueComment) *ReviewNotification {
+	state := &ReviewNotification{}
+	if notification == nil {
+		return state
+	}
+
+	approvers := sets.NewString()
+	// Example: - a => [[- a a]]
+	for _, match := range reviewNotificationApproversRegex.FindAllStringSubmatch(notification.Body, -1) {
+		approvers.Insert(match[1])
+	}
+	if approvers.Len() > 0 {
+		state.Approvers = approvers.List()
+	}
+
+	if match := assignedCommittersRegex.FindStringSubmatch(notification.Body); match != nil {
+		for _, committer := range strings.Split(match[1], ",") {
+			if committer = strings.TrimSpace(committer); committer != "" {
+				state.Committers = append(state.Committers, committer)
+			}
+		}
+	}
+	if match := approvedHeadRegex.FindStringSubmatch(notification.Body); match != nil {
+		state.ApprovedHead = match[1]
+	}
+	if match := approvalsResetRegex.FindStringSubmatch(notification.Body); match != nil {
+		if resetAt, err := time.Parse(time.RFC3339, match[2]); err == nil {
+			state.ResetHead = match[1]
+			state.ResetAt = resetAt
+		}
+	}
+	return state
+}
+
+// GetApproversFromComments returns the approvers listed in the latest review notification of the comments,
+// the second return value is false if no review notification is found.
+func GetApproversFromComments(comments []github.IssueComment, isBot func(string) bool) (sets.String, bool) {
+	notifications := FilterReviewNotifications(comments, isBot)
+	if len(notifications) == 0 {
+		return sets.NewString(), false
+	}
+	return sets.NewString(ParseReviewNotification(notifications[len(notifications)-1]).Approvers...), true
+}
+
+// RenderReviewNotification returns the review notification of the state. The notification shows the approvers,
+// how an approver can indicate or cancel their lgtm and the committers assigned to help merge the PR.
+// When the approvers are required to come from different affiliations, the approvers are grouped
+// by their affiliations and the number of missing affiliations is shown.
+func (c *Configuration) RenderReviewNotification(org, repo string, owners *ownersclient.Owners,
+	state *ReviewNotification, ownersLink string) (string, error) {
+	data := map[string]interface{}{
+		"reviewers":           state.Approvers,
+		"commandHelpLink":     c.CommandHelpLink,
+		"prProcessLink":       c.PRProcessLink,
+		"ownersLink":          ownersLink,
+		"org":                 org,
+		"repo":                repo,
+		"assignedCommitters":  state.Committers,
+		"autoAssignCommitter": c.BlunderbussFor(org, repo).AssignCommitterOnLgtm,
+	}
+	if opts := c.LgtmFor(org, repo); opts.RequiredAffiliations > 0 {
+		data["affiliationGroups"] = GroupApproversByAffiliation(owners, state.Approvers)
+		data["missingAffiliations"] = opts.MissingAffiliations(owners, state.Approvers)
+	}
+
+	message, err := c.RenderMessage(org, repo, MessageLgtmReviewNotification, data)
+	if err != nil {
+		return "", err
+	}
+
+	if len(state.Committers) > 0 {
+		message = fmt.Sprintf("%s\n\n<!--%s: %s-->", message, assignedCommittersIdentifier,
+			strings.Join(state.Committers, ","))
+	}
+	if state.ApprovedHead != "" {
+		message = fmt.Sprintf("%s\n\n<!--%s: %s-->", message, approvedHeadIdentifier, state.ApprovedHead)
+	}
+	if state.ResetHead != "" {
+		message = fmt.Sprintf("%s\n\n<!--%s: %s %s-->", message, approvalsResetIdentifier,
+			state.ResetHead, state.ResetAt.UTC().Format(time.RFC3339))
+	}
+	// The identifier is always appended last so that the notification can be recognized
+	// no matter how the template is customized.
+	message = fmt.Sprintf("%s\n\n<!--%s-->\n", message, ReviewNotificationIdentifier)
+
+	return "[" + strings.ToUpper(ReviewNotificationName) + "]\n\n" + strings.TrimSpace(message), nil
+}
+
+// UpdateAssignedCommitters shows the committers assigned to help merge the PR in the latest review notification,
+// the other state of the notification is kept. Nothing is updated if the PR has no review notification.
+func UpdateAssignedCommitters(gc ReviewNotificationClient, config *Configuration, owners *ownersclient.Owners,
+	org, repo string, number int, committers []string, log *logrus.Entry) error {
+	botUserChecker, err := gc.BotUserChecker()
+	if err != nil {
+		return err
+	}
+	issueComments, err := gc.ListIssueComments(org, repo, number)
+	if err != nil {
+		return err
+	}
+	notifications := FilterReviewNotifications(issueComments, botUserChecker)
+	if len(notifications) == 0 {
+		return nil
+	}
+	state := ParseReviewNotification(notifications[len(notifications)-1])
+	state.Committers = committers
+
+	ownersLink := fmt.Sprintf(ownersclient.OwnersURLFmt, config.TichiWebURL, org, repo, number)
+	body, err := config.RenderReviewNotification(org, repo, owners, state, ownersLink)
+	if err != nil {
+		return err
+	}
+	// The notification is edited in place, so that the author is not notified again.
+	return UpdateStickyComment(gc, org, repo, number, notifications, body, false, log)
+}
@@ -0,0 +1,189 @@
+package externalplugins
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+)
+
+func TestRenderReviewNotification(t *testing.T) {
+	testcases := []struct {
+		name                  string
+		assignCommitterOnLgtm bool
+		state                 *ReviewNotification
+
+		expectMessage string
+	}{
+		{
+			name:          "Assign the committer manually",
+			state:         &ReviewNotification{Approvers: []string{"collab1"}},
+			expectMessage: "by filling  `/assign @committer` in the comment",
+		},
+		{
+			name:                  "Assign the committer automatically",
+			assignCommitterOnLgtm: true,
+			state:                 &ReviewNotification{Approvers: []string{"collab1"}},
+			expectMessage:         "will be assigned automatically to help you merge this pull request.",
+		},
+		{
+			name:                  "The committers have been assigned",
+			assignCommitterOnLgtm: true,
+			state: &ReviewNotification{
+				Approvers:  []string{"collab1"},
+				Committers: []string{"committer1", "committer2"},
+			},
+			expectMessage: "This pull request has been assigned to @committer1, @committer2 to help you merge",
+		},
+		{
+			name: "Record the approved head",
+			state: &ReviewNotification{
+				Approvers:    []string{"collab1"},
+				ApprovedHead: "1a2b3c",
+			},
+			expectMessage: "<!--Approved Head: 1a2b3c-->\n\n<!--Review Notification Identifier-->",
+		},
+		{
+			name: "Record the reset push",
+			state: &ReviewNotification{
+				ResetHead: "4d5e6f",
+				ResetAt:   time.Date(2021, 2, 21, 12, 30, 0, 0, time.UTC),
+			},
+			expectMessage: "<!--Approvals Reset: 4d5e6f 2021-02-21T12:30:00Z-->\n\n<!--Review Notification Identifier-->",
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &Configuration{
+				TiCommunityBlunderbuss: []TiCommunityBlunderbuss{
+					{
+						Repos:                 []string{"org/repo"},
+						AssignCommitterOnLgtm: tc.assignCommitterOnLgtm,
+					},
+				},
+			}
+			msg, err := cfg.RenderReviewNotification("org", "repo", nil, tc.state, "/repos/org/repo/pulls/5/owners")
+			if err != nil {
+				t.Fatalf("failed to generate notification: %v", err)
+			}
+			if !strings.HasPrefix(msg, "[REVIEW NOTIFICATION]\n\n") {
+				t.Errorf("expected the notification to start with the title: %q", msg)
+			}
+			if !strings.Contains(msg, tc.expectMessage) {
+				t.Errorf("message mismatch: got %q, want to contain %q", msg, tc.expectMessage)
+			}
+
+			// The state can be read back, and the assigned committers are not recognized as the approvers.
+			parsed := ParseReviewNotification(&github.IssueComment{Body: msg})
+			if !reflect.DeepEqual(parsed, tc.state) {
+				t.Errorf("parsed state mismatch: got %+v, want %+v", parsed, tc.state)
+			}
+		})
+	}
+}
+
+func TestGetApproversFromComments(t *testing.T) {
+	cfg := &Configuration{}
+	notificationFor := func(approvers ...string) string {
+		msg, err := cfg.RenderReviewNotification("org", "repo", nil, &ReviewNotification{Approvers: approvers}, "")
+		if err != nil {
+			t.Fatalf("failed to generate notification: %v", err)
+		}
+		return msg
+	}
+	isBot := func(login string) bool { return login == fakegithub.Bot }
+
+	testcases := []struct {
+		name     string
+		comments []github.IssueComment
+
+		expectApprovers []string
+		expectFound     bool
+	}{
+		{
+			name:     "No notification",
+			comments: []github.IssueComment{{Body: "- collab1", User: github.User{Login: "collab1"}}},
+		},
+		{
+			name: "Use the latest notification",
+			comments: []github.IssueComment{
+				{Body: notificationFor("collab1"), User: github.User{Login: fakegithub.Bot}},
+				{Body: notificationFor("collab1", "collab2"), User: github.User{Login: fakegithub.Bot}},
+			},
+			expectApprovers: []string{"collab1", "collab2"},
+			expectFound:     true,
+		},
+		{
+			name: "Ignore the notification not created by the bot",
+			comments: []github.IssueComment{
+				{Body: notificationFor("collab1"), User: github.User{Login: fakegithub.Bot}},
+				{Body: notificationFor("collab1", "collab2"), User: github.User{Login: "collab2"}},
+			},
+			expectApprovers: []string{"collab1"},
+			expectFound:     true,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			approvers, found := GetApproversFromComments(tc.comments, isBot)
+			if found != tc.expectFound {
+				t.Errorf("expected found to be %v", tc.expectFound)
+			}
+			if got := strings.Join(approvers.List(), ","); got != strings.Join(tc.expectApprovers, ",") {
+				t.Errorf("approvers mismatch: got %s, want %v", got, tc.expectApprovers)
+			}
+		})
+	}
+}
+
+type fakeReviewNotificationClient struct {
+	*fakegithub.FakeClient
+	edited []string
+}
+
+func (f *fakeReviewNotificationClient) EditComment(_, _ string, id int, comment string) error {
+	f.edited = append(f.edited, comment)
+	return f.FakeClient.EditComment("org", "repo", id, comment)
+}
+
+func TestUpdateAssignedCommitters(t *testing.T) {
+	cfg := &Configuration{}
+	state := &ReviewNotification{
+		Approvers:    []string{"collab1", "collab2"},
+		ApprovedHead: "1a2b3c",
+		ResetHead:    "4d5e6f",
+		ResetAt:      time.Date(2021, 2, 21, 12, 30, 0, 0, time.UTC),
+	}
+	notification, err := cfg.RenderReviewNotification("org", "repo", nil, state, "")
+	if err != nil {
+		t.Fatalf("failed to generate notification: %v", err)
+	}
+	fc := &fakeReviewNotificationClient{FakeClient: &fakegithub.FakeClient{
+		IssueComments: map[int][]github.IssueComment{
+			5: {{ID: 1, Body: notification, User: github.User{Login: fakegithub.Bot}}},
+		},
+	}}
+
+	err = UpdateAssignedCommitters(fc, cfg, nil, "org", "repo", 5, []string{"committer1"},
+		logrus.WithField("plugin", "test"))
+	if err != nil {
+		t.Fatalf("didn't expect error: %v", err)
+	}
+	if len(fc.edited) != 1 {
+		t.Fatalf("expected the notification to be edited once, got %q", fc.edited)
+	}
+	// The other state of the notification is kept.
+	expected := *state
+	expected.Committers = []string{"committer1"}
+	if parsed := ParseReviewNotification(&github.IssueComment{Body: fc.edited[0]}); !reflect.DeepEqual(parsed, &expected) {
+		t.Errorf("parsed state mismatch: got %+v, want %+v", parsed, &expected)
+	}
+}
@@ -0,0 +1,95 @@
+package externalplugins
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+	"k8s.io/test-infra/prow/github"
+)
+
+const (
+	// ReviewStatusContext is the context of the commit status which reports the review progress.
+	ReviewStatusContext = "tichi/review"
+	// maxStatusDescriptionLength is the maximum length of the commit status description allowed by GitHub.
+	maxStatusDescriptionLength = 140
+)
+
+// Ref: https://docs.github.com/en/rest/reference/repos#create-a-commit-status.
+const (
+	statusStateSuccess = "success"
+	statusStatePending = "pending"
+)
+
+type reviewStatusClient interface {
+	GetPullRequest(org, repo string, number int) (*github.PullRequest, error)
+	GetIssueLabels(org, repo string, number int) ([]github.Label, error)
+	CreateStatus(org, repo, SHA string, s github.Status) error
+}
+
+// ReviewStatus builds the review status of the PR from its labels and approvers. The status is successful
+// when the approval rules are satisfied, and the PR has the can merge label if the merge plugin reports it.
+func (c *Configuration) ReviewStatus(org, repo string, number int, owners *ownersclient.Owners,
+	labels []github.Label, approvers []string) github.Status {
+	lgtmOpts := c.LgtmFor(org, repo)
+	labelScheme := c.LabelSchemeFor(org, repo)
+
+	lgtmCount := lgtmOpts.LgtmCountFromLabels(labelScheme, owners, labels, approvers)
+	isSatisfied := lgtmOpts.IsLgtmSatisfied(owners, lgtmCount, approvers)
+	canMerge := false
+	for _, label := range labels {
+		if label.Name == labelScheme.CanMergeLabel {
+			canMerge = true
+		}
+	}
+
+	descriptions := []string{fmt.Sprintf("LGTM: %d/%d", lgtmCount, owners.NeedsLgtm)}
+	if len(approvers) != 0 {
+		descriptions[0] += fmt.Sprintf(" (%s)", strings.Join(approvers, ", "))
+	}
+	if missingSigs := MissingSigs(owners, approvers); len(missingSigs) != 0 {
+		descriptions = append(descriptions, "missing SIGs: "+strings.Join(missingSigs, ", "))
+	}
+	if canMerge {
+		descriptions = append(descriptions, "can-merge: yes")
+	} else {
+		descriptions = append(descriptions, "can-merge: no")
+	}
+
+	state := statusStatePending
+	if isSatisfied && (!c.MergeFor(org, repo).ReviewStatus || canMerge) {
+		state = statusStateSuccess
+	}
+
+	return github.Status{
+		State:       state,
+		TargetURL:   fmt.Sprintf(ownersclient.OwnersURLFmt, c.TichiWebURL, org, repo, number),
+		Description: truncateDescription(strings.Join(descriptions, "; ")),
+		Context:     ReviewStatusContext,
+	}
+}
+
+// ReportReviewStatus publishes the review status on the head commit of the PR.
+func (c *Configuration) ReportReviewStatus(gc reviewStatusClient, org, repo string, number int,
+	owners *ownersclient.Owners, approvers []string) error {
+	pr, err := gc.GetPullRequest(org, repo, number)
+	if err != nil {
+		return fmt.Errorf("failed to get pull request %s/%s#%d: %v", org, repo, number, err)
+	}
+	labels, err := gc.GetIssueLabels(org, repo, number)
+	if err != nil {
+		return fmt.Errorf("failed to get issue labels for %s/%s#%d: %v", org, repo, number, err)
+	}
+
+	status := c.ReviewStatus(org, repo, number, owners, labels, approvers)
+	return gc.CreateStatus(org, repo, pr.Head.SHA, status)
+}
+
+// truncateDescription truncates the description to the maximum length allowed by GitHub.
+func truncateDescription(description string) string {
+	runes := []rune(description)
+	if len(runes) <= maxStatusDescriptionLength {
+		return description
+	}
+	return string(runes[:maxStatusDescriptionLength-3]) + "..."
+}
@@ -0,0 +1,143 @@
+package externalplugins
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+)
+
+func TestReviewStatus(t *testing.T) {
+	owners := &ownersclient.Owners{
+		Committers: []string{"committer1"},
+		Reviewers:  []string{"committer1", "reviewer1", "reviewer2"},
+		NeedsLgtm:  2,
+		Sigs: map[string][]string{
+			"sig1": {"committer1", "reviewer1"},
+			"sig2": {"reviewer2"},
+		},
+	}
+
+	testcases := []struct {
+		name        string
+		labels      []string
+		approvers   []string
+		mergeStatus bool
+
+		expectState       string
+		expectDescription string
+	}{
+		{
+			name:              "No approvals",
+			expectState:       statusStatePending,
+			expectDescription: "LGTM: 0/2; missing SIGs: sig1, sig2; can-merge: no",
+		},
+		{
+			name:              "Not enough approvals",
+			labels:            []string{"status/LGT1"},
+			approvers:         []string{"reviewer1"},
+			expectState:       statusStatePending,
+			expectDescription: "LGTM: 1/2 (reviewer1); missing SIGs: sig2; can-merge: no",
+		},
+		{
+			name:              "Enough approvals",
+			labels:            []string{"status/LGT2"},
+			approvers:         []string{"reviewer1", "reviewer2"},
+			expectState:       statusStateSuccess,
+			expectDescription: "LGTM: 2/2 (reviewer1, reviewer2); can-merge: no",
+		},
+		{
+			name:              "Enough approvals but cannot merge when merge reports the status",
+			labels:            []string{"status/LGT2"},
+			approvers:         []string{"reviewer1", "reviewer2"},
+			mergeStatus:       true,
+			expectState:       statusStatePending,
+			expectDescription: "LGTM: 2/2 (reviewer1, reviewer2); can-merge: no",
+		},
+		{
+			name:              "Can merge when merge reports the status",
+			labels:            []string{"status/LGT2", CanMergeLabel},
+			approvers:         []string{"reviewer1", "reviewer2"},
+			mergeStatus:       true,
+			expectState:       statusStateSuccess,
+			expectDescription: "LGTM: 2/2 (reviewer1, reviewer2); can-merge: yes",
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			config := &Configuration{
+				TichiWebURL: "https://tichiWebLink",
+				TiCommunityMerge: []TiCommunityMerge{
+					{
+						Repos:        []string{"org/repo"},
+						ReviewStatus: tc.mergeStatus,
+					},
+				},
+			}
+			var labels []github.Label
+			for _, label := range tc.labels {
+				labels = append(labels, github.Label{Name: label})
+			}
+
+			status := config.ReviewStatus("org", "repo", 1, owners, labels, tc.approvers)
+			if status.Context != ReviewStatusContext {
+				t.Errorf("context mismatch: got %s, want %s", status.Context, ReviewStatusContext)
+			}
+			if status.State != tc.expectState {
+				t.Errorf("state mismatch: got %s, want %s", status.State, tc.expectState)
+			}
+			if status.Description != tc.expectDescription {
+				t.Errorf("description mismatch: got %q, want %q", status.Description, tc.expectDescription)
+			}
+			if status.TargetURL != "https://tichiWebLink/repos/org/repo/pulls/1/owners" {
+				t.Errorf("unexpected target URL: %s", status.TargetURL)
+			}
+		})
+	}
+}
+
+func TestReviewStatusTruncateDescription(t *testing.T) {
+	var approvers []string
+	for i := 0; i < 20; i++ {
+		approvers = append(approvers, "reviewer-with-a-long-name")
+	}
+	config := &Configuration{}
+	status := config.ReviewStatus("org", "repo", 1, &ownersclient.Owners{NeedsLgtm: 2}, nil, approvers)
+
+	if len([]rune(status.Description)) != maxStatusDescriptionLength {
+		t.Errorf("expected the description is truncated to %d, got %d",
+			maxStatusDescriptionLength, len(status.Description))
+	}
+	if !strings.HasSuffix(status.Description, "...") {
+		t.Errorf("expected the truncated description ends with ..., got %s", status.Description)
+	}
+}
+
+func TestReportReviewStatus(t *testing.T) {
+	fc := &fakegithub.FakeClient{
+		PullRequests: map[int]*github.PullRequest{
+			1: {Number: 1, Head: github.PullRequestBranch{SHA: "head-sha"}},
+		},
+		IssueLabelsExisting: []string{"org/repo#1:status/LGT1"},
+		CombinedStatuses:    map[string]*github.CombinedStatus{},
+	}
+	config := &Configuration{}
+
+	err := config.ReportReviewStatus(fc, "org", "repo", 1, &ownersclient.Owners{NeedsLgtm: 1},
+		[]string{"reviewer1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	statuses := fc.CreatedStatuses["head-sha"]
+	if len(statuses) != 1 {
+		t.Fatalf("expected one status on the head commit, got %v", fc.CreatedStatuses)
+	}
+	if statuses[0].State != statusStateSuccess || statuses[0].Context != ReviewStatusContext {
+		t.Errorf("unexpected status: %+v", statuses[0])
+	}
+}
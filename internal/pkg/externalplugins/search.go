@@ -0,0 +1,140 @@
+package externalplugins
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+)
+
+// graphqlQuerier performs GitHub GraphQL queries.
+type graphqlQuerier interface {
+	Query(context.Context, interface{}, map[string]interface{}) error
+}
+
+// PullRequest contains the brief information of a pull request found by searching.
+type PullRequest struct {
+	Number     githubql.Int
+	Repository struct {
+		Name  githubql.String
+		Owner struct {
+			Login githubql.String
+		}
+	}
+	Author struct {
+		Login githubql.String
+	}
+	BaseRef struct {
+		Name githubql.String
+	}
+	HeadRefOID githubql.String `graphql:"headRefOid"`
+	Labels     struct {
+		Nodes []struct {
+			Name githubql.String
+		}
+	} `graphql:"labels(first:100)"`
+}
+
+type searchQuery struct {
+	RateLimit struct {
+		Cost      githubql.Int
+		Remaining githubql.Int
+	}
+	Search struct {
+		PageInfo struct {
+			HasNextPage githubql.Boolean
+			EndCursor   githubql.String
+		}
+		Nodes []struct {
+			PullRequest PullRequest `graphql:"... on PullRequest"`
+		}
+	} `graphql:"search(type: ISSUE, first: 100, after: $searchCursor, query: $query)"`
+}
+
+type countQuery struct {
+	Search struct {
+		IssueCount githubql.Int
+	} `graphql:"search(type: ISSUE, first: 1, query: $query)"`
+}
+
+// openPullRequestsQuery returns the search query which matches the open pull requests of the orgs and repos.
+func openPullRequestsQuery(orgs, repos []string) string {
+	return pullRequestsQuery("archived:false is:pr is:open", orgs, repos)
+}
+
+// pullRequestsQuery returns the search query which matches the pull requests of the orgs and repos.
+func pullRequestsQuery(prefix string, orgs, repos []string) string {
+	var buf bytes.Buffer
+	fmt.Fprint(&buf, prefix)
+	for _, org := range orgs {
+		fmt.Fprintf(&buf, " org:\"%s\"", org)
+	}
+	for _, repo := range repos {
+		fmt.Fprintf(&buf, " repo:\"%s\"", repo)
+	}
+	return buf.String()
+}
+
+// SearchOpenPullRequests returns all open pull requests of the orgs and repos.
+func SearchOpenPullRequests(ctx context.Context, log *logrus.Entry, ghc graphqlQuerier,
+	orgs, repos []string) ([]PullRequest, error) {
+	q := openPullRequestsQuery(orgs, repos)
+
+	var ret []PullRequest
+	vars := map[string]interface{}{
+		"query":        githubql.String(q),
+		"searchCursor": (*githubql.String)(nil),
+	}
+	var totalCost int
+	var remaining int
+	for {
+		sq := searchQuery{}
+		if err := ghc.Query(ctx, &sq, vars); err != nil {
+			return nil, err
+		}
+		totalCost += int(sq.RateLimit.Cost)
+		remaining = int(sq.RateLimit.Remaining)
+		for _, n := range sq.Search.Nodes {
+			ret = append(ret, n.PullRequest)
+		}
+		if !sq.Search.PageInfo.HasNextPage {
+			break
+		}
+		vars["searchCursor"] = githubql.NewString(sq.Search.PageInfo.EndCursor)
+	}
+	log.Infof("Search for query \"%s\" cost %d point(s). %d remaining.", q, totalCost, remaining)
+	return ret, nil
+}
+
+// CountPendingReviewRequests returns the number of the open pull requests of the orgs and repos
+// which are waiting for the review of the user.
+func CountPendingReviewRequests(ctx context.Context, ghc graphqlQuerier, login string,
+	orgs, repos []string) (int, error) {
+	q := fmt.Sprintf("%s review-requested:%s", openPullRequestsQuery(orgs, repos), login)
+	cq := countQuery{}
+	if err := ghc.Query(ctx, &cq, map[string]interface{}{"query": githubql.String(q)}); err != nil {
+		return 0, err
+	}
+	return int(cq.Search.IssueCount), nil
+}
+
+// CountRecentReviewRequests returns the number of the pull requests of the orgs and repos created since the time,
+// which are waiting for or have the review of the user. It approximates how many reviews were requested from
+// the user recently, since the search can not find the review requests which have been removed.
+func CountRecentReviewRequests(ctx context.Context, ghc graphqlQuerier, login string,
+	orgs, repos []string, since time.Time) (int, error) {
+	prefix := pullRequestsQuery("archived:false is:pr", orgs, repos)
+	var count int
+	for _, qualifier := range []string{"review-requested", "reviewed-by"} {
+		q := fmt.Sprintf("%s %s:%s created:>=%s", prefix, qualifier, login, since.UTC().Format("2006-01-02"))
+		cq := countQuery{}
+		if err := ghc.Query(ctx, &cq, map[string]interface{}{"query": githubql.String(q)}); err != nil {
+			return 0, err
+		}
+		count += int(cq.Search.IssueCount)
+	}
+	return count, nil
+}
@@ -0,0 +1,147 @@
+package externalplugins
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+)
+
+type fakeQuerier struct {
+	pages   [][]PullRequest
+	queries []string
+}
+
+func (f *fakeQuerier) Query(_ context.Context, q interface{}, vars map[string]interface{}) error {
+	query, ok := q.(*searchQuery)
+	if !ok {
+		return errors.New("invalid query format")
+	}
+	f.queries = append(f.queries, string(vars["query"].(githubql.String)))
+
+	page := f.pages[len(f.queries)-1]
+	for _, pr := range page {
+		query.Search.Nodes = append(query.Search.Nodes, struct {
+			PullRequest PullRequest `graphql:"... on PullRequest"`
+		}{pr})
+	}
+	query.Search.PageInfo.HasNextPage = githubql.Boolean(len(f.queries) < len(f.pages))
+	return nil
+}
+
+func TestSearchOpenPullRequests(t *testing.T) {
+	newPullRequest := func(number int) PullRequest {
+		pr := PullRequest{Number: githubql.Int(number)}
+		pr.Repository.Name = "repo"
+		pr.Repository.Owner.Login = "org"
+		return pr
+	}
+
+	testcases := []struct {
+		name  string
+		orgs  []string
+		repos []string
+		pages [][]PullRequest
+
+		expectQuery   string
+		expectNumbers []int
+	}{
+		{
+			name:          "Single page",
+			orgs:          []string{"org"},
+			pages:         [][]PullRequest{{newPullRequest(1), newPullRequest(2)}},
+			expectQuery:   "archived:false is:pr is:open org:\"org\"",
+			expectNumbers: []int{1, 2},
+		},
+		{
+			name:          "Multiple pages",
+			orgs:          []string{"org"},
+			repos:         []string{"org2/repo"},
+			pages:         [][]PullRequest{{newPullRequest(1)}, {newPullRequest(3)}},
+			expectQuery:   "archived:false is:pr is:open org:\"org\" repo:\"org2/repo\"",
+			expectNumbers: []int{1, 3},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			fq := &fakeQuerier{pages: tc.pages}
+			prs, err := SearchOpenPullRequests(context.Background(), logrus.WithField("test", tc.name),
+				fq, tc.orgs, tc.repos)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if fq.queries[0] != tc.expectQuery {
+				t.Errorf("query mismatch: got %q, want %q", fq.queries[0], tc.expectQuery)
+			}
+
+			if len(prs) != len(tc.expectNumbers) {
+				t.Fatalf("PRs length mismatch: got %d, want %d", len(prs), len(tc.expectNumbers))
+			}
+			for i, pr := range prs {
+				if int(pr.Number) != tc.expectNumbers[i] {
+					t.Errorf("PR number mismatch: got %d, want %d", pr.Number, tc.expectNumbers[i])
+				}
+			}
+		})
+	}
+}
+
+type fakeCountQuerier struct {
+	count   int
+	query   string
+	queries []string
+}
+
+func (f *fakeCountQuerier) Query(_ context.Context, q interface{}, vars map[string]interface{}) error {
+	query, ok := q.(*countQuery)
+	if !ok {
+		return errors.New("invalid query format")
+	}
+	f.query = string(vars["query"].(githubql.String))
+	f.queries = append(f.queries, f.query)
+	query.Search.IssueCount = githubql.Int(f.count)
+	return nil
+}
+
+func TestCountPendingReviewRequests(t *testing.T) {
+	fq := &fakeCountQuerier{count: 3}
+	count, err := CountPendingReviewRequests(context.Background(), fq, "reviewer",
+		[]string{"org"}, []string{"org2/repo"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("count mismatch: got %d, want 3", count)
+	}
+	expectQuery := "archived:false is:pr is:open org:\"org\" repo:\"org2/repo\" review-requested:reviewer"
+	if fq.query != expectQuery {
+		t.Errorf("query mismatch: got %q, want %q", fq.query, expectQuery)
+	}
+}
+
+func TestCountRecentReviewRequests(t *testing.T) {
+	fq := &fakeCountQuerier{count: 3}
+	since := time.Date(2021, 5, 25, 12, 0, 0, 0, time.UTC)
+	count, err := CountRecentReviewRequests(context.Background(), fq, "reviewer",
+		[]string{"org"}, []string{"org2/repo"}, since)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 6 {
+		t.Errorf("count mismatch: got %d, want 6", count)
+	}
+	expectQueries := []string{
+		"archived:false is:pr org:\"org\" repo:\"org2/repo\" review-requested:reviewer created:>=2021-05-25",
+		"archived:false is:pr org:\"org\" repo:\"org2/repo\" reviewed-by:reviewer created:>=2021-05-25",
+	}
+	if !reflect.DeepEqual(fq.queries, expectQueries) {
+		t.Errorf("queries mismatch: got %q, want %q", fq.queries, expectQueries)
+	}
+}
@@ -0,0 +1,58 @@
+package externalplugins
+
+import (
+	"github.com/sirupsen/logrus"
+	"k8s.io/test-infra/prow/github"
+)
+
+// StickyCommentClient is the GitHub client used to maintain the sticky comments.
+type StickyCommentClient interface {
+	CreateComment(org, repo string, number int, comment string) error
+	EditComment(org, repo string, id int, comment string) error
+	DeleteComment(org, repo string, id int) error
+}
+
+// FilterStickyComments returns the comments created by the bot which are recognized by isSticky.
+func FilterStickyComments(comments []github.IssueComment, isBot func(string) bool,
+	isSticky func(body string) bool) []*github.IssueComment {
+	var filtered []*github.IssueComment
+	for _, comment := range comments {
+		c := comment
+		if isBot(c.User.Login) && isSticky(c.Body) {
+			filtered = append(filtered, &c)
+		}
+	}
+	return filtered
+}
+
+// UpdateStickyComment makes the body the only sticky comment of the issue.
+//
+// The latest one of the existing sticky comments is edited in place and the others are deleted,
+// so that the participants are not notified again and the position of the comment is kept.
+// If recreate is true, a new comment is created and all the existing sticky comments are deleted.
+func UpdateStickyComment(gc StickyCommentClient, org, repo string, number int,
+	stickyComments []*github.IssueComment, body string, recreate bool, log *logrus.Entry) error {
+	staleComments := stickyComments
+	if recreate || len(stickyComments) == 0 {
+		if err := gc.CreateComment(org, repo, number, body); err != nil {
+			return err
+		}
+	} else {
+		latest := stickyComments[len(stickyComments)-1]
+		staleComments = stickyComments[:len(stickyComments)-1]
+		if latest.Body != body {
+			if err := gc.EditComment(org, repo, latest.ID, body); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Clean up the stale comments after the sticky comment is updated.
+	for _, comment := range staleComments {
+		if err := gc.DeleteComment(org, repo, comment.ID); err != nil {
+			log.WithError(err).Errorf("Failed to delete comment from %s/%s#%d, ID: %d.",
+				org, repo, number, comment.ID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package externalplugins
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+)
+
+type fakeStickyCommentClient struct {
+	*fakegithub.FakeClient
+	IssueCommentsEdited []string
+}
+
+func (f *fakeStickyCommentClient) EditComment(org, repo string, id int, comment string) error {
+	f.IssueCommentsEdited = append(f.IssueCommentsEdited, fmt.Sprintf("%s/%s#%d:%s", org, repo, id, comment))
+	return nil
+}
+
+func TestFilterStickyComments(t *testing.T) {
+	comments := []github.IssueComment{
+		{ID: 1, Body: "sticky", User: github.User{Login: "bot"}},
+		{ID: 2, Body: "sticky", User: github.User{Login: "user"}},
+		{ID: 3, Body: "random", User: github.User{Login: "bot"}},
+		{ID: 4, Body: "sticky again", User: github.User{Login: "bot"}},
+	}
+	isBot := func(login string) bool {
+		return login == "bot"
+	}
+	isSticky := func(body string) bool {
+		return strings.HasPrefix(body, "sticky")
+	}
+
+	var ids []int
+	for _, comment := range FilterStickyComments(comments, isBot, isSticky) {
+		ids = append(ids, comment.ID)
+	}
+	if !reflect.DeepEqual(ids, []int{1, 4}) {
+		t.Errorf("sticky comments mismatch: got %v, want %v", ids, []int{1, 4})
+	}
+}
+
+func TestUpdateStickyComment(t *testing.T) {
+	testcases := []struct {
+		name     string
+		comments []*github.IssueComment
+		recreate bool
+
+		expectAdded   []string
+		expectEdited  []string
+		expectDeleted []string
+	}{
+		{
+			name:        "No sticky comment",
+			expectAdded: []string{"org/repo#1:new"},
+		},
+		{
+			name:         "Edit the sticky comment",
+			comments:     []*github.IssueComment{{ID: 1, Body: "old"}},
+			expectEdited: []string{"org/repo#1:new"},
+		},
+		{
+			name:     "Sticky comment is up to date",
+			comments: []*github.IssueComment{{ID: 1, Body: "new"}},
+		},
+		{
+			name:          "Edit the latest sticky comment and delete the others",
+			comments:      []*github.IssueComment{{ID: 1, Body: "old"}, {ID: 2, Body: "old"}},
+			expectEdited:  []string{"org/repo#2:new"},
+			expectDeleted: []string{"org/repo#1"},
+		},
+		{
+			name:          "Recreate the sticky comment",
+			comments:      []*github.IssueComment{{ID: 1, Body: "old"}, {ID: 2, Body: "new"}},
+			recreate:      true,
+			expectAdded:   []string{"org/repo#1:new"},
+			expectDeleted: []string{"org/repo#1", "org/repo#2"},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			var comments []github.IssueComment
+			for _, comment := range tc.comments {
+				comments = append(comments, *comment)
+			}
+			fc := &fakeStickyCommentClient{FakeClient: &fakegithub.FakeClient{
+				IssueComments:  map[int][]github.IssueComment{1: comments},
+				IssueCommentID: len(comments),
+			}}
+
+			err := UpdateStickyComment(fc, "org", "repo", 1, tc.comments, "new", tc.recreate,
+				logrus.WithField("plugin", "sticky-comment"))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(fc.IssueCommentsAdded, tc.expectAdded) {
+				t.Errorf("added comments mismatch: got %v, want %v", fc.IssueCommentsAdded, tc.expectAdded)
+			}
+			if !reflect.DeepEqual(fc.IssueCommentsEdited, tc.expectEdited) {
+				t.Errorf("edited comments mismatch: got %v, want %v", fc.IssueCommentsEdited, tc.expectEdited)
+			}
+			if !reflect.DeepEqual(fc.IssueCommentsDeleted, tc.expectDeleted) {
+				t.Errorf("deleted comments mismatch: got %v, want %v", fc.IssueCommentsDeleted, tc.expectDeleted)
+			}
+		})
+	}
+}
@@ -23,13 +23,20 @@ const (
 
 var sleep = time.Sleep
 
-var configInfoAutoUpdatedMessagePrefix = "Auto updated message: "
+var (
+	configInfoAutoUpdatedMessagePrefix = "Auto updated message: "
+	configInfoEditMessage              = "The message is edited in place instead of creating a new message " +
+		"and deleting the old ones."
+)
 
 type githubClient interface {
 	CreateComment(org, repo string, number int, comment string) error
 	BotUserChecker() (func(candidate string) bool, error)
+	EditComment(org, repo string, ID int, comment string) error
+	DeleteComment(org, repo string, ID int) error
 	DeleteStaleComments(org, repo string, number int,
 		comments []github.IssueComment, isStale func(github.IssueComment) bool) error
+	ListIssueComments(org, repo string, number int) ([]github.IssueComment, error)
 	GetPullRequest(org, repo string, number int) (*github.PullRequest, error)
 	GetSingleCommit(org, repo, SHA string) (github.RepositoryCommit, error)
 	ListPRCommits(org, repo string, number int) ([]github.RepositoryCommit, error)
@@ -106,6 +113,9 @@ func HelpProvider(epa *externalplugins.ConfigAgent) func(
 			}
 
 			configInfoStrings = append(configInfoStrings, "<li>"+configInfoAutoUpdatedMessagePrefix+opts.Message+"</li>")
+			if opts.EditMessage {
+				configInfoStrings = append(configInfoStrings, "<li>"+configInfoEditMessage+"</li>")
+			}
 
 			configInfoStrings = append(configInfoStrings, "</ul>")
 			if isConfigured {
@@ -200,7 +210,7 @@ func handlePullRequest(log *logrus.Entry, ghc githubClient,
 	}
 
 	lastCommitIndex := len(prCommits) - 1
-	return takeAction(log, ghc, org, repo, number, &prCommits[lastCommitIndex].SHA, pr.User.Login, tars.Message)
+	return takeAction(log, ghc, org, repo, number, &prCommits[lastCommitIndex].SHA, pr.User.Login, tars.Message, cfg)
 }
 
 // HandleAll checks all orgs and repos that enabled this plugin for open PRs to
@@ -292,7 +302,7 @@ func handleAll(log *logrus.Entry, ghc githubClient, pr *pullRequest, cfg *extern
 
 	lastCommitIndex := 0
 	lastCommitSHA := string(pr.Commits.Nodes[lastCommitIndex].Commit.OID)
-	return takeAction(log, ghc, org, repo, number, &lastCommitSHA, string(pr.Author.Login), tars.Message)
+	return takeAction(log, ghc, org, repo, number, &lastCommitSHA, string(pr.Author.Login), tars.Message, cfg)
 }
 
 func search(ctx context.Context, log *logrus.Entry, ghc githubClient, q string) ([]pullRequest, error) {
@@ -324,14 +334,15 @@ func search(ctx context.Context, log *logrus.Entry, ghc githubClient, q string)
 
 // takeAction updates the PR and comment ont it.
 func takeAction(log *logrus.Entry, ghc githubClient, org, repo string, num int, expectedHeadSha *string,
-	author string, message string) error {
+	author string, message string, cfg *externalplugins.Configuration) error {
 	botUserChecker, err := ghc.BotUserChecker()
 	if err != nil {
 		return err
 	}
 	needsReply := len(message) != 0
+	recreate := !cfg.TarsFor(org, repo).EditMessage
 
-	if needsReply {
+	if needsReply && recreate {
 		err = ghc.DeleteStaleComments(org, repo, num, nil, shouldPrune(botUserChecker, message))
 		if err != nil {
 			return err
@@ -343,14 +354,27 @@ func takeAction(log *logrus.Entry, ghc githubClient, org, repo string, num int,
 	if err != nil {
 		return err
 	}
-	if needsReply {
+	if !needsReply {
+		return nil
+	}
+
+	msg := cfg.FormatSimpleResponse(org, repo, author, message)
+	var messages []*github.IssueComment
+	if !recreate {
+		comments, err := ghc.ListIssueComments(org, repo, num)
+		if err != nil {
+			return err
+		}
+		messages = externalplugins.FilterStickyComments(comments, botUserChecker, func(body string) bool {
+			return strings.Contains(body, message)
+		})
+	}
+	if len(messages) == 0 {
 		// Delay the reply because we may trigger the test in the reply.
 		// See: https://github.com/ti-community-infra/tichi/issues/181.
 		sleep(time.Second * 5)
-		msg := externalplugins.FormatSimpleResponse(author, message)
-		return ghc.CreateComment(org, repo, num, msg)
 	}
-	return nil
+	return externalplugins.UpdateStickyComment(ghc, org, repo, num, messages, msg, recreate, log)
 }
 
 func shouldPrune(isBot func(string) bool, message string) func(github.IssueComment) bool {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -32,14 +33,18 @@ type fakeGithub struct {
 
 	outOfDate bool
 
+	// comments are keyed by the PR number.
+	comments map[int][]github.IssueComment
+
 	// The following are maps are keyed using 'testKey'
-	commentCreated, commentDeleted map[string]bool
+	commentCreated, commentEdited, commentDeleted map[string]bool
 }
 
 func newFakeGithubClient(prs []pullRequest, pr *github.PullRequest,
 	baseCommit github.RepositoryCommit, prCommits []github.RepositoryCommit, outOfDate bool) *fakeGithub {
 	f := &fakeGithub{
 		commentCreated: make(map[string]bool),
+		commentEdited:  make(map[string]bool),
 		commentDeleted: make(map[string]bool),
 		pr:             pr,
 		baseCommit:     baseCommit,
@@ -68,6 +73,36 @@ func (f *fakeGithub) CreateComment(org, repo string, number int, comment string)
 	return nil
 }
 
+func (f *fakeGithub) EditComment(org, repo string, id int, comment string) error {
+	for number, comments := range f.comments {
+		for i := range comments {
+			if comments[i].ID == id {
+				comments[i].Body = comment
+				f.commentEdited[testKey(org, repo, number)] = true
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("could not find issue comment %d", id)
+}
+
+func (f *fakeGithub) DeleteComment(org, repo string, id int) error {
+	for number, comments := range f.comments {
+		for i := range comments {
+			if comments[i].ID == id {
+				f.comments[number] = append(comments[:i], comments[i+1:]...)
+				f.commentDeleted[testKey(org, repo, number)] = true
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("could not find issue comment %d", id)
+}
+
+func (f *fakeGithub) ListIssueComments(org, repo string, number int) ([]github.IssueComment, error) {
+	return f.comments[number], nil
+}
+
 func (f *fakeGithub) DeleteStaleComments(org, repo string, number int,
 	comments []github.IssueComment, isStale func(github.IssueComment) bool) error {
 	f.commentDeleted[testKey(org, repo, number)] = true
@@ -679,6 +714,103 @@ func TestHandleAll(t *testing.T) {
 	}
 }
 
+func TestTakeAction(t *testing.T) {
+	oldSleep := sleep
+	sleep = func(time.Duration) {}
+	defer func() { sleep = oldSleep }()
+
+	message := "updated"
+	botMessage := func(id int, to string) github.IssueComment {
+		body := (&externalplugins.Configuration{}).FormatSimpleResponse("org", "repo", to, message)
+		return github.IssueComment{ID: id, Body: body, User: github.User{Login: "tichi"}}
+	}
+
+	testcases := []struct {
+		name        string
+		comments    []github.IssueComment
+		editMessage bool
+
+		expectComment  bool
+		expectEdit     bool
+		expectDeletion bool
+		expectMessages []string
+	}{
+		{
+			name:           "No message, create it",
+			comments:       []github.IssueComment{{ID: 1, Body: "random", User: github.User{Login: "user"}}},
+			editMessage:    true,
+			expectComment:  true,
+			expectMessages: []string{"random"},
+		},
+		{
+			name:           "Existing message, keep it",
+			comments:       []github.IssueComment{botMessage(1, "author")},
+			editMessage:    true,
+			expectMessages: []string{botMessage(1, "author").Body},
+		},
+		{
+			name:           "Outdated message, edit it",
+			comments:       []github.IssueComment{botMessage(1, "previous-author")},
+			editMessage:    true,
+			expectEdit:     true,
+			expectMessages: []string{botMessage(1, "author").Body},
+		},
+		{
+			name: "Duplicated messages, edit the latest one and delete the others",
+			comments: []github.IssueComment{
+				botMessage(1, "author"),
+				botMessage(2, "previous-author"),
+			},
+			editMessage:    true,
+			expectEdit:     true,
+			expectDeletion: true,
+			expectMessages: []string{botMessage(2, "author").Body},
+		},
+		{
+			name:           "Recreate the message",
+			comments:       []github.IssueComment{botMessage(1, "author")},
+			expectComment:  true,
+			expectDeletion: true,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			fc := newFakeGithubClient(nil, getPullRequest(), github.RepositoryCommit{}, nil, true)
+			fc.comments = map[int][]github.IssueComment{5: tc.comments}
+			cfg := &externalplugins.Configuration{}
+			cfg.TiCommunityTars = []externalplugins.TiCommunityTars{
+				{
+					Repos:       []string{"org/repo"},
+					Message:     message,
+					EditMessage: tc.editMessage,
+				},
+			}
+
+			err := takeAction(logrus.WithField("plugin", PluginName), fc, "org", "repo", 5, nil,
+				"author", message, cfg)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v.", err)
+			}
+
+			fc.compareExpected(t, "org", "repo", 5, tc.expectComment, tc.expectDeletion, true)
+			if edited := fc.commentEdited[testKey("org", "repo", 5)]; edited != tc.expectEdit {
+				t.Errorf("Expected edit %v, but got %v.", tc.expectEdit, edited)
+			}
+			if tc.editMessage {
+				var messages []string
+				for _, comment := range fc.comments[5] {
+					messages = append(messages, comment.Body)
+				}
+				if !reflect.DeepEqual(messages, tc.expectMessages) {
+					t.Errorf("Mismatch messages expect %v, but got %v.", tc.expectMessages, messages)
+				}
+			}
+		})
+	}
+}
+
 func TestShouldPrune(t *testing.T) {
 	message := "updated"
 	isBot := func(candidate string) bool {
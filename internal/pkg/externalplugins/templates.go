@@ -0,0 +1,522 @@
+package externalplugins
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Supported locales of the built-in message templates.
+const (
+	// LocaleEnglish is the English locale, it is the default locale.
+	LocaleEnglish = "en"
+	// LocaleChinese is the Simplified Chinese locale.
+	LocaleChinese = "zh"
+)
+
+// Names of the messages that can be customized by templates.
+const (
+	// MessageAboutThisBot explains how to interact with the bot.
+	MessageAboutThisBot = "about_this_bot"
+	// MessageInResponseTo introduces the quoted comment that the bot responds to.
+	MessageInResponseTo = "in_response_to"
+
+	// MessageLgtmReviewNotification is the review notification of the lgtm plugin.
+	MessageLgtmReviewNotification = "lgtm_review_notification"
+	// MessageLgtmSelfApproval responds to the PR author who wants to LGTM their own PR.
+	MessageLgtmSelfApproval = "lgtm_self_approval"
+	// MessageLgtmOnlyReviewers responds to the user who wants to LGTM but is not a reviewer.
+	MessageLgtmOnlyReviewers = "lgtm_only_reviewers"
+	// MessageLgtmCancelOnlyReviewers responds to the user who wants to cancel LGTM but is not allowed.
+	MessageLgtmCancelOnlyReviewers = "lgtm_cancel_only_reviewers"
+	// MessageLgtmApprovalsReset notifies that the approvals are reset because of new commits.
+	MessageLgtmApprovalsReset = "lgtm_approvals_reset"
+
+	// MessageMergeOnlyCommitters responds to the user who wants to merge but is not a committer.
+	MessageMergeOnlyCommitters = "merge_only_committers"
+	// MessageMergeCancelOnlyCommitters responds to the user who wants to cancel merge but is not allowed.
+	MessageMergeCancelOnlyCommitters = "merge_cancel_only_committers"
+	// MessageMergeNeedsLgtm responds to the committer who wants to merge a PR without enough LGTMs.
+	MessageMergeNeedsLgtm = "merge_needs_lgtm"
+	// MessageMergeAccepted notifies that the PR is accepted and the 'can-merge' label is added.
+	MessageMergeAccepted = "merge_accepted"
+	// MessageMergeCanceledByPush notifies that the merge is canceled because of new commits.
+	MessageMergeCanceledByPush = "merge_canceled_by_push"
+	// MessageMergeCanceledByApprovals notifies that the merge is canceled because the approvals of the PR
+	// no longer satisfy the approval rules.
+	MessageMergeCanceledByApprovals = "merge_canceled_by_approvals"
+	// MessageMergeChecksNotPassed responds to the committer who wants to merge a PR whose required checks
+	// have not passed.
+	MessageMergeChecksNotPassed = "merge_checks_not_passed"
+	// MessageMergeMethodNotAllowed responds to the committer who chooses a merge method not allowed by the repo.
+	MessageMergeMethodNotAllowed = "merge_method_not_allowed"
+	// MessageMergeQueueStatus reports the position of the PR in the merge queue.
+	MessageMergeQueueStatus = "merge_queue_status"
+	// MessageMergeQueueMerged notifies that the PR is merged by the merge queue.
+	MessageMergeQueueMerged = "merge_queue_merged"
+	// MessageMergeQueueRemoved notifies that the PR is removed from the merge queue.
+	MessageMergeQueueRemoved = "merge_queue_removed"
+	// MessageMergeFrozen responds to the committer who wants to merge a PR to a frozen branch.
+	MessageMergeFrozen = "merge_frozen"
+	// MessageMergeFrozenLabelRemoved notifies that the 'can-merge' label is removed because the freeze starts.
+	MessageMergeFrozenLabelRemoved = "merge_frozen_label_removed"
+	// MessageMergeFreezeAnnouncement announces that the freeze starts or ends on the tracking issue.
+	MessageMergeFreezeAnnouncement = "merge_freeze_announcement"
+	// MessageMergeFreezeOnlyTrusted responds to the user who wants to freeze but is not trusted.
+	MessageMergeFreezeOnlyTrusted = "merge_freeze_only_trusted"
+	// MessageMergeDependencies shows the status of the dependencies of the PR.
+	MessageMergeDependencies = "merge_dependencies"
+	// MessageMergeWaitingForDependencies notifies that the 'can-merge' label is withheld until the dependencies
+	// are merged.
+	MessageMergeWaitingForDependencies = "merge_waiting_for_dependencies"
+	// MessageMergeDependsOnOnlyAuthorAndCommitters responds to the user who wants to change the dependencies
+	// but is neither the PR author nor a committer.
+	MessageMergeDependsOnOnlyAuthorAndCommitters = "merge_depends_on_only_author_and_committers"
+	// MessageMergeAutoMerge shows whether the auto-merge is enabled for the PR.
+	MessageMergeAutoMerge = "merge_auto_merge"
+	// MessageMergeAutoMergeOnlyAuthorAndCommitters responds to the user who wants to change the auto-merge
+	// but is neither the PR author nor a committer.
+	MessageMergeAutoMergeOnlyAuthorAndCommitters = "merge_auto_merge_only_author_and_committers"
+	// MessageMergeHelpConfig describes the configurations of the repo in the help of the plugin.
+	MessageMergeHelpConfig = "merge_help_config"
+
+	// MessageCherrypickerOnlyMembers responds to the user who wants to cherry-pick but is not an org member.
+	MessageCherrypickerOnlyMembers = "cherrypicker_only_members"
+	// MessageCherrypickerScheduled responds to the `/cherry-pick` on the PR which has not been merged.
+	MessageCherrypickerScheduled = "cherrypicker_scheduled"
+	// MessageCherrypickerCreated notifies that the cherry-pick PR is opened.
+	MessageCherrypickerCreated = "cherrypicker_created"
+	// MessageCherrypickerFailed notifies that the PR failed to be cherry-picked.
+	MessageCherrypickerFailed = "cherrypicker_failed"
+	// MessageCherrypickerPullRequestBody is the description of the cherry-pick PR.
+	MessageCherrypickerPullRequestBody = "cherrypicker_pull_request_body"
+
+	// MessageBlunderbussReviewersChosen explains why the reviewers are chosen by their expertise.
+	MessageBlunderbussReviewersChosen = "blunderbuss_reviewers_chosen"
+	// MessageBlunderbussReviewReminder pings the requested reviewers who have not reviewed the PR in time.
+	MessageBlunderbussReviewReminder = "blunderbuss_review_reminder"
+	// MessageBlunderbussReviewEscalated notifies that the review of the PR is escalated.
+	MessageBlunderbussReviewEscalated = "blunderbuss_review_escalated"
+	// MessageBlunderbussWelcome welcomes the first-time contributor and introduces the mentor.
+	MessageBlunderbussWelcome = "blunderbuss_welcome"
+	// MessageBlunderbussReviewerPreferences shows the preferences of the reviewer.
+	MessageBlunderbussReviewerPreferences = "blunderbuss_reviewer_preferences"
+
+	// MessageLabelNotSet responds to the user who wants to remove labels that are not set.
+	MessageLabelNotSet = "label_not_set"
+	// MessageLabelBlockerReason explains why the label blocker undoes the label operation.
+	MessageLabelBlockerReason = "label_blocker_reason"
+)
+
+// templateFuncs contains the functions that can be used in the message templates.
+var templateFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+// builtinTemplates contains the built-in message templates of each locale.
+// nolint:lll
+var builtinTemplates = map[string]map[string]string{
+	LocaleEnglish: {
+		MessageAboutThisBot: "Instructions for interacting with me using PR comments are available " +
+			"[here]({{ .commandHelpLink }}).  " +
+			"If you have questions or suggestions related to my behavior, " +
+			"please file an issue against the " +
+			"[ti-community-infra/tichi]" +
+			"(https://github.com/ti-community-infra/tichi/issues/new?title=Prow%20issue:) repository.",
+		MessageInResponseTo: "In response to [this]({{ .url }}):",
+
+		MessageLgtmReviewNotification: `
+{{if .reviewers}}
+This pull request has been approved by:
+
+{{if .affiliationGroups}}{{range .affiliationGroups}}**{{if .Affiliation}}{{ .Affiliation }}{{else}}Unknown affiliation{{end}}**
+{{range .Approvers}}- {{.}}` + "\n" + `{{end}}
+{{end}}{{else}}{{range $index, $reviewer := .reviewers}}- {{$reviewer}}` + "\n" + `{{end}}{{end}}
+
+{{else}}
+This pull request has not been approved.
+{{end}}{{if .missingAffiliations}}
+Approvals from {{ .missingAffiliations }} more affiliation(s) are still required.
+{{end}}
+
+To complete the [pull request process]({{ .prProcessLink }}), please ask the reviewers in the [list]({{ .ownersLink }}) to review by filling ` + "`/cc @reviewer`" + ` in the comment.
+{{if .assignedCommitters}}This pull request has been assigned to {{range $index, $committer := .assignedCommitters}}{{if $index}}, {{end}}@{{$committer}}{{end}} to help you merge this pull request.
+{{else if .autoAssignCommitter}}After your PR has acquired the required number of LGTMs, a committer in the [list]({{ .ownersLink }}) will be assigned automatically to help you merge this pull request.
+{{else}}After your PR has acquired the required number of LGTMs, you can assign this pull request to the committer in the [list]({{ .ownersLink }}) by filling  ` + "`/assign @committer`" + ` in the comment to help you merge this pull request.
+{{end}}
+The full list of commands accepted by this bot can be found [here]({{ .commandHelpLink }}?repo={{ .org }}%2F{{ .repo }}).
+
+<details>
+
+Reviewer can indicate their review by writing ` + "`/lgtm`" + ` in a comment.
+Reviewer can cancel approval by writing ` + "`/lgtm cancel`" + ` in a comment.
+</details>`,
+		MessageLgtmSelfApproval:        "you cannot `/lgtm` your own PR.",
+		MessageLgtmOnlyReviewers:       "`/lgtm` is only allowed for the reviewers in [list]({{ .ownersLink }}).",
+		MessageLgtmCancelOnlyReviewers: "`/lgtm cancel` is only allowed for the PR author or the reviewers in [list]({{ .ownersLink }}).",
+		MessageLgtmApprovalsReset: "New commits have been pushed, so the approvals" +
+			"{{if .reviewers}} from {{ join .reviewers \", \" }}{{end}} have been reset.",
+
+		MessageMergeOnlyCommitters:       "`/merge` is only allowed for the committers in [list]({{ .ownersLink }}).",
+		MessageMergeCancelOnlyCommitters: "`/merge cancel` is only allowed for the PR author and the committers in [list]({{ .ownersLink }}).",
+		MessageMergeNeedsLgtm:            "`/merge` in this pull request requires {{ .needsLgtm }} `/lgtm`{{ if .requiredCommitterLgtm }}, including {{ .requiredCommitterLgtm }} from committers while it has {{ .committerLgtm }}{{ end }}{{ if .missingAffiliations }}, and approvals from {{ .missingAffiliations }} other affiliations{{ end }}.",
+		MessageMergeCanceledByPush:       "Merge canceled because a new commit is pushed.",
+		MessageMergeChecksNotPassed:      "`/merge` in this pull request requires the required checks to pass.{{ if .failedContexts }} Failed: {{ join .failedContexts \", \" }}.{{ end }}{{ if .pendingContexts }} Pending: {{ join .pendingContexts \", \" }}.{{ end }}{{ if .labelWhenChecksPass }} The pull request will be accepted automatically once the required checks pass.{{ end }}",
+		MessageMergeAccepted: "This pull request has been accepted and is ready to merge. " +
+			"<details>Tree hash: {{ .treeHash }} Base commit: {{ .baseSHA }} Head commit: {{ .headSHA }}</details>",
+		MessageMergeCanceledByApprovals: "The `{{ .label }}` label{{ if .addedBy }} added by {{ .addedBy }}{{ end }} " +
+			"has been removed because the approvals no longer satisfy the requirements: " +
+			"it has {{ .currentLgtm }} LGTM while {{ .needsLgtm }} LGTM are required" +
+			"{{ if .requiredCommitterLgtm }}, {{ .committerLgtm }} LGTM from committers while " +
+			"{{ .requiredCommitterLgtm }} are required{{ end }}" +
+			"{{ if .missingAffiliations }}, and it still needs approvals from {{ .missingAffiliations }} " +
+			"other affiliations{{ end }}. Please `/merge` again after the pull request is approved.",
+		MessageMergeMethodNotAllowed: "`/merge {{ .method }}` is not allowed in this repository." +
+			"{{ if .methods }} The allowed merge methods are: {{ join .methods \", \" }}.{{ end }}",
+		MessageMergeQueueStatus: "{{ if .testing }}This pull request is being tested in the merge queue of `{{ .branch }}`" +
+			"{{ if .others }} together with {{ join .others \", \" }}{{ end }}." +
+			"{{ else }}This pull request is number {{ .position }} in the merge queue of `{{ .branch }}`.{{ end }}",
+		MessageMergeQueueMerged: "This pull request has been merged by the merge queue.",
+		MessageMergeQueueRemoved: "This pull request is removed from the merge queue because " +
+			"{{ if .conflict }}it conflicts with `{{ .branch }}`" +
+			"{{ else }}the required checks did not pass on the staging branch: {{ join .contexts \", \" }}{{ end }}. " +
+			"The `{{ .label }}` label has been removed, please `/merge` again after the problem is solved.",
+		MessageMergeFrozen: "`/merge` is not allowed because `{{ .branch }}` is frozen by the code freeze " +
+			"{{ .name }}, see {{ .trackingIssue }}." +
+			"{{ if .exemptLabels }} The pull requests with any of these labels can still be merged: " +
+			"{{ join .exemptLabels \", \" }}.{{ end }}",
+		MessageMergeFrozenLabelRemoved: "The `{{ .label }}` label has been removed because `{{ .branch }}` " +
+			"is frozen by the code freeze {{ .name }}, see {{ .trackingIssue }}. " +
+			"Please `/merge` again after the freeze is lifted.",
+		MessageMergeFreezeAnnouncement: "{{ if .active }}The code freeze {{ .name }} has started, " +
+			"the pull requests to the branches matching {{ join .branches \", \" }} can not be merged." +
+			"{{ if .end }} The freeze is scheduled to end at {{ .end }}.{{ end }}" +
+			"{{ else }}The code freeze {{ .name }} has ended.{{ end }}",
+		MessageMergeFreezeOnlyTrusted: "`/freeze` is only allowed for the members of the teams: " +
+			"{{ join .teams \", \" }}.",
+		MessageMergeDependencies: "This pull request depends on:\n" +
+			"{{ range .dependencies }}\n- {{ .Ref }}: {{ .State }}{{ end }}\n\n" +
+			"The `{{ .label }}` label is withheld until all the dependencies are merged." +
+			"{{ if .closed }}\n\n{{ join .closed \", \" }} have been closed without merging, " +
+			"please remove them from the dependencies if they are no longer needed.{{ end }}" +
+			"{{ if .unknown }}\n\n**Error**: failed to get {{ join .unknown \", \" }}, they are ignored. " +
+			"Please check whether the references are pull requests.{{ end }}",
+		MessageMergeWaitingForDependencies: "{{ if .removed }}The `{{ .label }}` label has been removed because " +
+			"{{ else }}`/merge` has been accepted, but {{ end }}these dependencies are not merged: " +
+			"{{ join .dependencies \", \" }}. The `{{ .label }}` label will be added once they are merged.",
+		MessageMergeDependsOnOnlyAuthorAndCommitters: "`/depends-on` is only allowed for the PR author " +
+			"and the committers in [list]({{ .ownersLink }}).",
+		MessageMergeAutoMerge: "{{ if .enabled }}Auto-merge is enabled, the `{{ .label }}` label will be added " +
+			"automatically once the approvals are satisfied and the required checks pass. " +
+			"Use `/auto-merge cancel` to stop it." +
+			"{{ else }}Auto-merge is disabled, a committer needs to comment `/merge`.{{ end }}",
+		MessageMergeAutoMergeOnlyAuthorAndCommitters: "`/auto-merge` is only allowed for the PR author " +
+			"and the committers in [list]({{ .ownersLink }}).",
+		MessageMergeHelpConfig: "The plugin has these configurations:<ul>" +
+			"{{ if .storeTreeHash }}\n<li>New commits will not remove the 'can-merge' label " +
+			"if the diff of the PR against its base is not changed.</li>{{ end }}" +
+			"{{ if .reviewStatus }}\n<li>The review progress including the 'can-merge' state is published " +
+			"as the '{{ .reviewStatusContext }}' commit status.</li>{{ end }}" +
+			"{{ if .recreateNotification }}\n<li>A new merge notification is created and the old ones are deleted " +
+			"instead of editing the notification in place.</li>{{ end }}" +
+			"{{ if .requireContexts }}\n<li>The 'can-merge' label is added only when these checks have passed: " +
+			"{{ join .requireContexts \", \" }}.</li>{{ end }}" +
+			"{{ if .labelWhenChecksPass }}\n<li>The 'can-merge' label is added automatically once the required checks " +
+			"of a PR waiting for them have passed.</li>{{ end }}" +
+			"{{ if .mergeMethods }}\n<li>The merge method can be chosen by `/merge <method>` from: " +
+			"{{ join .mergeMethods \", \" }}.</li>{{ end }}" +
+			"{{ range .freezes }}\n<li>The branches matching {{ join .Branches \", \" }} are frozen by the code freeze " +
+			"{{ .Name }}, see {{ .TrackingIssue }}.</li>{{ end }}" +
+			"{{ if .trackDependencies }}\n<li>The 'can-merge' label is withheld until all the PRs which the PR " +
+			"depends on are merged.</li>{{ end }}" +
+			"{{ if .autoMerge }}\n<li>The 'can-merge' label is added automatically once the approvals are satisfied " +
+			"and the required checks pass, unless it is disabled by `/auto-merge cancel`.</li>{{ end }}" +
+			"{{ if .mergeQueue }}\n<li>The PRs with the 'can-merge' label are tested in batches of at most " +
+			"{{ .maxBatchSize }} PRs on the staging branches prefixed with {{ .stagingBranchPrefix }}, " +
+			"and merged by the merge queue.</li>{{ end }}" +
+			"{{ if .canMergeLabel }}\n<li>The label which indicates the PR can be merged is " +
+			"{{ .canMergeLabel }}.</li>{{ end }}\n</ul>",
+
+		MessageCherrypickerOnlyMembers: "`/cherry-pick` is only allowed for the members of {{ .org }}.",
+		MessageCherrypickerScheduled:   "This pull request will be cherry-picked to `{{ .branch }}` after it is merged.",
+		MessageCherrypickerCreated: "{{ if .conflictFiles }}The cherry-pick to `{{ .branch }}` conflicts, " +
+			"the draft pull request #{{ .number }} is opened with the conflicts, please resolve them." +
+			"{{ else }}The cherry-pick to `{{ .branch }}` is opened in #{{ .number }}.{{ end }}",
+		MessageCherrypickerFailed: "Failed to cherry-pick this pull request to `{{ .branch }}`: {{ .error }}",
+		MessageCherrypickerPullRequestBody: "This is an automated cherry-pick of #{{ .number }} to `{{ .branch }}`." +
+			"{{ if .conflictFiles }}\n\nThe cherry-pick conflicts, the conflict markers have been committed in " +
+			"these files, please resolve them and mark the pull request as ready for review:\n" +
+			"{{ range .conflictFiles }}\n- `{{ . }}`{{ end }}{{ end }}" +
+			"{{ if .body }}\n\n---\n\n{{ .body }}{{ end }}",
+
+		MessageBlunderbussReviewersChosen: "Requested reviews from these reviewers:\n" +
+			"{{ range .chosenReviewers }}\n- {{ .Login }}: {{ if or .Authored .Reviewed }}authored {{ .Authored }} and " +
+			"reviewed {{ .Reviewed }} of the recent commits touching the changed files, " +
+			"most recently on {{ .LastTouched }}{{ else }}chosen from the reviewers in OWNERS{{ end }}{{ end }}",
+		MessageBlunderbussReviewReminder: "{{ range .reviewers }}@{{ . }} {{ end }}this pull request has been " +
+			"waiting for your review for more than {{ .hours }} hours, please take a look.",
+		MessageBlunderbussReviewEscalated: "The review requested from " +
+			"{{ range .reviewers }}@{{ . }} {{ end }}has been pending for more than {{ .hours }} hours, " +
+			"{{ if .added }}requested reviews from the {{ if .committers }}committers{{ else }}reviewers{{ end }} " +
+			"{{ range .added }}@{{ . }} {{ end }}and {{ end }}added the `{{ .label }}` label.",
+		MessageBlunderbussWelcome: "Welcome @{{ .author }}! It looks like this is your first pull request here, " +
+			"thanks for your contribution." +
+			"{{ if .mentor }} @{{ .mentor }} will mentor you through the review of this pull request.{{ end }}" +
+			"{{ if .links }}\n\nThese links may help you:\n{{ range .links }}\n- [{{ .Name }}]({{ .URL }}){{ end }}{{ end }}",
+		MessageBlunderbussReviewerPreferences: "The reviewer preferences of {{ .login }}:\n" +
+			"{{ with .preferences }}\n- Automatic review requests: {{ if .OptOut }}opted out{{ else }}enabled{{ end }}" +
+			"\n- Skip weekends: {{ if .SkipWeekends }}yes{{ else }}no{{ end }}" +
+			"\n- Time zone: {{ or .TimeZone \"UTC\" }}" +
+			"\n- Maximum requests per week: {{ if .MaxWeeklyRequests }}{{ .MaxWeeklyRequests }}{{ else }}unlimited{{ end }}" +
+			"\n- SIGs: {{ if .Sigs }}{{ join .Sigs \", \" }}{{ else }}all{{ end }}" +
+			"\n- Review reminders: {{ if .NoReminders }}off{{ else }}on{{ end }}{{ end }}" +
+			"\n\nThe preferences can be changed in the reviewer preferences file of the config repo.",
+
+		MessageLabelNotSet: "Those labels are not set on the issue: `{{ join .labels \", \" }}`",
+		MessageLabelBlockerReason: "In response to {{if eq .action \"labeled\"}}adding{{else}}removing{{end}} " +
+			"label named {{ .label }}.",
+	},
+	LocaleChinese: {
+		MessageAboutThisBot: "通过 PR 评论与我交互的说明可以在[这里]({{ .commandHelpLink }})找到。  " +
+			"如果你对我的行为有疑问或者建议，请在 " +
+			"[ti-community-infra/tichi]" +
+			"(https://github.com/ti-community-infra/tichi/issues/new?title=Prow%20issue:) 仓库中提交 issue。",
+		MessageInResponseTo: "回复[这条评论]({{ .url }})：",
+
+		MessageLgtmReviewNotification: `
+{{if .reviewers}}
+该 PR 已经被以下 reviewers 认可：
+
+{{if .affiliationGroups}}{{range .affiliationGroups}}**{{if .Affiliation}}{{ .Affiliation }}{{else}}未知组织{{end}}**
+{{range .Approvers}}- {{.}}` + "\n" + `{{end}}
+{{end}}{{else}}{{range $index, $reviewer := .reviewers}}- {{$reviewer}}` + "\n" + `{{end}}{{end}}
+
+{{else}}
+该 PR 还没有被认可。
+{{end}}{{if .missingAffiliations}}
+还需要来自 {{ .missingAffiliations }} 个其他组织的认可。
+{{end}}
+
+为了完成 [PR 流程]({{ .prProcessLink }})，请在评论中填写 ` + "`/cc @reviewer`" + ` 邀请[列表]({{ .ownersLink }})中的 reviewers 进行 review。
+{{if .assignedCommitters}}该 PR 已经被分配给 {{range $index, $committer := .assignedCommitters}}{{if $index}}、{{end}}@{{$committer}}{{end}} 帮助你合并该 PR。
+{{else if .autoAssignCommitter}}当你的 PR 获得了足够的 LGTM 之后，机器人会自动将该 PR 分配给[列表]({{ .ownersLink }})中的 committer 帮助你合并该 PR。
+{{else}}当你的 PR 获得了足够的 LGTM 之后，你可以在评论中填写 ` + "`/assign @committer`" + ` 将该 PR 分配给[列表]({{ .ownersLink }})中的 committer 帮助你合并该 PR。
+{{end}}
+机器人支持的所有命令可以在[这里]({{ .commandHelpLink }}?repo={{ .org }}%2F{{ .repo }})找到。
+
+<details>
+
+Reviewer 可以在评论中填写 ` + "`/lgtm`" + ` 表示认可。
+Reviewer 可以在评论中填写 ` + "`/lgtm cancel`" + ` 取消认可。
+</details>`,
+		MessageLgtmSelfApproval:        "你不能 `/lgtm` 自己的 PR。",
+		MessageLgtmOnlyReviewers:       "只有[列表]({{ .ownersLink }})中的 reviewers 才能使用 `/lgtm`。",
+		MessageLgtmCancelOnlyReviewers: "只有 PR 作者或者[列表]({{ .ownersLink }})中的 reviewers 才能使用 `/lgtm cancel`。",
+		MessageLgtmApprovalsReset: "由于推送了新的提交，" +
+			"{{if .reviewers}} {{ join .reviewers \", \" }} 的{{end}}认可已经被重置。",
+
+		MessageMergeOnlyCommitters:       "只有[列表]({{ .ownersLink }})中的 committers 才能使用 `/merge`。",
+		MessageMergeCancelOnlyCommitters: "只有 PR 作者或者[列表]({{ .ownersLink }})中的 committers 才能使用 `/merge cancel`。",
+		MessageMergeNeedsLgtm:            "该 PR 需要 {{ .needsLgtm }} 个 `/lgtm`{{ if .requiredCommitterLgtm }}，其中 {{ .requiredCommitterLgtm }} 个来自 committer，当前有 {{ .committerLgtm }} 个{{ end }}{{ if .missingAffiliations }}，并且需要来自 {{ .missingAffiliations }} 个其他组织的认可{{ end }}才能使用 `/merge`。",
+		MessageMergeCanceledByPush:       "由于推送了新的提交，合并已经被取消。",
+		MessageMergeChecksNotPassed:      "该 PR 需要通过必需的检查才能使用 `/merge`。{{ if .failedContexts }}失败的检查：{{ join .failedContexts \", \" }}。{{ end }}{{ if .pendingContexts }}等待中的检查：{{ join .pendingContexts \", \" }}。{{ end }}{{ if .labelWhenChecksPass }}必需的检查通过之后该 PR 会被自动接受。{{ end }}",
+		MessageMergeAccepted: "该 PR 已经被接受，可以合并。" +
+			"<details>Tree hash: {{ .treeHash }} Base commit: {{ .baseSHA }} Head commit: {{ .headSHA }}</details>",
+		MessageMergeCanceledByApprovals: "{{ if .addedBy }}{{ .addedBy }} 添加的{{ end }}`{{ .label }}` 标签已经被移除，" +
+			"因为该 PR 的认可不再满足要求：当前有 {{ .currentLgtm }} 个 LGTM，需要 {{ .needsLgtm }} 个 LGTM" +
+			"{{ if .requiredCommitterLgtm }}；当前有 {{ .committerLgtm }} 个来自 committer 的 LGTM，" +
+			"需要 {{ .requiredCommitterLgtm }} 个{{ end }}" +
+			"{{ if .missingAffiliations }}；还需要来自 {{ .missingAffiliations }} 个其他组织的认可{{ end }}。" +
+			"请在该 PR 被认可之后重新 `/merge`。",
+		MessageMergeMethodNotAllowed: "该仓库不允许使用 `/merge {{ .method }}`。" +
+			"{{ if .methods }}允许的合并方式：{{ join .methods \", \" }}。{{ end }}",
+		MessageMergeQueueStatus: "{{ if .testing }}该 PR 正在 `{{ .branch }}` 的合并队列中进行测试" +
+			"{{ if .others }}，同时测试的还有 {{ join .others \", \" }}{{ end }}。" +
+			"{{ else }}该 PR 在 `{{ .branch }}` 的合并队列中排第 {{ .position }} 位。{{ end }}",
+		MessageMergeQueueMerged: "该 PR 已经由合并队列合并。",
+		MessageMergeQueueRemoved: "该 PR 已经从合并队列中移除，因为" +
+			"{{ if .conflict }}它与 `{{ .branch }}` 存在冲突" +
+			"{{ else }}必需的检查没有在测试分支上通过：{{ join .contexts \", \" }}{{ end }}。" +
+			"`{{ .label }}` 标签已经被移除，请在问题解决之后重新 `/merge`。",
+		MessageMergeFrozen: "`{{ .branch }}` 处于代码冻结 {{ .name }} 中，不能使用 `/merge`，详见 {{ .trackingIssue }}。" +
+			"{{ if .exemptLabels }}带有以下任意标签的 PR 仍然可以合并：{{ join .exemptLabels \", \" }}。{{ end }}",
+		MessageMergeFrozenLabelRemoved: "`{{ .label }}` 标签已经被移除，因为 `{{ .branch }}` 处于代码冻结 " +
+			"{{ .name }} 中，详见 {{ .trackingIssue }}。请在冻结结束之后重新 `/merge`。",
+		MessageMergeFreezeAnnouncement: "{{ if .active }}代码冻结 {{ .name }} 已经开始，" +
+			"匹配 {{ join .branches \", \" }} 的分支上的 PR 不能被合并。" +
+			"{{ if .end }}冻结预计在 {{ .end }} 结束。{{ end }}" +
+			"{{ else }}代码冻结 {{ .name }} 已经结束。{{ end }}",
+		MessageMergeFreezeOnlyTrusted: "只有以下团队的成员才能使用 `/freeze`：{{ join .teams \", \" }}。",
+		MessageMergeDependencies: "该 PR 依赖于：\n" +
+			"{{ range .dependencies }}\n- {{ .Ref }}：" +
+			"{{ if eq .State \"merged\" }}已合并{{ else if eq .State \"open\" }}未合并" +
+			"{{ else if eq .State \"closed\" }}未合并并已关闭{{ else }}未知{{ end }}{{ end }}\n\n" +
+			"在所有依赖被合并之前，`{{ .label }}` 标签不会被添加。" +
+			"{{ if .closed }}\n\n{{ join .closed \", \" }} 未合并就已经被关闭，如果不再需要这些依赖，请将它们移除。{{ end }}" +
+			"{{ if .unknown }}\n\n**错误**：无法获取 {{ join .unknown \", \" }}，这些依赖会被忽略，" +
+			"请检查它们是否是 PR。{{ end }}",
+		MessageMergeWaitingForDependencies: "{{ if .removed }}`{{ .label }}` 标签已经被移除，因为" +
+			"{{ else }}`/merge` 已经被接受，但是{{ end }}以下依赖仍未合并：" +
+			"{{ join .dependencies \", \" }}。它们被合并之后 `{{ .label }}` 标签会被自动添加。",
+		MessageMergeDependsOnOnlyAuthorAndCommitters: "只有 PR 作者或者[列表]({{ .ownersLink }})中的 " +
+			"committers 才能使用 `/depends-on`。",
+		MessageMergeAutoMerge: "{{ if .enabled }}自动合并已开启，在认可满足要求并且必需的检查通过之后 " +
+			"`{{ .label }}` 标签会被自动添加，使用 `/auto-merge cancel` 可以停止自动合并。" +
+			"{{ else }}自动合并已关闭，需要 committer 评论 `/merge`。{{ end }}",
+		MessageMergeAutoMergeOnlyAuthorAndCommitters: "只有 PR 作者或者[列表]({{ .ownersLink }})中的 " +
+			"committers 才能使用 `/auto-merge`。",
+		MessageMergeHelpConfig: "该插件有以下配置：<ul>" +
+			"{{ if .storeTreeHash }}\n<li>如果 PR 相对于目标分支的 diff 没有变化，新的提交不会移除 'can-merge' 标签。</li>{{ end }}" +
+			"{{ if .reviewStatus }}\n<li>包括 'can-merge' 状态在内的 review 进度会以 " +
+			"'{{ .reviewStatusContext }}' commit status 的形式发布。</li>{{ end }}" +
+			"{{ if .recreateNotification }}\n<li>每次都会创建新的合并通知并删除旧的通知，而不是原地编辑通知。</li>{{ end }}" +
+			"{{ if .requireContexts }}\n<li>只有以下检查通过之后才会添加 'can-merge' 标签：" +
+			"{{ join .requireContexts \", \" }}。</li>{{ end }}" +
+			"{{ if .labelWhenChecksPass }}\n<li>等待检查的 PR 在必需的检查通过之后会被自动添加 'can-merge' 标签。</li>{{ end }}" +
+			"{{ if .mergeMethods }}\n<li>可以使用 `/merge <method>` 从以下合并方式中选择：" +
+			"{{ join .mergeMethods \", \" }}。</li>{{ end }}" +
+			"{{ range .freezes }}\n<li>匹配 {{ join .Branches \", \" }} 的分支处于代码冻结 {{ .Name }} 中，" +
+			"详见 {{ .TrackingIssue }}。</li>{{ end }}" +
+			"{{ if .trackDependencies }}\n<li>在该 PR 依赖的所有 PR 被合并之前，'can-merge' 标签不会被添加。</li>{{ end }}" +
+			"{{ if .autoMerge }}\n<li>在认可满足要求并且必需的检查通过之后会自动添加 'can-merge' 标签，" +
+			"除非使用 `/auto-merge cancel` 关闭了自动合并。</li>{{ end }}" +
+			"{{ if .mergeQueue }}\n<li>带有 'can-merge' 标签的 PR 会以每批最多 {{ .maxBatchSize }} 个 PR 的方式" +
+			"在以 {{ .stagingBranchPrefix }} 为前缀的测试分支上测试，并由合并队列合并。</li>{{ end }}" +
+			"{{ if .canMergeLabel }}\n<li>表示 PR 可以合并的标签是 {{ .canMergeLabel }}。</li>{{ end }}\n</ul>",
+
+		MessageCherrypickerOnlyMembers: "只有 {{ .org }} 的成员才能使用 `/cherry-pick`。",
+		MessageCherrypickerScheduled:   "该 PR 会在合并之后被 cherry-pick 到 `{{ .branch }}`。",
+		MessageCherrypickerCreated: "{{ if .conflictFiles }}cherry-pick 到 `{{ .branch }}` 时存在冲突，" +
+			"已经创建包含冲突的草稿 PR #{{ .number }}，请解决冲突。" +
+			"{{ else }}cherry-pick 到 `{{ .branch }}` 的 PR 已经创建：#{{ .number }}。{{ end }}",
+		MessageCherrypickerFailed: "cherry-pick 该 PR 到 `{{ .branch }}` 失败：{{ .error }}",
+		MessageCherrypickerPullRequestBody: "这是 #{{ .number }} 到 `{{ .branch }}` 的自动 cherry-pick。" +
+			"{{ if .conflictFiles }}\n\ncherry-pick 时存在冲突，以下文件中提交了冲突标记，" +
+			"请解决冲突之后将该 PR 标记为 ready for review：\n" +
+			"{{ range .conflictFiles }}\n- `{{ . }}`{{ end }}{{ end }}" +
+			"{{ if .body }}\n\n---\n\n{{ .body }}{{ end }}",
+
+		MessageBlunderbussReviewersChosen: "已经请求以下 reviewers review：\n" +
+			"{{ range .chosenReviewers }}\n- {{ .Login }}：{{ if or .Authored .Reviewed }}在修改的文件最近的提交中" +
+			"编写了 {{ .Authored }} 个并且 review 了 {{ .Reviewed }} 个，最近一次在 {{ .LastTouched }}" +
+			"{{ else }}从 OWNERS 的 reviewers 中选择{{ end }}{{ end }}",
+		MessageBlunderbussReviewReminder: "{{ range .reviewers }}@{{ . }} {{ end }}该 PR 等待你的 review " +
+			"已经超过 {{ .hours }} 小时，请尽快 review。",
+		MessageBlunderbussReviewEscalated: "请求 {{ range .reviewers }}@{{ . }} {{ end }}的 review " +
+			"已经超过 {{ .hours }} 小时，" +
+			"{{ if .added }}已经请求{{ if .committers }} committers{{ else }}其他 reviewers{{ end }} " +
+			"{{ range .added }}@{{ . }} {{ end }}review 并且{{ end }}添加了 `{{ .label }}` 标签。",
+		MessageBlunderbussWelcome: "欢迎 @{{ .author }}！这是你在这里的第一个 PR，感谢你的贡献。" +
+			"{{ if .mentor }}@{{ .mentor }} 会指导你完成该 PR 的 review。{{ end }}" +
+			"{{ if .links }}\n\n这些链接可能对你有帮助：\n{{ range .links }}\n- [{{ .Name }}]({{ .URL }}){{ end }}{{ end }}",
+		MessageBlunderbussReviewerPreferences: "{{ .login }} 的 reviewer 偏好设置：\n" +
+			"{{ with .preferences }}\n- 自动请求 review：{{ if .OptOut }}已退出{{ else }}已开启{{ end }}" +
+			"\n- 周末不请求 review：{{ if .SkipWeekends }}是{{ else }}否{{ end }}" +
+			"\n- 时区：{{ or .TimeZone \"UTC\" }}" +
+			"\n- 每周最多请求次数：{{ if .MaxWeeklyRequests }}{{ .MaxWeeklyRequests }}{{ else }}不限{{ end }}" +
+			"\n- SIG：{{ if .Sigs }}{{ join .Sigs \", \" }}{{ else }}全部{{ end }}" +
+			"\n- review 提醒：{{ if .NoReminders }}关闭{{ else }}开启{{ end }}{{ end }}" +
+			"\n\n偏好设置可以在配置仓库的 reviewer 偏好设置文件中修改。",
+
+		MessageLabelNotSet: "该 issue 上没有这些标签：`{{ join .labels \", \" }}`",
+		MessageLabelBlockerReason: "回复{{if eq .action \"labeled\"}}添加{{else}}移除{{end}}" +
+			"名为 {{ .label }} 的标签的操作。",
+	},
+}
+
+// MessageTemplates specifies the message templates for a set of repos.
+type MessageTemplates struct {
+	// Repos is either of the form org/repos or just org.
+	Repos []string `json:"repos,omitempty"`
+	// Locale specifies the locale of the built-in messages for these repos.
+	Locale string `json:"locale,omitempty"`
+	// Messages specifies the templates that override the built-in messages, the key is the message name.
+	Messages map[string]string `json:"messages,omitempty"`
+}
+
+// TemplatesFor finds the MessageTemplates for a repo, if one exists.
+// MessageTemplates configuration can be listed for a repository
+// or an organization.
+func (c *Configuration) TemplatesFor(org, repo string) *MessageTemplates {
+	fullName := fmt.Sprintf("%s/%s", org, repo)
+	for _, templates := range c.Templates {
+		if !sets.NewString(templates.Repos...).Has(fullName) {
+			continue
+		}
+		return &templates
+	}
+	// If you don't find anything, loop again looking for an org config
+	for _, templates := range c.Templates {
+		if !sets.NewString(templates.Repos...).Has(org) {
+			continue
+		}
+		return &templates
+	}
+	return &MessageTemplates{}
+}
+
+// RenderMessage renders the message of a repo with the data. The template overridden by the repo
+// or the organization is preferred, otherwise the built-in template of the configured locale is used.
+func (c *Configuration) RenderMessage(org, repo, name string, data map[string]interface{}) (string, error) {
+	opts := c.TemplatesFor(org, repo)
+
+	templ, ok := opts.Messages[name]
+	if !ok {
+		locale := opts.Locale
+		if locale == "" {
+			locale = c.Locale
+		}
+		templ, ok = builtinTemplates[locale][name]
+		if !ok {
+			templ, ok = builtinTemplates[LocaleEnglish][name]
+		}
+		if !ok {
+			return "", fmt.Errorf("unknown message %s", name)
+		}
+	}
+
+	return executeTemplate(name, templ, data)
+}
+
+// executeTemplate parses the template and executes it with the data.
+func executeTemplate(name, templ string, data map[string]interface{}) (string, error) {
+	buf := bytes.NewBufferString("")
+	if messageTemplate, err := template.New(name).Funcs(templateFuncs).Parse(templ); err != nil {
+		return "", fmt.Errorf("failed to parse template for %s: %v", name, err)
+	} else if err := messageTemplate.Execute(buf, data); err != nil {
+		return "", fmt.Errorf("failed to execute template for %s: %v", name, err)
+	}
+	return buf.String(), nil
+}
+
+// validateTemplates will return an error if the locale is unsupported, the message is unknown
+// or the template cannot be parsed.
+func validateTemplates(locale string, templates []MessageTemplates) error {
+	if locale != "" {
+		if _, ok := builtinTemplates[locale]; !ok {
+			return fmt.Errorf("unsupported locale %s", locale)
+		}
+	}
+
+	for _, t := range templates {
+		if t.Locale != "" {
+			if _, ok := builtinTemplates[t.Locale]; !ok {
+				return fmt.Errorf("unsupported locale %s", t.Locale)
+			}
+		}
+
+		for name, templ := range t.Messages {
+			if _, ok := builtinTemplates[LocaleEnglish][name]; !ok {
+				return fmt.Errorf("unknown message %s", name)
+			}
+			if _, err := template.New(name).Funcs(templateFuncs).Parse(templ); err != nil {
+				return fmt.Errorf("failed to parse template for %s: %v", name, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,214 @@
+package externalplugins
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRenderMessage(t *testing.T) {
+	config := &Configuration{
+		Locale: LocaleChinese,
+		Templates: []MessageTemplates{
+			{
+				Repos: []string{"org/repo"},
+				Messages: map[string]string{
+					MessageMergeNeedsLgtm: "Please get {{ .needsLgtm }} LGTMs first.",
+				},
+			},
+			{
+				Repos:  []string{"org/english"},
+				Locale: LocaleEnglish,
+			},
+			{
+				Repos: []string{"org"},
+				Messages: map[string]string{
+					MessageLgtmSelfApproval: "No self approval in {{ .repo }}.",
+				},
+			},
+		},
+	}
+
+	testcases := []struct {
+		name string
+		org  string
+		repo string
+		msg  string
+		data map[string]interface{}
+
+		expected string
+	}{
+		{
+			name:     "repo overridden message",
+			org:      "org",
+			repo:     "repo",
+			msg:      MessageMergeNeedsLgtm,
+			data:     map[string]interface{}{"needsLgtm": 2},
+			expected: "Please get 2 LGTMs first.",
+		},
+		{
+			name:     "org overridden message",
+			org:      "org",
+			repo:     "other",
+			msg:      MessageLgtmSelfApproval,
+			data:     map[string]interface{}{"repo": "other"},
+			expected: "No self approval in other.",
+		},
+		{
+			name:     "built-in message of the global locale",
+			org:      "org",
+			repo:     "repo",
+			msg:      MessageMergeCanceledByPush,
+			expected: "由于推送了新的提交，合并已经被取消。",
+		},
+		{
+			name:     "built-in message of the repo locale",
+			org:      "org",
+			repo:     "english",
+			msg:      MessageMergeNeedsLgtm,
+			data:     map[string]interface{}{"needsLgtm": 1},
+			expected: "`/merge` in this pull request requires 1 `/lgtm`.",
+		},
+		{
+			name:     "message with join function",
+			org:      "other-org",
+			repo:     "english",
+			msg:      MessageLgtmApprovalsReset,
+			data:     map[string]interface{}{"reviewers": []string{"a", "b"}},
+			expected: "由于推送了新的提交， a, b 的认可已经被重置。",
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			msg, err := config.RenderMessage(tc.org, tc.repo, tc.msg, tc.data)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if msg != tc.expected {
+				t.Errorf("message mismatch: got %q, want %q", msg, tc.expected)
+			}
+		})
+	}
+}
+
+func TestBuiltinTemplates(t *testing.T) {
+	for locale, templates := range builtinTemplates {
+		for name := range builtinTemplates[LocaleEnglish] {
+			if _, ok := templates[name]; !ok {
+				t.Errorf("locale %s is missing message %s", locale, name)
+			}
+		}
+		for name, templ := range templates {
+			if _, err := executeTemplate(name, templ, map[string]interface{}{
+				"reviewers": []string{"a"},
+				"labels":    []string{"a"},
+				"contexts":  []string{"a"},
+			}); err != nil {
+				t.Errorf("failed to render message %s of locale %s: %v", name, locale, err)
+			}
+		}
+	}
+}
+
+func TestFormatResponseWithTemplates(t *testing.T) {
+	config := &Configuration{}
+	want := "@user: reply\n\n<details>\n\nIn response to [this](https://comment):\n\n>/merge\n\n\n" +
+		AboutThisBotWithoutCommands + "\n</details>"
+	if got := config.FormatResponseRaw("org", "repo", "/merge", "https://comment", "user", "reply"); got != want {
+		t.Errorf("default response mismatch: got %q, want %q", got, want)
+	}
+
+	config = &Configuration{
+		Templates: []MessageTemplates{
+			{
+				Repos: []string{"org/repo"},
+				Messages: map[string]string{
+					MessageAboutThisBot: "Ask for help in {{ .org }}/{{ .repo }}.",
+				},
+			},
+		},
+	}
+	resp := config.FormatSimpleResponse("org", "repo", "user", "reply")
+	if !strings.Contains(resp, "Ask for help in org/repo.") {
+		t.Errorf("expected the customized about message, got %q", resp)
+	}
+}
+
+func TestValidateTemplates(t *testing.T) {
+	testcases := []struct {
+		name      string
+		locale    string
+		templates []MessageTemplates
+
+		expected error
+	}{
+		{
+			name:   "valid templates",
+			locale: LocaleChinese,
+			templates: []MessageTemplates{
+				{
+					Repos:  []string{"org/repo"},
+					Locale: LocaleEnglish,
+					Messages: map[string]string{
+						MessageMergeNeedsLgtm: "{{ .needsLgtm }} LGTMs required.",
+					},
+				},
+			},
+		},
+		{
+			name:     "unsupported global locale",
+			locale:   "fr",
+			expected: fmt.Errorf("unsupported locale fr"),
+		},
+		{
+			name: "unsupported repo locale",
+			templates: []MessageTemplates{
+				{
+					Repos:  []string{"org/repo"},
+					Locale: "fr",
+				},
+			},
+			expected: fmt.Errorf("unsupported locale fr"),
+		},
+		{
+			name: "unknown message",
+			templates: []MessageTemplates{
+				{
+					Repos: []string{"org/repo"},
+					Messages: map[string]string{
+						"nop": "nop",
+					},
+				},
+			},
+			expected: fmt.Errorf("unknown message nop"),
+		},
+		{
+			name: "invalid template",
+			templates: []MessageTemplates{
+				{
+					Repos: []string{"org/repo"},
+					Messages: map[string]string{
+						MessageMergeNeedsLgtm: "{{ .needsLgtm ",
+					},
+				},
+			},
+			expected: fmt.Errorf("failed to parse template for merge_needs_lgtm: " +
+				"template: merge_needs_lgtm:1: unclosed action"),
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateTemplates(tc.locale, tc.templates)
+			if err == nil && tc.expected == nil {
+				return
+			}
+			if err == nil || tc.expected == nil || err.Error() != tc.expected.Error() {
+				t.Errorf("error mismatch: got %v, want %v", err, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+// Package testutil provides the fixtures shared by the tests of the external plugins.
+package testutil
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/test-infra/prow/git/localgit"
+	git "k8s.io/test-infra/prow/git/v2"
+	"k8s.io/test-infra/prow/github"
+)
+
+const (
+	// Org is the org of the fake repo.
+	Org = "org"
+	// Repo is the name of the fake repo.
+	Repo = "repo"
+	// Number is the number of the PR in the fake repo.
+	Number = 101
+	// PRBranch is the branch of the PR in the fake repo.
+	PRBranch = "pr"
+)
+
+// FakeRepo is a local repo with the base branch master and a PR branch.
+type FakeRepo struct {
+	t  *testing.T
+	lg *localgit.LocalGit
+}
+
+// NewLocalGit creates a local git and a git client factory, both are cleaned when the test finishes.
+func NewLocalGit(t *testing.T) (*localgit.LocalGit, git.ClientFactory) {
+	lg, gitClient, err := localgit.NewV2()
+	if err != nil {
+		t.Fatalf("failed to create local git: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := lg.Clean(); err != nil {
+			t.Errorf("failed to clean local git: %v", err)
+		}
+		if err := gitClient.Clean(); err != nil {
+			t.Errorf("failed to clean git client: %v", err)
+		}
+	})
+	return lg, gitClient
+}
+
+// NewFakeRepo creates the repo with a PR which changes the file pr based on the file base.
+func NewFakeRepo(t *testing.T) (*FakeRepo, git.ClientFactory) {
+	lg, gitClient := NewLocalGit(t)
+	if err := lg.MakeFakeRepo(Org, Repo); err != nil {
+		t.Fatalf("failed to make fake repo: %v", err)
+	}
+	r := &FakeRepo{t: t, lg: lg}
+	r.Commit(map[string]string{"base": "1\n2\n3\n"})
+	r.CheckoutNewBranch(PRBranch)
+	r.Commit(map[string]string{"pr": "a\n"})
+	r.UpdatePR()
+	r.Checkout("master")
+	return r, gitClient
+}
+
+// Git runs the git command in the repo and returns the trimmed output.
+func (r *FakeRepo) Git(args ...string) string {
+	cmd := exec.Command(r.lg.Git, args...)
+	cmd.Dir = filepath.Join(r.lg.Dir, Org, Repo)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("git %v failed: %v, %s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// Commit commits the files with the contents to the current branch.
+func (r *FakeRepo) Commit(files map[string]string) {
+	contents := map[string][]byte{}
+	for name, content := range files {
+		contents[name] = []byte(content)
+	}
+	if err := r.lg.AddCommit(Org, Repo, contents); err != nil {
+		r.t.Fatalf("failed to add commit: %v", err)
+	}
+}
+
+// Checkout checks out the commitlike.
+func (r *FakeRepo) Checkout(commitlike string) {
+	if err := r.lg.Checkout(Org, Repo, commitlike); err != nil {
+		r.t.Fatalf("failed to checkout %s: %v", commitlike, err)
+	}
+}
+
+// CheckoutNewBranch creates the branch from the current commit and checks it out.
+func (r *FakeRepo) CheckoutNewBranch(branch string) {
+	if err := r.lg.CheckoutNewBranch(Org, Repo, branch); err != nil {
+		r.t.Fatalf("failed to create branch %s: %v", branch, err)
+	}
+}
+
+// UpdatePR points the head of the PR to the current commit.
+func (r *FakeRepo) UpdatePR() {
+	r.Git("update-ref", fmt.Sprintf("refs/pull/%d/head", Number), "HEAD")
+}
+
+// PullRequest returns the PR against master with the current head.
+func (r *FakeRepo) PullRequest() *github.PullRequest {
+	return &github.PullRequest{
+		Number: Number,
+		Base: github.PullRequestBranch{
+			Ref:  "master",
+			Repo: github.Repo{Owner: github.User{Login: Org}, Name: Repo},
+		},
+		Head: github.PullRequestBranch{SHA: r.Git("rev-parse", fmt.Sprintf("refs/pull/%d/head", Number))},
+	}
+}
@@ -0,0 +1,153 @@
+package labelmigrator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+	"k8s.io/test-infra/prow/github"
+)
+
+type githubClient interface {
+	AddLabel(org, repo string, number int, label string) error
+	RemoveLabel(org, repo string, number int, label string) error
+	ListIssueComments(org, repo string, number int) ([]github.IssueComment, error)
+	BotUserChecker() (func(candidate string) bool, error)
+	Query(context.Context, interface{}, map[string]interface{}) error
+}
+
+// Migrator relabels the open pull requests from the old label scheme to the configured label scheme.
+type Migrator struct {
+	// From is the label scheme used before the migration.
+	From *externalplugins.LabelScheme
+	// Config provides the label schemes of the repos after the migration.
+	Config *externalplugins.Configuration
+
+	GitHubClient githubClient
+	OwnersLoader ownersclient.OwnersLoader
+	Log          *logrus.Entry
+}
+
+// MigrateAll relabels all open pull requests of the orgs and repos.
+func (m *Migrator) MigrateAll(orgs, repos []string) error {
+	prs, err := externalplugins.SearchOpenPullRequests(context.Background(), m.Log, m.GitHubClient, orgs, repos)
+	if err != nil {
+		return fmt.Errorf("failed to search open pull requests: %v", err)
+	}
+
+	var failed int
+	for _, pr := range prs {
+		org := string(pr.Repository.Owner.Login)
+		repo := string(pr.Repository.Name)
+		number := int(pr.Number)
+		var labels []string
+		for _, label := range pr.Labels.Nodes {
+			labels = append(labels, string(label.Name))
+		}
+
+		l := m.Log.WithFields(logrus.Fields{
+			github.OrgLogField:  org,
+			github.RepoLogField: repo,
+			github.PrLogField:   number,
+		})
+		if err := m.Migrate(org, repo, number, labels, l); err != nil {
+			l.WithError(err).Error("Failed to migrate the labels.")
+			failed++
+		}
+	}
+
+	if failed != 0 {
+		return fmt.Errorf("failed to migrate the labels of %d pull request(s)", failed)
+	}
+	return nil
+}
+
+// Migrate relabels the pull request with the labels of the label scheme of its repo.
+func (m *Migrator) Migrate(org, repo string, number int, labels []string, log *logrus.Entry) error {
+	to := m.Config.LabelSchemeFor(org, repo)
+	lgtmOpts := m.Config.LgtmFor(org, repo)
+
+	// The owners are only loaded when the required LGTMs or the roles of the approvers are needed.
+	var owners *ownersclient.Owners
+	loadOwners := func() (*ownersclient.Owners, error) {
+		if owners != nil {
+			return owners, nil
+		}
+		var err error
+		owners, err = m.OwnersLoader.LoadOwners(lgtmOpts.PullOwnersEndpoint, org, repo, number)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load owners: %v", err)
+		}
+		return owners, nil
+	}
+
+	for _, label := range labels {
+		var newLabel string
+		if label == m.From.CanMergeLabel {
+			newLabel = to.CanMergeLabel
+		} else if m.From.IsLgtmLabel(label) {
+			// The countdown labels are parsed and rendered with the number of required LGTMs.
+			needsLgtm := 0
+			if m.From.LgtmLabelCountdown || to.LgtmLabelCountdown {
+				o, err := loadOwners()
+				if err != nil {
+					return err
+				}
+				needsLgtm = o.NeedsLgtm
+			}
+			count, _ := m.From.ParseLgtmLabel(label, needsLgtm)
+			if !m.From.IsLgtmLabelNumbered() && to.IsLgtmLabelNumbered() {
+				approvers, err := m.getApprovers(org, repo, number)
+				if err != nil {
+					return err
+				}
+				count = 0
+				if len(approvers) != 0 {
+					o, err := loadOwners()
+					if err != nil {
+						return err
+					}
+					// The approvals are weighted by the roles of the approvers like the lgtm plugin does.
+					count = lgtmOpts.CountLgtm(o, approvers)
+				}
+			}
+			if count == 0 && to.IsLgtmLabelNumbered() {
+				log.Warnf("Skip the label %s because the number of LGTMs is unknown.", label)
+				continue
+			}
+			newLabel = to.LgtmLabelName(count, needsLgtm)
+		} else {
+			continue
+		}
+
+		if newLabel == label {
+			continue
+		}
+
+		log.Infof("Relabeling %s to %s.", label, newLabel)
+		if err := m.GitHubClient.AddLabel(org, repo, number, newLabel); err != nil {
+			return err
+		}
+		if err := m.GitHubClient.RemoveLabel(org, repo, number, label); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getApprovers returns the approvers listed in the review notification of the lgtm plugin.
+func (m *Migrator) getApprovers(org, repo string, number int) ([]string, error) {
+	botUserChecker, err := m.GitHubClient.BotUserChecker()
+	if err != nil {
+		return nil, err
+	}
+	comments, err := m.GitHubClient.ListIssueComments(org, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	approvers, _ := externalplugins.GetApproversFromComments(comments, botUserChecker)
+	return approvers.List(), nil
+}
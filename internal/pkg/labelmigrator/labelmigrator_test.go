@@ -0,0 +1,168 @@
+package labelmigrator
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+)
+
+type fakeOwnersLoader struct {
+	committers []string
+	needsLgtm  int
+}
+
+func (f *fakeOwnersLoader) LoadOwners(_ string, _, _ string, _ int) (*ownersclient.Owners, error) {
+	return &ownersclient.Owners{Committers: f.committers, NeedsLgtm: f.needsLgtm}, nil
+}
+
+func TestMigrate(t *testing.T) {
+	notification := "[REVIEW NOTIFICATION]\n\nThis pull request has been approved by:\n\n" +
+		"- reviewer1\n- reviewer2\n\n<!--Review Notification Identifier-->"
+
+	testcases := []struct {
+		name         string
+		from         *externalplugins.LabelScheme
+		to           *externalplugins.LabelScheme
+		labels       []string
+		notification string
+		roleWeights  map[string]int
+
+		expectAdded   []string
+		expectRemoved []string
+	}{
+		{
+			name: "Default scheme to custom numbered scheme",
+			from: &externalplugins.LabelScheme{},
+			to: &externalplugins.LabelScheme{
+				LgtmLabel:     "approved-by-{n}",
+				CanMergeLabel: "ready-to-merge",
+			},
+			labels:        []string{"sig/testing", "status/LGT2", externalplugins.CanMergeLabel},
+			expectAdded:   []string{"org/repo#1:approved-by-2", "org/repo#1:ready-to-merge"},
+			expectRemoved: []string{"org/repo#1:status/LGT2", "org/repo#1:" + externalplugins.CanMergeLabel},
+		},
+		{
+			name: "Numbered scheme to scheme without number",
+			from: &externalplugins.LabelScheme{},
+			to: &externalplugins.LabelScheme{
+				LgtmLabel: "lgtm",
+			},
+			labels:        []string{"status/LGT1"},
+			expectAdded:   []string{"org/repo#1:lgtm"},
+			expectRemoved: []string{"org/repo#1:status/LGT1"},
+		},
+		{
+			name: "Scheme without number to numbered scheme",
+			from: &externalplugins.LabelScheme{
+				LgtmLabel: "lgtm",
+			},
+			to:            &externalplugins.LabelScheme{},
+			labels:        []string{"lgtm"},
+			notification:  notification,
+			expectAdded:   []string{"org/repo#1:status/LGT2"},
+			expectRemoved: []string{"org/repo#1:lgtm"},
+		},
+		{
+			name: "Scheme without number to numbered scheme with weighted approvals",
+			from: &externalplugins.LabelScheme{
+				LgtmLabel: "lgtm",
+			},
+			to:            &externalplugins.LabelScheme{},
+			labels:        []string{"lgtm"},
+			notification:  notification,
+			roleWeights:   map[string]int{ownersclient.RoleCommitter: 2},
+			expectAdded:   []string{"org/repo#1:status/LGT3"},
+			expectRemoved: []string{"org/repo#1:lgtm"},
+		},
+		{
+			name: "Numbered scheme to countdown scheme",
+			from: &externalplugins.LabelScheme{},
+			to: &externalplugins.LabelScheme{
+				LgtmLabel:          "needs-{n}-lgtm",
+				LgtmLabelCountdown: true,
+			},
+			labels:        []string{"status/LGT1"},
+			expectAdded:   []string{"org/repo#1:needs-1-lgtm"},
+			expectRemoved: []string{"org/repo#1:status/LGT1"},
+		},
+		{
+			name: "Countdown scheme to numbered scheme",
+			from: &externalplugins.LabelScheme{
+				LgtmLabel:          "needs-{n}-lgtm",
+				LgtmLabelCountdown: true,
+			},
+			to:            &externalplugins.LabelScheme{},
+			labels:        []string{"needs-0-lgtm"},
+			expectAdded:   []string{"org/repo#1:status/LGT2"},
+			expectRemoved: []string{"org/repo#1:needs-0-lgtm"},
+		},
+		{
+			name: "Scheme without number to numbered scheme without notification",
+			from: &externalplugins.LabelScheme{
+				LgtmLabel: "lgtm",
+			},
+			to:     &externalplugins.LabelScheme{},
+			labels: []string{"lgtm"},
+		},
+		{
+			name:   "Unchanged scheme",
+			from:   &externalplugins.LabelScheme{},
+			to:     &externalplugins.LabelScheme{},
+			labels: []string{"status/LGT1", externalplugins.CanMergeLabel},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			fc := &fakegithub.FakeClient{
+				IssueComments: map[int][]github.IssueComment{},
+			}
+			if tc.notification != "" {
+				fc.IssueComments[1] = []github.IssueComment{
+					{ID: 1, Body: tc.notification, User: github.User{Login: fakegithub.Bot}},
+				}
+			}
+			tc.to.Repos = []string{"org/repo"}
+			cfg := &externalplugins.Configuration{
+				LabelSchemes: []externalplugins.LabelScheme{*tc.to},
+				TiCommunityLgtm: []externalplugins.TiCommunityLgtm{
+					{
+						Repos:       []string{"org/repo"},
+						RoleWeights: tc.roleWeights,
+					},
+				},
+			}
+			tc.from.Repos = []string{"org/repo"}
+			from := &externalplugins.Configuration{
+				LabelSchemes: []externalplugins.LabelScheme{*tc.from},
+			}
+			m := &Migrator{
+				From:         from.LabelSchemeFor("org", "repo"),
+				Config:       cfg,
+				GitHubClient: fc,
+				OwnersLoader: &fakeOwnersLoader{committers: []string{"reviewer1"}, needsLgtm: 2},
+				Log:          logrus.WithField("component", "labelmigrator"),
+			}
+
+			if err := m.Migrate("org", "repo", 1, tc.labels, m.Log); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			sort.Strings(fc.IssueLabelsAdded)
+			sort.Strings(fc.IssueLabelsRemoved)
+			if !reflect.DeepEqual(fc.IssueLabelsAdded, tc.expectAdded) {
+				t.Errorf("added labels mismatch: got %v, want %v", fc.IssueLabelsAdded, tc.expectAdded)
+			}
+			if !reflect.DeepEqual(fc.IssueLabelsRemoved, tc.expectRemoved) {
+				t.Errorf("removed labels mismatch: got %v, want %v", fc.IssueLabelsRemoved, tc.expectRemoved)
+			}
+		})
+	}
+}
@@ -0,0 +1,643 @@
+package mergequeue
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"k8s.io/apimachinery/pkg/util/sets"
+	git "k8s.io/test-infra/prow/git/v2"
+	"k8s.io/test-infra/prow/github"
+)
+
+// ComponentName is the name of the merge queue.
+const ComponentName = "merge-queue"
+
+// queueCommentIdentifier identifies the comment that reports the status of the PR in the merge queue.
+const queueCommentIdentifier = "<!--Merge Queue-->"
+
+type githubClient interface {
+	externalplugins.ChecksClient
+	BotUser() (*github.UserData, error)
+	BotUserChecker() (func(candidate string) bool, error)
+	FindIssues(query, sort string, asc bool) ([]github.Issue, error)
+	GetPullRequest(org, repo string, number int) (*github.PullRequest, error)
+	GetRef(org, repo, ref string) (string, error)
+	Merge(org, repo string, pr int, details github.MergeDetails) error
+	RemoveLabel(org, repo string, number int, label string) error
+	CreateComment(org, repo string, number int, comment string) error
+	EditComment(org, repo string, id int, comment string) error
+	DeleteComment(org, repo string, id int) error
+	ListIssueComments(org, repo string, number int) ([]github.IssueComment, error)
+	ListIssueEvents(org, repo string, num int) ([]github.ListedIssueEvent, error)
+	ListPRCommits(org, repo string, number int) ([]github.RepositoryCommit, error)
+}
+
+// batch is a group of PRs tested together on the staging branch.
+type batch struct {
+	prs        []github.PullRequest
+	baseSHA    string
+	stagingSHA string
+	startedAt  time.Time
+}
+
+// pool is the merge queue of a base branch.
+type pool struct {
+	// batch is the batch being tested, it is nil when no batch is being tested.
+	batch *batch
+	// limit is the maximum size of the next batch, it is halved every time a batch fails
+	// so that the PR breaking the checks can be found by bisection.
+	limit int
+	// suspects are the PRs of the failed batch which have not been tested alone or merged,
+	// the next batches only test them until the PR breaking the checks is found.
+	suspects []int
+}
+
+// Queue merges the PRs with the 'can-merge' label of each base branch in order.
+//
+// The PRs are queued in the order their 'can-merge' labels were added. The PRs at the head of the queue are
+// merged together into the staging branch to be tested as a batch, all of them are merged once the required
+// contexts pass on the staging branch. If the batch fails, the queue tests the first half of it, and the
+// half which fails is halved again until the PR breaking the checks is found and removed.
+type Queue struct {
+	gc        githubClient
+	gitClient git.ClientFactory
+	log       *logrus.Entry
+	now       func() time.Time
+
+	// pools holds the queues of the base branches, the key is org/repo:branch.
+	pools map[string]*pool
+	// reported holds the last status reported on each PR, the key is org/repo#number.
+	reported map[string]string
+}
+
+// NewQueue creates a merge queue.
+func NewQueue(gc githubClient, gitClient git.ClientFactory, log *logrus.Entry) *Queue {
+	return &Queue{
+		gc:        gc,
+		gitClient: gitClient,
+		log:       log,
+		now:       time.Now,
+		pools:     map[string]*pool{},
+		reported:  map[string]string{},
+	}
+}
+
+// Sync moves the merge queues of all the repos which enable the merge queue forward,
+// it should be called periodically.
+func (q *Queue) Sync(config *externalplugins.Configuration) {
+	synced := sets.NewString()
+	for _, merge := range config.TiCommunityMerge {
+		if !merge.MergeQueue {
+			continue
+		}
+		for _, fullName := range merge.Repos {
+			parts := strings.Split(fullName, "/")
+			if len(parts) != 2 {
+				q.log.Warnf("The merge queue does not support the organization %s, please list the repos.", fullName)
+				continue
+			}
+			if synced.Has(fullName) {
+				continue
+			}
+			synced.Insert(fullName)
+
+			org, repo := parts[0], parts[1]
+			log := q.log.WithFields(logrus.Fields{"org": org, "repo": repo})
+			if err := q.syncRepo(config, org, repo, log); err != nil {
+				log.WithError(err).Error("Failed to sync the merge queue.")
+			}
+		}
+	}
+}
+
+// syncRepo moves the merge queues of the base branches of the repo forward.
+func (q *Queue) syncRepo(config *externalplugins.Configuration, org, repo string, log *logrus.Entry) error {
+	opts := config.MergeFor(org, repo)
+	if !opts.MergeQueue {
+		return nil
+	}
+	canMergeLabel := config.LabelSchemeFor(org, repo).CanMergeLabel
+
+	query := fmt.Sprintf("is:pr is:open repo:%s/%s label:\"%s\"", org, repo, canMergeLabel)
+	issues, err := q.gc.FindIssues(query, "created", true)
+	if err != nil {
+		return fmt.Errorf("failed to search the pull requests: %v", err)
+	}
+
+	queued := map[string][]github.PullRequest{}
+	queuedNumbers := sets.NewInt()
+	labeledAt := map[int]time.Time{}
+	for _, issue := range issues {
+		pr, err := q.gc.GetPullRequest(org, repo, issue.Number)
+		if err != nil {
+			log.WithError(err).Errorf("Failed to get pull request %d.", issue.Number)
+			continue
+		}
+		if pr.State != "open" || pr.Merged || !github.HasLabel(canMergeLabel, pr.Labels) {
+			continue
+		}
+		labeledAt[pr.Number], err = q.getLabeledTime(org, repo, pr.Number, canMergeLabel)
+		if err != nil {
+			log.WithError(err).Errorf("Failed to get the events of pull request %d.", pr.Number)
+			continue
+		}
+		queued[pr.Base.Ref] = append(queued[pr.Base.Ref], *pr)
+		queuedNumbers.Insert(pr.Number)
+	}
+	// The PRs accepted earlier are merged first.
+	for _, prs := range queued {
+		sort.SliceStable(prs, func(i, j int) bool {
+			if !labeledAt[prs[i].Number].Equal(labeledAt[prs[j].Number]) {
+				return labeledAt[prs[i].Number].Before(labeledAt[prs[j].Number])
+			}
+			return prs[i].Number < prs[j].Number
+		})
+	}
+
+	branches := sets.NewString()
+	for branch := range queued {
+		branches.Insert(branch)
+	}
+	prefix := fmt.Sprintf("%s/%s:", org, repo)
+	for key := range q.pools {
+		if strings.HasPrefix(key, prefix) {
+			branches.Insert(strings.TrimPrefix(key, prefix))
+		}
+	}
+	for _, branch := range branches.List() {
+		l := log.WithField("branch", branch)
+		if err := q.syncBranch(config, opts, org, repo, branch, queued[branch], l); err != nil {
+			l.WithError(err).Error("Failed to sync the merge queue of the branch.")
+		}
+	}
+
+	// Clean up the status of the PRs which leave the queue, e.g. the 'can-merge' label is removed.
+	prefix = fmt.Sprintf("%s/%s#", org, repo)
+	for key := range q.reported {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		number, err := strconv.Atoi(strings.TrimPrefix(key, prefix))
+		if err != nil || queuedNumbers.Has(number) {
+			continue
+		}
+		q.clearStatus(org, repo, number, log)
+	}
+	return nil
+}
+
+// getLabeledTime returns when the label was last added to the PR, it returns the zero time if the label
+// event is not found.
+func (q *Queue) getLabeledTime(org, repo string, number int, label string) (time.Time, error) {
+	events, err := q.gc.ListIssueEvents(org, repo, number)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var labeledAt time.Time
+	for _, event := range events {
+		if event.Event == github.IssueActionLabeled && event.Label.Name == label && event.CreatedAt.After(labeledAt) {
+			labeledAt = event.CreatedAt
+		}
+	}
+	return labeledAt, nil
+}
+
+// syncBranch moves the merge queue of the base branch forward.
+func (q *Queue) syncBranch(config *externalplugins.Configuration, opts *externalplugins.TiCommunityMerge,
+	org, repo, branch string, prs []github.PullRequest, log *logrus.Entry) error {
+	key := fmt.Sprintf("%s/%s:%s", org, repo, branch)
+	if len(prs) == 0 {
+		delete(q.pools, key)
+		return nil
+	}
+	p, ok := q.pools[key]
+	if !ok {
+		p = &pool{limit: opts.MaxBatchSize}
+		q.pools[key] = p
+	}
+
+	if p.batch != nil && !isBatchValid(p.batch, prs) {
+		log.Info("Discard the batch because some of the PRs are updated or removed from the queue.")
+		p.batch = nil
+	}
+
+	if p.batch != nil {
+		result, err := externalplugins.GetRequiredChecksResult(q.gc, org, repo, p.batch.stagingSHA,
+			opts.RequireContexts)
+		if err != nil {
+			return err
+		}
+		timeout := q.now().Sub(p.batch.startedAt) > time.Duration(opts.BatchTimeout)*time.Minute
+
+		switch {
+		case result.Passed():
+			merged := q.mergeBatch(config, opts, org, repo, branch, p.batch, log)
+			p.batch = nil
+			prs = removePRs(prs, merged)
+			q.bisectSuspects(opts, p, prs, log)
+			// Start the next batch after the base branch is updated.
+			q.reportPositions(config, org, repo, branch, prs, nil, log)
+			return nil
+		case len(result.Failed) != 0 || timeout:
+			contexts := result.Failed
+			if len(contexts) == 0 {
+				log.Infof("The batch timed out waiting for %v.", result.Pending)
+				contexts = result.Pending
+			}
+			prs = q.handleFailedBatch(config, opts, org, repo, branch, p, contexts, prs, log)
+		default:
+			q.reportPositions(config, org, repo, branch, prs, p.batch, log)
+			return nil
+		}
+	}
+
+	if len(prs) == 0 {
+		return nil
+	}
+	b, conflicted, err := q.startBatch(config, opts, org, repo, branch, prs, p.limit, log)
+	if err != nil {
+		return err
+	}
+	p.batch = b
+	q.reportPositions(config, org, repo, branch, removePRs(prs, conflicted), b, log)
+	return nil
+}
+
+// isBatchValid returns true if all the PRs of the batch are still in the queue and have not been updated.
+func isBatchValid(b *batch, prs []github.PullRequest) bool {
+	headSHAs := map[int]string{}
+	for _, pr := range prs {
+		headSHAs[pr.Number] = pr.Head.SHA
+	}
+	for _, pr := range b.prs {
+		if sha, ok := headSHAs[pr.Number]; !ok || sha != pr.Head.SHA {
+			return false
+		}
+	}
+	return true
+}
+
+// removePRs returns the PRs except the removed ones.
+func removePRs(prs []github.PullRequest, removed []int) []github.PullRequest {
+	removedNumbers := sets.NewInt(removed...)
+	var remaining []github.PullRequest
+	for _, pr := range prs {
+		if !removedNumbers.Has(pr.Number) {
+			remaining = append(remaining, pr)
+		}
+	}
+	return remaining
+}
+
+// startBatch merges the PRs at the head of the queue into the staging branch and pushes it to start the test.
+// The PRs conflicting with the base branch are removed from the queue and returned.
+func (q *Queue) startBatch(config *externalplugins.Configuration, opts *externalplugins.TiCommunityMerge,
+	org, repo, branch string, prs []github.PullRequest, limit int, log *logrus.Entry) (*batch, []int, error) {
+	baseSHA, err := q.gc.GetRef(org, repo, "heads/"+branch)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get the base branch: %v", err)
+	}
+
+	repoClient, err := q.gitClient.ClientFor(org, repo)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get the git client: %v", err)
+	}
+	defer func() {
+		if err := repoClient.Clean(); err != nil {
+			log.WithError(err).Error("Failed to clean the git client.")
+		}
+	}()
+	if err := q.configGitUser(repoClient); err != nil {
+		return nil, nil, err
+	}
+	if err := repoClient.Checkout(baseSHA); err != nil {
+		return nil, nil, err
+	}
+
+	var batched []github.PullRequest
+	var conflicted []int
+	for _, pr := range prs {
+		if len(batched) >= limit {
+			break
+		}
+		if err := repoClient.FetchRef(fmt.Sprintf("pull/%d/head", pr.Number)); err != nil {
+			return nil, nil, err
+		}
+		merged, err := repoClient.MergeWithStrategy(pr.Head.SHA, string(github.MergeMerge),
+			git.MergeOpt{CommitMessage: fmt.Sprintf("Merge pull request #%d", pr.Number)})
+		if err != nil {
+			return nil, nil, err
+		}
+		if merged {
+			batched = append(batched, pr)
+			continue
+		}
+		// The PR conflicting with the PRs ahead of it waits for them to be merged.
+		if len(batched) != 0 {
+			break
+		}
+		log.Infof("Pull request %d conflicts with the base branch.", pr.Number)
+		q.removeFromQueue(config, org, repo, branch, pr.Number, true, nil, log)
+		conflicted = append(conflicted, pr.Number)
+	}
+	if len(batched) == 0 {
+		return nil, conflicted, nil
+	}
+
+	stagingBranch := opts.StagingBranchPrefix + branch
+	if err := repoClient.CheckoutNewBranch(stagingBranch); err != nil {
+		return nil, nil, err
+	}
+	stagingSHA, err := repoClient.RevParse("HEAD")
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := repoClient.PushToCentral(stagingBranch, true); err != nil {
+		return nil, nil, err
+	}
+
+	b := &batch{
+		prs:        batched,
+		baseSHA:    baseSHA,
+		stagingSHA: strings.TrimSpace(stagingSHA),
+		startedAt:  q.now(),
+	}
+	log.WithField("staging-sha", b.stagingSHA).Infof("Testing the batch %v.", batchNumbers(b))
+	return b, conflicted, nil
+}
+
+// configGitUser configures the bot as the committer of the merge commits.
+func (q *Queue) configGitUser(repoClient git.RepoClient) error {
+	botUser, err := q.gc.BotUser()
+	if err != nil {
+		return fmt.Errorf("failed to get the bot user: %v", err)
+	}
+	name := botUser.Name
+	if name == "" {
+		name = botUser.Login
+	}
+	email := botUser.Email
+	if email == "" {
+		email = fmt.Sprintf("%s@users.noreply.github.com", botUser.Login)
+	}
+	if err := repoClient.Config("user.name", name); err != nil {
+		return err
+	}
+	return repoClient.Config("user.email", email)
+}
+
+// mergeBatch merges the PRs of the batch which has passed the test, and returns the merged PRs.
+func (q *Queue) mergeBatch(config *externalplugins.Configuration, opts *externalplugins.TiCommunityMerge,
+	org, repo, branch string, b *batch, log *logrus.Entry) []int {
+	baseSHA, err := q.gc.GetRef(org, repo, "heads/"+branch)
+	if err != nil {
+		log.WithError(err).Error("Failed to get the base branch.")
+		return nil
+	}
+	// The batch should be tested again if the base branch is changed outside the queue.
+	if baseSHA != b.baseSHA {
+		log.Infof("Discard the batch because the base branch is changed from %s to %s.", b.baseSHA, baseSHA)
+		return nil
+	}
+
+	var merged []int
+	for i := range b.prs {
+		pr := b.prs[i]
+		err := q.gc.Merge(org, repo, pr.Number, q.getMergeDetails(opts, org, repo, &pr, log))
+		if err != nil {
+			log.WithError(err).Errorf("Failed to merge pull request %d.", pr.Number)
+			break
+		}
+		log.Infof("Merged pull request %d.", pr.Number)
+		merged = append(merged, pr.Number)
+
+		msg, err := config.RenderMessage(org, repo, externalplugins.MessageMergeQueueMerged, nil)
+		if err != nil {
+			log.WithError(err).Error("Failed to render the message.")
+			continue
+		}
+		q.reportStatus(org, repo, pr.Number, msg, false, log)
+		delete(q.reported, reportedKey(org, repo, pr.Number))
+	}
+	return merged
+}
+
+// getMergeDetails uses the merge method chosen by the PR or the default merge method of the repo,
+// the commit message is composed from the template when the PR is squashed.
+func (q *Queue) getMergeDetails(opts *externalplugins.TiCommunityMerge, org, repo string,
+	pr *github.PullRequest, log *logrus.Entry) github.MergeDetails {
+	method, ok := externalplugins.GetMergeMethod(pr.Labels)
+	if !ok {
+		method = github.PullRequestMergeType(opts.MergeMethod)
+	}
+	if method == "" {
+		method = github.MergeMerge
+	}
+	details := github.MergeDetails{
+		SHA:         pr.Head.SHA,
+		MergeMethod: string(method),
+	}
+	if method != github.MergeSquash || opts.SquashCommitTemplate == nil {
+		return details
+	}
+
+	// The default commit message of GitHub is used if the commit message cannot be composed.
+	commits, err := q.gc.ListPRCommits(org, repo, pr.Number)
+	if err != nil {
+		log.WithError(err).Errorf("Failed to list the commits of pull request %d.", pr.Number)
+		return details
+	}
+	title, message, err := opts.SquashCommitTemplate.Render(externalplugins.NewCommitMessageData(pr, commits))
+	if err != nil {
+		log.WithError(err).Errorf("Failed to compose the commit message of pull request %d.", pr.Number)
+		return details
+	}
+	details.CommitTitle = title
+	details.CommitMessage = message
+	return details
+}
+
+// handleFailedBatch removes the PR from the queue if it fails alone, otherwise halves the size of the next batch.
+// It returns the PRs remaining in the queue.
+func (q *Queue) handleFailedBatch(config *externalplugins.Configuration, opts *externalplugins.TiCommunityMerge,
+	org, repo, branch string, p *pool, contexts []string, prs []github.PullRequest,
+	log *logrus.Entry) []github.PullRequest {
+	b := p.batch
+	p.batch = nil
+	if len(b.prs) > 1 {
+		p.suspects = batchNumbers(b)
+		p.limit = len(b.prs) / 2
+		log.Infof("The batch %v failed, test the first %d of them.", p.suspects, p.limit)
+		return prs
+	}
+
+	number := b.prs[0].Number
+	log.Infof("Pull request %d failed the required contexts %v.", number, contexts)
+	q.removeFromQueue(config, org, repo, branch, number, false, contexts, log)
+	p.suspects = nil
+	p.limit = opts.MaxBatchSize
+	return removePRs(prs, []int{number})
+}
+
+// bisectSuspects limits the next batch to the first half of the suspects remaining in the queue after a batch
+// passes, because the PR breaking the checks is among them. The limit is reset once no suspect remains.
+func (q *Queue) bisectSuspects(opts *externalplugins.TiCommunityMerge, p *pool, prs []github.PullRequest,
+	log *logrus.Entry) {
+	queued := sets.NewInt()
+	for _, pr := range prs {
+		queued.Insert(pr.Number)
+	}
+	var suspects []int
+	for _, number := range p.suspects {
+		if queued.Has(number) {
+			suspects = append(suspects, number)
+		}
+	}
+	p.suspects = suspects
+
+	switch len(suspects) {
+	case 0:
+		p.limit = opts.MaxBatchSize
+	case 1:
+		p.limit = 1
+		log.Infof("Test the suspect %d alone.", suspects[0])
+	default:
+		p.limit = len(suspects) / 2
+		log.Infof("The suspects %v remain, test the first %d of them.", suspects, p.limit)
+	}
+}
+
+// removeFromQueue removes the 'can-merge' label of the PR and notifies the reason.
+func (q *Queue) removeFromQueue(config *externalplugins.Configuration, org, repo, branch string, number int,
+	conflict bool, contexts []string, log *logrus.Entry) {
+	label := config.LabelSchemeFor(org, repo).CanMergeLabel
+	if err := q.gc.RemoveLabel(org, repo, number, label); err != nil {
+		log.WithError(err).Errorf("Failed to remove the '%s' label.", label)
+	}
+
+	msg, err := config.RenderMessage(org, repo, externalplugins.MessageMergeQueueRemoved,
+		map[string]interface{}{
+			"branch":   branch,
+			"conflict": conflict,
+			"contexts": contexts,
+			"label":    label,
+		})
+	if err != nil {
+		log.WithError(err).Error("Failed to render the message.")
+		return
+	}
+	// Create a new comment to notify the participants.
+	q.reportStatus(org, repo, number, msg, true, log)
+	delete(q.reported, reportedKey(org, repo, number))
+}
+
+// reportPositions reports the positions of the PRs in the queue, the PRs being tested are at the head of the queue.
+func (q *Queue) reportPositions(config *externalplugins.Configuration, org, repo, branch string,
+	prs []github.PullRequest, b *batch, log *logrus.Entry) {
+	var ordered []github.PullRequest
+	testing := map[int]bool{}
+	if b != nil {
+		ordered = append(ordered, b.prs...)
+		for _, pr := range b.prs {
+			testing[pr.Number] = true
+		}
+	}
+	for _, pr := range prs {
+		if !testing[pr.Number] {
+			ordered = append(ordered, pr)
+		}
+	}
+
+	for i, pr := range ordered {
+		var others []string
+		if testing[pr.Number] {
+			for _, other := range b.prs {
+				if other.Number != pr.Number {
+					others = append(others, fmt.Sprintf("#%d", other.Number))
+				}
+			}
+		}
+		msg, err := config.RenderMessage(org, repo, externalplugins.MessageMergeQueueStatus,
+			map[string]interface{}{
+				"branch":   branch,
+				"position": i + 1,
+				"testing":  testing[pr.Number],
+				"others":   others,
+			})
+		if err != nil {
+			log.WithError(err).Error("Failed to render the message.")
+			continue
+		}
+		q.reportStatus(org, repo, pr.Number, msg, false, log)
+	}
+}
+
+// reportStatus updates the status comment of the PR in the queue.
+func (q *Queue) reportStatus(org, repo string, number int, msg string, recreate bool, log *logrus.Entry) {
+	key := reportedKey(org, repo, number)
+	body := fmt.Sprintf("%s\n\n%s", msg, queueCommentIdentifier)
+	if !recreate && q.reported[key] == body {
+		return
+	}
+
+	statusComments, err := q.listStatusComments(org, repo, number)
+	if err != nil {
+		log.WithError(err).Errorf("Failed to list the comments of pull request %d.", number)
+		return
+	}
+	err = externalplugins.UpdateStickyComment(q.gc, org, repo, number, statusComments, body, recreate, log)
+	if err != nil {
+		log.WithError(err).Errorf("Failed to report the status of pull request %d.", number)
+		return
+	}
+	q.reported[key] = body
+}
+
+// clearStatus deletes the status comments of the PR which has left the queue.
+func (q *Queue) clearStatus(org, repo string, number int, log *logrus.Entry) {
+	delete(q.reported, reportedKey(org, repo, number))
+	statusComments, err := q.listStatusComments(org, repo, number)
+	if err != nil {
+		log.WithError(err).Errorf("Failed to list the comments of pull request %d.", number)
+		return
+	}
+	for _, comment := range statusComments {
+		if err := q.gc.DeleteComment(org, repo, comment.ID); err != nil {
+			log.WithError(err).Errorf("Failed to delete comment from %s/%s#%d, ID: %d.", org, repo, number, comment.ID)
+		}
+	}
+}
+
+// listStatusComments lists the comments created by the bot which report the status of the PR in the queue.
+func (q *Queue) listStatusComments(org, repo string, number int) ([]*github.IssueComment, error) {
+	botUserChecker, err := q.gc.BotUserChecker()
+	if err != nil {
+		return nil, err
+	}
+	comments, err := q.gc.ListIssueComments(org, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	return externalplugins.FilterStickyComments(comments, botUserChecker, func(body string) bool {
+		return strings.Contains(body, queueCommentIdentifier)
+	}), nil
+}
+
+// reportedKey returns the key of the PR in the reported status.
+func reportedKey(org, repo string, number int) string {
+	return fmt.Sprintf("%s/%s#%d", org, repo, number)
+}
+
+// batchNumbers returns the numbers of the PRs in the batch.
+func batchNumbers(b *batch) []int {
+	var numbers []int
+	for _, pr := range b.prs {
+		numbers = append(numbers, pr.Number)
+	}
+	return numbers
+}
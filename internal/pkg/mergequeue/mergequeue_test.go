@@ -0,0 +1,501 @@
+package mergequeue
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"k8s.io/test-infra/prow/git/localgit"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+)
+
+const (
+	org           = "org"
+	repo          = "repo"
+	baseBranch    = "master"
+	stagingBranch = "tichi-merge-queue/master"
+	testContext   = "ci/test"
+)
+
+// fakeGitHubClient merges the PRs into the local git repo, and provides the APIs which
+// the fake client does not support.
+type fakeGitHubClient struct {
+	*fakegithub.FakeClient
+	lg      *localgit.LocalGit
+	merged  []int
+	details map[int]github.MergeDetails
+}
+
+func (f *fakeGitHubClient) GetRef(org, repo, ref string) (string, error) {
+	return f.lg.RevParse(org, repo, strings.TrimPrefix(ref, "heads/"))
+}
+
+func (f *fakeGitHubClient) ListCheckRuns(_, _, _ string) (*github.CheckRunList, error) {
+	return &github.CheckRunList{}, nil
+}
+
+func (f *fakeGitHubClient) EditComment(_, _ string, id int, comment string) error {
+	for _, comments := range f.IssueComments {
+		for i := range comments {
+			if comments[i].ID == id {
+				comments[i].Body = comment
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("could not find issue comment %d", id)
+}
+
+func (f *fakeGitHubClient) DeleteComment(org, repo string, id int) error {
+	for number, comments := range f.IssueComments {
+		for i := range comments {
+			if comments[i].ID == id {
+				f.IssueComments[number] = append(comments[:i], comments[i+1:]...)
+				return f.FakeClient.DeleteComment(org, repo, id)
+			}
+		}
+	}
+	return fmt.Errorf("could not find issue comment %d", id)
+}
+
+func (f *fakeGitHubClient) Merge(org, repo string, number int, details github.MergeDetails) error {
+	pr := f.PullRequests[number]
+	if pr.Head.SHA != details.SHA {
+		return github.ModifiedHeadError("head modified")
+	}
+	if _, err := f.lg.Merge(org, repo, details.SHA); err != nil {
+		return err
+	}
+	pr.Merged = true
+	f.merged = append(f.merged, number)
+	f.details[number] = details
+	return nil
+}
+
+func (f *fakeGitHubClient) RemoveLabel(org, repo string, number int, label string) error {
+	pr := f.PullRequests[number]
+	var labels []github.Label
+	for _, l := range pr.Labels {
+		if l.Name != label {
+			labels = append(labels, l)
+		}
+	}
+	pr.Labels = labels
+	return nil
+}
+
+// setStagingStatus sets the state of the required context on the head of the staging branch.
+func (f *fakeGitHubClient) setStagingStatus(t *testing.T, state string) {
+	sha, err := f.lg.RevParse(org, repo, stagingBranch)
+	if err != nil {
+		t.Fatalf("failed to get the staging branch: %v", err)
+	}
+	f.CombinedStatuses[sha] = &github.CombinedStatus{
+		SHA:      sha,
+		Statuses: []github.Status{{Context: testContext, State: state}},
+	}
+}
+
+// statusComment returns the status comment of the PR in the queue.
+func (f *fakeGitHubClient) statusComment(number int) string {
+	var bodies []string
+	for _, comment := range f.IssueComments[number] {
+		if strings.Contains(comment.Body, queueCommentIdentifier) {
+			bodies = append(bodies, comment.Body)
+		}
+	}
+	return strings.Join(bodies, "\n---\n")
+}
+
+// newTestRepo creates a local repo and a fake client with the PRs, each PR adds a file named after it.
+// The PRs with the labels are in the queue, the labels are added in the order of the PRs.
+func newTestRepo(t *testing.T, numbers []int, conflicts map[int]bool) (*fakeGitHubClient, *Queue) {
+	lg, gitClient, err := localgit.NewV2()
+	if err != nil {
+		t.Fatalf("failed to create local git: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := lg.Clean(); err != nil {
+			t.Errorf("failed to clean local git: %v", err)
+		}
+		if err := gitClient.Clean(); err != nil {
+			t.Errorf("failed to clean git client: %v", err)
+		}
+	})
+	if err := lg.MakeFakeRepo(org, repo); err != nil {
+		t.Fatalf("failed to make fake repo: %v", err)
+	}
+
+	fc := &fakeGitHubClient{
+		FakeClient: &fakegithub.FakeClient{
+			PullRequests:     map[int]*github.PullRequest{},
+			IssueComments:    map[int][]github.IssueComment{},
+			CombinedStatuses: map[string]*github.CombinedStatus{},
+			CommitMap:        map[string][]github.RepositoryCommit{},
+			IssueEvents:      map[int][]github.ListedIssueEvent{},
+		},
+		lg:      lg,
+		details: map[int]github.MergeDetails{},
+	}
+	labeledAt := time.Now().Add(-time.Hour)
+	for i, number := range numbers {
+		branch := fmt.Sprintf("pr-%d", number)
+		file := fmt.Sprintf("file-%d", number)
+		if conflicts[number] {
+			file = "conflict"
+		}
+		if err := lg.CheckoutNewBranch(org, repo, branch); err != nil {
+			t.Fatalf("failed to create branch: %v", err)
+		}
+		if err := lg.AddCommit(org, repo, map[string][]byte{file: []byte(branch)}); err != nil {
+			t.Fatalf("failed to add commit: %v", err)
+		}
+		sha, err := lg.RevParse(org, repo, "HEAD")
+		if err != nil {
+			t.Fatalf("failed to get head: %v", err)
+		}
+		runGit(t, lg, "update-ref", fmt.Sprintf("refs/pull/%d/head", number), sha)
+		if err := lg.Checkout(org, repo, baseBranch); err != nil {
+			t.Fatalf("failed to checkout base branch: %v", err)
+		}
+
+		fc.PullRequests[number] = &github.PullRequest{
+			Number: number,
+			State:  "open",
+			Base:   github.PullRequestBranch{Ref: baseBranch},
+			Head:   github.PullRequestBranch{SHA: sha},
+			Labels: []github.Label{{Name: externalplugins.CanMergeLabel}},
+		}
+		fc.IssueEvents[number] = []github.ListedIssueEvent{
+			{
+				Event:     github.IssueActionLabeled,
+				Label:     github.Label{Name: externalplugins.CanMergeLabel},
+				CreatedAt: labeledAt.Add(time.Duration(i) * time.Minute),
+			},
+		}
+	}
+	if len(conflicts) != 0 {
+		if err := lg.AddCommit(org, repo, map[string][]byte{"conflict": []byte("base")}); err != nil {
+			t.Fatalf("failed to add commit: %v", err)
+		}
+	}
+
+	q := NewQueue(fc, gitClient, logrus.WithField("component", ComponentName))
+	return fc, q
+}
+
+func runGit(t *testing.T, lg *localgit.LocalGit, args ...string) string {
+	cmd := exec.Command(lg.Git, args...)
+	cmd.Dir = filepath.Join(lg.Dir, org, repo)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %v, %s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// isInStaging returns true if the head of the PR has been merged into the staging branch.
+func isInStaging(t *testing.T, fc *fakeGitHubClient, number int) bool {
+	cmd := exec.Command(fc.lg.Git, "merge-base", "--is-ancestor", fc.PullRequests[number].Head.SHA, stagingBranch)
+	cmd.Dir = filepath.Join(fc.lg.Dir, org, repo)
+	return cmd.Run() == nil
+}
+
+func newConfig(maxBatchSize int) *externalplugins.Configuration {
+	return &externalplugins.Configuration{
+		TiCommunityMerge: []externalplugins.TiCommunityMerge{
+			{
+				Repos:               []string{"org/repo"},
+				RequireContexts:     []string{testContext},
+				MergeQueue:          true,
+				MaxBatchSize:        maxBatchSize,
+				StagingBranchPrefix: "tichi-merge-queue/",
+				BatchTimeout:        60,
+			},
+		},
+	}
+}
+
+func TestSyncMergesBatch(t *testing.T) {
+	fc, q := newTestRepo(t, []int{1, 2, 3}, nil)
+	// The PR without the 'can-merge' label is not in the queue.
+	fc.PullRequests[3].Labels = nil
+	config := newConfig(5)
+
+	q.Sync(config)
+
+	if !isInStaging(t, fc, 1) || !isInStaging(t, fc, 2) || isInStaging(t, fc, 3) {
+		t.Fatalf("expected the staging branch contains #1 and #2 only")
+	}
+	if !strings.Contains(fc.statusComment(1), "being tested in the merge queue of `master` together with #2") {
+		t.Errorf("unexpected status comment of #1: %q", fc.statusComment(1))
+	}
+	if !strings.Contains(fc.statusComment(2), "together with #1") {
+		t.Errorf("unexpected status comment of #2: %q", fc.statusComment(2))
+	}
+	if fc.statusComment(3) != "" {
+		t.Errorf("expected no status comment on #3, got %q", fc.statusComment(3))
+	}
+
+	// Nothing is merged while the checks are pending.
+	q.Sync(config)
+	if len(fc.merged) != 0 {
+		t.Fatalf("expected nothing merged, got %v", fc.merged)
+	}
+
+	fc.setStagingStatus(t, github.StatusSuccess)
+	q.Sync(config)
+
+	if !reflect.DeepEqual(fc.merged, []int{1, 2}) {
+		t.Errorf("merged PRs mismatch: got %v, want %v", fc.merged, []int{1, 2})
+	}
+	for _, number := range []int{1, 2} {
+		comment := fc.statusComment(number)
+		if !strings.Contains(comment, "merged by the merge queue") || strings.Contains(comment, "---") {
+			t.Errorf("expected the status comment of #%d is edited to the merged message, got %q", number, comment)
+		}
+	}
+}
+
+func TestSyncBisectsFailedBatch(t *testing.T) {
+	fc, q := newTestRepo(t, []int{1, 2, 3, 4}, nil)
+	config := newConfig(4)
+
+	// #3 breaks the checks.
+	for i := 0; i < 20 && (len(fc.merged) != 3 || github.HasLabel(externalplugins.CanMergeLabel,
+		fc.PullRequests[3].Labels)); i++ {
+		q.Sync(config)
+		if _, err := fc.lg.RevParse(org, repo, stagingBranch); err != nil {
+			continue
+		}
+		if isInStaging(t, fc, 3) {
+			fc.setStagingStatus(t, github.StatusFailure)
+		} else {
+			fc.setStagingStatus(t, github.StatusSuccess)
+		}
+	}
+
+	if !reflect.DeepEqual(fc.merged, []int{1, 2, 4}) {
+		t.Errorf("merged PRs mismatch: got %v, want %v", fc.merged, []int{1, 2, 4})
+	}
+	if github.HasLabel(externalplugins.CanMergeLabel, fc.PullRequests[3].Labels) {
+		t.Errorf("expected the label of #3 removed")
+	}
+	comment := fc.statusComment(3)
+	if !strings.Contains(comment, "the required checks did not pass on the staging branch: ci/test") {
+		t.Errorf("unexpected status comment of #3: %q", comment)
+	}
+}
+
+func TestSyncBisectsFailingHalf(t *testing.T) {
+	fc, q := newTestRepo(t, []int{1, 2, 3, 4, 5}, nil)
+	config := newConfig(4)
+
+	// #3 breaks the checks, record the PRs of every batch.
+	var batches [][]int
+	var lastStagingSHA string
+	for i := 0; i < 20 && len(fc.merged) != 4; i++ {
+		q.Sync(config)
+		stagingSHA, err := fc.lg.RevParse(org, repo, stagingBranch)
+		if err != nil || stagingSHA == lastStagingSHA {
+			continue
+		}
+		lastStagingSHA = stagingSHA
+		var batch []int
+		for _, number := range []int{1, 2, 3, 4, 5} {
+			if !fc.PullRequests[number].Merged && isInStaging(t, fc, number) {
+				batch = append(batch, number)
+			}
+		}
+		batches = append(batches, batch)
+		if isInStaging(t, fc, 3) {
+			fc.setStagingStatus(t, github.StatusFailure)
+		} else {
+			fc.setStagingStatus(t, github.StatusSuccess)
+		}
+	}
+
+	expected := [][]int{{1, 2, 3, 4}, {1, 2}, {3}, {4, 5}}
+	if !reflect.DeepEqual(batches, expected) {
+		t.Errorf("batches mismatch: got %v, want %v", batches, expected)
+	}
+	if !reflect.DeepEqual(fc.merged, []int{1, 2, 4, 5}) {
+		t.Errorf("merged PRs mismatch: got %v, want %v", fc.merged, []int{1, 2, 4, 5})
+	}
+}
+
+func TestSyncOrdersByLabeledTime(t *testing.T) {
+	fc, q := newTestRepo(t, []int{1, 2, 3}, nil)
+	// The label of #1 is removed and added again after the others.
+	fc.IssueEvents[1] = append(fc.IssueEvents[1], github.ListedIssueEvent{
+		Event:     github.IssueActionLabeled,
+		Label:     github.Label{Name: externalplugins.CanMergeLabel},
+		CreatedAt: time.Now(),
+	})
+	config := newConfig(1)
+
+	q.Sync(config)
+
+	expected := map[int]string{
+		2: "being tested in the merge queue of `master`.",
+		3: "number 2 in the merge queue of `master`.",
+		1: "number 3 in the merge queue of `master`.",
+	}
+	for number, msg := range expected {
+		if !strings.Contains(fc.statusComment(number), msg) {
+			t.Errorf("expected the status comment of #%d contains %q, got %q", number, msg, fc.statusComment(number))
+		}
+	}
+}
+
+func TestSyncRemovesConflictingPR(t *testing.T) {
+	fc, q := newTestRepo(t, []int{1, 2}, map[int]bool{1: true})
+	config := newConfig(5)
+
+	q.Sync(config)
+
+	if github.HasLabel(externalplugins.CanMergeLabel, fc.PullRequests[1].Labels) {
+		t.Errorf("expected the label of #1 removed")
+	}
+	if !strings.Contains(fc.statusComment(1), "it conflicts with `master`") {
+		t.Errorf("unexpected status comment of #1: %q", fc.statusComment(1))
+	}
+	if isInStaging(t, fc, 1) || !isInStaging(t, fc, 2) {
+		t.Errorf("expected the staging branch contains #2 only")
+	}
+	if !strings.Contains(fc.statusComment(2), "being tested in the merge queue of `master`.") {
+		t.Errorf("unexpected status comment of #2: %q", fc.statusComment(2))
+	}
+}
+
+func TestSyncReportsPositions(t *testing.T) {
+	fc, q := newTestRepo(t, []int{1, 2, 3}, nil)
+	config := newConfig(1)
+
+	q.Sync(config)
+
+	expected := map[int]string{
+		1: "being tested in the merge queue of `master`.",
+		2: "number 2 in the merge queue of `master`.",
+		3: "number 3 in the merge queue of `master`.",
+	}
+	for number, msg := range expected {
+		if !strings.Contains(fc.statusComment(number), msg) {
+			t.Errorf("expected the status comment of #%d contains %q, got %q", number, msg, fc.statusComment(number))
+		}
+	}
+
+	// The positions move forward after #1 is merged, and the comments are edited in place.
+	fc.setStagingStatus(t, github.StatusSuccess)
+	q.Sync(config)
+	q.Sync(config)
+
+	expected = map[int]string{
+		2: "being tested in the merge queue of `master`.",
+		3: "number 2 in the merge queue of `master`.",
+	}
+	for number, msg := range expected {
+		comment := fc.statusComment(number)
+		if !strings.Contains(comment, msg) || strings.Contains(comment, "---") {
+			t.Errorf("expected the only status comment of #%d contains %q, got %q", number, msg, comment)
+		}
+	}
+}
+
+func TestSyncDiscardsBatch(t *testing.T) {
+	fc, q := newTestRepo(t, []int{1, 2}, nil)
+	config := newConfig(5)
+
+	q.Sync(config)
+	stagingSHA, err := fc.lg.RevParse(org, repo, stagingBranch)
+	if err != nil {
+		t.Fatalf("failed to get the staging branch: %v", err)
+	}
+
+	// The merge of #1 is canceled while the batch is being tested.
+	fc.PullRequests[1].Labels = nil
+	fc.setStagingStatus(t, github.StatusSuccess)
+	q.Sync(config)
+
+	if len(fc.merged) != 0 {
+		t.Errorf("expected nothing merged, got %v", fc.merged)
+	}
+	if fc.statusComment(1) != "" {
+		t.Errorf("expected the status comment of #1 deleted, got %q", fc.statusComment(1))
+	}
+	newStagingSHA, err := fc.lg.RevParse(org, repo, stagingBranch)
+	if err != nil {
+		t.Fatalf("failed to get the staging branch: %v", err)
+	}
+	if newStagingSHA == stagingSHA || isInStaging(t, fc, 1) || !isInStaging(t, fc, 2) {
+		t.Errorf("expected a new batch with #2 only")
+	}
+}
+
+func TestSyncBatchTimeout(t *testing.T) {
+	fc, q := newTestRepo(t, []int{1}, nil)
+	config := newConfig(5)
+	now := time.Now()
+	q.now = func() time.Time {
+		return now
+	}
+
+	q.Sync(config)
+	now = now.Add(61 * time.Minute)
+	q.Sync(config)
+
+	if github.HasLabel(externalplugins.CanMergeLabel, fc.PullRequests[1].Labels) {
+		t.Errorf("expected the label of #1 removed")
+	}
+	if !strings.Contains(fc.statusComment(1), "did not pass on the staging branch: ci/test") {
+		t.Errorf("unexpected status comment of #1: %q", fc.statusComment(1))
+	}
+}
+
+func TestSyncMergeMethod(t *testing.T) {
+	fc, q := newTestRepo(t, []int{1, 2}, nil)
+	fc.PullRequests[1].Title = "Fix the bug"
+	fc.PullRequests[1].Body = "### Release note\n\nFix the bug."
+	fc.PullRequests[1].User = github.User{Login: "author"}
+	fc.PullRequests[1].Labels = append(fc.PullRequests[1].Labels,
+		github.Label{Name: externalplugins.MergeMethodLabel(github.MergeSquash)})
+	fc.CommitMap["org/repo#1"] = []github.RepositoryCommit{
+		{
+			Author: github.User{Login: "helper"},
+			Commit: github.GitCommit{Author: github.CommitAuthor{Name: "Helper", Email: "helper@example.com"}},
+		},
+	}
+	config := newConfig(5)
+	config.TiCommunityMerge[0].MergeMethod = string(github.MergeRebase)
+	config.TiCommunityMerge[0].SquashCommitTemplate = &externalplugins.CommitMessageTemplate{
+		Title: "{{ .Title }} (#{{ .Number }})",
+		Body:  "{{ index .Sections \"Release note\" }}",
+	}
+
+	q.Sync(config)
+	fc.setStagingStatus(t, github.StatusSuccess)
+	q.Sync(config)
+
+	expected := map[int]github.MergeDetails{
+		1: {
+			SHA:           fc.PullRequests[1].Head.SHA,
+			MergeMethod:   string(github.MergeSquash),
+			CommitTitle:   "Fix the bug (#1)",
+			CommitMessage: "Fix the bug.\n\nCo-authored-by: Helper <helper@example.com>",
+		},
+		2: {
+			SHA:         fc.PullRequests[2].Head.SHA,
+			MergeMethod: string(github.MergeRebase),
+		},
+	}
+	if !reflect.DeepEqual(fc.details, expected) {
+		t.Errorf("merge details mismatch: got %+v, want %+v", fc.details, expected)
+	}
+}
@@ -195,3 +195,40 @@ func TestLoadOwnersFailed(t *testing.T) {
 		})
 	}
 }
+
+func TestOwnersRoles(t *testing.T) {
+	owners := &Owners{
+		Committers: []string{"committer1", "leader1"},
+		Reviewers:  []string{"committer1", "leader1", "reviewer1"},
+	}
+	if role := owners.RoleOf("committer1"); role != RoleCommitter {
+		t.Errorf("role mismatch without roles: got %s, want %s", role, RoleCommitter)
+	}
+	if role := owners.RoleOf("reviewer1"); role != RoleReviewer {
+		t.Errorf("role mismatch without roles: got %s, want %s", role, RoleReviewer)
+	}
+
+	owners.InsertRole("leader1", RoleCommitter)
+	owners.InsertRole("leader1", RoleLeader)
+	owners.InsertRole("leader1", RoleReviewer)
+	if role := owners.RoleOf("leader1"); role != RoleLeader {
+		t.Errorf("role mismatch: got %s, want the highest role %s", role, RoleLeader)
+	}
+}
+
+func TestOwnersAffiliations(t *testing.T) {
+	var nilOwners *Owners
+	if affiliation := nilOwners.AffiliationOf("reviewer1"); affiliation != "" {
+		t.Errorf("affiliation mismatch without owners: got %s", affiliation)
+	}
+
+	owners := &Owners{}
+	owners.InsertAffiliation("reviewer1", " company-a ")
+	owners.InsertAffiliation("reviewer2", "")
+	if affiliation := owners.AffiliationOf("reviewer1"); affiliation != "company-a" {
+		t.Errorf("affiliation mismatch: got %s, want company-a", affiliation)
+	}
+	if affiliation := owners.AffiliationOf("reviewer2"); affiliation != "" {
+		t.Errorf("affiliation mismatch: got %s, want unknown affiliation", affiliation)
+	}
+}
@@ -1,5 +1,27 @@
 package ownersclient
 
+import "strings"
+
+// Roles of the owners.
+const (
+	// RoleLeader is the role of the tech leaders.
+	RoleLeader = "leader"
+	// RoleCoLeader is the role of the co-leaders.
+	RoleCoLeader = "co-leader"
+	// RoleCommitter is the role of the committers.
+	RoleCommitter = "committer"
+	// RoleReviewer is the role of the reviewers.
+	RoleReviewer = "reviewer"
+)
+
+// roleRanks specifies the rank of roles, the higher role has the bigger rank.
+var roleRanks = map[string]int{
+	RoleReviewer:  1,
+	RoleCommitter: 2,
+	RoleCoLeader:  3,
+	RoleLeader:    4,
+}
+
 // OwnersResponse specifies the response to the request to get owners.
 type OwnersResponse struct {
 	Data    Owners `json:"data,omitempty"`
@@ -11,4 +33,80 @@ type Owners struct {
 	Committers []string `json:"committers,omitempty"`
 	Reviewers  []string `json:"reviewers,omitempty"`
 	NeedsLgtm  int      `json:"needsLGTM,omitempty"`
+	// Roles specifies the highest role of each owner.
+	Roles map[string]string `json:"roles,omitempty"`
+	// Affiliations specifies the affiliation of each owner, such as the company the owner works for.
+	Affiliations map[string]string `json:"affiliations,omitempty"`
+	// Sigs specifies the reviewers of each sig that the PR belongs to.
+	Sigs map[string][]string `json:"sigs,omitempty"`
+	// Preferences specifies the preferences of the owners who have set them.
+	Preferences map[string]ReviewerPreferences `json:"preferences,omitempty"`
+}
+
+// ReviewerPreferences specifies how a reviewer wants to be requested automatically by the bot.
+type ReviewerPreferences struct {
+	// OptOut specifies that the reviews are never requested from the reviewer automatically.
+	OptOut bool `json:"opt_out,omitempty"`
+	// SkipWeekends specifies that the reviews are not requested from the reviewer on weekends.
+	SkipWeekends bool `json:"skip_weekends,omitempty"`
+	// TimeZone specifies the time zone of the reviewer used to determine the weekends, defaults to UTC.
+	TimeZone string `json:"time_zone,omitempty"`
+	// MaxWeeklyRequests specifies the maximum number of the reviews requested in the past week,
+	// zero means unlimited.
+	MaxWeeklyRequests int `json:"max_weekly_requests,omitempty"`
+	// Sigs specifies that the reviews are only requested for the PRs of these sigs.
+	Sigs []string `json:"sigs,omitempty"`
+	// NoReminders specifies that the reviewer is not pinged by the review reminders.
+	NoReminders bool `json:"no_reminders,omitempty"`
+}
+
+// IsKnownRole returns true if the role is one of the roles of owners.
+func IsKnownRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// InsertRole records the role of the user if it is higher than the recorded one.
+func (o *Owners) InsertRole(login, role string) {
+	if o.Roles == nil {
+		o.Roles = map[string]string{}
+	}
+	if current, ok := o.Roles[login]; ok && roleRanks[current] >= roleRanks[role] {
+		return
+	}
+	o.Roles[login] = role
+}
+
+// RoleOf returns the role of the user. When the roles are not provided, the committers
+// are considered as committers and the others are considered as reviewers.
+func (o *Owners) RoleOf(login string) string {
+	if role, ok := o.Roles[login]; ok {
+		return role
+	}
+	for _, committer := range o.Committers {
+		if committer == login {
+			return RoleCommitter
+		}
+	}
+	return RoleReviewer
+}
+
+// InsertAffiliation records the affiliation of the user, the empty affiliation is ignored.
+func (o *Owners) InsertAffiliation(login, affiliation string) {
+	affiliation = strings.TrimSpace(affiliation)
+	if affiliation == "" {
+		return
+	}
+	if o.Affiliations == nil {
+		o.Affiliations = map[string]string{}
+	}
+	o.Affiliations[login] = affiliation
+}
+
+// AffiliationOf returns the affiliation of the user, or empty if the affiliation is unknown.
+func (o *Owners) AffiliationOf(login string) string {
+	if o == nil {
+		return ""
+	}
+	return o.Affiliations[login]
 }